@@ -0,0 +1,138 @@
+package acme
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	acmeclient "golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ALPNProto is the application protocol of the TLS-ALPN-01 challenges,
+// that the TLS listener needs to offer.
+const ALPNProto = acmeclient.ALPNProto
+
+// DefaultRenewBefore is the default time before the expiry of a
+// certificate when it is renewed.
+const DefaultRenewBefore = 30 * 24 * time.Hour
+
+var (
+	errMissingCache   = errors.New("acme: cache required")
+	errMissingDomains = errors.New("acme: domains or a host provider required")
+)
+
+// HostProvider provides the host names of the routes. It is implemented
+// by *routing.Routing.
+type HostProvider interface {
+	Hosts() []string
+}
+
+// Options for creating a Manager.
+type Options struct {
+
+	// The contact email address of the ACME account.
+	Email string
+
+	// The domains that the certificates are obtained for.
+	Domains []string
+
+	// When set, the certificates are obtained for the host names of
+	// the routes, too, as returned by the host provider.
+	Hosts HostProvider
+
+	// The cache that the account key and the certificates are stored
+	// in, and shared by the skipper instances. Required.
+	Cache autocert.Cache
+
+	// The directory URL of the ACME server. Default: Let's Encrypt.
+	DirectoryURL string
+
+	// The time before the expiry of a certificate when it is renewed.
+	// Default: 30 days.
+	RenewBefore time.Duration
+}
+
+// Manager obtains and renews the certificates with the ACME protocol,
+// answering the HTTP-01 and the TLS-ALPN-01 challenges.
+type Manager struct {
+	manager *autocert.Manager
+	domains map[string]bool
+	hosts   HostProvider
+}
+
+// New creates a Manager.
+func New(o Options) (*Manager, error) {
+	if o.Cache == nil {
+		return nil, errMissingCache
+	}
+
+	if len(o.Domains) == 0 && o.Hosts == nil {
+		return nil, errMissingDomains
+	}
+
+	if o.DirectoryURL == "" {
+		o.DirectoryURL = autocert.DefaultACMEDirectory
+	}
+
+	if o.RenewBefore <= 0 {
+		o.RenewBefore = DefaultRenewBefore
+	}
+
+	m := &Manager{
+		domains: make(map[string]bool),
+		hosts:   o.Hosts,
+	}
+
+	for _, d := range o.Domains {
+		m.domains[strings.ToLower(d)] = true
+	}
+
+	m.manager = &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       o.Cache,
+		HostPolicy:  m.hostPolicy,
+		RenewBefore: o.RenewBefore,
+		Email:       o.Email,
+		Client:      &acmeclient.Client{DirectoryURL: o.DirectoryURL},
+	}
+
+	return m, nil
+}
+
+// only the configured domains and the route hosts are allowed, to
+// prevent obtaining certificates for arbitrary server names sent by
+// the clients
+func (m *Manager) hostPolicy(_ context.Context, host string) error {
+	host = strings.ToLower(host)
+	if m.domains[host] {
+		return nil
+	}
+
+	if m.hosts != nil {
+		for _, h := range m.hosts.Hosts() {
+			if h == host {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("acme: host not allowed: %s", host)
+}
+
+// GetCertificate returns the certificate for the server name sent by
+// the client, obtaining it when necessary, or the challenge certificate
+// of a TLS-ALPN-01 challenge.
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return m.manager.GetCertificate(hello)
+}
+
+// HTTPHandler returns a handler that answers the HTTP-01 challenges,
+// and forwards the rest of the requests to the fallback handler.
+func (m *Manager) HTTPHandler(fallback http.Handler) http.Handler {
+	return m.manager.HTTPHandler(fallback)
+}
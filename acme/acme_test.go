@@ -0,0 +1,45 @@
+package acme
+
+import (
+	"context"
+	"testing"
+)
+
+type hosts []string
+
+func (h hosts) Hosts() []string { return h }
+
+func TestInvalidOptions(t *testing.T) {
+	for _, o := range []Options{
+		{Domains: []string{"example.org"}},
+		{Cache: NewDirCache("/tmp/skipper-acme")},
+	} {
+		if _, err := New(o); err == nil {
+			t.Error("failed to fail", o)
+		}
+	}
+}
+
+func TestHostPolicy(t *testing.T) {
+	m, err := New(Options{
+		Domains: []string{"Example.org"},
+		Hosts:   hosts{"www.example.org"},
+		Cache:   NewDirCache("/tmp/skipper-acme"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		host    string
+		allowed bool
+	}{
+		{"example.org", true},
+		{"www.example.org", true},
+		{"api.example.org", false},
+	} {
+		if err := m.hostPolicy(context.Background(), test.host); (err == nil) != test.allowed {
+			t.Error("invalid host policy", test.host, err)
+		}
+	}
+}
@@ -0,0 +1,444 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	cacheTimeout    = 10 * time.Second
+	s3Service       = "s3"
+	s3Algorithm     = "AWS4-HMAC-SHA256"
+	amzDateFormat   = "20060102T150405Z"
+	amzScopeFormat  = "20060102"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+var (
+	errMissingBucket      = errors.New("acme: S3 bucket required")
+	errMissingRegion      = errors.New("acme: S3 region required")
+	errMissingCredentials = errors.New("acme: S3 credentials required")
+	errMissingEndpoints   = errors.New("acme: etcd endpoints required")
+)
+
+// EtcdCacheOptions for creating a cache in etcd.
+type EtcdCacheOptions struct {
+
+	// The etcd endpoints. Required.
+	Endpoints []string
+
+	// The key prefix of the stored entries. Default: /skipper/acme.
+	Prefix string
+}
+
+type etcdCache struct {
+	endpoints []string
+	prefix    string
+	client    *http.Client
+}
+
+type etcdResponse struct {
+	Node struct {
+		Value string `json:"value"`
+	} `json:"node"`
+}
+
+// S3CacheOptions for creating a cache in an S3 bucket.
+type S3CacheOptions struct {
+
+	// The name of the bucket. Required.
+	Bucket string
+
+	// The key prefix of the stored objects.
+	Prefix string
+
+	// The region of the bucket. Required.
+	Region string
+
+	// The endpoint of the S3 compatible service. Default:
+	// https://s3.<region>.amazonaws.com.
+	Endpoint string
+
+	// The credentials. Default: the AWS_ACCESS_KEY_ID,
+	// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment
+	// variables.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+type s3Cache struct {
+	options S3CacheOptions
+	client  *http.Client
+	now     func() time.Time
+}
+
+// NewDirCache creates a cache storing the entries as files in a
+// directory. When the directory doesn't exist, it is created.
+func NewDirCache(dir string) autocert.Cache {
+	return autocert.DirCache(dir)
+}
+
+// NewEtcdCache creates a cache storing the entries in etcd, with the v2
+// API.
+func NewEtcdCache(o EtcdCacheOptions) (autocert.Cache, error) {
+	if len(o.Endpoints) == 0 {
+		return nil, errMissingEndpoints
+	}
+
+	if o.Prefix == "" {
+		o.Prefix = "/skipper/acme"
+	}
+
+	return &etcdCache{
+		endpoints: o.Endpoints,
+		prefix:    "/" + strings.Trim(o.Prefix, "/"),
+		client:    &http.Client{Timeout: cacheTimeout},
+	}, nil
+}
+
+// tries the endpoints in order, until one of them responds
+func (c *etcdCache) do(ctx context.Context, method, key string, body url.Values) (*http.Response, error) {
+	var lastErr error
+	for _, e := range c.endpoints {
+		var b *strings.Reader
+		if body != nil {
+			b = strings.NewReader(body.Encode())
+		} else {
+			b = strings.NewReader("")
+		}
+
+		u := strings.TrimRight(e, "/") + "/v2/keys" + c.prefix + "/" + url.PathEscape(key)
+		req, err := http.NewRequest(method, u, b)
+		if err != nil {
+			return nil, err
+		}
+
+		if body != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+
+		rsp, err := c.client.Do(req.WithContext(ctx))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		return rsp, nil
+	}
+
+	return nil, lastErr
+}
+
+func (c *etcdCache) Get(ctx context.Context, key string) ([]byte, error) {
+	rsp, err := c.do(ctx, "GET", key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode == http.StatusNotFound {
+		return nil, autocert.ErrCacheMiss
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("acme: unexpected status from etcd: %d", rsp.StatusCode)
+	}
+
+	var r etcdResponse
+	if err := json.NewDecoder(rsp.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(r.Node.Value)
+}
+
+func (c *etcdCache) Put(ctx context.Context, key string, data []byte) error {
+	rsp, err := c.do(ctx, "PUT", key, url.Values{"value": {base64.StdEncoding.EncodeToString(data)}})
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("acme: unexpected status from etcd: %d", rsp.StatusCode)
+	}
+
+	return nil
+}
+
+func (c *etcdCache) Delete(ctx context.Context, key string) error {
+	rsp, err := c.do(ctx, "DELETE", key, nil)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("acme: unexpected status from etcd: %d", rsp.StatusCode)
+	}
+
+	return nil
+}
+
+// NewS3Cache creates a cache storing the entries as objects in an S3
+// bucket.
+func NewS3Cache(o S3CacheOptions) (autocert.Cache, error) {
+	if o.Bucket == "" {
+		return nil, errMissingBucket
+	}
+
+	if o.Region == "" {
+		return nil, errMissingRegion
+	}
+
+	if o.AccessKeyID == "" && o.SecretAccessKey == "" {
+		o.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		o.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		o.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+
+	if o.AccessKeyID == "" || o.SecretAccessKey == "" {
+		return nil, errMissingCredentials
+	}
+
+	if o.Endpoint == "" {
+		o.Endpoint = "https://s3." + o.Region + ".amazonaws.com"
+	}
+
+	o.Endpoint = strings.TrimRight(o.Endpoint, "/")
+	o.Prefix = strings.Trim(o.Prefix, "/")
+	return &s3Cache{
+		options: o,
+		client:  &http.Client{Timeout: cacheTimeout},
+		now:     time.Now,
+	}, nil
+}
+
+// escapes every character, except the unreserved ones, as required by
+// the AWS signature
+func awsEscape(s string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// signs the request with the AWS signature version 4
+func (c *s3Cache) sign(req *http.Request, payloadHash string) {
+	now := c.now().UTC()
+	amzDate := now.Format(amzDateFormat)
+	scope := now.Format(amzScopeFormat) + "/" + c.options.Region + "/" + s3Service + "/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.options.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.options.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(req.Header.Get(k))
+	}
+
+	var names []string
+	for k := range headers {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+	var canonicalHeaders bytes.Buffer
+	for _, n := range names {
+		canonicalHeaders.WriteString(n + ":" + headers[n] + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.options.SecretAccessKey), now.Format(amzScopeFormat))
+	key = hmacSHA256(key, c.options.Region)
+	key = hmacSHA256(key, s3Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm,
+		c.options.AccessKeyID,
+		scope,
+		signedHeaders,
+		signature,
+	))
+}
+
+func (c *s3Cache) do(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	segments := []string{c.options.Bucket}
+	if c.options.Prefix != "" {
+		segments = append(segments, strings.Split(c.options.Prefix, "/")...)
+	}
+
+	segments = append(segments, key)
+
+	var path, rawPath bytes.Buffer
+	for _, s := range segments {
+		path.WriteString("/" + s)
+		rawPath.WriteString("/" + awsEscape(s))
+	}
+
+	u, err := url.Parse(c.options.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path = path.String()
+	u.RawPath = rawPath.String()
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	payloadHash := unsignedPayload
+	if body != nil {
+		payloadHash = sha256Hex(body)
+	}
+
+	c.sign(req, payloadHash)
+	return c.client.Do(req.WithContext(ctx))
+}
+
+func (c *s3Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	rsp, err := c.do(ctx, "GET", key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode == http.StatusNotFound {
+		return nil, autocert.ErrCacheMiss
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("acme: unexpected status from S3: %d", rsp.StatusCode)
+	}
+
+	return ioutil.ReadAll(rsp.Body)
+}
+
+func (c *s3Cache) Put(ctx context.Context, key string, data []byte) error {
+	rsp, err := c.do(ctx, "PUT", key, data)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("acme: unexpected status from S3: %d", rsp.StatusCode)
+	}
+
+	return nil
+}
+
+func (c *s3Cache) Delete(ctx context.Context, key string) error {
+	rsp, err := c.do(ctx, "DELETE", key, nil)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusNoContent && rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("acme: unexpected status from S3: %d", rsp.StatusCode)
+	}
+
+	return nil
+}
+
+// ParseCache creates a cache from its description:
+//
+//     /var/cache/skipper/acme         a directory
+//     s3://bucket/prefix?region=...   an S3 bucket, the region defaults to AWS_REGION
+//     etcd:///skipper/acme            etcd, using the etcd endpoints
+//
+func ParseCache(s string, etcdEndpoints []string) (autocert.Cache, error) {
+	switch {
+	case strings.HasPrefix(s, "s3://"):
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+
+		region := u.Query().Get("region")
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+
+		return NewS3Cache(S3CacheOptions{
+			Bucket:   u.Host,
+			Prefix:   u.Path,
+			Region:   region,
+			Endpoint: u.Query().Get("endpoint"),
+		})
+	case strings.HasPrefix(s, "etcd://"):
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewEtcdCache(EtcdCacheOptions{
+			Endpoints: etcdEndpoints,
+			Prefix:    u.Path,
+		})
+	default:
+		return NewDirCache(s), nil
+	}
+}
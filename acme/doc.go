@@ -0,0 +1,37 @@
+/*
+Package acme obtains and renews the TLS certificates of the proxy
+listener with the ACME protocol, e.g. from Let's Encrypt.
+
+When enabled, the certificates are requested on the first TLS handshake
+with a server name that they are not available for yet, and renewed 30
+days before they expire:
+
+    skipper -address :443 -enable-acme -acme-domains example.org,www.example.org -acme-email admin@example.org -tls-redirect-address :80
+
+The certificates are obtained only for the configured domains, and,
+optionally, with the -acme-hosts-from-routes flag, for the hosts of the
+routes, that are matched by a Host predicate with a literal host name,
+e.g. Host("^www[.]example[.]org$"). The server names sent by the
+clients, that don't match any of these, are rejected, to prevent
+requesting certificates for arbitrary names.
+
+The challenges of the ACME server are answered in two ways: the HTTP-01
+challenges on the plaintext listener set with -tls-redirect-address,
+that needs to be reachable on port 80, and the TLS-ALPN-01 challenges
+on the TLS listener itself. The certificates loaded from files, with
+-tls-cert or -tls-cert-dir, take precedence over the ones obtained with
+ACME.
+
+The account key and the certificates are stored in a cache, so that
+they survive restarts, and can be shared by multiple instances of
+skipper. The cache is set with the -acme-cache flag, as one of:
+
+    /var/cache/skipper/acme              a local directory, the default
+    s3://bucket/prefix?region=eu-west-1  an S3 bucket
+    etcd:///skipper/acme                 the etcd cluster set with -etcd-urls
+
+The S3 credentials and the default region are taken from the standard
+AWS environment variables. A different ACME server, e.g. the staging environment of
+Let's Encrypt, can be set with the -acme-directory-url flag.
+*/
+package acme
@@ -0,0 +1,413 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/maintenance"
+	"github.com/zalando/skipper/namespace"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/secrets"
+	"github.com/zalando/skipper/tracing"
+)
+
+const (
+	routesPath    = "/routes"
+	overridesPath = "/overrides"
+
+	// the maximum size of a routing document in a request
+	maxBodySize = 1 << 22
+)
+
+var (
+	errMissingRouting = errors.New("admin: missing routing")
+	errMissingClient  = errors.New("admin: missing client")
+	errMissingTokens  = errors.New("admin: missing tokens")
+)
+
+// Store is a writable data client, where the changes made with the admin
+// API can be persisted, e.g. the etcd client.
+type Store interface {
+	Upsert(*eskip.Route) error
+	Delete(id string) error
+}
+
+// Options for the admin API.
+type Options struct {
+
+	// The routing, used to list and to validate the routes. Required.
+	Routing *routing.Routing
+
+	// The in-memory data client, that applies the changes. It needs to
+	// be the last data client of the routing. Required.
+	Client *Client
+
+	// When set, the changes can be persisted with the persist=true
+	// query parameter.
+	Store Store
+
+	// The source of the bearer tokens accepted by the API, e.g.
+	// file:/etc/skipper/admin-tokens. Required.
+	Tokens secrets.Source
+
+	// The tracer of the proxy. When it supports it, its sample rate
+	// can be changed with the API.
+	Tracer tracing.Tracer
+
+	// When set, the maintenance mode of the proxy can be switched
+	// with the API.
+	Maintenance *maintenance.Mode
+
+	// When set, the tokens of the namespaces are accepted, too, and
+	// they grant access only to the routes of their namespace, on the
+	// /routes and the /overrides paths.
+	Namespaces *namespace.Namespaces
+}
+
+type handler struct {
+	routing      *routing.Routing
+	client       *Client
+	store        Store
+	tokens       secrets.Source
+	sampler      tracing.SampleRateController
+	initialDebug debugSettings
+	maintenance  *maintenance.Mode
+	namespaces   *namespace.Namespaces
+}
+
+type overrides struct {
+	Routes  string   `json:"routes"`
+	Deleted []string `json:"deleted"`
+}
+
+// New creates the handler of the admin API.
+func New(o Options) (http.Handler, error) {
+	if o.Routing == nil {
+		return nil, errMissingRouting
+	}
+
+	if o.Client == nil {
+		return nil, errMissingClient
+	}
+
+	if o.Tokens == nil {
+		return nil, errMissingTokens
+	}
+
+	h := &handler{
+		routing:     o.Routing,
+		client:      o.Client,
+		store:       o.Store,
+		tokens:      o.Tokens,
+		sampler:     sampleRateController(o.Tracer),
+		maintenance: o.Maintenance,
+		namespaces:  o.Namespaces,
+	}
+
+	// the settings at startup, restored when the debug settings are reset
+	h.initialDebug = h.debugSettings()
+	return h, nil
+}
+
+func bearerToken(r *http.Request) []byte {
+	a := r.Header.Get("Authorization")
+	if !strings.HasPrefix(a, "Bearer ") {
+		return nil
+	}
+
+	return []byte(strings.TrimSpace(strings.TrimPrefix(a, "Bearer ")))
+}
+
+// the tokens are reloaded on every request, so that they can be rotated
+// without a restart
+func matchToken(token []byte, s secrets.Source) bool {
+	keys, err := s.Keys()
+	if err != nil {
+		log.Errorf("error while loading the admin tokens: %v", err)
+		return false
+	}
+
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare(token, k) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// returns whether the request is authorized, and, when it was authorized
+// with the token of a namespace, the name of the namespace
+func (h *handler) authorize(r *http.Request) (string, bool) {
+	token := bearerToken(r)
+	if len(token) == 0 {
+		return "", false
+	}
+
+	if matchToken(token, h.tokens) {
+		return "", true
+	}
+
+	if h.namespaces == nil {
+		return "", false
+	}
+
+	tokens := h.namespaces.Tokens()
+	for _, name := range h.namespaces.Names() {
+		if s, ok := tokens[name]; ok && matchToken(token, s) {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+func routeID(path, prefix string) (string, bool) {
+	if path == prefix {
+		return "", true
+	}
+
+	if !strings.HasPrefix(path, prefix+"/") {
+		return "", false
+	}
+
+	return strings.TrimPrefix(path, prefix+"/"), true
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ns, ok := h.authorize(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if id, ok := routeID(r.URL.Path, routesPath); ok {
+		h.serveRoutes(w, r, ns, id)
+		return
+	}
+
+	if id, ok := routeID(r.URL.Path, overridesPath); ok {
+		h.serveOverrides(w, r, ns, id)
+		return
+	}
+
+	// the rest of the API affects all the routes
+	if ns != "" {
+		forbidden(w)
+		return
+	}
+
+	if id, ok := routeID(r.URL.Path, versionsPath); ok {
+		h.serveVersions(w, r, id)
+		return
+	}
+
+	if r.URL.Path == rollbackPath {
+		h.serveRollback(w, r)
+		return
+	}
+
+	if r.URL.Path == snapshotPath {
+		h.serveSnapshot(w, r)
+		return
+	}
+
+	if r.URL.Path == debugPath {
+		h.serveDebug(w, r)
+		return
+	}
+
+	if r.URL.Path == maintenancePath {
+		h.serveMaintenance(w, r)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+func methodNotAllowed(w http.ResponseWriter) {
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+func (h *handler) serveRoutes(w http.ResponseWriter, r *http.Request, ns, id string) {
+	if id != "" && !h.inNamespace(ns, id) {
+		forbidden(w)
+		return
+	}
+
+	switch {
+	case r.Method == "GET" && id == "":
+		writeRoutes(w, h.namespaceRoutes(ns, h.routing.Routes()))
+	case r.Method == "GET":
+		for _, ri := range h.routing.Routes() {
+			if ri.Id == id {
+				writeRoutes(w, []*eskip.Route{ri})
+				return
+			}
+		}
+
+		http.NotFound(w, r)
+	case r.Method == "POST" && id == "":
+		routes, ok := readRoutes(w, r)
+		if !ok {
+			return
+		}
+
+		for _, ri := range routes {
+			if ri.Id == "" {
+				http.Error(w, "missing route id", http.StatusBadRequest)
+				return
+			}
+
+			if !h.validRoute(w, ns, ri) {
+				return
+			}
+		}
+
+		h.upsert(w, r, routes)
+	case r.Method == "PUT" && id != "":
+		routes, ok := readRoutes(w, r)
+		if !ok {
+			return
+		}
+
+		if len(routes) != 1 {
+			http.Error(w, "a single route expected", http.StatusBadRequest)
+			return
+		}
+
+		if routes[0].Id != "" && routes[0].Id != id {
+			http.Error(w, "route id mismatch", http.StatusBadRequest)
+			return
+		}
+
+		routes[0].Id = id
+		if !h.validRoute(w, ns, routes[0]) {
+			return
+		}
+
+		h.upsert(w, r, routes)
+	case r.Method == "DELETE" && id != "":
+		h.delete(w, r, id)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+func (h *handler) serveOverrides(w http.ResponseWriter, r *http.Request, ns, id string) {
+	if id != "" && !h.inNamespace(ns, id) {
+		forbidden(w)
+		return
+	}
+
+	switch {
+	case r.Method == "GET" && id == "":
+		routes, deleted := h.client.Overrides()
+		routes, deleted = h.namespaceRoutes(ns, routes), h.namespaceIDs(ns, deleted)
+		if deleted == nil {
+			deleted = []string{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(overrides{
+			Routes:  eskip.Print(true, routes...),
+			Deleted: deleted,
+		}); err != nil {
+			log.Error("error while sending the admin overrides", err)
+		}
+	case r.Method == "DELETE" && id != "":
+		h.client.Reset(id)
+		log.Infof("admin API: reset route %s, from %s", id, r.RemoteAddr)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+func writeRoutes(w http.ResponseWriter, routes []*eskip.Route) {
+	w.Header().Set("Content-Type", "text/plain")
+	if _, err := fmt.Fprintln(w, eskip.Print(true, routes...)); err != nil {
+		log.Error("error while sending routes", err)
+	}
+}
+
+func readRoutes(w http.ResponseWriter, r *http.Request) ([]*eskip.Route, bool) {
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	routes, err := eskip.Parse(string(b))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	if len(routes) == 0 {
+		http.Error(w, "no routes", http.StatusBadRequest)
+		return nil, false
+	}
+
+	return routes, true
+}
+
+func persist(r *http.Request) bool {
+	return r.URL.Query().Get("persist") == "true"
+}
+
+func (h *handler) upsert(w http.ResponseWriter, r *http.Request, routes []*eskip.Route) {
+	if err := h.routing.Validate(routes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if persist(r) {
+		if h.store == nil {
+			http.Error(w, "no writable data client", http.StatusBadRequest)
+			return
+		}
+
+		for _, ri := range routes {
+			if err := h.store.Upsert(ri); err != nil {
+				log.Errorf("admin API: error while persisting route %s: %v", ri.Id, err)
+				http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+				return
+			}
+		}
+	}
+
+	h.client.Upsert(routes...)
+	for _, ri := range routes {
+		log.Infof("admin API: set route %s, from %s", ri.Id, r.RemoteAddr)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) delete(w http.ResponseWriter, r *http.Request, id string) {
+	if persist(r) {
+		if h.store == nil {
+			http.Error(w, "no writable data client", http.StatusBadRequest)
+			return
+		}
+
+		if err := h.store.Delete(id); err != nil {
+			log.Errorf("admin API: error while deleting persisted route %s: %v", id, err)
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			return
+		}
+	}
+
+	h.client.Delete(id)
+	log.Infof("admin API: deleted route %s, from %s", id, r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,682 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging"
+	"github.com/zalando/skipper/maintenance"
+	"github.com/zalando/skipper/namespace"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+	"github.com/zalando/skipper/secrets"
+	"github.com/zalando/skipper/tracing"
+)
+
+const (
+	testToken   = "test-token"
+	testTimeout = 3 * time.Second
+)
+
+type tokenSource []string
+
+type store struct {
+	routes map[string]*eskip.Route
+	fail   bool
+}
+
+func (s tokenSource) Keys() ([][]byte, error) {
+	var k [][]byte
+	for _, t := range s {
+		k = append(k, []byte(t))
+	}
+
+	return k, nil
+}
+
+func (s *store) Upsert(r *eskip.Route) error {
+	if s.fail {
+		return errors.New("store failed")
+	}
+
+	s.routes[r.Id] = r
+	return nil
+}
+
+func (s *store) Delete(id string) error {
+	if s.fail {
+		return errors.New("store failed")
+	}
+
+	delete(s.routes, id)
+	return nil
+}
+
+type testAPI struct {
+	server  *httptest.Server
+	routing *routing.Routing
+	client  *Client
+	store   *store
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	dc := testdataclient.New([]*eskip.Route{
+		{Id: "foo", Path: "/foo", Backend: "https://foo.example.org"},
+		{Id: "bar", Path: "/bar", Backend: "https://bar.example.org"},
+	})
+
+	c := NewClient()
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		PollTimeout:    time.Hour,
+		DataClients:    []routing.DataClient{dc, c},
+	})
+
+	s := &store{routes: make(map[string]*eskip.Route)}
+	h, err := New(Options{
+		Routing: rt,
+		Client:  c,
+		Store:   s,
+		Tokens:  tokenSource{"other-token", testToken},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := &testAPI{server: httptest.NewServer(h), routing: rt, client: c, store: s}
+	api.waitComplete(t)
+	api.waitRoutes(t, "bar=https://bar.example.org;foo=https://foo.example.org")
+	return api
+}
+
+// waits until both data clients have sent their routes
+func (api *testAPI) waitComplete(t *testing.T) {
+	timeout := time.After(testTimeout)
+	for !api.routing.Status().Complete {
+		select {
+		case <-timeout:
+			t.Fatal("timeout while waiting for the data clients")
+		case <-time.After(3 * time.Millisecond):
+		}
+	}
+}
+
+func (api *testAPI) close() {
+	api.server.Close()
+	api.routing.Close()
+}
+
+func routeList(routes []*eskip.Route) string {
+	var s []string
+	for _, r := range routes {
+		s = append(s, r.Id+"="+r.Backend)
+	}
+
+	return strings.Join(s, ";")
+}
+
+func (api *testAPI) waitRoutes(t *testing.T, expected string) {
+	timeout := time.After(testTimeout)
+	for {
+		current := routeList(api.routing.Routes())
+		if current == expected {
+			return
+		}
+
+		select {
+		case <-timeout:
+			t.Fatalf("timeout while waiting for the routes, expected: %s, got: %s", expected, current)
+		case <-time.After(3 * time.Millisecond):
+		}
+	}
+}
+
+func (api *testAPI) request(t *testing.T, method, path, token, body string) (int, string) {
+	req, err := http.NewRequest(method, api.server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rsp.Body.Close()
+	b, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rsp.StatusCode, string(b)
+}
+
+func TestUnauthorized(t *testing.T) {
+	api := newTestAPI(t)
+	defer api.close()
+
+	for _, token := range []string{"", "invalid-token", testToken + "x"} {
+		if status, _ := api.request(t, "GET", "/routes", token, ""); status != http.StatusUnauthorized {
+			t.Error("failed to reject the request", token, status)
+		}
+	}
+
+	if status, _ := api.request(t, "GET", "/routes", testToken, ""); status != http.StatusOK {
+		t.Error("failed to accept the token", status)
+	}
+}
+
+func TestListRoutes(t *testing.T) {
+	api := newTestAPI(t)
+	defer api.close()
+
+	status, body := api.request(t, "GET", "/routes", testToken, "")
+	if status != http.StatusOK {
+		t.Fatal("invalid status", status)
+	}
+
+	routes, err := eskip.Parse(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if routeList(routes) != "bar=https://bar.example.org;foo=https://foo.example.org" {
+		t.Error("invalid routes", body)
+	}
+
+	status, body = api.request(t, "GET", "/routes/foo", testToken, "")
+	if status != http.StatusOK {
+		t.Fatal("invalid status", status)
+	}
+
+	if routes, err = eskip.Parse(body); err != nil || routeList(routes) != "foo=https://foo.example.org" {
+		t.Error("invalid route", body, err)
+	}
+
+	if status, _ = api.request(t, "GET", "/routes/baz", testToken, ""); status != http.StatusNotFound {
+		t.Error("invalid status", status)
+	}
+}
+
+func TestChangeRoutes(t *testing.T) {
+	api := newTestAPI(t)
+	defer api.close()
+
+	status, _ := api.request(t, "POST", "/routes", testToken, `
+		foo: Path("/foo") -> "https://foo-fallback.example.org";
+		baz: Path("/baz") -> "https://baz.example.org";
+	`)
+	if status != http.StatusNoContent {
+		t.Fatal("invalid status", status)
+	}
+
+	api.waitRoutes(t, "bar=https://bar.example.org;baz=https://baz.example.org;foo=https://foo-fallback.example.org")
+
+	status, _ = api.request(t, "PUT", "/routes/baz", testToken, `Path("/baz") -> "https://baz2.example.org"`)
+	if status != http.StatusNoContent {
+		t.Fatal("invalid status", status)
+	}
+
+	api.waitRoutes(t, "bar=https://bar.example.org;baz=https://baz2.example.org;foo=https://foo-fallback.example.org")
+
+	if status, _ = api.request(t, "DELETE", "/routes/bar", testToken, ""); status != http.StatusNoContent {
+		t.Fatal("invalid status", status)
+	}
+
+	api.waitRoutes(t, "baz=https://baz2.example.org;foo=https://foo-fallback.example.org")
+
+	status, body := api.request(t, "GET", "/overrides", testToken, "")
+	if status != http.StatusOK {
+		t.Fatal("invalid status", status)
+	}
+
+	var o overrides
+	if err := json.Unmarshal([]byte(body), &o); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(o.Deleted) != 1 || o.Deleted[0] != "bar" || !strings.Contains(o.Routes, "baz2") {
+		t.Error("invalid overrides", body)
+	}
+
+	for _, id := range []string{"foo", "bar", "baz"} {
+		if status, _ = api.request(t, "DELETE", "/overrides/"+id, testToken, ""); status != http.StatusNoContent {
+			t.Fatal("invalid status", status)
+		}
+	}
+
+	api.waitRoutes(t, "bar=https://bar.example.org;foo=https://foo.example.org")
+
+	if len(api.store.routes) != 0 {
+		t.Error("unexpected persisted routes")
+	}
+}
+
+func TestInvalidChanges(t *testing.T) {
+	api := newTestAPI(t)
+	defer api.close()
+
+	for _, test := range []struct {
+		title  string
+		method string
+		path   string
+		body   string
+		status int
+	}{{
+		title:  "invalid eskip",
+		method: "POST",
+		path:   "/routes",
+		body:   "foo: Path(",
+		status: http.StatusBadRequest,
+	}, {
+		title:  "unknown filter",
+		method: "POST",
+		path:   "/routes",
+		body:   `foo: Path("/foo") -> noSuchFilter() -> <shunt>`,
+		status: http.StatusBadRequest,
+	}, {
+		title:  "missing id",
+		method: "POST",
+		path:   "/routes",
+		body:   `Path("/foo") -> <shunt>`,
+		status: http.StatusBadRequest,
+	}, {
+		title:  "id mismatch",
+		method: "PUT",
+		path:   "/routes/foo",
+		body:   `bar: Path("/foo") -> <shunt>`,
+		status: http.StatusBadRequest,
+	}, {
+		title:  "multiple routes",
+		method: "PUT",
+		path:   "/routes/foo",
+		body:   `foo: Path("/foo") -> <shunt>; bar: Path("/bar") -> <shunt>`,
+		status: http.StatusBadRequest,
+	}, {
+		title:  "method not allowed",
+		method: "PUT",
+		path:   "/routes",
+		status: http.StatusMethodNotAllowed,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			if status, body := api.request(t, test.method, test.path, testToken, test.body); status != test.status {
+				t.Error("invalid status", status, body)
+			}
+		})
+	}
+
+	if routes, deleted := api.client.Overrides(); len(routes) != 0 || len(deleted) != 0 {
+		t.Error("invalid changes applied")
+	}
+}
+
+func TestPersist(t *testing.T) {
+	api := newTestAPI(t)
+	defer api.close()
+
+	status, _ := api.request(t, "PUT", "/routes/baz?persist=true", testToken, `Path("/baz") -> "https://baz.example.org"`)
+	if status != http.StatusNoContent {
+		t.Fatal("invalid status", status)
+	}
+
+	if _, ok := api.store.routes["baz"]; !ok {
+		t.Error("failed to persist the route")
+	}
+
+	api.waitRoutes(t, "bar=https://bar.example.org;baz=https://baz.example.org;foo=https://foo.example.org")
+
+	if status, _ = api.request(t, "DELETE", "/routes/baz?persist=true", testToken, ""); status != http.StatusNoContent {
+		t.Fatal("invalid status", status)
+	}
+
+	if _, ok := api.store.routes["baz"]; ok {
+		t.Error("failed to delete the persisted route")
+	}
+
+	api.waitRoutes(t, "bar=https://bar.example.org;foo=https://foo.example.org")
+
+	api.store.fail = true
+	if status, _ = api.request(t, "DELETE", "/routes/foo?persist=true", testToken, ""); status != http.StatusBadGateway {
+		t.Error("invalid status", status)
+	}
+
+	if _, deleted := api.client.Overrides(); len(deleted) != 1 || deleted[0] != "baz" {
+		t.Error("failed persisting applied", deleted)
+	}
+}
+
+type testTracer struct {
+	tracing.Tracer
+	rate float64
+}
+
+func (t *testTracer) SampleRate() float64        { return t.rate }
+func (t *testTracer) SetSampleRate(rate float64) { t.rate = rate }
+
+func TestDebugSettings(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel(log.InfoLevel)
+
+	rt := routing.New(routing.Options{PollTimeout: time.Hour})
+	defer rt.Close()
+
+	tr := &testTracer{Tracer: tracing.Noop, rate: 0.01}
+	h, err := New(Options{
+		Routing: rt,
+		Client:  NewClient(),
+		Tokens:  tokenSource{testToken},
+		Tracer:  tr,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := &testAPI{server: httptest.NewServer(h)}
+	defer api.server.Close()
+
+	check := func(body, expected string) {
+		var s debugSettings
+		if err := json.Unmarshal([]byte(body), &s); err != nil {
+			t.Fatal(err)
+		}
+
+		if s.String() != expected {
+			t.Errorf("invalid settings, expected: %s, got: %s", expected, s)
+		}
+	}
+
+	status, body := api.request(t, "GET", "/debug", testToken, "")
+	if status != http.StatusOK {
+		t.Fatal("invalid status", status)
+	}
+
+	check(body, "log level: info, access log debug: false, trace sample rate: 0.01")
+
+	status, body = api.request(t, "PUT", "/debug", testToken, `{"log_level": "debug", "access_log_debug": true, "trace_sample_rate": 1}`)
+	if status != http.StatusOK {
+		t.Fatal("invalid status", status, body)
+	}
+
+	check(body, "log level: debug, access log debug: true, trace sample rate: 1")
+	if log.GetLevel() != log.DebugLevel || !logging.AccessLogDebug() || tr.rate != 1 {
+		t.Error("failed to apply the settings")
+	}
+
+	for _, invalid := range []string{
+		`{"log_level": "verbose", "access_log_debug": false}`,
+		`{"trace_sample_rate": 2, "access_log_debug": false}`,
+		`{"access_log_debug": "yes"}`,
+	} {
+		if status, _ = api.request(t, "PUT", "/debug", testToken, invalid); status != http.StatusBadRequest {
+			t.Error("failed to reject the settings", invalid, status)
+		}
+	}
+
+	if !logging.AccessLogDebug() {
+		t.Error("invalid settings partially applied")
+	}
+
+	status, body = api.request(t, "DELETE", "/debug", testToken, "")
+	if status != http.StatusOK {
+		t.Fatal("invalid status", status)
+	}
+
+	check(body, "log level: info, access log debug: false, trace sample rate: 0.01")
+}
+
+func TestDebugSettingsWithoutTracer(t *testing.T) {
+	api := newTestAPI(t)
+	defer api.close()
+
+	status, body := api.request(t, "GET", "/debug", testToken, "")
+	if status != http.StatusOK || strings.Contains(body, "trace_sample_rate") {
+		t.Error("invalid response", status, body)
+	}
+
+	if status, _ = api.request(t, "PUT", "/debug", testToken, `{"trace_sample_rate": 1}`); status != http.StatusBadRequest {
+		t.Error("invalid status", status)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	api := newTestAPI(t)
+	defer api.close()
+
+	if status, _ := api.request(t, "GET", "/maintenance", testToken, ""); status != http.StatusNotFound {
+		t.Error("invalid status without maintenance mode", status)
+	}
+
+	m := maintenance.New(maintenance.Options{DrainPeriod: -1})
+	defer m.Close()
+
+	h, err := New(Options{
+		Routing:     api.routing,
+		Client:      api.client,
+		Tokens:      tokenSource{testToken},
+		Maintenance: m,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api.server.Config.Handler = h
+
+	check := func(method string, enabled bool) {
+		status, body := api.request(t, method, "/maintenance", testToken, "")
+		if status != http.StatusOK {
+			t.Fatal("invalid status", status)
+		}
+
+		var s maintenance.Status
+		if err := json.Unmarshal([]byte(body), &s); err != nil {
+			t.Fatal(err)
+		}
+
+		if s.Enabled != enabled || m.Status().Enabled != enabled {
+			t.Error("invalid maintenance status", method, body)
+		}
+	}
+
+	check("GET", false)
+	check("POST", true)
+	check("GET", true)
+	check("DELETE", false)
+
+	if status, _ := api.request(t, "PUT", "/maintenance", testToken, ""); status != http.StatusMethodNotAllowed {
+		t.Error("invalid status", status)
+	}
+}
+
+func TestVersionsAndRollback(t *testing.T) {
+	api := newTestAPI(t)
+	defer api.close()
+
+	if status, _ := api.request(t, "POST", "/rollback", testToken, ""); status != http.StatusNotFound {
+		t.Error("invalid status without a previous version", status)
+	}
+
+	status, _ := api.request(t, "POST", "/routes", testToken, `
+		foo: Path("/foo") -> "https://foo-broken.example.org";
+		baz: Path("/baz") -> "https://baz.example.org";
+	`)
+	if status != http.StatusNoContent {
+		t.Fatal("invalid status", status)
+	}
+
+	api.waitRoutes(t, "bar=https://bar.example.org;baz=https://baz.example.org;foo=https://foo-broken.example.org")
+
+	status, body := api.request(t, "GET", "/versions", testToken, "")
+	if status != http.StatusOK {
+		t.Fatal("invalid status", status)
+	}
+
+	var versions []routing.Version
+	if err := json.Unmarshal([]byte(body), &versions); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(versions) != 2 || versions[0].Routes != 3 || versions[1].Routes != 2 {
+		t.Fatal("invalid versions", body)
+	}
+
+	status, body = api.request(t, "GET", "/versions/"+versions[1].ID, testToken, "")
+	if status != http.StatusOK {
+		t.Fatal("invalid status", status)
+	}
+
+	if routes, err := eskip.Parse(body); err != nil || routeList(routes) != "bar=https://bar.example.org;foo=https://foo.example.org" {
+		t.Error("invalid version", body, err)
+	}
+
+	if status, _ = api.request(t, "GET", "/versions/no-such-version", testToken, ""); status != http.StatusNotFound {
+		t.Error("invalid status", status)
+	}
+
+	if status, _ = api.request(t, "POST", "/rollback", testToken, ""); status != http.StatusNoContent {
+		t.Fatal("invalid status", status)
+	}
+
+	api.waitRoutes(t, "bar=https://bar.example.org;foo=https://foo.example.org")
+	if current := api.routing.Versions()[0]; current.ID != versions[1].ID {
+		t.Error("the rolled back version has a different id", current.ID, versions[1].ID)
+	}
+
+	// rolling forward, to an explicit version
+	if status, _ = api.request(t, "POST", "/rollback?version="+versions[0].ID, testToken, ""); status != http.StatusNoContent {
+		t.Fatal("invalid status", status)
+	}
+
+	api.waitRoutes(t, "bar=https://bar.example.org;baz=https://baz.example.org;foo=https://foo-broken.example.org")
+
+	if status, _ = api.request(t, "POST", "/rollback?version=no-such-version", testToken, ""); status != http.StatusNotFound {
+		t.Error("invalid status", status)
+	}
+
+	if status, _ = api.request(t, "GET", "/rollback", testToken, ""); status != http.StatusMethodNotAllowed {
+		t.Error("invalid status", status)
+	}
+}
+
+func TestSnapshotWithoutFile(t *testing.T) {
+	api := newTestAPI(t)
+	defer api.close()
+
+	if status, _ := api.request(t, "POST", "/snapshot", testToken, ""); status != http.StatusBadRequest {
+		t.Error("invalid status", status)
+	}
+
+	if status, _ := api.request(t, "GET", "/snapshot", testToken, ""); status != http.StatusMethodNotAllowed {
+		t.Error("invalid status", status)
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	api := newTestAPI(t)
+	defer api.close()
+
+	ns, err := namespace.New(namespace.Options{
+		Names:  []string{"team_a", "team_b"},
+		Tokens: map[string]secrets.Source{"team_a": tokenSource{"team-a-token"}},
+		Policies: map[string]namespace.Policy{
+			"team_a": {
+				Hosts:    []string{"a.example.org"},
+				Filters:  []string{"setPath"},
+				Backends: []string{"a2.example.org"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := New(Options{
+		Routing:    api.routing,
+		Client:     api.client,
+		Tokens:     tokenSource{testToken},
+		Namespaces: ns,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api.server.Config.Handler = h
+
+	status, _ := api.request(t, "POST", "/routes", testToken, `
+		team_a__api: Path("/a") -> "https://a.example.org";
+		team_b__api: Path("/b") -> "https://b.example.org";
+	`)
+	if status != http.StatusNoContent {
+		t.Fatal("invalid status", status)
+	}
+
+	api.waitRoutes(t, "bar=https://bar.example.org;foo=https://foo.example.org;team_a__api=https://a.example.org;team_b__api=https://b.example.org")
+
+	const token = "team-a-token"
+	status, body := api.request(t, "GET", "/routes", token, "")
+	if status != http.StatusOK {
+		t.Fatal("invalid status", status)
+	}
+
+	if routes, err := eskip.Parse(body); err != nil || routeList(routes) != "team_a__api=https://a.example.org" {
+		t.Error("invalid routes of the namespace", body, err)
+	}
+
+	for _, test := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{"GET", "/routes/team_a__api", "", http.StatusOK},
+		{"GET", "/routes/team_b__api", "", http.StatusForbidden},
+		{"GET", "/routes/foo", "", http.StatusForbidden},
+		{"PUT", "/routes/team_a__api", `Host("^a[.]example[.]org$") && Path("/a") -> setPath("/") -> "https://a2.example.org"`, http.StatusNoContent},
+		{"PUT", "/routes/team_a__x", `Host("^b[.]example[.]org$") -> "https://a2.example.org"`, http.StatusForbidden},
+		{"PUT", "/routes/team_a__x", `Path("/x") -> "https://a2.example.org"`, http.StatusForbidden},
+		{"PUT", "/routes/team_a__x", `Host("^a[.]example[.]org$") -> setRequestHeader("X-Foo", "bar") -> "https://a2.example.org"`, http.StatusForbidden},
+		{"PUT", "/routes/team_a__x", `Host("^a[.]example[.]org$") -> "https://b.example.org"`, http.StatusForbidden},
+		{"POST", "/routes", `team_a__x: Host("^b[.]example[.]org$") -> <shunt>`, http.StatusForbidden},
+		{"PUT", "/routes/foo", `Path("/foo") -> <shunt>`, http.StatusForbidden},
+		{"POST", "/routes", `team_a__x: Path("/x") -> <shunt>; team_b__x: Path("/x") -> <shunt>`, http.StatusForbidden},
+		{"DELETE", "/routes/team_b__api", "", http.StatusForbidden},
+		{"DELETE", "/overrides/team_b__api", "", http.StatusForbidden},
+		{"GET", "/versions", "", http.StatusForbidden},
+		{"POST", "/rollback", "", http.StatusForbidden},
+		{"GET", "/debug", "", http.StatusForbidden},
+	} {
+		if status, _ := api.request(t, test.method, test.path, token, test.body); status != test.status {
+			t.Errorf("%s %s: expected status: %d, got: %d", test.method, test.path, test.status, status)
+		}
+	}
+
+	api.waitRoutes(t, "bar=https://bar.example.org;foo=https://foo.example.org;team_a__api=https://a2.example.org;team_b__api=https://b.example.org")
+
+	status, body = api.request(t, "GET", "/overrides", token, "")
+	if status != http.StatusOK {
+		t.Fatal("invalid status", status)
+	}
+
+	var o overrides
+	if err := json.Unmarshal([]byte(body), &o); err != nil {
+		t.Fatal(err)
+	}
+
+	if routes, err := eskip.Parse(o.Routes); err != nil || routeList(routes) != "team_a__api=https://a2.example.org" {
+		t.Error("invalid overrides of the namespace", body, err)
+	}
+
+	if status, _ := api.request(t, "GET", "/routes", "team-b-token", ""); status != http.StatusUnauthorized {
+		t.Error("invalid status", status)
+	}
+}
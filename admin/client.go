@@ -0,0 +1,156 @@
+package admin
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+// Client is an in-memory data client, holding the routes set with the
+// admin API. It needs to be the last one in the list of the data
+// clients, so that its routes override the routes with the same id
+// from the other sources, and so that the routes that it deletes are
+// removed from the routing table, too.
+type Client struct {
+	mx      sync.Mutex
+	routes  map[string]*eskip.Route
+	deleted map[string]bool
+	upserts map[string]*eskip.Route
+	deletes map[string]bool
+	updates chan struct{}
+}
+
+// NewClient creates an empty in-memory data client.
+func NewClient() *Client {
+	return &Client{
+		routes:  make(map[string]*eskip.Route),
+		deleted: make(map[string]bool),
+		upserts: make(map[string]*eskip.Route),
+		deletes: make(map[string]bool),
+		updates: make(chan struct{}, 1),
+	}
+}
+
+func sortedRoutes(m map[string]*eskip.Route) []*eskip.Route {
+	var r []*eskip.Route
+	for _, ri := range m {
+		r = append(r, ri)
+	}
+
+	sort.Slice(r, func(i, j int) bool { return r[i].Id < r[j].Id })
+	return r
+}
+
+func sortedIDs(m map[string]bool) []string {
+	var ids []string
+	for id := range m {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	return ids
+}
+
+// LoadAll returns all the routes set with the admin API.
+func (c *Client) LoadAll() ([]*eskip.Route, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.upserts = make(map[string]*eskip.Route)
+	c.deletes = make(map[string]bool)
+	return sortedRoutes(c.routes), nil
+}
+
+// LoadUpdate returns the routes set and deleted with the admin API
+// since the previous call.
+func (c *Client) LoadUpdate() ([]*eskip.Route, []string, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	routes, deleted := sortedRoutes(c.upserts), sortedIDs(c.deletes)
+	c.upserts = make(map[string]*eskip.Route)
+	c.deletes = make(map[string]bool)
+	return routes, deleted, nil
+}
+
+// Updates signals the routing that there are changes to load.
+func (c *Client) Updates() <-chan struct{} {
+	return c.updates
+}
+
+// DeletedRoutes returns the IDs of the routes deleted with the admin
+// API, that are removed from the routes of the other data clients.
+func (c *Client) DeletedRoutes() []string {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return sortedIDs(c.deleted)
+}
+
+func (c *Client) notify() {
+	select {
+	case c.updates <- struct{}{}:
+	default:
+	}
+}
+
+// Upsert adds or replaces routes. The routes take precedence over the
+// routes with the same id from the other data clients, until they are
+// reset.
+func (c *Client) Upsert(routes ...*eskip.Route) {
+	c.Update(routes, nil)
+}
+
+// Delete removes routes from the routing table, including the routes
+// with the same id from the other data clients, until they are reset.
+func (c *Client) Delete(ids ...string) {
+	c.Update(nil, ids)
+}
+
+// Update adds or replaces, and deletes routes in a single change, so
+// that no intermediate state of the routing table is applied.
+func (c *Client) Update(upsert []*eskip.Route, deletedIDs []string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	for _, r := range upsert {
+		c.routes[r.Id] = r
+		c.upserts[r.Id] = r
+		delete(c.deleted, r.Id)
+		delete(c.deletes, r.Id)
+	}
+
+	for _, id := range deletedIDs {
+		delete(c.routes, id)
+		delete(c.upserts, id)
+		c.deleted[id] = true
+		c.deletes[id] = true
+	}
+
+	c.notify()
+}
+
+// Reset drops the changes made with the admin API to the routes with the
+// given IDs, so that the routes from the other data clients are used
+// again.
+func (c *Client) Reset(ids ...string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	for _, id := range ids {
+		delete(c.routes, id)
+		delete(c.upserts, id)
+		delete(c.deleted, id)
+		c.deletes[id] = true
+	}
+
+	c.notify()
+}
+
+// Overrides returns the routes set and the IDs of the routes deleted
+// with the admin API.
+func (c *Client) Overrides() ([]*eskip.Route, []string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return sortedRoutes(c.routes), sortedIDs(c.deleted)
+}
@@ -0,0 +1,141 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/logging"
+	"github.com/zalando/skipper/tracing"
+)
+
+const debugPath = "/debug"
+
+var errSampleRateNotSupported = errors.New("the tracer does not support changing the sample rate")
+
+// the runtime debug settings, as returned by the API
+type debugSettings struct {
+	LogLevel        string   `json:"log_level"`
+	AccessLogDebug  bool     `json:"access_log_debug"`
+	TraceSampleRate *float64 `json:"trace_sample_rate,omitempty"`
+}
+
+// a partial change of the debug settings, the missing fields are left
+// unchanged
+type debugChange struct {
+	LogLevel        *string  `json:"log_level"`
+	AccessLogDebug  *bool    `json:"access_log_debug"`
+	TraceSampleRate *float64 `json:"trace_sample_rate"`
+}
+
+func (s debugSettings) String() string {
+	rate := "n/a"
+	if s.TraceSampleRate != nil {
+		rate = fmt.Sprint(*s.TraceSampleRate)
+	}
+
+	return fmt.Sprintf("log level: %s, access log debug: %t, trace sample rate: %s", s.LogLevel, s.AccessLogDebug, rate)
+}
+
+// the sample rate can be changed only when the tracer supports it
+func sampleRateController(t tracing.Tracer) tracing.SampleRateController {
+	c, _ := t.(tracing.SampleRateController)
+	return c
+}
+
+func (h *handler) debugSettings() debugSettings {
+	s := debugSettings{
+		LogLevel:       log.GetLevel().String(),
+		AccessLogDebug: logging.AccessLogDebug(),
+	}
+
+	if h.sampler != nil {
+		rate := h.sampler.SampleRate()
+		s.TraceSampleRate = &rate
+	}
+
+	return s
+}
+
+// validates the whole change before applying any of it
+func (h *handler) applyDebugChange(c debugChange) error {
+	var level log.Level
+	if c.LogLevel != nil {
+		var err error
+		if level, err = log.ParseLevel(*c.LogLevel); err != nil {
+			return err
+		}
+	}
+
+	if c.TraceSampleRate != nil {
+		if h.sampler == nil {
+			return errSampleRateNotSupported
+		}
+
+		if *c.TraceSampleRate > 1 {
+			return fmt.Errorf("invalid trace sample rate: %v", *c.TraceSampleRate)
+		}
+	}
+
+	if c.LogLevel != nil {
+		log.SetLevel(level)
+	}
+
+	if c.AccessLogDebug != nil {
+		logging.SetAccessLogDebug(*c.AccessLogDebug)
+	}
+
+	if c.TraceSampleRate != nil {
+		h.sampler.SetSampleRate(*c.TraceSampleRate)
+	}
+
+	return nil
+}
+
+func (h *handler) writeDebugSettings(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.debugSettings()); err != nil {
+		log.Error("error while sending the debug settings", err)
+	}
+}
+
+func (h *handler) serveDebug(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		h.writeDebugSettings(w)
+	case "PUT":
+		var c debugChange
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&c); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.applyDebugChange(c); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// logged with warning level, to be visible even when the log
+		// level was just raised
+		s := h.debugSettings()
+		log.Warnf("admin API: debug settings changed, from %s: %s", r.RemoteAddr, s)
+		h.writeDebugSettings(w)
+	case "DELETE":
+		d := h.initialDebug
+		if err := h.applyDebugChange(debugChange{
+			LogLevel:        &d.LogLevel,
+			AccessLogDebug:  &d.AccessLogDebug,
+			TraceSampleRate: d.TraceSampleRate,
+		}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Warnf("admin API: debug settings reset, from %s", r.RemoteAddr)
+		h.writeDebugSettings(w)
+	default:
+		methodNotAllowed(w)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Package admin implements a REST API to change the routes at runtime,
+e.g. to divert or to block traffic in an emergency, without a redeploy.
+
+The API is served on a separate listener, and it is enabled by setting
+its address and the source of the accepted bearer tokens, in the format
+of file:<path> or env:<variable>, one token per line or separated by
+commas. The tokens are reloaded on every request, so that they can be
+rotated without a restart. As the tokens are sent in the clear, the
+listener can only be bound to a loopback address, and an address
+without a host, e.g. :9922, is bound to localhost:
+
+    skipper -routes-file routes.eskip -admin-address localhost:9922 -admin-tokens file:/etc/skipper/admin-tokens
+
+To serve the API on other addresses, it needs to be served with TLS, by
+setting a certificate and a key for the admin listener:
+
+    skipper -routes-file routes.eskip -admin-address :9922 -admin-tls-cert admin.crt -admin-tls-key admin.key \
+        -admin-tokens file:/etc/skipper/admin-tokens
+
+The routes are sent and received in eskip format:
+
+    # list the current routes
+    curl -H "Authorization: Bearer $TOKEN" localhost:9922/routes
+
+    # show a single route
+    curl -H "Authorization: Bearer $TOKEN" localhost:9922/routes/api
+
+    # add or update one or more routes
+    curl -H "Authorization: Bearer $TOKEN" -X POST localhost:9922/routes \
+        -d 'api: Path("/api") -> status(503) -> <shunt>;'
+
+    # add or update a single route, the id in the body is optional
+    curl -H "Authorization: Bearer $TOKEN" -X PUT localhost:9922/routes/api \
+        -d 'Path("/api") -> "https://api-fallback.example.org"'
+
+    # delete a route
+    curl -H "Authorization: Bearer $TOKEN" -X DELETE localhost:9922/routes/api
+
+The routes are validated before they are applied, and the changes take
+effect immediately. The changes are held in memory, and they take
+precedence over the routes with the same id from the other data
+sources, including the deletions, until they are reset, or until
+skipper is restarted. The current changes are listed, as JSON, on the
+/overrides path, and the changes to a route can be reset, returning to
+the route from the other data sources:
+
+    curl -H "Authorization: Bearer $TOKEN" localhost:9922/overrides
+    curl -H "Authorization: Bearer $TOKEN" -X DELETE localhost:9922/overrides/api
+
+When etcd is used as a data source, the changes can be persisted there,
+too, with the persist=true query parameter. In this case, the change is
+applied only when it was stored successfully:
+
+    curl -H "Authorization: Bearer $TOKEN" -X DELETE localhost:9922/routes/api?persist=true
+
+The log level, the debug fields of the access log and the sample rate
+of the tracer can be changed at runtime, too, to investigate an incident
+without a restart. The settings are sent and received as JSON, and a
+PUT request changes only the settings present in the body. A DELETE
+request restores the settings from the startup:
+
+    curl -H "Authorization: Bearer $TOKEN" localhost:9922/debug
+
+    curl -H "Authorization: Bearer $TOKEN" -X PUT localhost:9922/debug \
+        -d '{"log_level": "debug", "access_log_debug": true, "trace_sample_rate": 1}'
+
+    curl -H "Authorization: Bearer $TOKEN" -X DELETE localhost:9922/debug
+
+The trace sample rate can be changed only when a tracer is configured.
+
+The last applied versions of the routing table are listed on the
+/versions path, and they can be shown in eskip format by their id. When
+a bad change breaks the traffic, the routing table can be rolled back to
+the previous version with a single call, or to an explicit version:
+
+    curl -H "Authorization: Bearer $TOKEN" localhost:9922/versions
+    curl -H "Authorization: Bearer $TOKEN" localhost:9922/versions/3f2a9c1b7e04
+
+    curl -H "Authorization: Bearer $TOKEN" -X POST localhost:9922/rollback
+    curl -H "Authorization: Bearer $TOKEN" -X POST localhost:9922/rollback?version=3f2a9c1b7e04
+
+The rollback is applied as changes of the admin API, that take
+precedence over the later changes of the other data sources, until they
+are reset on the /overrides path.
+
+When the routing snapshot file is configured, the current routing table
+can be saved on the /snapshot path, with POST, not only after the
+updates and on shutdown. It is saved only when it contains the routes of
+all the data clients:
+
+    curl -H "Authorization: Bearer $TOKEN" -X POST localhost:9922/snapshot
+
+When namespaces are configured, the tokens of a namespace are accepted,
+too, but they grant access only to the routes of the namespace, and only
+on the /routes and the /overrides paths. The routes set with these
+tokens must match the policy of the namespace, e.g. its hosts, filters
+and backends, otherwise they are rejected with 403. See the namespace
+package.
+
+When the maintenance mode is configured, it is switched on the
+/maintenance path, with POST and DELETE. See the maintenance package.
+
+Every change is logged, with the address of the client.
+*/
+package admin
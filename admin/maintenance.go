@@ -0,0 +1,39 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const maintenancePath = "/maintenance"
+
+func (h *handler) writeMaintenanceStatus(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.maintenance.Status()); err != nil {
+		log.Error("error while sending the maintenance status", err)
+	}
+}
+
+func (h *handler) serveMaintenance(w http.ResponseWriter, r *http.Request) {
+	if h.maintenance == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+	case "POST":
+		h.maintenance.Enable()
+		log.Warnf("admin API: maintenance mode enabled, from %s", r.RemoteAddr)
+	case "DELETE":
+		h.maintenance.Disable()
+		log.Warnf("admin API: maintenance mode disabled, from %s", r.RemoteAddr)
+	default:
+		methodNotAllowed(w)
+		return
+	}
+
+	h.writeMaintenanceStatus(w)
+}
@@ -0,0 +1,62 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+func forbidden(w http.ResponseWriter) {
+	http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+}
+
+// checks whether a route can be accessed with the token of a namespace.
+// The empty namespace means access to all the routes.
+func (h *handler) inNamespace(ns, id string) bool {
+	return ns == "" || h.namespaces.Of(id) == ns
+}
+
+// checks whether a route can be set with the token of a namespace, and
+// responds with 403, when it cannot
+func (h *handler) validRoute(w http.ResponseWriter, ns string, r *eskip.Route) bool {
+	if ns == "" {
+		return true
+	}
+
+	if err := h.namespaces.Validate(ns, r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+func (h *handler) namespaceRoutes(ns string, routes []*eskip.Route) []*eskip.Route {
+	if ns == "" {
+		return routes
+	}
+
+	var r []*eskip.Route
+	for _, ri := range routes {
+		if h.inNamespace(ns, ri.Id) {
+			r = append(r, ri)
+		}
+	}
+
+	return r
+}
+
+func (h *handler) namespaceIDs(ns string, ids []string) []string {
+	if ns == "" {
+		return ids
+	}
+
+	var r []string
+	for _, id := range ids {
+		if h.inNamespace(ns, id) {
+			r = append(r, id)
+		}
+	}
+
+	return r
+}
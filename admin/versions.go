@@ -0,0 +1,121 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	versionsPath = "/versions"
+	rollbackPath = "/rollback"
+	snapshotPath = "/snapshot"
+)
+
+func (h *handler) serveVersions(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "GET" {
+		methodNotAllowed(w)
+		return
+	}
+
+	if id == "" {
+		versions := h.routing.Versions()
+		if versions == nil {
+			versions = []*routing.Version{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(versions); err != nil {
+			log.Error("error while sending the routing table versions", err)
+		}
+
+		return
+	}
+
+	v, ok := h.routing.Version(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeRoutes(w, v.Definitions())
+}
+
+// the version to roll back to: the one in the version query parameter,
+// or, by default, the one before the current
+func (h *handler) rollbackVersion(r *http.Request) (*routing.Version, bool) {
+	if id := r.URL.Query().Get("version"); id != "" {
+		return h.routing.Version(id)
+	}
+
+	versions := h.routing.Versions()
+	if len(versions) < 2 {
+		return nil, false
+	}
+
+	return versions[1], true
+}
+
+// rolls back to a previous version of the routing table, by overriding
+// the routes of the current version with the admin client: the routes of
+// the previous version are set, and the other current routes are deleted.
+// The overrides take precedence over the later changes from the other
+// data sources, until they are reset.
+func (h *handler) serveRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w)
+		return
+	}
+
+	v, ok := h.rollbackVersion(r)
+	if !ok {
+		http.Error(w, "version not found", http.StatusNotFound)
+		return
+	}
+
+	routes := v.Definitions()
+	if err := h.routing.Validate(routes); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	keep := make(map[string]bool)
+	for _, ri := range routes {
+		keep[ri.Id] = true
+	}
+
+	var deleted []string
+	for _, ri := range h.routing.Routes() {
+		if !keep[ri.Id] {
+			deleted = append(deleted, ri.Id)
+		}
+	}
+
+	h.client.Update(routes, deleted)
+	log.Warnf("admin API: rolled back the routing table to version %s, from %s", v.ID, r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// saves the current routing table in the snapshot file of the routing
+func (h *handler) serveSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w)
+		return
+	}
+
+	switch err := h.routing.SaveSnapshot(); err {
+	case nil:
+	case routing.ErrNoSnapshotFile, routing.ErrNoRoutingTable, routing.ErrIncompleteRoutingTable:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		log.Error("admin API: error while saving the routing snapshot", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("admin API: saved the routing snapshot, from %s", r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
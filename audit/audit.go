@@ -0,0 +1,199 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/events"
+)
+
+const (
+	// DefaultMaxEntries is the default number of the entries kept in
+	// memory.
+	DefaultMaxEntries = 1000
+
+	subscriptionBuffer = 256
+)
+
+// Options for creating a Trail.
+type Options struct {
+
+	// The number of the most recent entries kept in memory and served
+	// by the handler. Default: 1000.
+	MaxEntries int
+
+	// When set, every entry is appended to this file, as a JSON
+	// object per line. The file is never truncated.
+	File string
+}
+
+// Entry records an applied change of the routing table.
+type Entry struct {
+
+	// The time when the change was applied.
+	Time time.Time `json:"time"`
+
+	// The type of the data client that the change was received from.
+	Source string `json:"source"`
+
+	// Whether the data client sent the full set of its routes (reset),
+	// or only the changes (update).
+	Update string `json:"update"`
+
+	// The number of the routes in the applied routing table.
+	Routes int `json:"routes"`
+
+	// The number of the invalid route definitions that were dropped.
+	Invalid int `json:"invalid"`
+
+	// The IDs of the added, changed and removed routes, compared to
+	// the previous routing table.
+	Added   []string `json:"added,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Trail records the route table changes published on the event bus.
+// It implements http.Handler, serving the recorded entries.
+type Trail struct {
+	mx           sync.Mutex
+	entries      []*Entry
+	maxEntries   int
+	file         *os.File
+	subscription *events.Subscription
+	done         chan struct{}
+}
+
+// New creates a Trail, recording the route table changes published on
+// the bus.
+func New(bus *events.Bus, o Options) (*Trail, error) {
+	if o.MaxEntries <= 0 {
+		o.MaxEntries = DefaultMaxEntries
+	}
+
+	t := &Trail{
+		maxEntries: o.MaxEntries,
+		done:       make(chan struct{}),
+	}
+
+	if o.File != "" {
+		f, err := os.OpenFile(o.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		t.file = f
+	}
+
+	t.subscription = bus.Subscribe(subscriptionBuffer, events.TypeRouteTableUpdated)
+	go t.run()
+	return t, nil
+}
+
+func stringsOf(v interface{}) []string {
+	s, _ := v.([]string)
+	return s
+}
+
+func entryOf(e *events.Event) *Entry {
+	entry := &Entry{
+		Time:    e.Time,
+		Added:   stringsOf(e.Data["added"]),
+		Changed: stringsOf(e.Data["changed"]),
+		Removed: stringsOf(e.Data["removed"]),
+	}
+
+	entry.Source, _ = e.Data["source"].(string)
+	entry.Update, _ = e.Data["update"].(string)
+	entry.Routes, _ = e.Data["routes"].(int)
+	entry.Invalid, _ = e.Data["invalid"].(int)
+	return entry
+}
+
+func (t *Trail) record(e *Entry) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	t.entries = append(t.entries, e)
+	if len(t.entries) > t.maxEntries {
+		t.entries = append([]*Entry(nil), t.entries[len(t.entries)-t.maxEntries:]...)
+	}
+
+	if t.file == nil {
+		return
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		log.Error("error while encoding audit entry", err)
+		return
+	}
+
+	if _, err := t.file.Write(append(b, '\n')); err != nil {
+		log.Error("error while writing audit entry", err)
+	}
+}
+
+func (t *Trail) run() {
+	defer close(t.done)
+	for e := range t.subscription.C {
+		t.record(entryOf(e))
+	}
+}
+
+// Entries returns the recorded entries since the given time, the
+// oldest first. When since is zero, it returns every entry kept in
+// memory.
+func (t *Trail) Entries(since time.Time) []*Entry {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	var e []*Entry
+	for _, ei := range t.entries {
+		if ei.Time.Before(since) {
+			continue
+		}
+
+		e = append(e, ei)
+	}
+
+	return e
+}
+
+// ServeHTTP returns the recorded entries as JSON. The since query
+// parameter, in RFC3339 format, limits the response to the entries
+// recorded after that time.
+func (t *Trail) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, s); err != nil {
+			http.Error(w, "invalid since parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(t.Entries(since)); err != nil {
+		log.Error("error while sending the audit entries", err)
+	}
+}
+
+// Close stops recording the changes, and closes the file.
+func (t *Trail) Close() {
+	t.subscription.Close()
+	<-t.done
+	if t.file != nil {
+		t.file.Close()
+	}
+}
@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/events"
+)
+
+func routeEvent(t time.Time, added ...string) *events.Event {
+	return &events.Event{
+		Type: events.TypeRouteTableUpdated,
+		Time: t,
+		Data: map[string]interface{}{
+			"source":  "*testdataclient.Client",
+			"update":  "update",
+			"routes":  len(added),
+			"invalid": 0,
+			"added":   added,
+			"changed": []string(nil),
+			"removed": []string(nil),
+		},
+	}
+}
+
+func waitEntries(t *testing.T, tr *Trail, n int) {
+	timeout := time.After(time.Second)
+	for len(tr.Entries(time.Time{})) < n {
+		select {
+		case <-timeout:
+			t.Fatal("timeout while waiting for the entries")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestRecordsAndServesEntries(t *testing.T) {
+	bus := events.NewBus()
+	tr, err := New(bus, Options{MaxEntries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tr.Close()
+
+	start := time.Date(2017, 6, 1, 14, 30, 0, 0, time.UTC)
+	bus.Publish(routeEvent(start, "foo"))
+	bus.Publish(&events.Event{Type: events.TypeBackendUnhealthy})
+	bus.Publish(routeEvent(start.Add(time.Minute), "bar"))
+	bus.Publish(routeEvent(start.Add(2*time.Minute), "baz"))
+	waitEntries(t, tr, 2)
+
+	rsp := httptest.NewRecorder()
+	tr.ServeHTTP(rsp, httptest.NewRequest("GET", "/audit?since=2017-06-01T14:32:00Z", nil))
+	if rsp.Code != http.StatusOK {
+		t.Fatal("invalid status", rsp.Code)
+	}
+
+	var entries []*Entry
+	if err := json.Unmarshal(rsp.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Source != "*testdataclient.Client" ||
+		len(entries[0].Added) != 1 || entries[0].Added[0] != "baz" {
+		t.Error("invalid entries", entries)
+	}
+
+	if all := tr.Entries(time.Time{}); len(all) != 2 || all[0].Added[0] != "bar" {
+		t.Error("invalid retained entries", all)
+	}
+}
+
+func TestInvalidSince(t *testing.T) {
+	tr, err := New(events.NewBus(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tr.Close()
+
+	rsp := httptest.NewRecorder()
+	tr.ServeHTTP(rsp, httptest.NewRequest("GET", "/audit?since=yesterday", nil))
+	if rsp.Code != http.StatusBadRequest {
+		t.Error("invalid status", rsp.Code)
+	}
+}
+
+func TestAppendsToFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "skipper-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Close()
+	defer os.Remove(f.Name())
+
+	bus := events.NewBus()
+	tr, err := New(bus, Options{File: f.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Publish(routeEvent(time.Now(), "foo"))
+	bus.Publish(routeEvent(time.Now(), "bar"))
+	waitEntries(t, tr, 2)
+	tr.Close()
+
+	f, err = os.Open(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	var lines int
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var e Entry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Error(err)
+		}
+
+		lines++
+	}
+
+	if lines != 2 {
+		t.Error("invalid number of lines", lines)
+	}
+}
@@ -0,0 +1,22 @@
+/*
+Package audit implements the audit trail of the routing table changes,
+so that the time and the origin of a routing change can be looked up
+after the fact.
+
+Every applied version of the routing table is recorded with the type of
+the data client that triggered it, the time, and the IDs of the added,
+changed and removed routes, compared to the previous version. The
+entries are received from the event bus, see the events package.
+
+When enabled, the most recent entries are served as JSON on the /audit
+path of the support listener, the oldest first. The since query
+parameter limits the response to the recent changes:
+
+    curl localhost:9911/audit?since=2017-06-01T14:30:00Z
+
+Optionally, every entry is appended to a file, as a JSON object per
+line:
+
+    skipper -enable-route-audit -route-audit-file /var/log/skipper/routes.audit
+*/
+package audit
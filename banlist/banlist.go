@@ -0,0 +1,464 @@
+package banlist
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/events"
+	snet "github.com/zalando/skipper/net"
+)
+
+const (
+	// DefaultThreshold is the default number of violations within the
+	// window, that gets a client banned.
+	DefaultThreshold = 10
+
+	// DefaultWindow is the default time window of counting the
+	// violations.
+	DefaultWindow = time.Minute
+
+	// DefaultDuration is the default duration of the bans.
+	DefaultDuration = 10 * time.Minute
+
+	// DefaultSyncInterval is the default interval of synchronizing
+	// the bans with the shared store, and cleaning up the expired
+	// counters.
+	DefaultSyncInterval = 10 * time.Second
+)
+
+// DefaultStatuses are the status codes of the responses counted as
+// violations by default: the authentication and the authorization
+// failures, and the rate limited requests.
+var DefaultStatuses = []int{
+	http.StatusUnauthorized,
+	http.StatusForbidden,
+	http.StatusTooManyRequests,
+}
+
+// Store shares the bans between multiple skipper instances.
+type Store interface {
+
+	// Ban stores a ban until the specified time.
+	Ban(client string, until time.Time) error
+
+	// Unban removes a ban.
+	Unban(client string) error
+
+	// Bans returns the bans that are active at the specified time.
+	Bans(now time.Time) (map[string]time.Time, error)
+}
+
+// Options for creating a ban list.
+type Options struct {
+
+	// The number of violations within the window, that gets a client
+	// banned. Default: 10.
+	Threshold int
+
+	// The time window of counting the violations. Default: 1m.
+	Window time.Duration
+
+	// The duration of the bans. Default: 10m.
+	Duration time.Duration
+
+	// The status codes of the responses counted as violations.
+	// Default: 401, 403 and 429.
+	Statuses []int
+
+	// When set, the clients are identified by their Authorization
+	// header, too, and the violations are counted, and the bans are
+	// applied, both by the IP and the token.
+	IdentifyByToken bool
+
+	// When set, the IP of the clients is taken from the
+	// X-Forwarded-For header. Only use it, when skipper runs behind
+	// a load balancer that sets the header.
+	TrustForwardedFor bool
+
+	// When set, the bans are shared with the other skipper instances
+	// through the store.
+	Store Store
+
+	// The interval of synchronizing the bans with the store. Default:
+	// 10s.
+	SyncInterval time.Duration
+
+	// When set, a client_banned event is published on the bus, when
+	// a client gets banned.
+	EventBus *events.Bus
+}
+
+// Ban describes a banned client.
+type Ban struct {
+
+	// The client, as ip:<address> or token:<hash>.
+	Client string `json:"client"`
+
+	// The end of the ban.
+	Until time.Time `json:"until"`
+}
+
+type counter struct {
+	count int
+	start time.Time
+}
+
+// BanList counts the violations of the clients, and rejects the
+// requests of the banned clients.
+type BanList struct {
+	options  Options
+	statuses map[int]bool
+	mx       sync.Mutex
+	counters map[string]*counter
+	bans     map[string]time.Time
+	unsynced map[string]time.Time
+	quit     chan struct{}
+	done     chan struct{}
+}
+
+type statusWriter struct {
+	writer http.ResponseWriter
+	code   int
+}
+
+type handler struct {
+	banList *BanList
+	next    http.Handler
+}
+
+var errMissingClient = errors.New("missing client")
+
+// New creates a ban list, and starts the background synchronization.
+func New(o Options) *BanList {
+	if o.Threshold <= 0 {
+		o.Threshold = DefaultThreshold
+	}
+
+	if o.Window <= 0 {
+		o.Window = DefaultWindow
+	}
+
+	if o.Duration <= 0 {
+		o.Duration = DefaultDuration
+	}
+
+	if len(o.Statuses) == 0 {
+		o.Statuses = DefaultStatuses
+	}
+
+	if o.SyncInterval <= 0 {
+		o.SyncInterval = DefaultSyncInterval
+	}
+
+	b := &BanList{
+		options:  o,
+		statuses: make(map[int]bool),
+		counters: make(map[string]*counter),
+		bans:     make(map[string]time.Time),
+		unsynced: make(map[string]time.Time),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	for _, s := range o.Statuses {
+		b.statuses[s] = true
+	}
+
+	go b.run()
+	return b
+}
+
+func (b *BanList) clientIP(r *http.Request) string {
+	if b.options.TrustForwardedFor {
+		if ip := snet.RemoteHost(r); ip != nil {
+			return ip.String()
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// the identities of the client sending the request
+func (b *BanList) clients(r *http.Request) []string {
+	c := []string{"ip:" + b.clientIP(r)}
+	if b.options.IdentifyByToken {
+		if a := r.Header.Get("Authorization"); a != "" {
+			h := sha256.Sum256([]byte(a))
+			c = append(c, "token:"+hex.EncodeToString(h[:]))
+		}
+	}
+
+	return c
+}
+
+// Banned returns the end of the ban, when the client sending the
+// request is banned.
+func (b *BanList) Banned(r *http.Request) (time.Time, bool) {
+	now := time.Now()
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	for _, c := range b.clients(r) {
+		if until, ok := b.bans[c]; ok && now.Before(until) {
+			return until, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Violation counts a violation for the client sending the request,
+// and bans it, when the threshold is reached. Besides the responses
+// with the configured status codes, it can be used by other components
+// to report violations.
+func (b *BanList) Violation(r *http.Request) {
+	now := time.Now()
+	for _, c := range b.clients(r) {
+		b.violation(c, now)
+	}
+}
+
+func (b *BanList) violation(client string, now time.Time) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	c, ok := b.counters[client]
+	if !ok || now.Sub(c.start) >= b.options.Window {
+		c = &counter{start: now}
+		b.counters[client] = c
+	}
+
+	c.count++
+	if c.count < b.options.Threshold {
+		return
+	}
+
+	delete(b.counters, client)
+	until := now.Add(b.options.Duration)
+	b.bans[client] = until
+	if b.options.Store != nil {
+		b.unsynced[client] = until
+	}
+
+	log.Infof("client banned: %s, until: %v", client, until)
+	b.options.EventBus.Publish(&events.Event{
+		Type: events.TypeClientBanned,
+		Data: map[string]interface{}{
+			"client": client,
+			"until":  until,
+		},
+	})
+}
+
+// Unban removes the ban of a client, both locally and from the store.
+func (b *BanList) Unban(client string) error {
+	if client == "" {
+		return errMissingClient
+	}
+
+	b.mx.Lock()
+	delete(b.bans, client)
+	delete(b.unsynced, client)
+	delete(b.counters, client)
+	b.mx.Unlock()
+
+	if b.options.Store != nil {
+		return b.options.Store.Unban(client)
+	}
+
+	return nil
+}
+
+// Bans returns the active bans, sorted by the client.
+func (b *BanList) Bans() []Ban {
+	now := time.Now()
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	bans := []Ban{}
+	for c, until := range b.bans {
+		if now.Before(until) {
+			bans = append(bans, Ban{Client: c, Until: until})
+		}
+	}
+
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Client < bans[j].Client })
+	return bans
+}
+
+func (b *BanList) cleanup(now time.Time) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	for c, ct := range b.counters {
+		if now.Sub(ct.start) >= b.options.Window {
+			delete(b.counters, c)
+		}
+	}
+
+	for c, until := range b.bans {
+		if !now.Before(until) {
+			delete(b.bans, c)
+		}
+	}
+}
+
+// writes the new local bans to the store, and takes the bans of the
+// other instances from it
+func (b *BanList) sync(now time.Time) {
+	b.mx.Lock()
+	pending := b.unsynced
+	b.unsynced = make(map[string]time.Time)
+	b.mx.Unlock()
+
+	failed := make(map[string]time.Time)
+	for c, until := range pending {
+		if err := b.options.Store.Ban(c, until); err != nil {
+			log.Errorf("error while storing ban: %v", err)
+			failed[c] = until
+		}
+	}
+
+	bans, err := b.options.Store.Bans(now)
+	if err != nil {
+		log.Errorf("error while loading bans: %v", err)
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	for c, until := range failed {
+		if _, ok := b.unsynced[c]; !ok {
+			b.unsynced[c] = until
+		}
+	}
+
+	if err != nil {
+		return
+	}
+
+	// the store is the source of the bans, except for the ones that
+	// are not stored yet
+	b.bans = bans
+	for c, until := range b.unsynced {
+		b.bans[c] = until
+	}
+}
+
+func (b *BanList) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.options.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if b.options.Store != nil {
+				b.sync(now)
+			}
+
+			b.cleanup(now)
+		case <-b.quit:
+			return
+		}
+	}
+}
+
+// Wrap returns a handler that rejects the requests of the banned
+// clients with 403, and counts the responses with the configured
+// status codes as violations.
+func (b *BanList) Wrap(next http.Handler) http.Handler {
+	return &handler{banList: b, next: next}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if until, banned := h.banList.Banned(r); banned {
+		retry := int(until.Sub(time.Now())/time.Second) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retry))
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	sw := &statusWriter{writer: w}
+	h.next.ServeHTTP(sw, r)
+	if h.banList.statuses[sw.code] {
+		h.banList.Violation(r)
+	}
+}
+
+// ServeHTTP serves the active bans as JSON, and removes a ban with
+// DELETE ?client=<client>.
+func (b *BanList) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(b.Bans()); err != nil {
+			log.Error("error while sending bans", err)
+		}
+	case "DELETE":
+		if err := b.Unban(r.URL.Query().Get("client")); err == errMissingClient {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else if err != nil {
+			log.Errorf("error while removing ban: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// Close stops the background synchronization.
+func (b *BanList) Close() {
+	select {
+	case <-b.quit:
+	default:
+		close(b.quit)
+	}
+
+	<-b.done
+}
+
+func (w *statusWriter) Header() http.Header { return w.writer.Header() }
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+
+	w.writer.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	return w.writer.Write(p)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.writer.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+
+	return nil, nil, errors.New("could not hijack connection")
+}
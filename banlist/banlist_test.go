@@ -0,0 +1,202 @@
+package banlist
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/events"
+)
+
+func request(remoteAddr, token string) *http.Request {
+	r, _ := http.NewRequest("GET", "https://www.example.org/", nil)
+	r.RemoteAddr = remoteAddr
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return r
+}
+
+func serve(h http.Handler, r *http.Request) int {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestBansClient(t *testing.T) {
+	bus := events.NewBus()
+	sub := bus.Subscribe(0, events.TypeClientBanned)
+	defer sub.Close()
+
+	b := New(Options{Threshold: 3, Duration: time.Hour, EventBus: bus})
+	defer b.Close()
+
+	h := b.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+
+	// successful requests are not violations
+	for i := 0; i < 5; i++ {
+		if code := serve(h, request("10.0.0.1:1234", "valid")); code != http.StatusOK {
+			t.Fatal("unexpected status code", code)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := serve(h, request("10.0.0.1:1234", "invalid")); code != http.StatusUnauthorized {
+			t.Fatal("unexpected status code", code)
+		}
+	}
+
+	select {
+	case e := <-sub.C:
+		if e.Data["client"] != "ip:10.0.0.1" {
+			t.Error("invalid event", e.Data)
+		}
+	case <-time.After(time.Second):
+		t.Error("event not received")
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request("10.0.0.1:1234", "valid"))
+	if w.Code != http.StatusForbidden || w.Header().Get("Retry-After") != "3600" {
+		t.Error("failed to ban client", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	if code := serve(h, request("10.0.0.2:1234", "valid")); code != http.StatusOK {
+		t.Error("unexpected ban of other client", code)
+	}
+
+	// admin endpoint
+	var bans []Ban
+	w = httptest.NewRecorder()
+	b.ServeHTTP(w, httptest.NewRequest("GET", "/bans", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &bans); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bans) != 1 || bans[0].Client != "ip:10.0.0.1" {
+		t.Error("invalid bans", bans)
+	}
+
+	if code := serve(b, httptest.NewRequest("DELETE", "/bans", nil)); code != http.StatusBadRequest {
+		t.Error("failed to fail without client", code)
+	}
+
+	if code := serve(b, httptest.NewRequest("DELETE", "/bans?client=ip:10.0.0.1", nil)); code != http.StatusNoContent {
+		t.Error("failed to unban", code)
+	}
+
+	if code := serve(h, request("10.0.0.1:1234", "valid")); code != http.StatusOK {
+		t.Error("client still banned", code)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	b := New(Options{Threshold: 2, Window: 20 * time.Millisecond})
+	defer b.Close()
+
+	r := request("10.0.0.1:1234", "")
+	b.Violation(r)
+	time.Sleep(30 * time.Millisecond)
+	b.Violation(r)
+	if _, banned := b.Banned(r); banned {
+		t.Error("violations out of the window counted")
+	}
+
+	b.Violation(r)
+	if _, banned := b.Banned(r); !banned {
+		t.Error("failed to ban")
+	}
+}
+
+func TestIdentifyByToken(t *testing.T) {
+	b := New(Options{Threshold: 2, IdentifyByToken: true})
+	defer b.Close()
+
+	// the same token from different addresses
+	b.Violation(request("10.0.0.1:1234", "stolen"))
+	b.Violation(request("10.0.0.2:1234", "stolen"))
+
+	if _, banned := b.Banned(request("10.0.0.3:1234", "stolen")); !banned {
+		t.Error("failed to ban token")
+	}
+
+	if _, banned := b.Banned(request("10.0.0.1:1234", "")); banned {
+		t.Error("unexpected ban of the IP")
+	}
+}
+
+func TestForwardedFor(t *testing.T) {
+	for _, trust := range []bool{false, true} {
+		b := New(Options{Threshold: 1, TrustForwardedFor: trust})
+		r := request("10.0.0.1:1234", "")
+		r.Header.Set("X-Forwarded-For", "192.168.0.1")
+		b.Violation(r)
+
+		bans := b.Bans()
+		expected := "ip:10.0.0.1"
+		if trust {
+			expected = "ip:192.168.0.1"
+		}
+
+		if len(bans) != 1 || bans[0].Client != expected {
+			t.Error("invalid ban", trust, bans)
+		}
+
+		b.Close()
+	}
+}
+
+func TestSharedBans(t *testing.T) {
+	r := newFakeRedis(t, "")
+	defer r.close()
+
+	newBanList := func() *BanList {
+		s, err := NewRedisStore(RedisOptions{Address: r.listener.Addr().String()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return New(Options{Threshold: 1, Store: s, SyncInterval: 10 * time.Millisecond})
+	}
+
+	b1, b2 := newBanList(), newBanList()
+	defer b1.Close()
+	defer b2.Close()
+
+	req := request("10.0.0.1:1234", "")
+	b1.Violation(req)
+
+	banned := func() bool {
+		_, banned := b2.Banned(req)
+		return banned
+	}
+
+	timeout := time.After(time.Second)
+	for !banned() {
+		select {
+		case <-timeout:
+			t.Fatal("ban not shared")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	if err := b1.Unban("ip:10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout = time.After(time.Second)
+	for banned() {
+		select {
+		case <-timeout:
+			t.Fatal("unban not shared")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
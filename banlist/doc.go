@@ -0,0 +1,46 @@
+/*
+Package banlist implements the temporary banning of the clients that
+repeatedly violate the access policies, at the edge, before the route
+lookup.
+
+The ban list counts the responses with the status codes 401, 403 and
+429 per client, and when a client reaches the threshold, 10 violations
+within a minute by default, it gets banned for 10 minutes, and its
+requests are rejected with 403 and a Retry-After header:
+
+    skipper -enable-ban-list -ban-list-threshold 20 -ban-list-window 1m -ban-list-duration 1h
+
+The clients are identified by their IP address, and optionally by the
+Authorization header of the requests, too, with the -ban-list-by-token
+flag. In this case, the violations are counted, and the bans are
+applied, both by the IP and the token. The tokens are stored as their
+SHA-256 hash. When skipper runs behind a load balancer, the IP can be
+taken from the X-Forwarded-For header, with the
+-ban-list-trust-forwarded flag.
+
+Other components can report violations, too, e.g. the requests blocked
+by a web application firewall, by calling the Violation method.
+
+Shared Bans
+
+The bans can be shared by all the skipper instances of a fleet, by
+storing them in Redis:
+
+    skipper -enable-ban-list -ban-list-redis redis://:password@redis.example.org:6379/0
+
+The bans are stored in a sorted set, scored by the end of the bans, and
+synchronized every 10 seconds. A ban never shortens an existing, longer
+ban of the same client, when multiple instances ban it. This requires
+Redis 6.2 or newer. The violations are counted locally by each instance.
+
+Admin Endpoint
+
+The active bans are listed on the support listener, and a ban can be
+removed with a DELETE request:
+
+    curl localhost:9911/bans
+    curl -X DELETE localhost:9911/bans?client=ip:10.0.0.1
+
+When a client gets banned, a client_banned event is published.
+*/
+package banlist
@@ -0,0 +1,308 @@
+package banlist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRedisKey     = "skipper:bans"
+	defaultRedisTimeout = time.Second
+)
+
+// RedisOptions for creating a Redis store.
+type RedisOptions struct {
+
+	// The address of the Redis server, as host:port. Required.
+	Address string
+
+	// The password of the Redis server, when required.
+	Password string
+
+	// The database number.
+	DB int
+
+	// The key of the sorted set storing the bans. Default:
+	// skipper:bans.
+	Key string
+
+	// Timeout of the Redis commands. Default: 1s.
+	Timeout time.Duration
+}
+
+// RedisStore stores the bans in a sorted set in Redis, scored by the
+// end of the bans in Unix milliseconds, so that they can be shared by
+// multiple skipper instances. It requires Redis 6.2 or newer.
+type RedisStore struct {
+	options RedisOptions
+	mx      sync.Mutex
+	conn    net.Conn
+	reader  *bufio.Reader
+}
+
+type redisError string
+
+var errMissingRedisAddress = errors.New("banlist: Redis address required")
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// ParseRedisURL parses the Redis options from a URL, in the format of
+// redis://[:password@]host[:port][/db].
+func ParseRedisURL(s string) (RedisOptions, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return RedisOptions{}, err
+	}
+
+	if u.Scheme != "redis" || u.Host == "" {
+		return RedisOptions{}, fmt.Errorf("invalid Redis URL: %s", s)
+	}
+
+	o := RedisOptions{Address: u.Host}
+	if _, _, err := net.SplitHostPort(o.Address); err != nil {
+		o.Address = net.JoinHostPort(o.Address, "6379")
+	}
+
+	if u.User != nil {
+		o.Password, _ = u.User.Password()
+	}
+
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if o.DB, err = strconv.Atoi(db); err != nil {
+			return RedisOptions{}, fmt.Errorf("invalid Redis database: %s", db)
+		}
+	}
+
+	return o, nil
+}
+
+// NewRedisStore creates a Redis store. It connects to the server on
+// the first command, and reconnects after failures.
+func NewRedisStore(o RedisOptions) (*RedisStore, error) {
+	if o.Address == "" {
+		return nil, errMissingRedisAddress
+	}
+
+	if o.Key == "" {
+		o.Key = defaultRedisKey
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = defaultRedisTimeout
+	}
+
+	return &RedisStore{options: o}, nil
+}
+
+func writeCommand(w io.Writer, args []string) error {
+	b := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		b = append(b, "$"+strconv.Itoa(len(a))+"\r\n"+a+"\r\n"...)
+	}
+
+	_, err := w.Write(b)
+	return err
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	l, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasSuffix(l, "\r\n") {
+		return "", errors.New("redis: invalid reply")
+	}
+
+	return l[:len(l)-2], nil
+}
+
+// reads a reply as a string, an int64, a []byte, nil, a []interface{}
+// or a redisError
+func readReply(r *bufio.Reader) (interface{}, error) {
+	l, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(l) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch l[0] {
+	case '+':
+		return l[1:], nil
+	case '-':
+		return redisError(l[1:]), nil
+	case ':':
+		return strconv.ParseInt(l[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(l[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(l[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+
+		a := make([]interface{}, n)
+		for i := range a {
+			if a[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+
+		return a, nil
+	default:
+		return nil, fmt.Errorf("redis: invalid reply: %s", l)
+	}
+}
+
+func (s *RedisStore) connect() error {
+	conn, err := net.DialTimeout("tcp", s.options.Address, s.options.Timeout)
+	if err != nil {
+		return err
+	}
+
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+	if s.options.Password != "" {
+		if _, err := s.roundtrip("AUTH", s.options.Password); err != nil {
+			return err
+		}
+	}
+
+	if s.options.DB != 0 {
+		if _, err := s.roundtrip("SELECT", strconv.Itoa(s.options.DB)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *RedisStore) roundtrip(args ...string) (interface{}, error) {
+	s.conn.SetDeadline(time.Now().Add(s.options.Timeout))
+	if err := writeCommand(s.conn, args); err != nil {
+		return nil, err
+	}
+
+	rsp, err := readReply(s.reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if rerr, ok := rsp.(redisError); ok {
+		return nil, rerr
+	}
+
+	return rsp, nil
+}
+
+func (s *RedisStore) do(args ...string) (interface{}, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			s.close()
+			return nil, err
+		}
+	}
+
+	rsp, err := s.roundtrip(args...)
+	if _, ok := err.(redisError); err != nil && !ok {
+		// the connection is in an unknown state
+		s.close()
+	}
+
+	return rsp, err
+}
+
+func (s *RedisStore) close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func unixMillis(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
+
+// Ban stores a ban until the specified time. When the client is already
+// banned for longer, e.g. by another instance, the longer ban is kept.
+func (s *RedisStore) Ban(client string, until time.Time) error {
+	_, err := s.do("ZADD", s.options.Key, "GT", unixMillis(until), client)
+	return err
+}
+
+// Unban removes a ban.
+func (s *RedisStore) Unban(client string) error {
+	_, err := s.do("ZREM", s.options.Key, client)
+	return err
+}
+
+// Bans removes the expired bans, and returns the active ones.
+func (s *RedisStore) Bans(now time.Time) (map[string]time.Time, error) {
+	ms := unixMillis(now)
+	if _, err := s.do("ZREMRANGEBYSCORE", s.options.Key, "-inf", ms); err != nil {
+		return nil, err
+	}
+
+	rsp, err := s.do("ZRANGEBYSCORE", s.options.Key, "("+ms, "+inf", "WITHSCORES")
+	if err != nil {
+		return nil, err
+	}
+
+	a, ok := rsp.([]interface{})
+	if !ok || len(a)%2 != 0 {
+		return nil, errors.New("redis: invalid reply to ZRANGEBYSCORE")
+	}
+
+	bans := make(map[string]time.Time)
+	for i := 0; i < len(a); i += 2 {
+		client, ok := a[i].([]byte)
+		if !ok {
+			return nil, errors.New("redis: invalid member")
+		}
+
+		score, ok := a[i+1].([]byte)
+		if !ok {
+			return nil, errors.New("redis: invalid score")
+		}
+
+		until, err := strconv.ParseFloat(string(score), 64)
+		if err != nil {
+			return nil, err
+		}
+
+		bans[string(client)] = time.Unix(0, int64(until)*int64(time.Millisecond))
+	}
+
+	return bans, nil
+}
+
+// Close closes the connection to Redis.
+func (s *RedisStore) Close() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.close()
+}
@@ -0,0 +1,258 @@
+package banlist
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// a fake Redis server, supporting the sorted set commands used by the
+// store
+type fakeRedis struct {
+	t        *testing.T
+	listener net.Listener
+	password string
+	mx       sync.Mutex
+	sets     map[string]map[string]float64
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &fakeRedis{t: t, listener: l, password: password, sets: make(map[string]map[string]float64)}
+	go r.serve()
+	return r
+}
+
+func (r *fakeRedis) serve() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go r.handle(conn)
+	}
+}
+
+func parseScore(s string, exclusive bool) float64 {
+	switch s {
+	case "-inf":
+		return -1 << 62
+	case "+inf":
+		return 1 << 62
+	}
+
+	f, _ := strconv.ParseFloat(s, 64)
+	if exclusive {
+		f += 0.5
+	}
+
+	return f
+}
+
+func (r *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authenticated := r.password == ""
+	for {
+		req, err := readReply(reader)
+		if err != nil {
+			return
+		}
+
+		var args []string
+		for _, a := range req.([]interface{}) {
+			args = append(args, string(a.([]byte)))
+		}
+
+		if args[0] == "AUTH" {
+			if args[1] != r.password {
+				conn.Write([]byte("-ERR invalid password\r\n"))
+				continue
+			}
+
+			authenticated = true
+			conn.Write([]byte("+OK\r\n"))
+			continue
+		}
+
+		if !authenticated {
+			conn.Write([]byte("-NOAUTH Authentication required.\r\n"))
+			continue
+		}
+
+		r.mx.Lock()
+		set := r.sets[args[1]]
+		if set == nil {
+			set = make(map[string]float64)
+			r.sets[args[1]] = set
+		}
+
+		switch args[0] {
+		case "ZADD":
+			if len(args) != 5 || args[2] != "GT" {
+				conn.Write([]byte("-ERR syntax error\r\n"))
+				break
+			}
+
+			score, _ := strconv.ParseFloat(args[3], 64)
+			if current, ok := set[args[4]]; !ok || score > current {
+				set[args[4]] = score
+			}
+
+			conn.Write([]byte(":1\r\n"))
+		case "ZREM":
+			delete(set, args[2])
+			conn.Write([]byte(":1\r\n"))
+		case "ZREMRANGEBYSCORE":
+			max := parseScore(args[3], false)
+			for m, s := range set {
+				if s <= max {
+					delete(set, m)
+				}
+			}
+
+			conn.Write([]byte(":0\r\n"))
+		case "ZRANGEBYSCORE":
+			exclusive := args[2][0] == '('
+			if exclusive {
+				args[2] = args[2][1:]
+			}
+
+			min := parseScore(args[2], exclusive)
+			var members []string
+			for m, s := range set {
+				if s >= min {
+					members = append(members, m)
+				}
+			}
+
+			sort.Strings(members)
+			var reply []string
+			for _, m := range members {
+				reply = append(reply, m, strconv.FormatFloat(set[m], 'f', -1, 64))
+			}
+
+			writeCommand(conn, reply)
+		default:
+			conn.Write([]byte("-ERR unknown command\r\n"))
+		}
+
+		r.mx.Unlock()
+	}
+}
+
+func (r *fakeRedis) close() { r.listener.Close() }
+
+func TestParseRedisURL(t *testing.T) {
+	for _, test := range []struct {
+		url      string
+		expected RedisOptions
+		fail     bool
+	}{{
+		url:  "http://localhost",
+		fail: true,
+	}, {
+		url:  "redis://localhost/foo",
+		fail: true,
+	}, {
+		url:      "redis://localhost",
+		expected: RedisOptions{Address: "localhost:6379"},
+	}, {
+		url:      "redis://:secret@redis.example.org:6380/2",
+		expected: RedisOptions{Address: "redis.example.org:6380", Password: "secret", DB: 2},
+	}} {
+		o, err := ParseRedisURL(test.url)
+		if test.fail {
+			if err == nil {
+				t.Error("failed to fail", test.url)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if !reflect.DeepEqual(o, test.expected) {
+			t.Errorf("invalid options, got: %+v, expected: %+v", o, test.expected)
+		}
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	r := newFakeRedis(t, "secret")
+	defer r.close()
+
+	if _, err := NewRedisStore(RedisOptions{}); err == nil {
+		t.Error("failed to fail without address")
+	}
+
+	invalid, err := NewRedisStore(RedisOptions{Address: r.listener.Addr().String(), Password: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer invalid.Close()
+	if err := invalid.Ban("ip:10.0.0.1", time.Now().Add(time.Hour)); err == nil {
+		t.Error("failed to fail with invalid password")
+	}
+
+	s, err := NewRedisStore(RedisOptions{Address: r.listener.Addr().String(), Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	now := time.Now()
+	until := now.Add(time.Hour).Truncate(time.Millisecond)
+	for client, u := range map[string]time.Time{
+		"ip:10.0.0.1": until,
+		"ip:10.0.0.2": until,
+		"ip:10.0.0.3": now.Add(-time.Minute),
+	} {
+		if err := s.Ban(client, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a shorter ban doesn't overwrite a longer one
+	if err := s.Ban("ip:10.0.0.1", now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Unban("ip:10.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+
+	bans, err := s.Bans(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bans) != 1 || !bans["ip:10.0.0.1"].Equal(until) {
+		t.Error("invalid bans", bans)
+	}
+
+	// reconnects after the connection was lost
+	s.conn.Close()
+	if _, err := s.Bans(now); err == nil {
+		t.Error("failed to fail with a closed connection")
+	}
+
+	if _, err := s.Bans(now); err != nil {
+		t.Error("failed to reconnect", err)
+	}
+}
@@ -0,0 +1,452 @@
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/proxy"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+const (
+	// DefaultDuration is used when neither the duration nor the count
+	// of the requests is set.
+	DefaultDuration = 10 * time.Second
+
+	defaultHost        = "www.example.org"
+	routingLoadTimeout = 3 * time.Second
+)
+
+var (
+	errNoRequests      = errors.New("no requests to send")
+	errRoutingNotReady = errors.New("the routing table was not loaded in time")
+)
+
+// Options configure the load generator.
+type Options struct {
+
+	// The route table to measure. The routes with a network backend are
+	// forwarded to the mock backend.
+	Routes []*eskip.Route
+
+	// The filters available for the routes. Defaults to the built-in
+	// filters.
+	FilterRegistry filters.Registry
+
+	// The requests to send, in a round-robin manner. Defaults to the
+	// synthetic requests generated from the routes.
+	Requests []*Request
+
+	// The number of the concurrent clients. Defaults to GOMAXPROCS.
+	Concurrency int
+
+	// How long to send the requests for. When both the duration and the
+	// count are set, the load generator stops at whichever is reached
+	// first. When neither is set, DefaultDuration is used.
+	Duration time.Duration
+
+	// The number of the requests to send.
+	Count int
+
+	// The latency of the mock backend.
+	BackendLatency time.Duration
+
+	// The size of the response body of the mock backend.
+	BackendResponseSize int
+}
+
+// Latency contains the distribution of the time it took to serve the
+// requests.
+type Latency struct {
+	Min  time.Duration `json:"min"`
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P99  time.Duration `json:"p99"`
+	P999 time.Duration `json:"p999"`
+	Max  time.Duration `json:"max"`
+}
+
+// Result contains the report of a run.
+type Result struct {
+	Requests         int           `json:"requests"`
+	Duration         time.Duration `json:"duration"`
+	Throughput       float64       `json:"throughput"`
+	Latency          Latency       `json:"latency"`
+	StatusCodes      map[int]int   `json:"status_codes"`
+	AllocsPerRequest float64       `json:"allocs_per_request"`
+	BytesPerRequest  float64       `json:"bytes_per_request"`
+}
+
+type requestTemplate struct {
+	method string
+	uri    string
+	url    *url.URL
+	host   string
+	header http.Header
+	body   string
+}
+
+// a response writer that only records the status code
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+type runner struct {
+	concurrency int
+	requests    []*requestTemplate
+	backend     *httptest.Server
+	routing     *routing.Routing
+	proxy       *proxy.Proxy
+}
+
+func newTemplate(r *Request) (*requestTemplate, error) {
+	u, err := url.ParseRequestURI(r.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &requestTemplate{
+		method: r.Method,
+		uri:    r.URI,
+		url:    u,
+		host:   r.Host,
+		header: r.Header,
+		body:   r.Body,
+	}
+
+	if t.method == "" {
+		t.method = "GET"
+	}
+
+	if t.host == "" {
+		t.host = u.Host
+	}
+
+	if t.host == "" {
+		t.host = defaultHost
+	}
+
+	return t, nil
+}
+
+// creates a new request from the template, because the filters can
+// modify the request
+func (t *requestTemplate) request() *http.Request {
+	u := *t.url
+	r := &http.Request{
+		Method:     t.method,
+		URL:        &u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header, len(t.header)),
+		Host:       t.host,
+		RequestURI: t.uri,
+		RemoteAddr: "127.0.0.1:42424",
+		Body:       http.NoBody,
+	}
+
+	for k, v := range t.header {
+		r.Header[k] = v
+	}
+
+	if t.body != "" {
+		r.Body = ioutil.NopCloser(strings.NewReader(t.body))
+		r.ContentLength = int64(len(t.body))
+	}
+
+	return r
+}
+
+func (w *discardWriter) Header() http.Header { return w.header }
+
+func (w *discardWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *discardWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return len(p), nil
+}
+
+func (w *discardWriter) Flush() {}
+
+func (w *discardWriter) reset() {
+	for k := range w.header {
+		delete(w.header, k)
+	}
+
+	w.status = 0
+}
+
+func startBackend(latency time.Duration, size int) *httptest.Server {
+	body := []byte(strings.Repeat("x", size))
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+		if latency > 0 {
+			time.Sleep(latency)
+		}
+
+		w.Write(body)
+	}))
+}
+
+// forwards the network backends to the mock backend, without modifying
+// the original routes
+func mockBackends(routes []*eskip.Route, backend string) []*eskip.Route {
+	mocked := make([]*eskip.Route, len(routes))
+	for i, r := range routes {
+		rc := *r
+		if rc.BackendType == eskip.NetworkBackend {
+			rc.Backend = backend
+		}
+
+		mocked[i] = &rc
+	}
+
+	return mocked
+}
+
+func newRunner(o Options) (*runner, error) {
+	if o.FilterRegistry == nil {
+		o.FilterRegistry = builtin.MakeRegistry()
+	}
+
+	if o.Requests == nil {
+		o.Requests = Synthetic(o.Routes)
+	}
+
+	if len(o.Requests) == 0 {
+		return nil, errNoRequests
+	}
+
+	if o.Concurrency <= 0 {
+		o.Concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	r := &runner{concurrency: o.Concurrency}
+	for _, req := range o.Requests {
+		t, err := newTemplate(req)
+		if err != nil {
+			return nil, fmt.Errorf("invalid request %s: %v", req.URI, err)
+		}
+
+		r.requests = append(r.requests, t)
+	}
+
+	r.backend = startBackend(o.BackendLatency, o.BackendResponseSize)
+	r.routing = routing.New(routing.Options{
+		FilterRegistry: o.FilterRegistry,
+		DataClients:    []routing.DataClient{testdataclient.New(mockBackends(o.Routes, r.backend.URL))},
+	})
+
+	r.proxy = proxy.WithParams(proxy.Params{
+		Routing:                r.routing,
+		IdleConnectionsPerHost: o.Concurrency,
+		CloseIdleConnsPeriod:   -1,
+	})
+
+	deadline := time.Now().Add(routingLoadTimeout)
+	for !r.routing.Status().Ready() {
+		if time.Now().After(deadline) {
+			r.close()
+			return nil, errRoutingNotReady
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return r, nil
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+func measureLatency(latencies []time.Duration) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	var sum time.Duration
+	for _, l := range latencies {
+		sum += l
+	}
+
+	return Latency{
+		Min:  latencies[0],
+		Mean: sum / time.Duration(len(latencies)),
+		P50:  percentile(latencies, .5),
+		P90:  percentile(latencies, .9),
+		P99:  percentile(latencies, .99),
+		P999: percentile(latencies, .999),
+		Max:  latencies[len(latencies)-1],
+	}
+}
+
+func (r *runner) run(count int, d time.Duration) *Result {
+	if count <= 0 && d <= 0 {
+		d = DefaultDuration
+	}
+
+	capacity := 1 << 10
+	if count > 0 {
+		capacity = count/r.concurrency + 1
+	}
+
+	var (
+		next         int64
+		mx           sync.Mutex
+		wg           sync.WaitGroup
+		latencies    []time.Duration
+		memBefore    runtime.MemStats
+		memAfter     runtime.MemStats
+		statusCodes  = make(map[int]int)
+		start        = time.Now()
+		deadline     = start.Add(d)
+		requestCount = len(r.requests)
+	)
+
+	runtime.ReadMemStats(&memBefore)
+	for i := 0; i < r.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			l := make([]time.Duration, 0, capacity)
+			codes := make(map[int]int)
+			w := &discardWriter{header: make(http.Header)}
+			for {
+				n := atomic.AddInt64(&next, 1) - 1
+				if count > 0 && n >= int64(count) || d > 0 && time.Now().After(deadline) {
+					break
+				}
+
+				req := r.requests[n%int64(requestCount)].request()
+				w.reset()
+				s := time.Now()
+				r.proxy.ServeHTTP(w, req)
+				l = append(l, time.Since(s))
+				codes[w.status]++
+			}
+
+			mx.Lock()
+			defer mx.Unlock()
+			latencies = append(latencies, l...)
+			for code, n := range codes {
+				statusCodes[code] += n
+			}
+		}()
+	}
+
+	wg.Wait()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&memAfter)
+
+	res := &Result{
+		Requests:    len(latencies),
+		Duration:    elapsed,
+		Throughput:  float64(len(latencies)) / elapsed.Seconds(),
+		Latency:     measureLatency(latencies),
+		StatusCodes: statusCodes,
+	}
+
+	if res.Requests > 0 {
+		res.AllocsPerRequest = float64(memAfter.Mallocs-memBefore.Mallocs) / float64(res.Requests)
+		res.BytesPerRequest = float64(memAfter.TotalAlloc-memBefore.TotalAlloc) / float64(res.Requests)
+	}
+
+	return res
+}
+
+func (r *runner) close() {
+	r.proxy.Close()
+	r.routing.Close()
+	r.backend.Close()
+}
+
+// Run sends the requests to the proxy with the configured route table,
+// and reports the measured performance.
+func Run(o Options) (*Result, error) {
+	r, err := newRunner(o)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.close()
+	return r.run(o.Count, o.Duration), nil
+}
+
+// Benchmark runs the load generator as a Go benchmark, sending b.N
+// requests. Besides the time and the allocations, it reports the 99th
+// percentile of the latency. The Count and the Duration options are
+// ignored.
+func Benchmark(b *testing.B, o Options) {
+	r, err := newRunner(o)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer r.close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	res := r.run(b.N, 0)
+	b.StopTimer()
+	b.ReportMetric(float64(res.Latency.P99.Nanoseconds()), "p99-ns")
+}
+
+// Write prints the report in a human readable format.
+func (r *Result) Write(w io.Writer) error {
+	codes := make([]int, 0, len(r.StatusCodes))
+	for code := range r.StatusCodes {
+		codes = append(codes, code)
+	}
+
+	sort.Ints(codes)
+	statusCodes := make([]string, len(codes))
+	for i, code := range codes {
+		statusCodes[i] = fmt.Sprintf("%d: %d", code, r.StatusCodes[code])
+	}
+
+	l := r.Latency
+	_, err := fmt.Fprintf(
+		w,
+		"requests:     %d\n"+
+			"duration:     %v\n"+
+			"throughput:   %.1f requests/s\n"+
+			"latency:      min %v, mean %v, p50 %v, p90 %v, p99 %v, p99.9 %v, max %v\n"+
+			"allocations:  %.1f allocs/request, %.0f bytes/request\n"+
+			"status codes: %s\n",
+		r.Requests,
+		r.Duration,
+		r.Throughput,
+		l.Min, l.Mean, l.P50, l.P90, l.P99, l.P999, l.Max,
+		r.AllocsPerRequest, r.BytesPerRequest,
+		strings.Join(statusCodes, ", "),
+	)
+
+	return err
+}
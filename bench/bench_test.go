@@ -0,0 +1,142 @@
+package bench
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+const testRoutes = `
+	shunt: Path("/shunt") -> status(204) -> <shunt>;
+	backend: Host(/^api[.]example[.]org$/) && Path("/api/:id") -> setRequestHeader("X-Foo", "bar") -> "https://api.example.org";
+	method: Method("POST") && PathSubtree("/orders") && Header("X-Test", "foo") -> "https://orders.example.org";
+`
+
+const testCaptured = `[{
+	"method": "GET",
+	"uri": "/shunt?foo=bar",
+	"host": "www.example.org",
+	"request_header": {"Accept": ["text/plain"]}
+}, {
+	"method": "POST",
+	"uri": "/orders/42",
+	"host": "www.example.org",
+	"request_header": {"X-Test": ["foo"]},
+	"request_body": "Hello, world!"
+}]`
+
+func parseTestRoutes(t testing.TB) []*eskip.Route {
+	routes, err := eskip.Parse(testRoutes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return routes
+}
+
+func TestLiteralHost(t *testing.T) {
+	for expr, host := range map[string]string{
+		"^api[.]example[.]org$": "api.example.org",
+		`api\.example\.org`:     "api.example.org",
+		"^api[.]example[.]org":  "api.example.org",
+		"[.]example[.]org$":     ".example.org",
+		"^(api|www)[.]example$": "",
+		"^.*[.]example[.]org$":  "",
+	} {
+		if h := literalHost(expr); h != host {
+			t.Errorf("%s: expected %q, got %q", expr, host, h)
+		}
+	}
+}
+
+func TestSynthetic(t *testing.T) {
+	requests := Synthetic(parseTestRoutes(t))
+	if len(requests) != 3 {
+		t.Fatal("invalid number of requests", len(requests))
+	}
+
+	if requests[1].URI != "/api/x" || requests[1].Host != "api.example.org" {
+		t.Error("invalid request", requests[1].URI, requests[1].Host)
+	}
+
+	if requests[2].Method != "POST" || requests[2].URI != "/orders" || requests[2].Header.Get("X-Test") != "foo" {
+		t.Error("invalid request", requests[2].Method, requests[2].URI, requests[2].Header)
+	}
+}
+
+func TestLoadCaptured(t *testing.T) {
+	requests, err := LoadCaptured(strings.NewReader(testCaptured))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatal("invalid number of requests", len(requests))
+	}
+
+	if requests[1].Method != "POST" || requests[1].Body != "Hello, world!" || requests[1].Header.Get("X-Test") != "foo" {
+		t.Error("invalid request", requests[1])
+	}
+
+	if _, err := LoadCaptured(strings.NewReader("{")); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestRun(t *testing.T) {
+	routes := parseTestRoutes(t)
+	res, err := Run(Options{Routes: routes, Count: 300, Concurrency: 4, BackendResponseSize: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Requests != 300 {
+		t.Error("invalid number of requests", res.Requests)
+	}
+
+	if res.StatusCodes[204] != 100 || res.StatusCodes[200] != 200 {
+		t.Error("invalid status codes", res.StatusCodes)
+	}
+
+	l := res.Latency
+	if l.Min <= 0 || l.Min > l.P50 || l.P50 > l.P99 || l.P99 > l.Max || res.Throughput <= 0 || res.AllocsPerRequest <= 0 {
+		t.Error("invalid result", res)
+	}
+
+	var b bytes.Buffer
+	if err := res.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(b.String(), "status codes: 200: 200, 204: 100") {
+		t.Error("invalid report", b.String())
+	}
+}
+
+func TestRunCaptured(t *testing.T) {
+	requests, err := LoadCaptured(strings.NewReader(testCaptured))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Run(Options{Routes: parseTestRoutes(t), Requests: requests, Count: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StatusCodes[204] != 5 || res.StatusCodes[200] != 5 {
+		t.Error("invalid status codes", res.StatusCodes)
+	}
+}
+
+func TestNoRequests(t *testing.T) {
+	if _, err := Run(Options{}); err != errNoRequests {
+		t.Error("failed to fail", err)
+	}
+}
+
+func BenchmarkRoutes(b *testing.B) {
+	Benchmark(b, Options{Routes: parseTestRoutes(b)})
+}
@@ -0,0 +1,33 @@
+/*
+Package bench implements a load generator for measuring the performance
+of the proxy with a given route table, so that the performance
+regressions can be caught before a release.
+
+The requests are either replayed from the traffic recorded by the
+capture package, or they are generated from the routes themselves. The
+proxy runs in the same process as the load generator, and the routes
+with a network backend are forwarded to a mock backend, that responds
+with a fixed size body and an optional latency. The report contains the
+throughput, the latency percentiles and the number of the allocations
+per request.
+
+The allocations are counted for the whole process, including the load
+generator and the mock backend, and so the numbers are meant for
+comparing different versions of skipper or different route tables, and
+not as absolute values.
+
+The load generator is available as the bench command of the skipper
+executable:
+
+    skipper bench -duration 30s -concurrency 64 routes.eskip
+    curl -H "Authorization: Bearer $CAPTURE_TOKEN" localhost:9911/capture > traffic.json
+    skipper bench -requests-file traffic.json routes.eskip
+
+and as a Go benchmark harness:
+
+    func BenchmarkRoutes(b *testing.B) {
+        routes, _ := eskip.Parse(doc)
+        bench.Benchmark(b, bench.Options{Routes: routes})
+    }
+*/
+package bench
@@ -0,0 +1,146 @@
+package bench
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp/syntax"
+	"strings"
+
+	"github.com/zalando/skipper/capture"
+	"github.com/zalando/skipper/eskip"
+)
+
+// Request describes a request sent by the load generator.
+type Request struct {
+	Method string
+	URI    string
+	Host   string
+	Header http.Header
+	Body   string
+}
+
+// LoadCaptured reads the requests from the JSON array of recorded
+// exchanges, as served by the capture handler.
+func LoadCaptured(r io.Reader) ([]*Request, error) {
+	var exchanges []*capture.Exchange
+	if err := json.NewDecoder(r).Decode(&exchanges); err != nil {
+		return nil, err
+	}
+
+	requests := make([]*Request, 0, len(exchanges))
+	for _, e := range exchanges {
+		requests = append(requests, &Request{
+			Method: e.Method,
+			URI:    e.URI,
+			Host:   e.Host,
+			Header: e.RequestHeader,
+			Body:   e.RequestBody,
+		})
+	}
+
+	return requests, nil
+}
+
+// returns the literal host, when the regexp matches only a single host
+func literalHost(expr string) string {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return ""
+	}
+
+	re = re.Simplify()
+	if re.Op == syntax.OpLiteral {
+		return string(re.Rune)
+	}
+
+	if re.Op != syntax.OpConcat || len(re.Sub) == 0 {
+		return ""
+	}
+
+	var host string
+	for _, sub := range re.Sub {
+		switch sub.Op {
+		case syntax.OpBeginText, syntax.OpBeginLine, syntax.OpEndText, syntax.OpEndLine:
+		case syntax.OpLiteral:
+			if host != "" {
+				return ""
+			}
+
+			host = string(sub.Rune)
+		default:
+			return ""
+		}
+	}
+
+	return host
+}
+
+// replaces the wildcards of a path with a fixed value
+func literalPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = "x"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func routePath(r *eskip.Route) string {
+	if r.Path != "" {
+		return r.Path
+	}
+
+	for _, p := range r.Predicates {
+		if p.Name != "Path" && p.Name != "PathSubtree" || len(p.Args) != 1 {
+			continue
+		}
+
+		if s, ok := p.Args[0].(string); ok {
+			return s
+		}
+	}
+
+	return ""
+}
+
+// Synthetic generates a request for each route, using the path, the
+// method, the literal host and the exact headers of the route. The
+// routes matching only by regular expressions or custom predicates may
+// not be matched by the generated requests.
+func Synthetic(routes []*eskip.Route) []*Request {
+	requests := make([]*Request, 0, len(routes))
+	for _, r := range routes {
+		req := &Request{
+			Method: r.Method,
+			URI:    literalPath(routePath(r)),
+			Host:   "www.example.org",
+			Header: make(http.Header),
+		}
+
+		if req.Method == "" {
+			req.Method = "GET"
+		}
+
+		for _, h := range r.HostRegexps {
+			if host := literalHost(h); host != "" {
+				req.Host = host
+				break
+			}
+		}
+
+		for name, value := range r.Headers {
+			req.Header.Set(name, value)
+		}
+
+		requests = append(requests, req)
+	}
+
+	return requests
+}
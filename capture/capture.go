@@ -0,0 +1,408 @@
+package capture
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/logging"
+)
+
+const (
+	// Header is the name of the request header that triggers the
+	// capturing of a request, when it is sent from a trusted
+	// network. The header is removed from every request.
+	Header = "X-Skipper-Capture"
+
+	// DefaultBufferSize is the default number of the captured
+	// requests kept in memory.
+	DefaultBufferSize = 100
+
+	// DefaultMaxBodySize is the default maximum number of bytes
+	// recorded from the request and the response bodies.
+	DefaultMaxBodySize = 1 << 16
+
+	redacted = "[redacted]"
+)
+
+var errMissingToken = errors.New("capture: token required")
+
+// the values of these headers are redacted, unless the credentials are
+// kept explicitly
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+}
+
+type contextKey struct{}
+
+// Options for creating a Capture instance.
+type Options struct {
+
+	// The number of the captured requests kept in memory. When
+	// the buffer is full, the oldest entries are overwritten.
+	// Default: 100.
+	BufferSize int
+
+	// The maximum number of bytes recorded from the request and
+	// the response bodies. The rest of the bodies is proxied, but
+	// not recorded. Default: 64k.
+	MaxBodySize int64
+
+	// The networks, in CIDR notation, whose clients are allowed to
+	// trigger the capturing with the X-Skipper-Capture header. When
+	// empty, the capturing can be triggered only by the capture()
+	// filter of the routes.
+	TrustedNetworks []string
+
+	// The bearer token required to read and to drop the captured
+	// requests. Required.
+	Token string
+
+	// When set, the values of the Authorization, Proxy-Authorization,
+	// Cookie and Set-Cookie headers are captured in clear text.
+	// Otherwise, they are redacted.
+	KeepCredentials bool
+}
+
+// Exchange contains the captured details of a request and its
+// response.
+type Exchange struct {
+	Time                  time.Time     `json:"time"`
+	Duration              time.Duration `json:"duration"`
+	RouteID               string        `json:"route_id,omitempty"`
+	Method                string        `json:"method"`
+	URI                   string        `json:"uri"`
+	Proto                 string        `json:"proto"`
+	Host                  string        `json:"host"`
+	RemoteAddress         string        `json:"remote_address"`
+	RequestHeader         http.Header   `json:"request_header"`
+	RequestBody           string        `json:"request_body,omitempty"`
+	RequestBodyTruncated  bool          `json:"request_body_truncated,omitempty"`
+	StatusCode            int           `json:"status_code"`
+	ResponseHeader        http.Header   `json:"response_header"`
+	ResponseBody          string        `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool          `json:"response_body_truncated,omitempty"`
+}
+
+// Capture records the requests and responses marked for capturing, in
+// a ring buffer. It implements http.Handler, serving the recorded
+// exchanges.
+type Capture struct {
+	mx          sync.Mutex
+	buffer      []*Exchange
+	next        int
+	maxBodySize int64
+	trusted     []*net.IPNet
+	token       []byte
+	keepCreds   bool
+}
+
+// session holds the state of a single request. The request body can be
+// read by the proxy from a different goroutine than the handler's.
+type session struct {
+	mx                    sync.Mutex
+	enabled               bool
+	maxBodySize           int64
+	requestBody           []byte
+	requestBodyTruncated  bool
+	responseBody          []byte
+	responseBodyTruncated bool
+}
+
+type recordingBody struct {
+	body    io.ReadCloser
+	session *session
+}
+
+type recordingWriter struct {
+	writer  http.ResponseWriter
+	session *session
+	code    int
+}
+
+type handler struct {
+	capture *Capture
+	next    http.Handler
+}
+
+// New creates a Capture instance.
+func New(o Options) (*Capture, error) {
+	if o.Token == "" {
+		return nil, errMissingToken
+	}
+
+	if o.BufferSize <= 0 {
+		o.BufferSize = DefaultBufferSize
+	}
+
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = DefaultMaxBodySize
+	}
+
+	c := &Capture{
+		buffer:      make([]*Exchange, o.BufferSize),
+		maxBodySize: o.MaxBodySize,
+		token:       []byte(o.Token),
+		keepCreds:   o.KeepCredentials,
+	}
+
+	for _, n := range o.TrustedNetworks {
+		_, ipn, err := net.ParseCIDR(n)
+		if err != nil {
+			return nil, err
+		}
+
+		c.trusted = append(c.trusted, ipn)
+	}
+
+	return c, nil
+}
+
+// Enable marks the request of the context for capturing. It has no
+// effect when the request is not served through a capture handler.
+func Enable(ctx context.Context) {
+	s, ok := ctx.Value(contextKey{}).(*session)
+	if !ok {
+		return
+	}
+
+	s.mx.Lock()
+	s.enabled = true
+	s.mx.Unlock()
+}
+
+// appends to the recorded body up to the limit
+func (s *session) record(body []byte, truncated bool, p []byte) ([]byte, bool) {
+	if !s.enabled || truncated {
+		return body, truncated
+	}
+
+	if rest := s.maxBodySize - int64(len(body)); int64(len(p)) > rest {
+		return append(body, p[:rest]...), true
+	}
+
+	return append(body, p...), false
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	s := b.session
+	s.mx.Lock()
+	s.requestBody, s.requestBodyTruncated = s.record(s.requestBody, s.requestBodyTruncated, p[:n])
+	s.mx.Unlock()
+	return n, err
+}
+
+func (b *recordingBody) Close() error { return b.body.Close() }
+
+func (w *recordingWriter) Header() http.Header { return w.writer.Header() }
+
+func (w *recordingWriter) WriteHeader(code int) {
+	w.code = code
+	w.writer.WriteHeader(code)
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	n, err := w.writer.Write(p)
+	s := w.session
+	s.mx.Lock()
+	s.responseBody, s.responseBodyTruncated = s.record(s.responseBody, s.responseBodyTruncated, p[:n])
+	s.mx.Unlock()
+	return n, err
+}
+
+func (w *recordingWriter) Flush() {
+	if f, ok := w.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *recordingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.writer.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+
+	return nil, nil, errors.New("could not hijack connection")
+}
+
+func (c *Capture) isTrusted(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range c.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Capture) store(e *Exchange) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.buffer[c.next] = e
+	c.next = (c.next + 1) % len(c.buffer)
+}
+
+// Exchanges returns the captured exchanges, the most recent first.
+func (c *Capture) Exchanges() []*Exchange {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	var e []*Exchange
+	for i := 1; i <= len(c.buffer); i++ {
+		ei := c.buffer[(c.next-i+len(c.buffer))%len(c.buffer)]
+		if ei == nil {
+			break
+		}
+
+		e = append(e, ei)
+	}
+
+	return e
+}
+
+// Clear drops the captured exchanges.
+func (c *Capture) Clear() {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.buffer = make([]*Exchange, len(c.buffer))
+	c.next = 0
+}
+
+func (c *Capture) authorized(r *http.Request) bool {
+	const prefix = "Bearer "
+	a := r.Header.Get("Authorization")
+	if !strings.HasPrefix(a, prefix) {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(a, prefix)), c.token) == 1
+}
+
+// ServeHTTP returns the captured exchanges as JSON for GET requests, and
+// drops them for DELETE requests. The clients need to send the
+// configured token as a bearer token.
+func (c *Capture) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "DELETE" {
+		w.Header().Set("Allow", "GET, DELETE")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !c.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(c.Exchanges()); err != nil {
+			log.Error("error while sending the captured requests", err)
+		}
+	case "DELETE":
+		c.Clear()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Wrap creates an http.Handler that records the requests and the
+// responses of the wrapped handler, when they are marked for capturing
+// by the X-Skipper-Capture header from a trusted network, or by the
+// Enable function.
+func (c *Capture) Wrap(next http.Handler) http.Handler {
+	return &handler{capture: c, next: next}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s := &session{maxBodySize: h.capture.maxBodySize}
+	if r.Header.Get(Header) != "" {
+		s.enabled = h.capture.isTrusted(r.RemoteAddr)
+		r.Header.Del(Header)
+	}
+
+	requestHeader := h.capture.cloneHeader(r.Header)
+	r = r.WithContext(context.WithValue(r.Context(), contextKey{}, s))
+	if r.Body != nil {
+		r.Body = &recordingBody{body: r.Body, session: s}
+	}
+
+	rw := &recordingWriter{writer: w, session: s}
+	start := time.Now()
+	h.next.ServeHTTP(rw, r)
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if !s.enabled {
+		return
+	}
+
+	e := &Exchange{
+		Time:                  start,
+		Duration:              time.Since(start),
+		Method:                r.Method,
+		URI:                   r.RequestURI,
+		Proto:                 r.Proto,
+		Host:                  r.Host,
+		RemoteAddress:         r.RemoteAddr,
+		RequestHeader:         requestHeader,
+		RequestBody:           string(s.requestBody),
+		RequestBodyTruncated:  s.requestBodyTruncated,
+		StatusCode:            rw.code,
+		ResponseHeader:        h.capture.cloneHeader(w.Header()),
+		ResponseBody:          string(s.responseBody),
+		ResponseBodyTruncated: s.responseBodyTruncated,
+	}
+
+	if d := logging.ProxyDetailsFromContext(r.Context()); d != nil {
+		e.RouteID = d.RouteID
+	}
+
+	h.capture.store(e)
+}
+
+// copies the header, and redacts the credentials, unless they are kept
+func (c *Capture) cloneHeader(h http.Header) http.Header {
+	hh := make(http.Header)
+	for k, v := range h {
+		hh[k] = append([]string(nil), v...)
+	}
+
+	if c.keepCreds {
+		return hh
+	}
+
+	for _, k := range sensitiveHeaders {
+		for i := range hh[k] {
+			hh[k][i] = redacted
+		}
+	}
+
+	return hh
+}
@@ -0,0 +1,212 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/logging"
+)
+
+const testToken = "test-token"
+
+func echo(enable bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if enable {
+			Enable(r.Context())
+		}
+
+		if d := logging.ProxyDetailsFromContext(r.Context()); d != nil {
+			d.RouteID = "testRoute"
+		}
+
+		w.Header().Set("X-Echo", "true")
+		w.WriteHeader(http.StatusTeapot)
+		io.Copy(w, r.Body)
+	})
+}
+
+func request(c *Capture, h http.Handler, remoteAddr, header, body string) {
+	r := httptest.NewRequest("POST", "/foo?bar=baz", bytes.NewBufferString(body))
+	r.RemoteAddr = remoteAddr
+	if header != "" {
+		r.Header.Set(Header, header)
+	}
+
+	r = r.WithContext(logging.ContextWithProxyDetails(r.Context(), &logging.ProxyDetails{}))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestInvalidNetwork(t *testing.T) {
+	if _, err := New(Options{Token: testToken, TrustedNetworks: []string{"foo"}}); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestCaptureByHeader(t *testing.T) {
+	c, err := New(Options{Token: testToken, TrustedNetworks: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := c.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(Header) != "" {
+			t.Error("failed to remove the capture header")
+		}
+
+		echo(false).ServeHTTP(w, r)
+	}))
+
+	request(c, h, "10.0.0.1:1234", "", "not marked")
+	request(c, h, "192.168.0.1:1234", "true", "not trusted")
+	request(c, h, "10.0.0.1:1234", "true", "captured")
+
+	e := c.Exchanges()
+	if len(e) != 1 {
+		t.Fatal("invalid number of captured requests", len(e))
+	}
+
+	ee := e[0]
+	if ee.RouteID != "testRoute" || ee.Method != "POST" || ee.URI != "/foo?bar=baz" ||
+		ee.RemoteAddress != "10.0.0.1:1234" || ee.StatusCode != http.StatusTeapot ||
+		ee.RequestBody != "captured" || ee.ResponseBody != "captured" ||
+		ee.ResponseHeader.Get("X-Echo") != "true" {
+		t.Error("invalid captured request", ee)
+	}
+}
+
+func TestCaptureByFilterWithRingBuffer(t *testing.T) {
+	c, err := New(Options{Token: testToken, BufferSize: 2, MaxBodySize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := c.Wrap(echo(true))
+	for _, body := range []string{"foo", "bar", "bazqux"} {
+		request(c, h, "192.168.0.1:1234", "true", body)
+	}
+
+	e := c.Exchanges()
+	if len(e) != 2 {
+		t.Fatal("invalid number of captured requests", len(e))
+	}
+
+	if e[0].RequestBody != "bazq" || !e[0].RequestBodyTruncated ||
+		e[0].ResponseBody != "bazq" || !e[0].ResponseBodyTruncated {
+		t.Error("failed to truncate the bodies", e[0])
+	}
+
+	if e[1].RequestBody != "bar" || e[1].RequestBodyTruncated {
+		t.Error("invalid order", e[1])
+	}
+}
+
+func TestMissingToken(t *testing.T) {
+	if _, err := New(Options{}); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func authorizedRequest(method string) *http.Request {
+	r := httptest.NewRequest(method, "/capture", nil)
+	r.Header.Set("Authorization", "Bearer "+testToken)
+	return r
+}
+
+func TestServeCaptured(t *testing.T) {
+	c, err := New(Options{Token: testToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request(c, c.Wrap(echo(true)), "127.0.0.1:1234", "", "foo")
+
+	for _, method := range []string{"GET", "DELETE"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "/capture", nil)
+		r.Header.Set("Authorization", "Bearer foo")
+		c.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Error("failed to reject the invalid token", method, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, authorizedRequest("GET"))
+
+	var e []*Exchange
+	if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(e) != 1 || e[0].RequestBody != "foo" {
+		t.Error("failed to serve the captured requests", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	c.ServeHTTP(w, authorizedRequest("DELETE"))
+	if w.Code != http.StatusNoContent || len(c.Exchanges()) != 0 {
+		t.Error("failed to clear the captured requests")
+	}
+
+	w = httptest.NewRecorder()
+	c.ServeHTTP(w, authorizedRequest("POST"))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Error("failed to reject the method")
+	}
+
+	b, _ := ioutil.ReadAll(w.Body)
+	if strings.TrimSpace(string(b)) != "" {
+		t.Error("unexpected body")
+	}
+}
+
+func TestRedactCredentials(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		c, err := New(Options{Token: testToken, KeepCredentials: keep})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h := c.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Enable(r.Context())
+			w.Header().Set("Set-Cookie", "session=bar")
+			w.Header().Set("X-Echo", "true")
+		}))
+
+		r := httptest.NewRequest("GET", "/foo", nil)
+		r.Header.Set("Authorization", "Bearer foo")
+		r.Header.Add("Cookie", "session=foo")
+		r.Header.Add("Cookie", "theme=dark")
+		r.Header.Set("User-Agent", "test")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+
+		e := c.Exchanges()
+		if len(e) != 1 {
+			t.Fatal("invalid number of captured requests", len(e))
+		}
+
+		rq, rs := e[0].RequestHeader, e[0].ResponseHeader
+		if rq.Get("User-Agent") != "test" || rs.Get("X-Echo") != "true" {
+			t.Error("failed to capture the headers", rq, rs)
+		}
+
+		if keep {
+			if rq.Get("Authorization") != "Bearer foo" || rq["Cookie"][1] != "theme=dark" ||
+				rs.Get("Set-Cookie") != "session=bar" {
+				t.Error("failed to keep the credentials", rq, rs)
+			}
+		} else {
+			if rq.Get("Authorization") != redacted || len(rq["Cookie"]) != 2 ||
+				rq["Cookie"][0] != redacted || rq["Cookie"][1] != redacted ||
+				rs.Get("Set-Cookie") != redacted {
+				t.Error("failed to redact the credentials", rq, rs)
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+/*
+Package capture implements the recording of the full requests and
+responses, for diagnostic purposes.
+
+The capturing is opt-in, and it is triggered for a request either by the
+X-Skipper-Capture header, when the request is sent from one of the
+trusted networks, or by the capture() filter of the matched route:
+
+    debugMe: Path("/api/orders") -> capture() -> "https://orders.example.org";
+
+The headers of the request, as received by skipper, and the response
+headers, together with the first part of the request and response
+bodies, up to the configured size, are recorded in a ring buffer. When
+the buffer is full, the oldest entries are overwritten.
+
+The captured requests are served on the /capture path of the support
+(metrics) listener, as a JSON array, the most recent first. A DELETE
+request to the same path drops the captured entries. Both require the
+configured token, sent as a bearer token. Example:
+
+    skipper -enable-capture -capture-token secret -capture-trusted-networks 10.0.0.0/8
+    curl -H 'X-Skipper-Capture: true' localhost:9090/api/orders
+    curl -H 'Authorization: Bearer secret' localhost:9911/capture
+
+The values of the Authorization, Proxy-Authorization, Cookie and
+Set-Cookie headers are redacted, unless the -capture-keep-credentials
+flag is set. Note that the captured bodies may still contain sensitive
+information, and they are not redacted.
+*/
+package capture
@@ -37,6 +37,7 @@ const (
 	appendFileFlag     = "append-file"
 	prettyFlag         = "pretty"
 	jsonFlag           = "json"
+	writeFlag          = "w"
 
 	defaultEtcdUrls     = "http://127.0.0.1:2379,http://127.0.0.1:4001"
 	defaultEtcdPrefix   = "/skipper"
@@ -70,6 +71,7 @@ var (
 	appendFileArg     string
 	pretty            bool
 	printJson         bool
+	writeFile         bool
 )
 
 var (
@@ -103,6 +105,7 @@ func initFlags() {
 
 	flags.BoolVar(&pretty, prettyFlag, false, prettyUsage)
 	flags.BoolVar(&printJson, jsonFlag, false, jsonUsage)
+	flags.BoolVar(&writeFile, writeFlag, false, writeUsage)
 }
 
 func init() {
@@ -183,20 +186,22 @@ func processInnkeeperArgs(innkeeperUrl, oauthToken string) (*medium, error) {
 		oauthToken: oauthToken}, nil
 }
 
-// returns file type medium if a positional parameter is defined.
-func processFileArg() (*medium, error) {
+// returns file type media if positional parameters are defined, at
+// most max of them.
+func processFileArgs(max int) ([]*medium, error) {
 	nonFlagArgs := flags.Args()
-	if len(nonFlagArgs) > 1 {
+	if len(nonFlagArgs) > max {
 		return nil, invalidNumberOfArgs
 	}
 
-	if len(nonFlagArgs) == 0 {
-		return nil, nil
+	var media []*medium
+	for _, a := range nonFlagArgs {
+		media = append(media, &medium{
+			typ:  file,
+			path: a})
 	}
 
-	return &medium{
-		typ:  file,
-		path: nonFlagArgs[0]}, nil
+	return media, nil
 }
 
 // returns stdin type medium if stdin is not TTY.
@@ -236,6 +241,12 @@ func processPatchArgs(pfilters, pfile, afilters, afile string) []*medium {
 
 // returns media detected from the executing command.
 func processArgs() ([]*medium, error) {
+	return processArgsWithFiles(1)
+}
+
+// same as processArgs, but accepts multiple files as positional
+// parameters, e.g. for diff.
+func processArgsWithFiles(maxFiles int) ([]*medium, error) {
 	err := flags.Parse(os.Args[2:])
 	if err != nil {
 		return nil, err
@@ -274,14 +285,12 @@ func processArgs() ([]*medium, error) {
 			ids: strings.Split(inlineRouteIds, ",")})
 	}
 
-	fileArg, err := processFileArg()
+	fileArgs, err := processFileArgs(maxFiles)
 	if err != nil {
 		return nil, err
 	}
 
-	if fileArg != nil {
-		media = append(media, fileArg)
-	}
+	media = append(media, fileArgs...)
 
 	stdinArg := processStdin()
 
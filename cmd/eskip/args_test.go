@@ -191,3 +191,34 @@ func TestProcessArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessDiffArgs(t *testing.T) {
+	preserveArgs([]string{"file1", "file2"}, func() {
+		media, err := processArgsWithFiles(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(media) != 2 {
+			t.Fatal("invalid number of parsed media")
+		}
+
+		checkMedium(t, &medium{typ: file, path: "file1"}, media[0], 0, 0)
+		checkMedium(t, &medium{typ: file, path: "file2"}, media[1], 0, 1)
+
+		a, err := validateSelectMedia(diff, media)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if a.in != media[0] || a.other != media[1] {
+			t.Error("invalid inputs selected")
+		}
+	})
+
+	preserveArgs([]string{"file1", "file2", "file3"}, func() {
+		if _, err := processArgsWithFiles(2); err != invalidNumberOfArgs {
+			t.Error("failed to fail", err)
+		}
+	})
+}
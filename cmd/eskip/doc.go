@@ -22,10 +22,23 @@ For command line help, enter:
 
 Examples
 
-Check if an eskip file has valid syntax:
+Check if an eskip file has valid syntax, and uses only the filters and
+predicates available in skipper:
 
     eskip check routes.eskip
 
+Format an eskip file in the canonical format:
+
+    eskip fmt -w routes.eskip
+
+Show the differences between the routes in etcd and an eskip file:
+
+    eskip diff routes.eskip
+
+Show the differences between two eskip files:
+
+    eskip diff routes-old.eskip routes.eskip
+
 Print routes stored in etcd:
 
     eskip print -etcd-urls https://etcd.example.org
@@ -83,11 +96,12 @@ const (
 	appendFileUsage     = "append filters from a file to each patched route"
 	prettyUsage         = "prints routes in a more readable format"
 	jsonUsage           = "prints routes as JSON"
+	writeUsage          = "fmt: writes the formatted routes back to the input file"
 
 	// command line help (1):
 	help1 = `Usage: eskip <command> [media flags] [--] [file]
-Commands: check|print|upsert|reset|delete|patch
-Verify, print, update or delete Skipper routes.
+Commands: check|print|fmt|diff|upsert|reset|delete|patch
+Verify, print, format, compare, update or delete Skipper routes.
 See more: https://github.com/zalando/skipper
 
 Media types:
@@ -118,12 +132,29 @@ Media flags:
 	help2 = `
 Commands:
 
-check    verifies the syntax of routes. Accepts one input medium
-         of the following types: etcd (default), stdin, file, inline.
-         Example:
+check    verifies the syntax of routes, and validates them against the
+         filters and the predicates available in skipper. Accepts one
+         input medium of the following types: etcd (default), stdin,
+         file, inline. Example:
          eskip check -etcd-urls http://etcd.example.org
 
-print    same as check, but also prints the routes.
+print    same as check, but only verifies the syntax, and prints the
+         routes.
+
+fmt      prints the routes in the canonical format. Accepts the same
+         input media as print. With -w, the formatted routes are
+         written back to the input file. The comments are not
+         preserved. Example:
+         eskip fmt -w routes.eskip
+
+diff     compares the routes of two inputs by their id, ignoring the
+         formatting and the order of the routes and the predicates, and
+         prints the removed (-), the added (+) and the changed routes.
+         Accepts two input media of the following types: etcd, stdin,
+         file, inline. When only one is specified, it is compared to
+         the routes in etcd. Exits with a non-zero status, when the
+         routes differ. Example:
+         eskip diff routes-old.eskip routes.eskip
 
 upsert   insert/update routes from input to output. Expects one input
          medium of the following types: stdin, file, inline.
@@ -33,6 +33,8 @@ const (
 	reset  command = "reset"
 	delete command = "delete"
 	patch  command = "patch"
+	format command = "fmt"
+	diff   command = "diff"
 	ver    command = "version"
 )
 
@@ -49,6 +51,8 @@ var commands = map[command]commandFunc{
 	reset:  resetCmd,
 	delete: deleteCmd,
 	patch:  patchCmd,
+	format: fmtCmd,
+	diff:   diffCmd,
 	ver:    versionCmd}
 
 var (
@@ -61,6 +65,9 @@ var stdout io.Writer = os.Stdout
 type cmdArgs struct {
 	in, out  *medium
 	allMedia []*medium
+
+	// the second input, for diff
+	other *medium
 }
 
 func printStderr(args ...interface{}) {
@@ -132,7 +139,12 @@ func main() {
 	}
 
 	// process arguments, not checking if they make any sense:
-	media, err := processArgs()
+	fileArgs := 1
+	if cmd == diff {
+		fileArgs = 2
+	}
+
+	media, err := processArgsWithFiles(fileArgs)
 	if err != nil {
 		exitHint(err)
 	}
@@ -0,0 +1,164 @@
+// Copyright 2015 Zalando SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+var (
+	writeRequiresFile = errors.New("-w requires a file input")
+	differentRoutes   = errors.New("routes differ")
+)
+
+// prints the routes in the canonical format, one route per paragraph,
+// with the predicates, the filters and the backend on separate lines.
+func formatRoutes(routes []*eskip.Route) string {
+	if len(routes) == 1 && routes[0].Id == "" {
+		return routes[0].Print(true) + "\n"
+	}
+
+	var b bytes.Buffer
+	for i, r := range routes {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		fmt.Fprintf(&b, "%s: %s;\n", r.Id, r.Print(true))
+	}
+
+	return b.String()
+}
+
+// command executed for fmt.
+func fmtCmd(a cmdArgs) error {
+	routes, err := loadRoutesChecked(a.in)
+	if err != nil {
+		return err
+	}
+
+	formatted := formatRoutes(routes)
+	if !writeFile {
+		_, err := fmt.Fprint(stdout, formatted)
+		return err
+	}
+
+	if a.in.typ != file {
+		return writeRequiresFile
+	}
+
+	current, err := ioutil.ReadFile(a.in.path)
+	if err != nil {
+		return err
+	}
+
+	if string(current) == formatted {
+		return nil
+	}
+
+	fi, err := os.Stat(a.in.path)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(a.in.path, []byte(formatted), fi.Mode())
+}
+
+func sortedStrings(s []string) []string {
+	c := append([]string(nil), s...)
+	sort.Strings(c)
+	return c
+}
+
+// serializes a route without the id, with the predicates in a fixed
+// order, because their order doesn't change the meaning of the route.
+// The order of the filters is kept.
+func canonicalString(r *eskip.Route) string {
+	c := *r
+	c.Id = ""
+	c.HostRegexps = sortedStrings(r.HostRegexps)
+	c.PathRegexps = sortedStrings(r.PathRegexps)
+
+	c.Predicates = append([]*eskip.Predicate(nil), r.Predicates...)
+	sort.SliceStable(c.Predicates, func(i, j int) bool {
+		return fmt.Sprint(c.Predicates[i].Name, c.Predicates[i].Args) <
+			fmt.Sprint(c.Predicates[j].Name, c.Predicates[j].Args)
+	})
+
+	return c.String()
+}
+
+func printRoute(prefix string, r *eskip.Route) {
+	fmt.Fprintf(stdout, "%s%s: %s;\n", prefix, r.Id, r.String())
+}
+
+// command executed for diff.
+func diffCmd(a cmdArgs) error {
+	left, err := loadRoutesChecked(a.in)
+	if err != nil {
+		return err
+	}
+
+	right, err := loadRoutesChecked(a.other)
+	if err != nil {
+		return err
+	}
+
+	mleft, mright := mapRoutes(left), mapRoutes(right)
+
+	var ids []string
+	for id := range mleft {
+		ids = append(ids, id)
+	}
+
+	for id := range mright {
+		if _, ok := mleft[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	var differ bool
+	for _, id := range ids {
+		l, r := mleft[id], mright[id]
+		switch {
+		case r == nil:
+			printRoute("-", l)
+		case l == nil:
+			printRoute("+", r)
+		case routesDiffer(l, r):
+			printRoute("-", l)
+			printRoute("+", r)
+		default:
+			continue
+		}
+
+		differ = true
+	}
+
+	if differ {
+		return differentRoutes
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2015 Zalando SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func withStdout(f func()) string {
+	var b bytes.Buffer
+	previous := stdout
+	stdout = &b
+	defer func() { stdout = previous }()
+	f()
+	return b.String()
+}
+
+func TestFormat(t *testing.T) {
+	const (
+		doc = `r1: Header("X-B", "b") && Path("/foo") && Header("X-A", "a") -> setPath("/") -> "https://www.example.org";
+			r0: * -> <shunt>`
+
+		expected = `r1: Path("/foo") && Header("X-A", "a") && Header("X-B", "b")
+  -> setPath("/")
+  -> "https://www.example.org";
+
+r0: *
+  -> <shunt>;
+`
+	)
+
+	out := withStdout(func() {
+		if err := fmtCmd(cmdArgs{in: &medium{typ: inline, eskip: doc}}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if out != expected {
+		t.Errorf("invalid format, got:\n%s\nexpected:\n%s", out, expected)
+	}
+
+	if err := fmtCmd(cmdArgs{in: &medium{typ: inline, eskip: "invalid doc"}}); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestFormatWrite(t *testing.T) {
+	const name = "testFile"
+	err := withFile(name, `r0: Path("/foo") -> "https://www.example.org"; r1: * -> <shunt>`, func(_ *os.File) {
+		writeFile = true
+		defer func() { writeFile = false }()
+
+		out := withStdout(func() {
+			if err := fmtCmd(cmdArgs{in: &medium{typ: file, path: name}}); err != nil {
+				t.Fatal(err)
+			}
+		})
+
+		if out != "" {
+			t.Error("unexpected output", out)
+		}
+
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != "r0: Path(\"/foo\")\n  -> \"https://www.example.org\";\n\nr1: *\n  -> <shunt>;\n" {
+			t.Error("invalid formatted file", string(b))
+		}
+
+		if err := fmtCmd(cmdArgs{in: &medium{typ: inline, eskip: "* -> <shunt>"}}); err != writeRequiresFile {
+			t.Error("failed to fail", err)
+		}
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	const (
+		left = `
+			r0: * -> <shunt>;
+			r1: Header("X-A", "a") && Path("/foo") -> setPath("/") -> "https://www.example.org";
+			r2: Path("/bar") -> "https://bar.example.org";
+		`
+
+		right = `
+			r1: Path("/foo") && Header("X-A", "a")
+				-> setPath("/")
+				-> "https://www.example.org";
+			r2: Path("/bar") -> "https://bar2.example.org";
+			r3: Path("/baz") -> <shunt>;
+		`
+
+		expected = `-r0: * -> <shunt>;
+-r2: Path("/bar") -> "https://bar.example.org";
++r2: Path("/bar") -> "https://bar2.example.org";
++r3: Path("/baz") -> <shunt>;
+`
+	)
+
+	var err error
+	out := withStdout(func() {
+		err = diffCmd(cmdArgs{
+			in:    &medium{typ: inline, eskip: left},
+			other: &medium{typ: inline, eskip: right},
+		})
+	})
+
+	if err != differentRoutes {
+		t.Error("failed to report the difference", err)
+	}
+
+	if out != expected {
+		t.Errorf("invalid diff, got:\n%s\nexpected:\n%s", out, expected)
+	}
+
+	out = withStdout(func() {
+		err = diffCmd(cmdArgs{
+			in:    &medium{typ: inline, eskip: `r0: Host(/b/) && Host(/a/) -> <shunt>`},
+			other: &medium{typ: inline, eskip: `r0: Host(/a/) && Host(/b/) -> <shunt>`},
+		})
+	})
+
+	if err != nil || out != "" {
+		t.Error("unexpected difference", err, out)
+	}
+}
+
+func TestCheckFiltersAndPredicates(t *testing.T) {
+	for _, doc := range []string{
+		`r0: * -> noSuchFilter() -> <shunt>`,
+		`r0: NoSuchPredicate() -> <shunt>`,
+		`r0: * -> setPath(42) -> <shunt>`,
+	} {
+		if err := checkCmd(cmdArgs{in: &medium{typ: inline, eskip: doc}}); err != invalidRoutes {
+			t.Error("failed to fail", doc, err)
+		}
+	}
+
+	const valid = `r0: Cookie("alpha", /on/) && Traffic(.1) -> setPath("/") -> encryptCookie("session", "default") -> <shunt>`
+	if err := checkCmd(cmdArgs{in: &medium{typ: inline, eskip: valid}}); err != nil {
+		t.Error(err)
+	}
+}
@@ -102,7 +102,11 @@ func checkCmd(a cmdArgs) error {
 		return err
 	}
 
-	return checkRepeatedRouteIds(routes)
+	if err := checkRepeatedRouteIds(routes); err != nil {
+		return err
+	}
+
+	return validateRoutes(routes)
 }
 
 // command executed for print.
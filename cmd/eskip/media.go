@@ -44,7 +44,9 @@ var commandToValidations = map[command]validateSelectFunc{
 	upsert: validateSelectWrite,
 	reset:  validateSelectWrite,
 	delete: validateSelectDelete,
-	patch:  validateSelectPatch}
+	patch:  validateSelectPatch,
+	format: validateSelectRead,
+	diff:   validateSelectDiff}
 
 type medium struct {
 	typ          mediaType
@@ -172,6 +174,32 @@ func validateSelectPatch(media []*medium) (a cmdArgs, err error) {
 	return
 }
 
+// validate media from args, and select the two inputs to compare. When
+// only one input is specified, it is compared to the default etcd.
+// (diff)
+func validateSelectDiff(media []*medium) (a cmdArgs, err error) {
+	for _, m := range media {
+		switch m.typ {
+		case inlineIds, patchPrepend, patchPrependFile, patchAppend, patchAppendFile:
+			err = invalidInputType
+			return
+		}
+	}
+
+	switch len(media) {
+	case 0:
+		err = missingInput
+	case 1:
+		a.other = media[0]
+	case 2:
+		a.in, a.other = media[0], media[1]
+	default:
+		err = tooManyInputs
+	}
+
+	return
+}
+
 // Validates media from args for the current command, and selects input and/or output.
 func validateSelectMedia(cmd command, media []*medium) (cmdArgs cmdArgs, err error) {
 	a, err := commandToValidations[cmd](media)
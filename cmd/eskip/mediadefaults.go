@@ -9,7 +9,9 @@ var commandToDefaultMediums = map[command]defaultFunc{
 	upsert: defaultWrite,
 	reset:  defaultWrite,
 	delete: defaultWrite,
-	patch:  defaultRead}
+	patch:  defaultRead,
+	format: defaultRead,
+	diff:   defaultRead}
 
 func defaultRead(a cmdArgs) (aa cmdArgs, err error) {
 	aa = a
@@ -0,0 +1,95 @@
+// Copyright 2015 Zalando SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/filters/cookie"
+	geoipfilter "github.com/zalando/skipper/filters/geoip"
+	"github.com/zalando/skipper/filters/waf"
+	"github.com/zalando/skipper/predicates/clientcert"
+	predicatecookie "github.com/zalando/skipper/predicates/cookie"
+	geoippredicate "github.com/zalando/skipper/predicates/geoip"
+	"github.com/zalando/skipper/predicates/interval"
+	"github.com/zalando/skipper/predicates/query"
+	"github.com/zalando/skipper/predicates/source"
+	"github.com/zalando/skipper/predicates/traffic"
+	"github.com/zalando/skipper/routing"
+)
+
+var invalidRoutes = errors.New("one or more invalid routes")
+
+// the filters that depend on the runtime configuration of skipper, e.g.
+// on keys or rules, are checked only by their name
+type configuredFilterSpec string
+
+func (s configuredFilterSpec) Name() string { return string(s) }
+
+func (s configuredFilterSpec) CreateFilter([]interface{}) (filters.Filter, error) {
+	return nil, nil
+}
+
+// the filters available in skipper
+func filterRegistry() filters.Registry {
+	r := builtin.MakeRegistry()
+	r.Register(geoipfilter.New(nil))
+	r.Register(configuredFilterSpec(cookie.EncryptCookieFilterName))
+	r.Register(configuredFilterSpec(waf.Name))
+	return r
+}
+
+// the predicates available in skipper, besides the builtin ones
+func predicateSpecs() []routing.PredicateSpec {
+	return []routing.PredicateSpec{
+		source.New(),
+		interval.NewBetween(),
+		interval.NewBefore(),
+		interval.NewAfter(),
+		predicatecookie.New(),
+		query.New(),
+		traffic.New(),
+		clientcert.New(),
+		geoippredicate.NewCountry(nil),
+		geoippredicate.NewASN(nil),
+	}
+}
+
+// validates the routes against the filters and the predicates available
+// in skipper, and prints the errors if any.
+func validateRoutes(routes []*eskip.Route) error {
+	rt := routing.New(routing.Options{
+		FilterRegistry: filterRegistry(),
+		Predicates:     predicateSpecs(),
+	})
+	defer rt.Close()
+
+	var failed bool
+	for _, r := range routes {
+		if err := rt.Validate([]*eskip.Route{r}); err != nil {
+			printStderr(err)
+			failed = true
+		}
+	}
+
+	if failed {
+		return invalidRoutes
+	}
+
+	return nil
+}
@@ -25,7 +25,7 @@ type (
 func any(_ *eskip.Route) bool { return true }
 
 func routesDiffer(left, right *eskip.Route) bool {
-	return left.String() != right.String()
+	return canonicalString(left) != canonicalString(right)
 }
 
 func mapRoutes(routes []*eskip.Route) routeMap {
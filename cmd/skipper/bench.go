@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/bench"
+	"github.com/zalando/skipper/eskip"
+)
+
+const (
+	benchUsageHeader = `Usage: skipper bench [options] <routes file>
+
+Sends requests to the proxy with the routes of the eskip file, forwarding
+the network backends to a mock backend, and reports the throughput, the
+latency percentiles and the allocations per request.
+
+Options:
+`
+
+	benchRequestsFileUsage        = "JSON file with the requests recorded by the /capture endpoint. When not set, a request is generated for each route"
+	benchConcurrencyUsage         = "number of the concurrent clients, defaults to GOMAXPROCS"
+	benchDurationUsage            = "how long to send the requests for, defaults to 10s, unless the count is set"
+	benchCountUsage               = "number of the requests to send. When both the count and the duration are set, the one reached first stops the benchmark"
+	benchBackendLatencyUsage      = "latency of the mock backend"
+	benchBackendResponseSizeUsage = "size of the response body of the mock backend, in bytes"
+	benchJSONUsage                = "print the report as JSON"
+
+	defaultBenchBackendResponseSize = 1024
+)
+
+func loadBenchRequests(path string) ([]*bench.Request, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return bench.LoadCaptured(f)
+}
+
+// runs the bench mode, returns the exit code
+func runBench(args []string) int {
+	var (
+		requestsFile string
+		o            bench.Options
+		printJSON    bool
+	)
+
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, benchUsageHeader)
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&requestsFile, "requests-file", "", benchRequestsFileUsage)
+	fs.IntVar(&o.Concurrency, "concurrency", 0, benchConcurrencyUsage)
+	fs.DurationVar(&o.Duration, "duration", 0, benchDurationUsage)
+	fs.IntVar(&o.Count, "count", 0, benchCountUsage)
+	fs.DurationVar(&o.BackendLatency, "backend-latency", 0, benchBackendLatencyUsage)
+	fs.IntVar(&o.BackendResponseSize, "backend-response-size", defaultBenchBackendResponseSize, benchBackendResponseSizeUsage)
+	fs.BoolVar(&printJSON, "json", false, benchJSONUsage)
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	// keep the report readable
+	log.SetLevel(log.WarnLevel)
+
+	doc, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	o.Routes, err = eskip.Parse(string(doc))
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	if requestsFile != "" {
+		o.Requests, err = loadBenchRequests(requestsFile)
+		if err != nil {
+			log.Errorf("failed to load the requests: %v", err)
+			return 1
+		}
+	}
+
+	res, err := bench.Run(o)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	if printJSON {
+		err = json.NewEncoder(os.Stdout).Encode(res)
+	} else {
+		err = res.Write(os.Stdout)
+	}
+
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	return 0
+}
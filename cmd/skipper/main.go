@@ -6,6 +6,10 @@ For the list of command line options, run:
 
     skipper -help
 
+To measure the performance of a route table with a mock backend, run:
+
+    skipper bench -help
+
 For details about the usage and extensibility of skipper, please see the
 documentation of the root skipper package.
 
@@ -15,6 +19,7 @@ package documentation.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -24,7 +29,24 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zalando/skipper"
+	"github.com/zalando/skipper/audit"
+	"github.com/zalando/skipper/banlist"
+	"github.com/zalando/skipper/capture"
+	"github.com/zalando/skipper/config"
+	"github.com/zalando/skipper/errorreport"
+	"github.com/zalando/skipper/filters/auth"
+	"github.com/zalando/skipper/filters/waf"
+	"github.com/zalando/skipper/geoip"
+	"github.com/zalando/skipper/maintenance"
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/profiling"
 	"github.com/zalando/skipper/proxy"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/secrets"
+	"github.com/zalando/skipper/slowclient"
+	"github.com/zalando/skipper/strictparsing"
+	"github.com/zalando/skipper/tap"
+	"github.com/zalando/skipper/tlsconfig"
 )
 
 const (
@@ -36,8 +58,13 @@ const (
 	defaultRuntimeMetrics       = true
 	defaultApplicationLogPrefix = "[APP]"
 	defaultApplicationLogLevel  = "INFO"
+	defaultAccessLogFormat      = "default"
 	defaultBackendFlushInterval = 20 * time.Millisecond
 	defaultExperimentalUpgrade  = false
+	defaultTracer               = "noop"
+	defaultTracerSampleRate     = 1.0
+	defaultTracerSampler        = "parentbased"
+	defaultACMECache            = "/var/cache/skipper/acme"
 
 	addressUsage                   = "network address that skipper should listen on"
 	etcdUrlsUsage                  = "urls of nodes in an etcd cluster, storing route definitions"
@@ -56,32 +83,176 @@ const (
 	oauthCredentialsDirUsage       = "directory where oauth credentials are stored: client.json and user.json"
 	oauthScopeUsage                = "the whitespace separated list of oauth scopes"
 	routesFileUsage                = "file containing static route definitions"
+	routeSigningKeysUsage          = "comma separated list of the public key files trusted to sign the route definitions in the routes file and in etcd"
+	adminAddressUsage              = "when set, the admin API for changing the routes and the debug settings at runtime is served on this address. Without -admin-tls-cert and -admin-tls-key, it needs to be a loopback address, and without a host, it is bound to localhost"
+	adminCertPathTLSUsage          = "the certificate file of the admin listener. When set together with -admin-tls-key, the admin API is served with TLS"
+	adminKeyPathTLSUsage           = "the key file of the admin listener"
+	routeHistorySizeUsage          = "number of the last applied versions of the routing table kept in memory, that the admin API can roll back to. When negative, no history is kept"
+	routeHistoryDirUsage           = "when set, the versions of the routing table are stored in this directory, too, and they are kept across restarts. It can't be used together with -route-signing-keys"
+	routingSnapshotFileUsage       = "when set, the route definitions of the routing table are saved in this file after every complete update and on shutdown, and on startup the routing table is built from them and served until the data clients are loaded. It can't be used together with -route-signing-keys"
+	maintenanceRoutesUsage         = "comma separated list of the IDs of the routes that respond with 503 in maintenance mode, switched with the admin API. When empty, all the requests are responded with 503"
+	maintenanceRetryAfterUsage     = "value of the Retry-After header of the responses in maintenance mode"
+	namespacesUsage                = "comma separated list of the namespaces of the routes. A route belongs to a namespace, when its id starts with the name of the namespace and a double underscore, e.g. team_a__api"
+	namespaceTokensUsage           = "sources of the admin API tokens of the namespaces, granting access only to the routes of the namespace, e.g. team_a=file:/etc/skipper/team-a-tokens,team_b=env:TEAM_B_TOKENS"
+	namespaceRateLimitsUsage       = "maximum number of requests per second served by the routes of the namespaces, e.g. team_a=1000,team_b=200"
+	namespacePoliciesUsage         = "YAML file with the policies of the namespaces: the hosts, custom predicates, filters and backends of the routes that can be set with the tokens of the namespaces. A namespace without a policy cannot set routes"
+	maintenanceDrainPeriodUsage    = "period of closing the existing connections after entering maintenance mode. When negative, the connections are not drained"
+	allowedFiltersUsage            = "comma separated list of the filters that can be used in the routes. When set, the routes with other filters are rejected"
+	disabledFiltersUsage           = "comma separated list of the filters that cannot be used in the routes"
+	allowedPredicatesUsage         = "comma separated list of the predicates, including the built-in ones like Path or Host, that can be used in the routes. When set, the routes with other predicates are rejected"
+	disabledPredicatesUsage        = "comma separated list of the predicates that cannot be used in the routes, including the built-in ones"
+	maxRoutesUsage                 = "maximum number of the routes in the routing table. The updates exceeding it are rejected. Zero means no limit"
+	maxFiltersPerRouteUsage        = "maximum number of filters in a route. Zero means no limit"
+	maxRegexpComplexityUsage       = "maximum complexity of the regular expressions in the routes, in the number of the instructions of the compiled expression. Zero means no limit"
+	maxRouteSizeUsage              = "maximum estimated memory used by a route, in bytes. Zero means no limit"
+	adminTokensUsage               = "source of the bearer tokens accepted by the admin API, file:<path> or env:<variable>"
 	sourcePollTimeoutUsage         = "polling timeout of the routing data sources, in milliseconds"
 	insecureUsage                  = "flag indicating to ignore the verification of the TLS certificates of the backend services"
 	proxyPreserveHostUsage         = "flag indicating to preserve the incoming request 'Host' header in the outgoing requests"
 	idleConnsPerHostUsage          = "maximum idle connections per backend host"
 	closeIdleConnsPeriodUsage      = "period of closing all idle connections in seconds or as a duration string. Not closing when less than 0"
 	devModeUsage                   = "enables developer time behavior, like ubuffered routing updates"
+	configFileUsage                = "path of a YAML (.yaml, .yml) or TOML (.toml) configuration file, whose keys are the flag names, optionally grouped in sections. The flags set on the command line take precedence"
+	validateUsage                  = "loads and validates the routes, the TLS certificates and the other configured resources, prints a report, and exits, with a non-zero status on errors"
 	metricsListenerUsage           = "network address used for exposing the /metrics endpoint. An empty value disables metrics."
 	metricsPrefixUsage             = "allows setting a custom path prefix for metrics export"
 	enableProfileUsage             = "enable profile information on the metrics endpoint with path /pprof"
+	enablePrometheusMetricsUsage   = "serve the request latencies as Prometheus histograms, and the other metrics translated to Prometheus metrics with labels, on /metrics/prometheus, with trace ID exemplars in the OpenMetrics format"
+	metricsGraphiteAddrUsage       = "when set, the metrics are pushed to this Graphite server. The address can be prefixed with the network, e.g. udp://graphite.example.org:2003. Default network: tcp"
+	metricsGraphitePrefixUsage     = "prefix of the keys pushed to Graphite. Defaults to the metrics prefix"
+	metricsStatsdAddrUsage         = "when set, the metrics are pushed to this StatsD server. The address can be prefixed with the network, e.g. tcp://statsd.example.org:8125. Default network: udp"
+	metricsStatsdPrefixUsage       = "prefix of the keys pushed to StatsD. Defaults to the metrics prefix"
+	metricsReportIntervalUsage     = "interval of pushing the metrics to Graphite and StatsD"
+	metricsTimerSampleUsage        = "sampling of the durations measured by the timers: uniform, expdecay or hdr. The hdr sampling counts all the durations with a bounded relative error, keeping the tail latencies"
+	metricsTimerReservoirSizeUsage = "number of the durations kept by the uniform and the expdecay timer samples"
+	metricsHistogramPrecisionUsage = "number of the significant decimal digits of the durations counted by the hdr timers, from 1 to 5"
+	metricsHistogramMaxValueUsage  = "highest duration tracked by the hdr timers. The longer durations are counted as this"
+	metricsResetTokenUsage         = "when set, the metrics can be reset with a DELETE request to /metrics on the metrics listener, e.g. /metrics/serveroute/my-route, authorized with this bearer token"
 	debugGcMetricsUsage            = "enables reporting of the Go garbage collector statistics exported in debug.GCStats"
 	runtimeMetricsUsage            = "enables reporting of the Go runtime statistics exported in runtime and specifically runtime.MemStats"
 	serveRouteMetricsUsage         = "enables reporting total serve time metrics for each route"
 	serveHostMetricsUsage          = "enables reporting total serve time metrics for each host"
 	backendHostMetricsUsage        = "enables reporting total serve time metrics for each backend"
-	applicationLogUsage            = "output file for the application log. When not set, /dev/stderr is used"
+	applicationLogUsage            = "output file for the application log. When not set, /dev/stderr is used. Remote outputs can be set with the URLs of a syslog server, e.g. syslog+udp://localhost:514, syslog+tcp://..., syslog+tls://..., or of an HTTP endpoint"
 	applicationLogLevelUsage       = "log level for application logs, possible values: PANIC, FATAL, ERROR, WARN, INFO, DEBUG"
 	applicationLogPrefixUsage      = "prefix for each log entry"
-	accessLogUsage                 = "output file for the access log, When not set, /dev/stderr is used"
+	accessLogUsage                 = "output file for the access log, When not set, /dev/stderr is used. Accepts the same remote outputs as -application-log"
 	accessLogDisabledUsage         = "when this flag is set, no access log is printed"
-	debugEndpointUsage             = "when this address is set, skipper starts an additional listener returning the original and transformed requests"
-	certPathTLSUsage               = "the path on the local filesystem to the certificate file (including any intermediates)"
-	keyPathTLSUsage                = "the path on the local filesystem to the certificate's private key file"
+	accessLogFormatUsage           = "format of the access log entries, possible values: default, combined, common, json. The default is the Apache combined format extended with the duration in ms and the requested host"
+	accessLogJSONFieldsUsage       = "comma separated list of the fields written by the json access log, when not set, the default fields are written, the country and asn fields only when listed"
+	accessLogRequestHeadersUsage   = "comma separated list of request headers written by the json access log"
+	accessLogResponseHeadersUsage  = "comma separated list of response headers written by the json access log"
+	accessLogSampleRatesUsage      = "comma separated list of access log sample rates by response status class, e.g. 2xx=0.01,3xx=0.1. The classes without a rate are always logged"
+	accessLogExcludeRoutesUsage    = "comma separated list of route IDs excluded from the access log"
+	accessLogExcludePathsUsage     = "comma separated list of path prefixes excluded from the access log"
+	enableCaptureUsage             = "enables capturing the full requests and responses, triggered by the X-Skipper-Capture header from the trusted networks or by the capture() filter; served on /capture of the metrics listener"
+	captureBufferSizeUsage         = "number of captured requests kept in memory"
+	captureMaxBodySizeUsage        = "maximum number of bytes recorded from the captured request and response bodies"
+	captureTrustedNetworksUsage    = "comma separated list of networks in CIDR notation whose clients can trigger capturing with the X-Skipper-Capture header"
+	captureTokenUsage              = "bearer token required by the capture endpoint"
+	captureKeepCredentialsUsage    = "captures the Authorization, Proxy-Authorization, Cookie and Set-Cookie headers in clear text, instead of redacting them"
+	enableTapUsage                 = "enables streaming the summary of the live requests as server-sent events; served on /tap of the metrics listener"
+	tapTokenUsage                  = "bearer token required by the tap endpoint"
+	tapSampleRateUsage             = "fraction of the requests streamed by the tap endpoint"
+	tapHeadersUsage                = "comma separated list of request headers included in the tap events; credentials and cookies are redacted"
+	debugEndpointUsage             = "when this address is set, skipper starts an additional listener returning the original and transformed requests, and accepting synthetic requests in JSON on the /__skipper/dryrun path"
+	certPathTLSUsage               = "the path on the local filesystem to the certificate file (including any intermediates), or a comma separated list of certificate files selected by SNI, the first one being the default"
+	keyPathTLSUsage                = "the path on the local filesystem to the certificate's private key file, or a comma separated list of key files in the order of the certificates"
+	certDirTLSUsage                = "directory containing certificate and key file pairs, named as <name>.crt and <name>.key, served in addition to -tls-cert and -tls-key"
+	tlsKubernetesSecretsUsage      = "comma separated list of Kubernetes secrets of the type kubernetes.io/tls, as <namespace>/<name>, whose certificates are served by the TLS listener"
+	vaultAddressUsage              = "address of the Vault server, default: the value of the VAULT_ADDR environment variable"
+	vaultPKIPathUsage              = "path of the issue endpoint of a Vault PKI role, e.g. pki/issue/skipper, to serve a certificate issued by Vault; the token is taken from the VAULT_TOKEN environment variable"
+	vaultPKICommonNameUsage        = "common name of the certificate issued by Vault"
+	vaultPKIAltNamesUsage          = "comma separated list of the subject alternative names of the certificate issued by Vault"
+	vaultPKITTLUsage               = "requested lifetime of the certificate issued by Vault, default: the TTL of the role"
+	tlsReloadIntervalUsage         = "interval of checking the certificate files for changes and reloading them, negative to disable"
+	enableACMEUsage                = "obtain and renew the TLS certificates with ACME, e.g. from Let's Encrypt"
+	acmeDomainsUsage               = "comma separated list of the domains that the certificates are obtained for with ACME"
+	acmeHostsFromRoutesUsage       = "obtain the certificates with ACME for the hosts of the routes, too, matched by Host predicates with literal host names"
+	acmeEmailUsage                 = "contact email address of the ACME account"
+	acmeCacheUsage                 = "where the ACME account key and the certificates are stored: a directory, s3://bucket/prefix, or etcd:///prefix"
+	acmeDirectoryURLUsage          = "directory URL of the ACME server, default: Let's Encrypt"
+	disableHTTP2Usage              = "when TLS is enabled, offer only HTTP/1.1 to the clients, instead of negotiating HTTP/2"
+	tlsRedirectAddressUsage        = "when TLS is enabled, accept plaintext requests on this address, and redirect them to the TLS listener"
+	tlsMinVersionUsage             = "minimum TLS version accepted by the listener: 1.0, 1.1, 1.2 or 1.3"
+	tlsMaxVersionUsage             = "maximum TLS version accepted by the listener: 1.0, 1.1, 1.2 or 1.3"
+	tlsCipherSuitesUsage           = "comma separated list of the cipher suites accepted by the listener, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256; not applied to TLS 1.3"
+	tlsCurvesUsage                 = "comma separated list of the elliptic curves accepted by the listener: X25519, P256, P384, P521"
+	tlsClientAuthUsage             = "verify the client certificates on the TLS listener: none, optional or required"
+	tlsClientCAUsage               = "comma separated list of the PEM files of the CA certificates that the client certificates are verified with"
+	enableOCSPStaplingUsage        = "staple the OCSP responses of the TLS certificates to the handshakes, requires the issuer certificates in the certificate files"
+	ocspCheckIntervalUsage         = "interval of checking whether the stapled OCSP responses need to be refreshed"
+	upstreamTLSMinVersionUsage     = "minimum TLS version of the backend connections: 1.0, 1.1, 1.2 or 1.3"
+	upstreamTLSMaxVersionUsage     = "maximum TLS version of the backend connections: 1.0, 1.1, 1.2 or 1.3"
+	upstreamTLSCipherSuitesUsage   = "comma separated list of the cipher suites of the backend connections; not applied to TLS 1.3"
+	upstreamTLSCurvesUsage         = "comma separated list of the elliptic curves of the backend connections: X25519, P256, P384, P521"
+	fipsUsage                      = "restrict the TLS connections to the algorithms approved by FIPS 140-2, and reject the configuration that is not compliant"
+	spiffeUsage                    = "use the SVIDs received from the SPIFFE workload API as the client certificates of the backend connections, and verify the backends by their SPIFFE ID"
+	spiffeSocketUsage              = "address of the SPIFFE workload API, e.g. unix:///run/spire/sockets/agent.sock, default: the SPIFFE_ENDPOINT_SOCKET environment variable"
+	spiffeAllowedIDsUsage          = "comma separated list of the SPIFFE IDs or trust domains of the accepted backends, default: the trust domain of the proxy"
 	backendFlushIntervalUsage      = "flush interval for upgraded proxy connections"
 	experimentalUpgradeUsage       = "enable experimental feature to handle upgrade protocol requests"
 	versionUsage                   = "print Skipper version"
 	maxLoopbacksUsage              = "maximum number of loopbacks for an incoming request, set to -1 to disable loopbacks"
+	serverTimingUsage              = "set the Server-Timing header with the durations of the proxy phases for every response, not only for the routes with the serverTiming filter"
+	hstsMaxAgeUsage                = "when greater than 0, set the Strict-Transport-Security header with this max-age in the responses of the HTTPS requests; can be turned off for individual routes with the disableSecurityHeaders filter"
+	hstsIncludeSubdomainsUsage     = "add the includeSubDomains directive to the Strict-Transport-Security header"
+	hstsPreloadUsage               = "add the preload directive to the Strict-Transport-Security header"
+	removeFingerprintUsage         = "remove the Server, Via and X-Powered-By headers from all the responses"
+	stripResponseHeadersUsage      = "comma separated list of response headers removed from all the responses"
+	enableBanListUsage             = "temporarily ban the clients that repeatedly receive 401, 403 or 429 responses, see the banlist package"
+	banListThresholdUsage          = "number of violations within the window, that gets a client banned"
+	banListWindowUsage             = "time window of counting the violations of the clients"
+	banListDurationUsage           = "duration of the bans"
+	banListStatusesUsage           = "comma separated list of the status codes counted as violations, default: 401,403,429"
+	banListByTokenUsage            = "identify the clients by their Authorization header, too, besides their IP"
+	banListTrustForwardedUsage     = "take the IP of the clients from the X-Forwarded-For header, only when running behind a load balancer"
+	banListRedisUsage              = "share the bans through Redis, as redis://[:password@]host[:port][/db]"
+	wafRulesUsage                  = "comma separated list of web application firewall rule files, in the ModSecurity format, enables the waf() filter"
+	wafModeUsage                   = "default mode of the waf() filter: block or detect"
+	wafInspectResponseUsage        = "evaluate the waf rules of the response phases against the responses"
+	wafMaxBodySizeUsage            = "maximum size of the request and response bodies inspected by the waf rules. In block mode, the longer request bodies are rejected with 413"
+	wafPartialBodyInspectionUsage  = "inspect only the beginning of the request bodies longer than -waf-max-body-size, and skip inspecting the encoded request bodies, instead of rejecting them with 413 and 415 in block mode"
+	strictParsingUsage             = "reject the requests with ambiguous framing, invalid characters or oversized headers. On the TLS listener, it disables HTTP/2"
+	reusePortListenersUsage        = "when greater than 1, the proxy listens with this many sockets on the same address, using SO_REUSEPORT, each with its own accept loop. When negative, GOMAXPROCS is used. Linux only"
+	adjustMaxProcsUsage            = "lower GOMAXPROCS to the CPU quota of the cgroup of the container, unless the GOMAXPROCS environment variable is set"
+	maxHeaderBytesUsage            = "maximum size of the request line and the header fields of the incoming requests"
+	secretsUsage                   = "named keyrings for the filters, as name1=source1,name2=source2, where the sources are file:<path>, env:<variable> or vault:<path>[#<field>]"
+	secretsRefreshIntervalUsage    = "interval of reloading the secret keys from their sources"
+	geoipDBUsage                   = "comma separated list of GeoIP databases in the MaxMind DB format, enables the Country and ASN predicates and the geoip() filter"
+	geoipReloadIntervalUsage       = "interval of checking the GeoIP databases for changes"
+	geoipTrustForwardedUsage       = "take the IP of the clients for the GeoIP lookups and the IP reputation from the X-Forwarded-For header, only when running behind a load balancer"
+	ipReputationUsage              = "comma separated list of files or URLs of IP reputation feeds, the requests from the listed addresses and networks are rejected"
+	ipReputationRefreshUsage       = "interval of reloading the IP reputation feeds"
+	slowClientHeaderTimeoutUsage   = "maximum time of receiving the header of a request, counted from accepting the connection or from the first byte of the request, 0 means no limit"
+	slowClientMinBodyRateUsage     = "minimum transfer rate of the request bodies in bytes per second, 0 means no limit"
+	slowClientBodyTimeoutUsage     = "initial time allowed for receiving a request body, extended by one second for every -slow-client-min-body-rate bytes"
+	maxConnsPerIPUsage             = "maximum number of concurrent connections from the same IP address, 0 means no limit"
+	authCacheTTLUsage              = "time of caching the accepted credentials by the auth filters, 0 disables the caching"
+	authCacheNegativeTTLUsage      = "time of caching the rejected credentials by the auth filters, negative disables the negative caching"
+	authCacheMaxEntriesUsage       = "maximum number of the cached auth decisions"
+	errorReportingDSNUsage         = "Sentry DSN of the project that the panics, filter panics and bursts of 5xx responses are reported to"
+	errorReportingEnvUsage         = "environment reported with the error events"
+	errorReportingRateLimitUsage   = "maximum number of error events sent per minute"
+	errorReportingBurstUsage       = "number of 5xx responses of a route per minute that is reported as an error event"
+	eventWebhookUsage              = "URL that the internal events, e.g. the routing table updates, are posted to as JSON"
+	enableHealthEndpointsUsage     = "serve the liveness and the readiness of the proxy, including the connectivity of the data clients, on the metrics listener"
+	livenessPathUsage              = "path of the liveness endpoint, default: /alive"
+	readinessPathUsage             = "path of the readiness endpoint, default: /ready"
+	drainDelayUsage                = "when set, on SIGTERM, the proxy is reported as not ready, and keeps serving the requests for this duration before shutting down"
+	enableRouteAuditUsage          = "record the applied changes of the routing table, and serve them on the /audit path of the metrics listener"
+	routeAuditMaxEntriesUsage      = "number of the route audit entries kept in memory"
+	routeAuditFileUsage            = "file that the route audit entries are appended to"
+	profilingEndpointUsage         = "Pyroscope compatible ingest endpoint that the periodically captured CPU and heap profiles are pushed to"
+	profilingIntervalUsage         = "interval of capturing the CPU and heap profiles"
+	profilingLabelsUsage           = "labels pushed with the profiles, e.g. env=prod,zone=eu-1"
+	generateFlowIDUsage            = "generate a flow id for the incoming requests without a valid X-Flow-Id header, used as the correlation ID in the logs and traces"
+	tracerUsage                    = "tracer used to trace the proxied requests, possible values: noop, jaeger, otel, zipkin"
+	tracerServiceNameUsage         = "service name reported with the recorded spans"
+	tracerEndpointUsage            = "address where the recorded spans are sent to, e.g. the URL of the Jaeger collector"
+	tracerSampleRateUsage          = "fraction of the sampled traces, when the incoming request doesn't carry a sampling decision"
+	tracerSamplerUsage             = "sampling strategy of the tracer, possible values: parentbased, ratio"
+	tracerPropagationUsage         = "format of the span context in the HTTP headers, possible values: jaeger, w3c, b3, b3single; by default, the format of the tracer"
 )
 
 var (
@@ -106,6 +277,29 @@ var (
 	innkeeperUrl              string
 	sourcePollTimeout         int64
 	routesFile                string
+	routeSigningKeys          string
+	adminAddress              string
+	adminCertPathTLS          string
+	adminKeyPathTLS           string
+	adminTokens               string
+	routeHistorySize          int
+	routeHistoryDir           string
+	routingSnapshotFile       string
+	maintenanceRoutes         string
+	maintenanceRetryAfter     time.Duration
+	maintenanceDrainPeriod    time.Duration
+	namespaces                string
+	namespaceTokens           string
+	namespaceRateLimits       string
+	namespacePolicies         string
+	allowedFilters            string
+	disabledFilters           string
+	allowedPredicates         string
+	disabledPredicates        string
+	maxRoutes                 int
+	maxFiltersPerRoute        int
+	maxRegexpComplexity       int
+	maxRouteSize              int
 	oauthUrl                  string
 	oauthScope                string
 	oauthCredentialsDir       string
@@ -113,9 +307,22 @@ var (
 	innkeeperPreRouteFilters  string
 	innkeeperPostRouteFilters string
 	devMode                   bool
+	configFile                string
+	validate                  bool
 	metricsListener           string
 	metricsPrefix             string
 	enableProfile             bool
+	enablePrometheusMetrics   bool
+	metricsGraphiteAddr       string
+	metricsGraphitePrefix     string
+	metricsStatsdAddr         string
+	metricsStatsdPrefix       string
+	metricsReportInterval     time.Duration
+	metricsTimerSample        string
+	metricsTimerReservoirSize int
+	metricsHistogramPrecision int
+	metricsHistogramMaxValue  time.Duration
+	metricsResetToken         string
 	debugGcMetrics            bool
 	runtimeMetrics            bool
 	serveRouteMetrics         bool
@@ -126,13 +333,121 @@ var (
 	applicationLogPrefix      string
 	accessLog                 string
 	accessLogDisabled         bool
+	accessLogFormat           string
+	accessLogJSONFields       string
+	accessLogRequestHeaders   string
+	accessLogResponseHeaders  string
+	accessLogSampleRates      string
+	accessLogExcludeRoutes    string
+	accessLogExcludePaths     string
+	enableCapture             bool
+	captureBufferSize         int
+	captureMaxBodySize        int64
+	captureTrustedNetworks    string
+	captureToken              string
+	captureKeepCredentials    bool
+	enableTap                 bool
+	tapToken                  string
+	tapSampleRate             float64
+	tapHeaders                string
 	debugListener             string
 	certPathTLS               string
 	keyPathTLS                string
+	certDirTLS                string
+	tlsKubernetesSecrets      string
+	vaultAddress              string
+	vaultPKIPath              string
+	vaultPKICommonName        string
+	vaultPKIAltNames          string
+	vaultPKITTL               time.Duration
+	tlsReloadInterval         time.Duration
+	enableACME                bool
+	acmeDomains               string
+	acmeHostsFromRoutes       bool
+	acmeEmail                 string
+	acmeCache                 string
+	acmeDirectoryURL          string
+	disableHTTP2              bool
+	tlsRedirectAddress        string
+	tlsMinVersion             string
+	tlsMaxVersion             string
+	tlsCipherSuites           string
+	tlsCurves                 string
+	tlsClientAuth             string
+	tlsClientCA               string
+	enableOCSPStapling        bool
+	ocspCheckInterval         time.Duration
+	upstreamTLSMinVersion     string
+	upstreamTLSMaxVersion     string
+	upstreamTLSCipherSuites   string
+	upstreamTLSCurves         string
+	fips                      bool
+	enableSPIFFE              bool
+	spiffeSocket              string
+	spiffeAllowedIDs          string
 	backendFlushInterval      time.Duration
 	experimentalUpgrade       bool
 	printVersion              bool
 	maxLoopbacks              int
+	generateFlowID            bool
+	serverTiming              bool
+	hstsMaxAge                time.Duration
+	hstsIncludeSubdomains     bool
+	hstsPreload               bool
+	removeFingerprintHeaders  bool
+	stripResponseHeaders      string
+	enableBanList             bool
+	banListThreshold          int
+	banListWindow             time.Duration
+	banListDuration           time.Duration
+	banListStatuses           string
+	banListByToken            bool
+	banListTrustForwarded     bool
+	banListRedis              string
+	wafRules                  string
+	wafMode                   string
+	wafInspectResponse        bool
+	wafMaxBodySize            int64
+	wafPartialBodyInspection  bool
+	strictParsing             bool
+	reusePortListeners        int
+	adjustMaxProcs            bool
+	maxHeaderBytes            int
+	secretSources             string
+	secretsRefreshInterval    time.Duration
+	geoipDB                   string
+	geoipReloadInterval       time.Duration
+	geoipTrustForwarded       bool
+	ipReputation              string
+	ipReputationRefresh       time.Duration
+	slowClientHeaderTimeout   time.Duration
+	slowClientMinBodyRate     int
+	slowClientBodyTimeout     time.Duration
+	maxConnsPerIP             int
+	authCacheTTL              time.Duration
+	authCacheNegativeTTL      time.Duration
+	authCacheMaxEntries       int
+	errorReportingDSN         string
+	errorReportingEnv         string
+	errorReportingRateLimit   int
+	errorReportingBurst       int
+	eventWebhook              string
+	enableHealthEndpoints     bool
+	livenessPath              string
+	readinessPath             string
+	drainDelay                time.Duration
+	enableRouteAudit          bool
+	routeAuditMaxEntries      int
+	routeAuditFile            string
+	profilingEndpoint         string
+	profilingInterval         time.Duration
+	profilingLabels           string
+	tracer                    string
+	tracerServiceName         string
+	tracerEndpoint            string
+	tracerSampleRate          float64
+	tracerSampler             string
+	tracerPropagation         string
 )
 
 func init() {
@@ -152,6 +467,29 @@ func init() {
 	flag.StringVar(&innkeeperUrl, "innkeeper-url", "", innkeeperUrlUsage)
 	flag.Int64Var(&sourcePollTimeout, "source-poll-timeout", defaultSourcePollTimeout, sourcePollTimeoutUsage)
 	flag.StringVar(&routesFile, "routes-file", "", routesFileUsage)
+	flag.StringVar(&routeSigningKeys, "route-signing-keys", "", routeSigningKeysUsage)
+	flag.StringVar(&adminAddress, "admin-address", "", adminAddressUsage)
+	flag.StringVar(&adminCertPathTLS, "admin-tls-cert", "", adminCertPathTLSUsage)
+	flag.StringVar(&adminKeyPathTLS, "admin-tls-key", "", adminKeyPathTLSUsage)
+	flag.StringVar(&adminTokens, "admin-tokens", "", adminTokensUsage)
+	flag.IntVar(&routeHistorySize, "route-history-size", routing.DefaultHistorySize, routeHistorySizeUsage)
+	flag.StringVar(&routeHistoryDir, "route-history-dir", "", routeHistoryDirUsage)
+	flag.StringVar(&routingSnapshotFile, "routing-snapshot-file", "", routingSnapshotFileUsage)
+	flag.StringVar(&maintenanceRoutes, "maintenance-routes", "", maintenanceRoutesUsage)
+	flag.DurationVar(&maintenanceRetryAfter, "maintenance-retry-after", maintenance.DefaultRetryAfter, maintenanceRetryAfterUsage)
+	flag.DurationVar(&maintenanceDrainPeriod, "maintenance-drain-period", maintenance.DefaultDrainPeriod, maintenanceDrainPeriodUsage)
+	flag.StringVar(&namespaces, "namespaces", "", namespacesUsage)
+	flag.StringVar(&namespaceTokens, "namespace-tokens", "", namespaceTokensUsage)
+	flag.StringVar(&namespaceRateLimits, "namespace-rate-limits", "", namespaceRateLimitsUsage)
+	flag.StringVar(&namespacePolicies, "namespace-policies", "", namespacePoliciesUsage)
+	flag.StringVar(&allowedFilters, "allowed-filters", "", allowedFiltersUsage)
+	flag.StringVar(&disabledFilters, "disabled-filters", "", disabledFiltersUsage)
+	flag.StringVar(&allowedPredicates, "allowed-predicates", "", allowedPredicatesUsage)
+	flag.StringVar(&disabledPredicates, "disabled-predicates", "", disabledPredicatesUsage)
+	flag.IntVar(&maxRoutes, "max-routes", 0, maxRoutesUsage)
+	flag.IntVar(&maxFiltersPerRoute, "max-filters-per-route", 0, maxFiltersPerRouteUsage)
+	flag.IntVar(&maxRegexpComplexity, "max-regexp-complexity", 0, maxRegexpComplexityUsage)
+	flag.IntVar(&maxRouteSize, "max-route-size", 0, maxRouteSizeUsage)
 	flag.StringVar(&oauthUrl, "oauth-url", "", oauthUrlUsage)
 	flag.StringVar(&oauthScope, "oauth-scope", "", oauthScopeUsage)
 	flag.StringVar(&oauthCredentialsDir, "oauth-credentials-dir", "", oauthCredentialsDirUsage)
@@ -159,9 +497,22 @@ func init() {
 	flag.StringVar(&innkeeperPreRouteFilters, "innkeeper-pre-route-filters", "", innkeeperPreRouteFiltersUsage)
 	flag.StringVar(&innkeeperPostRouteFilters, "innkeeper-post-route-filters", "", innkeeperPostRouteFiltersUsage)
 	flag.BoolVar(&devMode, "dev-mode", false, devModeUsage)
+	flag.StringVar(&configFile, "config-file", "", configFileUsage)
+	flag.BoolVar(&validate, "validate", false, validateUsage)
 	flag.StringVar(&metricsListener, "metrics-listener", defaultMetricsListener, metricsListenerUsage)
 	flag.StringVar(&metricsPrefix, "metrics-prefix", defaultMetricsPrefix, metricsPrefixUsage)
 	flag.BoolVar(&enableProfile, "enable-profile", false, enableProfileUsage)
+	flag.BoolVar(&enablePrometheusMetrics, "enable-prometheus-metrics", false, enablePrometheusMetricsUsage)
+	flag.StringVar(&metricsGraphiteAddr, "metrics-graphite-addr", "", metricsGraphiteAddrUsage)
+	flag.StringVar(&metricsGraphitePrefix, "metrics-graphite-prefix", "", metricsGraphitePrefixUsage)
+	flag.StringVar(&metricsStatsdAddr, "metrics-statsd-addr", "", metricsStatsdAddrUsage)
+	flag.StringVar(&metricsStatsdPrefix, "metrics-statsd-prefix", "", metricsStatsdPrefixUsage)
+	flag.DurationVar(&metricsReportInterval, "metrics-report-interval", metrics.DefaultReportInterval, metricsReportIntervalUsage)
+	flag.StringVar(&metricsTimerSample, "metrics-timer-sample", string(metrics.UniformSample), metricsTimerSampleUsage)
+	flag.IntVar(&metricsTimerReservoirSize, "metrics-timer-reservoir-size", 1024, metricsTimerReservoirSizeUsage)
+	flag.IntVar(&metricsHistogramPrecision, "metrics-histogram-precision", 2, metricsHistogramPrecisionUsage)
+	flag.DurationVar(&metricsHistogramMaxValue, "metrics-histogram-max-value", time.Minute, metricsHistogramMaxValueUsage)
+	flag.StringVar(&metricsResetToken, "metrics-reset-token", "", metricsResetTokenUsage)
 	flag.BoolVar(&debugGcMetrics, "debug-gc-metrics", false, debugGcMetricsUsage)
 	flag.BoolVar(&runtimeMetrics, "runtime-metrics", defaultRuntimeMetrics, runtimeMetricsUsage)
 	flag.BoolVar(&serveRouteMetrics, "serve-route-metrics", false, serveRouteMetricsUsage)
@@ -172,13 +523,121 @@ func init() {
 	flag.StringVar(&applicationLogPrefix, "application-log-prefix", defaultApplicationLogPrefix, applicationLogPrefixUsage)
 	flag.StringVar(&accessLog, "access-log", "", accessLogUsage)
 	flag.BoolVar(&accessLogDisabled, "access-log-disabled", false, accessLogDisabledUsage)
+	flag.StringVar(&accessLogFormat, "access-log-format", defaultAccessLogFormat, accessLogFormatUsage)
+	flag.StringVar(&accessLogJSONFields, "access-log-json-fields", "", accessLogJSONFieldsUsage)
+	flag.StringVar(&accessLogRequestHeaders, "access-log-request-headers", "", accessLogRequestHeadersUsage)
+	flag.StringVar(&accessLogResponseHeaders, "access-log-response-headers", "", accessLogResponseHeadersUsage)
+	flag.StringVar(&accessLogSampleRates, "access-log-sample-rates", "", accessLogSampleRatesUsage)
+	flag.StringVar(&accessLogExcludeRoutes, "access-log-exclude-routes", "", accessLogExcludeRoutesUsage)
+	flag.StringVar(&accessLogExcludePaths, "access-log-exclude-paths", "", accessLogExcludePathsUsage)
+	flag.BoolVar(&enableCapture, "enable-capture", false, enableCaptureUsage)
+	flag.IntVar(&captureBufferSize, "capture-buffer-size", capture.DefaultBufferSize, captureBufferSizeUsage)
+	flag.Int64Var(&captureMaxBodySize, "capture-max-body-size", capture.DefaultMaxBodySize, captureMaxBodySizeUsage)
+	flag.StringVar(&captureTrustedNetworks, "capture-trusted-networks", "", captureTrustedNetworksUsage)
+	flag.StringVar(&captureToken, "capture-token", "", captureTokenUsage)
+	flag.BoolVar(&captureKeepCredentials, "capture-keep-credentials", false, captureKeepCredentialsUsage)
+	flag.BoolVar(&enableTap, "enable-tap", false, enableTapUsage)
+	flag.StringVar(&tapToken, "tap-token", "", tapTokenUsage)
+	flag.Float64Var(&tapSampleRate, "tap-sample-rate", tap.DefaultSampleRate, tapSampleRateUsage)
+	flag.StringVar(&tapHeaders, "tap-headers", "", tapHeadersUsage)
 	flag.StringVar(&debugListener, "debug-listener", "", debugEndpointUsage)
 	flag.StringVar(&certPathTLS, "tls-cert", "", certPathTLSUsage)
 	flag.StringVar(&keyPathTLS, "tls-key", "", keyPathTLSUsage)
+	flag.StringVar(&certDirTLS, "tls-cert-dir", "", certDirTLSUsage)
+	flag.StringVar(&tlsKubernetesSecrets, "tls-kubernetes-secrets", "", tlsKubernetesSecretsUsage)
+	flag.StringVar(&vaultAddress, "vault-address", "", vaultAddressUsage)
+	flag.StringVar(&vaultPKIPath, "vault-pki-path", "", vaultPKIPathUsage)
+	flag.StringVar(&vaultPKICommonName, "vault-pki-common-name", "", vaultPKICommonNameUsage)
+	flag.StringVar(&vaultPKIAltNames, "vault-pki-alt-names", "", vaultPKIAltNamesUsage)
+	flag.DurationVar(&vaultPKITTL, "vault-pki-ttl", 0, vaultPKITTLUsage)
+	flag.DurationVar(&tlsReloadInterval, "tls-reload-interval", tlsconfig.DefaultReloadInterval, tlsReloadIntervalUsage)
+	flag.BoolVar(&enableACME, "enable-acme", false, enableACMEUsage)
+	flag.StringVar(&acmeDomains, "acme-domains", "", acmeDomainsUsage)
+	flag.BoolVar(&acmeHostsFromRoutes, "acme-hosts-from-routes", false, acmeHostsFromRoutesUsage)
+	flag.StringVar(&acmeEmail, "acme-email", "", acmeEmailUsage)
+	flag.StringVar(&acmeCache, "acme-cache", defaultACMECache, acmeCacheUsage)
+	flag.StringVar(&acmeDirectoryURL, "acme-directory-url", "", acmeDirectoryURLUsage)
+	flag.BoolVar(&disableHTTP2, "disable-http2", false, disableHTTP2Usage)
+	flag.StringVar(&tlsRedirectAddress, "tls-redirect-address", "", tlsRedirectAddressUsage)
+	flag.StringVar(&tlsMinVersion, "tls-min-version", "", tlsMinVersionUsage)
+	flag.StringVar(&tlsMaxVersion, "tls-max-version", "", tlsMaxVersionUsage)
+	flag.StringVar(&tlsCipherSuites, "tls-cipher-suites", "", tlsCipherSuitesUsage)
+	flag.StringVar(&tlsCurves, "tls-curves", "", tlsCurvesUsage)
+	flag.StringVar(&tlsClientAuth, "tls-client-auth", "", tlsClientAuthUsage)
+	flag.StringVar(&tlsClientCA, "tls-client-ca", "", tlsClientCAUsage)
+	flag.BoolVar(&enableOCSPStapling, "enable-ocsp-stapling", false, enableOCSPStaplingUsage)
+	flag.DurationVar(&ocspCheckInterval, "ocsp-check-interval", tlsconfig.DefaultOCSPCheckInterval, ocspCheckIntervalUsage)
+	flag.StringVar(&upstreamTLSMinVersion, "upstream-tls-min-version", "", upstreamTLSMinVersionUsage)
+	flag.StringVar(&upstreamTLSMaxVersion, "upstream-tls-max-version", "", upstreamTLSMaxVersionUsage)
+	flag.StringVar(&upstreamTLSCipherSuites, "upstream-tls-cipher-suites", "", upstreamTLSCipherSuitesUsage)
+	flag.StringVar(&upstreamTLSCurves, "upstream-tls-curves", "", upstreamTLSCurvesUsage)
+	flag.BoolVar(&fips, "fips", false, fipsUsage)
+	flag.BoolVar(&enableSPIFFE, "spiffe", false, spiffeUsage)
+	flag.StringVar(&spiffeSocket, "spiffe-socket", "", spiffeSocketUsage)
+	flag.StringVar(&spiffeAllowedIDs, "spiffe-allowed-ids", "", spiffeAllowedIDsUsage)
 	flag.DurationVar(&backendFlushInterval, "backend-flush-interval", defaultBackendFlushInterval, backendFlushIntervalUsage)
 	flag.BoolVar(&experimentalUpgrade, "experimental-upgrade", defaultExperimentalUpgrade, experimentalUpgradeUsage)
 	flag.BoolVar(&printVersion, "version", false, versionUsage)
 	flag.IntVar(&maxLoopbacks, "max-loopbacks", proxy.DefaultMaxLoopbacks, maxLoopbacksUsage)
+	flag.BoolVar(&generateFlowID, "generate-flow-id", false, generateFlowIDUsage)
+	flag.BoolVar(&serverTiming, "server-timing", false, serverTimingUsage)
+	flag.DurationVar(&hstsMaxAge, "hsts-max-age", 0, hstsMaxAgeUsage)
+	flag.BoolVar(&hstsIncludeSubdomains, "hsts-include-subdomains", false, hstsIncludeSubdomainsUsage)
+	flag.BoolVar(&hstsPreload, "hsts-preload", false, hstsPreloadUsage)
+	flag.BoolVar(&removeFingerprintHeaders, "remove-fingerprint-headers", false, removeFingerprintUsage)
+	flag.StringVar(&stripResponseHeaders, "strip-response-headers", "", stripResponseHeadersUsage)
+	flag.BoolVar(&enableBanList, "enable-ban-list", false, enableBanListUsage)
+	flag.IntVar(&banListThreshold, "ban-list-threshold", banlist.DefaultThreshold, banListThresholdUsage)
+	flag.DurationVar(&banListWindow, "ban-list-window", banlist.DefaultWindow, banListWindowUsage)
+	flag.DurationVar(&banListDuration, "ban-list-duration", banlist.DefaultDuration, banListDurationUsage)
+	flag.StringVar(&banListStatuses, "ban-list-statuses", "", banListStatusesUsage)
+	flag.BoolVar(&banListByToken, "ban-list-by-token", false, banListByTokenUsage)
+	flag.BoolVar(&banListTrustForwarded, "ban-list-trust-forwarded", false, banListTrustForwardedUsage)
+	flag.StringVar(&banListRedis, "ban-list-redis", "", banListRedisUsage)
+	flag.StringVar(&wafRules, "waf-rules", "", wafRulesUsage)
+	flag.StringVar(&wafMode, "waf-mode", waf.ModeBlock, wafModeUsage)
+	flag.BoolVar(&wafInspectResponse, "waf-inspect-response", false, wafInspectResponseUsage)
+	flag.Int64Var(&wafMaxBodySize, "waf-max-body-size", waf.DefaultMaxBodySize, wafMaxBodySizeUsage)
+	flag.BoolVar(&wafPartialBodyInspection, "waf-partial-body-inspection", false, wafPartialBodyInspectionUsage)
+	flag.BoolVar(&strictParsing, "strict-parsing", false, strictParsingUsage)
+	flag.IntVar(&reusePortListeners, "reuse-port-listeners", 0, reusePortListenersUsage)
+	flag.BoolVar(&adjustMaxProcs, "adjust-maxprocs", false, adjustMaxProcsUsage)
+	flag.IntVar(&maxHeaderBytes, "max-header-bytes", strictparsing.DefaultMaxHeaderBytes, maxHeaderBytesUsage)
+	flag.StringVar(&secretSources, "secrets", "", secretsUsage)
+	flag.DurationVar(&secretsRefreshInterval, "secrets-refresh-interval", secrets.DefaultRefreshInterval, secretsRefreshIntervalUsage)
+	flag.StringVar(&geoipDB, "geoip-db", "", geoipDBUsage)
+	flag.DurationVar(&geoipReloadInterval, "geoip-reload-interval", geoip.DefaultReloadInterval, geoipReloadIntervalUsage)
+	flag.BoolVar(&geoipTrustForwarded, "geoip-trust-forwarded", false, geoipTrustForwardedUsage)
+	flag.StringVar(&ipReputation, "ip-reputation", "", ipReputationUsage)
+	flag.DurationVar(&ipReputationRefresh, "ip-reputation-refresh-interval", geoip.DefaultRefreshInterval, ipReputationRefreshUsage)
+	flag.DurationVar(&slowClientHeaderTimeout, "slow-client-header-timeout", 0, slowClientHeaderTimeoutUsage)
+	flag.IntVar(&slowClientMinBodyRate, "slow-client-min-body-rate", 0, slowClientMinBodyRateUsage)
+	flag.DurationVar(&slowClientBodyTimeout, "slow-client-body-timeout", slowclient.DefaultBodyTimeout, slowClientBodyTimeoutUsage)
+	flag.IntVar(&maxConnsPerIP, "max-conns-per-ip", 0, maxConnsPerIPUsage)
+	flag.DurationVar(&authCacheTTL, "auth-cache-ttl", 0, authCacheTTLUsage)
+	flag.DurationVar(&authCacheNegativeTTL, "auth-cache-negative-ttl", auth.DefaultCacheNegativeTTL, authCacheNegativeTTLUsage)
+	flag.IntVar(&authCacheMaxEntries, "auth-cache-max-entries", auth.DefaultCacheMaxEntries, authCacheMaxEntriesUsage)
+	flag.StringVar(&errorReportingDSN, "error-reporting-dsn", "", errorReportingDSNUsage)
+	flag.StringVar(&errorReportingEnv, "error-reporting-environment", "", errorReportingEnvUsage)
+	flag.IntVar(&errorReportingRateLimit, "error-reporting-rate-limit", errorreport.DefaultMaxEventsPerMinute, errorReportingRateLimitUsage)
+	flag.IntVar(&errorReportingBurst, "error-reporting-burst-threshold", errorreport.DefaultBurstThreshold, errorReportingBurstUsage)
+	flag.StringVar(&eventWebhook, "event-webhook", "", eventWebhookUsage)
+	flag.BoolVar(&enableHealthEndpoints, "enable-health-endpoints", false, enableHealthEndpointsUsage)
+	flag.StringVar(&livenessPath, "liveness-path", "", livenessPathUsage)
+	flag.StringVar(&readinessPath, "readiness-path", "", readinessPathUsage)
+	flag.DurationVar(&drainDelay, "drain-delay", 0, drainDelayUsage)
+	flag.BoolVar(&enableRouteAudit, "enable-route-audit", false, enableRouteAuditUsage)
+	flag.IntVar(&routeAuditMaxEntries, "route-audit-max-entries", audit.DefaultMaxEntries, routeAuditMaxEntriesUsage)
+	flag.StringVar(&routeAuditFile, "route-audit-file", "", routeAuditFileUsage)
+	flag.StringVar(&profilingEndpoint, "profiling-endpoint", "", profilingEndpointUsage)
+	flag.DurationVar(&profilingInterval, "profiling-interval", profiling.DefaultInterval, profilingIntervalUsage)
+	flag.StringVar(&profilingLabels, "profiling-labels", "", profilingLabelsUsage)
+	flag.StringVar(&tracer, "tracer", defaultTracer, tracerUsage)
+	flag.StringVar(&tracerServiceName, "tracer-service-name", "", tracerServiceNameUsage)
+	flag.StringVar(&tracerEndpoint, "tracer-endpoint", "", tracerEndpointUsage)
+	flag.Float64Var(&tracerSampleRate, "tracer-sample-rate", defaultTracerSampleRate, tracerSampleRateUsage)
+	flag.StringVar(&tracerSampler, "tracer-sampler", defaultTracerSampler, tracerSamplerUsage)
+	flag.StringVar(&tracerPropagation, "tracer-propagation", "", tracerPropagationUsage)
 	flag.Parse()
 }
 
@@ -196,6 +655,104 @@ func parseDurationFlag(ds string) (time.Duration, error) {
 	}
 }
 
+func splitList(l string) []string {
+	if l == "" {
+		return nil
+	}
+
+	return strings.Split(l, ",")
+}
+
+// parses the sample rates in the format of 2xx=0.01,5xx=1
+func parseSampleRates(s string) (map[int]float64, error) {
+	rates := make(map[int]float64)
+	for _, r := range splitList(s) {
+		kv := strings.Split(r, "=")
+		if len(kv) != 2 || len(kv[0]) != 3 || !strings.HasSuffix(kv[0], "xx") {
+			return nil, fmt.Errorf("invalid sample rate: %s", r)
+		}
+
+		class, err := strconv.Atoi(kv[0][:1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid status class: %s", kv[0])
+		}
+
+		rate, err := strconv.ParseFloat(kv[1], 64)
+		if err != nil {
+			return nil, err
+		}
+
+		rates[class] = rate
+	}
+
+	return rates, nil
+}
+
+// parses a comma separated list of integers
+func parseIntList(s string) ([]int, error) {
+	var l []int
+	for _, si := range splitList(s) {
+		i, err := strconv.Atoi(si)
+		if err != nil {
+			return nil, err
+		}
+
+		l = append(l, i)
+	}
+
+	return l, nil
+}
+
+// parses the labels in the format of key1=value1,key2=value2
+func parseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, l := range splitList(s) {
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid label: %s", l)
+		}
+
+		labels[kv[0]] = kv[1]
+	}
+
+	return labels, nil
+}
+
+// parses the secret sources in the format of name1=source1,name2=source2
+func parseSecrets(s string) (map[string]string, error) {
+	sources := make(map[string]string)
+	for _, si := range splitList(s) {
+		ns := strings.SplitN(si, "=", 2)
+		if len(ns) != 2 || ns[0] == "" || ns[1] == "" {
+			return nil, fmt.Errorf("invalid secret source: %s", si)
+		}
+
+		sources[ns[0]] = ns[1]
+	}
+
+	return sources, nil
+}
+
+// parses the rate limits in the format of name1=limit1,name2=limit2
+func parseRateLimits(s string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, l := range splitList(s) {
+		kv := strings.Split(l, "=")
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid rate limit: %s", l)
+		}
+
+		limit, err := strconv.Atoi(kv[1])
+		if err != nil {
+			return nil, err
+		}
+
+		limits[kv[0]] = limit
+	}
+
+	return limits, nil
+}
+
 func main() {
 	if printVersion {
 		fmt.Printf(
@@ -206,16 +763,29 @@ func main() {
 		return
 	}
 
+	if flag.NArg() > 0 && flag.Arg(0) == "bench" {
+		os.Exit(runBench(flag.Args()[1:]))
+	}
+
+	if configFile != "" {
+		values, err := config.Load(configFile)
+		if err == nil {
+			err = values.Apply(flag.CommandLine)
+		}
+
+		if err != nil {
+			log.Error(err)
+			os.Exit(2)
+		}
+	}
+
 	if logLevel, err := log.ParseLevel(applicationLogLevel); err != nil {
 		log.Fatal(err)
 	} else {
 		log.SetLevel(logLevel)
 	}
 
-	var eus []string
-	if len(etcdUrls) > 0 {
-		eus = strings.Split(etcdUrls, ",")
-	}
+	eus := splitList(etcdUrls)
 
 	clsic, err := parseDurationFlag(closeIdleConnsPeriod)
 	if err != nil {
@@ -223,6 +793,48 @@ func main() {
 		os.Exit(2)
 	}
 
+	sampleRates, err := parseSampleRates(accessLogSampleRates)
+	if err != nil {
+		log.Error(err)
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	labels, err := parseLabels(profilingLabels)
+	if err != nil {
+		log.Error(err)
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	statuses, err := parseIntList(banListStatuses)
+	if err != nil {
+		log.Error(err)
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	secretSourceMap, err := parseSecrets(secretSources)
+	if err != nil {
+		log.Error(err)
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	namespaceTokenMap, err := parseSecrets(namespaceTokens)
+	if err != nil {
+		log.Error(err)
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	namespaceRateLimitMap, err := parseRateLimits(namespaceRateLimits)
+	if err != nil {
+		log.Error(err)
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
 	options := skipper.Options{
 		Address:                   address,
 		EtcdUrls:                  eus,
@@ -236,6 +848,29 @@ func main() {
 		InnkeeperUrl:              innkeeperUrl,
 		SourcePollTimeout:         time.Duration(sourcePollTimeout) * time.Millisecond,
 		RoutesFile:                routesFile,
+		RouteSigningKeys:          splitList(routeSigningKeys),
+		AdminAddress:              adminAddress,
+		AdminCertPathTLS:          adminCertPathTLS,
+		AdminKeyPathTLS:           adminKeyPathTLS,
+		AdminTokens:               adminTokens,
+		RouteHistorySize:          routeHistorySize,
+		RouteHistoryDir:           routeHistoryDir,
+		RoutingSnapshotFile:       routingSnapshotFile,
+		MaintenanceRoutes:         splitList(maintenanceRoutes),
+		MaintenanceRetryAfter:     maintenanceRetryAfter,
+		MaintenanceDrainPeriod:    maintenanceDrainPeriod,
+		Namespaces:                splitList(namespaces),
+		NamespaceTokens:           namespaceTokenMap,
+		NamespaceRateLimits:       namespaceRateLimitMap,
+		NamespacePolicyFile:       namespacePolicies,
+		AllowedFilters:            splitList(allowedFilters),
+		DisabledFilters:           splitList(disabledFilters),
+		AllowedPredicates:         splitList(allowedPredicates),
+		DisabledPredicates:        splitList(disabledPredicates),
+		MaxRoutes:                 maxRoutes,
+		MaxFiltersPerRoute:        maxFiltersPerRoute,
+		MaxRegexpComplexity:       maxRegexpComplexity,
+		MaxRouteSize:              maxRouteSize,
 		IdleConnectionsPerHost:    idleConnsPerHost,
 		CloseIdleConnsPeriod:      time.Duration(clsic) * time.Second,
 		IgnoreTrailingSlash:       false,
@@ -249,6 +884,17 @@ func main() {
 		MetricsListener:           metricsListener,
 		MetricsPrefix:             metricsPrefix,
 		EnableProfile:             enableProfile,
+		EnablePrometheusMetrics:   enablePrometheusMetrics,
+		MetricsGraphiteAddr:       metricsGraphiteAddr,
+		MetricsGraphitePrefix:     metricsGraphitePrefix,
+		MetricsStatsdAddr:         metricsStatsdAddr,
+		MetricsStatsdPrefix:       metricsStatsdPrefix,
+		MetricsReportInterval:     metricsReportInterval,
+		MetricsTimerSample:        metricsTimerSample,
+		MetricsTimerReservoirSize: metricsTimerReservoirSize,
+		MetricsHistogramPrecision: metricsHistogramPrecision,
+		MetricsHistogramMaxValue:  metricsHistogramMaxValue,
+		MetricsResetToken:         metricsResetToken,
 		EnableDebugGcMetrics:      debugGcMetrics,
 		EnableRuntimeMetrics:      runtimeMetrics,
 		EnableServeRouteMetrics:   serveRouteMetrics,
@@ -258,12 +904,121 @@ func main() {
 		ApplicationLogPrefix:      applicationLogPrefix,
 		AccessLogOutput:           accessLog,
 		AccessLogDisabled:         accessLogDisabled,
+		AccessLogFormat:           accessLogFormat,
+		AccessLogJSONFields:       splitList(accessLogJSONFields),
+		AccessLogRequestHeaders:   splitList(accessLogRequestHeaders),
+		AccessLogResponseHeaders:  splitList(accessLogResponseHeaders),
+		AccessLogSampleRates:      sampleRates,
+		AccessLogExcludeRoutes:    splitList(accessLogExcludeRoutes),
+		AccessLogExcludePaths:     splitList(accessLogExcludePaths),
+		EnableCapture:             enableCapture,
+		CaptureBufferSize:         captureBufferSize,
+		CaptureMaxBodySize:        captureMaxBodySize,
+		CaptureTrustedNetworks:    splitList(captureTrustedNetworks),
+		CaptureToken:              captureToken,
+		CaptureKeepCredentials:    captureKeepCredentials,
+		EnableTap:                 enableTap,
+		TapToken:                  tapToken,
+		TapSampleRate:             tapSampleRate,
+		TapHeaders:                splitList(tapHeaders),
 		DebugListener:             debugListener,
 		CertPathTLS:               certPathTLS,
 		KeyPathTLS:                keyPathTLS,
+		CertDirTLS:                certDirTLS,
+		TLSKubernetesSecrets:      splitList(tlsKubernetesSecrets),
+		VaultAddress:              vaultAddress,
+		VaultPKIPath:              vaultPKIPath,
+		VaultPKICommonName:        vaultPKICommonName,
+		VaultPKIAltNames:          splitList(vaultPKIAltNames),
+		VaultPKITTL:               vaultPKITTL,
+		TLSReloadInterval:         tlsReloadInterval,
+		EnableACME:                enableACME,
+		ACMEDomains:               splitList(acmeDomains),
+		ACMEHostsFromRoutes:       acmeHostsFromRoutes,
+		ACMEEmail:                 acmeEmail,
+		ACMECache:                 acmeCache,
+		ACMEDirectoryURL:          acmeDirectoryURL,
+		DisableHTTP2:              disableHTTP2,
+		TLSRedirectAddress:        tlsRedirectAddress,
+		TLSMinVersion:             tlsMinVersion,
+		TLSMaxVersion:             tlsMaxVersion,
+		TLSCipherSuites:           splitList(tlsCipherSuites),
+		TLSCurves:                 splitList(tlsCurves),
+		TLSClientAuth:             tlsClientAuth,
+		TLSClientCAFiles:          splitList(tlsClientCA),
+		EnableOCSPStapling:        enableOCSPStapling,
+		OCSPCheckInterval:         ocspCheckInterval,
+		UpstreamTLSMinVersion:     upstreamTLSMinVersion,
+		UpstreamTLSMaxVersion:     upstreamTLSMaxVersion,
+		UpstreamTLSCipherSuites:   splitList(upstreamTLSCipherSuites),
+		UpstreamTLSCurves:         splitList(upstreamTLSCurves),
+		FIPS:                      fips,
+		EnableSPIFFE:              enableSPIFFE,
+		SPIFFESocket:              spiffeSocket,
+		SPIFFEAllowedIDs:          splitList(spiffeAllowedIDs),
 		BackendFlushInterval:      backendFlushInterval,
 		ExperimentalUpgrade:       experimentalUpgrade,
 		MaxLoopbacks:              maxLoopbacks,
+		GenerateFlowID:            generateFlowID,
+		ServerTiming:              serverTiming,
+		HSTSMaxAge:                hstsMaxAge,
+		HSTSIncludeSubdomains:     hstsIncludeSubdomains,
+		HSTSPreload:               hstsPreload,
+		RemoveFingerprintHeaders:  removeFingerprintHeaders,
+		StripResponseHeaders:      splitList(stripResponseHeaders),
+		EnableBanList:             enableBanList,
+		BanListThreshold:          banListThreshold,
+		BanListWindow:             banListWindow,
+		BanListDuration:           banListDuration,
+		BanListStatuses:           statuses,
+		BanListByToken:            banListByToken,
+		BanListTrustForwarded:     banListTrustForwarded,
+		BanListRedisURL:           banListRedis,
+		WAFRules:                  splitList(wafRules),
+		WAFMode:                   wafMode,
+		WAFInspectResponse:        wafInspectResponse,
+		WAFMaxBodySize:            wafMaxBodySize,
+		WAFPartialBodyInspection:  wafPartialBodyInspection,
+		StrictParsing:             strictParsing,
+		ReusePortListeners:        reusePortListeners,
+		AdjustMaxProcs:            adjustMaxProcs,
+		MaxHeaderBytes:            maxHeaderBytes,
+		Secrets:                   secretSourceMap,
+		SecretsRefreshInterval:    secretsRefreshInterval,
+		GeoIPDatabases:            splitList(geoipDB),
+		GeoIPReloadInterval:       geoipReloadInterval,
+		GeoIPTrustForwarded:       geoipTrustForwarded,
+		IPReputationFeeds:         splitList(ipReputation),
+		IPReputationRefresh:       ipReputationRefresh,
+		SlowClientHeaderTimeout:   slowClientHeaderTimeout,
+		SlowClientMinBodyRate:     slowClientMinBodyRate,
+		SlowClientBodyTimeout:     slowClientBodyTimeout,
+		MaxConnsPerIP:             maxConnsPerIP,
+		AuthCacheTTL:              authCacheTTL,
+		AuthCacheNegativeTTL:      authCacheNegativeTTL,
+		AuthCacheMaxEntries:       authCacheMaxEntries,
+		ErrorReportingDSN:         errorReportingDSN,
+		ErrorReportingEnvironment: errorReportingEnv,
+		ErrorReportingRateLimit:   errorReportingRateLimit,
+		ErrorBurstThreshold:       errorReportingBurst,
+		EventWebhookURL:           eventWebhook,
+		EnableHealthEndpoints:     enableHealthEndpoints,
+		LivenessPath:              livenessPath,
+		ReadinessPath:             readinessPath,
+		DrainDelay:                drainDelay,
+		EffectiveConfig:           config.Effective(flag.CommandLine),
+		EnableRouteAudit:          enableRouteAudit,
+		RouteAuditMaxEntries:      routeAuditMaxEntries,
+		RouteAuditFile:            routeAuditFile,
+		ProfilingEndpoint:         profilingEndpoint,
+		ProfilingInterval:         profilingInterval,
+		ProfilingLabels:           labels,
+		Tracer:                    tracer,
+		TracerServiceName:         tracerServiceName,
+		TracerEndpoint:            tracerEndpoint,
+		TracerSampleRate:          tracerSampleRate,
+		TracerSampler:             tracerSampler,
+		TracerPropagation:         tracerPropagation,
 	}
 
 	if insecure {
@@ -274,5 +1029,15 @@ func main() {
 		options.ProxyFlags |= proxy.PreserveHost
 	}
 
-	log.Fatal(skipper.Run(options))
+	if validate {
+		if err := skipper.Validate(options, os.Stdout); err != nil {
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if err := skipper.Run(context.Background(), options); err != nil {
+		log.Fatal(err)
+	}
 }
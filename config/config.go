@@ -0,0 +1,300 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Values contains the options loaded from a configuration file, in the
+// structure of the file, with the environment variables already
+// interpolated.
+type Values map[string]interface{}
+
+var (
+	errUnsupportedFormat = errors.New("config: unsupported file format, expected .yaml, .yml or .toml")
+	errInvalidReference  = errors.New("config: invalid environment variable reference")
+)
+
+// Load reads a configuration file. The format is decided by the file
+// extension: .yaml or .yml for YAML and .toml for TOML.
+func Load(path string) (Values, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParseYAML(b)
+	case ".toml":
+		return ParseTOML(b)
+	default:
+		return nil, errUnsupportedFormat
+	}
+}
+
+// ParseYAML parses a YAML configuration document.
+func ParseYAML(b []byte) (Values, error) {
+	var v map[string]interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("config: %v", err)
+	}
+
+	return interpolateMap(v)
+}
+
+// ParseTOML parses a TOML configuration document.
+func ParseTOML(b []byte) (Values, error) {
+	v, err := parseTOML(string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	return interpolateMap(v)
+}
+
+func interpolateMap(m map[string]interface{}) (Values, error) {
+	v, err := interpolate(m)
+	if err != nil {
+		return nil, err
+	}
+
+	if v == nil {
+		return Values{}, nil
+	}
+
+	return Values(v.(map[string]interface{})), nil
+}
+
+// replaces the environment variable references in the string values
+func interpolate(v interface{}) (interface{}, error) {
+	switch vt := v.(type) {
+	case string:
+		return interpolateString(vt)
+	case map[string]interface{}:
+		if vt == nil {
+			return nil, nil
+		}
+
+		for k, vi := range vt {
+			var err error
+			if vt[k], err = interpolate(vi); err != nil {
+				return nil, err
+			}
+		}
+
+		return vt, nil
+	case []interface{}:
+		for i, vi := range vt {
+			var err error
+			if vt[i], err = interpolate(vi); err != nil {
+				return nil, err
+			}
+		}
+
+		return vt, nil
+	default:
+		return v, nil
+	}
+}
+
+// replaces ${NAME} and ${NAME:-default} with the value of the environment
+// variable, and $$ with $. It is an error to reference an undefined
+// variable without a default.
+func interpolateString(s string) (string, error) {
+	var b []byte
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 || i == len(s)-1 {
+			return string(append(b, s...)), nil
+		}
+
+		b = append(b, s[:i]...)
+		s = s[i+1:]
+		switch s[0] {
+		case '$':
+			b = append(b, '$')
+			s = s[1:]
+			continue
+		case '{':
+		default:
+			b = append(b, '$')
+			continue
+		}
+
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return "", errInvalidReference
+		}
+
+		ref := s[1:end]
+		s = s[end+1:]
+
+		name, def, hasDefault := ref, "", false
+		if i := strings.Index(ref, ":-"); i >= 0 {
+			name, def, hasDefault = ref[:i], ref[i+2:], true
+		}
+
+		if name == "" {
+			return "", errInvalidReference
+		}
+
+		value, ok := os.LookupEnv(name)
+		switch {
+		case ok && (value != "" || !hasDefault):
+			b = append(b, value...)
+		case hasDefault:
+			b = append(b, def...)
+		default:
+			return "", fmt.Errorf("config: environment variable not set: %s", name)
+		}
+	}
+}
+
+func formatScalar(v interface{}) (string, error) {
+	switch vt := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return vt, nil
+	case bool:
+		return strconv.FormatBool(vt), nil
+	case int:
+		return strconv.Itoa(vt), nil
+	case int64:
+		return strconv.FormatInt(vt, 10), nil
+	case uint64:
+		return strconv.FormatUint(vt, 10), nil
+	case float64:
+		return strconv.FormatFloat(vt, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported value: %v", v)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// formats the value of a single flag: lists are joined with commas, and
+// maps are formatted as key=value pairs, joined with commas
+func formatValue(v interface{}) (string, error) {
+	switch vt := v.(type) {
+	case []interface{}:
+		var s []string
+		for _, vi := range vt {
+			si, err := formatValue(vi)
+			if err != nil {
+				return "", err
+			}
+
+			s = append(s, si)
+		}
+
+		return strings.Join(s, ","), nil
+	case map[string]interface{}:
+		var s []string
+		for _, k := range sortedKeys(vt) {
+			si, err := formatScalar(vt[k])
+			if err != nil {
+				return "", err
+			}
+
+			s = append(s, k+"="+si)
+		}
+
+		return strings.Join(s, ","), nil
+	default:
+		return formatScalar(v)
+	}
+}
+
+func flagName(prefix, key string) string {
+	key = strings.Replace(key, "_", "-", -1)
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + "-" + key
+}
+
+// flattens the nested sections into flag names, joining the keys with
+// dashes, e.g. tls: {min-version: "1.2"} sets the tls-min-version flag
+func flatten(fs *flag.FlagSet, prefix string, m map[string]interface{}, flags map[string]string) error {
+	for _, k := range sortedKeys(m) {
+		name := flagName(prefix, k)
+		v := m[k]
+
+		if mv, ok := v.(map[string]interface{}); ok && fs.Lookup(name) == nil {
+			if err := flatten(fs, name, mv, flags); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("config: unknown option: %s", name)
+		}
+
+		if _, exists := flags[name]; exists {
+			return fmt.Errorf("config: duplicate option: %s", name)
+		}
+
+		s, err := formatValue(v)
+		if err != nil {
+			return fmt.Errorf("config: option %s: %v", name, err)
+		}
+
+		flags[name] = s
+	}
+
+	return nil
+}
+
+// Apply sets the flags from the configuration values. The flags that were
+// set explicitly on the command line take precedence over the
+// configuration file. It fails on unknown options, and on values that are
+// invalid for the flag.
+func (v Values) Apply(fs *flag.FlagSet) error {
+	flags := make(map[string]string)
+	if err := flatten(fs, "", v, flags); err != nil {
+		return err
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	var names []string
+	for name := range flags {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		if explicit[name] {
+			continue
+		}
+
+		if err := fs.Set(name, flags[name]); err != nil {
+			return fmt.Errorf("config: option %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
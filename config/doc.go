@@ -0,0 +1,64 @@
+/*
+Package config implements loading the command line options of skipper
+from a structured configuration file, in YAML or in TOML format, e.g. to
+manage the configuration of a deployment as a single file instead of a
+long list of flags.
+
+The keys of the configuration are the names of the command line flags.
+The keys can be grouped in nested sections, in which case the flag name
+is made of the section names and the key, joined with dashes. Underscores
+in the keys are accepted in place of dashes. The following YAML and TOML
+documents are equivalent to the flags:
+
+    -address :9090 -tls-cert /etc/skipper/cert.pem -tls-min-version 1.2 -etcd-urls http://etcd-1:2379,http://etcd-2:2379
+
+YAML:
+
+    address: :9090
+    tls:
+      cert: /etc/skipper/cert.pem
+      min-version: "1.2"
+    etcd:
+      urls:
+      - http://etcd-1:2379
+      - http://etcd-2:2379
+
+TOML:
+
+    address = ":9090"
+
+    [tls]
+    cert = "/etc/skipper/cert.pem"
+    min-version = "1.2"
+
+    [etcd]
+    urls = ["http://etcd-1:2379", "http://etcd-2:2379"]
+
+Lists are joined with commas, and when a section matches the name of a
+flag itself, e.g. secrets or profiling-labels, it is formatted as a comma
+separated list of key=value pairs.
+
+The string values can reference environment variables as ${NAME}, or with
+a default value as ${NAME:-default}. A literal dollar sign can be written
+as $$. It is an error to reference an undefined variable without a
+default:
+
+    secrets:
+      cookies: env:COOKIE_KEYS
+    tls:
+      cert: ${TLS_DIR:-/etc/skipper}/cert.pem
+
+The flags set explicitly on the command line take precedence over the
+values from the configuration file. Unknown options are rejected.
+
+The effective configuration, with the command line flags, the values
+from the configuration file and the defaults merged, is served as JSON
+by skipper on the /config path of the metrics listener. The values of
+the options whose name contains e.g. token, secret, password or dsn, and
+the user info of the URLs, e.g. user:password@, are redacted.
+
+Only a subset of TOML is supported: tables, dotted keys, strings,
+integers, floats, booleans and arrays. Inline tables, arrays of tables
+and dates are rejected.
+*/
+package config
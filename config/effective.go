@@ -0,0 +1,115 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Redacted replaces the values of the secret options in the effective
+// configuration.
+const Redacted = "<redacted>"
+
+// the options whose name contains any of these words are considered
+// secret
+var secretWords = map[string]bool{
+	"token":       true,
+	"tokens":      true,
+	"secret":      true,
+	"secrets":     true,
+	"password":    true,
+	"passwords":   true,
+	"credentials": true,
+	"dsn":         true,
+}
+
+func isSecret(name string) bool {
+	for _, w := range strings.Split(name, "-") {
+		if secretWords[w] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removes the user info from the URLs in a comma separated list, because
+// it can be a credential, even without a password, e.g. the key of an
+// error reporting DSN
+func redactURLs(s string) string {
+	if !strings.Contains(s, "@") {
+		return s
+	}
+
+	items := strings.Split(s, ",")
+	for i, item := range items {
+		u, err := url.Parse(item)
+		if err != nil || u.User == nil {
+			continue
+		}
+
+		// the user info is the part of the authority before the last @
+		start := strings.Index(item, "//") + 2
+		authority := item[start:]
+		if end := strings.IndexAny(authority, "/?#"); end >= 0 {
+			authority = authority[:end]
+		}
+
+		at := strings.LastIndex(authority, "@")
+		items[i] = item[:start] + Redacted + item[start+at:]
+	}
+
+	return strings.Join(items, ",")
+}
+
+func flagValue(f *flag.Flag) interface{} {
+	if g, ok := f.Value.(flag.Getter); ok {
+		switch v := g.Get().(type) {
+		case bool, int, int64, uint, uint64, float64:
+			return v
+		}
+	}
+
+	return f.Value.String()
+}
+
+// Effective returns the values of all the flags, after the command line
+// and the configuration file were applied, including the defaults. The
+// non-empty values of the options whose name suggests a secret, e.g.
+// tap-token or error-reporting-dsn, are replaced by Redacted, and so is
+// the user info of the URLs.
+func Effective(fs *flag.FlagSet) Values {
+	v := make(Values)
+	fs.VisitAll(func(f *flag.Flag) {
+		fv := flagValue(f)
+		if s, ok := fv.(string); ok && s != "" && isSecret(f.Name) {
+			fv = Redacted
+		} else if ok {
+			fv = redactURLs(s)
+		}
+
+		v[f.Name] = fv
+	})
+
+	return v
+}
+
+// Handler returns a handler that serves the configuration values as
+// JSON, e.g. the effective configuration on the support listener.
+func Handler(v Values) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			log.Error("error while sending the configuration", err)
+		}
+	})
+}
@@ -0,0 +1,368 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parses the subset of TOML used by the configuration files: tables,
+// dotted keys, strings, integers, floats, booleans and arrays of these.
+// Inline tables, arrays of tables and dates are not supported.
+type tomlParser struct {
+	input string
+	pos   int
+	line  int
+}
+
+func parseTOML(input string) (map[string]interface{}, error) {
+	p := &tomlParser{input: input, line: 1}
+	root := make(map[string]interface{})
+	current := root
+	for {
+		p.skipSpaceAndComments(true)
+		if p.eof() {
+			return root, nil
+		}
+
+		var err error
+		if p.peek() == '[' {
+			current, err = p.parseTable(root)
+		} else {
+			err = p.parseKeyValue(current)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("config: TOML line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) eof() bool { return p.pos >= len(p.input) }
+
+func (p *tomlParser) peek() byte { return p.input[p.pos] }
+
+func (p *tomlParser) next() byte {
+	c := p.input[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+
+	return c
+}
+
+// skips the whitespace and the comments, including the new lines when
+// multiline is set
+func (p *tomlParser) skipSpaceAndComments(multiline bool) {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.next()
+		case c == '\n' && multiline:
+			p.next()
+		case c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.next()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// expects the end of the line, after a value or a table header
+func (p *tomlParser) endOfLine() error {
+	p.skipSpaceAndComments(false)
+	if p.eof() {
+		return nil
+	}
+
+	if p.next() != '\n' {
+		return p.errorf("expected new line")
+	}
+
+	return nil
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// parses a dotted key, e.g. tls.min-version or "quoted".key
+func (p *tomlParser) parseKey() ([]string, error) {
+	var key []string
+	for {
+		p.skipSpaceAndComments(false)
+		if p.eof() {
+			return nil, p.errorf("missing key")
+		}
+
+		var part string
+		switch c := p.peek(); {
+		case c == '"' || c == '\'':
+			s, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+
+			part = s
+		case isBareKeyChar(c):
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.next()
+			}
+
+			part = p.input[start:p.pos]
+		default:
+			return nil, p.errorf("invalid key character: %q", c)
+		}
+
+		key = append(key, part)
+		p.skipSpaceAndComments(false)
+		if p.eof() || p.peek() != '.' {
+			return key, nil
+		}
+
+		p.next()
+	}
+}
+
+// returns the table at the key path, creating the missing ones
+func (p *tomlParser) table(root map[string]interface{}, key []string) (map[string]interface{}, error) {
+	t := root
+	for _, k := range key {
+		v, ok := t[k]
+		if !ok {
+			nt := make(map[string]interface{})
+			t[k] = nt
+			t = nt
+			continue
+		}
+
+		nt, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, p.errorf("key is not a table: %s", k)
+		}
+
+		t = nt
+	}
+
+	return t, nil
+}
+
+func (p *tomlParser) parseTable(root map[string]interface{}) (map[string]interface{}, error) {
+	p.next()
+	if !p.eof() && p.peek() == '[' {
+		return nil, p.errorf("arrays of tables are not supported")
+	}
+
+	key, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.eof() || p.next() != ']' {
+		return nil, p.errorf("invalid table header")
+	}
+
+	if err := p.endOfLine(); err != nil {
+		return nil, err
+	}
+
+	return p.table(root, key)
+}
+
+func (p *tomlParser) parseKeyValue(current map[string]interface{}) error {
+	key, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+
+	if p.eof() || p.next() != '=' {
+		return p.errorf("expected =")
+	}
+
+	p.skipSpaceAndComments(false)
+	v, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	t, err := p.table(current, key[:len(key)-1])
+	if err != nil {
+		return err
+	}
+
+	name := key[len(key)-1]
+	if _, exists := t[name]; exists {
+		return p.errorf("duplicate key: %s", strings.Join(key, "."))
+	}
+
+	t[name] = v
+	return p.endOfLine()
+}
+
+func (p *tomlParser) parseValue() (interface{}, error) {
+	if p.eof() {
+		return nil, p.errorf("missing value")
+	}
+
+	switch c := p.peek(); c {
+	case '"', '\'':
+		return p.parseString()
+	case '[':
+		return p.parseArray()
+	case '{':
+		return nil, p.errorf("inline tables are not supported")
+	default:
+		start := p.pos
+		for !p.eof() && !strings.ContainsRune(" \t\r\n#,]", rune(p.peek())) {
+			p.next()
+		}
+
+		return p.parseScalar(p.input[start:p.pos])
+	}
+}
+
+func (p *tomlParser) parseScalar(s string) (interface{}, error) {
+	switch s {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+
+	n := strings.Replace(s, "_", "", -1)
+	if i, err := strconv.ParseInt(n, 0, 64); err == nil {
+		return i, nil
+	}
+
+	if f, err := strconv.ParseFloat(n, 64); err == nil {
+		return f, nil
+	}
+
+	return nil, p.errorf("invalid value: %s", s)
+}
+
+func (p *tomlParser) parseArray() ([]interface{}, error) {
+	p.next()
+	var a []interface{}
+	for {
+		p.skipSpaceAndComments(true)
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+
+		if p.peek() == ']' {
+			p.next()
+			return a, nil
+		}
+
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		a = append(a, v)
+		p.skipSpaceAndComments(true)
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+
+		switch p.next() {
+		case ',':
+		case ']':
+			return a, nil
+		default:
+			return nil, p.errorf("expected , or ] in array")
+		}
+	}
+}
+
+func (p *tomlParser) parseString() (string, error) {
+	quote := p.next()
+	multiline := strings.HasPrefix(p.input[p.pos:], string([]byte{quote, quote}))
+	if multiline {
+		p.pos += 2
+
+		// a new line right after the opening delimiter is trimmed
+		if strings.HasPrefix(p.input[p.pos:], "\n") {
+			p.next()
+		}
+	}
+
+	var b bytes.Buffer
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+
+		if multiline && strings.HasPrefix(p.input[p.pos:], strings.Repeat(string(quote), 3)) {
+			p.pos += 3
+			return b.String(), nil
+		}
+
+		c := p.next()
+		switch {
+		case c == quote && !multiline:
+			return b.String(), nil
+		case c == '\n' && !multiline:
+			return "", p.errorf("unterminated string")
+		case c == '\\' && quote == '"':
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+func (p *tomlParser) parseEscape(b *bytes.Buffer) error {
+	if p.eof() {
+		return p.errorf("unterminated string")
+	}
+
+	switch c := p.next(); c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+
+		if p.pos+size > len(p.input) {
+			return p.errorf("invalid unicode escape")
+		}
+
+		r, err := strconv.ParseUint(p.input[p.pos:p.pos+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return p.errorf("invalid unicode escape")
+		}
+
+		p.pos += size
+		b.WriteRune(rune(r))
+	default:
+		return p.errorf("invalid escape: \\%c", c)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/zalando/skipper/tlsconfig"
+)
+
+const (
+	secretURIFmt  = "/api/v1/namespaces/%s/secrets/%s"
+	tlsSecretType = "kubernetes.io/tls"
+	tlsCertKey    = "tls.crt"
+	tlsKeyKey     = "tls.key"
+)
+
+type secret struct {
+	Type string            `json:"type"`
+	Data map[string]string `json:"data"`
+}
+
+type secretRef struct {
+	namespace, name string
+}
+
+// SecretProvider loads the TLS certificates from Kubernetes secrets of
+// the type kubernetes.io/tls. It implements the tlsconfig.Provider
+// interface, and the certificates are reloaded when the secrets change.
+type SecretProvider struct {
+	client  *Client
+	secrets []secretRef
+}
+
+// NewSecretProvider creates a provider of the TLS certificates stored
+// in the Kubernetes secrets, referenced as <namespace>/<name>. Only the
+// KubernetesInCluster and the KubernetesURL options are used. The
+// service account of skipper needs to be allowed to get the secrets.
+func NewSecretProvider(o Options, secrets []string) (*SecretProvider, error) {
+	p := &SecretProvider{}
+	for _, s := range secrets {
+		parts := strings.Split(s, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid secret reference, expected <namespace>/<name>: %s", s)
+		}
+
+		p.secrets = append(p.secrets, secretRef{namespace: parts[0], name: parts[1]})
+	}
+
+	c, err := New(Options{
+		KubernetesInCluster: o.KubernetesInCluster,
+		KubernetesURL:       o.KubernetesURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p.client = c
+	return p, nil
+}
+
+func decodeSecretKey(s *secret, key string) ([]byte, error) {
+	v, ok := s.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("missing key: %s", key)
+	}
+
+	return base64.StdEncoding.DecodeString(v)
+}
+
+// KeyPairs returns the certificates and the keys stored in the secrets.
+func (p *SecretProvider) KeyPairs() ([]tlsconfig.PEMKeyPair, error) {
+	var pairs []tlsconfig.PEMKeyPair
+	for _, r := range p.secrets {
+		var s secret
+		if err := p.client.getJSON(fmt.Sprintf(secretURIFmt, r.namespace, r.name), &s); err != nil {
+			return nil, fmt.Errorf("error while loading secret %s/%s: %v", r.namespace, r.name, err)
+		}
+
+		if s.Type != tlsSecretType {
+			return nil, fmt.Errorf("invalid type of secret %s/%s: %s", r.namespace, r.name, s.Type)
+		}
+
+		cert, err := decodeSecretKey(&s, tlsCertKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret %s/%s: %v", r.namespace, r.name, err)
+		}
+
+		key, err := decodeSecretKey(&s, tlsKeyKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret %s/%s: %v", r.namespace, r.name, err)
+		}
+
+		pairs = append(pairs, tlsconfig.PEMKeyPair{
+			Name: "kubernetes:" + r.namespace + "/" + r.name,
+			Cert: cert,
+			Key:  key,
+		})
+	}
+
+	return pairs, nil
+}
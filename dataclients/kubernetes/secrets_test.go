@@ -0,0 +1,102 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSecretProvider(t *testing.T) {
+	secrets := map[string]secret{
+		"/api/v1/namespaces/default/secrets/www": {
+			Type: "kubernetes.io/tls",
+			Data: map[string]string{
+				"tls.crt": base64.StdEncoding.EncodeToString([]byte("cert")),
+				"tls.key": base64.StdEncoding.EncodeToString([]byte("key")),
+			},
+		},
+		"/api/v1/namespaces/default/secrets/opaque": {
+			Type: "Opaque",
+			Data: map[string]string{"password": "c2VjcmV0"},
+		},
+		"/api/v1/namespaces/default/secrets/no-key": {
+			Type: "kubernetes.io/tls",
+			Data: map[string]string{"tls.crt": "Y2VydA=="},
+		},
+	}
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, ok := secrets[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(s)
+	}))
+	defer api.Close()
+
+	for _, test := range []struct {
+		title    string
+		secrets  []string
+		fail     bool
+		loadFail bool
+	}{{
+		title:   "invalid reference",
+		secrets: []string{"www"},
+		fail:    true,
+	}, {
+		title:    "not found",
+		secrets:  []string{"default/missing"},
+		loadFail: true,
+	}, {
+		title:    "invalid type",
+		secrets:  []string{"default/opaque"},
+		loadFail: true,
+	}, {
+		title:    "missing key",
+		secrets:  []string{"default/no-key"},
+		loadFail: true,
+	}, {
+		title:   "tls secret",
+		secrets: []string{"default/www"},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			p, err := NewSecretProvider(Options{KubernetesURL: api.URL}, test.secrets)
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pairs, err := p.KeyPairs()
+			if test.loadFail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(pairs) != 1 ||
+				pairs[0].Name != "kubernetes:default/www" ||
+				!reflect.DeepEqual(pairs[0].Cert, []byte("cert")) ||
+				!reflect.DeepEqual(pairs[0].Key, []byte("key")) {
+				t.Error("invalid key pairs", pairs)
+			}
+		})
+	}
+}
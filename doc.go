@@ -320,13 +320,14 @@ Example, hello.go:
     package main
 
     import (
+        "context"
         "github.com/zalando/skipper"
         "github.com/zalando/skipper/filters"
         "log"
     )
 
     func main() {
-        log.Fatal(skipper.Run(skipper.Options{
+        log.Fatal(skipper.Run(context.Background(), skipper.Options{
             Address: ":9090",
             RoutesFile: "routes.eskip",
             CustomPredicates: []routing.PredicateSpec{&randomSpec{}},
@@ -343,6 +344,40 @@ Start the custom router:
     go run hello.go
 
 
+Embedding Skipper
+
+The 'Run' function blocks until the context is done, and then shuts
+down the proxy gracefully. When the embedding program needs to control
+the lifecycle of the proxy, it can create an instance with 'New', start
+it with 'Serve', and stop it with 'Shutdown':
+
+    s, err := skipper.New(skipper.Options{
+        Address:           ":9090",
+        CustomFilters:     []filters.Spec{&helloSpec{}},
+        CustomDataClients: []routing.DataClient{myRoutes},
+        Metrics:           metrics.New(metrics.Options{Registry: myRegistry}),
+    })
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    go func() {
+        <-stop
+        ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+        defer cancel()
+        s.Shutdown(ctx)
+    }()
+
+    if err := s.Serve(); err != nil {
+        log.Fatal(err)
+    }
+
+The custom filters, predicates and data clients are used together with
+the built-in ones. When the Metrics option is set, the metrics are
+collected by the provided instance, e.g. with a go-metrics registry
+exported by the embedding program.
+
+
 Proxy Package Used Individually
 
 The 'Run' function in the root Skipper package starts its own listener
@@ -0,0 +1,23 @@
+/*
+Package errorreport implements sending the errors of the proxy to a
+Sentry compatible error reporting service, so that the incidents
+surface in the existing alerting tools.
+
+The following errors are reported, with the ID of the route, the name
+of the filter and the flow id of the request, when known:
+
+    panic:        a panic while proxying a request
+    filter_panic: a panic of a filter
+    5xx_burst:    the number of 5xx responses of a route reached the
+                  threshold within the burst window, reported once per
+                  window
+
+The events are sent with the Sentry store API, to the endpoint derived
+from the DSN, e.g:
+
+    skipper -error-reporting-dsn https://key@sentry.example.org/42
+
+The events are sent from the background, and the number of the events
+sent per minute is limited. The events over the limit are dropped.
+*/
+package errorreport
@@ -0,0 +1,389 @@
+package errorreport
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMaxEventsPerMinute is the default maximum number of the
+	// events sent to the endpoint per minute. The further events are
+	// dropped.
+	DefaultMaxEventsPerMinute = 60
+
+	// DefaultBurstThreshold is the default number of 5xx responses of
+	// a route within the burst window that triggers an event.
+	DefaultBurstThreshold = 10
+
+	// DefaultBurstWindow is the default time window of counting the
+	// 5xx responses of a route.
+	DefaultBurstWindow = time.Minute
+
+	queueSize   = 1 << 7
+	sendTimeout = 5 * time.Second
+	sentryAuth  = "Sentry sentry_version=7, sentry_client=skipper/1.0, sentry_key=%s"
+)
+
+// Kinds of the reported events.
+const (
+	KindPanic       = "panic"
+	KindFilterPanic = "filter_panic"
+	KindBurst       = "5xx_burst"
+)
+
+var errInvalidDSN = errors.New("invalid error reporting DSN")
+
+// Event contains the details of a reported error.
+type Event struct {
+
+	// The kind of the error, one of the Kind constants.
+	Kind string
+
+	// The error message.
+	Message string
+
+	// The ID of the route that the error happened on.
+	RouteID string
+
+	// The name of the filter that failed, if any.
+	Filter string
+
+	// The correlation ID of the request, if any.
+	FlowID string
+
+	// The stack trace, if any.
+	Stack string
+}
+
+// Reporter is used by the proxy to report the errors. Implementations
+// need to be safe for concurrent use and must not block the caller.
+type Reporter interface {
+
+	// Report sends an error event.
+	Report(*Event)
+
+	// ObserveResponse is called with the status code of every
+	// response, to detect the bursts of 5xx responses.
+	ObserveResponse(routeID string, statusCode int)
+
+	// Close flushes the pending events and stops the reporter.
+	Close()
+}
+
+// Options for creating a reporter.
+type Options struct {
+
+	// The Sentry DSN of the project, in the format:
+	// https://<key>@<host>/<project-id>. Required.
+	DSN string
+
+	// The environment reported with the events, e.g. production.
+	Environment string
+
+	// The server name reported with the events. Default: the
+	// hostname.
+	ServerName string
+
+	// The maximum number of events sent per minute. Default: 60.
+	MaxEventsPerMinute int
+
+	// The number of 5xx responses of a route within the burst window
+	// that triggers an event. Default: 10.
+	BurstThreshold int
+
+	// The time window of counting the 5xx responses. Default: 1m.
+	BurstWindow time.Duration
+}
+
+type noop struct{}
+
+// Noop is the default reporter that drops every event.
+var Noop Reporter = noop{}
+
+func (noop) Report(*Event)               {}
+func (noop) ObserveResponse(string, int) {}
+func (noop) Close()                      {}
+
+type burst struct {
+	start    time.Time
+	count    int
+	reported bool
+}
+
+type sentry struct {
+	storeURL    string
+	auth        string
+	environment string
+	serverName  string
+	client      *http.Client
+
+	maxEvents      int
+	burstThreshold int
+	burstWindow    time.Duration
+
+	mx        sync.Mutex
+	rateStart time.Time
+	rateCount int
+	bursts    map[string]*burst
+	queue     chan *Event
+	closed    bool
+	done      chan struct{}
+	now       func() time.Time
+}
+
+type (
+	sentryException struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}
+
+	sentryEvent struct {
+		EventID     string            `json:"event_id"`
+		Timestamp   string            `json:"timestamp"`
+		Level       string            `json:"level"`
+		Logger      string            `json:"logger"`
+		Platform    string            `json:"platform"`
+		Message     string            `json:"message"`
+		ServerName  string            `json:"server_name,omitempty"`
+		Environment string            `json:"environment,omitempty"`
+		Tags        map[string]string `json:"tags"`
+		Extra       map[string]string `json:"extra,omitempty"`
+		Exception   struct {
+			Values []sentryException `json:"values"`
+		} `json:"exception"`
+	}
+)
+
+// parses the DSN and returns the URL of the store endpoint and the
+// public key
+func parseDSN(dsn string) (string, string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", err
+	}
+
+	if u.User == nil || u.User.Username() == "" || u.Host == "" {
+		return "", "", errInvalidDSN
+	}
+
+	path := strings.Trim(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	project := path[i+1:]
+	if project == "" {
+		return "", "", errInvalidDSN
+	}
+
+	prefix := ""
+	if i >= 0 {
+		prefix = "/" + path[:i]
+	}
+
+	return fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, prefix, project), u.User.Username(), nil
+}
+
+// New creates a reporter that sends the events to a Sentry compatible
+// endpoint. When the DSN is empty, it returns Noop.
+func New(o Options) (Reporter, error) {
+	if o.DSN == "" {
+		return Noop, nil
+	}
+
+	storeURL, key, err := parseDSN(o.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.ServerName == "" {
+		o.ServerName, _ = os.Hostname()
+	}
+
+	if o.MaxEventsPerMinute <= 0 {
+		o.MaxEventsPerMinute = DefaultMaxEventsPerMinute
+	}
+
+	if o.BurstThreshold <= 0 {
+		o.BurstThreshold = DefaultBurstThreshold
+	}
+
+	if o.BurstWindow <= 0 {
+		o.BurstWindow = DefaultBurstWindow
+	}
+
+	s := &sentry{
+		storeURL:       storeURL,
+		auth:           fmt.Sprintf(sentryAuth, key),
+		environment:    o.Environment,
+		serverName:     o.ServerName,
+		client:         &http.Client{Timeout: sendTimeout},
+		maxEvents:      o.MaxEventsPerMinute,
+		burstThreshold: o.BurstThreshold,
+		burstWindow:    o.BurstWindow,
+		bursts:         make(map[string]*burst),
+		queue:          make(chan *Event, queueSize),
+		done:           make(chan struct{}),
+		now:            time.Now,
+	}
+
+	go s.run()
+	return s, nil
+}
+
+// counts the events in fixed one minute windows
+func (s *sentry) allow() bool {
+	now := s.now()
+	if now.Sub(s.rateStart) >= time.Minute {
+		s.rateStart = now
+		s.rateCount = 0
+	}
+
+	if s.rateCount >= s.maxEvents {
+		return false
+	}
+
+	s.rateCount++
+	return true
+}
+
+func (s *sentry) Report(e *Event) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed || !s.allow() {
+		return
+	}
+
+	select {
+	case s.queue <- e:
+	default:
+	}
+}
+
+// reports a burst once per window and route
+func (s *sentry) ObserveResponse(routeID string, statusCode int) {
+	if statusCode < http.StatusInternalServerError {
+		return
+	}
+
+	now := s.now()
+	s.mx.Lock()
+	b, ok := s.bursts[routeID]
+	if !ok || now.Sub(b.start) >= s.burstWindow {
+		b = &burst{start: now}
+		s.bursts[routeID] = b
+	}
+
+	b.count++
+	count := b.count
+	report := count >= s.burstThreshold && !b.reported
+	if report {
+		b.reported = true
+	}
+
+	s.mx.Unlock()
+
+	if report {
+		s.Report(&Event{
+			Kind:    KindBurst,
+			Message: fmt.Sprintf("%d 5xx responses within %v", count, s.burstWindow),
+			RouteID: routeID,
+		})
+	}
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *sentry) convert(e *Event) *sentryEvent {
+	se := &sentryEvent{
+		EventID:     newEventID(),
+		Timestamp:   s.now().UTC().Format("2006-01-02T15:04:05"),
+		Level:       "error",
+		Logger:      "skipper",
+		Platform:    "go",
+		Message:     e.Message,
+		ServerName:  s.serverName,
+		Environment: s.environment,
+		Tags:        map[string]string{"kind": e.Kind},
+	}
+
+	if e.RouteID != "" {
+		se.Tags["route_id"] = e.RouteID
+	}
+
+	if e.Filter != "" {
+		se.Tags["filter"] = e.Filter
+	}
+
+	if e.FlowID != "" {
+		se.Tags["flow_id"] = e.FlowID
+	}
+
+	if e.Stack != "" {
+		se.Extra = map[string]string{"stack": e.Stack}
+	}
+
+	se.Exception.Values = []sentryException{{Type: e.Kind, Value: e.Message}}
+	return se
+}
+
+func (s *sentry) send(e *Event) error {
+	b, err := json.Marshal(s.convert(e))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", s.storeURL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", s.auth)
+	rsp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status from the error reporting endpoint: %d", rsp.StatusCode)
+	}
+
+	return nil
+}
+
+func (s *sentry) run() {
+	defer close(s.done)
+	for e := range s.queue {
+		if err := s.send(e); err != nil {
+			log.Errorf("error while reporting error event: %v", err)
+		}
+	}
+}
+
+func (s *sentry) Close() {
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+		return
+	}
+
+	s.closed = true
+	close(s.queue)
+	s.mx.Unlock()
+	<-s.done
+}
@@ -3,6 +3,7 @@ package eskip
 import (
 	"fmt"
 	"math"
+	"sort"
 	"strings"
 )
 
@@ -70,12 +71,26 @@ func (r *Route) predicateString() string {
 		predicates = appendFmtEscape(predicates, `Method("%s")`, `"`, r.Method)
 	}
 
-	for k, v := range r.Headers {
-		predicates = appendFmtEscape(predicates, `Header("%s", "%s")`, `"`, k, v)
+	// the headers are printed in the order of their names, to make the
+	// output deterministic
+	headerKeys := make([]string, 0, len(r.Headers))
+	for k := range r.Headers {
+		headerKeys = append(headerKeys, k)
 	}
 
-	for k, rxs := range r.HeaderRegexps {
-		for _, rx := range rxs {
+	sort.Strings(headerKeys)
+	for _, k := range headerKeys {
+		predicates = appendFmtEscape(predicates, `Header("%s", "%s")`, `"`, k, r.Headers[k])
+	}
+
+	headerRegexpKeys := make([]string, 0, len(r.HeaderRegexps))
+	for k := range r.HeaderRegexps {
+		headerRegexpKeys = append(headerRegexpKeys, k)
+	}
+
+	sort.Strings(headerRegexpKeys)
+	for _, k := range headerRegexpKeys {
+		for _, rx := range r.HeaderRegexps[k] {
 			predicates = appendFmt(predicates, `HeaderRegexp("%s", /%s/)`, escape(k, `"`), escape(rx, "/"))
 		}
 	}
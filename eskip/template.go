@@ -5,8 +5,8 @@
 package eskip
 
 import (
+	"bytes"
 	"regexp"
-	"strings"
 )
 
 var parameterRegexp = regexp.MustCompile("\\$\\{(\\w+)\\}")
@@ -14,10 +14,16 @@ var parameterRegexp = regexp.MustCompile("\\$\\{(\\w+)\\}")
 // TemplateGetter functions return the value for a template parameter name.
 type TemplateGetter func(string) string
 
-// Template represents a string template with named placeholders.
+// Template represents a string template with named placeholders. The
+// template is split into its literal parts and its placeholders, when it
+// is created, so that applying it doesn't need to search the template.
 type Template struct {
 	template     string
 	placeholders []string
+
+	// the literal parts of the template, surrounding the placeholders,
+	// always one more than the placeholders
+	literals []string
 }
 
 // New parses a template string and returns a reusable *Template object.
@@ -26,28 +32,35 @@ type Template struct {
 // 	Hello, ${who}!
 //
 func NewTemplate(template string) *Template {
-	matches := parameterRegexp.FindAllStringSubmatch(template, -1)
+	matches := parameterRegexp.FindAllStringSubmatchIndex(template, -1)
 	placeholders := make([]string, len(matches))
+	literals := make([]string, len(matches)+1)
 
-	for index, placeholder := range matches {
-		placeholders[index] = placeholder[1]
+	last := 0
+	for index, m := range matches {
+		literals[index] = template[last:m[0]]
+		placeholders[index] = template[m[2]:m[3]]
+		last = m[1]
 	}
 
-	return &Template{template: template, placeholders: placeholders}
+	literals[len(matches)] = template[last:]
+	return &Template{template: template, placeholders: placeholders, literals: literals}
 }
 
 // Apply evaluates the template using a TemplateGetter function to resolve the
 // placeholders.
 func (t *Template) Apply(get TemplateGetter) string {
-	result := t.template
-
-	if get == nil {
-		return result
+	if get == nil || len(t.placeholders) == 0 {
+		return t.template
 	}
 
-	for _, placeholder := range t.placeholders {
-		result = strings.Replace(result, "${"+placeholder+"}", get(placeholder), -1)
+	var b bytes.Buffer
+	b.Grow(len(t.template))
+	for index, placeholder := range t.placeholders {
+		b.WriteString(t.literals[index])
+		b.WriteString(get(placeholder))
 	}
 
-	return result
+	b.WriteString(t.literals[len(t.placeholders)])
+	return b.String()
 }
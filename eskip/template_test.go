@@ -55,5 +55,31 @@ func TestTemplateGetter(t *testing.T) {
 		"/${param1}",
 		"/${param1}",
 		nil,
+	}, {
+		"/${param1}/${param1}${param2}",
+		"/param1/param1param2",
+		func(param string) string {
+			return param
+		},
+	}, {
+		"/${param1}/${param2}",
+		"/${param2}/bar",
+		func(param string) string {
+			if param == "param1" {
+				return "${param2}"
+			}
+
+			return "bar"
+		},
 	}})
 }
+
+func BenchmarkTemplateApply(b *testing.B) {
+	t := NewTemplate("/api/${version}/items/${id}")
+	get := func(param string) string { return param }
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		t.Apply(get)
+	}
+}
@@ -18,14 +18,26 @@ definitions from an eskip formatted file when opened.
 
 (See the DataClient interface in the skipper/routing package and the eskip
 format in the skipper/eskip package.)
+
+With OpenVerified, the file is accepted only when it is signed by one of
+the trusted keys, either with a detached signature in the file with the
+.sig suffix, e.g. routes.eskip.sig, or when the file itself is a JWS
+containing the route definitions. (See the routesig package.)
 */
 package eskipfile
 
 import (
-	"github.com/zalando/skipper/eskip"
 	"io/ioutil"
+	"os"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/routesig"
 )
 
+// SignatureSuffix is the suffix of the file containing the detached
+// signature of an eskip file.
+const SignatureSuffix = ".sig"
+
 // A Client contains the route definitions from an eskip file.
 type Client struct{ routes []*eskip.Route }
 
@@ -45,6 +57,35 @@ func Open(path string) (*Client, error) {
 	return &Client{routes}, nil
 }
 
+// Opens an eskip file, verifies its signature, and parses it. The
+// signature is read from the file with the same path and the .sig
+// suffix. When there is no such file, the eskip file needs to be a JWS
+// in compact serialization, containing the route definitions as its
+// payload. If the signature is missing or invalid, returns an error.
+func OpenVerified(path string, v *routesig.Verifier) (*Client, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := ioutil.ReadFile(path + SignatureSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	content, err = v.Verify(path, content, sig)
+	if err != nil {
+		return nil, err
+	}
+
+	routes, err := eskip.Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{routes}, nil
+}
+
 func (c Client) LoadAndParseAll() (routeInfos []*eskip.RouteInfo, err error) {
 	for _, route := range c.routes {
 		routeInfos = append(routeInfos, &eskip.RouteInfo{Route: *route})
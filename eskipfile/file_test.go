@@ -0,0 +1,78 @@
+package eskipfile
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/routesig"
+)
+
+const testRoutes = `r: Path("/foo") -> "https://www.example.org";`
+
+func TestOpenVerified(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skipper-eskipfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := routesig.NewWithKeys(map[string]crypto.PublicKey{"test": key.Public()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jws, err := routesig.SignJWS([]byte(testRoutes), key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		return p
+	}
+
+	signed := write("signed.eskip", jws)
+	unsigned := write("unsigned.eskip", testRoutes)
+
+	c, err := OpenVerified(signed, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r, _ := c.LoadAll(); len(r) != 1 || r[0].Id != "r" {
+		t.Error("failed to load the routes", r)
+	}
+
+	if _, err := OpenVerified(unsigned, v); err == nil {
+		t.Error("failed to fail with unsigned routes")
+	}
+
+	// a detached JWS, with an empty payload
+	parts := strings.Split(jws, ".")
+	write("unsigned.eskip"+SignatureSuffix, parts[0]+".."+parts[2])
+	if _, err := OpenVerified(unsigned, v); err != nil {
+		t.Error(err)
+	}
+
+	write("unsigned.eskip", testRoutes+"\n"+`r2: * -> "https://evil.example.org";`)
+	if _, err := OpenVerified(unsigned, v); err == nil {
+		t.Error("failed to fail with modified routes")
+	}
+}
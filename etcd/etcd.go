@@ -25,6 +25,17 @@ routes will get the etcd key as id.
 
 In addition to the DataClient implementation, type Client provides
 methods to Upsert and Delete routes.
+
+When a verifier is set in the options, the values need to be signed by
+one of the trusted keys, stored as a JWS in compact serialization, with
+the id, the version and the expression of the route as the payload,
+created with routesig.SignRoute. The values without a valid signature,
+signed for a different key, or with an older version than the current
+one are rejected, and the current version of the route is kept. The
+routes need to be deleted by storing a signed tombstone under their key,
+and the removed keys are rejected the same way. (See the routesig
+package.) The routes stored with Upsert are not signed, and Delete
+doesn't store tombstones.
 */
 package etcd
 
@@ -36,6 +47,7 @@ import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/routesig"
 	"io"
 	"io/ioutil"
 	"net"
@@ -106,6 +118,10 @@ type Options struct {
 
 	// Skip TLS certificate check.
 	Insecure bool
+
+	// When set, only the route expressions signed by the trusted
+	// keys are accepted.
+	Verifier *routesig.Verifier
 }
 
 // A Client is used to load the whole set of routes and the updates from an
@@ -115,6 +131,7 @@ type Client struct {
 	routesRoot string
 	client     *http.Client
 	etcdIndex  uint64
+	verifier   *routesig.Verifier
 }
 
 var (
@@ -154,7 +171,8 @@ func New(o Options) (*Client, error) {
 		endpoints:  o.Endpoints,
 		routesRoot: o.Prefix + routesPath,
 		client:     httpClient,
-		etcdIndex:  0}, nil
+		etcdIndex:  0,
+		verifier:   o.Verifier}, nil
 }
 
 func isTimeout(err error) bool {
@@ -334,13 +352,40 @@ func parseOne(data string) (*eskip.Route, error) {
 	return r[0], nil
 }
 
-// Parses a set of eskip routes.
-func parseRoutes(data map[string]string) []*eskip.RouteInfo {
+// Verifies the signature of a route, when a verifier is set, and parses
+// it. When the value is a signed tombstone, it returns true and no route.
+func (c *Client) verifyAndParse(id, data string) (*eskip.Route, bool, error) {
+	if c.verifier != nil {
+		sr, err := c.verifier.VerifyRoute(c.routesRoot+"/"+id, id, []byte(data))
+		if err != nil {
+			return nil, false, err
+		}
+
+		if sr.Deleted {
+			return nil, true, nil
+		}
+
+		data = sr.Expression
+	}
+
+	r, err := parseOne(data)
+	return r, false, err
+}
+
+// Parses a set of eskip routes. It returns the ids of the routes deleted
+// with a signed tombstone separately.
+func (c *Client) parseRoutes(data map[string]string) ([]*eskip.RouteInfo, []string) {
 	allInfo := make([]*eskip.RouteInfo, 0, len(data))
+	var deleted []string
 	for id, d := range data {
 		info := &eskip.RouteInfo{}
 
-		r, err := parseOne(d)
+		r, tombstone, err := c.verifyAndParse(id, d)
+		if tombstone {
+			deleted = append(deleted, id)
+			continue
+		}
+
 		if err == nil {
 			info.Route = *r
 		} else {
@@ -351,7 +396,7 @@ func parseRoutes(data map[string]string) []*eskip.RouteInfo {
 		allInfo = append(allInfo, info)
 	}
 
-	return allInfo
+	return allInfo, deleted
 }
 
 // Converts route info to route objects logging those whose
@@ -391,7 +436,8 @@ func (c *Client) LoadAndParseAll() ([]*eskip.RouteInfo, error) {
 	}
 
 	c.etcdIndex = etcdIndex
-	return parseRoutes(data), nil
+	routeInfo, _ := c.parseRoutes(data)
+	return routeInfo, nil
 }
 
 // Returns all the route definitions currently stored in etcd.
@@ -425,7 +471,12 @@ func (c *Client) LoadUpdate() ([]*eskip.Route, []string, error) {
 		}
 
 		id := path.Base(response.Node.Key)
-		if response.Action == "delete" {
+		if response.Action == "delete" && c.verifier != nil {
+			// the deletions need to be signed tombstones, the
+			// removed key is verified as an unsigned value
+			updates[id] = ""
+			deletes[id] = false
+		} else if response.Action == "delete" {
 			deletes[id] = true
 			delete(updates, id)
 		} else {
@@ -438,8 +489,11 @@ func (c *Client) LoadUpdate() ([]*eskip.Route, []string, error) {
 		}
 	}
 
-	routeInfo := parseRoutes(updates)
+	routeInfo, tombstones := c.parseRoutes(updates)
 	routes := infoToRoutesLogged(routeInfo)
+	for _, id := range tombstones {
+		deletes[id] = true
+	}
 
 	deletedIds := make([]string, 0, len(deletes))
 	for id, deleted := range deletes {
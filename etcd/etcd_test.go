@@ -15,15 +15,23 @@
 package etcd
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
 	"errors"
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/etcd/etcdtest"
+	"github.com/zalando/skipper/routesig"
 	"log"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -260,7 +268,7 @@ func TestReceivesInitial(t *testing.T) {
 
 	expectedEndpoints := strings.Join(etcdtest.Urls, ";")
 
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -287,7 +295,7 @@ func TestReceivesUpdates(t *testing.T) {
 		return
 	}
 
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -317,7 +325,7 @@ func TestReceiveInsert(t *testing.T) {
 		return
 	}
 
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -350,7 +358,7 @@ func TestReceiveDelete(t *testing.T) {
 		return
 	}
 
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -375,7 +383,7 @@ func TestReceiveDelete(t *testing.T) {
 }
 
 func TestUpsertNoId(t *testing.T) {
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -393,7 +401,7 @@ func TestUpsertNew(t *testing.T) {
 		return
 	}
 
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -419,7 +427,7 @@ func TestUpsertExisting(t *testing.T) {
 		return
 	}
 
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -448,7 +456,7 @@ func TestUpsertExisting(t *testing.T) {
 }
 
 func TestDeleteNoId(t *testing.T) {
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -465,7 +473,7 @@ func TestDeleteNotExists(t *testing.T) {
 		t.Error(err)
 		return
 	}
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -495,7 +503,7 @@ func TestDelete(t *testing.T) {
 		t.Error(err)
 		return
 	}
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -529,7 +537,7 @@ func TestLoadWithParseFailures(t *testing.T) {
 	etcdtest.PutData("catalog", `Path("/pdp") -> "https://catalog.example.org"`)
 	etcdtest.PutData("cms", "invalid expression")
 
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -559,3 +567,181 @@ func TestLoadWithParseFailures(t *testing.T) {
 		t.Error("failed to detect parse error")
 	}
 }
+
+func TestSignedRoutes(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	v, err := routesig.NewWithKeys(map[string]crypto.PublicKey{"test": key.Public()}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	signed, err := routesig.SignRoute(routesig.Route{
+		Id:         "signed",
+		Version:    1,
+		Expression: `Path("/signed") -> "https://www.example.org"`,
+	}, key, "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(etcdIndexHeader, "42")
+		json.NewEncoder(w).Encode(&response{Action: "get", Node: &node{
+			Key: "/skippertest-signed/routes",
+			Dir: true,
+			Nodes: []*node{{
+				Key:   "/skippertest-signed/routes/signed",
+				Value: signed,
+			}, {
+				Key:   "/skippertest-signed/routes/replayed",
+				Value: signed,
+			}, {
+				Key:   "/skippertest-signed/routes/unsigned",
+				Value: `Path("/unsigned") -> "https://evil.example.org"`,
+			}},
+		}})
+	}))
+	defer s.Close()
+
+	c, err := New(Options{Endpoints: []string{s.URL}, Prefix: "/skippertest-signed", Verifier: v})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(routes) != 1 || routes[0].Id != "signed" {
+		t.Error("failed to reject the unsigned and the replayed route", routes)
+	}
+}
+
+func TestSignedUpdates(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := routesig.NewWithKeys(map[string]crypto.PublicKey{"test": key.Public()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(r routesig.Route) string {
+		s, err := routesig.SignRoute(r, key, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return s
+	}
+
+	const (
+		root    = "/skippertest-signed/routes"
+		initial = 42
+	)
+
+	v1 := sign(routesig.Route{Id: "r", Version: 1, Expression: `Path("/v1") -> "https://v1.example.org"`})
+	v2 := sign(routesig.Route{Id: "r", Version: 2, Expression: `Path("/v2") -> "https://v2.example.org"`})
+	events := []*response{
+		// rolled back to the older version
+		{Action: "set", Node: &node{Key: root + "/r", Value: v1}},
+
+		// signed for a different route
+		{Action: "set", Node: &node{Key: root + "/r", Value: sign(routesig.Route{
+			Id:         "other",
+			Version:    3,
+			Expression: `Path("/other") -> "https://other.example.org"`,
+		})}},
+
+		// removed key without a tombstone
+		{Action: "delete", Node: &node{Key: root + "/r"}},
+
+		// signed tombstone
+		{Action: "set", Node: &node{Key: root + "/r", Value: sign(routesig.Route{Id: "r", Version: 3, Deleted: true})}},
+	}
+
+	// every change is followed by a timeout, so that the client
+	// receives one change with every LoadUpdate
+	var (
+		mx      sync.Mutex
+		timeout bool
+	)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("wait") != "true" {
+			w.Header().Set(etcdIndexHeader, strconv.Itoa(initial))
+			json.NewEncoder(w).Encode(&response{Action: "get", Node: &node{
+				Key:   root,
+				Dir:   true,
+				Nodes: []*node{{Key: root + "/r", Value: v2, ModifiedIndex: initial}},
+			}})
+
+			return
+		}
+
+		mx.Lock()
+		index, _ := strconv.Atoi(r.URL.Query().Get("waitIndex"))
+		if timeout || index <= initial || index > initial+len(events) {
+			timeout = false
+			mx.Unlock()
+			time.Sleep(120 * time.Millisecond)
+			return
+		}
+
+		timeout = true
+		mx.Unlock()
+
+		e := events[index-initial-1]
+		e.Node.ModifiedIndex = uint64(index)
+		w.Header().Set(etcdIndexHeader, strconv.Itoa(index))
+		json.NewEncoder(w).Encode(e)
+	}))
+	defer s.Close()
+
+	c, err := New(Options{
+		Endpoints: []string{s.URL},
+		Prefix:    "/skippertest-signed",
+		Timeout:   60 * time.Millisecond,
+		Verifier:  v,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := c.LoadAll()
+	if err != nil || len(routes) != 1 || routes[0].Path != "/v2" {
+		t.Fatal("failed to load the signed route", routes, err)
+	}
+
+	for i, rejected := range []string{"rollback", "different id", "unsigned delete"} {
+		rs, ds, err := c.LoadUpdate()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rs) != 0 || len(ds) != 0 {
+			t.Errorf("failed to reject the update %d, %s: %v, %v", i, rejected, rs, ds)
+		}
+	}
+
+	rs, ds, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rs) != 0 || !checkDeleted(ds, "r") {
+		t.Error("failed to delete the route with a tombstone", rs, ds)
+	}
+}
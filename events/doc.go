@@ -0,0 +1,27 @@
+/*
+Package events implements an internal event bus, publishing structured
+events about the changes of the proxy state, e.g. when the routing
+table was updated, so that automation can be built on them.
+
+Programs embedding skipper can subscribe to the events with the Go
+API:
+
+    bus := events.NewBus()
+    s := bus.Subscribe(0, events.TypeRouteTableUpdated)
+    go func() {
+        for e := range s.C {
+            log.Println(e.Type, e.Data)
+        }
+    }()
+
+    skipper.Run(ctx, skipper.Options{EventBus: bus, ...})
+
+Optionally, the events can be posted to an HTTP endpoint, as JSON
+objects, one event per request:
+
+    skipper -event-webhook https://automation.example.org/skipper-events
+
+The publishing never blocks the publisher. When a subscriber cannot
+keep up with the events, the further events are dropped for it.
+*/
+package events
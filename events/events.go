@@ -0,0 +1,211 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Types of the events published by skipper.
+const (
+	// TypeRouteTableUpdated is published when a new version of the
+	// routing table was applied.
+	TypeRouteTableUpdated = "route_table_updated"
+
+	// TypeBackendUnhealthy is published when a backend was marked
+	// unhealthy.
+	TypeBackendUnhealthy = "backend_unhealthy"
+
+	// TypeCertificateReloaded is published when a TLS certificate
+	// was reloaded.
+	TypeCertificateReloaded = "certificate_reloaded"
+
+	// TypeClientBanned is published when a client was banned for
+	// exceeding the violation threshold.
+	TypeClientBanned = "client_banned"
+
+	// TypeRouteSignatureInvalid is published when a route document
+	// was rejected, because its signature was missing or invalid.
+	TypeRouteSignatureInvalid = "route_signature_invalid"
+
+	// TypeRouteTableRejected is published when an update of the
+	// routing table was rejected, because it exceeded the maximum
+	// number of the routes.
+	TypeRouteTableRejected = "route_table_rejected"
+)
+
+const (
+	// DefaultBufferSize is the default number of the events buffered
+	// for a subscriber.
+	DefaultBufferSize = 64
+
+	webhookTimeout = 5 * time.Second
+)
+
+// Event describes a change of the proxy state.
+type Event struct {
+
+	// The type of the event, one of the Type constants, or a custom
+	// one.
+	Type string `json:"type"`
+
+	// The time of the event. When not set, it is set by Publish.
+	Time time.Time `json:"time"`
+
+	// Details of the event, specific to its type.
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// Bus distributes the published events to the subscribers. It is safe
+// for concurrent use. A nil *Bus drops every event, so that the
+// publishers don't need to check whether a bus was configured.
+type Bus struct {
+	mx          sync.Mutex
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives the events of the selected types on its
+// channel, until it is closed.
+type Subscription struct {
+
+	// The channel that the events are received on. It is closed
+	// when the subscription is closed.
+	C <-chan *Event
+
+	c      chan *Event
+	types  map[string]bool
+	bus    *Bus
+	closed bool
+}
+
+// Webhook is a sink that posts the events to an HTTP endpoint, as JSON
+// objects.
+type Webhook struct {
+	url          string
+	client       *http.Client
+	subscription *Subscription
+	done         chan struct{}
+}
+
+// NewBus creates an event bus.
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Publish sends an event to the subscribers. It never blocks: when a
+// subscriber's buffer is full, the event is dropped for that
+// subscriber.
+func (b *Bus) Publish(e *Event) {
+	if b == nil {
+		return
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	for s := range b.subscribers {
+		if len(s.types) > 0 && !s.types[e.Type] {
+			continue
+		}
+
+		select {
+		case s.c <- e:
+		default:
+			log.Warnf("event subscriber buffer full, dropping event: %s", e.Type)
+		}
+	}
+}
+
+// Subscribe creates a subscription receiving the events of the given
+// types, or all events, when no type is specified. When bufferSize is
+// not positive, DefaultBufferSize is used.
+func (b *Bus) Subscribe(bufferSize int, types ...string) *Subscription {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	c := make(chan *Event, bufferSize)
+	s := &Subscription{C: c, c: c, bus: b}
+	if len(types) > 0 {
+		s.types = make(map[string]bool)
+		for _, t := range types {
+			s.types[t] = true
+		}
+	}
+
+	b.mx.Lock()
+	b.subscribers[s] = struct{}{}
+	b.mx.Unlock()
+	return s
+}
+
+// Close stops receiving events, and closes the channel of the
+// subscription.
+func (s *Subscription) Close() {
+	s.bus.mx.Lock()
+	defer s.bus.mx.Unlock()
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+	delete(s.bus.subscribers, s)
+	close(s.c)
+}
+
+// NewWebhook creates a sink that posts the events of the given types,
+// or all events, to the URL, one event per request. The failed requests
+// are logged and not retried.
+func NewWebhook(b *Bus, url string, types ...string) *Webhook {
+	w := &Webhook{
+		url:          url,
+		client:       &http.Client{Timeout: webhookTimeout},
+		subscription: b.Subscribe(DefaultBufferSize, types...),
+		done:         make(chan struct{}),
+	}
+
+	go w.run()
+	return w
+}
+
+func (w *Webhook) send(e *Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	rsp, err := w.client.Post(w.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status from the event webhook: %d", rsp.StatusCode)
+	}
+
+	return nil
+}
+
+func (w *Webhook) run() {
+	defer close(w.done)
+	for e := range w.subscription.C {
+		if err := w.send(e); err != nil {
+			log.Errorf("error while sending event %s: %v", e.Type, err)
+		}
+	}
+}
+
+// Close stops the webhook, after sending the already received events.
+func (w *Webhook) Close() {
+	w.subscription.Close()
+	<-w.done
+}
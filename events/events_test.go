@@ -0,0 +1,81 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNilBus(t *testing.T) {
+	var b *Bus
+	b.Publish(&Event{Type: TypeRouteTableUpdated})
+}
+
+func TestSubscribeByType(t *testing.T) {
+	b := NewBus()
+	all := b.Subscribe(0)
+	routes := b.Subscribe(0, TypeRouteTableUpdated)
+
+	b.Publish(&Event{Type: TypeRouteTableUpdated})
+	b.Publish(&Event{Type: TypeCertificateReloaded})
+
+	if len(all.C) != 2 || len(routes.C) != 1 {
+		t.Fatal("invalid number of events received", len(all.C), len(routes.C))
+	}
+
+	if e := <-routes.C; e.Type != TypeRouteTableUpdated || e.Time.IsZero() {
+		t.Error("invalid event", e)
+	}
+
+	routes.Close()
+	routes.Close()
+	b.Publish(&Event{Type: TypeRouteTableUpdated})
+	if _, ok := <-routes.C; ok {
+		t.Error("failed to close subscription")
+	}
+}
+
+func TestDropsWhenFull(t *testing.T) {
+	b := NewBus()
+	s := b.Subscribe(1)
+	b.Publish(&Event{Type: "foo"})
+	b.Publish(&Event{Type: "bar"})
+	if len(s.C) != 1 {
+		t.Fatal("invalid number of events", len(s.C))
+	}
+
+	if e := <-s.C; e.Type != "foo" {
+		t.Error("invalid event", e.Type)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	received := make(chan *Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+
+		received <- &e
+	}))
+	defer server.Close()
+
+	b := NewBus()
+	w := NewWebhook(b, server.URL, TypeBackendUnhealthy)
+	defer w.Close()
+
+	b.Publish(&Event{Type: TypeRouteTableUpdated})
+	b.Publish(&Event{Type: TypeBackendUnhealthy, Data: map[string]interface{}{"backend": "foo"}})
+
+	select {
+	case e := <-received:
+		if e.Type != TypeBackendUnhealthy || e.Data["backend"] != "foo" {
+			t.Error("invalid event", e)
+		}
+	case <-time.After(time.Second):
+		t.Error("event not received")
+	}
+}
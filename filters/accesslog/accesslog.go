@@ -0,0 +1,101 @@
+/*
+Package accesslog provides filters to control the access log of the
+requests of individual routes.
+
+The disableAccessLog filter turns off the access log for the route:
+
+    healthcheck: Path("/healthz") -> disableAccessLog() -> <shunt>;
+
+The enableAccessLog filter turns on the access log for the route, even
+when the request would be excluded or sampled out by the global access
+log settings. Optionally, it accepts status code prefixes, to log only
+the responses whose status code starts with one of them, e.g. the
+following logs only the 4xx responses and 500:
+
+    api: Path("/api") -> enableAccessLog(4, 500) -> "https://api.example.org";
+
+When multiple of these filters are set in a route, the last one takes
+effect.
+*/
+package accesslog
+
+import (
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/logging"
+)
+
+const (
+	DisableAccessLogName = "disableAccessLog"
+	EnableAccessLogName  = "enableAccessLog"
+)
+
+type spec struct {
+	enable bool
+}
+
+type filter struct {
+	control logging.AccessLogControl
+}
+
+// NewDisableAccessLog creates a filter specification whose filter
+// instances turn off the access log for the requests of the route.
+func NewDisableAccessLog() filters.Spec { return &spec{} }
+
+// NewEnableAccessLog creates a filter specification whose filter
+// instances turn on the access log for the requests of the route,
+// optionally restricted to the responses whose status code starts with
+// one of the prefixes passed in as arguments.
+func NewEnableAccessLog() filters.Spec { return &spec{enable: true} }
+
+func (s *spec) Name() string {
+	if s.enable {
+		return EnableAccessLogName
+	}
+
+	return DisableAccessLogName
+}
+
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if !s.enable {
+		if len(args) != 0 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		return &filter{}, nil
+	}
+
+	f := &filter{control: logging.AccessLogControl{Enabled: true}}
+	for _, a := range args {
+		var p int
+		switch v := a.(type) {
+		case int:
+			p = v
+		case float64:
+			p = int(v)
+			if float64(p) != v {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+		default:
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		if p <= 0 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.control.StatusPrefixes = append(f.control.StatusPrefixes, p)
+	}
+
+	return f, nil
+}
+
+// the access log settings are passed to the logging handler in the
+// context of the incoming request
+func (f *filter) Request(ctx filters.FilterContext) {
+	if d := logging.ProxyDetailsFromContext(ctx.Request().Context()); d != nil {
+		c := f.control
+		d.AccessLog = &c
+	}
+}
+
+func (f *filter) Response(filters.FilterContext) {}
@@ -0,0 +1,78 @@
+package accesslog
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/logging"
+)
+
+func TestCreateFilter(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		spec     filters.Spec
+		args     []interface{}
+		fail     bool
+		expected logging.AccessLogControl
+	}{{
+		title: "disable",
+		spec:  NewDisableAccessLog(),
+	}, {
+		title: "disable with args",
+		spec:  NewDisableAccessLog(),
+		args:  []interface{}{5},
+		fail:  true,
+	}, {
+		title:    "enable",
+		spec:     NewEnableAccessLog(),
+		expected: logging.AccessLogControl{Enabled: true},
+	}, {
+		title:    "enable with prefixes",
+		spec:     NewEnableAccessLog(),
+		args:     []interface{}{float64(4), 500},
+		expected: logging.AccessLogControl{Enabled: true, StatusPrefixes: []int{4, 500}},
+	}, {
+		title: "enable with invalid prefix",
+		spec:  NewEnableAccessLog(),
+		args:  []interface{}{"5xx"},
+		fail:  true,
+	}, {
+		title: "enable with fraction",
+		spec:  NewEnableAccessLog(),
+		args:  []interface{}{4.5},
+		fail:  true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			f, err := test.spec.CreateFilter(test.args)
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d := &logging.ProxyDetails{}
+			r, _ := http.NewRequest("GET", "https://www.example.org", nil)
+			r = r.WithContext(logging.ContextWithProxyDetails(r.Context(), d))
+			f.Request(&filtertest.Context{FRequest: r})
+
+			if d.AccessLog == nil || !reflect.DeepEqual(*d.AccessLog, test.expected) {
+				t.Error("invalid access log control", d.AccessLog, test.expected)
+			}
+		})
+	}
+}
+
+func TestNoProxyDetails(t *testing.T) {
+	f, _ := NewDisableAccessLog().CreateFilter(nil)
+	r, _ := http.NewRequest("GET", "https://www.example.org", nil)
+	f.Request(&filtertest.Context{FRequest: r})
+}
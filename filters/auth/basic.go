@@ -13,23 +13,45 @@ const (
 	DefaultRealmName          = "Basic Realm"
 )
 
-type basicSpec struct{}
+type basicSpec struct {
+	cache *Cache
+}
 
 type basic struct {
 	authenticator   *auth.BasicAuth
 	realmDefinition string
+	cache           *Cache
+	cacheScope      string
 }
 
 func NewBasicAuth() *basicSpec {
 	return &basicSpec{}
 }
 
+// NewBasicAuthWithCache creates the basicAuth filter specification,
+// whose filters cache the results of checking the credentials, to avoid
+// verifying the password hashes on every request.
+func NewBasicAuthWithCache(c *Cache) *basicSpec {
+	return &basicSpec{cache: c}
+}
+
 //We do not touch response at all
 func (a *basic) Response(filters.FilterContext) {}
 
+func (a *basic) checkAuth(r *http.Request) string {
+	credentials := r.Header.Get("Authorization")
+	if d, ok := a.cache.Get(a.cacheScope, credentials); ok {
+		return d.Subject
+	}
+
+	username := a.authenticator.CheckAuth(r)
+	a.cache.Set(a.cacheScope, credentials, Decision{Allowed: username != "", Subject: username}, 0)
+	return username
+}
+
 // check basic auth
 func (a *basic) Request(ctx filters.FilterContext) {
-	username := a.authenticator.CheckAuth(ctx.Request())
+	username := a.checkAuth(ctx.Request())
 
 	if username == "" {
 		header := http.Header{}
@@ -69,6 +91,8 @@ func (spec *basicSpec) CreateFilter(config []interface{}) (filters.Filter, error
 	return &basic{
 		authenticator:   authenticator,
 		realmDefinition: ForceBasicAuthHeaderValue + `"` + realmName + `"`,
+		cache:           spec.cache,
+		cacheScope:      Name + ":" + configFile,
 	}, nil
 }
 
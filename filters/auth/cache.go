@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheNegativeTTL is the default time of caching the
+	// rejected credentials.
+	DefaultCacheNegativeTTL = 10 * time.Second
+
+	// DefaultCacheMaxEntries is the default maximum number of the
+	// cached decisions.
+	DefaultCacheMaxEntries = 10000
+)
+
+// CacheOptions for creating an auth decision cache.
+type CacheOptions struct {
+
+	// The time of caching the accepted credentials. Required.
+	TTL time.Duration
+
+	// The time of caching the rejected credentials. 0 means the
+	// default, 10s, while a negative value disables the negative
+	// caching.
+	NegativeTTL time.Duration
+
+	// The maximum number of the cached decisions. When exceeded, the
+	// least recently used decisions are evicted. Default: 10000.
+	MaxEntries int
+}
+
+// Decision is the result of validating a credential, e.g. a token.
+type Decision struct {
+
+	// Tells whether the credential was accepted.
+	Allowed bool
+
+	// The identity of the authenticated client, e.g. the user name,
+	// when known.
+	Subject string
+}
+
+type cacheKey struct {
+	scope string
+	hash  [sha256.Size]byte
+}
+
+type cacheEntry struct {
+	key      cacheKey
+	decision Decision
+	expires  time.Time
+}
+
+// Cache stores the auth decisions, so that the repeated requests with
+// the same credentials don't need to be validated again, e.g. by an
+// identity provider, until the decision expires. The credentials are
+// stored only as their SHA-256 hash. The cache can be shared between
+// filters, the decisions are separated by the scope passed in by the
+// filters, e.g. the validation endpoint or the password file.
+type Cache struct {
+	options CacheOptions
+	mx      sync.Mutex
+	entries map[cacheKey]*list.Element
+	lru     *list.List
+	now     func() time.Time
+}
+
+// NewCache creates an auth decision cache.
+func NewCache(o CacheOptions) *Cache {
+	if o.NegativeTTL == 0 {
+		o.NegativeTTL = DefaultCacheNegativeTTL
+	}
+
+	if o.MaxEntries <= 0 {
+		o.MaxEntries = DefaultCacheMaxEntries
+	}
+
+	return &Cache{
+		options: o,
+		entries: make(map[cacheKey]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+func newCacheKey(scope, credential string) cacheKey {
+	return cacheKey{scope: scope, hash: sha256.Sum256([]byte(credential))}
+}
+
+// Get returns the cached decision about a credential in a scope.
+func (c *Cache) Get(scope, credential string) (Decision, bool) {
+	if c == nil || credential == "" {
+		return Decision{}, false
+	}
+
+	key := newCacheKey(scope, credential)
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return Decision{}, false
+	}
+
+	entry := e.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(e)
+		return Decision{}, false
+	}
+
+	c.lru.MoveToFront(e)
+	return entry.decision, true
+}
+
+// Set stores a decision about a credential in a scope. The accepted
+// credentials are stored for the TTL, while the rejected ones for the
+// negative TTL. The ttl argument, when positive and shorter, overrides
+// the configured one, e.g. to not cache a token beyond its expiry.
+func (c *Cache) Set(scope, credential string, d Decision, ttl time.Duration) {
+	if c == nil || credential == "" {
+		return
+	}
+
+	maxTTL := c.options.TTL
+	if !d.Allowed {
+		maxTTL = c.options.NegativeTTL
+	}
+
+	if ttl <= 0 || ttl > maxTTL {
+		ttl = maxTTL
+	}
+
+	if ttl <= 0 {
+		return
+	}
+
+	key := newCacheKey(scope, credential)
+	entry := &cacheEntry{key: key, decision: d, expires: c.now().Add(ttl)}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		e.Value = entry
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.options.MaxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// Expects the lock to be held.
+func (c *Cache) remove(e *list.Element) {
+	c.lru.Remove(e)
+	delete(c.entries, e.Value.(*cacheEntry).key)
+}
+
+// Len returns the number of the cached decisions, including the
+// expired ones not evicted yet.
+func (c *Cache) Len() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.lru.Len()
+}
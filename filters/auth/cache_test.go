@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func testCache(o CacheOptions) (*Cache, *time.Time) {
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := NewCache(o)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestCacheTTL(t *testing.T) {
+	c, now := testCache(CacheOptions{TTL: time.Minute, NegativeTTL: 10 * time.Second})
+
+	c.Set("scope", "valid-token", Decision{Allowed: true, Subject: "jdoe"}, 0)
+	c.Set("scope", "invalid-token", Decision{}, 0)
+
+	if d, ok := c.Get("scope", "valid-token"); !ok || !d.Allowed || d.Subject != "jdoe" {
+		t.Error("failed to get the decision", d, ok)
+	}
+
+	if d, ok := c.Get("scope", "invalid-token"); !ok || d.Allowed {
+		t.Error("failed to get the negative decision", d, ok)
+	}
+
+	if _, ok := c.Get("other-scope", "valid-token"); ok {
+		t.Error("unexpected decision from another scope")
+	}
+
+	*now = now.Add(15 * time.Second)
+	if _, ok := c.Get("scope", "invalid-token"); ok {
+		t.Error("failed to expire the negative decision")
+	}
+
+	if _, ok := c.Get("scope", "valid-token"); !ok {
+		t.Error("unexpected expiry")
+	}
+
+	*now = now.Add(time.Minute)
+	if _, ok := c.Get("scope", "valid-token"); ok {
+		t.Error("failed to expire the decision")
+	}
+
+	if c.Len() != 0 {
+		t.Error("failed to remove the expired decisions", c.Len())
+	}
+}
+
+func TestCacheShorterTTL(t *testing.T) {
+	c, now := testCache(CacheOptions{TTL: time.Hour})
+	c.Set("scope", "token", Decision{Allowed: true}, time.Minute)
+	*now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("scope", "token"); ok {
+		t.Error("failed to apply the shorter TTL")
+	}
+}
+
+func TestCacheDisabledNegative(t *testing.T) {
+	c, _ := testCache(CacheOptions{TTL: time.Hour, NegativeTTL: -1})
+	c.Set("scope", "token", Decision{}, 0)
+	if _, ok := c.Get("scope", "token"); ok {
+		t.Error("unexpected negative decision")
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c, _ := testCache(CacheOptions{TTL: time.Hour, MaxEntries: 2})
+	c.Set("scope", "token1", Decision{Allowed: true}, 0)
+	c.Set("scope", "token2", Decision{Allowed: true}, 0)
+
+	// makes token1 recently used
+	c.Get("scope", "token1")
+	c.Set("scope", "token3", Decision{Allowed: true}, 0)
+
+	if _, ok := c.Get("scope", "token2"); ok {
+		t.Error("failed to evict the least recently used decision")
+	}
+
+	for _, token := range []string{"token1", "token3"} {
+		if _, ok := c.Get("scope", token); !ok {
+			t.Error("unexpected eviction", token)
+		}
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	var c *Cache
+	c.Set("scope", "token", Decision{Allowed: true}, 0)
+	if _, ok := c.Get("scope", "token"); ok {
+		t.Error("unexpected decision")
+	}
+}
+
+func TestBasicAuthWithCache(t *testing.T) {
+	c, _ := testCache(CacheOptions{TTL: time.Minute})
+	f, err := NewBasicAuthWithCache(c).CreateFilter([]interface{}{"testdata/htpasswd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ti := range []struct {
+		password string
+		served   bool
+	}{
+		{"myPassword", false},
+		{"wrongPassword", true},
+		{"myPassword", false},
+		{"wrongPassword", true},
+	} {
+		req, _ := http.NewRequest("GET", "https://www.example.org/", nil)
+		req.SetBasicAuth("myName", ti.password)
+		ctx := &filtertest.Context{FRequest: req}
+		f.Request(ctx)
+		if ctx.Served() != ti.served {
+			t.Errorf("%s: expected served: %t", ti.password, ti.served)
+		}
+	}
+
+	if c.Len() != 2 {
+		t.Error("failed to cache the decisions", c.Len())
+	}
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/tlsconfig"
+)
+
+const (
+	ClientCertAuthName    = "clientCertAuth"
+	ClientCertHeadersName = "clientCertHeaders"
+
+	ClientCertSubjectHeader     = "X-Client-Cert-Subject"
+	ClientCertIssuerHeader      = "X-Client-Cert-Issuer"
+	ClientCertSerialHeader      = "X-Client-Cert-Serial"
+	ClientCertFingerprintHeader = "X-Client-Cert-Fingerprint"
+)
+
+type (
+	clientCertAuthSpec    struct{}
+	clientCertHeadersSpec struct{}
+
+	clientCertAuth struct {
+		names []*regexp.Regexp
+	}
+
+	clientCertHeaders struct{}
+)
+
+// NewClientCertAuth creates a filter specification, whose instances
+// reject the requests without a verified client certificate with 401,
+// and the requests whose client certificate doesn't match any of the
+// regular expression arguments with 403. The expressions are matched
+// against the common name of the subject, and the DNS, email and URI
+// subject alternative names. Without arguments, any verified client
+// certificate is accepted.
+//
+// Eskip example:
+//
+// 	clientCertAuth(/^orders[.]example[.]org$/) -> "https://www.example.org";
+//
+func NewClientCertAuth() filters.Spec { return &clientCertAuthSpec{} }
+
+func (s *clientCertAuthSpec) Name() string { return ClientCertAuthName }
+
+func (s *clientCertAuthSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	f := &clientCertAuth{}
+	for _, a := range args {
+		s, ok := a.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		rx, err := regexp.Compile(s)
+		if err != nil {
+			return nil, err
+		}
+
+		f.names = append(f.names, rx)
+	}
+
+	return f, nil
+}
+
+func (f *clientCertAuth) matches(names []string) bool {
+	if len(f.names) == 0 {
+		return true
+	}
+
+	for _, n := range names {
+		for _, rx := range f.names {
+			if rx.MatchString(n) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (f *clientCertAuth) Request(ctx filters.FilterContext) {
+	c := tlsconfig.ClientCertificate(ctx.Request())
+	if c == nil {
+		ctx.Serve(&http.Response{StatusCode: http.StatusUnauthorized})
+		return
+	}
+
+	if !f.matches(tlsconfig.ClientCertificateNames(c)) {
+		ctx.Serve(&http.Response{StatusCode: http.StatusForbidden})
+	}
+}
+
+func (f *clientCertAuth) Response(filters.FilterContext) {}
+
+// NewClientCertHeaders creates a filter specification, whose instances
+// forward the verified client certificate to the backend in the
+// X-Client-Cert-Subject, X-Client-Cert-Issuer, X-Client-Cert-Serial
+// and X-Client-Cert-Fingerprint request headers. The fingerprint is
+// the hex encoded SHA-256 hash of the certificate. The same headers
+// sent by the client are always removed.
+//
+// Eskip example:
+//
+// 	clientCertHeaders() -> "https://www.example.org";
+//
+func NewClientCertHeaders() filters.Spec { return &clientCertHeadersSpec{} }
+
+func (s *clientCertHeadersSpec) Name() string { return ClientCertHeadersName }
+
+func (s *clientCertHeadersSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &clientCertHeaders{}, nil
+}
+
+func (f *clientCertHeaders) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	for _, h := range []string{
+		ClientCertSubjectHeader,
+		ClientCertIssuerHeader,
+		ClientCertSerialHeader,
+		ClientCertFingerprintHeader,
+	} {
+		r.Header.Del(h)
+	}
+
+	c := tlsconfig.ClientCertificate(r)
+	if c == nil {
+		return
+	}
+
+	fingerprint := sha256.Sum256(c.Raw)
+	r.Header.Set(ClientCertSubjectHeader, c.Subject.String())
+	r.Header.Set(ClientCertIssuerHeader, c.Issuer.String())
+	r.Header.Set(ClientCertSerialHeader, c.SerialNumber.String())
+	r.Header.Set(ClientCertFingerprintHeader, hex.EncodeToString(fingerprint[:]))
+}
+
+func (f *clientCertHeaders) Response(filters.FilterContext) {}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func requestWithClientCert() *http.Request {
+	spiffeID, _ := url.Parse("spiffe://example.org/orders")
+	cert := &x509.Certificate{
+		Raw:          []byte("certificate"),
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "orders.example.org"},
+		Issuer:       pkix.Name{CommonName: "Example CA"},
+		DNSNames:     []string{"orders.internal"},
+		URIs:         []*url.URL{spiffeID},
+	}
+
+	r, _ := http.NewRequest("GET", "https://www.example.org/", nil)
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	return r
+}
+
+func TestClientCertAuth(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		args     []interface{}
+		noCert   bool
+		expected int
+	}{{
+		title:    "no certificate",
+		noCert:   true,
+		expected: http.StatusUnauthorized,
+	}, {
+		title: "any certificate",
+	}, {
+		title: "matching common name",
+		args:  []interface{}{"^orders[.]example[.]org$"},
+	}, {
+		title: "matching SPIFFE ID",
+		args:  []interface{}{"^billing$", "^spiffe://example[.]org/"},
+	}, {
+		title:    "not matching",
+		args:     []interface{}{"^billing[.]example[.]org$"},
+		expected: http.StatusForbidden,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			f, err := NewClientCertAuth().CreateFilter(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := requestWithClientCert()
+			if test.noCert {
+				r.TLS = nil
+			}
+
+			ctx := &filtertest.Context{FRequest: r}
+			f.Request(ctx)
+			if test.expected == 0 {
+				if ctx.Served() {
+					t.Error("unexpected response", ctx.Response().StatusCode)
+				}
+
+				return
+			}
+
+			if !ctx.Served() || ctx.Response().StatusCode != test.expected {
+				t.Error("failed to reject the request")
+			}
+		})
+	}
+
+	if _, err := NewClientCertAuth().CreateFilter([]interface{}{"("}); err == nil {
+		t.Error("failed to fail with an invalid expression")
+	}
+}
+
+func TestClientCertHeaders(t *testing.T) {
+	if _, err := NewClientCertHeaders().CreateFilter([]interface{}{"foo"}); err == nil {
+		t.Error("failed to fail")
+	}
+
+	f, err := NewClientCertHeaders().CreateFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := requestWithClientCert()
+	r.Header.Set(ClientCertSubjectHeader, "CN=spoofed")
+	f.Request(&filtertest.Context{FRequest: r})
+	for h, v := range map[string]string{
+		ClientCertSubjectHeader:     "CN=orders.example.org",
+		ClientCertIssuerHeader:      "CN=Example CA",
+		ClientCertSerialHeader:      "42",
+		ClientCertFingerprintHeader: "03d66dd08835c1ca3f128cceacd1f31ac94163096b20f445ae84285bc0832d72",
+	} {
+		if got := r.Header.Get(h); got != v {
+			t.Errorf("invalid header %s, got: %s, expected: %s", h, got, v)
+		}
+	}
+
+	r = requestWithClientCert()
+	r.TLS = nil
+	r.Header.Set(ClientCertSubjectHeader, "CN=spoofed")
+	f.Request(&filtertest.Context{FRequest: r})
+	if r.Header.Get(ClientCertSubjectHeader) != "" {
+		t.Error("failed to remove the client header")
+	}
+}
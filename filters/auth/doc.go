@@ -14,5 +14,41 @@ Usage
 
 	basicAuth("/path/to/htpasswd")
 	basicAuth("/path/to/htpasswd", "My Website")
+
+Caching
+
+Verifying the password hashes on every request can be expensive. With
+the -auth-cache-ttl flag, the auth decisions are cached, keyed by the
+SHA-256 hash of the credentials, and the rejected credentials are cached,
+too, for a shorter time, see the -auth-cache-negative-ttl flag:
+
+	skipper -auth-cache-ttl 5m -auth-cache-negative-ttl 10s
+
+The cache is shared by the filters, and the decisions are separated by a
+scope, e.g. the password file of the basicAuth filter. The changes of the
+password files take effect after the TTL of the cached decisions. The
+filters validating tokens with an identity provider can use the same
+cache, through the Get and Set methods of the Cache type, limiting the
+TTL to the expiry of the tokens.
+
+Client Certificates
+
+When the client authentication is enabled on the TLS listener, with the
+-tls-client-auth and -tls-client-ca flags, the clientCertAuth filter
+rejects the requests without a verified client certificate with 401, and
+the requests whose certificate doesn't match any of the regular
+expression arguments with 403. The expressions are matched against the
+common name of the subject, and the DNS, email and URI subject
+alternative names:
+
+	clientCertAuth()
+	clientCertAuth(/^orders[.]example[.]org$/, /^billing[.]example[.]org$/)
+
+The clientCertHeaders filter forwards the verified client certificate to
+the backend, in the X-Client-Cert-Subject, X-Client-Cert-Issuer,
+X-Client-Cert-Serial and X-Client-Cert-Fingerprint headers. The same
+headers sent by the client are always removed:
+
+	clientCertHeaders()
 */
 package auth
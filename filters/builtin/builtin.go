@@ -5,10 +5,13 @@ package builtin
 
 import (
 	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/accesslog"
 	"github.com/zalando/skipper/filters/auth"
 	"github.com/zalando/skipper/filters/cookie"
 	"github.com/zalando/skipper/filters/diag"
 	"github.com/zalando/skipper/filters/flowid"
+	"github.com/zalando/skipper/filters/securityheaders"
+	"github.com/zalando/skipper/filters/servertiming"
 	"github.com/zalando/skipper/filters/tee"
 )
 
@@ -76,13 +79,20 @@ func MakeRegistry() filters.Registry {
 		diag.NewBackendLatency(),
 		diag.NewBackendBandwidth(),
 		diag.NewBackendChunks(),
+		diag.NewCapture(),
 		tee.NewTee(),
 		tee.NewTeeDeprecated(),
 		tee.NewTeeNoFollow(),
 		auth.NewBasicAuth(),
+		auth.NewClientCertAuth(),
+		auth.NewClientCertHeaders(),
 		cookie.NewRequestCookie(),
 		cookie.NewResponseCookie(),
 		cookie.NewJSCookie(),
+		accesslog.NewDisableAccessLog(),
+		accesslog.NewEnableAccessLog(),
+		servertiming.New(),
+		securityheaders.New(),
 	} {
 		r.Register(s)
 	}
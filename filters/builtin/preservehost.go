@@ -24,6 +24,13 @@ type spec struct{}
 
 type filter bool
 
+// the filter prepared for a route, with the host of the backend already
+// known
+type preparedFilter struct {
+	preserve    bool
+	backendHost string
+}
+
 // Returns a filter specification whose filter instances are used to override
 // the `proxyPreserveHost` behavior for individual routes.
 //
@@ -50,6 +57,12 @@ func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
 	}
 }
 
+// PrepareRoute returns a filter that doesn't need to parse the backend
+// address of the route for every request.
+func (preserve filter) PrepareRoute(ri filters.RouteInfo) (filters.Filter, error) {
+	return &preparedFilter{preserve: bool(preserve), backendHost: ri.Host}, nil
+}
+
 func (preserve filter) Response(_ filters.FilterContext) {}
 
 func (preserve filter) Request(ctx filters.FilterContext) {
@@ -59,9 +72,19 @@ func (preserve filter) Request(ctx filters.FilterContext) {
 		return
 	}
 
-	if preserve && ctx.OutgoingHost() == u.Host {
+	setOutgoingHost(ctx, bool(preserve), u.Host)
+}
+
+func (f *preparedFilter) Response(_ filters.FilterContext) {}
+
+func (f *preparedFilter) Request(ctx filters.FilterContext) {
+	setOutgoingHost(ctx, f.preserve, f.backendHost)
+}
+
+func setOutgoingHost(ctx filters.FilterContext, preserve bool, backendHost string) {
+	if preserve && ctx.OutgoingHost() == backendHost {
 		ctx.SetOutgoingHost(ctx.Request().Host)
 	} else if !preserve && ctx.OutgoingHost() == ctx.Request().Host {
-		ctx.SetOutgoingHost(u.Host)
+		ctx.SetOutgoingHost(backendHost)
 	}
 }
@@ -15,8 +15,10 @@
 package builtin
 
 import (
+	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/filters/filtertest"
 	"net/http"
+	"net/url"
 	"testing"
 )
 
@@ -137,5 +139,18 @@ func TestRequest(t *testing.T) {
 		if ctx.OutgoingHost() != ti.checkHost {
 			t.Error(ti.msg, ctx.OutgoingHost(), ti.checkHost)
 		}
+
+		u, _ := url.Parse(ti.backendUrl)
+		pf, err := f.(filters.RoutePreparer).PrepareRoute(filters.RouteInfo{Backend: ti.backendUrl, Scheme: u.Scheme, Host: u.Host})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx.FOutgoingHost = ti.currentOutgoing
+		ctx.FBackendUrl = ""
+		pf.Request(ctx)
+		if ctx.OutgoingHost() != ti.checkHost {
+			t.Error(ti.msg, "prepared", ctx.OutgoingHost(), ti.checkHost)
+		}
 	}
 }
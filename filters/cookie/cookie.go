@@ -31,6 +31,15 @@ Examples:
 
     // response cookie without HttpOnly:
     jsCookie("test-session-info", "abc-debug", 31536000, "change-only")
+
+The encrypt cookie filter encrypts the value of a cookie set by the
+backend, and decrypts it in the requests, with a keyring configured in
+the proxy, see the -secrets flag and the skipper/secrets package. It
+expects the name of the cookie and the name of the keyring, and
+optionally the max-age of the cookie, used when the cookie encrypted
+with a rotated key is set again:
+
+    encryptCookie("session", "cookies", 86400)
 */
 package cookie
 
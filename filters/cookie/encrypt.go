@@ -0,0 +1,190 @@
+package cookie
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/secrets"
+)
+
+const EncryptCookieFilterName = "encryptCookie"
+
+type encryptSpec struct {
+	secrets *secrets.Registry
+}
+
+type encryptFilter struct {
+	name    string
+	keyring *secrets.Keyring
+	ttl     time.Duration
+}
+
+// NewEncryptCookie creates a filter spec for encrypting a cookie set by
+// the backend, and decrypting it in the requests, with a keyring from
+// the secrets registry.
+//
+// The filters accept the name of the cookie, the name of the keyring,
+// and optionally the max-age of the cookie, in seconds. The cookies that
+// cannot be decrypted are removed from the requests. When the cookie in
+// the request was encrypted with a rotated key, and the backend doesn't
+// set it in the response, the filter sets it again, encrypted with the
+// active key, with the optional max-age, and the same directives as the
+// responseCookie filter.
+//
+// Name: encryptCookie
+func NewEncryptCookie(r *secrets.Registry) filters.Spec {
+	return &encryptSpec{secrets: r}
+}
+
+func (s *encryptSpec) Name() string { return EncryptCookieFilterName }
+
+func (s *encryptSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	name, ok := args[0].(string)
+	if !ok || name == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	keyringName, ok := args[1].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	keyring, ok := s.secrets.Get(keyringName)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &encryptFilter{name: name, keyring: keyring}
+	if len(args) == 3 {
+		ttl, ok := args[2].(float64)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.ttl = time.Duration(ttl) * time.Second
+	}
+
+	return f, nil
+}
+
+func (f *encryptFilter) stateBagKey() string {
+	return "filter::" + EncryptCookieFilterName + "::" + f.name
+}
+
+func (f *encryptFilter) encrypt(value string) (string, error) {
+	c, err := f.keyring.Encrypt([]byte(value))
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(c), nil
+}
+
+func (f *encryptFilter) decrypt(value string) (string, bool, error) {
+	c, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return "", false, err
+	}
+
+	p, rotated, err := f.keyring.Decrypt(c)
+	return string(p), rotated, err
+}
+
+// replaces the encrypted cookie in the Cookie header with its decrypted
+// value, and keeps the rest of the cookies unchanged
+func (f *encryptFilter) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	cookies := r.Cookies()
+	if len(cookies) == 0 {
+		return
+	}
+
+	var parts []string
+	for _, c := range cookies {
+		if c.Name != f.name {
+			parts = append(parts, c.Name+"="+c.Value)
+			continue
+		}
+
+		value, rotated, err := f.decrypt(c.Value)
+		if err != nil {
+			log.Debugf("failed to decrypt cookie %s: %v", f.name, err)
+			continue
+		}
+
+		if rotated {
+			ctx.StateBag()[f.stateBagKey()] = value
+		}
+
+		parts = append(parts, c.Name+"="+value)
+	}
+
+	r.Header.Del("Cookie")
+	if len(parts) > 0 {
+		r.Header.Set("Cookie", strings.Join(parts, "; "))
+	}
+}
+
+// encrypts the value of the cookie in the Set-Cookie headers, keeping
+// the directives unchanged
+func (f *encryptFilter) Response(ctx filters.FilterContext) {
+	rsp := ctx.Response()
+	var set bool
+	for i, h := range rsp.Header[SetCookieHttpHeader] {
+		pair, directives := h, ""
+		if j := strings.IndexByte(h, ';'); j >= 0 {
+			pair, directives = h[:j], h[j:]
+		}
+
+		nv := strings.SplitN(pair, "=", 2)
+		if len(nv) != 2 || strings.TrimSpace(nv[0]) != f.name {
+			continue
+		}
+
+		value, err := f.encrypt(strings.Trim(strings.TrimSpace(nv[1]), `"`))
+		if err != nil {
+			log.Errorf("failed to encrypt cookie %s: %v", f.name, err)
+			rsp.Header[SetCookieHttpHeader][i] = f.name + "=" + directives
+			continue
+		}
+
+		rsp.Header[SetCookieHttpHeader][i] = f.name + "=" + value + directives
+		set = true
+	}
+
+	plain, ok := ctx.StateBag()[f.stateBagKey()].(string)
+	if set || !ok {
+		return
+	}
+
+	value, err := f.encrypt(plain)
+	if err != nil {
+		log.Errorf("failed to encrypt cookie %s: %v", f.name, err)
+		return
+	}
+
+	var req = ctx.Request()
+	if ctx.OriginalRequest() != nil {
+		req = ctx.OriginalRequest()
+	}
+
+	c := &http.Cookie{
+		Name:     f.name,
+		Value:    value,
+		HttpOnly: true,
+		Secure:   true,
+		Domain:   extractDomainFromHost(req.Host),
+		Path:     "/",
+		MaxAge:   int(f.ttl.Seconds()),
+	}
+
+	rsp.Header.Add(SetCookieHttpHeader, c.String())
+}
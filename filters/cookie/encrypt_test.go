@@ -0,0 +1,143 @@
+package cookie
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/secrets"
+)
+
+type testSource []string
+
+func (s testSource) Keys() ([][]byte, error) {
+	var k [][]byte
+	for _, si := range s {
+		k = append(k, []byte(si))
+	}
+
+	return k, nil
+}
+
+func testRegistry(t *testing.T, keys ...string) *secrets.Registry {
+	k, err := secrets.NewKeyring(testSource(keys), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := secrets.NewRegistry()
+	r.Add("cookies", k)
+	return r
+}
+
+func createEncryptCookie(t *testing.T, r *secrets.Registry, args ...interface{}) filters.Filter {
+	f, err := NewEncryptCookie(r).CreateFilter(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+// encrypts a cookie value in a response, and returns the Set-Cookie
+// header
+func encryptedSetCookie(t *testing.T, f filters.Filter, value string) string {
+	rsp := &http.Response{Header: http.Header{
+		"Set-Cookie": []string{"session=" + value + "; Path=/app; HttpOnly", "other=bar"},
+	}}
+
+	r, _ := http.NewRequest("GET", "https://www.example.org", nil)
+	f.Response(&filtertest.Context{FRequest: r, FResponse: rsp, FStateBag: make(map[string]interface{})})
+	return rsp.Header["Set-Cookie"][0]
+}
+
+func TestEncryptCookieCreateFilter(t *testing.T) {
+	r := testRegistry(t, "current-key-0123456789")
+	defer r.Close()
+
+	for _, args := range [][]interface{}{
+		{"session"},
+		{"session", "foo"},
+		{"", "cookies"},
+		{"session", "cookies", "3600"},
+		{"session", "cookies", 3600.0, "foo"},
+	} {
+		if _, err := NewEncryptCookie(r).CreateFilter(args); err == nil {
+			t.Errorf("failed to fail: %v", args)
+		}
+	}
+}
+
+func TestEncryptCookie(t *testing.T) {
+	r := testRegistry(t, "current-key-0123456789")
+	defer r.Close()
+	f := createEncryptCookie(t, r, "session", "cookies")
+
+	h := encryptedSetCookie(t, f, "abc")
+	if strings.Contains(h, "abc") || !strings.HasPrefix(h, "session=") || !strings.HasSuffix(h, "; Path=/app; HttpOnly") {
+		t.Fatalf("failed to encrypt the cookie: %s", h)
+	}
+
+	value := strings.TrimPrefix(h[:strings.IndexByte(h, ';')], "session=")
+	req, _ := http.NewRequest("GET", "https://www.example.org", nil)
+	req.Header.Set("Cookie", "other=bar; session="+value)
+	ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+	if c := req.Header.Get("Cookie"); c != "other=bar; session=abc" {
+		t.Errorf("failed to decrypt the cookie: %s", c)
+	}
+
+	if len(ctx.FStateBag) != 0 {
+		t.Error("unexpected rotation")
+	}
+}
+
+func TestEncryptCookieInvalid(t *testing.T) {
+	r := testRegistry(t, "current-key-0123456789")
+	defer r.Close()
+	f := createEncryptCookie(t, r, "session", "cookies")
+
+	req, _ := http.NewRequest("GET", "https://www.example.org", nil)
+	req.Header.Set("Cookie", "session=abc; other=bar")
+	f.Request(&filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})})
+	if c := req.Header.Get("Cookie"); c != "other=bar" {
+		t.Errorf("failed to remove the invalid cookie: %s", c)
+	}
+}
+
+func TestEncryptCookieRotation(t *testing.T) {
+	old := testRegistry(t, "old-key-0123456789")
+	defer old.Close()
+	h := encryptedSetCookie(t, createEncryptCookie(t, old, "session", "cookies"), "abc")
+	value := strings.TrimPrefix(h[:strings.IndexByte(h, ';')], "session=")
+
+	rotated := testRegistry(t, "new-key-0123456789", "old-key-0123456789")
+	defer rotated.Close()
+	f := createEncryptCookie(t, rotated, "session", "cookies", 3600.0)
+
+	req, _ := http.NewRequest("GET", "https://www.example.org", nil)
+	req.Header.Set("Cookie", "session="+value)
+	rsp := &http.Response{Header: make(http.Header)}
+	ctx := &filtertest.Context{FRequest: req, FResponse: rsp, FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+	if c := req.Header.Get("Cookie"); c != "session=abc" {
+		t.Fatalf("failed to decrypt the cookie: %s", c)
+	}
+
+	f.Response(ctx)
+	setCookie := rsp.Header.Get("Set-Cookie")
+	if !strings.HasPrefix(setCookie, "session=") || strings.Contains(setCookie, value) || !strings.Contains(setCookie, "Max-Age=3600") {
+		t.Fatalf("failed to set the re-encrypted cookie: %s", setCookie)
+	}
+
+	current := testRegistry(t, "new-key-0123456789")
+	defer current.Close()
+	reencrypted := strings.TrimPrefix(setCookie[:strings.IndexByte(setCookie, ';')], "session=")
+	req.Header.Set("Cookie", "session="+reencrypted)
+	createEncryptCookie(t, current, "session", "cookies").Request(&filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})})
+	if c := req.Header.Get("Cookie"); c != "session=abc" {
+		t.Errorf("failed to decrypt the re-encrypted cookie: %s", c)
+	}
+}
@@ -0,0 +1,36 @@
+package diag
+
+import (
+	"github.com/zalando/skipper/capture"
+	"github.com/zalando/skipper/filters"
+)
+
+const CaptureName = "capture"
+
+type captureSpec struct{}
+
+type captureFilter struct{}
+
+// NewCapture creates a filter specification whose filter instances mark
+// the requests of the route for capturing, when skipper is started with
+// capturing enabled. See the capture package. Eskip example:
+//
+// 	* -> capture() -> "https://www.example.org";
+//
+func NewCapture() filters.Spec { return captureSpec{} }
+
+func (captureSpec) Name() string { return CaptureName }
+
+func (captureSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return captureFilter{}, nil
+}
+
+func (captureFilter) Request(ctx filters.FilterContext) {
+	capture.Enable(ctx.Request().Context())
+}
+
+func (captureFilter) Response(filters.FilterContext) {}
@@ -4,6 +4,9 @@ Package diag provides a set of network throttling filters for diagnostic purpose
 The filters enable adding artificial latency, limiting bandwidth or chunking responses with custom chunk size
 and delay. This throttling can be applied to the proxy responses or to the outgoing backend requests. An
 additional filter, randomContent, can be used to generate response with random text of specified length.
+
+The capture filter marks the requests of a route for recording the full request and response, when the
+capturing is enabled. See the capture package.
 */
 package diag
 
@@ -31,6 +31,14 @@ route. Filter instances are created while the route definitions are parsed and
 initialized, based on the specifications stored in the filter registry.
 Different filter instances can be created with different parameters.
 
+The work that depends only on the arguments, e.g. compiling regular
+expressions or parsing templates, should be done when the filter instance is
+created, and the invalid arguments should be reported as errors, so that the
+route is rejected when it is loaded, instead of failing the requests. When the
+work depends on the route, e.g. on its backend address, the filter can
+implement the RoutePreparer interface, and prepare it once when the route is
+loaded.
+
 
 Filtering and FilterContext
 
@@ -53,5 +61,17 @@ generating the default response.
 
 If a filter replaces the response body, it is the filter's responsibility to
 close the original body.
+
+
+Metrics of the Filters
+
+The filters can emit their own metrics, counters, timers and gauges, through
+the Metrics method of the context:
+
+    ctx.Metrics().IncCounter("myfilter.cache_hit")
+
+The proxy prefixes the keys with custom., so that they don't collide with its
+own metrics, e.g. custom.myfilter.cache_hit, and collects them with the same
+metrics implementation, that can be set in the options of the proxy.
 */
 package filters
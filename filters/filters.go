@@ -3,6 +3,7 @@ package filters
 import (
 	"errors"
 	"net/http"
+	"time"
 )
 
 // Context object providing state and information that is unique to a request.
@@ -71,6 +72,27 @@ type FilterContext interface {
 	// (The requestHeader filter automatically detects if the header name
 	// is 'Host' and calls this method.)
 	SetOutgoingHost(string)
+
+	// Provides the metrics that the filters can emit, e.g. to count
+	// the cache hits. The keys are prefixed by the implementation, so
+	// that they don't collide with the metrics of the proxy.
+	Metrics() Metrics
+}
+
+// Metrics allows the filters to emit their own metrics.
+type Metrics interface {
+
+	// Measures the duration since start.
+	MeasureSince(key string, start time.Time)
+
+	// Increments a counter by one.
+	IncCounter(key string)
+
+	// Increments a counter by n.
+	IncCounterBy(key string, n int64)
+
+	// Sets the value of a gauge.
+	UpdateGauge(key string, value float64)
 }
 
 // Filters are created by the Spec components, optionally using filter
@@ -88,6 +110,30 @@ type Filter interface {
 	Response(FilterContext)
 }
 
+// RouteInfo describes the route that a filter instance was created for.
+type RouteInfo struct {
+
+	// The id of the route.
+	Id string
+
+	// The backend address of the route, or empty, in case of shunt
+	// and loopback routes.
+	Backend string
+
+	// The scheme and the host of the backend address.
+	Scheme, Host string
+}
+
+// RoutePreparer can be implemented by the filters whose work depends on
+// the route they are used in, e.g. on its backend. PrepareRoute is
+// called once, when the route is loaded, so that the filter can compute
+// this work in advance, instead of repeating it for every request. The
+// returned filter is used in the route. When it fails, the route is
+// rejected.
+type RoutePreparer interface {
+	PrepareRoute(RouteInfo) (Filter, error)
+}
+
 // Spec objects are specifications for filters. When initializing the routes,
 // the Filter instances are created using the Spec objects found in the
 // registry.
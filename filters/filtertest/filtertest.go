@@ -21,6 +21,8 @@ package filtertest
 import (
 	"github.com/zalando/skipper/filters"
 	"net/http"
+	"sync"
+	"time"
 )
 
 // Noop filter, used to verify the filter name and the args in the route.
@@ -41,6 +43,15 @@ type Context struct {
 	FStateBag           map[string]interface{}
 	FBackendUrl         string
 	FOutgoingHost       string
+	FMetrics            *Metrics
+}
+
+// Metrics records the metrics emitted by the filters.
+type Metrics struct {
+	mx        sync.Mutex
+	Counters  map[string]int64
+	Gauges    map[string]float64
+	Durations map[string][]time.Duration
 }
 
 func (spec *Filter) Name() string                    { return spec.FilterName }
@@ -59,6 +70,15 @@ func (fc *Context) OriginalResponse() *http.Response    { return nil }
 func (fc *Context) BackendUrl() string                  { return fc.FBackendUrl }
 func (fc *Context) OutgoingHost() string                { return fc.FOutgoingHost }
 func (fc *Context) SetOutgoingHost(h string)            { fc.FOutgoingHost = h }
+
+// Metrics returns FMetrics, and creates it when not set.
+func (fc *Context) Metrics() filters.Metrics {
+	if fc.FMetrics == nil {
+		fc.FMetrics = &Metrics{}
+	}
+
+	return fc.FMetrics
+}
 func (fc *Context) Serve(resp *http.Response) {
 	fc.FServedWithResponse = true
 	fc.FResponse = resp
@@ -68,3 +88,37 @@ func (fc *Context) Serve(resp *http.Response) {
 func (spec *Filter) CreateFilter(config []interface{}) (filters.Filter, error) {
 	return &Filter{spec.FilterName, config}, nil
 }
+
+func (m *Metrics) MeasureSince(key string, start time.Time) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.Durations == nil {
+		m.Durations = make(map[string][]time.Duration)
+	}
+
+	m.Durations[key] = append(m.Durations[key], time.Since(start))
+}
+
+func (m *Metrics) IncCounter(key string) {
+	m.IncCounterBy(key, 1)
+}
+
+func (m *Metrics) IncCounterBy(key string, n int64) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.Counters == nil {
+		m.Counters = make(map[string]int64)
+	}
+
+	m.Counters[key] += n
+}
+
+func (m *Metrics) UpdateGauge(key string, value float64) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.Gauges == nil {
+		m.Gauges = make(map[string]float64)
+	}
+
+	m.Gauges[key] = value
+}
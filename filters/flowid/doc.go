@@ -18,6 +18,14 @@ not a valid flow id it is ignored and a new flow id is generated, overwriting th
 Any other string used for this parameter is ignored and trigger the same, default, behavior - to ignore any existing
 X-Flow-Id header.
 
+Correlation ID
+
+The flow id is the correlation ID of the request: it is written to the JSON access log as flow-id, the error logs
+of the proxy are tagged with it, and it is set on the trace spans as skipper.flow_id. When skipper is started with
+the -generate-flow-id flag, a flow id is generated for every request without a valid X-Flow-Id header, even if the
+route doesn't contain the flowId filter. Other filters can read the flow id of the current request with the
+FromContext function.
+
 Generators
 
 The Flow ID generation can follow any format. Skipper provides two Generator implementations - Standard and ULID. They
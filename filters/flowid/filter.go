@@ -3,6 +3,7 @@ package flowid
 import (
 	"fmt"
 	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/logging"
 	"log"
 	"strings"
 )
@@ -47,7 +48,7 @@ func NewFlowId(l int) (string, error) {
 // New creates a new instance of the flowId filter spec which uses the StandardGenerator.
 // To use another type of Generator use NewWithGenerator()
 func New() *flowIdSpec {
-	g, err := NewStandardGenerator(defaultLen)
+	g, err := NewStandardGenerator(DefaultLength)
 	if err != nil {
 		panic(err)
 	}
@@ -69,6 +70,7 @@ func (f *flowId) Request(fc filters.FilterContext) {
 	if f.reuseExisting {
 		flowId = r.Header.Get(HeaderName)
 		if f.generator.IsValid(flowId) {
+			setRequestID(fc, flowId)
 			return
 		}
 	}
@@ -76,11 +78,33 @@ func (f *flowId) Request(fc filters.FilterContext) {
 	flowId, err := f.generator.Generate()
 	if err == nil {
 		r.Header.Set(HeaderName, flowId)
+		setRequestID(fc, flowId)
 	} else {
 		log.Println(err)
 	}
 }
 
+// makes the flow id available for the access log, the traces and the
+// subsequent filters
+func setRequestID(fc filters.FilterContext, flowId string) {
+	if d := logging.ProxyDetailsFromContext(fc.Request().Context()); d != nil {
+		d.RequestID = flowId
+	}
+}
+
+// FromContext returns the correlation ID of the current request: the
+// same value that appears in the access log, the error logs and the
+// trace spans of the request. When it is not known by the proxy, e.g.
+// in tests, the value of the X-Flow-Id header is returned.
+func FromContext(fc filters.FilterContext) string {
+	r := fc.Request()
+	if id := logging.RequestIDFromContext(r.Context()); id != "" {
+		return id
+	}
+
+	return r.Header.Get(HeaderName)
+}
+
 // Response is No-Op in this filter
 func (_ *flowId) Response(filters.FilterContext) {}
 
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/logging"
 	"log"
 	"net/http"
 	"strings"
@@ -124,6 +125,24 @@ func TestFlowIdWithCustomGenerators(t *testing.T) {
 	}
 }
 
+func TestFlowIdFromContext(t *testing.T) {
+	fc := buildfilterContext(HeaderName, testFlowId)
+	if FromContext(fc) != testFlowId {
+		t.Error("failed to fall back to the header")
+	}
+
+	d := &logging.ProxyDetails{}
+	r := fc.Request()
+	fc = &filtertest.Context{FRequest: r.WithContext(logging.ContextWithProxyDetails(r.Context(), d))}
+	f, _ := testFlowIdSpec.CreateFilter(nil)
+	f.Request(fc)
+
+	flowId := fc.Request().Header.Get(HeaderName)
+	if flowId == testFlowId || d.RequestID != flowId || FromContext(fc) != flowId {
+		t.Error("failed to propagate the generated flow id", flowId, d.RequestID)
+	}
+}
+
 func buildfilterContext(headers ...string) filters.FilterContext {
 	r, _ := http.NewRequest("GET", "http://example.org", nil)
 	for i := 0; i < len(headers); i += 2 {
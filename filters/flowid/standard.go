@@ -12,7 +12,7 @@ const (
 	alphabetBitMask = 63
 	MaxLength       = 64
 	MinLength       = 8
-	defaultLen      = 16
+	DefaultLength   = 16
 )
 
 var (
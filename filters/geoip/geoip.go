@@ -0,0 +1,97 @@
+/*
+Package geoip provides a filter to pass the country and the autonomous
+system of the client to the backend, looked up in the GeoIP databases
+configured with the -geoip-db flag. See the skipper/geoip package.
+
+The geoip filter sets the X-Geoip-Country and the X-Geoip-Asn request
+headers, when the country or the ASN of the client is known, and removes
+them from the incoming request otherwise, so that the clients cannot set
+them. The names of the headers can be changed with the optional
+arguments, and an empty name disables the header:
+
+    api: Path("/api") -> geoip() -> "https://api.example.org";
+
+    api: Path("/api") -> geoip("X-Country", "") -> "https://api.example.org";
+
+The filter stores the record of the client in the state bag, too, for
+the subsequent filters, with the StateBagKey key.
+*/
+package geoip
+
+import (
+	"strconv"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/geoip"
+)
+
+const (
+	Name = "geoip"
+
+	// StateBagKey is the key of the state bag entry containing the
+	// geoip.Record of the client.
+	StateBagKey = "filter::" + Name
+
+	DefaultCountryHeader = "X-Geoip-Country"
+	DefaultASNHeader     = "X-Geoip-Asn"
+)
+
+type spec struct {
+	db *geoip.DB
+}
+
+type filter struct {
+	db            *geoip.DB
+	countryHeader string
+	asnHeader     string
+}
+
+// New creates a filter specification whose filter instances set the
+// country and the ASN of the client in the request headers.
+func New(db *geoip.DB) filters.Spec { return &spec{db: db} }
+
+func (s *spec) Name() string { return Name }
+
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) > 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	headers := []string{DefaultCountryHeader, DefaultASNHeader}
+	for i, a := range args {
+		h, ok := a.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		headers[i] = h
+	}
+
+	return &filter{
+		db:            s.db,
+		countryHeader: headers[0],
+		asnHeader:     headers[1],
+	}, nil
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	rec := f.db.LookupRequest(r)
+	ctx.StateBag()[StateBagKey] = rec
+
+	if f.countryHeader != "" {
+		r.Header.Del(f.countryHeader)
+		if rec.Country != "" {
+			r.Header.Set(f.countryHeader, rec.Country)
+		}
+	}
+
+	if f.asnHeader != "" {
+		r.Header.Del(f.asnHeader)
+		if rec.ASN != 0 {
+			r.Header.Set(f.asnHeader, strconv.FormatUint(uint64(rec.ASN), 10))
+		}
+	}
+}
+
+func (f *filter) Response(filters.FilterContext) {}
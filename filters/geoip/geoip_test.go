@@ -0,0 +1,101 @@
+package geoip
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/geoip"
+	"github.com/zalando/skipper/geoip/geoiptest"
+)
+
+func testDB(t *testing.T) (*geoip.DB, func()) {
+	dir, err := ioutil.TempDir("", "geoip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := filepath.Join(dir, "test.mmdb")
+	if err := geoiptest.WriteFile(f, "GeoLite2-Test", []geoiptest.Network{
+		{CIDR: "192.0.2.0/24", Country: "DE", ASN: 64496},
+	}); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	db, err := geoip.New(geoip.Options{Databases: []string{f}, ReloadInterval: -1})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCreateFilter(t *testing.T) {
+	for _, args := range [][]interface{}{
+		{42.0},
+		{"X-Country", "X-Asn", "X-Foo"},
+	} {
+		if _, err := New(nil).CreateFilter(args); err == nil {
+			t.Error("failed to fail", args)
+		}
+	}
+}
+
+func TestSetsHeaders(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	for _, ti := range []struct {
+		msg        string
+		args       []interface{}
+		remoteAddr string
+		country    string
+		asn        string
+	}{{
+		msg:        "default headers",
+		remoteAddr: "192.0.2.1:1234",
+		country:    "DE",
+		asn:        "64496",
+	}, {
+		msg:        "unknown client",
+		remoteAddr: "203.0.113.1:1234",
+	}, {
+		msg:        "disabled asn header",
+		args:       []interface{}{DefaultCountryHeader, ""},
+		remoteAddr: "192.0.2.1:1234",
+		country:    "DE",
+		asn:        "spoofed",
+	}} {
+		f, err := New(db).CreateFilter(ti.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r, _ := http.NewRequest("GET", "https://www.example.org", nil)
+		r.RemoteAddr = ti.remoteAddr
+		r.Header.Set(DefaultCountryHeader, "spoofed")
+		r.Header.Set(DefaultASNHeader, "spoofed")
+		ctx := &filtertest.Context{FRequest: r, FStateBag: make(map[string]interface{})}
+		f.Request(ctx)
+
+		if h := r.Header.Get(DefaultCountryHeader); h != ti.country {
+			t.Errorf("%s: invalid country header: %s", ti.msg, h)
+		}
+
+		if h := r.Header.Get(DefaultASNHeader); h != ti.asn {
+			t.Errorf("%s: invalid asn header: %s", ti.msg, h)
+		}
+
+		if rec, ok := ctx.StateBag()[StateBagKey].(geoip.Record); !ok || rec.Country != ti.country {
+			t.Errorf("%s: invalid state bag record: %v", ti.msg, rec)
+		}
+	}
+}
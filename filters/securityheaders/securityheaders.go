@@ -0,0 +1,47 @@
+/*
+Package securityheaders provides a filter to opt out from the proxy level
+security header policy, for individual routes.
+
+The security header policy is set with the skipper options, e.g.
+-hsts-max-age, -remove-fingerprint-headers and -strip-response-headers,
+and it is applied to all the responses. The disableSecurityHeaders
+filter turns it off for the requests of the route, e.g. for a backend
+that needs to control these headers itself:
+
+    legacy: Host("^legacy[.]example[.]org$") -> disableSecurityHeaders() -> "https://legacy.example.org";
+*/
+package securityheaders
+
+import "github.com/zalando/skipper/filters"
+
+const (
+	Name = "disableSecurityHeaders"
+
+	// StateBagKey is the key of the state bag entry that signals to
+	// the proxy that the security header policy is not applied.
+	StateBagKey = "filter::" + Name
+)
+
+type spec struct{}
+
+type filter struct{}
+
+// New creates a filter specification whose filter instances turn off
+// the proxy level security header policy for the requests of the route.
+func New() filters.Spec { return spec{} }
+
+func (spec) Name() string { return Name }
+
+func (spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return filter{}, nil
+}
+
+func (filter) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[StateBagKey] = true
+}
+
+func (filter) Response(filters.FilterContext) {}
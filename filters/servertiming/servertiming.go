@@ -0,0 +1,52 @@
+/*
+Package servertiming provides a filter to emit the Server-Timing
+response header, containing the durations of the processing phases of
+skipper, so that the proxy overhead can be seen in the developer tools
+of the browsers.
+
+The serverTiming filter turns on the header for the requests of the
+route:
+
+    api: Path("/api") -> serverTiming() -> "https://api.example.org";
+
+The header is set by the proxy, with the following metrics, in
+milliseconds: route (route lookup), request-filters, backend,
+response-filters and total. Existing Server-Timing headers of the
+backend response are preserved. To emit the header for every route,
+start skipper with the -server-timing flag.
+*/
+package servertiming
+
+import "github.com/zalando/skipper/filters"
+
+const (
+	Name = "serverTiming"
+
+	// StateBagKey is the key of the state bag entry that signals to
+	// the proxy that the Server-Timing header needs to be set.
+	StateBagKey = "filter::" + Name
+)
+
+type spec struct{}
+
+type filter struct{}
+
+// New creates a filter specification whose filter instances turn on the
+// Server-Timing response header for the requests of the route.
+func New() filters.Spec { return spec{} }
+
+func (spec) Name() string { return Name }
+
+func (spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return filter{}, nil
+}
+
+func (filter) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[StateBagKey] = true
+}
+
+func (filter) Response(filters.FilterContext) {}
@@ -0,0 +1,26 @@
+package servertiming
+
+import (
+	"testing"
+
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestCreateFilter(t *testing.T) {
+	if _, err := New().CreateFilter([]interface{}{"foo"}); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestSetsStateBag(t *testing.T) {
+	f, err := New().CreateFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &filtertest.Context{FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+	if ctx.StateBag()[StateBagKey] != true {
+		t.Error("failed to set the state bag")
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Package waf provides a web application firewall filter, that evaluates
+rules in a subset of the SecLang format of ModSecurity against the
+requests, and optionally the responses. It is not a complete
+implementation of ModSecurity, and the rule sets written for it, e.g. the
+OWASP Core Rule Set, can be loaded only partially: the rules using
+unsupported features are rejected when loading, instead of being
+evaluated with a different meaning, and need to be removed from the rule
+files.
+
+Rules
+
+The rule files are set with the -waf-rules flag. They are loaded in the
+order of the flag, and the directives apply across the files. The
+following directives are supported:
+
+    SecRule VARIABLES "OPERATOR" "ACTIONS"
+    SecAction "ACTIONS"
+    SecMarker NAME
+    SecDefaultAction "ACTIONS"
+    SecRuleRemoveById 942100 920300-920350
+
+The configuration directives, e.g. SecRuleEngine or SecRequestBodyAccess,
+are accepted and ignored, because the same behavior is controlled by the
+skipper options.
+
+Supported variables: ARGS, ARGS_GET, ARGS_POST, ARGS_NAMES,
+ARGS_GET_NAMES, ARGS_POST_NAMES, QUERY_STRING, REMOTE_ADDR,
+REQUEST_BASENAME, REQUEST_BODY, REQUEST_COOKIES, REQUEST_COOKIES_NAMES,
+REQUEST_FILENAME, REQUEST_HEADERS, REQUEST_HEADERS_NAMES, REQUEST_LINE,
+REQUEST_METHOD, REQUEST_PROTOCOL, REQUEST_URI, REQUEST_URI_RAW,
+RESPONSE_BODY, RESPONSE_CONTENT_TYPE, RESPONSE_HEADERS,
+RESPONSE_HEADERS_NAMES, RESPONSE_STATUS and TX. The collections accept a
+key or a /regexp/ key, the ! prefix excludes a key, and the & prefix
+counts the values.
+
+Supported operators: @rx, @pm, @contains, @streq, @beginsWith,
+@endsWith, @within, @eq, @ge, @gt, @le, @lt, @ipMatch,
+@unconditionalMatch and @noMatch, optionally negated with the ! prefix.
+The regular expressions are evaluated with the Go regexp package, so the
+rules using PCRE only constructs are rejected when loading. The arguments
+of the operators, except for @rx and @ipMatch, can contain macros, e.g.
+%{tx.inbound_anomaly_score_threshold}.
+
+Supported actions: id, phase, msg, severity, deny, drop, block, pass,
+allow, status, t, chain, capture, log, nolog, skipAfter, setvar and ctl.
+The other logging and metadata actions, e.g. tag, are ignored.
+
+The actions follow ModSecurity:
+
+    - deny and drop reject the request with 403, or the status set by the rule
+    - block applies the disruptive action of the SecDefaultAction of the
+      phase of the rule, and passes without it
+    - allow stops evaluating the rules of the request and the response,
+      allow:request of the request, and allow:phase of the current phase
+    - skipAfter continues the evaluation after the SecMarker following the rule
+    - setvar sets, increments, decrements or deletes a variable of the TX
+      collection. The other collections, e.g. IP, are not supported.
+    - capture stores the groups of a matching @rx in TX:0-9
+    - ctl supports ruleRemoveById and ruleEngine, for the current request
+
+Only the disruptive action and the status of SecDefaultAction are applied
+to the rules. This way, the anomaly scoring works as in ModSecurity: the
+rules with the block action increase the score with setvar, and a rule
+checking the score with the deny action rejects the request. The TX
+collection is kept from the request phases to the response phases.
+
+Modes
+
+In block mode, the requests matching a rule with the deny action are
+rejected with 403, or the status set by the rule. In detect mode, the
+matching rules are only logged. The default mode is set with the
+-waf-mode flag, and it can be overridden for the individual routes. The
+rules can switch a single request to detect mode with
+ctl:ruleEngine=DetectionOnly, or stop evaluating the rules with
+ctl:ruleEngine=Off.
+
+Usage
+
+The rules are evaluated for the routes that contain the waf filter:
+
+    waf() -> "https://www.example.org";
+
+The filter accepts the mode, and the ids or the id ranges of the rules
+that are excluded on the route, e.g. to avoid false positives:
+
+    waf("detect") -> "https://www.example.org";
+    waf(942100, "920300-920350") -> "https://www.example.org";
+
+The request bodies, and with the -waf-inspect-response flag, the
+response bodies are inspected up to the size set with the
+-waf-max-body-size flag, 128k by default. In block mode, the request
+bodies longer than this are rejected with 413, and the request bodies
+with a Content-Encoding, e.g. gzip, with 415, because the rules cannot be
+evaluated against them. With the -waf-partial-body-inspection flag, only
+the beginning of the longer bodies is inspected, and the encoded bodies
+are not inspected, like with SecRequestBodyLimitAction ProcessPartial.
+The response bodies are always inspected only up to the maximum size,
+and the encoded response bodies are inspected as they are. The rejected
+requests count as violations for the ban list, when it is enabled.
+*/
+package waf
@@ -0,0 +1,464 @@
+package waf
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type field struct {
+	name  string
+	value string
+}
+
+// the data of a request and its response, that the rules are evaluated
+// against, and the state of the evaluation, shared by the request and the
+// response phases
+type transaction struct {
+	request      *http.Request
+	requestBody  []byte
+	response     *http.Response
+	responseBody []byte
+
+	// the TX collection, by the lowercase names
+	tx map[string]string
+
+	// the evaluated rule and its matching variable, for the macros
+	rule           *Rule
+	matchedVar     string
+	matchedVarName string
+
+	// set by the allow and the ctl actions
+	allowed    string
+	removed    [][2]int
+	engineOff  bool
+	detectOnly bool
+}
+
+// the result of evaluating the rules of some phases
+type verdict struct {
+	matches []Match
+
+	// the matching rule with the deny action
+	deny *Rule
+
+	// set with ctl:ruleEngine=DetectionOnly
+	detectOnly bool
+}
+
+var macroRx = regexp.MustCompile(`%\{([^}]+)\}`)
+
+func newTransaction(r *http.Request, body []byte) *transaction {
+	return &transaction{request: r, requestBody: body, tx: make(map[string]string)}
+}
+
+// Match describes a matching rule.
+type Match struct {
+
+	// The matching rule.
+	Rule *Rule
+
+	// The variable that matched, e.g. ARGS:q.
+	Variable string
+
+	// The value of the variable, that matched, after the
+	// transformations.
+	Value string
+}
+
+func scalar(v string) []field { return []field{{value: v}} }
+
+func valuesFields(v url.Values) []field {
+	var keys []string
+	for k := range v {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	var f []field
+	for _, k := range keys {
+		for _, vi := range v[k] {
+			f = append(f, field{name: k, value: vi})
+		}
+	}
+
+	return f
+}
+
+func headerFields(h http.Header) []field {
+	return valuesFields(url.Values(h))
+}
+
+func names(f []field) []field {
+	var n []field
+	for _, fi := range f {
+		n = append(n, field{name: fi.name, value: fi.name})
+	}
+
+	return n
+}
+
+func (t *transaction) argsGet() []field {
+	return valuesFields(t.request.URL.Query())
+}
+
+func (t *transaction) argsPost() []field {
+	ct := t.request.Header.Get("Content-Type")
+	if !strings.HasPrefix(strings.ToLower(ct), "application/x-www-form-urlencoded") {
+		return nil
+	}
+
+	v, err := url.ParseQuery(string(t.requestBody))
+	if err != nil {
+		return nil
+	}
+
+	return valuesFields(v)
+}
+
+func (t *transaction) requestHeaders() []field {
+	h := headerFields(t.request.Header)
+	if t.request.Host != "" && t.request.Header.Get("Host") == "" {
+		h = append(h, field{name: "Host", value: t.request.Host})
+	}
+
+	return h
+}
+
+func (t *transaction) cookies() []field {
+	var f []field
+	for _, c := range t.request.Cookies() {
+		f = append(f, field{name: c.Name, value: c.Value})
+	}
+
+	return f
+}
+
+func (t *transaction) remoteAddr() []field {
+	host, _, err := net.SplitHostPort(t.request.RemoteAddr)
+	if err != nil {
+		return scalar(t.request.RemoteAddr)
+	}
+
+	return scalar(host)
+}
+
+func (t *transaction) txFields() []field {
+	var f []field
+	for k, v := range t.tx {
+		f = append(f, field{name: k, value: v})
+	}
+
+	sort.Slice(f, func(i, j int) bool { return f[i].name < f[j].name })
+	return f
+}
+
+// expands the macros, e.g. %{tx.critical_anomaly_score} or %{rule.id}.
+// The unknown macros are expanded to empty strings.
+func (t *transaction) expand(s string) string {
+	if !strings.Contains(s, "%{") {
+		return s
+	}
+
+	return macroRx.ReplaceAllStringFunc(s, func(m string) string {
+		name := strings.ToLower(m[2 : len(m)-1])
+		switch {
+		case strings.HasPrefix(name, "tx."):
+			return t.tx[name[len("tx."):]]
+		case name == "matched_var":
+			return t.matchedVar
+		case name == "matched_var_name":
+			return t.matchedVarName
+		case t.rule == nil:
+			return ""
+		case name == "rule.id":
+			return strconv.Itoa(t.rule.ID)
+		case name == "rule.msg":
+			return t.rule.Message
+		case name == "rule.severity":
+			return t.rule.Severity
+		default:
+			return ""
+		}
+	})
+}
+
+// stores the groups of a regular expression match in TX:0-9
+func (t *transaction) captureGroups(groups []string) {
+	for i := 0; i < 10; i++ {
+		key := strconv.Itoa(i)
+		if i < len(groups) {
+			t.tx[key] = groups[i]
+		} else {
+			delete(t.tx, key)
+		}
+	}
+}
+
+func (t *transaction) setVar(sv setVar) {
+	if sv.delete {
+		delete(t.tx, sv.name)
+		return
+	}
+
+	value := t.expand(sv.value)
+	if sv.op == '=' {
+		t.tx[sv.name] = value
+		return
+	}
+
+	current, _ := strconv.Atoi(t.tx[sv.name])
+	change, _ := strconv.Atoi(strings.TrimSpace(value))
+	if sv.op == '-' {
+		change = -change
+	}
+
+	t.tx[sv.name] = strconv.Itoa(current + change)
+}
+
+func (t *transaction) control(c control) {
+	switch c.name {
+	case "ruleremovebyid":
+		t.removed = append(t.removed, [2]int{c.from, c.to})
+	case "ruleengine":
+		t.engineOff = c.value == "off"
+		t.detectOnly = c.value == "detectiononly"
+	}
+}
+
+func (t *transaction) responseHeaders() []field {
+	if t.response == nil {
+		return nil
+	}
+
+	return headerFields(t.response.Header)
+}
+
+var collections = map[string]func(t *transaction) []field{
+	"ARGS":                  func(t *transaction) []field { return append(t.argsGet(), t.argsPost()...) },
+	"ARGS_GET":              func(t *transaction) []field { return t.argsGet() },
+	"ARGS_POST":             func(t *transaction) []field { return t.argsPost() },
+	"ARGS_NAMES":            func(t *transaction) []field { return names(append(t.argsGet(), t.argsPost()...)) },
+	"ARGS_GET_NAMES":        func(t *transaction) []field { return names(t.argsGet()) },
+	"ARGS_POST_NAMES":       func(t *transaction) []field { return names(t.argsPost()) },
+	"QUERY_STRING":          func(t *transaction) []field { return scalar(t.request.URL.RawQuery) },
+	"REMOTE_ADDR":           func(t *transaction) []field { return t.remoteAddr() },
+	"REQUEST_BASENAME":      func(t *transaction) []field { return scalar(path.Base(t.request.URL.Path)) },
+	"REQUEST_BODY":          func(t *transaction) []field { return scalar(string(t.requestBody)) },
+	"REQUEST_COOKIES":       func(t *transaction) []field { return t.cookies() },
+	"REQUEST_COOKIES_NAMES": func(t *transaction) []field { return names(t.cookies()) },
+	"REQUEST_FILENAME":      func(t *transaction) []field { return scalar(t.request.URL.Path) },
+	"REQUEST_HEADERS":       func(t *transaction) []field { return t.requestHeaders() },
+	"REQUEST_HEADERS_NAMES": func(t *transaction) []field { return names(t.requestHeaders()) },
+	"REQUEST_LINE": func(t *transaction) []field {
+		return scalar(t.request.Method + " " + t.request.URL.RequestURI() + " " + t.request.Proto)
+	},
+	"REQUEST_METHOD":   func(t *transaction) []field { return scalar(t.request.Method) },
+	"REQUEST_PROTOCOL": func(t *transaction) []field { return scalar(t.request.Proto) },
+	"REQUEST_URI":      func(t *transaction) []field { return scalar(t.request.URL.RequestURI()) },
+	"REQUEST_URI_RAW": func(t *transaction) []field {
+		if t.request.RequestURI != "" {
+			return scalar(t.request.RequestURI)
+		}
+
+		return scalar(t.request.URL.RequestURI())
+	},
+	"RESPONSE_BODY": func(t *transaction) []field {
+		if t.response == nil {
+			return nil
+		}
+
+		return scalar(string(t.responseBody))
+	},
+	"RESPONSE_CONTENT_TYPE": func(t *transaction) []field {
+		if t.response == nil {
+			return nil
+		}
+
+		return scalar(t.response.Header.Get("Content-Type"))
+	},
+	"RESPONSE_HEADERS":       func(t *transaction) []field { return t.responseHeaders() },
+	"RESPONSE_HEADERS_NAMES": func(t *transaction) []field { return names(t.responseHeaders()) },
+	"RESPONSE_STATUS": func(t *transaction) []field {
+		if t.response == nil {
+			return nil
+		}
+
+		return scalar(strconv.Itoa(t.response.StatusCode))
+	},
+	"TX": func(t *transaction) []field { return t.txFields() },
+}
+
+func (v variable) matchesKey(key string) bool {
+	switch {
+	case v.keyRx != nil:
+		return v.keyRx.MatchString(key)
+	case v.key != "":
+		return strings.EqualFold(v.key, key)
+	default:
+		return true
+	}
+}
+
+func (v variable) name(key string) string {
+	if key == "" {
+		return v.collection
+	}
+
+	return v.collection + ":" + key
+}
+
+// returns the values of the variables of the rule, with the variable names
+func (r *Rule) values(t *transaction) []field {
+	if r.unconditional {
+		return scalar("")
+	}
+
+	var f []field
+	for _, v := range r.variables {
+		if v.exclude {
+			continue
+		}
+
+		var selected []field
+		for _, fi := range collections[v.collection](t) {
+			if !v.matchesKey(fi.name) || r.excluded(v.collection, fi.name) {
+				continue
+			}
+
+			selected = append(selected, field{name: v.name(fi.name), value: fi.value})
+		}
+
+		if v.count {
+			f = append(f, field{name: "&" + v.name(v.key), value: strconv.Itoa(len(selected))})
+			continue
+		}
+
+		f = append(f, selected...)
+	}
+
+	return f
+}
+
+func (r *Rule) excluded(collection, key string) bool {
+	for _, v := range r.variables {
+		if v.exclude && v.collection == collection && v.matchesKey(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Rule) transform(s string) string {
+	for _, t := range r.transformations {
+		s = t(s)
+	}
+
+	return s
+}
+
+// evaluates the rule and its chained rules, and returns the first
+// matching variable of the rule
+func (r *Rule) match(t *transaction) (field, bool) {
+	for _, f := range r.values(t) {
+		t.rule = r
+		v := r.transform(f.value)
+		if !r.operator(t, v) {
+			continue
+		}
+
+		t.matchedVar, t.matchedVarName = v, f.name
+		if r.capture && r.rx != nil {
+			t.captureGroups(r.rx.FindStringSubmatch(v))
+		}
+
+		if r.chain != nil {
+			if _, ok := r.chain.match(t); !ok {
+				return field{}, false
+			}
+		}
+
+		return field{name: f.name, value: v}, true
+	}
+
+	return field{}, false
+}
+
+// executes the non-disruptive actions of a matching rule and its chained
+// rules
+func (r *Rule) execute(t *transaction) {
+	for ri := r; ri != nil; ri = ri.chain {
+		t.rule = ri
+		for _, sv := range ri.setVars {
+			t.setVar(sv)
+		}
+
+		for _, c := range ri.controls {
+			t.control(c)
+		}
+	}
+}
+
+func (t *transaction) skipPhase(p int) bool {
+	return t.engineOff || t.allowed == allowAll || t.allowed == allowRequest && p <= PhaseRequestBody
+}
+
+// evaluates the rules of the phases, in the order of the phases and the
+// rules, skipping the excluded rules, the ones removed by ctl, and the
+// ones skipped by skipAfter. It stops at the first matching rule with the
+// deny action, and at the rules with the allow action, by the scope of
+// the allow action. The matches of the rules with the nolog action are
+// not returned, unless they deny the request.
+func (rs *RuleSet) evaluate(t *transaction, phases []int, excluded func(int) bool) (v verdict) {
+	defer func() { v.detectOnly = t.detectOnly }()
+
+phases:
+	for _, p := range phases {
+		for i := 0; i < len(rs.rules) && !t.skipPhase(p); i++ {
+			r := rs.rules[i]
+			if r.marker != "" || r.Phase != p || excluded != nil && excluded(r.ID) || inRanges(r.ID, t.removed) {
+				continue
+			}
+
+			f, ok := r.match(t)
+			if !ok {
+				continue
+			}
+
+			r.execute(t)
+			if !r.noLog || r.Deny {
+				v.matches = append(v.matches, Match{Rule: r, Variable: f.name, Value: f.value})
+			}
+
+			switch {
+			case t.engineOff:
+				return v
+			case r.Deny:
+				v.deny = r
+				return v
+			case r.allow == allowPhase:
+				continue phases
+			case r.allow != "":
+				t.allowed = r.allow
+			case r.skipTo > 0:
+				i = r.skipTo
+			}
+		}
+	}
+
+	return v
+}
+
+func (rs *RuleSet) evaluateRequest(t *transaction, excluded func(int) bool) verdict {
+	return rs.evaluate(t, []int{PhaseRequestHeaders, PhaseRequestBody}, excluded)
+}
+
+func (rs *RuleSet) evaluateResponse(t *transaction, excluded func(int) bool) verdict {
+	return rs.evaluate(t, []int{PhaseResponseHeaders, PhaseResponseBody}, excluded)
+}
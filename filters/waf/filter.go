@@ -0,0 +1,314 @@
+package waf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	Name = "waf"
+
+	// ModeBlock rejects the requests matching a rule with the deny
+	// action.
+	ModeBlock = "block"
+
+	// ModeDetect only logs the matching rules.
+	ModeDetect = "detect"
+
+	// DefaultMaxBodySize is the default maximum size of the request
+	// and response bodies inspected by the rules.
+	DefaultMaxBodySize = 128 << 10
+
+	// the state bag key signaling to the response phase, that the
+	// request was rejected
+	blockedKey = "filter::" + Name + "::blocked"
+
+	// the state bag key of the transaction, keeping the TX collection
+	// and the allow and ctl actions for the response phase
+	transactionKey = "filter::" + Name + "::transaction"
+
+	maxLoggedValue = 64
+)
+
+// Options for creating the waf filter specification.
+type Options struct {
+
+	// The rules evaluated by the filters. Required.
+	Rules *RuleSet
+
+	// The mode of the filters, that don't set it in their arguments:
+	// block or detect. Default: block.
+	Mode string
+
+	// When set, the rules of phase 3 and 4 are evaluated against the
+	// responses.
+	InspectResponse bool
+
+	// The maximum size of the request and response bodies inspected by
+	// the rules. In block mode, the longer request bodies are rejected
+	// with 413, unless PartialBodyInspection is set. Beyond this size,
+	// only the beginning of the response bodies is inspected. Default:
+	// 128k.
+	MaxBodySize int64
+
+	// When set, the request bodies longer than MaxBodySize are
+	// inspected only up to MaxBodySize, and the request bodies with a
+	// Content-Encoding, e.g. gzip, are not inspected, instead of
+	// rejecting them in block mode with 413 and 415. It is the
+	// equivalent of SecRequestBodyLimitAction ProcessPartial.
+	PartialBodyInspection bool
+}
+
+type spec struct {
+	options Options
+}
+
+type filter struct {
+	options  Options
+	block    bool
+	excluded [][2]int
+}
+
+type body struct {
+	io.Reader
+	io.Closer
+}
+
+var errMissingRules = errors.New("waf: missing rules")
+
+// New creates a filter specification, whose instances evaluate the
+// rules against the requests, and optionally the responses, and reject
+// the requests matching a rule with the deny action with 403, or the
+// status set by the rule. In detect mode, the matching rules are only
+// logged. The filters accept the mode, and the ids or id ranges of the
+// rules excluded on the route as arguments.
+//
+// Eskip example:
+//
+// 	waf("detect") -> "https://www.example.org";
+// 	waf("block", 942100, "920300-920350") -> "https://www.example.org";
+//
+func New(o Options) (filters.Spec, error) {
+	if o.Rules == nil {
+		return nil, errMissingRules
+	}
+
+	switch o.Mode {
+	case "":
+		o.Mode = ModeBlock
+	case ModeBlock, ModeDetect:
+	default:
+		return nil, fmt.Errorf("invalid WAF mode: %s", o.Mode)
+	}
+
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = DefaultMaxBodySize
+	}
+
+	return &spec{options: o}, nil
+}
+
+func (s *spec) Name() string { return Name }
+
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	f := &filter{options: s.options, block: s.options.Mode == ModeBlock}
+	for i, a := range args {
+		switch v := a.(type) {
+		case string:
+			if i == 0 && (v == ModeBlock || v == ModeDetect) {
+				f.block = v == ModeBlock
+				continue
+			}
+
+			from, to, err := parseIDRange(v)
+			if err != nil {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			f.excluded = append(f.excluded, [2]int{from, to})
+		case float64:
+			f.excluded = append(f.excluded, [2]int{int(v), int(v)})
+		default:
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	return f, nil
+}
+
+func (f *filter) isExcluded(id int) bool {
+	return inRanges(id, f.excluded)
+}
+
+// reads the beginning of a body for the inspection, and returns a body
+// that replays it, and whether the body is longer than the inspected part
+func (f *filter) readBody(b io.ReadCloser) ([]byte, io.ReadCloser, bool) {
+	if b == nil || b == http.NoBody {
+		return nil, b, false
+	}
+
+	// the read errors are returned to the next reader of the body
+	p, _ := ioutil.ReadAll(io.LimitReader(b, f.options.MaxBodySize+1))
+	rb := &body{Reader: io.MultiReader(bytes.NewReader(p), b), Closer: b}
+	if int64(len(p)) > f.options.MaxBodySize {
+		return p[:f.options.MaxBodySize], rb, true
+	}
+
+	return p, rb, false
+}
+
+func isEncoded(h http.Header) bool {
+	e := strings.TrimSpace(h.Get("Content-Encoding"))
+	return e != "" && !strings.EqualFold(e, "identity")
+}
+
+// checks whether the request body can be inspected completely, and
+// returns the status of the rejection, when it cannot, and the request
+// needs to be rejected. The rules cannot be evaluated against the part of
+// the body beyond the maximum size, or against the encoded bodies, so in
+// block mode, these are rejected, unless partial inspection is enabled.
+func (f *filter) uninspectedBody(r *http.Request, b []byte, truncated bool) (int, bool) {
+	var (
+		status int
+		reason string
+	)
+
+	switch {
+	case truncated:
+		status, reason = http.StatusRequestEntityTooLarge, "longer than the maximum body size"
+	case len(b) > 0 && isEncoded(r.Header):
+		status, reason = http.StatusUnsupportedMediaType, "encoded with "+r.Header.Get("Content-Encoding")
+	default:
+		return 0, false
+	}
+
+	reject := f.block && !f.options.PartialBodyInspection
+	mode := ModeDetect
+	if reject {
+		mode = ModeBlock
+	} else if f.block {
+		mode = "block, partial inspection"
+	}
+
+	log.Warnf("waf: request body not inspected, %s, mode: %s, request: %s %s%s", reason, mode, r.Method, r.Host, r.URL.Path)
+	return status, reject
+}
+
+func truncate(s string) string {
+	if len(s) <= maxLoggedValue {
+		return s
+	}
+
+	return s[:maxLoggedValue] + "..."
+}
+
+// logs the matches, and returns the status of the rejection, when the
+// request needs to be rejected
+func (f *filter) handle(r *http.Request, v verdict) (int, bool) {
+	mode := ModeDetect
+	if f.block {
+		mode = ModeBlock
+	}
+
+	for _, mi := range v.matches {
+		log.Warnf(
+			"waf: rule %d matched, mode: %s, message: %s, severity: %s, variable: %s, value: %q, request: %s %s%s",
+			mi.Rule.ID,
+			mode,
+			mi.Rule.Message,
+			mi.Rule.Severity,
+			mi.Variable,
+			truncate(mi.Value),
+			r.Method,
+			r.Host,
+			r.URL.Path,
+		)
+	}
+
+	if !f.block || v.deny == nil || v.detectOnly {
+		return 0, false
+	}
+
+	status := v.deny.Status
+	if status == 0 {
+		status = http.StatusForbidden
+	}
+
+	return status, true
+}
+
+func rejection(status int) (http.Header, io.ReadCloser, int64) {
+	text := http.StatusText(status)
+	h := http.Header{
+		"Content-Type":   []string{"text/plain; charset=utf-8"},
+		"Content-Length": []string{strconv.Itoa(len(text))},
+	}
+
+	return h, ioutil.NopCloser(strings.NewReader(text)), int64(len(text))
+}
+
+func (f *filter) reject(ctx filters.FilterContext, status int) {
+	ctx.StateBag()[blockedKey] = true
+	h, rb, l := rejection(status)
+	ctx.Serve(&http.Response{
+		StatusCode:    status,
+		Header:        h,
+		Body:          rb,
+		ContentLength: l,
+	})
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	var (
+		b         []byte
+		truncated bool
+	)
+
+	b, r.Body, truncated = f.readBody(r.Body)
+	if status, reject := f.uninspectedBody(r, b, truncated); reject {
+		f.reject(ctx, status)
+		return
+	}
+
+	t := newTransaction(r, b)
+	ctx.StateBag()[transactionKey] = t
+	if status, reject := f.handle(r, f.options.Rules.evaluateRequest(t, f.isExcluded)); reject {
+		f.reject(ctx, status)
+	}
+}
+
+func (f *filter) Response(ctx filters.FilterContext) {
+	if !f.options.InspectResponse || ctx.StateBag()[blockedKey] == true {
+		return
+	}
+
+	t, ok := ctx.StateBag()[transactionKey].(*transaction)
+	if !ok {
+		t = newTransaction(ctx.Request(), nil)
+	}
+
+	rsp := ctx.Response()
+	t.response = rsp
+	t.responseBody, rsp.Body, _ = f.readBody(rsp.Body)
+	status, reject := f.handle(t.request, f.options.Rules.evaluateResponse(t, f.isExcluded))
+	if !reject {
+		return
+	}
+
+	if rsp.Body != nil {
+		rsp.Body.Close()
+	}
+
+	rsp.StatusCode = status
+	rsp.Header, rsp.Body, rsp.ContentLength = rejection(status)
+}
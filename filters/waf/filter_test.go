@@ -0,0 +1,467 @@
+package waf
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func createFilter(t *testing.T, o Options, args ...interface{}) filters.Filter {
+	rs, err := LoadRules([]string{"testdata/rules.conf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o.Rules = rs
+	s, err := New(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := s.CreateFilter(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Options{}); err == nil {
+		t.Error("failed to fail without rules")
+	}
+
+	if _, err := New(Options{Rules: &RuleSet{}, Mode: "foo"}); err == nil {
+		t.Error("failed to fail with invalid mode")
+	}
+}
+
+func TestCreateFilter(t *testing.T) {
+	s, err := New(Options{Rules: &RuleSet{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]interface{}{
+		{"foo"},
+		{"block", "20-10"},
+		{true},
+	} {
+		if _, err := s.CreateFilter(args); err == nil {
+			t.Errorf("failed to fail: %v", args)
+		}
+	}
+}
+
+func TestRequest(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		options  Options
+		args     []interface{}
+		method   string
+		url      string
+		body     string
+		header   http.Header
+		expected int
+	}{{
+		title: "clean request",
+		url:   "https://www.example.org/search?q=shoes",
+	}, {
+		title:    "matching query",
+		url:      "https://www.example.org/search?q=1%20UNION%20%20SELECT%20password",
+		expected: http.StatusForbidden,
+	}, {
+		title:    "matching form body",
+		method:   "POST",
+		url:      "https://www.example.org/search",
+		body:     "q=1+union+select+password",
+		header:   http.Header{"Content-Type": []string{"application/x-www-form-urlencoded"}},
+		expected: http.StatusForbidden,
+	}, {
+		title:  "body not form",
+		method: "POST",
+		url:    "https://www.example.org/search",
+		body:   "q=1+union+select+password",
+		header: http.Header{"Content-Type": []string{"text/plain"}},
+	}, {
+		title:    "matching cookie",
+		url:      "https://www.example.org/",
+		header:   http.Header{"Cookie": []string{"q=union select"}},
+		expected: http.StatusForbidden,
+	}, {
+		title:  "excluded variable",
+		url:    "https://www.example.org/",
+		header: http.Header{"Cookie": []string{"session=union select"}},
+	}, {
+		title:    "status of the rule",
+		url:      "https://www.example.org/",
+		header:   http.Header{"User-Agent": []string{"SQLMap/1.0"}},
+		expected: http.StatusNotAcceptable,
+	}, {
+		title:    "method not allowed",
+		method:   "DELETE",
+		url:      "https://www.example.org/",
+		expected: http.StatusForbidden,
+	}, {
+		title:    "chained rule matching",
+		url:      "https://www.example.org/index.php?id=1",
+		expected: http.StatusForbidden,
+	}, {
+		title: "chained rule not matching",
+		url:   "https://www.example.org/index.php",
+	}, {
+		title: "passing rule",
+		url:   "https://www.example.org/?debug=1",
+	}, {
+		title:   "detect mode",
+		options: Options{Mode: ModeDetect},
+		url:     "https://www.example.org/search?q=1%20union%20select%20password",
+	}, {
+		title:    "block mode on the route",
+		options:  Options{Mode: ModeDetect},
+		args:     []interface{}{"block"},
+		url:      "https://www.example.org/search?q=1%20union%20select%20password",
+		expected: http.StatusForbidden,
+	}, {
+		title: "detect mode on the route",
+		args:  []interface{}{"detect"},
+		url:   "https://www.example.org/search?q=1%20union%20select%20password",
+	}, {
+		title: "excluded rule",
+		args:  []interface{}{float64(942100)},
+		url:   "https://www.example.org/search?q=1%20union%20select%20password",
+	}, {
+		title: "excluded rule range",
+		args:  []interface{}{"block", "942000-942999"},
+		url:   "https://www.example.org/search?q=1%20union%20select%20password",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			f := createFilter(t, test.options, test.args...)
+
+			method := test.method
+			if method == "" {
+				method = "GET"
+			}
+
+			r, err := http.NewRequest(method, test.url, strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for k, v := range test.header {
+				r.Header[k] = v
+			}
+
+			ctx := &filtertest.Context{FRequest: r, FStateBag: make(map[string]interface{})}
+			f.Request(ctx)
+
+			if test.expected == 0 {
+				if ctx.FServed {
+					t.Fatalf("unexpected rejection: %d", ctx.FResponse.StatusCode)
+				}
+
+				b, err := ioutil.ReadAll(r.Body)
+				if err != nil || string(b) != test.body {
+					t.Errorf("failed to preserve the body, got: %q, %v", b, err)
+				}
+
+				return
+			}
+
+			if !ctx.FServed || ctx.FResponse.StatusCode != test.expected {
+				t.Fatalf("failed to reject the request, expected: %d", test.expected)
+			}
+
+			if ctx.FStateBag[blockedKey] != true {
+				t.Error("failed to set the state bag")
+			}
+		})
+	}
+}
+
+func TestResponse(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		inspect  bool
+		status   int
+		body     string
+		blocked  bool
+		expected int
+	}{{
+		title:    "clean response",
+		inspect:  true,
+		status:   http.StatusOK,
+		body:     "hello",
+		expected: http.StatusOK,
+	}, {
+		title:    "matching response",
+		inspect:  true,
+		status:   http.StatusOK,
+		body:     "ORA-01756: quoted string not properly terminated",
+		expected: http.StatusForbidden,
+	}, {
+		title:    "passing response rule",
+		inspect:  true,
+		status:   http.StatusInternalServerError,
+		body:     "error",
+		expected: http.StatusInternalServerError,
+	}, {
+		title:    "response inspection disabled",
+		status:   http.StatusOK,
+		body:     "ORA-01756: quoted string not properly terminated",
+		expected: http.StatusOK,
+	}, {
+		title:    "request blocked",
+		inspect:  true,
+		status:   http.StatusForbidden,
+		body:     "ORA-01756",
+		blocked:  true,
+		expected: http.StatusForbidden,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			f := createFilter(t, Options{InspectResponse: test.inspect})
+
+			r, err := http.NewRequest("GET", "https://www.example.org/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rsp := &http.Response{
+				StatusCode: test.status,
+				Header:     make(http.Header),
+				Body:       ioutil.NopCloser(strings.NewReader(test.body)),
+			}
+
+			ctx := &filtertest.Context{FRequest: r, FResponse: rsp, FStateBag: make(map[string]interface{})}
+			if test.blocked {
+				ctx.FStateBag[blockedKey] = true
+			}
+
+			f.Response(ctx)
+			if rsp.StatusCode != test.expected {
+				t.Fatalf("invalid status, expected: %d, got: %d", test.expected, rsp.StatusCode)
+			}
+
+			b, err := ioutil.ReadAll(rsp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.expected == test.status && string(b) != test.body {
+				t.Errorf("failed to preserve the body, got: %q", b)
+			}
+		})
+	}
+}
+
+const anomalyScoringRules = `
+SecAction "id:900110,phase:1,pass,nolog,setvar:tx.inbound_anomaly_score_threshold=5,setvar:tx.critical_anomaly_score=5,setvar:tx.warning_anomaly_score=3"
+
+SecRule REQUEST_HEADERS:X-Internal "@streq yes" "id:900200,phase:1,allow,nolog"
+SecRule REQUEST_FILENAME "@beginsWith /static/" "id:900210,phase:1,allow:phase,nolog"
+SecRule REQUEST_FILENAME "@beginsWith /legacy/" "id:900220,phase:1,pass,nolog,ctl:ruleRemoveById=942100"
+SecRule REQUEST_FILENAME "@beginsWith /audit/" "id:900230,phase:1,pass,nolog,ctl:ruleEngine=DetectionOnly"
+
+SecRule REQUEST_FILENAME "@beginsWith /public/" "id:910000,phase:2,pass,nolog,skipAfter:END-SQLI"
+SecRule ARGS "@rx (?i)union\s+select" "id:942100,phase:2,block,capture,msg:'SQL Injection',setvar:'tx.anomaly_score=+%{tx.critical_anomaly_score}',setvar:tx.matched=%{tx.0}"
+SecRule ARGS "@rx (?i)select" "id:942200,phase:2,block,msg:'SQL keyword',setvar:'tx.anomaly_score=+%{tx.warning_anomaly_score}'"
+SecMarker END-SQLI
+
+SecRule TX:ANOMALY_SCORE "@ge %{tx.inbound_anomaly_score_threshold}" "id:949110,phase:2,deny,msg:'Inbound Anomaly Score Exceeded (Total Score: %{tx.anomaly_score})'"
+`
+
+func TestAnomalyScoring(t *testing.T) {
+	rs, err := ParseRules(strings.NewReader(anomalyScoringRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(Options{Rules: rs})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := s.CreateFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		title    string
+		url      string
+		header   http.Header
+		blocked  bool
+		score    string
+		captured string
+	}{{
+		title: "clean request",
+		url:   "https://www.example.org/search?q=shoes",
+	}, {
+		title: "warning only, below the threshold",
+		url:   "https://www.example.org/search?q=select",
+		score: "3",
+	}, {
+		title:    "critical and warning, above the threshold",
+		url:      "https://www.example.org/search?q=1%20union%20select%20password",
+		blocked:  true,
+		score:    "8",
+		captured: "union select",
+	}, {
+		title:  "allow stops the evaluation",
+		url:    "https://www.example.org/search?q=1%20union%20select%20password",
+		header: http.Header{"X-Internal": []string{"yes"}},
+	}, {
+		title:   "allow phase skips only the rest of the phase",
+		url:     "https://www.example.org/static/?q=1%20union%20select%20password",
+		blocked: true,
+		score:   "8",
+	}, {
+		title: "skip after the marker",
+		url:   "https://www.example.org/public/?q=1%20union%20select%20password",
+	}, {
+		title: "rule removed by ctl",
+		url:   "https://www.example.org/legacy/?q=1%20union%20select%20password",
+		score: "3",
+	}, {
+		title: "detection only by ctl",
+		url:   "https://www.example.org/audit/?q=1%20union%20select%20password",
+		score: "8",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := http.NewRequest("GET", test.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for k, v := range test.header {
+				r.Header[k] = v
+			}
+
+			ctx := &filtertest.Context{FRequest: r, FStateBag: make(map[string]interface{})}
+			f.Request(ctx)
+			if ctx.FServed != test.blocked {
+				t.Errorf("invalid rejection, expected: %v, got: %v", test.blocked, ctx.FServed)
+			}
+
+			tx := ctx.FStateBag[transactionKey].(*transaction).tx
+			if tx["anomaly_score"] != test.score {
+				t.Errorf("invalid anomaly score, expected: %q, got: %q", test.score, tx["anomaly_score"])
+			}
+
+			if test.captured != "" && tx["matched"] != test.captured {
+				t.Errorf("invalid captured value, expected: %q, got: %q", test.captured, tx["matched"])
+			}
+		})
+	}
+}
+
+func TestAllowSkipsResponse(t *testing.T) {
+	rs, err := ParseRules(strings.NewReader(`
+SecRule REQUEST_HEADERS:X-Internal "@streq yes" "id:1,phase:1,allow"
+SecRule RESPONSE_STATUS "@eq 500" "id:2,phase:3,deny"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(Options{Rules: rs, InspectResponse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := s.CreateFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ := http.NewRequest("GET", "https://www.example.org/", nil)
+	r.Header.Set("X-Internal", "yes")
+	rsp := &http.Response{StatusCode: 500, Header: make(http.Header), Body: ioutil.NopCloser(strings.NewReader("error"))}
+	ctx := &filtertest.Context{FRequest: r, FResponse: rsp, FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+	f.Response(ctx)
+	if rsp.StatusCode != 500 {
+		t.Error("failed to skip the response rules after allow", rsp.StatusCode)
+	}
+}
+
+func TestUninspectedRequestBody(t *testing.T) {
+	padded := "q=1+union+select+password&" + strings.Repeat("a", 64)
+	for _, test := range []struct {
+		title    string
+		options  Options
+		encoding string
+		body     string
+		expected int
+	}{{
+		title:    "body longer than the maximum, rejected",
+		body:     strings.Repeat("a", 64) + "&q=1+union+select+password",
+		expected: http.StatusRequestEntityTooLarge,
+	}, {
+		title:   "body longer than the maximum, detect mode",
+		options: Options{Mode: ModeDetect},
+		body:    strings.Repeat("a", 64) + "&q=1+union+select+password",
+	}, {
+		title:   "body longer than the maximum, partial inspection",
+		options: Options{PartialBodyInspection: true},
+		body:    strings.Repeat("a", 64) + "&q=1+union+select+password",
+	}, {
+		title:    "partial inspection, matching the inspected part",
+		options:  Options{PartialBodyInspection: true},
+		body:     padded,
+		expected: http.StatusForbidden,
+	}, {
+		title:    "encoded body, rejected",
+		encoding: "gzip",
+		body:     "compressed",
+		expected: http.StatusUnsupportedMediaType,
+	}, {
+		title:    "encoded body, partial inspection",
+		options:  Options{PartialBodyInspection: true},
+		encoding: "gzip",
+		body:     "compressed",
+	}, {
+		title:    "identity encoding",
+		encoding: "identity",
+		body:     "q=shoes",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			o := test.options
+			o.MaxBodySize = 32
+			f := createFilter(t, o)
+
+			r, err := http.NewRequest("POST", "https://www.example.org/search", strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if test.encoding != "" {
+				r.Header.Set("Content-Encoding", test.encoding)
+			}
+
+			ctx := &filtertest.Context{FRequest: r, FStateBag: make(map[string]interface{})}
+			f.Request(ctx)
+			if test.expected == 0 {
+				if ctx.FServed {
+					t.Fatalf("unexpected rejection: %d", ctx.FResponse.StatusCode)
+				}
+
+				b, err := ioutil.ReadAll(r.Body)
+				if err != nil || string(b) != test.body {
+					t.Errorf("failed to preserve the body, got: %q, %v", b, err)
+				}
+
+				return
+			}
+
+			if !ctx.FServed || ctx.FResponse.StatusCode != test.expected {
+				t.Fatalf("failed to reject the request, expected: %d", test.expected)
+			}
+		})
+	}
+}
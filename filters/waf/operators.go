@@ -0,0 +1,287 @@
+package waf
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type (
+	operator       func(t *transaction, s string) bool
+	transformation func(string) string
+)
+
+var transformations = map[string]transformation{
+	"lowercase":          strings.ToLower,
+	"uppercase":          strings.ToUpper,
+	"trim":               strings.TrimSpace,
+	"trimleft":           func(s string) string { return strings.TrimLeftFunc(s, unicode.IsSpace) },
+	"trimright":          func(s string) string { return strings.TrimRightFunc(s, unicode.IsSpace) },
+	"urldecode":          urlDecode,
+	"urldecodeuni":       urlDecode,
+	"htmlentitydecode":   html.UnescapeString,
+	"compresswhitespace": compressWhitespace,
+	"removewhitespace":   removeWhitespace,
+	"removenulls":        func(s string) string { return strings.Replace(s, "\x00", "", -1) },
+	"replacenulls":       func(s string) string { return strings.Replace(s, "\x00", " ", -1) },
+	"base64decode":       base64Decode,
+	"length":             func(s string) string { return strconv.Itoa(len(s)) },
+	"normalisepath":      normalizePath,
+	"normalizepath":      normalizePath,
+}
+
+// decodes the percent encoding, keeping the invalid sequences
+func urlDecode(s string) string {
+	if d, err := url.QueryUnescape(s); err == nil {
+		return d
+	}
+
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '+':
+			b = append(b, ' ')
+		case s[i] == '%' && i+2 < len(s):
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b = append(b, byte(v))
+				i += 2
+				continue
+			}
+
+			b = append(b, s[i])
+		default:
+			b = append(b, s[i])
+		}
+	}
+
+	return string(b)
+}
+
+func compressWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func removeWhitespace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+
+		return r
+	}, s)
+}
+
+func base64Decode(s string) string {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return s
+	}
+
+	return string(b)
+}
+
+// resolves the . and .. segments, and removes the duplicate slashes
+func normalizePath(s string) string {
+	var segments []string
+	for _, si := range strings.Split(s, "/") {
+		switch si {
+		case "", ".":
+		case "..":
+			if len(segments) > 0 {
+				segments = segments[:len(segments)-1]
+			}
+		default:
+			segments = append(segments, si)
+		}
+	}
+
+	n := strings.Join(segments, "/")
+	if strings.HasPrefix(s, "/") {
+		n = "/" + n
+	}
+
+	return n
+}
+
+func numeric(arg string, cmp func(v, arg int) bool) (func(string) bool, error) {
+	a, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil {
+		return nil, fmt.Errorf("invalid numeric operator argument: %s", arg)
+	}
+
+	return func(s string) bool {
+		v, err := strconv.Atoi(strings.TrimSpace(s))
+		return err == nil && cmp(v, a)
+	}, nil
+}
+
+func ipMatch(arg string) (func(string) bool, error) {
+	var nets []*net.IPNet
+	for _, a := range strings.Split(arg, ",") {
+		a = strings.TrimSpace(a)
+		if !strings.Contains(a, "/") {
+			if strings.Contains(a, ":") {
+				a += "/128"
+			} else {
+				a += "/32"
+			}
+		}
+
+		_, n, err := net.ParseCIDR(a)
+		if err != nil {
+			return nil, err
+		}
+
+		nets = append(nets, n)
+	}
+
+	return func(s string) bool {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return false
+		}
+
+		for _, n := range nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+// the operators accepting macros in their argument, e.g.
+// "@ge %{tx.inbound_anomaly_score_threshold}"
+var macroOperators = map[string]bool{
+	"beginswith": true,
+	"contains":   true,
+	"endswith":   true,
+	"eq":         true,
+	"ge":         true,
+	"gt":         true,
+	"le":         true,
+	"lt":         true,
+	"pm":         true,
+	"streq":      true,
+	"within":     true,
+}
+
+// creates an operator from its lowercase name and its argument. For the
+// regular expressions, it returns the compiled expression, too.
+func newOperator(name, arg string) (func(string) bool, *regexp.Regexp, error) {
+	switch name {
+	case "rx":
+		rx, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return rx.MatchString, rx, nil
+	case "pm":
+		phrases := strings.Fields(strings.ToLower(arg))
+		return func(s string) bool {
+			s = strings.ToLower(s)
+			for _, p := range phrases {
+				if strings.Contains(s, p) {
+					return true
+				}
+			}
+
+			return false
+		}, nil, nil
+	case "contains":
+		return func(s string) bool { return strings.Contains(s, arg) }, nil, nil
+	case "streq":
+		return func(s string) bool { return s == arg }, nil, nil
+	case "beginswith":
+		return func(s string) bool { return strings.HasPrefix(s, arg) }, nil, nil
+	case "endswith":
+		return func(s string) bool { return strings.HasSuffix(s, arg) }, nil, nil
+	case "within":
+		return func(s string) bool { return strings.Contains(arg, s) }, nil, nil
+	case "eq":
+		op, err := numeric(arg, func(v, a int) bool { return v == a })
+		return op, nil, err
+	case "ge":
+		op, err := numeric(arg, func(v, a int) bool { return v >= a })
+		return op, nil, err
+	case "gt":
+		op, err := numeric(arg, func(v, a int) bool { return v > a })
+		return op, nil, err
+	case "le":
+		op, err := numeric(arg, func(v, a int) bool { return v <= a })
+		return op, nil, err
+	case "lt":
+		op, err := numeric(arg, func(v, a int) bool { return v < a })
+		return op, nil, err
+	case "ipmatch":
+		op, err := ipMatch(arg)
+		return op, nil, err
+	case "unconditionalmatch":
+		return func(string) bool { return true }, nil, nil
+	case "nomatch":
+		return func(string) bool { return false }, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported operator: @%s", name)
+	}
+}
+
+// parses an operator, e.g. "@rx ^foo", "!@streq bar". Without the @
+// prefix, the operator is a regular expression. The macros in the
+// argument, e.g. %{tx.allowed_methods}, are expanded when the operator is
+// evaluated. For the regular expressions that are not negated, it
+// returns the compiled expression, used by the capture action.
+func parseOperator(s string) (operator, *regexp.Regexp, error) {
+	negate := strings.HasPrefix(s, "!")
+	if negate {
+		s = s[1:]
+	}
+
+	name, arg := "rx", s
+	if strings.HasPrefix(s, "@") {
+		parts := strings.SplitN(s[1:], " ", 2)
+		name = parts[0]
+		arg = ""
+		if len(parts) == 2 {
+			arg = parts[1]
+		}
+	}
+
+	name = strings.ToLower(name)
+
+	var op operator
+	if strings.Contains(arg, "%{") {
+		if !macroOperators[name] {
+			return nil, nil, fmt.Errorf("macros are not supported in the argument of @%s", name)
+		}
+
+		op = func(t *transaction, s string) bool {
+			o, _, err := newOperator(name, t.expand(arg))
+			return err == nil && o(s)
+		}
+	} else {
+		o, rx, err := newOperator(name, arg)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !negate {
+			return func(_ *transaction, s string) bool { return o(s) }, rx, nil
+		}
+
+		op = func(_ *transaction, s string) bool { return o(s) }
+	}
+
+	if negate {
+		return func(t *transaction, s string) bool { return !op(t, s) }, nil, nil
+	}
+
+	return op, nil, nil
+}
@@ -0,0 +1,777 @@
+package waf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Phases of the rules.
+const (
+	PhaseRequestHeaders  = 1
+	PhaseRequestBody     = 2
+	PhaseResponseHeaders = 3
+	PhaseResponseBody    = 4
+)
+
+type variable struct {
+	collection string
+	key        string
+	keyRx      *regexp.Regexp
+	exclude    bool
+	count      bool
+}
+
+// Rule is a parsed SecRule.
+type Rule struct {
+
+	// The id of the rule.
+	ID int
+
+	// The phase when the rule is evaluated, 1-4.
+	Phase int
+
+	// The message of the rule, logged when it matches.
+	Message string
+
+	// The severity of the rule, e.g. CRITICAL.
+	Severity string
+
+	// Whether a match rejects the request in blocking mode. The block
+	// action is resolved to the disruptive action of the
+	// SecDefaultAction of the phase, pass by default.
+	Deny bool
+
+	// The status code of the rejected requests. 0 means 403.
+	Status int
+
+	variables       []variable
+	operator        operator
+	rx              *regexp.Regexp
+	transformations []transformation
+	hasChain        bool
+	chain           *Rule
+
+	// SecAction, matching without variables
+	unconditional bool
+
+	block     bool
+	allow     string
+	noLog     bool
+	capture   bool
+	skipAfter string
+	setVars   []setVar
+	controls  []control
+
+	// set for the SecMarker directives, that are kept among the rules
+	marker string
+
+	// the index of the marker, that the evaluation continues after
+	// when the rule with skipAfter matches
+	skipTo int
+}
+
+// the scopes of the allow action
+const (
+	allowAll     = "all"
+	allowPhase   = "phase"
+	allowRequest = "request"
+)
+
+// a setvar action on the TX collection
+type setVar struct {
+	name   string
+	value  string
+	op     byte
+	delete bool
+}
+
+// a ctl action, changing the evaluation of the current transaction
+type control struct {
+	name  string
+	value string
+	from  int
+	to    int
+}
+
+// the disruptive action of a SecDefaultAction, applied to the rules of
+// its phase with the block action
+type defaultAction struct {
+	deny   bool
+	status int
+}
+
+// RuleSet contains the rules loaded from one or more rule files.
+type RuleSet struct {
+	rules []*Rule
+}
+
+// the state of the parsing shared by the rule files, so that
+// SecDefaultAction and SecRuleRemoveById apply to the rules of the
+// other files, too
+type parser struct {
+	rules         []*Rule
+	defaults      map[int]defaultAction
+	removed       map[int]bool
+	removedRanges [][2]int
+}
+
+// Directives that are accepted, but have no effect, because the
+// corresponding behavior is configured with the skipper options, or it is
+// not supported.
+var ignoredDirectives = map[string]bool{
+	"secauditengine":          true,
+	"secauditlog":             true,
+	"secauditlogparts":        true,
+	"secauditlogtype":         true,
+	"seccollectiontimeout":    true,
+	"seccomponentsignature":   true,
+	"secdebuglog":             true,
+	"secdebugloglevel":        true,
+	"secrequestbodyaccess":    true,
+	"secrequestbodylimit":     true,
+	"secresponsebodyaccess":   true,
+	"secresponsebodylimit":    true,
+	"secresponsebodymimetype": true,
+	"secruleengine":           true,
+}
+
+// Actions that are accepted, but have no effect.
+var ignoredActions = map[string]bool{
+	"accuracy":   true,
+	"auditlog":   true,
+	"expirevar":  true,
+	"initcol":    true,
+	"logdata":    true,
+	"maturity":   true,
+	"multimatch": true,
+	"noauditlog": true,
+	"rev":        true,
+	"tag":        true,
+	"ver":        true,
+}
+
+// the ctl options that are accepted, but have no effect
+var ignoredControls = map[string]bool{
+	"auditengine":   true,
+	"auditlogparts": true,
+}
+
+// ParseRules parses the rules in the SecLang format of ModSecurity. It
+// supports the SecRule, SecAction, SecMarker, SecDefaultAction and
+// SecRuleRemoveById directives, a subset of the variables, operators,
+// transformations and actions, the chained rules, the TX collection with
+// setvar, and the skipAfter, allow and ctl actions. The regular
+// expressions are evaluated with the Go regexp package, so the rules with
+// PCRE only constructs, e.g. lookarounds, are rejected. The unsupported
+// directives, variables, operators and actions are rejected, too, instead
+// of changing the meaning of the rules.
+func ParseRules(r io.Reader) (*RuleSet, error) {
+	p := newParser()
+	if err := p.parse(r); err != nil {
+		return nil, err
+	}
+
+	return p.ruleSet()
+}
+
+// LoadRules loads the rules from one or more files, in the order of the
+// files. The SecDefaultAction directives apply to the rules following
+// them, and the SecRuleRemoveById directives to the rules of all the
+// files.
+func LoadRules(files []string) (*RuleSet, error) {
+	p := newParser()
+	for _, fn := range files {
+		f, err := os.Open(fn)
+		if err != nil {
+			return nil, err
+		}
+
+		err = p.parse(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error while loading WAF rules from %s: %v", fn, err)
+		}
+	}
+
+	return p.ruleSet()
+}
+
+func newParser() *parser {
+	return &parser{
+		defaults: make(map[int]defaultAction),
+		removed:  make(map[int]bool),
+	}
+}
+
+func (p *parser) parse(r io.Reader) error {
+	var (
+		previous *Rule
+		line     string
+		lineNo   int
+	)
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		lineNo++
+		l := strings.TrimSpace(s.Text())
+		if strings.HasSuffix(l, "\\") {
+			line += strings.TrimSuffix(l, "\\") + " "
+			continue
+		}
+
+		line += l
+		if line == "" || strings.HasPrefix(line, "#") {
+			line = ""
+			continue
+		}
+
+		args, err := tokenize(line)
+		line = ""
+		if err != nil {
+			return fmt.Errorf("line %d: %v", lineNo, err)
+		}
+
+		directive := strings.ToLower(args[0])
+		switch {
+		case directive == "secrule" || directive == "secaction":
+			var rule *Rule
+			if directive == "secrule" {
+				rule, err = parseRule(args[1:])
+			} else {
+				rule, err = parseAction(args[1:])
+			}
+
+			if err != nil {
+				return fmt.Errorf("line %d: %v", lineNo, err)
+			}
+
+			// a rule following a rule with the chain action is part of
+			// the previous one
+			if previous != nil && previous.lastInChain().chained() {
+				if rule.unconditional {
+					return fmt.Errorf("line %d: SecAction in a chain", lineNo)
+				}
+
+				last := previous.lastInChain()
+				last.chain = rule
+				rule.ID = previous.ID
+				rule.Phase = previous.Phase
+				continue
+			}
+
+			if rule.ID == 0 {
+				return fmt.Errorf("line %d: missing rule id", lineNo)
+			}
+
+			p.resolveBlock(rule)
+			p.rules = append(p.rules, rule)
+			previous = rule
+		case directive == "secmarker":
+			if len(args) != 2 {
+				return fmt.Errorf("line %d: invalid SecMarker", lineNo)
+			}
+
+			p.rules = append(p.rules, &Rule{marker: args[1]})
+		case directive == "secdefaultaction":
+			if err := p.parseDefaultAction(args[1:]); err != nil {
+				return fmt.Errorf("line %d: %v", lineNo, err)
+			}
+		case directive == "secruleremovebyid":
+			for _, a := range args[1:] {
+				from, to, err := parseIDRange(a)
+				if err != nil {
+					return fmt.Errorf("line %d: %v", lineNo, err)
+				}
+
+				if from == to {
+					p.removed[from] = true
+				} else {
+					p.removedRanges = append(p.removedRanges, [2]int{from, to})
+				}
+			}
+		case ignoredDirectives[directive]:
+		default:
+			return fmt.Errorf("line %d: unsupported directive: %s", lineNo, args[0])
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	if line != "" {
+		return fmt.Errorf("line %d: unterminated line", lineNo)
+	}
+
+	if previous != nil && previous.lastInChain().chained() {
+		return fmt.Errorf("rule %d: missing chained rule", previous.ID)
+	}
+
+	return nil
+}
+
+// sets the disruptive action of the rules with the block action from the
+// SecDefaultAction of their phase. Without SecDefaultAction, the block
+// action passes, like in ModSecurity, so that e.g. the anomaly scoring
+// rules only increase the score.
+func (p *parser) resolveBlock(r *Rule) {
+	if !r.block {
+		return
+	}
+
+	d := p.defaults[r.Phase]
+	r.Deny = d.deny
+	if r.Status == 0 {
+		r.Status = d.status
+	}
+}
+
+// parses a SecDefaultAction. Only its disruptive action and status are
+// applied to the rules with the block action.
+func (p *parser) parseDefaultAction(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("invalid SecDefaultAction, expected actions")
+	}
+
+	r := &Rule{Phase: PhaseRequestBody}
+	if err := r.parseActions(args[0]); err != nil {
+		return err
+	}
+
+	if r.block || r.allow != "" {
+		return fmt.Errorf("invalid disruptive action in SecDefaultAction")
+	}
+
+	p.defaults[r.Phase] = defaultAction{deny: r.Deny, status: r.Status}
+	return nil
+}
+
+// removes the rules removed by id, and resolves the markers of the
+// skipAfter actions
+func (p *parser) ruleSet() (*RuleSet, error) {
+	var rules []*Rule
+	for _, rule := range p.rules {
+		if rule.marker != "" || !p.removed[rule.ID] && !inRanges(rule.ID, p.removedRanges) {
+			rules = append(rules, rule)
+		}
+	}
+
+	for i, rule := range rules {
+		if rule.skipAfter == "" {
+			continue
+		}
+
+		for j := i + 1; j < len(rules); j++ {
+			if rules[j].marker == rule.skipAfter {
+				rule.skipTo = j
+				break
+			}
+		}
+
+		if rule.skipTo == 0 {
+			return nil, fmt.Errorf("rule %d: marker not found after the rule: %s", rule.ID, rule.skipAfter)
+		}
+	}
+
+	return &RuleSet{rules: rules}, nil
+}
+
+// Rules returns the loaded rules, in the order of evaluation.
+func (rs *RuleSet) Rules() []*Rule {
+	var rules []*Rule
+	for _, r := range rs.rules {
+		if r.marker == "" {
+			rules = append(rules, r)
+		}
+	}
+
+	return rules
+}
+
+// tells whether the rule has the chain action, but the chained rule is
+// not parsed yet
+func (r *Rule) chained() bool { return r.hasChain && r.chain == nil }
+
+func (r *Rule) lastInChain() *Rule {
+	last := r
+	for last.chain != nil {
+		last = last.chain
+	}
+
+	return last
+}
+
+// splits a directive into its arguments, handling the double quoted
+// arguments. Inside the quotes, only the escaped double quotes are
+// unescaped, the rest of the backslashes are kept, e.g. in the regular
+// expressions.
+func tokenize(line string) ([]string, error) {
+	var (
+		args    []string
+		current []byte
+		quoted  bool
+		inArg   bool
+	)
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quoted && c == '\\' && i+1 < len(line) && line[i+1] == '"':
+			current = append(current, '"')
+			i++
+		case c == '"':
+			quoted = !quoted
+			inArg = true
+		case !quoted && (c == ' ' || c == '\t'):
+			if inArg {
+				args = append(args, string(current))
+				current = nil
+				inArg = false
+			}
+		default:
+			current = append(current, c)
+			inArg = true
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+
+	if inArg {
+		args = append(args, string(current))
+	}
+
+	return args, nil
+}
+
+func parseIDRange(s string) (int, int, error) {
+	parts := strings.SplitN(s, "-", 2)
+	from, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid rule id: %s", s)
+	}
+
+	to := from
+	if len(parts) == 2 {
+		if to, err = strconv.Atoi(parts[1]); err != nil || to < from {
+			return 0, 0, fmt.Errorf("invalid rule id range: %s", s)
+		}
+	}
+
+	return from, to, nil
+}
+
+func inRanges(id int, ranges [][2]int) bool {
+	for _, r := range ranges {
+		if id >= r[0] && id <= r[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseRule(args []string) (*Rule, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, fmt.Errorf("invalid SecRule, expected variables, operator and actions")
+	}
+
+	vars, err := parseVariables(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	op, rx, err := parseOperator(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Rule{
+		Phase:     PhaseRequestBody,
+		variables: vars,
+		operator:  op,
+		rx:        rx,
+	}
+
+	if len(args) == 3 {
+		if err := r.parseActions(args[2]); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// parses a SecAction, an unconditionally matching rule
+func parseAction(args []string) (*Rule, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("invalid SecAction, expected actions")
+	}
+
+	r := &Rule{
+		Phase:         PhaseRequestBody,
+		operator:      func(*transaction, string) bool { return true },
+		unconditional: true,
+	}
+
+	if err := r.parseActions(args[0]); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func parseVariables(s string) ([]variable, error) {
+	var vars []variable
+	for _, vs := range strings.Split(s, "|") {
+		var v variable
+		switch {
+		case strings.HasPrefix(vs, "!"):
+			v.exclude = true
+			vs = vs[1:]
+		case strings.HasPrefix(vs, "&"):
+			v.count = true
+			vs = vs[1:]
+		}
+
+		parts := strings.SplitN(vs, ":", 2)
+		v.collection = strings.ToUpper(parts[0])
+		if _, ok := collections[v.collection]; !ok {
+			return nil, fmt.Errorf("unsupported variable: %s", parts[0])
+		}
+
+		if len(parts) == 2 {
+			key := parts[1]
+			if len(key) > 1 && strings.HasPrefix(key, "/") && strings.HasSuffix(key, "/") {
+				rx, err := regexp.Compile("(?i)" + key[1:len(key)-1])
+				if err != nil {
+					return nil, err
+				}
+
+				v.keyRx = rx
+			} else {
+				v.key = key
+			}
+		}
+
+		if v.exclude && v.key == "" && v.keyRx == nil {
+			return nil, fmt.Errorf("variable exclusion without a key: %s", vs)
+		}
+
+		vars = append(vars, v)
+	}
+
+	return vars, nil
+}
+
+// splits the actions at the commas that are not quoted
+func splitActions(s string) []string {
+	var (
+		actions []string
+		quoted  bool
+		start   int
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				actions = append(actions, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(actions, s[start:])
+}
+
+func (r *Rule) parseActions(s string) error {
+	for _, a := range splitActions(s) {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+
+		parts := strings.SplitN(a, ":", 2)
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		var value string
+		if len(parts) == 2 {
+			value = strings.Trim(strings.TrimSpace(parts[1]), "'")
+		}
+
+		var err error
+		switch {
+		case name == "id":
+			r.ID, err = strconv.Atoi(value)
+		case name == "phase":
+			r.Phase, err = parsePhase(value)
+		case name == "msg":
+			r.Message = value
+		case name == "severity":
+			r.Severity = strings.ToUpper(value)
+		case name == "deny", name == "drop":
+			r.Deny, r.block, r.allow = true, false, ""
+		case name == "block":
+			r.Deny, r.block, r.allow = false, true, ""
+		case name == "pass":
+			r.Deny, r.block, r.allow = false, false, ""
+		case name == "allow":
+			r.Deny, r.block = false, false
+			r.allow, err = parseAllow(value)
+		case name == "skipafter":
+			if value == "" {
+				return fmt.Errorf("missing marker: %s", a)
+			}
+
+			r.skipAfter = value
+		case name == "setvar":
+			var sv setVar
+			if sv, err = parseSetVar(value); err != nil {
+				return err
+			}
+
+			r.setVars = append(r.setVars, sv)
+		case name == "ctl":
+			var c control
+			if c, err = parseControl(value); err != nil {
+				return err
+			}
+
+			if c.name != "" {
+				r.controls = append(r.controls, c)
+			}
+		case name == "capture":
+			r.capture = true
+		case name == "log":
+			r.noLog = false
+		case name == "nolog":
+			r.noLog = true
+		case name == "status":
+			r.Status, err = strconv.Atoi(value)
+		case name == "t":
+			if strings.ToLower(value) == "none" {
+				r.transformations = nil
+				continue
+			}
+
+			t, ok := transformations[strings.ToLower(value)]
+			if !ok {
+				return fmt.Errorf("unsupported transformation: %s", value)
+			}
+
+			r.transformations = append(r.transformations, t)
+		case name == "chain":
+			r.hasChain = true
+		case ignoredActions[name]:
+		default:
+			return fmt.Errorf("unsupported action: %s", parts[0])
+		}
+
+		if err != nil {
+			return fmt.Errorf("invalid action: %s", a)
+		}
+	}
+
+	return nil
+}
+
+func parsePhase(s string) (int, error) {
+	switch strings.ToLower(s) {
+	case "request":
+		return PhaseRequestBody, nil
+	case "response":
+		return PhaseResponseBody, nil
+	}
+
+	p, err := strconv.Atoi(s)
+	if err != nil || p < PhaseRequestHeaders || p > PhaseResponseBody {
+		return 0, fmt.Errorf("unsupported phase: %s", s)
+	}
+
+	return p, nil
+}
+
+func parseAllow(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return allowAll, nil
+	case "phase":
+		return allowPhase, nil
+	case "request":
+		return allowRequest, nil
+	default:
+		return "", fmt.Errorf("unsupported allow action: %s", s)
+	}
+}
+
+// parses a setvar action, e.g. tx.score=+5, !tx.flag or tx.flag. Only the
+// TX collection is supported.
+func parseSetVar(s string) (setVar, error) {
+	var sv setVar
+	if strings.HasPrefix(s, "!") {
+		sv.delete = true
+		s = s[1:]
+	}
+
+	parts := strings.SplitN(s, "=", 2)
+	name := strings.ToLower(strings.TrimSpace(parts[0]))
+	if !strings.HasPrefix(name, "tx.") || len(name) == len("tx.") {
+		return sv, fmt.Errorf("unsupported setvar, only the TX collection is supported: %s", s)
+	}
+
+	sv.name = name[len("tx."):]
+	sv.op = '='
+	sv.value = "1"
+	if len(parts) == 2 {
+		sv.value = parts[1]
+		if strings.HasPrefix(sv.value, "+") || strings.HasPrefix(sv.value, "-") {
+			sv.op = sv.value[0]
+			sv.value = sv.value[1:]
+		}
+	}
+
+	return sv, nil
+}
+
+// parses a ctl action. It supports ruleRemoveById and ruleEngine, and
+// ignores the audit log options. It returns an empty control for the
+// ignored options.
+func parseControl(s string) (control, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return control{}, fmt.Errorf("invalid ctl action: %s", s)
+	}
+
+	c := control{name: strings.ToLower(parts[0]), value: parts[1]}
+	switch {
+	case c.name == "ruleremovebyid":
+		from, to, err := parseIDRange(c.value)
+		if err != nil {
+			return control{}, err
+		}
+
+		c.from, c.to = from, to
+	case c.name == "ruleengine":
+		c.value = strings.ToLower(c.value)
+		switch c.value {
+		case "on", "off", "detectiononly":
+		default:
+			return control{}, fmt.Errorf("invalid ctl action: %s", s)
+		}
+	case ignoredControls[c.name]:
+		return control{}, nil
+	default:
+		return control{}, fmt.Errorf("unsupported ctl action: %s", s)
+	}
+
+	return c, nil
+}
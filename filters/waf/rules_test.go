@@ -0,0 +1,215 @@
+package waf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	rs, err := LoadRules([]string{"testdata/rules.conf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules := rs.Rules()
+	if len(rules) != 7 {
+		t.Fatalf("failed to parse the rules, got: %d", len(rules))
+	}
+
+	r := rules[1]
+	if r.ID != 942100 || r.Phase != PhaseRequestBody || !r.Deny || r.Severity != "CRITICAL" ||
+		r.Message != "SQL Injection Attack Detected" || len(r.transformations) != 2 || len(r.variables) != 3 {
+		t.Errorf("failed to parse the rule: %+v", r)
+	}
+
+	if rules[2].Status != 406 {
+		t.Errorf("failed to parse the status, got: %d", rules[2].Status)
+	}
+
+	if rules[3].Deny {
+		t.Error("failed to parse the pass action")
+	}
+
+	if rules[4].chain == nil || rules[4].chain.ID != 920440 || rules[4].chain.Phase != PhaseRequestHeaders {
+		t.Error("failed to parse the chained rule")
+	}
+}
+
+func TestParseRulesRemoveByID(t *testing.T) {
+	rs, err := ParseRules(strings.NewReader(`
+SecRule ARGS "foo" "id:1,deny"
+SecRule ARGS "bar" "id:2,deny"
+SecRule ARGS "baz" "id:13,deny"
+SecRuleRemoveById 1 10-20
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rs.Rules()) != 1 || rs.Rules()[0].ID != 2 {
+		t.Errorf("failed to remove the rules: %+v", rs.Rules())
+	}
+}
+
+func TestParseRulesInvalid(t *testing.T) {
+	for _, test := range []struct {
+		title string
+		rules string
+	}{{
+		title: "unsupported directive",
+		rules: `SecFoo On`,
+	}, {
+		title: "missing operator",
+		rules: `SecRule ARGS`,
+	}, {
+		title: "unsupported variable",
+		rules: `SecRule FOO "bar" "id:1"`,
+	}, {
+		title: "unsupported operator",
+		rules: `SecRule ARGS "@detectSQLi" "id:1"`,
+	}, {
+		title: "invalid regular expression",
+		rules: `SecRule ARGS "@rx foo(?=bar)" "id:1"`,
+	}, {
+		title: "unsupported action",
+		rules: `SecRule ARGS "foo" "id:1,exec:/bin/sh"`,
+	}, {
+		title: "unsupported transformation",
+		rules: `SecRule ARGS "foo" "id:1,t:sqlHexDecode"`,
+	}, {
+		title: "missing id",
+		rules: `SecRule ARGS "foo" "deny"`,
+	}, {
+		title: "invalid phase",
+		rules: `SecRule ARGS "foo" "id:1,phase:5"`,
+	}, {
+		title: "unterminated quote",
+		rules: `SecRule ARGS "foo "id:1"`,
+	}, {
+		title: "missing chained rule",
+		rules: `SecRule ARGS "foo" "id:1,chain"`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			if _, err := ParseRules(strings.NewReader(test.rules)); err == nil {
+				t.Error("failed to fail")
+			}
+		})
+	}
+}
+
+func TestTransformations(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"urldecode", "union%20select+1%zz", "union select 1%zz"},
+		{"htmlentitydecode", "&lt;script&gt;", "<script>"},
+		{"compresswhitespace", " a \t\n b ", "a b"},
+		{"removewhitespace", " a \t b ", "ab"},
+		{"base64decode", "Zm9v", "foo"},
+		{"normalizepath", "/a/./b/../../etc//passwd", "/etc/passwd"},
+		{"length", "foo", "3"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if v := transformations[test.name](test.input); v != test.expected {
+				t.Errorf("expected: %q, got: %q", test.expected, v)
+			}
+		})
+	}
+}
+
+func TestParseRulesBlock(t *testing.T) {
+	rs, err := ParseRules(strings.NewReader(`
+SecRule ARGS "foo" "id:1,phase:2,block"
+SecDefaultAction "phase:2,log,deny,status:406"
+SecRule ARGS "bar" "id:2,phase:2,block"
+SecRule ARGS "baz" "id:3,phase:1,block"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules := rs.Rules()
+	if rules[0].Deny {
+		t.Error("block without SecDefaultAction should pass")
+	}
+
+	if !rules[1].Deny || rules[1].Status != 406 {
+		t.Error("block should apply the SecDefaultAction of the phase")
+	}
+
+	if rules[2].Deny {
+		t.Error("block should apply the SecDefaultAction of its own phase")
+	}
+}
+
+func TestLoadRulesAcrossFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "waf-rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"setup.conf": `SecDefaultAction "phase:2,deny"`,
+		"rules.conf": `SecRule ARGS "foo" "id:1,phase:2,block"` + "\n" + `SecRule ARGS "bar" "id:2,phase:2,block"`,
+		"after.conf": `SecRuleRemoveById 2`,
+	}
+
+	var names []string
+	for _, n := range []string{"setup.conf", "rules.conf", "after.conf"} {
+		fn := filepath.Join(dir, n)
+		if err := ioutil.WriteFile(fn, []byte(files[n]), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		names = append(names, fn)
+	}
+
+	rs, err := LoadRules(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rs.Rules()) != 1 || !rs.Rules()[0].Deny {
+		t.Errorf("failed to apply the directives across the files: %+v", rs.Rules())
+	}
+}
+
+func TestParseRulesUnsupportedControlFlow(t *testing.T) {
+	for _, test := range []struct {
+		title string
+		rules string
+	}{{
+		title: "setvar on a persistent collection",
+		rules: `SecRule ARGS "foo" "id:1,setvar:ip.blocked=1"`,
+	}, {
+		title: "unsupported ctl",
+		rules: `SecRule ARGS "foo" "id:1,ctl:ruleRemoveTargetById=942100;ARGS:q"`,
+	}, {
+		title: "missing marker",
+		rules: `SecRule ARGS "foo" "id:1,skipAfter:END"`,
+	}, {
+		title: "marker before the rule",
+		rules: "SecMarker END\n" + `SecRule ARGS "foo" "id:1,skipAfter:END"`,
+	}, {
+		title: "macro in a regular expression",
+		rules: `SecRule ARGS "@rx %{tx.foo}" "id:1"`,
+	}, {
+		title: "block in SecDefaultAction",
+		rules: `SecDefaultAction "phase:2,block"`,
+	}, {
+		title: "invalid allow",
+		rules: `SecRule ARGS "foo" "id:1,allow:foo"`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			if _, err := ParseRules(strings.NewReader(test.rules)); err == nil {
+				t.Error("failed to fail")
+			}
+		})
+	}
+}
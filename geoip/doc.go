@@ -0,0 +1,43 @@
+/*
+Package geoip implements the lookup of the country and the autonomous
+system of the clients in GeoIP databases, and the rejection of the
+requests coming from the sources listed in IP reputation feeds.
+
+GeoIP
+
+The databases need to be in the MaxMind DB format, e.g. the GeoLite2
+Country and ASN databases. When multiple databases are configured, the
+records found in them are merged:
+
+    skipper -geoip-db /var/lib/geoip/GeoLite2-Country.mmdb,/var/lib/geoip/GeoLite2-ASN.mmdb
+
+The database files are checked for changes periodically, and loaded
+again when they change, e.g. after an update by geoipupdate, without
+restarting skipper. When loading an updated file fails, the current
+database is kept.
+
+The record of the client is looked up once per request, and it is used
+by the Country and the ASN predicates, see the skipper/predicates/geoip
+package, by the geoip filter, see the skipper/filters/geoip package, and
+by the JSON access log, when the country or the asn field is configured:
+
+    skipper -geoip-db GeoLite2-Country.mmdb -access-log-format json -access-log-json-fields timestamp,uri,status,country
+
+By default, the address of the client is taken from the connection.
+When skipper runs behind a load balancer, the -geoip-trust-forwarded flag
+enables taking it from the X-Forwarded-For header.
+
+IP Reputation
+
+The IP reputation feeds contain the known bad addresses and networks, one
+per line. The text after the first whitespace, and the lines starting
+with # or ; are ignored, so that the common blocklist formats can be
+used directly. The feeds can be files or http(s) URLs, and they are
+reloaded periodically:
+
+    skipper -ip-reputation https://www.spamhaus.org/drop/drop.txt,/etc/skipper/blocked.txt
+
+The requests coming from the listed sources are rejected with 403,
+before any other processing.
+*/
+package geoip
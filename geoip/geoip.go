@@ -0,0 +1,292 @@
+package geoip
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/logging"
+	snet "github.com/zalando/skipper/net"
+)
+
+// DefaultReloadInterval is the default interval of checking the database
+// files for changes.
+const DefaultReloadInterval = time.Minute
+
+var errNoDatabase = errors.New("geoip: no database")
+
+type recordKey struct{}
+
+// Options for creating a GeoIP database.
+type Options struct {
+
+	// The paths of the database files, in the MaxMind DB format, e.g.
+	// GeoLite2-Country.mmdb and GeoLite2-ASN.mmdb. The records of the
+	// same address found in the different files are merged. Required.
+	Databases []string
+
+	// The interval of checking the database files for changes. When a
+	// file changed, it is loaded again. 0 means the default interval,
+	// 1m, while a negative value disables the reloading.
+	ReloadInterval time.Duration
+
+	// When set, the IP of the clients is taken from the
+	// X-Forwarded-For header. Only use it, when skipper runs behind
+	// a load balancer that sets the header.
+	TrustForwardedFor bool
+}
+
+// Record contains the information found about an IP address.
+type Record struct {
+
+	// The ISO 3166-1 alpha-2 code of the country, e.g. DE, or empty,
+	// when not known.
+	Country string
+
+	// The number of the autonomous system, or 0, when not known.
+	ASN uint
+
+	// The organization of the autonomous system.
+	Organization string
+}
+
+// the fields of the country, city and ASN databases
+type dbRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+
+	ASN          uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+type database struct {
+	path    string
+	modTime time.Time
+	size    int64
+	reader  *maxminddb.Reader
+}
+
+// DB looks up the country and the autonomous system of IP addresses in
+// MaxMind databases, and reloads the databases, when their files change.
+type DB struct {
+	options   Options
+	databases atomic.Value
+	quit      chan struct{}
+	done      chan struct{}
+}
+
+type handler struct {
+	db   *DB
+	next http.Handler
+}
+
+// the database is read into memory, so that a reloaded file can
+// replace it while lookups are in progress
+func openDatabase(path string) (*database, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := maxminddb.FromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &database{
+		path:    path,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		reader:  r,
+	}, nil
+}
+
+// New creates a GeoIP database, loading the configured files.
+func New(o Options) (*DB, error) {
+	if len(o.Databases) == 0 {
+		return nil, errNoDatabase
+	}
+
+	var dbs []*database
+	for _, p := range o.Databases {
+		d, err := openDatabase(p)
+		if err != nil {
+			return nil, err
+		}
+
+		dbs = append(dbs, d)
+	}
+
+	db := &DB{
+		options: o,
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	db.databases.Store(dbs)
+
+	if o.ReloadInterval == 0 {
+		o.ReloadInterval = DefaultReloadInterval
+	}
+
+	if o.ReloadInterval < 0 {
+		close(db.done)
+		return db, nil
+	}
+
+	go db.run(o.ReloadInterval)
+	return db, nil
+}
+
+func (db *DB) current() []*database {
+	return db.databases.Load().([]*database)
+}
+
+// loads the changed files again, and keeps the current databases on
+// failure
+func (db *DB) reload() {
+	current := db.current()
+	next := make([]*database, len(current))
+	var changed bool
+	for i, d := range current {
+		next[i] = d
+		info, err := os.Stat(d.path)
+		if err != nil {
+			log.Errorf("error while checking GeoIP database %s: %v", d.path, err)
+			continue
+		}
+
+		if info.ModTime().Equal(d.modTime) && info.Size() == d.size {
+			continue
+		}
+
+		nd, err := openDatabase(d.path)
+		if err != nil {
+			log.Errorf("error while reloading GeoIP database %s: %v", d.path, err)
+			continue
+		}
+
+		log.Infof("GeoIP database reloaded: %s", d.path)
+		next[i] = nd
+		changed = true
+	}
+
+	if changed {
+		db.databases.Store(next)
+	}
+}
+
+func (db *DB) run(interval time.Duration) {
+	defer close(db.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			db.reload()
+		case <-db.quit:
+			return
+		}
+	}
+}
+
+// Lookup returns the record of an IP address. The fields that were not
+// found are left empty.
+func (db *DB) Lookup(ip net.IP) Record {
+	var rec Record
+	if ip == nil {
+		return rec
+	}
+
+	for _, d := range db.current() {
+		var dr dbRecord
+		if err := d.reader.Lookup(ip, &dr); err != nil {
+			log.Debugf("error while looking up %s in GeoIP database %s: %v", ip, d.path, err)
+			continue
+		}
+
+		if rec.Country == "" {
+			rec.Country = strings.ToUpper(dr.Country.ISOCode)
+		}
+
+		if rec.ASN == 0 {
+			rec.ASN = dr.ASN
+			rec.Organization = dr.Organization
+		}
+	}
+
+	return rec
+}
+
+func clientIP(r *http.Request, trustForwardedFor bool) net.IP {
+	if trustForwardedFor {
+		if ip := snet.RemoteHost(r); ip != nil {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
+
+// ClientIP returns the IP of the client sending the request.
+func (db *DB) ClientIP(r *http.Request) net.IP {
+	return clientIP(r, db.options.TrustForwardedFor)
+}
+
+// LookupRequest returns the record of the client sending the request.
+// When the request went through the handler returned by Wrap, the
+// record looked up by the handler is returned.
+func (db *DB) LookupRequest(r *http.Request) Record {
+	if rec, ok := r.Context().Value(recordKey{}).(Record); ok {
+		return rec
+	}
+
+	return db.Lookup(db.ClientIP(r))
+}
+
+// Wrap returns a handler that looks up the client of each request once,
+// makes the record available to the predicates and the filters, and
+// sets the country and the ASN for the access log.
+func (db *DB) Wrap(next http.Handler) http.Handler {
+	return &handler{db: db, next: next}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec := h.db.Lookup(h.db.ClientIP(r))
+	if d := logging.ProxyDetailsFromContext(r.Context()); d != nil {
+		d.Country = rec.Country
+		d.ASN = rec.ASN
+	}
+
+	h.next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), recordKey{}, rec)))
+}
+
+// Close stops reloading the databases.
+func (db *DB) Close() {
+	select {
+	case <-db.quit:
+	default:
+		close(db.quit)
+	}
+
+	<-db.done
+}
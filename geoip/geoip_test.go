@@ -0,0 +1,181 @@
+package geoip
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/geoip/geoiptest"
+	"github.com/zalando/skipper/logging"
+)
+
+func writeDatabases(t *testing.T) (string, []string) {
+	dir, err := ioutil.TempDir("", "geoip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	country := filepath.Join(dir, "country.mmdb")
+	if err := geoiptest.WriteFile(country, "GeoLite2-Country", []geoiptest.Network{
+		{CIDR: "192.0.2.0/24", Country: "DE"},
+		{CIDR: "198.51.100.0/24", Country: "at"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	asn := filepath.Join(dir, "asn.mmdb")
+	if err := geoiptest.WriteFile(asn, "GeoLite2-ASN", []geoiptest.Network{
+		{CIDR: "192.0.2.0/25", ASN: 64496, Organization: "Example"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir, []string{country, asn}
+}
+
+func TestMissingDatabase(t *testing.T) {
+	if _, err := New(Options{}); err == nil {
+		t.Error("failed to fail")
+	}
+
+	if _, err := New(Options{Databases: []string{"/no/such/file.mmdb"}}); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	dir, files := writeDatabases(t)
+	defer os.RemoveAll(dir)
+
+	db, err := New(Options{Databases: files, ReloadInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	for _, ti := range []struct {
+		ip       string
+		expected Record
+	}{{
+		ip:       "192.0.2.1",
+		expected: Record{Country: "DE", ASN: 64496, Organization: "Example"},
+	}, {
+		ip:       "192.0.2.200",
+		expected: Record{Country: "DE"},
+	}, {
+		ip:       "198.51.100.1",
+		expected: Record{Country: "AT"},
+	}, {
+		ip: "203.0.113.1",
+	}, {
+		ip: "2001:db8::1",
+	}} {
+		if rec := db.Lookup(net.ParseIP(ti.ip)); rec != ti.expected {
+			t.Errorf("%s: expected %v, got %v", ti.ip, ti.expected, rec)
+		}
+	}
+
+	if rec := db.Lookup(nil); rec != (Record{}) {
+		t.Error("unexpected record for nil", rec)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	r, _ := http.NewRequest("GET", "https://www.example.org", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 192.0.2.1")
+
+	db := &DB{}
+	if ip := db.ClientIP(r); !ip.Equal(net.ParseIP("192.0.2.1")) {
+		t.Error("invalid client IP", ip)
+	}
+
+	db.options.TrustForwardedFor = true
+	if ip := db.ClientIP(r); !ip.Equal(net.ParseIP("198.51.100.1")) {
+		t.Error("invalid forwarded client IP", ip)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	dir, files := writeDatabases(t)
+	defer os.RemoveAll(dir)
+
+	db, err := New(Options{Databases: files, ReloadInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	var rec Record
+	h := db.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the record comes from the context, even when the address
+		// changes
+		r.RemoteAddr = "203.0.113.1:1234"
+		rec = db.LookupRequest(r)
+	}))
+
+	r, _ := http.NewRequest("GET", "https://www.example.org", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	d := &logging.ProxyDetails{}
+	r = r.WithContext(logging.ContextWithProxyDetails(r.Context(), d))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if rec.Country != "DE" || rec.ASN != 64496 {
+		t.Error("invalid record", rec)
+	}
+
+	if d.Country != "DE" || d.ASN != 64496 {
+		t.Error("invalid proxy details", d.Country, d.ASN)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir, files := writeDatabases(t)
+	defer os.RemoveAll(dir)
+
+	db, err := New(Options{Databases: files, ReloadInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	ip := net.ParseIP("203.0.113.1")
+	if rec := db.Lookup(ip); rec.Country != "" {
+		t.Fatal("unexpected country", rec.Country)
+	}
+
+	if err := geoiptest.WriteFile(files[0], "GeoLite2-Country", []geoiptest.Network{
+		{CIDR: "203.0.113.0/24", Country: "FR"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the modification time may not change on some filesystems within
+	// the test, but the size does
+	timeout := time.After(3 * time.Second)
+	for db.Lookup(ip).Country != "FR" {
+		select {
+		case <-timeout:
+			t.Fatal("failed to reload the database")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// invalid files are ignored
+	if err := ioutil.WriteFile(files[0], []byte("invalid"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if rec := db.Lookup(ip); rec.Country != "FR" {
+		t.Error("failed to keep the database", rec.Country)
+	}
+}
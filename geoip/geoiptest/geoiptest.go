@@ -0,0 +1,246 @@
+/*
+Package geoiptest writes small databases in the MaxMind DB format, for
+testing the components using GeoIP lookups.
+*/
+package geoiptest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net"
+	"sort"
+)
+
+// Network describes the record of an IPv4 network.
+type Network struct {
+
+	// The network, e.g. 192.0.2.0/24.
+	CIDR string
+
+	// The ISO country code, written as country.iso_code when set.
+	Country string
+
+	// The autonomous system number, written as
+	// autonomous_system_number when set.
+	ASN uint
+
+	// The organization of the autonomous system, written as
+	// autonomous_system_organization when set.
+	Organization string
+}
+
+const (
+	typePointer = iota + 1
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+
+	recordSize = 24
+)
+
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+type node struct {
+	// 0: empty, positive: index of the next node + 1, negative: -(index
+	// of the data + 1)
+	children [2]int
+}
+
+func writeControl(b *bytes.Buffer, typ, size int) {
+	var sizeBits int
+	var sizeBytes []byte
+	switch {
+	case size < 29:
+		sizeBits = size
+	case size < 285:
+		sizeBits = 29
+		sizeBytes = []byte{byte(size - 29)}
+	case size < 65821:
+		sizeBits = 30
+		sizeBytes = []byte{byte((size - 285) >> 8), byte(size - 285)}
+	default:
+		// the tests don't need the longer values
+		panic("value too long")
+	}
+
+	if typ <= typeMap {
+		b.WriteByte(byte(typ<<5 | sizeBits))
+	} else {
+		b.WriteByte(byte(sizeBits))
+		b.WriteByte(byte(typ - 7))
+	}
+
+	b.Write(sizeBytes)
+}
+
+func writeUint(b *bytes.Buffer, typ int, v uint64) {
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], v)
+	i := 0
+	for i < len(n) && n[i] == 0 {
+		i++
+	}
+
+	writeControl(b, typ, len(n)-i)
+	b.Write(n[i:])
+}
+
+func encode(b *bytes.Buffer, v interface{}) {
+	switch vt := v.(type) {
+	case string:
+		writeControl(b, typeString, len(vt))
+		b.WriteString(vt)
+	case uint16:
+		writeUint(b, typeUint16, uint64(vt))
+	case uint32:
+		writeUint(b, typeUint32, uint64(vt))
+	case uint64:
+		writeUint(b, typeUint64, vt)
+	case []interface{}:
+		writeControl(b, typeArray, len(vt))
+		for _, vi := range vt {
+			encode(b, vi)
+		}
+	case map[string]interface{}:
+		var keys []string
+		for k := range vt {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		writeControl(b, typeMap, len(vt))
+		for _, k := range keys {
+			encode(b, k)
+			encode(b, vt[k])
+		}
+	default:
+		panic("unsupported type")
+	}
+}
+
+func (n Network) record() map[string]interface{} {
+	r := make(map[string]interface{})
+	if n.Country != "" {
+		r["country"] = map[string]interface{}{"iso_code": n.Country}
+	}
+
+	if n.ASN != 0 {
+		r["autonomous_system_number"] = uint32(n.ASN)
+	}
+
+	if n.Organization != "" {
+		r["autonomous_system_organization"] = n.Organization
+	}
+
+	return r
+}
+
+// Build creates an IPv4 database containing the networks. The networks
+// must not overlap.
+func Build(dbType string, networks []Network) ([]byte, error) {
+	nodes := []node{{}}
+	var data bytes.Buffer
+	for i, n := range networks {
+		_, ipn, err := net.ParseCIDR(n.CIDR)
+		if err != nil {
+			return nil, err
+		}
+
+		ip := ipn.IP.To4()
+		if ip == nil {
+			return nil, errors.New("only IPv4 networks are supported")
+		}
+
+		ones, _ := ipn.Mask.Size()
+		if ones == 0 {
+			return nil, errors.New("empty prefix not supported")
+		}
+
+		current := 0
+		for bit := 0; bit < ones; bit++ {
+			b := int(ip[bit/8]>>(7-uint(bit%8))) & 1
+			child := nodes[current].children[b]
+			if child < 0 {
+				return nil, errors.New("overlapping networks")
+			}
+
+			if bit == ones-1 {
+				if child != 0 {
+					return nil, errors.New("overlapping networks")
+				}
+
+				nodes[current].children[b] = -(i + 1)
+				break
+			}
+
+			if child == 0 {
+				nodes = append(nodes, node{})
+				child = len(nodes)
+				nodes[current].children[b] = child
+			}
+
+			current = child - 1
+		}
+	}
+
+	offsets := make([]int, len(networks))
+	for i, n := range networks {
+		offsets[i] = data.Len()
+		encode(&data, n.record())
+	}
+
+	var db bytes.Buffer
+	nodeCount := len(nodes)
+	for _, n := range nodes {
+		for _, c := range n.children {
+			var v int
+			switch {
+			case c == 0:
+				v = nodeCount
+			case c > 0:
+				v = c - 1
+			default:
+				v = nodeCount + 16 + offsets[-c-1]
+			}
+
+			db.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+
+	db.Write(make([]byte, 16))
+	db.Write(data.Bytes())
+	db.Write(metadataMarker)
+	encode(&db, map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1),
+		"database_type":               dbType,
+		"description":                 map[string]interface{}{"en": "test database"},
+		"ip_version":                  uint16(4),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+	})
+
+	return db.Bytes(), nil
+}
+
+// WriteFile creates an IPv4 database containing the networks, and writes
+// it to a file.
+func WriteFile(path, dbType string, networks []Network) error {
+	b, err := Build(dbType, networks)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, b, 0644)
+}
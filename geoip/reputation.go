@@ -0,0 +1,289 @@
+package geoip
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultRefreshInterval is the default interval of reloading the
+	// IP reputation feeds.
+	DefaultRefreshInterval = 10 * time.Minute
+
+	defaultFeedTimeout = 30 * time.Second
+)
+
+var errNoFeed = errors.New("geoip: no reputation feed")
+
+// ReputationOptions for creating an IP reputation list.
+type ReputationOptions struct {
+
+	// The feeds of the known bad addresses, as file paths or http(s)
+	// URLs. The feeds contain one address or network per line, e.g.
+	// 192.0.2.1 or 198.51.100.0/24. The text after the first
+	// whitespace, and the lines starting with # or ; are ignored, so
+	// the common blocklist formats can be used directly. Required.
+	Feeds []string
+
+	// The interval of reloading the feeds. 0 means the default
+	// interval, 10m, while a negative value disables the reloading.
+	RefreshInterval time.Duration
+
+	// Timeout of downloading the feeds. Default: 30s.
+	Timeout time.Duration
+
+	// When set, the IP of the clients is taken from the
+	// X-Forwarded-For header. Only use it, when skipper runs behind
+	// a load balancer that sets the header.
+	TrustForwardedFor bool
+}
+
+// the networks grouped by their prefix length, so that a lookup needs
+// only one map access per distinct prefix length
+type netSet struct {
+	prefixes []int
+	networks map[int]map[string]struct{}
+	size     int
+}
+
+// Reputation contains the addresses and the networks loaded from the IP
+// reputation feeds, and rejects the requests coming from them.
+type Reputation struct {
+	options ReputationOptions
+	client  *http.Client
+	current atomic.Value
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+type reputationHandler struct {
+	reputation *Reputation
+	next       http.Handler
+}
+
+func normalizeIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+
+	return ip
+}
+
+func newNetSet() *netSet {
+	return &netSet{networks: make(map[int]map[string]struct{})}
+}
+
+func (s *netSet) add(n *net.IPNet) {
+	ones, _ := n.Mask.Size()
+	m, ok := s.networks[ones]
+	if !ok {
+		m = make(map[string]struct{})
+		s.networks[ones] = m
+		s.prefixes = append(s.prefixes, ones)
+		sort.Ints(s.prefixes)
+	}
+
+	key := string(normalizeIP(n.IP))
+	if _, ok := m[key]; !ok {
+		m[key] = struct{}{}
+		s.size++
+	}
+}
+
+func (s *netSet) contains(ip net.IP) bool {
+	ip = normalizeIP(ip)
+	bits := len(ip) * 8
+	for _, p := range s.prefixes {
+		if p > bits {
+			continue
+		}
+
+		if _, ok := s.networks[p][string(ip.Mask(net.CIDRMask(p, bits)))]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseNetwork(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, n, err := net.ParseCIDR(s)
+		return n, err
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address: %s", s)
+	}
+
+	ip = normalizeIP(ip)
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}, nil
+}
+
+// parses a feed, skipping the invalid lines
+func parseFeed(r io.Reader, s *netSet) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+
+		n, err := parseNetwork(fields[0])
+		if err != nil {
+			log.Debugf("invalid entry in IP reputation feed: %v", err)
+			continue
+		}
+
+		s.add(n)
+	}
+
+	return scanner.Err()
+}
+
+// NewReputation creates an IP reputation list, loading the feeds.
+func NewReputation(o ReputationOptions) (*Reputation, error) {
+	if len(o.Feeds) == 0 {
+		return nil, errNoFeed
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = defaultFeedTimeout
+	}
+
+	r := &Reputation{
+		options: o,
+		client:  &http.Client{Timeout: o.Timeout},
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	s, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	r.current.Store(s)
+
+	if o.RefreshInterval == 0 {
+		o.RefreshInterval = DefaultRefreshInterval
+	}
+
+	if o.RefreshInterval < 0 {
+		close(r.done)
+		return r, nil
+	}
+
+	go r.run(o.RefreshInterval)
+	return r, nil
+}
+
+func (r *Reputation) open(feed string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(feed, "http://") && !strings.HasPrefix(feed, "https://") {
+		return os.Open(feed)
+	}
+
+	rsp, err := r.client.Get(feed)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		rsp.Body.Close()
+		return nil, fmt.Errorf("failed to download IP reputation feed %s: %d", feed, rsp.StatusCode)
+	}
+
+	return rsp.Body, nil
+}
+
+func (r *Reputation) load() (*netSet, error) {
+	s := newNetSet()
+	for _, f := range r.options.Feeds {
+		rc, err := r.open(f)
+		if err != nil {
+			return nil, err
+		}
+
+		err = parseFeed(rc, s)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// reloads the feeds, and keeps the current list on failure
+func (r *Reputation) refresh() {
+	s, err := r.load()
+	if err != nil {
+		log.Errorf("error while reloading IP reputation feeds: %v", err)
+		return
+	}
+
+	log.Debugf("IP reputation feeds reloaded, number of entries: %d", s.size)
+	r.current.Store(s)
+}
+
+func (r *Reputation) run(interval time.Duration) {
+	defer close(r.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.refresh()
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// Listed tells whether an IP address is contained by the feeds.
+func (r *Reputation) Listed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	return r.current.Load().(*netSet).contains(ip)
+}
+
+// Wrap returns a handler that rejects the requests coming from the
+// addresses contained by the feeds with 403.
+func (r *Reputation) Wrap(next http.Handler) http.Handler {
+	return &reputationHandler{reputation: r, next: next}
+}
+
+func (h *reputationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if ip := clientIP(r, h.reputation.options.TrustForwardedFor); h.reputation.Listed(ip) {
+		log.Debugf("rejected request from IP with bad reputation: %s", ip)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+// Close stops reloading the feeds.
+func (r *Reputation) Close() {
+	select {
+	case <-r.quit:
+	default:
+		close(r.quit)
+	}
+
+	<-r.done
+}
@@ -0,0 +1,138 @@
+package geoip
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testFeed = `
+# known bad sources
+192.0.2.1
+198.51.100.0/24 ; SBL000001
+2001:db8::/32
+invalid
+; comment
+`
+
+func TestParseFeed(t *testing.T) {
+	s := newNetSet()
+	if err := parseFeed(strings.NewReader(testFeed), s); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.size != 3 {
+		t.Error("invalid number of entries", s.size)
+	}
+
+	for ip, listed := range map[string]bool{
+		"192.0.2.1":        true,
+		"192.0.2.2":        false,
+		"198.51.100.42":    true,
+		"203.0.113.1":      false,
+		"2001:db8::1":      true,
+		"2001:db9::1":      false,
+		"::ffff:192.0.2.1": true,
+	} {
+		if s.contains(net.ParseIP(ip)) != listed {
+			t.Errorf("%s: expected listed: %t", ip, listed)
+		}
+	}
+}
+
+func TestReputationFromURL(t *testing.T) {
+	feed := "192.0.2.1\n"
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(feed))
+	}))
+	defer s.Close()
+
+	r, err := NewReputation(ReputationOptions{Feeds: []string{s.URL}, RefreshInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.Close()
+
+	if !r.Listed(net.ParseIP("192.0.2.1")) {
+		t.Error("failed to list address")
+	}
+
+	feed = "192.0.2.2\n"
+	r.refresh()
+	if r.Listed(net.ParseIP("192.0.2.1")) || !r.Listed(net.ParseIP("192.0.2.2")) {
+		t.Error("failed to refresh feed")
+	}
+
+	s.Close()
+	r.refresh()
+	if !r.Listed(net.ParseIP("192.0.2.2")) {
+		t.Error("failed to keep the feed")
+	}
+}
+
+func TestReputationFailsWithoutFeed(t *testing.T) {
+	if _, err := NewReputation(ReputationOptions{}); err == nil {
+		t.Error("failed to fail")
+	}
+
+	if _, err := NewReputation(ReputationOptions{Feeds: []string{"/no/such/feed"}}); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestReputationWrap(t *testing.T) {
+	f, err := ioutil.TempFile("", "reputation")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(f.Name())
+	f.WriteString(testFeed)
+	f.Close()
+
+	r, err := NewReputation(ReputationOptions{
+		Feeds:             []string{f.Name()},
+		RefreshInterval:   time.Hour,
+		TrustForwardedFor: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.Close()
+
+	h := r.Wrap(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	for _, ti := range []struct {
+		remoteAddr string
+		forwarded  string
+		status     int
+	}{{
+		remoteAddr: "192.0.2.1:1234",
+		status:     http.StatusForbidden,
+	}, {
+		remoteAddr: "203.0.113.1:1234",
+		status:     http.StatusOK,
+	}, {
+		remoteAddr: "203.0.113.1:1234",
+		forwarded:  "198.51.100.1",
+		status:     http.StatusForbidden,
+	}} {
+		req, _ := http.NewRequest("GET", "https://www.example.org", nil)
+		req.RemoteAddr = ti.remoteAddr
+		if ti.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", ti.forwarded)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != ti.status {
+			t.Errorf("%s %s: expected %d, got %d", ti.remoteAddr, ti.forwarded, ti.status, w.Code)
+		}
+	}
+}
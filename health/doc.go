@@ -0,0 +1,65 @@
+/*
+Package health implements the liveness and the readiness endpoints of
+the proxy, for the load balancers and the Kubernetes probes.
+
+When enabled, the endpoints are served on the support listener:
+
+    /alive        the liveness of the process, always 200
+    /ready        the readiness of the proxy, 200 or 503
+    /ready/drain  POST forces the proxy to be not ready, DELETE cancels it
+
+The paths of the liveness and the readiness endpoints can be changed
+with the -liveness-path and the -readiness-path flags. For backwards
+compatibility, the endpoints are served on the /healthz and the /readyz
+paths, too.
+
+The readiness response contains the connectivity of the data clients,
+and the time of the last applied routing table update. The proxy is not
+ready until every data client has loaded its routes once, or the routing
+table was loaded from the snapshot file, and the proxy listener was
+bound. When a data client is
+disconnected, the status is reported as degraded, but the endpoint keeps
+responding with 200, because the proxy serves the last loaded routing
+table, e.g:
+
+    {
+      "status": "degraded",
+      "routing": {
+        "updated": true,
+        "complete": true,
+        "last_update": "2017-06-01T14:32:05Z",
+        "routes": 42,
+        "data_clients": [{
+          "type": "*etcd.Client",
+          "connected": false,
+          "last_success": "2017-06-01T14:32:05Z",
+          "last_error": "etcd: connection refused"
+        }]
+      },
+      "unhealthy_backends": [{
+        "host": "10.2.0.3:8080",
+        "failures": 3,
+        "last_seen": "2017-06-01T14:40:12Z",
+        "error": "dial tcp 10.2.0.3:8080: connection refused"
+      }]
+    }
+
+While draining, the readiness endpoint responds with 503 and the
+draining status, while the liveness endpoint keeps responding with 200,
+so that the load balancers stop sending new requests without the
+process being restarted. With the -drain-delay flag, the proxy starts
+draining when it receives SIGTERM, and it keeps serving the requests for
+the configured duration, before it shuts down gracefully, e.g. to match
+the readiness probe period of Kubernetes:
+
+    skipper -enable-health-endpoints -drain-delay 15s
+
+Similarly, in maintenance mode, switched with the admin API, the
+readiness endpoint responds with 503 and the maintenance status. See the
+maintenance package.
+
+The unhealthy backends are reported optionally, based on the
+backend_unhealthy events published by the proxy, when connecting to a
+backend failed within the last minute.
+*/
+package health
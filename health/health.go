@@ -0,0 +1,322 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/events"
+	"github.com/zalando/skipper/routing"
+)
+
+// Values of the status field of the responses.
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusNotReady    = "not_ready"
+	StatusDraining    = "draining"
+	StatusMaintenance = "maintenance"
+)
+
+const (
+	// DefaultBackendWindow is the default time window that the
+	// unhealthy backends are reported within.
+	DefaultBackendWindow = time.Minute
+
+	subscriptionBuffer = 256
+)
+
+// RoutingStatus provides the state of the routing table. It is
+// implemented by *routing.Routing.
+type RoutingStatus interface {
+	Status() *routing.Status
+}
+
+// Options for creating a Health instance.
+type Options struct {
+
+	// The routing instance whose state is reported. Required.
+	Routing RoutingStatus
+
+	// When set, the backends reported unhealthy by the proxy on the
+	// bus are included in the readiness response.
+	EventBus *events.Bus
+
+	// The time window that the unhealthy backends are reported
+	// within. Default: 1m.
+	BackendWindow time.Duration
+
+	// When set, the proxy is not ready until SetListening is called,
+	// after the proxy listener was bound.
+	WaitForListener bool
+}
+
+// Backend describes a backend that the proxy failed to connect to.
+type Backend struct {
+	Host     string    `json:"host"`
+	Failures int       `json:"failures"`
+	LastSeen time.Time `json:"last_seen"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Liveness is the response of the liveness endpoint.
+type Liveness struct {
+	Status     string  `json:"status"`
+	Uptime     float64 `json:"uptime_seconds"`
+	Goroutines int     `json:"goroutines"`
+}
+
+// Readiness is the response of the readiness endpoint.
+type Readiness struct {
+
+	// ok, when the routing table was loaded and all the data clients
+	// are connected, degraded, when a data client is disconnected,
+	// not_ready, before the routing table was loaded or before the
+	// listener was bound, draining, when the proxy is being taken
+	// out of the rotation, and maintenance, when the proxy is in
+	// maintenance mode.
+	Status string `json:"status"`
+
+	// Tells whether the proxy listener was bound. It is reported only
+	// when the health instance waits for the listener.
+	Listening *bool `json:"listening,omitempty"`
+
+	// The state of the routing table and the data clients.
+	Routing *routing.Status `json:"routing"`
+
+	// The backends that the proxy failed to connect to within the
+	// backend window, when enabled.
+	UnhealthyBackends []Backend `json:"unhealthy_backends,omitempty"`
+}
+
+// Health serves the liveness and the readiness of the proxy.
+type Health struct {
+	routing       RoutingStatus
+	backendWindow time.Duration
+	started       time.Time
+	waitListener  bool
+	listening     int32
+	draining      int32
+	maintenance   int32
+	mx            sync.Mutex
+	backends      map[string]*Backend
+	subscription  *events.Subscription
+	done          chan struct{}
+}
+
+// New creates a Health instance.
+func New(o Options) *Health {
+	if o.BackendWindow <= 0 {
+		o.BackendWindow = DefaultBackendWindow
+	}
+
+	h := &Health{
+		routing:       o.Routing,
+		backendWindow: o.BackendWindow,
+		started:       time.Now(),
+		waitListener:  o.WaitForListener,
+	}
+
+	if o.EventBus != nil {
+		h.backends = make(map[string]*Backend)
+		h.subscription = o.EventBus.Subscribe(subscriptionBuffer, events.TypeBackendUnhealthy)
+		h.done = make(chan struct{})
+		go h.receiveBackendEvents()
+	}
+
+	return h
+}
+
+func (h *Health) receiveBackendEvents() {
+	defer close(h.done)
+	for e := range h.subscription.C {
+		host, _ := e.Data["backend"].(string)
+		if host == "" {
+			continue
+		}
+
+		h.mx.Lock()
+		b, ok := h.backends[host]
+		if !ok || e.Time.Sub(b.LastSeen) >= h.backendWindow {
+			b = &Backend{Host: host}
+			h.backends[host] = b
+		}
+
+		b.Failures++
+		b.LastSeen = e.Time
+		b.Error, _ = e.Data["error"].(string)
+		h.mx.Unlock()
+	}
+}
+
+func (h *Health) unhealthyBackends(now time.Time) []Backend {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	var b []Backend
+	for host, bi := range h.backends {
+		if now.Sub(bi.LastSeen) >= h.backendWindow {
+			delete(h.backends, host)
+			continue
+		}
+
+		b = append(b, *bi)
+	}
+
+	sort.Slice(b, func(i, j int) bool { return b[i].Host < b[j].Host })
+	return b
+}
+
+// SetListening marks the proxy listener as bound.
+func (h *Health) SetListening() {
+	atomic.StoreInt32(&h.listening, 1)
+}
+
+// SetDraining forces the proxy to be reported as not ready, e.g. while
+// it is being removed from the load balancers before a shutdown, or
+// returns it to the normal readiness.
+func (h *Health) SetDraining(d bool) {
+	var v int32
+	if d {
+		v = 1
+	}
+
+	atomic.StoreInt32(&h.draining, v)
+}
+
+// Draining tells whether the proxy is forced to be not ready.
+func (h *Health) Draining() bool {
+	return atomic.LoadInt32(&h.draining) == 1
+}
+
+// SetMaintenance forces the proxy to be reported as not ready, while it
+// is in maintenance mode.
+func (h *Health) SetMaintenance(m bool) {
+	var v int32
+	if m {
+		v = 1
+	}
+
+	atomic.StoreInt32(&h.maintenance, v)
+}
+
+// Maintenance tells whether the proxy is in maintenance mode.
+func (h *Health) Maintenance() bool {
+	return atomic.LoadInt32(&h.maintenance) == 1
+}
+
+// Liveness returns the liveness of the process.
+func (h *Health) Liveness() *Liveness {
+	return &Liveness{
+		Status:     StatusOK,
+		Uptime:     time.Since(h.started).Seconds(),
+		Goroutines: runtime.NumGoroutine(),
+	}
+}
+
+// Readiness returns the readiness of the proxy. The proxy is ready after
+// every data client has loaded its routes once, or the routing table was
+// loaded from the snapshot file, and, when configured, after the
+// listener was bound, unless it is draining. The disconnected data
+// clients don't make it unready, because it keeps serving the last
+// loaded routing table.
+func (h *Health) Readiness() *Readiness {
+	rs := h.routing.Status()
+	r := &Readiness{Status: StatusOK, Routing: rs}
+
+	listening := true
+	if h.waitListener {
+		listening = atomic.LoadInt32(&h.listening) == 1
+		r.Listening = &listening
+	}
+
+	switch {
+	case h.Draining():
+		r.Status = StatusDraining
+	case h.Maintenance():
+		r.Status = StatusMaintenance
+	case !rs.Ready() || !listening:
+		r.Status = StatusNotReady
+	default:
+		for _, c := range rs.DataClients {
+			if !c.Connected {
+				r.Status = StatusDegraded
+				break
+			}
+		}
+	}
+
+	if h.backends != nil {
+		r.UnhealthyBackends = h.unhealthyBackends(time.Now())
+	}
+
+	return r
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("error while sending health status", err)
+	}
+}
+
+// LivenessHandler returns the handler of the liveness endpoint. It
+// always responds with 200, as long as the process can serve requests.
+func (h *Health) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, h.Liveness())
+	})
+}
+
+// ReadinessHandler returns the handler of the readiness endpoint. It
+// responds with 503, when the proxy is not ready, draining or in
+// maintenance mode, otherwise with 200, including when it is degraded.
+func (h *Health) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rd := h.Readiness()
+		code := http.StatusOK
+		switch rd.Status {
+		case StatusNotReady, StatusDraining, StatusMaintenance:
+			code = http.StatusServiceUnavailable
+		}
+
+		writeJSON(w, code, rd)
+	})
+}
+
+// DrainHandler returns a handler to force the proxy to be not ready with
+// POST, and to cancel it with DELETE.
+func (h *Health) DrainHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			log.Info("readiness: draining")
+			h.SetDraining(true)
+		case "DELETE":
+			log.Info("readiness: draining canceled")
+			h.SetDraining(false)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// Close stops receiving the backend events.
+func (h *Health) Close() {
+	if h.subscription == nil {
+		return
+	}
+
+	h.subscription.Close()
+	<-h.done
+}
@@ -0,0 +1,165 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/events"
+	"github.com/zalando/skipper/routing"
+)
+
+type staticStatus routing.Status
+
+func (s *staticStatus) Status() *routing.Status {
+	rs := routing.Status(*s)
+	return &rs
+}
+
+func get(t *testing.T, h http.Handler, v interface{}) int {
+	rsp := httptest.NewRecorder()
+	h.ServeHTTP(rsp, httptest.NewRequest("GET", "/", nil))
+	if err := json.Unmarshal(rsp.Body.Bytes(), v); err != nil {
+		t.Fatal(err)
+	}
+
+	return rsp.Code
+}
+
+func TestLiveness(t *testing.T) {
+	h := New(Options{Routing: &staticStatus{}})
+	defer h.Close()
+
+	var l Liveness
+	if code := get(t, h.LivenessHandler(), &l); code != http.StatusOK || l.Status != StatusOK || l.Goroutines == 0 {
+		t.Error("invalid liveness", code, l)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		status routing.Status
+		code   int
+		expect string
+	}{{
+		title:  "not loaded",
+		status: routing.Status{DataClients: []routing.DataClientStatus{{Connected: true}}},
+		code:   http.StatusServiceUnavailable,
+		expect: StatusNotReady,
+	}, {
+		title:  "loaded",
+		status: routing.Status{Updated: true, Complete: true, DataClients: []routing.DataClientStatus{{Connected: true}}},
+		code:   http.StatusOK,
+		expect: StatusOK,
+	}, {
+		title:  "partially loaded",
+		status: routing.Status{Updated: true, DataClients: []routing.DataClientStatus{{Connected: true}, {Connected: true}}},
+		code:   http.StatusServiceUnavailable,
+		expect: StatusNotReady,
+	}, {
+		title:  "snapshot",
+		status: routing.Status{Updated: true, Snapshot: true, DataClients: []routing.DataClientStatus{{Connected: false}}},
+		code:   http.StatusOK,
+		expect: StatusDegraded,
+	}, {
+		title: "disconnected",
+		status: routing.Status{Updated: true, Complete: true, DataClients: []routing.DataClientStatus{
+			{Connected: true},
+			{Connected: false, LastError: "connection refused"},
+		}},
+		code:   http.StatusOK,
+		expect: StatusDegraded,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			s := staticStatus(test.status)
+			h := New(Options{Routing: &s})
+			defer h.Close()
+
+			var r Readiness
+			if code := get(t, h.ReadinessHandler(), &r); code != test.code || r.Status != test.expect {
+				t.Error("invalid readiness", code, r.Status)
+			}
+		})
+	}
+}
+
+func TestUnhealthyBackends(t *testing.T) {
+	bus := events.NewBus()
+	h := New(Options{Routing: &staticStatus{Updated: true, Complete: true}, EventBus: bus, BackendWindow: time.Hour})
+	defer h.Close()
+
+	publish := func(backend string, err error) {
+		bus.Publish(&events.Event{
+			Type: events.TypeBackendUnhealthy,
+			Data: map[string]interface{}{"backend": backend, "error": err.Error()},
+		})
+	}
+
+	publish("10.0.0.2:8080", errors.New("connection refused"))
+	publish("10.0.0.1:8080", errors.New("i/o timeout"))
+	publish("10.0.0.2:8080", errors.New("connection refused"))
+
+	timeout := time.After(time.Second)
+	for {
+		r := h.Readiness()
+		if len(r.UnhealthyBackends) == 2 && r.UnhealthyBackends[1].Failures == 2 {
+			if r.UnhealthyBackends[0].Host != "10.0.0.1:8080" || r.UnhealthyBackends[0].Error != "i/o timeout" {
+				t.Error("invalid unhealthy backends", r.UnhealthyBackends)
+			}
+
+			return
+		}
+
+		select {
+		case <-timeout:
+			t.Fatal("timeout, invalid unhealthy backends", r.UnhealthyBackends)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestListenerAndDraining(t *testing.T) {
+	h := New(Options{Routing: &staticStatus{Updated: true, Complete: true}, WaitForListener: true})
+	defer h.Close()
+
+	check := func(code int, status string) {
+		var r Readiness
+		if c := get(t, h.ReadinessHandler(), &r); c != code || r.Status != status {
+			t.Error("invalid readiness", c, r.Status)
+		}
+	}
+
+	check(http.StatusServiceUnavailable, StatusNotReady)
+
+	h.SetListening()
+	check(http.StatusOK, StatusOK)
+
+	drain := func(method string) {
+		rsp := httptest.NewRecorder()
+		h.DrainHandler().ServeHTTP(rsp, httptest.NewRequest(method, "/", nil))
+		if rsp.Code != http.StatusNoContent {
+			t.Fatal("invalid status", rsp.Code)
+		}
+	}
+
+	drain("POST")
+	check(http.StatusServiceUnavailable, StatusDraining)
+
+	var l Liveness
+	if code := get(t, h.LivenessHandler(), &l); code != http.StatusOK {
+		t.Error("invalid liveness while draining", code)
+	}
+
+	drain("DELETE")
+	check(http.StatusOK, StatusOK)
+
+	h.SetMaintenance(true)
+	check(http.StatusServiceUnavailable, StatusMaintenance)
+
+	h.SetMaintenance(false)
+	check(http.StatusOK, StatusOK)
+}
@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"net"
@@ -8,6 +9,26 @@ import (
 	"time"
 )
 
+const (
+	// AccessLogFormatCommon is the name of the Apache common log
+	// format.
+	AccessLogFormatCommon = "common"
+
+	// AccessLogFormatCombined is the name of the Apache combined log
+	// format.
+	AccessLogFormatCombined = "combined"
+
+	// AccessLogFormatDefault is the name of the default access log
+	// format: the Apache combined log format, extended with the
+	// duration of the request in milliseconds, and the requested
+	// host.
+	AccessLogFormatDefault = "default"
+
+	// AccessLogFormatJSON is the name of the structured access log
+	// format, writing a JSON object per line.
+	AccessLogFormatJSON = "json"
+)
+
 const (
 	dateFormat      = "02/Jan/2006:15:04:05 -0700"
 	commonLogFormat = `%s - - [%s] "%s %s %s" %d %d`
@@ -20,6 +41,13 @@ const (
 
 type accessLogFormatter struct {
 	format string
+	keys   []string
+}
+
+type jsonAccessLogFormatter struct {
+	fields          []string
+	requestHeaders  []string
+	responseHeaders []string
 }
 
 // Access log entry.
@@ -39,9 +67,58 @@ type AccessEntry struct {
 
 	// The time that the request was received.
 	RequestTime time.Time
+
+	// The headers of the response sent to the client.
+	ResponseHeader http.Header
+
+	// The ID of the matched route.
+	RouteID string
+
+	// The host of the backend that the request was forwarded to.
+	BackendHost string
+
+	// The number of times the backend request was retried.
+	Retries int
+
+	// The correlation ID of the request.
+	RequestID string
+
+	// When set, overrides the global access log settings.
+	AccessLog *AccessLogControl
+
+	// The country code of the client, when known.
+	Country string
+
+	// The autonomous system number of the client, when known.
+	ASN uint
 }
 
-var accessLog *logrus.Logger
+var (
+	accessLog    *logrus.Logger
+	accessFilter *accessLogFilter
+)
+
+var (
+	commonLogKeys = []string{
+		"host", "timestamp", "method", "uri", "proto",
+		"status", "response-size"}
+
+	combinedLogKeys = append(commonLogKeys, "referer", "user-agent")
+
+	accessLogKeys = append(combinedLogKeys, "duration", "requested-host")
+
+	// DefaultJSONAccessLogFields is the set of fields written by the
+	// JSON access log, when no fields are configured explicitly.
+	DefaultJSONAccessLogFields = []string{
+		"timestamp", "host", "method", "uri", "proto",
+		"status", "response-size", "referer", "user-agent",
+		"duration", "requested-host", "route-id", "backend-host",
+		"retries", "flow-id"}
+
+	// the fields that the JSON access log writes only when they are
+	// configured explicitly
+	optionalJSONAccessLogFields = []string{"country", "asn"}
+)
 
 // strip port from addresses with hostname, ipv4 or ipv6
 func stripPort(address string) string {
@@ -73,23 +150,126 @@ func remoteHost(r *http.Request) string {
 	return "-"
 }
 
-func (f *accessLogFormatter) Format(e *logrus.Entry) ([]byte, error) {
-	keys := []string{
-		"host", "timestamp", "method", "uri", "proto",
-		"status", "response-size", "referer", "user-agent",
-		"duration", "requested-host"}
+func newAccessLogFormatter(format string) (*accessLogFormatter, error) {
+	switch format {
+	case "", AccessLogFormatDefault:
+		return &accessLogFormatter{accessLogFormat, accessLogKeys}, nil
+	case AccessLogFormatCombined:
+		return &accessLogFormatter{combinedLogFormat + "\n", combinedLogKeys}, nil
+	case AccessLogFormatCommon:
+		return &accessLogFormatter{commonLogFormat + "\n", commonLogKeys}, nil
+	default:
+		return nil, fmt.Errorf("unsupported access log format: %s", format)
+	}
+}
+
+func newJSONAccessLogFormatter(o Options) (*jsonAccessLogFormatter, error) {
+	fields := o.AccessLogJSONFields
+	if len(fields) == 0 {
+		fields = DefaultJSONAccessLogFields
+	}
 
-	values := make([]interface{}, len(keys))
-	for i, key := range keys {
+	for _, f := range fields {
+		if !isJSONAccessLogField(f) {
+			return nil, fmt.Errorf("unsupported access log field: %s", f)
+		}
+	}
+
+	return &jsonAccessLogFormatter{
+		fields:          fields,
+		requestHeaders:  o.AccessLogRequestHeaders,
+		responseHeaders: o.AccessLogResponseHeaders,
+	}, nil
+}
+
+func isJSONAccessLogField(f string) bool {
+	for _, ff := range DefaultJSONAccessLogFields {
+		if f == ff {
+			return true
+		}
+	}
+
+	for _, ff := range optionalJSONAccessLogFields {
+		if f == ff {
+			return true
+		}
+	}
+
+	return false
+}
+
+func selectHeaders(h http.Header, names []string) map[string]string {
+	if len(names) == 0 || h == nil {
+		return nil
+	}
+
+	selected := make(map[string]string)
+	for _, n := range names {
+		if v := h.Get(n); v != "" {
+			selected[n] = v
+		}
+	}
+
+	return selected
+}
+
+func (f *jsonAccessLogFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	m := make(map[string]interface{})
+	for _, key := range f.fields {
+		if key == "timestamp" {
+			if t, ok := e.Data["request-time"].(time.Time); ok {
+				m[key] = t.Format(time.RFC3339Nano)
+			}
+
+			continue
+		}
+
+		m[key] = e.Data[key]
+	}
+
+	if h, ok := e.Data["request-header"].(http.Header); ok && len(f.requestHeaders) > 0 {
+		m["request-headers"] = selectHeaders(h, f.requestHeaders)
+	}
+
+	if h, ok := e.Data["response-header"].(http.Header); ok && len(f.responseHeaders) > 0 {
+		m["response-headers"] = selectHeaders(h, f.responseHeaders)
+	}
+
+	if AccessLogDebug() {
+		for key, value := range debugFields(e) {
+			m[key] = value
+		}
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+func (f *accessLogFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	values := make([]interface{}, len(f.keys))
+	for i, key := range f.keys {
 		values[i] = e.Data[key]
 	}
 
-	return []byte(fmt.Sprintf(f.format, values...)), nil
+	line := []byte(fmt.Sprintf(f.format, values...))
+	if AccessLogDebug() {
+		return appendDebugFields(line, e)
+	}
+
+	return line, nil
 }
 
-// Logs an access event in Apache combined log format (with a minor customization with the duration).
+// Logs an access event in the configured format, by default in Apache
+// combined log format (with a minor customization with the duration).
+// The entries of the excluded routes and paths, and the ones not
+// selected by the sampling, are dropped, unless the access log is
+// controlled for the route by the AccessLog field.
 func LogAccess(entry *AccessEntry) {
-	if accessLog == nil || entry == nil {
+	if accessLog == nil || entry == nil || !shouldLog(entry) {
 		return
 	}
 
@@ -102,6 +282,7 @@ func LogAccess(entry *AccessEntry) {
 	referer := ""
 	userAgent := ""
 	requestedHost := ""
+	var requestHeader http.Header
 
 	status := entry.StatusCode
 	responseSize := entry.ResponseSize
@@ -115,19 +296,29 @@ func LogAccess(entry *AccessEntry) {
 		referer = entry.Request.Referer()
 		userAgent = entry.Request.UserAgent()
 		requestedHost = entry.Request.Host
+		requestHeader = entry.Request.Header
 	}
 
 	accessLog.WithFields(logrus.Fields{
-		"timestamp":      ts,
-		"host":           host,
-		"method":         method,
-		"uri":            uri,
-		"proto":          proto,
-		"referer":        referer,
-		"user-agent":     userAgent,
-		"status":         status,
-		"response-size":  responseSize,
-		"requested-host": requestedHost,
-		"duration":       duration,
+		"timestamp":       ts,
+		"host":            host,
+		"method":          method,
+		"uri":             uri,
+		"proto":           proto,
+		"referer":         referer,
+		"user-agent":      userAgent,
+		"status":          status,
+		"response-size":   responseSize,
+		"requested-host":  requestedHost,
+		"duration":        duration,
+		"request-time":    entry.RequestTime,
+		"route-id":        entry.RouteID,
+		"backend-host":    entry.BackendHost,
+		"retries":         entry.Retries,
+		"flow-id":         entry.RequestID,
+		"country":         entry.Country,
+		"asn":             entry.ASN,
+		"request-header":  requestHeader,
+		"response-header": entry.ResponseHeader,
 	}).Infoln()
 }
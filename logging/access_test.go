@@ -83,3 +83,146 @@ func TestMissingHostFallback(t *testing.T) {
 	entry.Request.RemoteAddr = ""
 	testAccessLog(t, entry, `- - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.1" 418 2326 "" "" 42 example.com`)
 }
+
+func TestAccessLogFormats(t *testing.T) {
+	for _, test := range []struct {
+		format   string
+		expected string
+	}{{
+		format:   AccessLogFormatDefault,
+		expected: logOutput,
+	}, {
+		format:   AccessLogFormatCombined,
+		expected: `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.1" 418 2326 "" ""`,
+	}, {
+		format:   AccessLogFormatCommon,
+		expected: `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.1" 418 2326`,
+	}} {
+		t.Run(test.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Init(Options{AccessLogOutput: &buf, AccessLogFormat: test.format}); err != nil {
+				t.Fatal(err)
+			}
+
+			LogAccess(testAccessEntry())
+			if got := buf.String(); got != test.expected+"\n" {
+				t.Error("got wrong access log.")
+				t.Log("expected:", test.expected)
+				t.Log("got     :", got)
+			}
+		})
+	}
+}
+
+func TestInvalidAccessLogFormat(t *testing.T) {
+	if err := Init(Options{AccessLogFormat: "foo"}); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestJSONAccessLog(t *testing.T) {
+	entry := testAccessEntry()
+	entry.Request.Header.Set("X-Flow-Id", "foo")
+	entry.ResponseHeader = http.Header{"Content-Type": []string{"text/plain"}}
+	entry.RouteID = "testRoute"
+	entry.BackendHost = "backend.example.org"
+	entry.RequestID = "foo"
+
+	var buf bytes.Buffer
+	if err := Init(Options{
+		AccessLogOutput:          &buf,
+		AccessLogFormat:          AccessLogFormatJSON,
+		AccessLogRequestHeaders:  []string{"X-Flow-Id", "X-Missing"},
+		AccessLogResponseHeaders: []string{"Content-Type"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	LogAccess(entry)
+
+	const expected = `{"backend-host":"backend.example.org","duration":42,"flow-id":"foo","host":"127.0.0.1",` +
+		`"method":"GET","proto":"HTTP/1.1","referer":"","request-headers":{"X-Flow-Id":"foo"},"requested-host":"example.com",` +
+		`"response-headers":{"Content-Type":"text/plain"},"response-size":2326,"retries":0,"route-id":"testRoute",` +
+		`"status":418,"timestamp":"2000-10-10T13:55:36-07:00","uri":"/apache_pb.gif","user-agent":""}` + "\n"
+
+	if got := buf.String(); got != expected {
+		t.Error("got wrong access log.")
+		t.Log("expected:", expected)
+		t.Log("got     :", got)
+	}
+}
+
+func TestJSONAccessLogFields(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Init(Options{
+		AccessLogOutput:     &buf,
+		AccessLogFormat:     AccessLogFormatJSON,
+		AccessLogJSONFields: []string{"status", "route-id"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	LogAccess(testAccessEntry())
+	if got := buf.String(); got != `{"route-id":"","status":418}`+"\n" {
+		t.Error("got wrong access log:", got)
+	}
+
+	if err := Init(Options{
+		AccessLogFormat:     AccessLogFormatJSON,
+		AccessLogJSONFields: []string{"foo"},
+	}); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestJSONAccessLogGeoIPFields(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Init(Options{
+		AccessLogOutput:     &buf,
+		AccessLogFormat:     AccessLogFormatJSON,
+		AccessLogJSONFields: []string{"status", "country", "asn"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := testAccessEntry()
+	entry.Country = "DE"
+	entry.ASN = 64496
+	LogAccess(entry)
+	if got := buf.String(); got != `{"asn":64496,"country":"DE","status":418}`+"\n" {
+		t.Error("got wrong access log:", got)
+	}
+}
+
+func TestAccessLogDebug(t *testing.T) {
+	SetAccessLogDebug(true)
+	defer SetAccessLogDebug(false)
+
+	entry := testAccessEntry()
+	entry.Request.Header.Set("Authorization", "Bearer secret")
+	entry.Request.Header.Set("X-Flow-Id", "foo")
+	entry.RouteID = "testRoute"
+
+	testAccessLog(t, entry, logOutput+
+		` {"asn":0,"backend-host":"","country":"","flow-id":"","request-headers":{"Authorization":"[redacted]","X-Flow-Id":"foo"},`+
+		`"retries":0,"route-id":"testRoute"}`)
+
+	var buf bytes.Buffer
+	if err := Init(Options{
+		AccessLogOutput:     &buf,
+		AccessLogFormat:     AccessLogFormatJSON,
+		AccessLogJSONFields: []string{"status"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	LogAccess(entry)
+	const expected = `{"asn":0,"backend-host":"","country":"","flow-id":"","request-headers":{"Authorization":"[redacted]","X-Flow-Id":"foo"},` +
+		`"retries":0,"route-id":"testRoute","status":418}` + "\n"
+	if got := buf.String(); got != expected {
+		t.Error("got wrong access log:", got)
+	}
+
+	SetAccessLogDebug(false)
+	testAccessLog(t, entry, logOutput)
+}
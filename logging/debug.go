@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// the value of the headers that are not written even in debug mode
+const redacted = "[redacted]"
+
+var accessLogDebug int32
+
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// SetAccessLogDebug enables or disables the debug fields of the access
+// log at runtime. When enabled, every entry contains the route, the
+// backend, the retries, the flow id, the client location and all the
+// request and response headers, except for the values of the
+// credentials and the cookies. The JSON format writes them as
+// additional fields, while the text formats append them to the line
+// as a JSON object.
+func SetAccessLogDebug(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+
+	atomic.StoreInt32(&accessLogDebug, v)
+}
+
+// AccessLogDebug tells whether the debug fields of the access log are
+// enabled.
+func AccessLogDebug() bool {
+	return atomic.LoadInt32(&accessLogDebug) == 1
+}
+
+func allHeaders(h http.Header) map[string]string {
+	all := make(map[string]string)
+	for name := range h {
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			all[name] = redacted
+			continue
+		}
+
+		all[name] = h.Get(name)
+	}
+
+	return all
+}
+
+func debugFields(e *logrus.Entry) map[string]interface{} {
+	m := make(map[string]interface{})
+	for _, key := range []string{"route-id", "backend-host", "retries", "flow-id", "country", "asn"} {
+		m[key] = e.Data[key]
+	}
+
+	if h, ok := e.Data["request-header"].(http.Header); ok && len(h) > 0 {
+		m["request-headers"] = allHeaders(h)
+	}
+
+	if h, ok := e.Data["response-header"].(http.Header); ok && len(h) > 0 {
+		m["response-headers"] = allHeaders(h)
+	}
+
+	return m
+}
+
+// appends the debug fields to a text access log line
+func appendDebugFields(line []byte, e *logrus.Entry) ([]byte, error) {
+	b, err := json.Marshal(debugFields(e))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		line = line[:len(line)-1]
+	}
+
+	line = append(line, ' ')
+	line = append(line, b...)
+	return append(line, '\n'), nil
+}
@@ -0,0 +1,74 @@
+package logging
+
+import "context"
+
+type proxyDetailsKey struct{}
+
+// ProxyDetails contains information about how a request was proxied,
+// that is not known to the logging handler itself. The handler puts an
+// empty instance into the context of each request, and the proxy sets
+// its fields, so that they can be included in the access log.
+type ProxyDetails struct {
+
+	// The correlation ID of the request, the value of the X-Flow-Id
+	// header, received from the client, generated by the proxy, or
+	// set by the flowId filter.
+	RequestID string
+
+	// The ID of the matched route.
+	RouteID string
+
+	// The host of the backend that the request was forwarded to.
+	BackendHost string
+
+	// The number of times the request was routed again by the proxy,
+	// after a route with a loopback backend.
+	Retries int
+
+	// When set, overrides the access log settings for the request.
+	// Set by the disableAccessLog and enableAccessLog filters.
+	AccessLog *AccessLogControl
+
+	// The country code of the client, when GeoIP lookups are enabled.
+	Country string
+
+	// The autonomous system number of the client, when GeoIP lookups
+	// are enabled.
+	ASN uint
+}
+
+// AccessLogControl overrides the global access log settings, the
+// sampling and the exclusions, for the requests of a route.
+type AccessLogControl struct {
+
+	// When false, the request is not logged.
+	Enabled bool
+
+	// When not empty, the request is logged only when the response
+	// status code starts with one of the prefixes, e.g. 5 for all
+	// 5xx, or 40 for 400-409.
+	StatusPrefixes []int
+}
+
+// ContextWithProxyDetails returns a copy of the parent context that
+// carries the proxy details.
+func ContextWithProxyDetails(parent context.Context, d *ProxyDetails) context.Context {
+	return context.WithValue(parent, proxyDetailsKey{}, d)
+}
+
+// ProxyDetailsFromContext returns the proxy details stored in the
+// context, or nil, when there is none.
+func ProxyDetailsFromContext(ctx context.Context) *ProxyDetails {
+	d, _ := ctx.Value(proxyDetailsKey{}).(*ProxyDetails)
+	return d
+}
+
+// RequestIDFromContext returns the correlation ID of the request whose
+// context is passed in, or an empty string, when it is not known.
+func RequestIDFromContext(ctx context.Context) string {
+	if d := ProxyDetailsFromContext(ctx); d != nil {
+		return d.RequestID
+	}
+
+	return ""
+}
@@ -32,7 +32,72 @@ central proxy handler, and automatically provides access logging.
 
 During initialization, it is possible to redirect the access log output
 from the default /dev/stderr to another file, or completely disable the
-access log.
+access log. The access log output is always separate from the
+application log output.
+
+The format of the access log entries can be selected with the
+AccessLogFormat option:
+
+    default:  the Apache combined log format, followed by the duration
+              of the request in milliseconds and the requested host
+    combined: the Apache combined log format
+    common:   the Apache common log format
+    json:     a JSON object per line, for direct ingestion into log
+              processing systems
+
+The JSON access log writes the fields listed in the AccessLogJSONFields
+option, by default all of them: timestamp, host, method, uri, proto,
+status, response-size, referer, user-agent, duration, requested-host,
+route-id, backend-host, retries and flow-id. The values of the request and
+response headers listed in the AccessLogRequestHeaders and
+AccessLogResponseHeaders options are written in the request-headers and
+response-headers objects. Example:
+
+    {"backend-host":"service.example.org","duration":42,"method":"GET","request-headers":{"X-Flow-Id":"abc"},"route-id":"service","status":200,...}
+
+The route ID, the backend host and the number of retries are provided
+by the proxy through the ProxyDetails stored in the request context by
+the logging handler. The retries count the loopbacks, the times that the
+request was routed again, and the route ID and the backend host are of
+the last matched route.
+
+When GeoIP lookups are enabled, the country code and the autonomous
+system number of the client can be written, too, by listing the country
+and the asn fields explicitly:
+
+    skipper -access-log-format json -access-log-json-fields timestamp,host,uri,status,country,asn
+
+Sampling and Exclusion
+
+To reduce the volume of the access log without losing the visibility of
+the errors, the entries can be sampled by the class of the response
+status code. E.g. the following writes 1% of the 2xx and 10% of the 3xx
+entries, while writing every 4xx and 5xx entry:
+
+    skipper -access-log-sample-rates 2xx=0.01,3xx=0.1
+
+The requests of certain routes or paths, e.g. the health checks, can be
+excluded completely from the access log:
+
+    skipper -access-log-exclude-routes healthcheck -access-log-exclude-paths /healthz
+
+The access log can be also controlled for the individual routes, in the
+routing table, with the disableAccessLog() and enableAccessLog() filters.
+See the filters/accesslog package.
+
+Remote Outputs
+
+Both the application log and the access log can be sent to a syslog
+server, in RFC 5424 format over UDP, TCP or TLS, or to an HTTP endpoint,
+e.g:
+
+    skipper -access-log syslog+tls://logs.example.org:6514 -application-log https://logs.example.org/ingest
+
+The remote outputs buffer the log entries in a bounded queue, and send
+them in batches from the background, retrying the failed batches with
+an exponential backoff. When the queue is full, or the retries are
+exhausted, the entries are dropped, and the failure is reported on
+stderr.
 
 Output Files
 
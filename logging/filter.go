@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// accessLogFilter decides which access log entries are written, based
+// on the sample rates of the status classes and the excluded routes
+// and paths.
+type accessLogFilter struct {
+	sampleRates   map[int]float64
+	excludeRoutes map[string]bool
+	excludePaths  []string
+}
+
+func newAccessLogFilter(o Options) *accessLogFilter {
+	if len(o.AccessLogSampleRates) == 0 &&
+		len(o.AccessLogExcludeRoutes) == 0 &&
+		len(o.AccessLogExcludePaths) == 0 {
+		return nil
+	}
+
+	f := &accessLogFilter{
+		sampleRates:   o.AccessLogSampleRates,
+		excludeRoutes: make(map[string]bool),
+		excludePaths:  o.AccessLogExcludePaths,
+	}
+
+	for _, id := range o.AccessLogExcludeRoutes {
+		f.excludeRoutes[id] = true
+	}
+
+	return f
+}
+
+func (f *accessLogFilter) excluded(e *AccessEntry) bool {
+	if f.excludeRoutes[e.RouteID] {
+		return true
+	}
+
+	if e.Request == nil || e.Request.URL == nil {
+		return false
+	}
+
+	for _, p := range f.excludePaths {
+		if strings.HasPrefix(e.Request.URL.Path, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *accessLogFilter) sampled(e *AccessEntry) bool {
+	rate, ok := f.sampleRates[e.StatusCode/100]
+	if !ok || rate >= 1 {
+		return true
+	}
+
+	return rand.Float64() < rate
+}
+
+// a nil filter lets every entry through
+func (f *accessLogFilter) log(e *AccessEntry) bool {
+	if f == nil {
+		return true
+	}
+
+	return !f.excluded(e) && f.sampled(e)
+}
+
+func (c *AccessLogControl) log(statusCode int) bool {
+	if !c.Enabled {
+		return false
+	}
+
+	if len(c.StatusPrefixes) == 0 {
+		return true
+	}
+
+	status := strconv.Itoa(statusCode)
+	for _, p := range c.StatusPrefixes {
+		if strings.HasPrefix(status, strconv.Itoa(p)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// the route level control takes precedence over the global settings
+func shouldLog(e *AccessEntry) bool {
+	if e.AccessLog != nil {
+		return e.AccessLog.log(e.StatusCode)
+	}
+
+	return accessFilter.log(e)
+}
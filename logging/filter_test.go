@@ -0,0 +1,140 @@
+package logging
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogFilter(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		options  Options
+		entry    func() *AccessEntry
+		expected bool
+	}{{
+		title:    "no filter",
+		entry:    testAccessEntry,
+		expected: true,
+	}, {
+		title:   "excluded route",
+		options: Options{AccessLogExcludeRoutes: []string{"healthcheck"}},
+		entry: func() *AccessEntry {
+			e := testAccessEntry()
+			e.RouteID = "healthcheck"
+			return e
+		},
+	}, {
+		title:   "other route",
+		options: Options{AccessLogExcludeRoutes: []string{"healthcheck"}},
+		entry: func() *AccessEntry {
+			e := testAccessEntry()
+			e.RouteID = "foo"
+			return e
+		},
+		expected: true,
+	}, {
+		title:   "excluded path",
+		options: Options{AccessLogExcludePaths: []string{"/apache"}},
+		entry: func() *AccessEntry {
+			e := testAccessEntry()
+			e.Request.URL.Path = "/apache_pb.gif"
+			return e
+		},
+	}, {
+		title:    "other path",
+		options:  Options{AccessLogExcludePaths: []string{"/healthz"}},
+		entry:    testAccessEntry,
+		expected: true,
+	}, {
+		title:   "status class sampled out",
+		options: Options{AccessLogSampleRates: map[int]float64{4: 0}},
+		entry:   testAccessEntry,
+	}, {
+		title:    "status class always logged",
+		options:  Options{AccessLogSampleRates: map[int]float64{4: 1}},
+		entry:    testAccessEntry,
+		expected: true,
+	}, {
+		title:    "status class without rate",
+		options:  Options{AccessLogSampleRates: map[int]float64{2: 0}},
+		entry:    testAccessEntry,
+		expected: true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			var buf bytes.Buffer
+			test.options.AccessLogOutput = &buf
+			if err := Init(test.options); err != nil {
+				t.Fatal(err)
+			}
+
+			LogAccess(test.entry())
+			if logged := buf.Len() > 0; logged != test.expected {
+				t.Error("unexpected filtering", logged)
+			}
+		})
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Init(Options{
+		AccessLogOutput:      &buf,
+		AccessLogSampleRates: map[int]float64{2: 0.1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 10000
+	for i := 0; i < n; i++ {
+		LogAccess(&AccessEntry{StatusCode: http.StatusOK})
+	}
+
+	count := strings.Count(buf.String(), "\n")
+	if count < n/20 || count > n/5 {
+		t.Error("unexpected number of sampled entries", count)
+	}
+}
+
+func TestAccessLogControl(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		control  *AccessLogControl
+		status   int
+		expected bool
+	}{{
+		title:   "disabled",
+		control: &AccessLogControl{},
+		status:  http.StatusInternalServerError,
+	}, {
+		title:    "enabled overrides sampling",
+		control:  &AccessLogControl{Enabled: true},
+		status:   http.StatusOK,
+		expected: true,
+	}, {
+		title:    "matching prefix",
+		control:  &AccessLogControl{Enabled: true, StatusPrefixes: []int{3, 50}},
+		status:   http.StatusBadGateway,
+		expected: true,
+	}, {
+		title:   "not matching prefix",
+		control: &AccessLogControl{Enabled: true, StatusPrefixes: []int{3, 50}},
+		status:  http.StatusOK,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Init(Options{
+				AccessLogOutput:      &buf,
+				AccessLogSampleRates: map[int]float64{2: 0},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			LogAccess(&AccessEntry{StatusCode: test.status, AccessLog: test.control})
+			if logged := buf.Len() > 0; logged != test.expected {
+				t.Error("unexpected filtering", logged)
+			}
+		})
+	}
+}
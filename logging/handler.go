@@ -19,17 +19,28 @@ func NewHandler(next http.Handler) http.Handler {
 func (lh *loggingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	now := time.Now()
 
+	details := &ProxyDetails{}
+	r = r.WithContext(ContextWithProxyDetails(r.Context(), details))
+
 	lw := &loggingWriter{writer: w}
 	lh.proxy.ServeHTTP(lw, r)
 
 	dur := time.Now().Sub(now)
 
 	entry := &AccessEntry{
-		Request:      r,
-		ResponseSize: lw.bytes,
-		StatusCode:   lw.code,
-		RequestTime:  now,
-		Duration:     dur,
+		Request:        r,
+		ResponseSize:   lw.bytes,
+		StatusCode:     lw.code,
+		RequestTime:    now,
+		Duration:       dur,
+		ResponseHeader: lw.Header(),
+		RouteID:        details.RouteID,
+		BackendHost:    details.BackendHost,
+		Retries:        details.Retries,
+		RequestID:      details.RequestID,
+		AccessLog:      details.AccessLog,
+		Country:        details.Country,
+		ASN:            details.ASN,
 	}
 	LogAccess(entry)
 }
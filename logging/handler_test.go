@@ -48,3 +48,27 @@ func TestLogsAccess(t *testing.T) {
 		t.Error("failed to log access")
 	}
 }
+
+func TestLogsProxyDetails(t *testing.T) {
+	var accessLog bytes.Buffer
+	Init(Options{AccessLogOutput: &accessLog, AccessLogFormat: AccessLogFormatJSON})
+
+	innerHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := ProxyDetailsFromContext(r.Context())
+		if d == nil {
+			t.Fatal("proxy details not found")
+		}
+
+		d.RouteID = "testRoute"
+		d.BackendHost = "backend.example.org"
+	})
+	h := NewHandler(innerHandler)
+
+	h.ServeHTTP(httptest.NewRecorder(), &http.Request{})
+
+	output := accessLog.String()
+	if !strings.Contains(output, `"route-id":"testRoute"`) ||
+		!strings.Contains(output, `"backend-host":"backend.example.org"`) {
+		t.Error("failed to log proxy details", output)
+	}
+}
@@ -29,6 +29,37 @@ type Options struct {
 
 	// When set, no access log is printed.
 	AccessLogDisabled bool
+
+	// Format of the access log entries, possible values: default,
+	// combined, common and json. The default format is the Apache
+	// combined log format, extended with the duration of the
+	// request in milliseconds and the requested host.
+	AccessLogFormat string
+
+	// The fields written by the JSON access log. When empty,
+	// DefaultJSONAccessLogFields is used.
+	AccessLogJSONFields []string
+
+	// Names of the request headers whose values are written by the
+	// JSON access log, in the request-headers object.
+	AccessLogRequestHeaders []string
+
+	// Names of the response headers whose values are written by
+	// the JSON access log, in the response-headers object.
+	AccessLogResponseHeaders []string
+
+	// Sample rates of the access log entries by the class of the
+	// response status code, e.g. {2: 0.01} writes 1% of the
+	// entries of the 2xx responses. The classes without a
+	// configured rate are always logged.
+	AccessLogSampleRates map[int]float64
+
+	// The IDs of the routes whose requests are not logged, e.g. the
+	// health checks.
+	AccessLogExcludeRoutes []string
+
+	// The path prefixes of the requests that are not logged.
+	AccessLogExcludePaths []string
 }
 
 func (f *prefixFormatter) Format(e *logrus.Entry) ([]byte, error) {
@@ -51,25 +82,46 @@ func initApplicationLog(prefix string, output io.Writer) {
 	}
 }
 
-func initAccessLog(output io.Writer) {
+func initAccessLog(o Options) error {
+	var (
+		f   logrus.Formatter
+		err error
+	)
+
+	if o.AccessLogFormat == AccessLogFormatJSON {
+		f, err = newJSONAccessLogFormatter(o)
+	} else {
+		f, err = newAccessLogFormatter(o.AccessLogFormat)
+	}
+
+	if err != nil {
+		return err
+	}
+
 	l := logrus.New()
-	l.Formatter = &accessLogFormatter{accessLogFormat}
-	l.Out = output
+	l.Formatter = f
+	l.Out = o.AccessLogOutput
 	l.Level = logrus.InfoLevel
 	accessLog = l
+	accessFilter = newAccessLogFilter(o)
+	return nil
 }
 
-// Initializes logging.
-func Init(o Options) {
+// Initializes logging. The access log has its own output, separate
+// from the application log.
+func Init(o Options) error {
 	if o.ApplicationLogPrefix != "" || o.ApplicationLogOutput != nil {
 		initApplicationLog(o.ApplicationLogPrefix, o.ApplicationLogOutput)
 	}
 
-	if !o.AccessLogDisabled {
-		if o.AccessLogOutput == nil {
-			o.AccessLogOutput = os.Stderr
-		}
+	if o.AccessLogDisabled {
+		accessLog = nil
+		return nil
+	}
 
-		initAccessLog(o.AccessLogOutput)
+	if o.AccessLogOutput == nil {
+		o.AccessLogOutput = os.Stderr
 	}
+
+	return initAccessLog(o)
 }
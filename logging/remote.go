@@ -0,0 +1,369 @@
+package logging
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRemoteQueueSize is the maximum number of log entries
+	// buffered by the remote outputs, while waiting to be sent. When
+	// the queue is full, the further entries are dropped.
+	DefaultRemoteQueueSize = 1 << 13
+
+	// DefaultRemoteRetries is the number of times the remote outputs
+	// try to resend a batch of entries before dropping it.
+	DefaultRemoteRetries = 3
+
+	// DefaultRemoteRetryInterval is the initial wait time before
+	// resending a failed batch. It is doubled after each failure.
+	DefaultRemoteRetryInterval = 100 * time.Millisecond
+
+	remoteBatchSize = 1 << 7
+	remoteTimeout   = 5 * time.Second
+
+	// RFC 5424 facility user, severity informational
+	syslogPriority = 1<<3 | 6
+	syslogAppName  = "skipper"
+)
+
+// Schemes of the supported remote log outputs.
+const (
+	SyslogUDPScheme = "syslog+udp"
+	SyslogTCPScheme = "syslog+tcp"
+	SyslogTLSScheme = "syslog+tls"
+	HTTPScheme      = "http"
+	HTTPSScheme     = "https"
+)
+
+var errRemoteWriterClosed = errors.New("remote log writer closed")
+
+// remoteWriter buffers the log entries, and sends them in batches from
+// a background goroutine, retrying the failed batches. It never blocks
+// the logging caller.
+type remoteWriter struct {
+	queue         chan []byte
+	send          func([][]byte) error
+	closeSender   func() error
+	retries       int
+	retryInterval time.Duration
+	mx            sync.Mutex
+	closed        bool
+	quit          chan struct{}
+	done          chan struct{}
+}
+
+type syslogSender struct {
+	network   string
+	address   string
+	tlsConfig *tls.Config
+	hostname  string
+	msgID     string
+	conn      net.Conn
+}
+
+type httpSender struct {
+	url    string
+	client *http.Client
+}
+
+// IsRemoteOutput tells whether a log output address is a remote one,
+// that needs to be created with NewRemoteWriter.
+func IsRemoteOutput(address string) bool {
+	u, err := url.Parse(address)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case SyslogUDPScheme, SyslogTCPScheme, SyslogTLSScheme, HTTPScheme, HTTPSScheme:
+		return true
+	default:
+		return false
+	}
+}
+
+// NewRemoteWriter creates a log output that sends the log entries to a
+// syslog server, in RFC 5424 format, or to an HTTP endpoint, in the
+// body of POST requests, one entry per line. The address is a URL,
+// e.g:
+//
+//	syslog+udp://localhost:514
+//	syslog+tcp://logs.example.org:601
+//	syslog+tls://logs.example.org:6514
+//	https://logs.example.org/ingest
+//
+// The name is used as the MSGID of the syslog messages, e.g. access
+// or application.
+//
+// The entries are buffered and sent from the background, with retries
+// on failures. The returned writer needs to be closed to flush the
+// buffered entries.
+func NewRemoteWriter(address, name string) (io.WriteCloser, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		send        func([][]byte) error
+		closeSender func() error
+	)
+
+	switch u.Scheme {
+	case SyslogUDPScheme, SyslogTCPScheme, SyslogTLSScheme:
+		s := newSyslogSender(u, name)
+		send, closeSender = s.send, s.close
+	case HTTPScheme, HTTPSScheme:
+		s := &httpSender{url: address, client: &http.Client{Timeout: remoteTimeout}}
+		send, closeSender = s.send, func() error { return nil }
+	default:
+		return nil, fmt.Errorf("unsupported remote log output: %s", address)
+	}
+
+	w := &remoteWriter{
+		queue:         make(chan []byte, DefaultRemoteQueueSize),
+		send:          send,
+		closeSender:   closeSender,
+		retries:       DefaultRemoteRetries,
+		retryInterval: DefaultRemoteRetryInterval,
+		quit:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// the log entries cannot be reported to the application log, because
+// it may be the one failing
+func reportRemoteError(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "remote log output: "+format+"\n", args...)
+}
+
+// Write queues a single log entry. It copies the data, because logrus
+// reuses its buffers.
+func (w *remoteWriter) Write(p []byte) (int, error) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if w.closed {
+		return 0, errRemoteWriterClosed
+	}
+
+	entry := make([]byte, len(p))
+	copy(entry, p)
+
+	select {
+	case w.queue <- entry:
+	default:
+		reportRemoteError("queue full, dropping entry")
+	}
+
+	return len(p), nil
+}
+
+func (w *remoteWriter) sendWithRetry(batch [][]byte) {
+	wait := w.retryInterval
+	for i := 0; ; i++ {
+		err := w.send(batch)
+		if err == nil {
+			return
+		}
+
+		if i >= w.retries {
+			reportRemoteError("failed to send %d entries: %v", len(batch), err)
+			return
+		}
+
+		select {
+		case <-time.After(wait):
+			wait *= 2
+		case <-w.quit:
+			// still trying once more, when closing
+			if err := w.send(batch); err != nil {
+				reportRemoteError("failed to send %d entries: %v", len(batch), err)
+			}
+
+			return
+		}
+	}
+}
+
+func (w *remoteWriter) collect(first []byte) [][]byte {
+	batch := [][]byte{first}
+	for len(batch) < remoteBatchSize {
+		select {
+		case e, ok := <-w.queue:
+			if !ok {
+				return batch
+			}
+
+			batch = append(batch, e)
+		default:
+			return batch
+		}
+	}
+
+	return batch
+}
+
+func (w *remoteWriter) run() {
+	defer close(w.done)
+	for e := range w.queue {
+		w.sendWithRetry(w.collect(e))
+	}
+}
+
+// Close flushes the buffered entries and stops the writer.
+func (w *remoteWriter) Close() error {
+	w.mx.Lock()
+	if w.closed {
+		w.mx.Unlock()
+		return nil
+	}
+
+	w.closed = true
+	close(w.quit)
+	close(w.queue)
+	w.mx.Unlock()
+
+	<-w.done
+	return w.closeSender()
+}
+
+func newSyslogSender(u *url.URL, msgID string) *syslogSender {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	if msgID == "" {
+		msgID = "-"
+	}
+
+	s := &syslogSender{
+		address:  u.Host,
+		hostname: hostname,
+		msgID:    msgID,
+	}
+
+	switch u.Scheme {
+	case SyslogUDPScheme:
+		s.network = "udp"
+	case SyslogTLSScheme:
+		s.network = "tcp"
+		s.tlsConfig = &tls.Config{ServerName: u.Hostname()}
+	default:
+		s.network = "tcp"
+	}
+
+	return s
+}
+
+func (s *syslogSender) dial() (net.Conn, error) {
+	d := &net.Dialer{Timeout: remoteTimeout}
+	if s.tlsConfig != nil {
+		return tls.DialWithDialer(d, s.network, s.address, s.tlsConfig)
+	}
+
+	return d.Dial(s.network, s.address)
+}
+
+// format: <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+func (s *syslogSender) format(entry []byte, now time.Time) []byte {
+	return []byte(fmt.Sprintf(
+		"<%d>1 %s %s %s %d %s - %s",
+		syslogPriority,
+		now.Format(time.RFC3339Nano),
+		s.hostname,
+		syslogAppName,
+		os.Getpid(),
+		s.msgID,
+		strings.TrimRight(string(entry), "\n"),
+	))
+}
+
+func (s *syslogSender) send(batch [][]byte) error {
+	if s.conn == nil {
+		conn, err := s.dial()
+		if err != nil {
+			return err
+		}
+
+		s.conn = conn
+	}
+
+	now := time.Now()
+	var buf bytes.Buffer
+	for _, e := range batch {
+		m := s.format(e, now)
+
+		// over UDP, every message is a separate datagram, while
+		// over TCP and TLS, the messages are framed with octet
+		// counting, as in RFC 6587 and RFC 5425
+		if s.network == "udp" {
+			if _, err := s.conn.Write(m); err != nil {
+				s.close()
+				return err
+			}
+
+			continue
+		}
+
+		fmt.Fprintf(&buf, "%d %s", len(m), m)
+	}
+
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(remoteTimeout))
+	if _, err := s.conn.Write(buf.Bytes()); err != nil {
+		s.close()
+		return err
+	}
+
+	return nil
+}
+
+func (s *syslogSender) close() error {
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *httpSender) send(batch [][]byte) error {
+	var buf bytes.Buffer
+	for _, e := range batch {
+		buf.Write(e)
+		if len(e) == 0 || e[len(e)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+
+	rsp, err := s.client.Post(s.url, "text/plain; charset=utf-8", &buf)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status from the log endpoint: %d", rsp.StatusCode)
+	}
+
+	return nil
+}
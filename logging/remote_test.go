@@ -0,0 +1,202 @@
+package logging
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var syslogMessage = regexp.MustCompile(`^<14>1 \S+ \S+ skipper \d+ access - (.*)$`)
+
+func TestIsRemoteOutput(t *testing.T) {
+	for address, expected := range map[string]bool{
+		"/dev/stderr":                 false,
+		"/var/log/skipper.log":        false,
+		"syslog+udp://localhost:514":  true,
+		"syslog+tcp://localhost:601":  true,
+		"syslog+tls://localhost:6514": true,
+		"http://localhost:9999":       true,
+		"https://logs.example.org":    true,
+		"ftp://logs.example.org":      false,
+	} {
+		if IsRemoteOutput(address) != expected {
+			t.Error("failed to detect remote output", address)
+		}
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	w, err := NewRemoteWriter("syslog+udp://"+conn.LocalAddr().String(), "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.Write([]byte("foo\n"))
+	w.Write([]byte("bar\n"))
+	w.Close()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1<<10)
+	for _, expected := range []string{"foo", "bar"} {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m := syslogMessage.FindStringSubmatch(string(buf[:n]))
+		if len(m) != 2 || m[1] != expected {
+			t.Error("invalid message", string(buf[:n]))
+		}
+	}
+}
+
+func TestSyslogTCPOctetCounting(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var messages []string
+		for len(messages) < 2 {
+			size, err := r.ReadString(' ')
+			if err != nil {
+				break
+			}
+
+			n, err := strconv.Atoi(strings.TrimSpace(size))
+			if err != nil {
+				break
+			}
+
+			m := make([]byte, n)
+			if _, err := io.ReadFull(r, m); err != nil {
+				break
+			}
+
+			messages = append(messages, string(m))
+		}
+
+		received <- messages
+	}()
+
+	w, err := NewRemoteWriter("syslog+tcp://"+l.Addr().String(), "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.Write([]byte("foo bar\n"))
+	w.Write([]byte("baz\n"))
+	w.Close()
+
+	select {
+	case messages := <-received:
+		if len(messages) != 2 {
+			t.Fatal("invalid number of messages", len(messages))
+		}
+
+		for i, expected := range []string{"foo bar", "baz"} {
+			m := syslogMessage.FindStringSubmatch(messages[i])
+			if len(m) != 2 || m[1] != expected {
+				t.Error("invalid message", messages[i])
+			}
+		}
+	case <-time.After(time.Second):
+		t.Error("messages not received")
+	}
+}
+
+func TestHTTPOutputRetries(t *testing.T) {
+	var requests int
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		b, _ := ioutil.ReadAll(r.Body)
+		received <- string(b)
+	}))
+	defer server.Close()
+
+	w, err := NewRemoteWriter(server.URL, "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.Write([]byte("foo\n"))
+	w.Write([]byte("bar"))
+
+	select {
+	case body := <-received:
+		if body != "foo\nbar\n" && body != "foo\n" {
+			t.Error("invalid body", body)
+		}
+	case <-time.After(time.Second):
+		t.Error("entries not received")
+	}
+
+	w.Close()
+}
+
+func TestRemoteWriterDropsAfterRetries(t *testing.T) {
+	attempts := make(chan struct{}, 1<<7)
+	w := &remoteWriter{
+		queue: make(chan []byte, 1),
+		send: func([][]byte) error {
+			attempts <- struct{}{}
+			return errors.New("test error")
+		},
+		closeSender:   func() error { return nil },
+		retries:       DefaultRemoteRetries,
+		retryInterval: time.Millisecond,
+		quit:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	go w.run()
+	w.Write([]byte("foo"))
+
+	// the queue is full, when the first entry is not taken yet
+	for i := 0; i < 3; i++ {
+		w.Write([]byte("bar"))
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	w.Close()
+	if _, err := w.Write([]byte("baz")); err != errRemoteWriterClosed {
+		t.Error("failed to reject entries after close")
+	}
+
+	if len(attempts) < DefaultRemoteRetries+1 {
+		t.Error("failed to retry", len(attempts))
+	}
+}
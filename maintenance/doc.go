@@ -0,0 +1,29 @@
+/*
+Package maintenance implements the maintenance mode of the proxy, to take
+an instance out of the rotation without stopping it, e.g. before a
+maintenance of the host.
+
+When the maintenance mode is enabled:
+
+    - the readiness endpoint responds with 503 and the maintenance
+      status, so that the load balancers stop sending new connections,
+    - the configured routes respond with 503 and a Retry-After header,
+      or every request, when no routes are configured,
+    - the keep-alive connections are closed gradually over the drain
+      period, with the probability of closing a connection after a
+      response growing linearly, and at the end of the drain period,
+      the idle connections are closed and the keep-alives are disabled.
+
+The maintenance mode is switched with the admin API:
+
+    skipper -admin-address localhost:9922 -admin-tokens file:/etc/skipper/admin-tokens \
+        -maintenance-routes api,upload -maintenance-drain-period 1m
+
+    curl -H "Authorization: Bearer $TOKEN" -X POST localhost:9922/maintenance
+    curl -H "Authorization: Bearer $TOKEN" localhost:9922/maintenance
+    curl -H "Authorization: Bearer $TOKEN" -X DELETE localhost:9922/maintenance
+
+Disabling the maintenance mode enables the keep-alives again, and
+restores the readiness.
+*/
+package maintenance
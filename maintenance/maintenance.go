@@ -0,0 +1,275 @@
+package maintenance
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	// DefaultRetryAfter is the default value of the Retry-After header
+	// of the maintenance responses.
+	DefaultRetryAfter = 5 * time.Minute
+
+	// DefaultDrainPeriod is the default period of draining the
+	// existing connections.
+	DefaultDrainPeriod = 30 * time.Second
+)
+
+// Readiness is notified when the maintenance mode is switched, e.g. to
+// fail the readiness checks. It is implemented by *health.Health.
+type Readiness interface {
+	SetMaintenance(bool)
+}
+
+// RouteLookup finds the route of a request. It is implemented by
+// *routing.Routing.
+type RouteLookup interface {
+	Route(*http.Request) (*routing.Route, map[string]string)
+}
+
+// Options for the maintenance mode.
+type Options struct {
+
+	// The routing, used to find the route of the requests. Required,
+	// when Routes is set.
+	Routing RouteLookup
+
+	// The IDs of the routes that respond with 503 during the
+	// maintenance. When empty, all the requests are responded with
+	// 503.
+	Routes []string
+
+	// The value of the Retry-After header of the maintenance
+	// responses. Default: 5m.
+	RetryAfter time.Duration
+
+	// The period, during which the existing connections are closed,
+	// after the maintenance mode was enabled. Default: 30s. When
+	// negative, the connections are not drained.
+	DrainPeriod time.Duration
+
+	// When set, it is notified when the maintenance mode is switched.
+	Readiness Readiness
+}
+
+// Status of the maintenance mode.
+type Status struct {
+	Enabled     bool       `json:"enabled"`
+	Since       *time.Time `json:"since,omitempty"`
+	Drained     bool       `json:"drained"`
+	Routes      []string   `json:"routes,omitempty"`
+	RetryAfter  float64    `json:"retry_after_seconds"`
+	DrainPeriod float64    `json:"drain_period_seconds"`
+}
+
+// Mode controls the maintenance mode of the proxy. When enabled, the
+// readiness fails, the configured routes respond with 503 and a
+// Retry-After header, and the keep-alive connections are closed
+// gradually, over the drain period.
+type Mode struct {
+	options    Options
+	routes     map[string]bool
+	retryAfter string
+
+	mx      sync.Mutex
+	enabled bool
+	since   time.Time
+	drained bool
+	timer   *time.Timer
+	servers []*http.Server
+}
+
+// New creates a maintenance mode controller, initially disabled.
+func New(o Options) *Mode {
+	if o.RetryAfter <= 0 {
+		o.RetryAfter = DefaultRetryAfter
+	}
+
+	if o.DrainPeriod == 0 {
+		o.DrainPeriod = DefaultDrainPeriod
+	}
+
+	m := &Mode{
+		options:    o,
+		retryAfter: strconv.Itoa(int(o.RetryAfter / time.Second)),
+	}
+
+	if len(o.Routes) > 0 {
+		m.routes = make(map[string]bool)
+		for _, id := range o.Routes {
+			m.routes[id] = true
+		}
+	}
+
+	return m
+}
+
+// AddServer registers a server whose idle connections are closed, and
+// whose keep-alives are disabled, at the end of the drain period.
+func (m *Mode) AddServer(s *http.Server) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.servers = append(m.servers, s)
+	if m.drained {
+		s.SetKeepAlivesEnabled(false)
+	}
+}
+
+func (m *Mode) setKeepAlives(enabled bool) {
+	for _, s := range m.servers {
+		s.SetKeepAlivesEnabled(enabled)
+	}
+}
+
+func (m *Mode) drain() {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if !m.enabled || m.drained {
+		return
+	}
+
+	m.drained = true
+	m.setKeepAlives(false)
+	log.Info("maintenance: connections drained")
+}
+
+// Enable switches the proxy into maintenance mode. Enabling it again has
+// no effect.
+func (m *Mode) Enable() {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.enabled {
+		return
+	}
+
+	m.enabled = true
+	m.since = time.Now()
+	if m.options.Readiness != nil {
+		m.options.Readiness.SetMaintenance(true)
+	}
+
+	if m.options.DrainPeriod > 0 {
+		m.timer = time.AfterFunc(m.options.DrainPeriod, m.drain)
+	}
+
+	log.Info("maintenance: enabled")
+}
+
+// Disable returns the proxy to the normal operation.
+func (m *Mode) Disable() {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if !m.enabled {
+		return
+	}
+
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+
+	if m.drained {
+		m.setKeepAlives(true)
+	}
+
+	m.enabled = false
+	m.drained = false
+	m.since = time.Time{}
+	if m.options.Readiness != nil {
+		m.options.Readiness.SetMaintenance(false)
+	}
+
+	log.Info("maintenance: disabled")
+}
+
+// Status returns the current state of the maintenance mode.
+func (m *Mode) Status() *Status {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	s := &Status{
+		Enabled:     m.enabled,
+		Drained:     m.drained,
+		Routes:      m.options.Routes,
+		RetryAfter:  m.options.RetryAfter.Seconds(),
+		DrainPeriod: m.options.DrainPeriod.Seconds(),
+	}
+
+	if m.enabled {
+		since := m.since
+		s.Since = &since
+	}
+
+	return s
+}
+
+// returns whether the mode is enabled, and the fraction of the drain
+// period passed
+func (m *Mode) state() (bool, float64) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	switch {
+	case !m.enabled:
+		return false, 0
+	case m.drained:
+		return true, 1
+	case m.options.DrainPeriod < 0:
+		return true, 0
+	default:
+		return true, float64(time.Since(m.since)) / float64(m.options.DrainPeriod)
+	}
+}
+
+func (m *Mode) inMaintenance(r *http.Request) bool {
+	if m.routes == nil {
+		return true
+	}
+
+	if m.options.Routing == nil {
+		return false
+	}
+
+	rt, _ := m.options.Routing.Route(r)
+	return rt != nil && m.routes[rt.Id]
+}
+
+// Wrap returns a handler that applies the maintenance mode to the
+// requests of the proxy.
+func (m *Mode) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enabled, drained := m.state()
+		if !enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// the connections are closed gradually, with a probability
+		// growing over the drain period, to avoid reconnection storms
+		if drained >= 1 || rand.Float64() < drained {
+			w.Header().Set("Connection", "close")
+		}
+
+		if m.inMaintenance(r) {
+			w.Header().Set("Retry-After", m.retryAfter)
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Close stops the pending drain timer.
+func (m *Mode) Close() {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+}
@@ -0,0 +1,126 @@
+package maintenance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/routing"
+)
+
+type readiness struct {
+	maintenance bool
+}
+
+type lookup struct{}
+
+func (r *readiness) SetMaintenance(m bool) { r.maintenance = m }
+
+// the first path segment is used as the route id
+func (lookup) Route(r *http.Request) (*routing.Route, map[string]string) {
+	rt := &routing.Route{}
+	rt.Id = strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")[0]
+	return rt, nil
+}
+
+func serve(m *Mode, path string) *httptest.ResponseRecorder {
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func TestMaintenanceRoutes(t *testing.T) {
+	rd := &readiness{}
+	m := New(Options{
+		Routing:     lookup{},
+		Routes:      []string{"api"},
+		RetryAfter:  90 * time.Second,
+		DrainPeriod: time.Hour,
+		Readiness:   rd,
+	})
+	defer m.Close()
+
+	if w := serve(m, "/api/foo"); w.Code != http.StatusOK {
+		t.Error("invalid status before the maintenance", w.Code)
+	}
+
+	m.Enable()
+	if !rd.maintenance || !m.Status().Enabled || m.Status().Since == nil {
+		t.Error("failed to enable the maintenance mode")
+	}
+
+	w := serve(m, "/api/foo")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "90" {
+		t.Error("invalid maintenance response", w.Code, w.Header())
+	}
+
+	if w := serve(m, "/other"); w.Code != http.StatusOK {
+		t.Error("invalid status of the route not in maintenance", w.Code)
+	}
+
+	m.Disable()
+	if rd.maintenance || m.Status().Enabled {
+		t.Error("failed to disable the maintenance mode")
+	}
+
+	if w := serve(m, "/api/foo"); w.Code != http.StatusOK {
+		t.Error("invalid status after the maintenance", w.Code)
+	}
+}
+
+func TestMaintenanceAllRoutes(t *testing.T) {
+	m := New(Options{})
+	defer m.Close()
+
+	m.Enable()
+	if w := serve(m, "/any"); w.Code != http.StatusServiceUnavailable ||
+		w.Header().Get("Retry-After") != "300" {
+		t.Error("invalid maintenance response", w.Code, w.Header())
+	}
+}
+
+func TestDrain(t *testing.T) {
+	s := &http.Server{}
+	m := New(Options{Routing: lookup{}, Routes: []string{"api"}, DrainPeriod: 30 * time.Millisecond})
+	defer m.Close()
+	m.AddServer(s)
+
+	m.Enable()
+	timeout := time.After(3 * time.Second)
+	for !m.Status().Drained {
+		select {
+		case <-timeout:
+			t.Fatal("failed to drain")
+		case <-time.After(3 * time.Millisecond):
+		}
+	}
+
+	if w := serve(m, "/other"); w.Code != http.StatusOK || w.Header().Get("Connection") != "close" {
+		t.Error("failed to close the connection", w.Code, w.Header())
+	}
+
+	m.Disable()
+	if m.Status().Drained {
+		t.Error("failed to reset the drain")
+	}
+
+	if w := serve(m, "/other"); w.Header().Get("Connection") != "" {
+		t.Error("unexpected connection close")
+	}
+}
+
+func TestNoDrain(t *testing.T) {
+	m := New(Options{DrainPeriod: -1})
+	defer m.Close()
+
+	m.Enable()
+	if w := serve(m, "/any"); w.Header().Get("Connection") != "" {
+		t.Error("unexpected connection close")
+	}
+}
@@ -0,0 +1,19 @@
+/*
+Package maxprocs adjusts GOMAXPROCS to the CPU limit of the container.
+
+The Go runtime sets GOMAXPROCS to the number of the CPUs available to the
+process, including the CPU affinity mask, but not the CPU quota of the
+cgroup. On large hosts, a container limited to a few CPUs would run with
+as many Ps as the host has CPUs, and it would be throttled. Adjust reads
+the quota from the cgroup filesystem, both version 1 and 2, mounted at
+/sys/fs/cgroup, as in the containers, and lowers GOMAXPROCS to the quota,
+rounded down, but at least 1.
+
+When the GOMAXPROCS environment variable is set, it is respected, and
+GOMAXPROCS is not adjusted.
+
+The process doesn't pin itself to CPUs or NUMA nodes. The pinning is best
+done when starting the process, e.g. with taskset, numactl or the cpuset
+of the container, and the Go runtime respects it.
+*/
+package maxprocs
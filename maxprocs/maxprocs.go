@@ -0,0 +1,138 @@
+package maxprocs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultCgroupRoot is where the cgroup filesystem is mounted.
+const DefaultCgroupRoot = "/sys/fs/cgroup"
+
+var errInvalidQuota = errors.New("invalid CPU quota")
+
+func readFile(name string) (string, error) {
+	b, err := ioutil.ReadFile(name)
+	return strings.TrimSpace(string(b)), err
+}
+
+func parseQuota(quota, period string) (float64, error) {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if p <= 0 {
+		return 0, errInvalidQuota
+	}
+
+	if q <= 0 {
+		return 0, nil
+	}
+
+	return float64(q) / float64(p), nil
+}
+
+// cgroup v2, e.g. "200000 100000" or "max 100000"
+func cgroup2Limit(root string) (float64, error) {
+	s, err := readFile(filepath.Join(root, "cpu.max"))
+	if err != nil {
+		return 0, err
+	}
+
+	f := strings.Fields(s)
+	if len(f) != 2 {
+		return 0, errInvalidQuota
+	}
+
+	if f[0] == "max" {
+		return 0, nil
+	}
+
+	return parseQuota(f[0], f[1])
+}
+
+// cgroup v1, the quota is -1 when not limited
+func cgroup1Limit(root string) (float64, error) {
+	dir := filepath.Join(root, "cpu")
+	quota, err := readFile(filepath.Join(dir, "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0, err
+	}
+
+	period, err := readFile(filepath.Join(dir, "cpu.cfs_period_us"))
+	if err != nil {
+		return 0, err
+	}
+
+	return parseQuota(quota, period)
+}
+
+// CPULimit returns the CPU quota of the cgroup mounted at root, in CPUs,
+// or 0, when the CPU usage is not limited.
+func CPULimit(root string) (float64, error) {
+	limit, err := cgroup2Limit(root)
+	if os.IsNotExist(err) {
+		limit, err = cgroup1Limit(root)
+	}
+
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	return limit, err
+}
+
+// returns the GOMAXPROCS for the limit, or 0, when it doesn't need to
+// be adjusted
+func procs(limit float64, current int) int {
+	if limit <= 0 {
+		return 0
+	}
+
+	n := int(limit)
+	if n < 1 {
+		n = 1
+	}
+
+	if n >= current {
+		return 0
+	}
+
+	return n
+}
+
+// Adjust lowers GOMAXPROCS to the CPU quota of the cgroup mounted at
+// root, when the quota is lower than the current value, and the
+// GOMAXPROCS environment variable is not set. It returns the value of
+// GOMAXPROCS after the adjustment.
+func Adjust(root string) (int, error) {
+	current := runtime.GOMAXPROCS(0)
+	if os.Getenv("GOMAXPROCS") != "" {
+		return current, nil
+	}
+
+	limit, err := CPULimit(root)
+	if err != nil {
+		return current, err
+	}
+
+	if n := procs(limit, current); n > 0 {
+		log.Infof("adjusting GOMAXPROCS from %d to %d, to the CPU quota of the cgroup: %g", current, n, limit)
+		runtime.GOMAXPROCS(n)
+		return n, nil
+	}
+
+	return current, nil
+}
@@ -0,0 +1,132 @@
+package maxprocs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(p, []byte(content+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func TestCPULimit(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		files    map[string]string
+		expected float64
+		fail     bool
+	}{{
+		title: "no cgroup",
+	}, {
+		title:    "cgroup v2",
+		files:    map[string]string{"cpu.max": "250000 100000"},
+		expected: 2.5,
+	}, {
+		title: "cgroup v2, not limited",
+		files: map[string]string{"cpu.max": "max 100000"},
+	}, {
+		title: "cgroup v2, invalid",
+		files: map[string]string{"cpu.max": "250000"},
+		fail:  true,
+	}, {
+		title: "cgroup v1",
+		files: map[string]string{
+			"cpu/cpu.cfs_quota_us":  "50000",
+			"cpu/cpu.cfs_period_us": "100000",
+		},
+		expected: .5,
+	}, {
+		title: "cgroup v1, not limited",
+		files: map[string]string{
+			"cpu/cpu.cfs_quota_us":  "-1",
+			"cpu/cpu.cfs_period_us": "100000",
+		},
+	}, {
+		title: "cgroup v1, invalid period",
+		files: map[string]string{
+			"cpu/cpu.cfs_quota_us":  "50000",
+			"cpu/cpu.cfs_period_us": "0",
+		},
+		fail: true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			root := writeFiles(t, test.files)
+			defer os.RemoveAll(root)
+
+			limit, err := CPULimit(root)
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if limit != test.expected {
+				t.Errorf("expected: %g, got: %g", test.expected, limit)
+			}
+		})
+	}
+}
+
+func TestProcs(t *testing.T) {
+	for _, test := range []struct {
+		limit    float64
+		current  int
+		expected int
+	}{
+		{0, 8, 0},
+		{.5, 8, 1},
+		{2.5, 8, 2},
+		{8, 8, 0},
+		{16, 8, 0},
+	} {
+		if n := procs(test.limit, test.current); n != test.expected {
+			t.Errorf("%g, %d: expected: %d, got: %d", test.limit, test.current, test.expected, n)
+		}
+	}
+}
+
+func TestAdjust(t *testing.T) {
+	current := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(current)
+
+	root := writeFiles(t, map[string]string{"cpu.max": "100000 100000"})
+	defer os.RemoveAll(root)
+
+	if os.Getenv("GOMAXPROCS") != "" {
+		t.Skip("GOMAXPROCS is set")
+	}
+
+	n, err := Adjust(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 1 || runtime.GOMAXPROCS(0) != 1 {
+		t.Error("failed to adjust GOMAXPROCS", n, runtime.GOMAXPROCS(0))
+	}
+}
@@ -34,5 +34,106 @@ metrics that share the same prefix.
 
 If you request an unknown key or prefix the response will be an HTTP 404.
 
+The path segments after /metrics/ are joined with dots, so that e.g. "/metrics/serveroute/my-route" returns the metrics
+with the keys starting with "serveroute.my-route". The prefix can also be set in the query, e.g.
+"/metrics?prefix=backend.". The response can be limited to some families of the metrics, e.g. "?family=timers" or
+"?family=counters,meters". The families are: counters, gauges, histograms, meters, timers and unknown.
+
+For the registries with many keys, the response can be paginated with the limit query parameter. The metrics are
+returned in the order of their keys, and when there are more than the limit, the response has a Link header with the
+rel="next" URL, which continues after the last returned key, set in the after query parameter:
+
+	GET /metrics?prefix=backend.&limit=100
+	Link: </metrics?after=backend.route-99&limit=100&prefix=backend.>; rel="next"
+
+The response is written one metric at a time, without collecting all the values first.
+
+When ResetToken is set, the counters, the meters, the timers and the histograms selected the same way can be reset,
+e.g. after a deployment, with a DELETE request, or a POST request with reset=true, authorized with the token as a bearer
+token. The reset metrics are removed from the registry, and created again by their next measurement. The gauges are
+kept:
+
+	DELETE /metrics/serveroute/my-route
+	Authorization: Bearer <token>
+
+The reset responds with 204, with 404 when no metrics matched, with 401 when the token is missing or invalid, and with
+403 when ResetToken is not set.
+
+Recording
+
+The measurements are not applied to the registry on the path of the requests. They are buffered in sharded, lock-free
+queues, and a background worker applies them in batches, typically within a millisecond. Recording a measurement
+doesn't allocate, take locks or start goroutines. When a queue is full, the measurement is applied directly. Flush
+applies the buffered measurements immediately, and Close stops the worker.
+
+The keys of the measurements are interned: they are formatted once, for each combination of e.g. the route, method and
+status code, and then reused, so that the measurements don't allocate new keys for every request.
+
+The registry created by default is sharded by the hash of the metrics keys, into as many shards as CPUs, so that the
+cost of looking up and registering the metrics stays flat as the number of the keys grows. The shards are merged when
+the metrics are listed. The number of the shards can be set with RegistryShards.
+
+Timer Sampling
+
+By default, the timers keep a uniformly random sample of 1024 of the measured durations, which may leave out the rare,
+long durations of the busy routes, and so underestimate the p99 and p999 percentiles. The sampling can be set with
+TimerSample:
+
+	uniform   a uniformly random sample of TimerReservoirSize durations (default)
+	expdecay  a sample of TimerReservoirSize durations, biased towards the last five minutes
+	hdr       all the durations, counted in fixed, log-linear buckets
+
+The hdr timers count every duration in buckets whose width grows with the duration, like HdrHistogram, so that the
+relative error of the percentiles is bounded by HistogramPrecision, the number of the significant decimal digits, from 1
+to 5, default 2. The durations longer than HistogramMaxValue, default 1m, are counted as HistogramMaxValue. The hdr
+timers are updated without locks, and their memory doesn't grow with the number of the measurements.
+
+Custom Metrics Backends
+
+The proxy and the other components collect the metrics through the Metrics interface, by default with the CodaHale
+implementation created by New. A custom implementation can be set with InitWith, or passed to the proxy in its options,
+e.g. to forward the metrics to an in-house backend. Only the CodaHale metrics are served on the listener and pushed by
+the reporters. The filters can emit their own metrics through the same interface, with the MeasureSince, IncCounter,
+IncCounterBy and UpdateGauge methods.
+
+Push Reporting
+
+When GraphiteAddr or StatsdAddr is set, the metrics are pushed periodically, every ReportInterval, to Graphite, in the
+plaintext protocol, or to StatsD, e.g. when the metrics listener cannot be reached from the monitoring system. This
+works with or without the listener. The addresses can be prefixed with the network, tcp:// or udp://. The keys are
+prefixed with GraphitePrefix and StatsdPrefix, or by default with Prefix. The durations are pushed in milliseconds. To
+StatsD, the counters are pushed as the changes since the previous push, and the timers as gauges of their
+percentiles. When the connection fails, it is established again on the next push.
+
+Namespaces
+
+When RouteNamespace is set, the keys of the metrics of the routes that belong to a namespace are prefixed with
+namespace.<name>., e.g. namespace.team_a.backend.team_a__api, so that the metrics of a tenant can be queried on the
+/metrics/namespace.<name>. path. The requests rejected by the rate limit of a namespace are counted with the
+namespace.<name>.ratelimited key.
+
+Prometheus
+
+When EnablePrometheus is set, the durations of serving the requests are recorded in histograms, labeled by route,
+method and status code, and served on /metrics/prometheus in the Prometheus text format. When the scraper accepts the
+OpenMetrics format, and tracing is enabled, each histogram bucket carries the trace ID of the last sampled request
+observed in it as an exemplar, so that the slow requests can be opened in the tracing system directly from the
+dashboards.
+
+The metrics of the registry are served on the same path, translated from the dotted keys to Prometheus metric names with
+labels for the route, host, method, status code, filter and namespace, e.g. the response.200.GET.skipper.api timer is
+served as:
+
+    skipper_response_duration_seconds{route="api",method="GET",code="200",quantile="0.99"} 0.012
+
+The host labels carry the original hosts. In the dotted keys, the dots and the colons of the hosts are replaced by
+underscores, and when two hosts would get the same key this way, e.g. a_b.example.org and a.b.example.org, the key of
+the later one gets a numbered suffix, like backendhost.a_b_example_org_2, so that they are measured separately.
+
+The timers and the histograms are served as summaries, the timers in seconds, the counters and the meters as counters,
+and the gauges as gauges. The keys that are not known, e.g. the runtime metrics, are served with their names sanitized,
+without labels. When a Prometheus scraper requests /metrics, with the Prometheus or OpenMetrics format in the Accept
+header, the same is served there, so that skipper can be scraped without an exporter.
+
 */
 package metrics
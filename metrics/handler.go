@@ -1,58 +1,301 @@
 package metrics
 
 import (
+	"bufio"
+	"crypto/subtle"
 	"encoding/json"
+	"fmt"
 	"github.com/rcrowley/go-metrics"
 	"net/http"
-	"path"
+	"sort"
+	"strconv"
 	"strings"
 )
 
+// the families of the metrics in the JSON responses
+var metricsFamilies = map[string]bool{
+	"counters":   true,
+	"gauges":     true,
+	"histograms": true,
+	"meters":     true,
+	"timers":     true,
+	"unknown":    true,
+}
+
 type metricsHandler struct {
-	registry metrics.Registry
-	profile  http.Handler
-	options  Options
+	registry   metrics.Registry
+	profile    http.Handler
+	prometheus http.Handler
+	support    *http.ServeMux
+	options    Options
 }
 
-func filterMetrics(reg metrics.Registry, prefix, key string) skipperMetrics {
-	metrics := make(skipperMetrics)
+// the selection of the metrics, parsed from the path and the query of a
+// request
+type metricsQuery struct {
+	// the key or the key prefix, without the prefix of the options
+	key string
+
+	// when set, a metric matching the key exactly is returned alone
+	exact bool
+
+	families map[string]bool
+	after    string
+	limit    int
+}
+
+// a metric matching a query. The name contains the prefix of the options.
+type metricEntry struct {
+	key, name, family string
+	metric            interface{}
+}
+
+// parses the selection of the metrics. The path segments after /metrics/
+// are joined with dots, so that e.g. /metrics/serveroute/foo selects the
+// keys starting with serveroute.foo.
+func parseMetricsQuery(r *http.Request, prefix string) (metricsQuery, error) {
+	var q metricsQuery
+	key := strings.Trim(strings.TrimPrefix(r.URL.Path, "/metrics"), "/")
+	key = strings.Replace(key, "/", ".", -1)
+
+	values := r.URL.Query()
+	if p := values.Get("prefix"); p != "" {
+		if key != "" {
+			return q, fmt.Errorf("both a key in the path and a prefix in the query are set")
+		}
 
-	canonicalKey := strings.TrimPrefix(key, prefix)
-	m := reg.Get(canonicalKey)
-	if m != nil {
-		metrics[key] = m
+		key = p
 	} else {
-		reg.Each(func(name string, i interface{}) {
-			if key == "" || (strings.HasPrefix(name, canonicalKey)) {
-				metrics[prefix+name] = i
+		q.exact = true
+	}
+
+	q.key = strings.TrimPrefix(key, prefix)
+
+	for _, v := range values["family"] {
+		for _, f := range strings.Split(v, ",") {
+			if !metricsFamilies[f] {
+				return q, fmt.Errorf("unknown metrics family: %s", f)
+			}
+
+			if q.families == nil {
+				q.families = make(map[string]bool)
 			}
-		})
+
+			q.families[f] = true
+		}
+	}
+
+	if l := values.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			return q, fmt.Errorf("invalid limit: %s", l)
+		}
+
+		q.limit = limit
 	}
 
-	return metrics
+	q.after = values.Get("after")
+	return q, nil
 }
 
-func (mh *metricsHandler) sendMetrics(w http.ResponseWriter, p string) {
-	_, k := path.Split(p)
+// returns the metrics matching the key and the families of the query,
+// sorted by their names. The pagination of the query is not applied.
+func (mh *metricsHandler) selectMetrics(q metricsQuery) []metricEntry {
+	var entries []metricEntry
+	add := func(key string, m interface{}) {
+		family := metricFamily(m)
+		if q.families == nil || q.families[family] {
+			entries = append(entries, metricEntry{
+				key:    key,
+				name:   mh.options.Prefix + key,
+				family: family,
+				metric: m,
+			})
+		}
+	}
 
-	metrics := filterMetrics(mh.registry, mh.options.Prefix, k)
+	if q.exact && q.key != "" {
+		if m := mh.registry.Get(q.key); m != nil {
+			add(q.key, m)
+			return entries
+		}
+	}
 
-	if len(metrics) > 0 {
-		w.WriteHeader(http.StatusOK)
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(metrics)
-	} else {
-		http.NotFound(w, nil)
+	mh.registry.Each(func(key string, m interface{}) {
+		if strings.HasPrefix(key, q.key) {
+			add(key, m)
+		}
+	})
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries
+}
+
+// returns the page of the entries after the cursor of the query, and
+// whether there are more
+func paginate(entries []metricEntry, q metricsQuery) ([]metricEntry, bool) {
+	if q.after != "" {
+		i := sort.Search(len(entries), func(i int) bool { return entries[i].name > q.after })
+		entries = entries[i:]
+	}
+
+	if q.limit > 0 && len(entries) > q.limit {
+		return entries[:q.limit], true
+	}
+
+	return entries, false
+}
+
+func writeJSONString(w *bufio.Writer, s string) {
+	b, _ := json.Marshal(s)
+	w.Write(b)
+}
+
+// writes the entries grouped by their families, one metric at a time,
+// without building the whole response in memory
+func writeMetricsJSON(w *bufio.Writer, entries []metricEntry) {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].family < entries[j].family })
+
+	w.WriteByte('{')
+	for i, e := range entries {
+		if i == 0 || e.family != entries[i-1].family {
+			if i > 0 {
+				w.WriteString("},")
+			}
+
+			writeJSONString(w, e.family)
+			w.WriteString(":{")
+		} else {
+			w.WriteByte(',')
+		}
+
+		_, values := metricValues(e.metric)
+		b, err := json.Marshal(values)
+		if err != nil {
+			b, _ = json.Marshal(map[string]interface{}{"error": err.Error()})
+		}
+
+		writeJSONString(w, e.name)
+		w.WriteByte(':')
+		w.Write(b)
+	}
+
+	if len(entries) > 0 {
+		w.WriteByte('}')
+	}
+
+	w.WriteString("}\n")
+}
+
+func (mh *metricsHandler) sendMetrics(w http.ResponseWriter, r *http.Request) {
+	q, err := parseMetricsQuery(r, mh.options.Prefix)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, more := paginate(mh.selectMetrics(q), q)
+	if len(entries) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	if more {
+		next := *r.URL
+		values := next.Query()
+		values.Set("after", entries[len(entries)-1].name)
+		next.RawQuery = values.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	bw := bufio.NewWriter(w)
+	writeMetricsJSON(bw, entries)
+	bw.Flush()
+}
+
+func (mh *metricsHandler) authorizedReset(r *http.Request) bool {
+	const bearer = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, bearer) {
+		return false
+	}
+
+	token := strings.TrimPrefix(auth, bearer)
+	return subtle.ConstantTimeCompare([]byte(token), []byte(mh.options.ResetToken)) == 1
+}
+
+// removes the selected counters, meters, timers and histograms from the
+// registry, so that they are created again, empty, by the next
+// measurement. The gauges are kept.
+func (mh *metricsHandler) resetMetrics(w http.ResponseWriter, r *http.Request) {
+	if mh.options.ResetToken == "" {
+		http.Error(w, "resetting the metrics is disabled", http.StatusForbidden)
+		return
+	}
+
+	if !mh.authorizedReset(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	q, err := parseMetricsQuery(r, mh.options.Prefix)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var reset int
+	for _, e := range mh.selectMetrics(q) {
+		if e.family != "gauges" {
+			mh.registry.Unregister(e.key)
+			reset++
+		}
+	}
+
+	if reset == 0 {
+		http.NotFound(w, r)
+		return
 	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (mh *metricsHandler) supportHandler(r *http.Request) http.Handler {
+	if mh.support == nil {
+		return nil
+	}
+
+	if h, pattern := mh.support.Handler(r); pattern != "" {
+		return h
+	}
+
+	return nil
+}
+
+func isResetRequest(r *http.Request) bool {
+	return r.Method == "DELETE" || r.Method == "POST" && r.URL.Query().Get("reset") == "true"
 }
 
-// This listener is only used to expose the metrics
+// This listener is used to expose the metrics, and the additional
+// support handlers
 func (mh *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	p := r.URL.Path
-	if r.Method == "GET" && (p == "/metrics" || strings.HasPrefix(p, "/metrics/")) {
-		mh.sendMetrics(w, strings.TrimPrefix(p, "/metrics"))
+	isMetrics := p == "/metrics" || strings.HasPrefix(p, "/metrics/")
+	if mh.prometheus != nil && r.Method == "GET" && (p == PrometheusPath || p == "/metrics" && acceptsPrometheus(r)) {
+		mh.prometheus.ServeHTTP(w, r)
+	} else if isMetrics && mh.registry == nil {
+		http.NotFound(w, r)
+	} else if isMetrics && r.Method == "GET" {
+		mh.sendMetrics(w, r)
+	} else if isMetrics && isResetRequest(r) {
+		mh.resetMetrics(w, r)
 	} else if mh.profile != nil && r.Method == "GET" && (p == "/debug/pprof" || strings.HasPrefix(p, "/debug/pprof/")) {
 		mh.profile.ServeHTTP(w, r)
+	} else if h := mh.supportHandler(r); h != nil {
+		h.ServeHTTP(w, r)
 	} else {
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 	}
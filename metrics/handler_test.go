@@ -5,8 +5,11 @@ import (
 	"github.com/rcrowley/go-metrics"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestBadRequests(t *testing.T) {
@@ -156,3 +159,197 @@ func TestUnknownMetricRequest(t *testing.T) {
 		t.Error("Request for unknown metrics should return a Not Found status")
 	}
 }
+
+func TestSupportHandlers(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/foo", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	mh := &metricsHandler{registry: metrics.NewRegistry(), support: mux}
+
+	r, _ := http.NewRequest("DELETE", "/foo", nil)
+	rw := httptest.NewRecorder()
+	mh.ServeHTTP(rw, r)
+	if rw.Code != http.StatusTeapot {
+		t.Error("failed to serve the support handler", rw.Code)
+	}
+
+	r, _ = http.NewRequest("GET", "/bar", nil)
+	rw = httptest.NewRecorder()
+	mh.ServeHTTP(rw, r)
+	if rw.Code != http.StatusBadRequest {
+		t.Error("unexpected response for unknown path", rw.Code)
+	}
+}
+
+func newQueryTestHandler(o Options) *metricsHandler {
+	reg := metrics.NewRegistry()
+	for _, key := range []string{"serveroute.foo.GET.200", "serveroute.foo.GET.404", "serveroute.bar.GET.200", "backend.foo"} {
+		metrics.GetOrRegisterTimer(key, reg).Update(time.Millisecond)
+	}
+
+	metrics.GetOrRegisterCounter("errors.backend.foo", reg).Inc(1)
+	metrics.GetOrRegisterGauge("backend.gauge", reg).Update(42)
+	return &metricsHandler{registry: reg, options: o}
+}
+
+func getMetrics(t *testing.T, mh *metricsHandler, url string) (*httptest.ResponseRecorder, map[string]map[string]interface{}) {
+	r, _ := http.NewRequest("GET", url, nil)
+	rw := httptest.NewRecorder()
+	mh.ServeHTTP(rw, r)
+
+	var data map[string]map[string]interface{}
+	if rw.Code == http.StatusOK {
+		if err := json.Unmarshal(rw.Body.Bytes(), &data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return rw, data
+}
+
+func metricNames(data map[string]map[string]interface{}) []string {
+	var names []string
+	for _, family := range data {
+		for name := range family {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func TestMetricsQuery(t *testing.T) {
+	mh := newQueryTestHandler(Options{})
+	for _, test := range []struct {
+		url      string
+		code     int
+		expected []string
+	}{
+		{"/metrics/serveroute/foo", 200, []string{"serveroute.foo.GET.200", "serveroute.foo.GET.404"}},
+		{"/metrics/serveroute/foo/GET/200", 200, []string{"serveroute.foo.GET.200"}},
+		{"/metrics?prefix=backend.", 200, []string{"backend.foo", "backend.gauge"}},
+		{"/metrics?prefix=backend.&family=timers", 200, []string{"backend.foo"}},
+		{"/metrics?family=counters,gauges", 200, []string{"backend.gauge", "errors.backend.foo"}},
+		{"/metrics?family=histograms", 404, nil},
+		{"/metrics?family=foo", 400, nil},
+		{"/metrics/serveroute?prefix=backend.", 400, nil},
+		{"/metrics?limit=0", 400, nil},
+	} {
+		t.Run(test.url, func(t *testing.T) {
+			rw, data := getMetrics(t, mh, test.url)
+			if rw.Code != test.code {
+				t.Fatal("invalid status code", rw.Code)
+			}
+
+			if names := metricNames(data); !reflect.DeepEqual(names, test.expected) {
+				t.Error("invalid metrics", names, test.expected)
+			}
+		})
+	}
+}
+
+func TestMetricsPagination(t *testing.T) {
+	mh := newQueryTestHandler(Options{Prefix: "zmon."})
+	var names []string
+	url := "/metrics?limit=2"
+	for i := 0; url != ""; i++ {
+		if i > 3 {
+			t.Fatal("too many pages")
+		}
+
+		rw, data := getMetrics(t, mh, url)
+		if rw.Code != http.StatusOK {
+			t.Fatal("invalid status code", rw.Code)
+		}
+
+		page := metricNames(data)
+		if len(page) > 2 {
+			t.Error("page too long", page)
+		}
+
+		names = append(names, page...)
+		url = ""
+		if link := rw.Header().Get("Link"); link != "" {
+			url = strings.TrimPrefix(strings.TrimSuffix(link, `>; rel="next"`), "<")
+		}
+	}
+
+	expected := []string{
+		"zmon.backend.foo",
+		"zmon.backend.gauge",
+		"zmon.errors.backend.foo",
+		"zmon.serveroute.bar.GET.200",
+		"zmon.serveroute.foo.GET.200",
+		"zmon.serveroute.foo.GET.404",
+	}
+
+	if !reflect.DeepEqual(names, expected) {
+		t.Error("invalid metrics", names)
+	}
+}
+
+func TestResetMetrics(t *testing.T) {
+	reset := func(mh *metricsHandler, method, url, token string) int {
+		r, _ := http.NewRequest(method, url, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rw := httptest.NewRecorder()
+		mh.ServeHTTP(rw, r)
+		return rw.Code
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		mh := newQueryTestHandler(Options{})
+		if code := reset(mh, "DELETE", "/metrics", "secret"); code != http.StatusForbidden {
+			t.Error("invalid status code", code)
+		}
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		mh := newQueryTestHandler(Options{ResetToken: "secret"})
+		for _, token := range []string{"", "wrong"} {
+			if code := reset(mh, "DELETE", "/metrics", token); code != http.StatusUnauthorized {
+				t.Error("invalid status code", code)
+			}
+		}
+
+		if mh.registry.Get("backend.foo") == nil {
+			t.Error("unauthorized reset")
+		}
+	})
+
+	t.Run("by prefix", func(t *testing.T) {
+		mh := newQueryTestHandler(Options{ResetToken: "secret"})
+		if code := reset(mh, "DELETE", "/metrics/serveroute/foo", "secret"); code != http.StatusNoContent {
+			t.Fatal("invalid status code", code)
+		}
+
+		if mh.registry.Get("serveroute.foo.GET.200") != nil || mh.registry.Get("serveroute.foo.GET.404") != nil {
+			t.Error("failed to reset the metrics")
+		}
+
+		if mh.registry.Get("serveroute.bar.GET.200") == nil {
+			t.Error("unexpected reset")
+		}
+
+		if code := reset(mh, "DELETE", "/metrics/serveroute/foo", "secret"); code != http.StatusNotFound {
+			t.Error("invalid status code", code)
+		}
+	})
+
+	t.Run("keeps the gauges", func(t *testing.T) {
+		mh := newQueryTestHandler(Options{ResetToken: "secret"})
+		if code := reset(mh, "POST", "/metrics?prefix=backend.&reset=true", "secret"); code != http.StatusNoContent {
+			t.Fatal("invalid status code", code)
+		}
+
+		if mh.registry.Get("backend.foo") != nil || mh.registry.Get("backend.gauge") == nil {
+			t.Error("failed to reset the metrics, except for the gauges")
+		}
+	})
+}
@@ -0,0 +1,311 @@
+package metrics
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+const (
+	defaultHistogramPrecision = 2
+	defaultHistogramMaxValue  = time.Minute
+)
+
+// The HDR histogram counts the values in fixed buckets, whose width grows
+// with the magnitude of the values, like the HdrHistogram: the values with
+// the same highest significant digits, by the precision, share a counter.
+// This way, the relative error of the percentiles is bounded, and unlike
+// with the samples, the rare values of the tail are never left out. The
+// values above the highest trackable value are counted as the highest.
+//
+// The updates are lock-free. The counters are allocated in chunks, when
+// first used, so that the histograms of the keys with a narrow range of
+// values stay small.
+type hdrHistogram struct {
+	// accessed atomically, first for the alignment on 32-bit platforms
+	count, sum, min, max int64
+
+	halfCountMagnitude uint
+	halfCount          int64
+	subBucketMask      int64
+	highest            int64
+
+	// the counters, in chunks of halfCount, each stored as a *[]int64
+	chunks []unsafe.Pointer
+}
+
+// the timer measuring the durations in an HDR histogram
+type hdrTimer struct {
+	histogram *hdrHistogram
+	meter     metrics.Meter
+}
+
+// creates a histogram tracking the values from 1 to highest, with the
+// given number of significant decimal digits, from 1 to 5
+func newHDRHistogram(highest int64, precision int) *hdrHistogram {
+	if precision < 1 {
+		precision = 1
+	} else if precision > 5 {
+		precision = 5
+	}
+
+	if highest < 2 {
+		highest = 2
+	}
+
+	largestSingleUnit := 2 * math.Pow10(precision)
+	countMagnitude := uint(math.Ceil(math.Log2(largestSingleUnit)))
+	subBucketCount := int64(1) << countMagnitude
+
+	buckets := 1
+	for smallestUntrackable := subBucketCount; smallestUntrackable <= highest; buckets++ {
+		if smallestUntrackable > math.MaxInt64/2 {
+			buckets++
+			break
+		}
+
+		smallestUntrackable <<= 1
+	}
+
+	return &hdrHistogram{
+		min:                math.MaxInt64,
+		halfCountMagnitude: countMagnitude - 1,
+		halfCount:          subBucketCount / 2,
+		subBucketMask:      subBucketCount - 1,
+		highest:            highest,
+		chunks:             make([]unsafe.Pointer, buckets+1),
+	}
+}
+
+func (h *hdrHistogram) index(v int64) int {
+	bucket := 64 - bits.LeadingZeros64(uint64(v|h.subBucketMask)) - int(h.halfCountMagnitude+1)
+	subBucket := v >> uint(bucket)
+	return (bucket+1)<<h.halfCountMagnitude + int(subBucket-h.halfCount)
+}
+
+// returns the lowest value and the size of the range counted at an index
+func (h *hdrHistogram) valueRange(index int) (int64, int64) {
+	bucket := index>>h.halfCountMagnitude - 1
+	subBucket := int64(index)&(h.halfCount-1) + h.halfCount
+	if bucket < 0 {
+		subBucket -= h.halfCount
+		bucket = 0
+	}
+
+	return subBucket << uint(bucket), 1 << uint(bucket)
+}
+
+func (h *hdrHistogram) chunk(i int) []int64 {
+	if p := atomic.LoadPointer(&h.chunks[i]); p != nil {
+		return *(*[]int64)(p)
+	}
+
+	c := make([]int64, h.halfCount)
+	if atomic.CompareAndSwapPointer(&h.chunks[i], nil, unsafe.Pointer(&c)) {
+		return c
+	}
+
+	return *(*[]int64)(atomic.LoadPointer(&h.chunks[i]))
+}
+
+func (h *hdrHistogram) update(v int64) {
+	if v < 0 {
+		v = 0
+	} else if v > h.highest {
+		v = h.highest
+	}
+
+	i := h.index(v)
+	atomic.AddInt64(&h.chunk(i >> h.halfCountMagnitude)[int64(i)&(h.halfCount-1)], 1)
+	atomic.AddInt64(&h.count, 1)
+	atomic.AddInt64(&h.sum, v)
+
+	for min := atomic.LoadInt64(&h.min); v < min; min = atomic.LoadInt64(&h.min) {
+		if atomic.CompareAndSwapInt64(&h.min, min, v) {
+			break
+		}
+	}
+
+	for max := atomic.LoadInt64(&h.max); v > max; max = atomic.LoadInt64(&h.max) {
+		if atomic.CompareAndSwapInt64(&h.max, max, v) {
+			break
+		}
+	}
+}
+
+// calls f with the index and the count of every counter that is not
+// zero, in the order of the values
+func (h *hdrHistogram) each(f func(index int, count int64)) {
+	for ci := range h.chunks {
+		p := atomic.LoadPointer(&h.chunks[ci])
+		if p == nil {
+			continue
+		}
+
+		for j := range *(*[]int64)(p) {
+			if c := atomic.LoadInt64(&(*(*[]int64)(p))[j]); c > 0 {
+				f(ci<<h.halfCountMagnitude+j, c)
+			}
+		}
+	}
+}
+
+func (h *hdrHistogram) snapshot() *hdrHistogram {
+	s := hdrHistogram{
+		halfCountMagnitude: h.halfCountMagnitude,
+		halfCount:          h.halfCount,
+		subBucketMask:      h.subBucketMask,
+		highest:            h.highest,
+		chunks:             make([]unsafe.Pointer, len(h.chunks)),
+	}
+
+	h.each(func(index int, count int64) {
+		s.chunk(index >> h.halfCountMagnitude)[int64(index)&(h.halfCount-1)] = count
+	})
+
+	// the counts are updated before the totals, so the sum of the
+	// copied counters is used as the count
+	var count int64
+	s.each(func(_ int, c int64) { count += c })
+	s.count = count
+	s.sum = atomic.LoadInt64(&h.sum)
+	s.min = atomic.LoadInt64(&h.min)
+	s.max = atomic.LoadInt64(&h.max)
+	return &s
+}
+
+func (h *hdrHistogram) Count() int64 { return atomic.LoadInt64(&h.count) }
+func (h *hdrHistogram) Sum() int64   { return atomic.LoadInt64(&h.sum) }
+func (h *hdrHistogram) Max() int64   { return atomic.LoadInt64(&h.max) }
+
+func (h *hdrHistogram) Min() int64 {
+	if h.Count() == 0 {
+		return 0
+	}
+
+	return atomic.LoadInt64(&h.min)
+}
+
+func (h *hdrHistogram) Mean() float64 {
+	count := h.Count()
+	if count == 0 {
+		return 0
+	}
+
+	return float64(h.Sum()) / float64(count)
+}
+
+// the percentiles are returned as the highest value equivalent to the
+// counter, limited by the observed minimum and maximum
+func (h *hdrHistogram) Percentiles(ps []float64) []float64 {
+	s := h.snapshot()
+	values := make([]float64, len(ps))
+	if s.count == 0 {
+		return values
+	}
+
+	ranks := make([]int64, len(ps))
+	for i, p := range ps {
+		ranks[i] = int64(math.Ceil(p * float64(s.count)))
+		if ranks[i] < 1 {
+			ranks[i] = 1
+		}
+	}
+
+	var cumulative int64
+	s.each(func(index int, count int64) {
+		cumulative += count
+		low, size := s.valueRange(index)
+		v := low + size - 1
+		if v > s.max {
+			v = s.max
+		} else if v < s.min {
+			v = s.min
+		}
+
+		for i, r := range ranks {
+			if r > 0 && cumulative >= r {
+				values[i] = float64(v)
+				ranks[i] = 0
+			}
+		}
+	})
+
+	for i, r := range ranks {
+		if r > 0 {
+			values[i] = float64(s.max)
+		}
+	}
+
+	return values
+}
+
+func (h *hdrHistogram) Percentile(p float64) float64 {
+	return h.Percentiles([]float64{p})[0]
+}
+
+// the variance is calculated from the middle of the ranges of the
+// counters
+func (h *hdrHistogram) Variance() float64 {
+	s := h.snapshot()
+	if s.count == 0 {
+		return 0
+	}
+
+	mean := float64(s.sum) / float64(s.count)
+	var sum float64
+	s.each(func(index int, count int64) {
+		low, size := s.valueRange(index)
+		d := float64(low) + float64(size-1)/2 - mean
+		sum += float64(count) * d * d
+	})
+
+	return sum / float64(s.count)
+}
+
+func (h *hdrHistogram) StdDev() float64 {
+	return math.Sqrt(h.Variance())
+}
+
+func newHDRTimer(highest time.Duration, precision int) *hdrTimer {
+	return &hdrTimer{
+		histogram: newHDRHistogram(int64(highest), precision),
+		meter:     metrics.NewMeter(),
+	}
+}
+
+func (t *hdrTimer) Count() int64                       { return t.histogram.Count() }
+func (t *hdrTimer) Max() int64                         { return t.histogram.Max() }
+func (t *hdrTimer) Mean() float64                      { return t.histogram.Mean() }
+func (t *hdrTimer) Min() int64                         { return t.histogram.Min() }
+func (t *hdrTimer) Percentile(p float64) float64       { return t.histogram.Percentile(p) }
+func (t *hdrTimer) Percentiles(ps []float64) []float64 { return t.histogram.Percentiles(ps) }
+func (t *hdrTimer) Rate1() float64                     { return t.meter.Rate1() }
+func (t *hdrTimer) Rate5() float64                     { return t.meter.Rate5() }
+func (t *hdrTimer) Rate15() float64                    { return t.meter.Rate15() }
+func (t *hdrTimer) RateMean() float64                  { return t.meter.RateMean() }
+func (t *hdrTimer) StdDev() float64                    { return t.histogram.StdDev() }
+func (t *hdrTimer) Stop()                              { t.meter.Stop() }
+func (t *hdrTimer) Sum() int64                         { return t.histogram.Sum() }
+func (t *hdrTimer) UpdateSince(start time.Time)        { t.Update(time.Since(start)) }
+func (t *hdrTimer) Variance() float64                  { return t.histogram.Variance() }
+
+// Snapshot returns a read-only copy of the timer.
+func (t *hdrTimer) Snapshot() metrics.Timer {
+	return &hdrTimer{histogram: t.histogram.snapshot(), meter: t.meter.Snapshot()}
+}
+
+func (t *hdrTimer) Time(f func()) {
+	start := time.Now()
+	f()
+	t.UpdateSince(start)
+}
+
+func (t *hdrTimer) Update(d time.Duration) {
+	t.histogram.update(int64(d))
+	t.meter.Mark(1)
+}
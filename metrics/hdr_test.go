@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestHDRIndexRange(t *testing.T) {
+	h := newHDRHistogram(int64(time.Minute), 2)
+	for _, v := range []int64{0, 1, 2, 127, 128, 255, 256, 1000, 12345, 999999, int64(time.Second), int64(time.Minute)} {
+		low, size := h.valueRange(h.index(v))
+		if v < low || v >= low+size {
+			t.Errorf("value %d not in the range of its index: %d, %d", v, low, size)
+		}
+
+		if size > 1 && float64(size)/float64(v) > 0.01 {
+			t.Errorf("range too wide for the precision: %d, %d", v, size)
+		}
+	}
+}
+
+func TestHDRPercentiles(t *testing.T) {
+	h := newHDRHistogram(int64(time.Minute), 2)
+	for i := 1; i <= 100000; i++ {
+		h.update(int64(i) * int64(time.Microsecond))
+	}
+
+	// the rare, long tail
+	for i := 0; i < 50; i++ {
+		h.update(int64(3 * time.Second))
+	}
+
+	ps := h.Percentiles([]float64{0.5, 0.99, 0.9999, 1})
+	expected := []float64{
+		float64(50 * time.Millisecond),
+		float64(99 * time.Millisecond),
+		float64(3 * time.Second),
+		float64(3 * time.Second),
+	}
+
+	for i, p := range ps {
+		if math.Abs(p-expected[i])/expected[i] > 0.01 {
+			t.Errorf("invalid percentile %d: %v, expected: %v", i, time.Duration(p), time.Duration(expected[i]))
+		}
+	}
+
+	if h.Count() != 100050 {
+		t.Error("invalid count", h.Count())
+	}
+
+	if h.Min() != int64(time.Microsecond) || h.Max() != int64(3*time.Second) {
+		t.Error("invalid min or max", h.Min(), h.Max())
+	}
+}
+
+func TestHDRAboveHighest(t *testing.T) {
+	h := newHDRHistogram(int64(time.Second), 2)
+	h.update(int64(time.Hour))
+	if h.Max() != int64(time.Second) || h.Percentile(1) != float64(time.Second) {
+		t.Error("failed to count the value as the highest", h.Max(), h.Percentile(1))
+	}
+}
+
+func TestHDRTimerSnapshot(t *testing.T) {
+	tm := newHDRTimer(time.Minute, 2)
+	tm.Update(time.Millisecond)
+	s := tm.Snapshot()
+	tm.Update(time.Second)
+	if s.Count() != 1 || s.Max() != int64(time.Millisecond) {
+		t.Error("snapshot changed", s.Count(), s.Max())
+	}
+
+	if tm.Count() != 2 {
+		t.Error("invalid count", tm.Count())
+	}
+}
+
+func TestHDRConcurrentUpdates(t *testing.T) {
+	const (
+		goroutines = 8
+		count      = 10000
+	)
+
+	h := newHDRHistogram(int64(time.Minute), 3)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < count; j++ {
+				h.update(int64(i*count + j + 1))
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	if h.Count() != goroutines*count || h.snapshot().count != goroutines*count {
+		t.Error("invalid count", h.Count())
+	}
+
+	if h.Min() != 1 || h.Max() != goroutines*count {
+		t.Error("invalid min or max", h.Min(), h.Max())
+	}
+}
+
+func TestTimerSample(t *testing.T) {
+	for _, test := range []struct {
+		sample TimerSample
+		check  func(metrics.Timer) bool
+	}{{
+		sample: "",
+		check: func(tm metrics.Timer) bool {
+			_, ok := tm.(*metrics.StandardTimer)
+			return ok
+		},
+	}, {
+		sample: ExpDecaySample,
+		check: func(tm metrics.Timer) bool {
+			_, ok := tm.(*metrics.StandardTimer)
+			return ok
+		},
+	}, {
+		sample: HDRSample,
+		check: func(tm metrics.Timer) bool {
+			_, ok := tm.(*hdrTimer)
+			return ok
+		},
+	}, {
+		sample: "unknown",
+		check: func(tm metrics.Timer) bool {
+			_, ok := tm.(*metrics.StandardTimer)
+			return ok
+		},
+	}} {
+		t.Run(string(test.sample), func(t *testing.T) {
+			m := New(Options{TimerSample: test.sample, EnablePrometheus: true})
+			defer m.Close()
+
+			m.MeasureSince("TestTimerSample", time.Now().Add(-time.Millisecond))
+			m.Flush()
+			tm := m.getTimer("TestTimerSample")
+			if !test.check(tm) {
+				t.Fatalf("invalid timer type: %T", tm)
+			}
+
+			if tm.Count() != 1 || tm.Percentile(0.5) < float64(time.Millisecond) {
+				t.Error("failed to measure", tm.Count(), tm.Percentile(0.5))
+			}
+
+			var b bytes.Buffer
+			writeRegistry(&b, m.reg, false, hostFromKey)
+			if !strings.Contains(b.String(), "skipper_TestTimerSample_seconds_count 1") {
+				t.Error("failed to serve the timer", b.String())
+			}
+		})
+	}
+}
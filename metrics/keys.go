@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// the number of the keys kept in the cache, so that the high cardinality
+// keys, e.g. the hosts, cannot grow it without bounds. Beyond this, the
+// keys are formatted for every measurement.
+const maxInternedKeys = 1 << 16
+
+// the parameters of a key, e.g. the route id, the method and the status
+// code of a response
+type keyParams struct {
+	format    string
+	namespace string
+	s1, s2    string
+	code      int
+}
+
+// The key cache interns the keys of the metrics, so that the repeated
+// measurements reuse the formatted strings instead of allocating a new
+// key for every request. The keys are stored in a sync.Map, so that the
+// lookups of the cached keys don't share a lock. The zero value is ready
+// to use.
+type keyCache struct {
+	keys  sync.Map
+	size  int64
+	hosts hostKeys
+}
+
+// The host keys map the hosts to their parts of the metric keys, and
+// back, so that the Prometheus labels carry the original hosts. When the
+// key part of a host, with the dots and the colons replaced, is already
+// taken by a different host, e.g. a_b and a.b, it gets a numbered suffix,
+// so that the two hosts are measured separately. Beyond the limit of the
+// cached keys, the key parts are not recorded, and the hosts are restored
+// from the keys on a best effort basis.
+type hostKeys struct {
+	keys  sync.Map
+	mx    sync.Mutex
+	hosts map[string]string
+}
+
+func namespacedKey(namespace, key string) string {
+	if namespace == "" {
+		return key
+	}
+
+	return fmt.Sprintf(KeyNamespace, namespace) + key
+}
+
+// returns the key part of a host
+func (h *hostKeys) key(host string) string {
+	if k, ok := h.keys.Load(host); ok {
+		return k.(string)
+	}
+
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if k, ok := h.keys.Load(host); ok {
+		return k.(string)
+	}
+
+	k := hostForKey(host)
+	if len(h.hosts) >= maxInternedKeys {
+		return k
+	}
+
+	if h.hosts == nil {
+		h.hosts = make(map[string]string)
+	}
+
+	base := k
+	for i := 2; ; i++ {
+		if _, taken := h.hosts[k]; !taken {
+			break
+		}
+
+		k = base + "_" + strconv.Itoa(i)
+	}
+
+	h.hosts[k] = host
+	h.keys.Store(host, k)
+	return k
+}
+
+// returns the original host of a key part
+func (h *hostKeys) host(k string) string {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if host, ok := h.hosts[k]; ok {
+		return host
+	}
+
+	return hostFromKey(k)
+}
+
+func (c *keyCache) format(p keyParams) string {
+	var key string
+	switch p.format {
+	case KeyResponse:
+		key = fmt.Sprintf(p.format, p.code, p.s1, p.s2)
+	case KeyServeRoute:
+		key = fmt.Sprintf(p.format, p.s1, p.s2, p.code)
+	case KeyServeHost:
+		key = fmt.Sprintf(p.format, c.hosts.key(p.s1), p.s2, p.code)
+	case KeyProxyBackendHost:
+		key = fmt.Sprintf(p.format, c.hosts.key(p.s1))
+	default:
+		key = fmt.Sprintf(p.format, p.s1)
+	}
+
+	return namespacedKey(p.namespace, key)
+}
+
+func (c *keyCache) get(p keyParams) string {
+	if key, ok := c.keys.Load(p); ok {
+		return key.(string)
+	}
+
+	key := c.format(p)
+	if atomic.LoadInt64(&c.size) >= maxInternedKeys {
+		return key
+	}
+
+	// the size can exceed the limit by the number of the concurrent
+	// stores, but not without bounds
+	if _, loaded := c.keys.LoadOrStore(p, key); !loaded {
+		atomic.AddInt64(&c.size, 1)
+	}
+
+	return key
+}
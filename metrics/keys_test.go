@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestInternedKeys(t *testing.T) {
+	m := New(Options{RouteNamespace: func(routeId string) string {
+		if routeId == "team_a__api" {
+			return "team_a"
+		}
+
+		return ""
+	}})
+
+	defer m.Close()
+
+	for _, test := range []struct {
+		got, expected string
+	}{
+		{m.key(KeyFilterRequest, "setPath", "", 0), "filter.setPath.request"},
+		{m.key(KeyProxyBackendHost, "api.example.org:443", "", 0), "backendhost.api_example_org__443"},
+		{m.key(KeyServeHost, "www.example.org", "GET", 200), "servehost.www_example_org.GET.200"},
+		{m.key(KeySlowClient, "closed", "", 0), "slowclient.closed"},
+		{m.routeMetricKey("api", KeyProxyBackend, "api", "", 0), "backend.api"},
+		{m.routeMetricKey("api", KeyResponse, "GET", "api", 200), "response.200.GET.skipper.api"},
+		{m.routeMetricKey("api", KeyServeRoute, "api", "POST", 404), "serveroute.api.POST.404"},
+		{m.routeMetricKey("team_a__api", KeyErrorsBackend, "team_a__api", "", 0), "namespace.team_a.errors.backend.team_a__api"},
+		{m.routeMetricKey("team_a__api", KeyResponse, "GET", "team_a__api", 200), "namespace.team_a.response.200.GET.skipper.team_a__api"},
+	} {
+		if test.got != test.expected {
+			t.Errorf("expected: %s, got: %s", test.expected, test.got)
+		}
+	}
+
+	// the second lookup returns the same key
+	if k := m.routeMetricKey("team_a__api", KeyResponse, "GET", "team_a__api", 200); k != "namespace.team_a.response.200.GET.skipper.team_a__api" {
+		t.Error("invalid interned key", k)
+	}
+}
+
+func TestKeyCacheLimit(t *testing.T) {
+	var c keyCache
+	for i := 0; i < maxInternedKeys+10; i++ {
+		c.get(keyParams{format: KeyProxyBackend, s1: fmt.Sprint(i)})
+	}
+
+	var n int
+	c.keys.Range(func(interface{}, interface{}) bool {
+		n++
+		return true
+	})
+
+	if n != maxInternedKeys {
+		t.Error("invalid number of keys", n)
+	}
+
+	if k := c.get(keyParams{format: KeyProxyBackend, s1: "foo"}); k != "backend.foo" {
+		t.Error("invalid key beyond the limit", k)
+	}
+}
+
+func TestMeasureWithoutAllocation(t *testing.T) {
+	m := New(Options{EnableServeRouteMetrics: true, EnableServeHostMetrics: true})
+	defer m.Close()
+
+	start := time.Now()
+	allocs := testing.AllocsPerRun(100, func() {
+		m.MeasureResponse(200, "GET", "api", start)
+		m.MeasureServe("api", "www.example.org", "GET", 200, start)
+		m.MeasureBackend("api", start)
+		m.IncErrorsBackend("api")
+	})
+
+	if allocs != 0 {
+		t.Error("measuring allocates", allocs)
+	}
+}
+
+func BenchmarkFormatKey(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = fmt.Sprintf(KeyResponse, 200, "GET", "api")
+	}
+}
+
+func BenchmarkInternedKey(b *testing.B) {
+	m := New(Options{})
+	defer m.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = m.routeMetricKey("api", KeyResponse, "GET", "api", 200)
+	}
+}
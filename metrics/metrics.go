@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/pprof"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rcrowley/go-metrics"
@@ -48,8 +49,100 @@ type Options struct {
 	// EnableProfile exposes profiling information on /pprof of the
 	// metrics listener.
 	EnableProfile bool
+
+	// Additional handlers served by the metrics listener, mapped by
+	// their path patterns, as used by http.ServeMux. It allows
+	// other components to expose their diagnostic endpoints on the
+	// same support listener.
+	SupportHandlers map[string]http.Handler
+
+	// When set, the metrics can be reset with a DELETE request, or a
+	// POST request with reset=true, to /metrics, authorized with
+	// this token as a bearer token.
+	ResetToken string
+
+	// If set, the durations of serving the requests are recorded in
+	// Prometheus histograms, by route, method and status code, and
+	// served on /metrics/prometheus, together with the metrics of the
+	// registry, translated to Prometheus metrics with labels. The
+	// same is served on /metrics, when the client accepts the
+	// Prometheus text format. When the clients accept the OpenMetrics
+	// format, the histogram buckets are served with the trace IDs of
+	// sampled requests as exemplars.
+	EnablePrometheus bool
+
+	// When set, it returns the namespace of a route, and the keys of
+	// the metrics of the routes in a namespace are prefixed with
+	// namespace.<name>., so that they can be queried and exported
+	// separately for each tenant.
+	RouteNamespace func(routeId string) string
+
+	// The registry of the collected metrics. When not set, a new
+	// registry is created. It allows the programs embedding skipper to
+	// export the metrics with the reporters of go-metrics.
+	Registry metrics.Registry
+
+	// The number of the shards of the registry created when Registry
+	// is not set. Defaults to the number of the CPUs, up to 64.
+	RegistryShards int
+
+	// When set, the metrics are pushed to this Graphite server, in
+	// the plaintext protocol. The address can be prefixed with the
+	// network, e.g. udp://graphite.example.org:2003. Default network:
+	// tcp.
+	GraphiteAddr string
+
+	// The prefix of the keys pushed to Graphite. Defaults to Prefix.
+	GraphitePrefix string
+
+	// When set, the metrics are pushed to this StatsD server. The
+	// address can be prefixed with the network, e.g.
+	// tcp://statsd.example.org:8125. Default network: udp.
+	StatsdAddr string
+
+	// The prefix of the keys pushed to StatsD. Defaults to Prefix.
+	StatsdPrefix string
+
+	// The interval of pushing the metrics to Graphite and StatsD.
+	// Default: 10s.
+	ReportInterval time.Duration
+
+	// The sampling of the durations measured by the timers:
+	// UniformSample, ExpDecaySample or HDRSample. Default:
+	// UniformSample.
+	TimerSample TimerSample
+
+	// The number of the durations kept by the uniform and the
+	// exponentially decaying samples. Default: 1024.
+	TimerReservoirSize int
+
+	// The number of the significant decimal digits of the durations
+	// counted by the HDR histograms, from 1 to 5. Default: 2.
+	HistogramPrecision int
+
+	// The highest duration tracked by the HDR histograms. The longer
+	// ones are counted as this. Default: 1m.
+	HistogramMaxValue time.Duration
 }
 
+// TimerSample is the sampling strategy of the timers.
+type TimerSample string
+
+const (
+	// UniformSample keeps a uniformly random sample of all the
+	// measured durations.
+	UniformSample TimerSample = "uniform"
+
+	// ExpDecaySample keeps a sample biased towards the durations
+	// measured in the last five minutes.
+	ExpDecaySample TimerSample = "expdecay"
+
+	// HDRSample counts all the durations in fixed, log-linear
+	// buckets, bounding the relative error of the percentiles by the
+	// precision, including the tail latencies.
+	HDRSample TimerSample = "hdr"
+)
+
 const (
 	KeyRouteLookup      = "routelookup"
 	KeyRouteFailure     = "routefailure"
@@ -66,30 +159,88 @@ const (
 	KeyErrorsBackend   = "errors.backend.%s"
 	KeyErrorsStreaming = "errors.streaming.%s"
 
+	KeySlowClient = "slowclient.%s"
+
+	KeyNamespace            = "namespace.%s."
+	KeyNamespaceRateLimited = "namespace.%s.ratelimited"
+
 	statsRefreshDuration = time.Duration(5 * time.Second)
 
 	defaultReservoirSize = 1024
+
+	// the alpha of the exponentially decaying samples, biasing them
+	// towards the last five minutes, the same as used by go-metrics
+	expDecayAlpha = 0.015
 )
 
-type Metrics struct {
-	reg           metrics.Registry
-	createTimer   func() metrics.Timer
-	createCounter func() metrics.Counter
-	options       Options
+// Metrics is the interface of the metrics collected by the proxy and by
+// the other components. It can be implemented to plug in a custom metrics
+// backend, e.g. by the programs embedding skipper.
+type Metrics interface {
+	MeasureRouteLookup(start time.Time)
+	MeasureFilterRequest(filterName string, start time.Time)
+	MeasureAllFiltersRequest(routeId string, start time.Time)
+	MeasureBackend(routeId string, start time.Time)
+	MeasureBackendHost(routeBackendHost string, start time.Time)
+	MeasureFilterResponse(filterName string, start time.Time)
+	MeasureAllFiltersResponse(routeId string, start time.Time)
+	MeasureResponse(code int, method string, routeId string, start time.Time)
+	MeasureServe(routeId, host, method string, code int, start time.Time)
+	MeasureServeLatency(routeId, method string, code int, start time.Time, traceID string)
+	IncRoutingFailures()
+	IncErrorsBackend(routeId string)
+	IncErrorsStreaming(routeId string)
+
+	// The metrics with custom keys, e.g. the ones of the filters.
+	MeasureSince(key string, start time.Time)
+	IncCounter(key string)
+	IncCounterBy(key string, n int64)
+	UpdateGauge(key string, value float64)
+}
+
+// CodaHale is the default implementation of Metrics, collecting the
+// metrics in a go-metrics registry.
+type CodaHale struct {
+	reg            metrics.Registry
+	createTimer    func() metrics.Timer
+	createCounter  func() metrics.Counter
+	createGauge    func() metrics.GaugeFloat64
+	options        Options
+	serveDurations *histogramSet
+	recorder       *recorder
+	reporters      *reporters
+	closeRecorder  sync.Once
+	keys           keyCache
 }
 
 var (
-	Default *Metrics
-	Void    *Metrics
+	Default Metrics
+	Void    Metrics
 )
 
-func New(o Options) *Metrics {
-	m := &Metrics{}
-	m.reg = metrics.NewRegistry()
-	m.createTimer = createTimer
+func New(o Options) *CodaHale {
+	m := &CodaHale{}
+	m.reg = o.Registry
+	if m.reg == nil {
+		shards := o.RegistryShards
+		if shards <= 0 {
+			shards = shardCount()
+		}
+
+		m.reg = NewShardedRegistry(shards)
+	}
+
+	m.createTimer = timerFactory(o)
 	m.createCounter = metrics.NewCounter
+	m.createGauge = metrics.NewGaugeFloat64
 	m.options = o
 
+	m.recorder = newRecorder(m.updateTimer, m.addCounter)
+
+	if o.EnablePrometheus {
+		m.serveDurations = newHistogramSet(defaultLatencyBuckets)
+	}
+
 	if o.EnableDebugGcMetrics {
 		metrics.RegisterDebugGCStats(m.reg)
 		go metrics.CaptureDebugGCStats(m.reg, statsRefreshDuration)
@@ -103,11 +254,35 @@ func New(o Options) *Metrics {
 	return m
 }
 
-func NewVoid() *Metrics {
-	m := &Metrics{}
+// Flush applies the measurements buffered by the background worker to the
+// registry.
+func (m *CodaHale) Flush() {
+	if m.recorder != nil {
+		m.recorder.flush()
+	}
+}
+
+// Close stops the background worker, after applying the buffered
+// measurements to the registry, and the reporters, after pushing the
+// metrics for the last time.
+func (m *CodaHale) Close() {
+	m.stopReporters()
+	if m.recorder != nil {
+		m.closeRecorder.Do(m.recorder.close)
+	}
+}
+
+// Registry returns the registry of the collected metrics.
+func (m *CodaHale) Registry() metrics.Registry {
+	return m.reg
+}
+
+func NewVoid() *CodaHale {
+	m := &CodaHale{}
 	m.reg = metrics.NewRegistry()
 	m.createTimer = func() metrics.Timer { return metrics.NilTimer{} }
 	m.createCounter = func() metrics.Counter { return metrics.NilCounter{} }
+	m.createGauge = func() metrics.GaugeFloat64 { return metrics.NilGaugeFloat64{} }
 	return m
 }
 
@@ -116,16 +291,34 @@ func init() {
 	Default = Void
 }
 
-// Initializes the collection of metrics.
-func Init(o Options) {
-	if o.Listener == "" {
+// Initializes the collection of metrics. It returns a function that
+// stops serving and pushing the metrics, and stops the collection.
+func Init(o Options) func() {
+	if o.Listener == "" && o.GraphiteAddr == "" && o.StatsdAddr == "" {
 		log.Infoln("Metrics are disabled")
-		return
+		return func() {}
+	}
+
+	m := New(o)
+	stop := InitWith(m, o)
+	return func() {
+		stop()
+		m.Close()
 	}
+}
 
-	Default = New(o)
+// creates the handler of the metrics listener. The metrics are served
+// only from a CodaHale instance, while the support handlers are served
+// with any implementation.
+func newMetricsHandler(mi Metrics, o Options) *metricsHandler {
+	handler := &metricsHandler{options: o}
+	if m, ok := mi.(*CodaHale); ok {
+		handler.registry = m.reg
+		if m.serveDurations != nil {
+			handler.prometheus = http.HandlerFunc(m.servePrometheus)
+		}
+	}
 
-	handler := &metricsHandler{registry: Default.reg, options: o}
 	if o.EnableProfile {
 		mux := http.NewServeMux()
 		mux.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
@@ -136,62 +329,177 @@ func Init(o Options) {
 		handler.profile = mux
 	}
 
-	log.Infof("metrics listener on %s/metrics", o.Listener)
-	go http.ListenAndServe(o.Listener, handler)
+	if len(o.SupportHandlers) > 0 {
+		mux := http.NewServeMux()
+		for p, h := range o.SupportHandlers {
+			mux.Handle(p, h)
+		}
+
+		handler.support = mux
+	}
+
+	return handler
+}
+
+// InitWith initializes the collection of metrics with an instance
+// created by the caller, e.g. a program embedding skipper. When it is a
+// CodaHale instance, and the listener is set in the options, the metrics
+// of the instance are served on it, and when the Graphite or the StatsD
+// address is set, they are pushed there. The metrics of the other
+// implementations are not served, but the support handlers are. It
+// returns a function that closes the listener, stops the reporters and
+// resets the Default.
+func InitWith(mi Metrics, o Options) func() {
+	Default = mi
+	m, ok := mi.(*CodaHale)
+	if ok {
+		m.startReporters(o)
+	} else if o.Listener != "" {
+		log.Infof("metrics of %T are not served on %s", mi, o.Listener)
+	}
+
+	var srv *http.Server
+	if o.Listener != "" {
+		log.Infof("metrics listener on %s/metrics", o.Listener)
+		srv = &http.Server{Addr: o.Listener, Handler: newMetricsHandler(mi, o)}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Errorf("metrics listener failed: %v", err)
+			}
+		}()
+	}
+
+	return func() {
+		if srv != nil {
+			srv.Close()
+		}
+
+		if ok {
+			m.stopReporters()
+		}
+
+		if Default == mi {
+			Default = Void
+		}
+	}
 }
 
 func createTimer() metrics.Timer {
 	return metrics.NewCustomTimer(metrics.NewHistogram(metrics.NewUniformSample(defaultReservoirSize)), metrics.NewMeter())
 }
 
-func (m *Metrics) getTimer(key string) metrics.Timer {
+// returns the function creating the timers with the sampling set in the
+// options
+func timerFactory(o Options) func() metrics.Timer {
+	size := o.TimerReservoirSize
+	if size <= 0 {
+		size = defaultReservoirSize
+	}
+
+	switch o.TimerSample {
+	case "", UniformSample:
+		if size == defaultReservoirSize {
+			return createTimer
+		}
+
+		return func() metrics.Timer {
+			return metrics.NewCustomTimer(metrics.NewHistogram(metrics.NewUniformSample(size)), metrics.NewMeter())
+		}
+	case ExpDecaySample:
+		return func() metrics.Timer {
+			return metrics.NewCustomTimer(metrics.NewHistogram(metrics.NewExpDecaySample(size, expDecayAlpha)), metrics.NewMeter())
+		}
+	case HDRSample:
+		precision := o.HistogramPrecision
+		if precision <= 0 {
+			precision = defaultHistogramPrecision
+		}
+
+		max := o.HistogramMaxValue
+		if max <= 0 {
+			max = defaultHistogramMaxValue
+		}
+
+		return func() metrics.Timer { return newHDRTimer(max, precision) }
+	default:
+		log.Errorf("unknown timer sample: %s, using %s", o.TimerSample, UniformSample)
+		return createTimer
+	}
+}
+
+func (m *CodaHale) getTimer(key string) metrics.Timer {
 	return m.reg.GetOrRegister(key, m.createTimer).(metrics.Timer)
 }
 
-func (m *Metrics) updateTimer(key string, d time.Duration) {
+func (m *CodaHale) updateTimer(key string, d time.Duration) {
 	if t := m.getTimer(key); t != nil {
 		t.Update(d)
 	}
 }
 
-func (m *Metrics) measureSince(key string, start time.Time) {
-	d := time.Since(start)
-	go m.updateTimer(key, d)
+func (m *CodaHale) measureSince(key string, start time.Time) {
+	if m.recorder != nil {
+		m.recorder.recordTimer(key, time.Since(start))
+	}
+}
+
+func (m *CodaHale) namespace(routeId string) string {
+	if m.options.RouteNamespace == nil {
+		return ""
+	}
+
+	return m.options.RouteNamespace(routeId)
+}
+
+// prefixes the key of a route metric with the namespace of the route
+func (m *CodaHale) routeKey(routeId, key string) string {
+	return namespacedKey(m.namespace(routeId), key)
+}
+
+// returns the interned key of a metric
+func (m *CodaHale) key(format, s1, s2 string, code int) string {
+	return m.keys.get(keyParams{format: format, s1: s1, s2: s2, code: code})
 }
 
-func (m *Metrics) MeasureRouteLookup(start time.Time) {
+// returns the interned key of a route metric, prefixed with the namespace
+// of the route
+func (m *CodaHale) routeMetricKey(routeId, format, s1, s2 string, code int) string {
+	return m.keys.get(keyParams{format: format, namespace: m.namespace(routeId), s1: s1, s2: s2, code: code})
+}
+
+func (m *CodaHale) MeasureRouteLookup(start time.Time) {
 	m.measureSince(KeyRouteLookup, start)
 }
 
-func (m *Metrics) MeasureFilterRequest(filterName string, start time.Time) {
-	m.measureSince(fmt.Sprintf(KeyFilterRequest, filterName), start)
+func (m *CodaHale) MeasureFilterRequest(filterName string, start time.Time) {
+	m.measureSince(m.key(KeyFilterRequest, filterName, "", 0), start)
 }
 
-func (m *Metrics) MeasureAllFiltersRequest(routeId string, start time.Time) {
-	m.measureSince(fmt.Sprintf(KeyFiltersRequest, routeId), start)
+func (m *CodaHale) MeasureAllFiltersRequest(routeId string, start time.Time) {
+	m.measureSince(m.routeMetricKey(routeId, KeyFiltersRequest, routeId, "", 0), start)
 }
 
-func (m *Metrics) MeasureBackend(routeId string, start time.Time) {
-	m.measureSince(fmt.Sprintf(KeyProxyBackend, routeId), start)
+func (m *CodaHale) MeasureBackend(routeId string, start time.Time) {
+	m.measureSince(m.routeMetricKey(routeId, KeyProxyBackend, routeId, "", 0), start)
 }
 
-func (m *Metrics) MeasureBackendHost(routeBackendHost string, start time.Time) {
+func (m *CodaHale) MeasureBackendHost(routeBackendHost string, start time.Time) {
 	if m.options.EnableBackendHostMetrics {
-		m.measureSince(fmt.Sprintf(KeyProxyBackendHost, hostForKey(routeBackendHost)), start)
+		m.measureSince(m.key(KeyProxyBackendHost, routeBackendHost, "", 0), start)
 	}
 }
 
-func (m *Metrics) MeasureFilterResponse(filterName string, start time.Time) {
-	m.measureSince(fmt.Sprintf(KeyFilterResponse, filterName), start)
+func (m *CodaHale) MeasureFilterResponse(filterName string, start time.Time) {
+	m.measureSince(m.key(KeyFilterResponse, filterName, "", 0), start)
 }
 
-func (m *Metrics) MeasureAllFiltersResponse(routeId string, start time.Time) {
-	m.measureSince(fmt.Sprintf(KeyFiltersResponse, routeId), start)
+func (m *CodaHale) MeasureAllFiltersResponse(routeId string, start time.Time) {
+	m.measureSince(m.routeMetricKey(routeId, KeyFiltersResponse, routeId, "", 0), start)
 }
 
-func (m *Metrics) MeasureResponse(code int, method string, routeId string, start time.Time) {
+func (m *CodaHale) MeasureResponse(code int, method string, routeId string, start time.Time) {
 	method = measuredMethod(method)
-	m.measureSince(fmt.Sprintf(KeyResponse, code, method, routeId), start)
+	m.measureSince(m.routeMetricKey(routeId, KeyResponse, method, routeId, code), start)
 }
 
 func hostForKey(h string) string {
@@ -216,92 +524,149 @@ func measuredMethod(m string) string {
 	}
 }
 
-func (m *Metrics) MeasureServe(routeId, host, method string, code int, start time.Time) {
+func (m *CodaHale) MeasureServe(routeId, host, method string, code int, start time.Time) {
 	method = measuredMethod(method)
 
 	if m.options.EnableServeRouteMetrics {
-		m.measureSince(fmt.Sprintf(KeyServeRoute, routeId, method, code), start)
+		m.measureSince(m.routeMetricKey(routeId, KeyServeRoute, routeId, method, code), start)
 	}
 
 	if m.options.EnableServeHostMetrics {
-		m.measureSince(fmt.Sprintf(KeyServeHost, hostForKey(host), method, code), start)
+		m.measureSince(m.key(KeyServeHost, host, method, code), start)
 	}
 }
 
-func (m *Metrics) getCounter(key string) metrics.Counter {
+// MeasureSince measures the duration since start, with a custom key.
+func (m *CodaHale) MeasureSince(key string, start time.Time) {
+	m.measureSince(key, start)
+}
+
+// IncCounter increments a counter with a custom key.
+func (m *CodaHale) IncCounter(key string) {
+	m.incCounter(key)
+}
+
+// IncCounterBy increments a counter with a custom key by n.
+func (m *CodaHale) IncCounterBy(key string, n int64) {
+	if m.recorder != nil {
+		m.recorder.recordCounter(key, n)
+	}
+}
+
+// UpdateGauge sets the value of a gauge with a custom key. The gauges are
+// set directly in the registry.
+func (m *CodaHale) UpdateGauge(key string, value float64) {
+	if g, ok := m.reg.GetOrRegister(key, m.createGauge).(metrics.GaugeFloat64); ok {
+		g.Update(value)
+	}
+}
+
+func (m *CodaHale) getCounter(key string) metrics.Counter {
 	return m.reg.GetOrRegister(key, m.createCounter).(metrics.Counter)
 }
 
-func (m *Metrics) incCounter(key string) {
-	go func() {
-		if c := m.getCounter(key); c != nil {
-			c.Inc(1)
-		}
-	}()
+func (m *CodaHale) addCounter(key string, n int64) {
+	if c := m.getCounter(key); c != nil {
+		c.Inc(n)
+	}
+}
+
+func (m *CodaHale) incCounter(key string) {
+	if m.recorder != nil {
+		m.recorder.recordCounter(key, 1)
+	}
 }
 
-func (m *Metrics) IncRoutingFailures() {
+func (m *CodaHale) IncRoutingFailures() {
 	m.incCounter(KeyRouteFailure)
 }
 
-func (m *Metrics) IncErrorsBackend(routeId string) {
-	m.incCounter(fmt.Sprintf(KeyErrorsBackend, routeId))
+func (m *CodaHale) IncErrorsBackend(routeId string) {
+	m.incCounter(m.routeMetricKey(routeId, KeyErrorsBackend, routeId, "", 0))
+}
+
+func (m *CodaHale) IncErrorsStreaming(routeId string) {
+	m.incCounter(m.routeMetricKey(routeId, KeyErrorsStreaming, routeId, "", 0))
+}
+
+// returns the family of a metric in the JSON responses
+func metricFamily(metric interface{}) string {
+	switch metric.(type) {
+	case metrics.Gauge, metrics.GaugeFloat64:
+		return "gauges"
+	case metrics.Histogram:
+		return "histograms"
+	case metrics.Timer:
+		return "timers"
+	case metrics.Counter:
+		return "counters"
+	case metrics.Meter:
+		return "meters"
+	default:
+		return "unknown"
+	}
 }
 
-func (m *Metrics) IncErrorsStreaming(routeId string) {
-	m.incCounter(fmt.Sprintf(KeyErrorsStreaming, routeId))
+// returns the family and the values of a metric in the JSON responses
+func metricValues(metric interface{}) (string, map[string]interface{}) {
+	values := make(map[string]interface{})
+	switch m := metric.(type) {
+	case metrics.Gauge:
+		values["value"] = m.Value()
+	case metrics.GaugeFloat64:
+		values["value"] = m.Value()
+	case metrics.Histogram:
+		h := m.Snapshot()
+		ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+		values["count"] = h.Count()
+		values["min"] = h.Min()
+		values["max"] = h.Max()
+		values["mean"] = h.Mean()
+		values["stddev"] = h.StdDev()
+		values["median"] = ps[0]
+		values["75%"] = ps[1]
+		values["95%"] = ps[2]
+		values["99%"] = ps[3]
+		values["99.9%"] = ps[4]
+	case metrics.Timer:
+		t := m.Snapshot()
+		ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+		values["count"] = t.Count()
+		values["min"] = t.Min()
+		values["max"] = t.Max()
+		values["mean"] = t.Mean()
+		values["stddev"] = t.StdDev()
+		values["median"] = ps[0]
+		values["75%"] = ps[1]
+		values["95%"] = ps[2]
+		values["99%"] = ps[3]
+		values["99.9%"] = ps[4]
+		values["1m.rate"] = t.Rate1()
+		values["5m.rate"] = t.Rate5()
+		values["15m.rate"] = t.Rate15()
+		values["mean.rate"] = t.RateMean()
+	case metrics.Counter:
+		t := m.Snapshot()
+		values["count"] = t.Count()
+	case metrics.Meter:
+		t := m.Snapshot()
+		values["count"] = t.Count()
+		values["1m.rate"] = t.Rate1()
+		values["5m.rate"] = t.Rate5()
+		values["15m.rate"] = t.Rate15()
+		values["mean.rate"] = t.RateMean()
+	default:
+		values["error"] = fmt.Sprintf("unknown metrics type %T", m)
+	}
+
+	return metricFamily(metric), values
 }
 
 // This listener is used to expose the collected metrics.
 func (sm skipperMetrics) MarshalJSON() ([]byte, error) {
 	data := make(map[string]map[string]interface{})
 	for name, metric := range sm {
-		values := make(map[string]interface{})
-		var metricsFamily string
-		switch m := metric.(type) {
-		case metrics.Gauge:
-			metricsFamily = "gauges"
-			values["value"] = m.Value()
-		case metrics.Histogram:
-			metricsFamily = "histograms"
-			h := m.Snapshot()
-			ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
-			values["count"] = h.Count()
-			values["min"] = h.Min()
-			values["max"] = h.Max()
-			values["mean"] = h.Mean()
-			values["stddev"] = h.StdDev()
-			values["median"] = ps[0]
-			values["75%"] = ps[1]
-			values["95%"] = ps[2]
-			values["99%"] = ps[3]
-			values["99.9%"] = ps[4]
-		case metrics.Timer:
-			metricsFamily = "timers"
-			t := m.Snapshot()
-			ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
-			values["count"] = t.Count()
-			values["min"] = t.Min()
-			values["max"] = t.Max()
-			values["mean"] = t.Mean()
-			values["stddev"] = t.StdDev()
-			values["median"] = ps[0]
-			values["75%"] = ps[1]
-			values["95%"] = ps[2]
-			values["99%"] = ps[3]
-			values["99.9%"] = ps[4]
-			values["1m.rate"] = t.Rate1()
-			values["5m.rate"] = t.Rate5()
-			values["15m.rate"] = t.Rate15()
-			values["mean.rate"] = t.RateMean()
-		case metrics.Counter:
-			metricsFamily = "counters"
-			t := m.Snapshot()
-			values["count"] = t.Count()
-		default:
-			metricsFamily = "unknown"
-			values["error"] = fmt.Sprintf("unknown metrics type %T", m)
-		}
+		metricsFamily, values := metricValues(metric)
 		if data[metricsFamily] == nil {
 			data[metricsFamily] = make(map[string]interface{})
 		}
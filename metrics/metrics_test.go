@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -21,14 +23,14 @@ func TestDefaultOptions(t *testing.T) {
 		t.Error("Default Options should not create a registry or enable metrics")
 	}
 
-	timer := Default.getTimer(KeyRouteLookup)
+	timer := Default.(*CodaHale).getTimer(KeyRouteLookup)
 	switch timer.(type) {
 	case metrics.NilTimer:
 	default:
 		t.Errorf("Able to get metric timer for key '%s' while it shouldn't be possible", KeyRouteLookup)
 	}
 
-	counter := Default.getCounter(KeyRouteFailure)
+	counter := Default.(*CodaHale).getCounter(KeyRouteFailure)
 	switch counter.(type) {
 	case metrics.NilCounter:
 	default:
@@ -44,11 +46,11 @@ func TestDefaultOptionsWithListener(t *testing.T) {
 		t.Error("Options containing a listener should create a registry")
 	}
 
-	if Default.reg.Get("debug.GCStats.LastGC") != nil {
+	if Default.(*CodaHale).reg.Get("debug.GCStats.LastGC") != nil {
 		t.Error("Default options should not enable debug gc stats")
 	}
 
-	if Default.reg.Get("runtime.MemStats.Alloc") != nil {
+	if Default.(*CodaHale).reg.Get("runtime.MemStats.Alloc") != nil {
 		t.Error("Default options should not enable runtime stats")
 	}
 }
@@ -57,7 +59,7 @@ func TestDebugGcStats(t *testing.T) {
 	o := Options{Listener: ":0", EnableDebugGcMetrics: true}
 	Init(o)
 
-	if Default.reg.Get("debug.GCStats.LastGC") == nil {
+	if Default.(*CodaHale).reg.Get("debug.GCStats.LastGC") == nil {
 		t.Error("Options enabled debug gc stats but failed to find the key 'debug.GCStats.LastGC'")
 	}
 }
@@ -66,7 +68,7 @@ func TestRuntimeStats(t *testing.T) {
 	o := Options{Listener: ":0", EnableRuntimeMetrics: true}
 	Init(o)
 
-	if Default.reg.Get("runtime.MemStats.Alloc") == nil {
+	if Default.(*CodaHale).reg.Get("runtime.MemStats.Alloc") == nil {
 		t.Error("Options enabled runtime stats but failed to find the key 'runtime.MemStats.Alloc'")
 	}
 }
@@ -75,36 +77,36 @@ func TestMeasurement(t *testing.T) {
 	o := Options{Listener: ":0"}
 	Init(o)
 
-	t1 := Default.getTimer("TestMeasurement1")
+	t1 := Default.(*CodaHale).getTimer("TestMeasurement1")
 	if t1.Count() != 0 && t1.Max() != 0 {
 		t.Error("'TestMeasurement1' metric should only have zeroes")
 	}
 	now := time.Now()
 	time.Sleep(5)
-	Default.measureSince("TestMeasurement1", now)
+	Default.(*CodaHale).measureSince("TestMeasurement1", now)
 
 	time.Sleep(20 * time.Millisecond)
 	if t1.Count() == 0 || t1.Max() == 0 {
 		t.Error("'TestMeasurement1' metric should have some numbers")
 	}
 
-	t2 := Default.getTimer("TestMeasurement2")
+	t2 := Default.(*CodaHale).getTimer("TestMeasurement2")
 	if t2.Count() != 0 && t2.Max() != 0 {
 		t.Error("'TestMeasurement2' metric should only have zeroes")
 	}
 
-	Default.measureSince("TestMeasurement2", now)
+	Default.(*CodaHale).measureSince("TestMeasurement2", now)
 	time.Sleep(20 * time.Millisecond)
 
 	if t2.Count() == 0 || t2.Max() == 0 {
 		t.Error("'TestMeasurement2' metric should have some numbers")
 	}
 
-	c1 := Default.getCounter("TestCounter1")
+	c1 := Default.(*CodaHale).getCounter("TestCounter1")
 	if c1.Count() != 0 {
 		t.Error("'TestCounter1' metric should be zero")
 	}
-	Default.incCounter("TestCounter1")
+	Default.(*CodaHale).incCounter("TestCounter1")
 	time.Sleep(20 * time.Millisecond)
 	if c1.Count() != 1 {
 		t.Errorf("'TestCounter1' metric should be 1. Got %d", c1.Count())
@@ -138,17 +140,58 @@ var proxyMetricsTests = []proxyMetricTest{
 	{fmt.Sprintf(KeyErrorsBackend, "r1"), func() { Default.IncErrorsBackend("r1") }},
 	// T10 - Inc streaming errors
 	{fmt.Sprintf(KeyErrorsStreaming, "r1"), func() { Default.IncErrorsStreaming("r1") }},
+	// T11 - Inc slow client enforcement actions
+	{fmt.Sprintf(KeySlowClient, "headertimeout"), func() { Default.IncCounter(fmt.Sprintf(KeySlowClient, "headertimeout")) }},
 }
 
 func TestProxyMetrics(t *testing.T) {
 	for _, pmt := range proxyMetricsTests {
-		Init(Options{Listener: ":0"})
+		stop := Init(Options{Listener: ":0"})
 		pmt.measureFunc()
-		Default.reg.Each(func(key string, _ interface{}) {
+		m := Default.(*CodaHale)
+		m.Flush()
+
+		var found bool
+		m.reg.Each(func(key string, _ interface{}) {
 			if key != pmt.metricsKey {
 				t.Errorf("Registry contained unexpected metric for key '%s'. Found '%s'", pmt.metricsKey, key)
 			}
+
+			found = true
 		})
+
+		if !found {
+			t.Errorf("Registry is missing the metric for key '%s'", pmt.metricsKey)
+		}
+
+		stop()
+	}
+}
+
+func TestNamespacedRouteKeys(t *testing.T) {
+	m := New(Options{RouteNamespace: func(routeId string) string {
+		if routeId == "team_a__api" {
+			return "team_a"
+		}
+
+		return ""
+	}})
+
+	for _, test := range []struct {
+		routeId  string
+		key      string
+		expected string
+	}{
+		{"team_a__api", fmt.Sprintf(KeyProxyBackend, "team_a__api"), "namespace.team_a.backend.team_a__api"},
+		{"api", fmt.Sprintf(KeyProxyBackend, "api"), "backend.api"},
+	} {
+		if k := m.routeKey(test.routeId, test.key); k != test.expected {
+			t.Errorf("expected: %s, got: %s", test.expected, k)
+		}
+	}
+
+	if k := New(Options{}).routeKey("team_a__api", "backend.team_a__api"); k != "backend.team_a__api" {
+		t.Error("unexpected namespace prefix", k)
 	}
 }
 
@@ -168,6 +211,9 @@ var serializationTests = []serializationTest{
 	{func() metrics.Histogram { return metrics.NewHistogram(nil) }, serializationResult{"histograms": {"test": {"75%": 0.0,
 		"95%": 0.0, "99%": 0.0, "99.9%": 0.0, "count": 0.0, "max": 0.0, "mean": 0.0, "median": 0.0, "min": 0.0,
 		"stddev": 0.0}}}},
+	{metrics.NewGaugeFloat64, serializationResult{"gauges": {"test": {"value": 0.0}}}},
+	{metrics.NewMeter, serializationResult{"meters": {"test": {"15m.rate": 0.0, "1m.rate": 0.0, "5m.rate": 0.0,
+		"count": 0.0, "mean.rate": 0.0}}}},
 	{func() int { return 42 }, serializationResult{"unknown": {"test": {"error": "unknown metrics type int"}}}},
 }
 
@@ -364,7 +410,7 @@ func TestServeMetrics(t *testing.T) {
 		}},
 	}} {
 		t.Run(ti.msg, func(t *testing.T) {
-			checkMetrics := func(m *Metrics, key string, enabled bool, count int64, minDuration time.Duration) (bool, string) {
+			checkMetrics := func(m *CodaHale, key string, enabled bool, count int64, minDuration time.Duration) (bool, string) {
 				v := m.reg.Get(key)
 
 				switch {
@@ -415,3 +461,132 @@ func TestServeMetrics(t *testing.T) {
 		})
 	}
 }
+
+func TestBatchedRecording(t *testing.T) {
+	m := New(Options{})
+	defer m.Close()
+
+	const (
+		goroutines = 8
+		count      = 3 * shardSize
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			for j := 0; j < count; j++ {
+				m.measureSince("TestBatchedTimer", start)
+				m.incCounter("TestBatchedCounter")
+			}
+		}()
+	}
+
+	wg.Wait()
+	m.Flush()
+
+	if c := m.getTimer("TestBatchedTimer").Count(); c != goroutines*count {
+		t.Errorf("invalid timer count: %d, expected: %d", c, goroutines*count)
+	}
+
+	if c := m.getCounter("TestBatchedCounter").Count(); c != goroutines*count {
+		t.Errorf("invalid counter: %d, expected: %d", c, goroutines*count)
+	}
+}
+
+func TestRecordingAfterIdle(t *testing.T) {
+	m := New(Options{})
+	defer m.Close()
+
+	for i := 0; i < 3; i++ {
+		m.incCounter("TestIdleCounter")
+		time.Sleep(12 * time.Millisecond)
+		if c := m.getCounter("TestIdleCounter").Count(); c != int64(i+1) {
+			t.Fatalf("measurement not applied by the worker: %d, expected: %d", c, i+1)
+		}
+	}
+}
+
+func BenchmarkRecordTimer(b *testing.B) {
+	r := newRecorder(func(string, time.Duration) {}, func(string, int64) {})
+	defer r.close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var d time.Duration
+		for pb.Next() {
+			d++
+			r.recordTimer(KeyRouteLookup, d)
+		}
+	})
+}
+
+func TestCustomMetrics(t *testing.T) {
+	var m Metrics = New(Options{})
+	c := m.(*CodaHale)
+	defer c.Close()
+
+	m.MeasureSince("myfilter.lookup", time.Now().Add(-time.Millisecond))
+	m.IncCounter("myfilter.cache_hit")
+	m.IncCounterBy("myfilter.cache_hit", 2)
+	m.UpdateGauge("myfilter.cache_size", 42)
+	c.Flush()
+
+	if timer, ok := c.reg.Get("myfilter.lookup").(metrics.Timer); !ok || timer.Count() != 1 || timer.Min() < int64(time.Millisecond) {
+		t.Error("failed to measure the custom timer")
+	}
+
+	if counter, ok := c.reg.Get("myfilter.cache_hit").(metrics.Counter); !ok || counter.Count() != 3 {
+		t.Error("failed to count the custom counter")
+	}
+
+	if gauge, ok := c.reg.Get("myfilter.cache_size").(metrics.GaugeFloat64); !ok || gauge.Value() != 42 {
+		t.Error("failed to set the custom gauge")
+	}
+}
+
+type countingMetrics struct {
+	Metrics
+	failures int
+}
+
+func (m *countingMetrics) IncRoutingFailures() { m.failures++ }
+
+func TestInitWithCustomMetrics(t *testing.T) {
+	defer func() { Default = Void }()
+
+	m := &countingMetrics{Metrics: Void}
+	InitWith(m, Options{})
+	Default.IncRoutingFailures()
+	if m.failures != 1 {
+		t.Error("failed to set the custom metrics")
+	}
+}
+
+func TestSupportHandlersWithCustomMetrics(t *testing.T) {
+	h := newMetricsHandler(&countingMetrics{Metrics: Void}, Options{
+		SupportHandlers: map[string]http.Handler{
+			"/healthz": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}),
+		},
+	})
+
+	for _, test := range []struct {
+		path   string
+		status int
+	}{
+		{"/healthz", http.StatusNoContent},
+		{"/metrics", http.StatusNotFound},
+		{"/metrics/foo", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.status {
+			t.Errorf("%s: expected status: %d, got: %d", test.path, test.status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// PrometheusPath is the path of the metrics listener where the
+	// Prometheus metrics are served.
+	PrometheusPath = "/metrics/prometheus"
+
+	prometheusServeDuration = "skipper_serve_duration_seconds"
+	prometheusServeHelp     = "Duration of serving the requests, by route, method and status code."
+
+	prometheusTextContentType = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType    = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// the default buckets of the Prometheus client libraries, in seconds
+var defaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// exemplar links an observation to the trace that it was made in
+type exemplar struct {
+	traceID string
+	value   float64
+	time    time.Time
+}
+
+type histogramKey struct {
+	route, method string
+	code          int
+}
+
+// fixed-bucket histogram, keeping the last exemplar of each bucket
+type histogram struct {
+	mx        sync.Mutex
+	counts    []uint64
+	exemplars []*exemplar
+	count     uint64
+	sum       float64
+}
+
+type histogramSet struct {
+	mx         sync.Mutex
+	buckets    []float64
+	histograms map[histogramKey]*histogram
+}
+
+func newHistogramSet(buckets []float64) *histogramSet {
+	return &histogramSet{
+		buckets:    buckets,
+		histograms: make(map[histogramKey]*histogram),
+	}
+}
+
+func (s *histogramSet) get(k histogramKey) *histogram {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	h, ok := s.histograms[k]
+	if !ok {
+		// the last one is the +Inf bucket
+		h = &histogram{
+			counts:    make([]uint64, len(s.buckets)+1),
+			exemplars: make([]*exemplar, len(s.buckets)+1),
+		}
+
+		s.histograms[k] = h
+	}
+
+	return h
+}
+
+func (s *histogramSet) observe(k histogramKey, d time.Duration, traceID string) {
+	v := d.Seconds()
+	i := sort.SearchFloat64s(s.buckets, v)
+	h := s.get(k)
+
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	h.counts[i]++
+	h.count++
+	h.sum += v
+	if traceID != "" {
+		h.exemplars[i] = &exemplar{traceID: traceID, value: v, time: time.Now()}
+	}
+}
+
+func escapeLabel(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, "\n", `\n`, -1)
+	return strings.Replace(v, `"`, `\"`, -1)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// writes the histograms in the Prometheus text format, or in the
+// OpenMetrics format, which supports the exemplars
+func (s *histogramSet) write(w io.Writer, name, help string, openMetrics bool) {
+	s.mx.Lock()
+	keys := make([]histogramKey, 0, len(s.histograms))
+	for k := range s.histograms {
+		keys = append(keys, k)
+	}
+
+	s.mx.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		ki, kj := keys[i], keys[j]
+		if ki.route != kj.route {
+			return ki.route < kj.route
+		}
+
+		if ki.method != kj.method {
+			return ki.method < kj.method
+		}
+
+		return ki.code < kj.code
+	})
+
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for _, k := range keys {
+		labels := fmt.Sprintf(`route="%s",method="%s",code="%d"`, escapeLabel(k.route), k.method, k.code)
+		h := s.get(k)
+
+		h.mx.Lock()
+		var cumulative uint64
+		for i, c := range h.counts {
+			cumulative += c
+			le := "+Inf"
+			if i < len(s.buckets) {
+				le = formatFloat(s.buckets[i])
+			}
+
+			fmt.Fprintf(w, `%s_bucket{%s,le="%s"} %d`, name, labels, le, cumulative)
+			if e := h.exemplars[i]; openMetrics && e != nil {
+				fmt.Fprintf(
+					w,
+					` # {trace_id="%s"} %s %.3f`,
+					e.traceID,
+					formatFloat(e.value),
+					float64(e.time.UnixNano())/float64(time.Second),
+				)
+			}
+
+			fmt.Fprintln(w)
+		}
+
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+		h.mx.Unlock()
+	}
+}
+
+func acceptsOpenMetrics(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+}
+
+// true when the client accepts the OpenMetrics or the Prometheus text
+// format, like the Prometheus scrapers do
+func acceptsPrometheus(r *http.Request) bool {
+	return acceptsOpenMetrics(r) || strings.Contains(r.Header.Get("Accept"), "version=0.0.4")
+}
+
+func (m *CodaHale) servePrometheus(w http.ResponseWriter, r *http.Request) {
+	openMetrics := acceptsOpenMetrics(r)
+	if openMetrics {
+		w.Header().Set("Content-Type", openMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", prometheusTextContentType)
+	}
+
+	m.serveDurations.write(w, prometheusServeDuration, prometheusServeHelp, openMetrics)
+	writeRegistry(w, m.reg, openMetrics, m.keys.hosts.host)
+	if openMetrics {
+		fmt.Fprintln(w, "# EOF")
+	}
+}
+
+// MeasureServeLatency records the duration of serving a request in
+// the Prometheus histograms, when they are enabled. When the trace ID
+// is not empty, it is attached to the observation as an exemplar, so
+// that the slow requests can be looked up in the tracing system.
+func (m *CodaHale) MeasureServeLatency(routeId, method string, code int, start time.Time, traceID string) {
+	if m.serveDurations == nil {
+		return
+	}
+
+	m.serveDurations.observe(histogramKey{routeId, measuredMethod(method), code}, time.Since(start), traceID)
+}
@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestServeLatencyDisabled(t *testing.T) {
+	m := New(Options{})
+	m.MeasureServeLatency("foo", "GET", 200, time.Now(), "")
+	if m.serveDurations != nil {
+		t.Error("unexpected histograms")
+	}
+}
+
+func TestPrometheusHistograms(t *testing.T) {
+	m := New(Options{EnablePrometheus: true})
+	m.MeasureServeLatency("foo", "GET", 200, time.Now(), "")
+	m.MeasureServeLatency("foo", "GET", 200, time.Now().Add(-time.Second), "0000000000000001000000000000000a")
+	m.MeasureServeLatency("bar", "FOO", 500, time.Now().Add(-time.Minute), "")
+
+	mh := &metricsHandler{prometheus: http.HandlerFunc(m.servePrometheus)}
+	for _, test := range []struct {
+		accept      string
+		contentType string
+		exemplar    bool
+	}{
+		{"", "text/plain", false},
+		{"application/openmetrics-text; version=1.0.0", "application/openmetrics-text", true},
+	} {
+		r, _ := http.NewRequest("GET", PrometheusPath, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+
+		w := httptest.NewRecorder()
+		mh.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), test.contentType) {
+			t.Fatal("invalid response", w.Code, w.Header().Get("Content-Type"))
+		}
+
+		body := w.Body.String()
+		for _, expected := range []string{
+			"# TYPE skipper_serve_duration_seconds histogram\n",
+			`skipper_serve_duration_seconds_bucket{route="foo",method="GET",code="200",le="0.005"} 1` + "\n",
+			`skipper_serve_duration_seconds_bucket{route="bar",method="_unknownmethod_",code="500",le="10"} 0` + "\n",
+			`skipper_serve_duration_seconds_bucket{route="bar",method="_unknownmethod_",code="500",le="+Inf"} 1` + "\n",
+			`skipper_serve_duration_seconds_count{route="foo",method="GET",code="200"} 2` + "\n",
+		} {
+			if !strings.Contains(body, expected) {
+				t.Error("missing line", expected)
+			}
+		}
+
+		exemplar := regexp.MustCompile(
+			`skipper_serve_duration_seconds_bucket\{route="foo",method="GET",code="200",le="2.5"\} 2 ` +
+				`# \{trace_id="0000000000000001000000000000000a"\} 1\.\d+ \d+\.\d{3}\n`)
+		if exemplar.MatchString(body) != test.exemplar {
+			t.Error("invalid exemplar", test.accept, body)
+		}
+
+		if strings.HasSuffix(body, "# EOF\n") != test.exemplar {
+			t.Error("invalid end of output")
+		}
+	}
+}
+
+func TestTranslateKeys(t *testing.T) {
+	for _, test := range []struct {
+		key, name, labels string
+	}{
+		{"routelookup", "skipper_route_lookup_duration_seconds", ""},
+		{"routefailure", "skipper_route_failures_total", ""},
+		{"filter.setPath.request", "skipper_filter_request_duration_seconds", `{filter="setPath"}`},
+		{"allfilters.response.api", "skipper_filters_response_duration_seconds", `{route="api"}`},
+		{"backend.api", "skipper_backend_duration_seconds", `{route="api"}`},
+		{"backendhost.api_example_org__443", "skipper_backend_host_duration_seconds", `{host="api.example.org:443"}`},
+		{"response.200.GET.skipper.api", "skipper_response_duration_seconds", `{route="api",method="GET",code="200"}`},
+		{"serveroute.api.POST.404", "skipper_serve_route_duration_seconds", `{route="api",method="POST",code="404"}`},
+		{"servehost.www_example_org.GET.200", "skipper_serve_host_duration_seconds", `{host="www.example.org",method="GET",code="200"}`},
+		{"errors.streaming.api", "skipper_streaming_errors_total", `{route="api"}`},
+		{"slowclient.closed", "skipper_slow_client_total", `{action="closed"}`},
+		{"namespace.team_a.ratelimited", "skipper_namespace_ratelimited_total", `{namespace="team_a"}`},
+		{"namespace.team_a.errors.backend.team_a__api", "skipper_backend_errors_total", `{namespace="team_a",route="team_a__api"}`},
+		{"runtime.MemStats.HeapAlloc", "skipper_runtime_MemStats_HeapAlloc", ""},
+	} {
+		k := translateKey(test.key, hostFromKey)
+		if k.name != test.name || formatLabels(k.labels) != test.labels {
+			t.Errorf("invalid translation of %s: %s%s", test.key, k.name, formatLabels(k.labels))
+		}
+	}
+}
+
+func TestPrometheusRegistry(t *testing.T) {
+	m := New(Options{EnablePrometheus: true})
+	defer m.Close()
+
+	m.MeasureResponse(200, "GET", "api", time.Now().Add(-20*time.Millisecond))
+	m.IncErrorsBackend("api")
+	m.IncErrorsBackend("api")
+	m.Registry().Register("custom.requests", metrics.NewCounter())
+	m.Registry().Register("custom.ratio", metrics.NewGaugeFloat64())
+	m.Registry().Get("custom.ratio").(metrics.GaugeFloat64).Update(0.5)
+	m.Flush()
+
+	mh := &metricsHandler{prometheus: http.HandlerFunc(m.servePrometheus)}
+	for _, test := range []struct {
+		path, accept string
+		counterType  string
+	}{
+		{PrometheusPath, "", "skipper_backend_errors_total"},
+		{"/metrics", "text/plain;version=0.0.4;q=0.5,*/*;q=0.1", "skipper_backend_errors_total"},
+		{"/metrics", "application/openmetrics-text; version=1.0.0", "skipper_backend_errors"},
+	} {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		r.Header.Set("Accept", test.accept)
+		w := httptest.NewRecorder()
+		mh.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatal("invalid status", w.Code)
+		}
+
+		body := w.Body.String()
+		for _, expected := range []string{
+			"# TYPE skipper_response_duration_seconds summary\n",
+			`skipper_response_duration_seconds{route="api",method="GET",code="200",quantile="0.5"} 0.02`,
+			`skipper_response_duration_seconds_count{route="api",method="GET",code="200"} 1` + "\n",
+			"# TYPE " + test.counterType + " counter\n",
+			`skipper_backend_errors_total{route="api"} 2` + "\n",
+			"skipper_custom_requests_total 0\n",
+			"# TYPE skipper_custom_ratio gauge\nskipper_custom_ratio 0.5\n",
+		} {
+			if !strings.Contains(body, expected) {
+				t.Error("missing line", test.path, test.accept, expected)
+			}
+		}
+	}
+
+	// the JSON dump is served on /metrics to the other clients
+	w := httptest.NewRecorder()
+	mh.registry = m.Registry()
+	mh.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "# TYPE") {
+		t.Error("failed to serve the JSON dump", w.Code)
+	}
+}
+
+func TestPrometheusHostLabels(t *testing.T) {
+	m := New(Options{EnablePrometheus: true, EnableBackendHostMetrics: true})
+	defer m.Close()
+
+	for _, host := range []string{"a_b.example.org", "a.b.example.org", "a.b.example.org"} {
+		m.MeasureBackendHost(host, time.Now())
+	}
+
+	m.Flush()
+	w := httptest.NewRecorder()
+	m.servePrometheus(w, httptest.NewRequest("GET", PrometheusPath, nil))
+	body := w.Body.String()
+	for _, expected := range []string{
+		`skipper_backend_host_duration_seconds_count{host="a_b.example.org"} 1` + "\n",
+		`skipper_backend_host_duration_seconds_count{host="a.b.example.org"} 2` + "\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Error("missing line", expected)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+const prometheusNamespace = "skipper_"
+
+// the quantiles of the timers and the histograms of the registry, served
+// as Prometheus summaries
+var prometheusQuantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
+type promLabel struct {
+	name, value string
+}
+
+// the Prometheus metric name and labels that a key of the registry is
+// translated to. Generic is set for the keys that are not known, whose
+// names get the unit suffix of the metric type.
+type promKey struct {
+	name    string
+	labels  []promLabel
+	generic bool
+}
+
+// a metric of the registry, translated to the samples of a Prometheus
+// metric family
+type promSample struct {
+	suffix string
+	labels string
+	value  float64
+}
+
+type promFamily struct {
+	typ     string
+	samples []promSample
+}
+
+// reverses hostForKey, for the hosts that are not known by the key cache.
+// The underscores of the original host names cannot be restored.
+func hostFromKey(h string) string {
+	h = strings.Replace(h, "__", ":", -1)
+	return strings.Replace(h, "_", ".", -1)
+}
+
+func isNameChar(c rune, first bool) bool {
+	return c == '_' || c == ':' ||
+		c >= 'a' && c <= 'z' ||
+		c >= 'A' && c <= 'Z' ||
+		!first && c >= '0' && c <= '9'
+}
+
+func sanitizeName(key string) string {
+	var b strings.Builder
+	for i, c := range key {
+		if isNameChar(c, i == 0) {
+			b.WriteRune(c)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+
+	return b.String()
+}
+
+func label(name, value string) []promLabel {
+	return []promLabel{{name: name, value: value}}
+}
+
+func requestLabels(route, method, code string) []promLabel {
+	return []promLabel{{"route", route}, {"method", method}, {"code", code}}
+}
+
+// translates the dotted keys created from the Key* formats to metric
+// names with labels, e.g. backend.api to skipper_backend_duration_seconds
+// with the label route="api". The host labels are looked up by the host
+// parts of the keys.
+func translateKey(key string, host func(string) string) promKey {
+	parts := strings.Split(key, ".")
+	if len(parts) > 2 && parts[0] == "namespace" {
+		var k promKey
+		if len(parts) == 3 && parts[2] == "ratelimited" {
+			k = promKey{name: "skipper_namespace_ratelimited_total"}
+		} else {
+			k = translateKey(strings.Join(parts[2:], "."), host)
+		}
+
+		k.labels = append(label("namespace", parts[1]), k.labels...)
+		return k
+	}
+
+	switch {
+	case key == KeyRouteLookup:
+		return promKey{name: "skipper_route_lookup_duration_seconds"}
+	case key == KeyRouteFailure:
+		return promKey{name: "skipper_route_failures_total"}
+	case len(parts) == 3 && parts[0] == "filter" && (parts[2] == "request" || parts[2] == "response"):
+		return promKey{name: "skipper_filter_" + parts[2] + "_duration_seconds", labels: label("filter", parts[1])}
+	case len(parts) == 3 && parts[0] == "allfilters" && (parts[1] == "request" || parts[1] == "response"):
+		return promKey{name: "skipper_filters_" + parts[1] + "_duration_seconds", labels: label("route", parts[2])}
+	case len(parts) == 2 && parts[0] == "backend":
+		return promKey{name: "skipper_backend_duration_seconds", labels: label("route", parts[1])}
+	case len(parts) == 2 && parts[0] == "backendhost":
+		return promKey{name: "skipper_backend_host_duration_seconds", labels: label("host", host(parts[1]))}
+	case len(parts) == 5 && parts[0] == "response" && parts[3] == "skipper":
+		return promKey{name: "skipper_response_duration_seconds", labels: requestLabels(parts[4], parts[2], parts[1])}
+	case len(parts) == 4 && parts[0] == "serveroute":
+		return promKey{name: "skipper_serve_route_duration_seconds", labels: requestLabels(parts[1], parts[2], parts[3])}
+	case len(parts) == 4 && parts[0] == "servehost":
+		return promKey{
+			name:   "skipper_serve_host_duration_seconds",
+			labels: []promLabel{{"host", host(parts[1])}, {"method", parts[2]}, {"code", parts[3]}},
+		}
+	case len(parts) == 3 && parts[0] == "errors" && (parts[1] == "backend" || parts[1] == "streaming"):
+		return promKey{name: "skipper_" + parts[1] + "_errors_total", labels: label("route", parts[2])}
+	case len(parts) == 2 && parts[0] == "slowclient":
+		return promKey{name: "skipper_slow_client_total", labels: label("action", parts[1])}
+	default:
+		return promKey{name: prometheusNamespace + sanitizeName(key), generic: true}
+	}
+}
+
+func formatLabels(labels []promLabel, extra ...promLabel) string {
+	labels = append(labels[:len(labels):len(labels)], extra...)
+	if len(labels) == 0 {
+		return ""
+	}
+
+	s := make([]string, len(labels))
+	for i, l := range labels {
+		s[i] = fmt.Sprintf(`%s="%s"`, l.name, escapeLabel(l.value))
+	}
+
+	return "{" + strings.Join(s, ",") + "}"
+}
+
+// the sum is estimated from the mean of the sample, because the timers and
+// the histograms of the registry don't keep the sum of all the values
+func summarySamples(labels []promLabel, ps []float64, mean float64, count int64, scale float64) []promSample {
+	samples := make([]promSample, 0, len(ps)+2)
+	for i, q := range prometheusQuantiles {
+		samples = append(samples, promSample{
+			labels: formatLabels(labels, promLabel{"quantile", formatFloat(q)}),
+			value:  ps[i] * scale,
+		})
+	}
+
+	l := formatLabels(labels)
+	return append(
+		samples,
+		promSample{suffix: "_sum", labels: l, value: mean * float64(count) * scale},
+		promSample{suffix: "_count", labels: l, value: float64(count)},
+	)
+}
+
+// returns the metric type, the name and the samples of a metric of the
+// registry. The timers are served as summaries, in seconds, and the
+// meters as counters.
+func translateMetric(key string, i interface{}, host func(string) string) (typ, name string, samples []promSample) {
+	k := translateKey(key, host)
+	name = k.name
+	l := formatLabels(k.labels)
+	switch m := i.(type) {
+	case metrics.Timer:
+		t := m.Snapshot()
+		if k.generic {
+			name += "_seconds"
+		}
+
+		typ = "summary"
+		samples = summarySamples(k.labels, t.Percentiles(prometheusQuantiles), t.Mean(), t.Count(), 1/float64(time.Second))
+	case metrics.Histogram:
+		h := m.Snapshot()
+		typ = "summary"
+		samples = summarySamples(k.labels, h.Percentiles(prometheusQuantiles), h.Mean(), h.Count(), 1)
+	case metrics.Counter:
+		typ = "counter"
+		samples = []promSample{{labels: l, value: float64(m.Count())}}
+	case metrics.Meter:
+		typ = "counter"
+		samples = []promSample{{labels: l, value: float64(m.Count())}}
+	case metrics.Gauge:
+		typ = "gauge"
+		samples = []promSample{{labels: l, value: float64(m.Value())}}
+	case metrics.GaugeFloat64:
+		typ = "gauge"
+		samples = []promSample{{labels: l, value: m.Value()}}
+	default:
+		return "", "", nil
+	}
+
+	if typ == "counter" && k.generic {
+		name += "_total"
+	}
+
+	return
+}
+
+// writes the metrics of the registry in the Prometheus text format, or in
+// the OpenMetrics format, grouped into metric families by their
+// translated names. The metrics whose type differs from the type of
+// their family are skipped.
+func writeRegistry(w io.Writer, reg metrics.Registry, openMetrics bool, host func(string) string) {
+	byKey := make(map[string]interface{})
+	var keys []string
+	reg.Each(func(key string, m interface{}) {
+		byKey[key] = m
+		keys = append(keys, key)
+	})
+
+	sort.Strings(keys)
+	families := make(map[string]*promFamily)
+	var names []string
+	for _, key := range keys {
+		typ, name, samples := translateMetric(key, byKey[key], host)
+		if typ == "" {
+			continue
+		}
+
+		f, ok := families[name]
+		if !ok {
+			f = &promFamily{typ: typ}
+			families[name] = f
+			names = append(names, name)
+		} else if f.typ != typ {
+			continue
+		}
+
+		f.samples = append(f.samples, samples...)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		f := families[name]
+		typeName := name
+		if openMetrics && f.typ == "counter" {
+			typeName = strings.TrimSuffix(name, "_total")
+		}
+
+		fmt.Fprintf(w, "# TYPE %s %s\n", typeName, f.typ)
+		for _, s := range f.samples {
+			fmt.Fprintf(w, "%s%s%s %s\n", name, s.suffix, s.labels, formatFloat(s.value))
+		}
+	}
+}
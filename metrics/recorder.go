@@ -0,0 +1,237 @@
+package metrics
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	shardSize     = 1 << 12
+	shardMask     = shardSize - 1
+	maxShards     = 64
+	flushInterval = time.Millisecond
+)
+
+type measurementKind int
+
+const (
+	timerMeasurement measurementKind = iota + 1
+	counterMeasurement
+)
+
+type slot struct {
+	// the position the slot can be written at, when equal to it, or
+	// the position + 1, when it holds a measurement
+	seq   uint64
+	kind  measurementKind
+	key   string
+	value int64
+}
+
+// a bounded lock-free queue of measurements, with multiple producers and
+// a single consumer
+type shard struct {
+	tail  uint64
+	_     [56]byte // keeps the tail of the neighbour shards on separate cache lines
+	head  uint64
+	slots []slot
+}
+
+// The recorder buffers the measurements in shards, and a background
+// worker applies them to the registry in batches, so that recording a
+// measurement doesn't need to look up the registry, take locks or start
+// goroutines.
+type recorder struct {
+	shards []*shard
+	mask   uint64
+	idle   uint32
+	wake   chan struct{}
+	quit   chan struct{}
+	done   chan struct{}
+
+	// the consumer side
+	mx       sync.Mutex
+	counters map[string]int64
+	timer    func(string, time.Duration)
+	counter  func(string, int64)
+}
+
+func newShard() *shard {
+	s := &shard{slots: make([]slot, shardSize)}
+	for i := range s.slots {
+		s.slots[i].seq = uint64(i)
+	}
+
+	return s
+}
+
+func (s *shard) push(k measurementKind, key string, value int64) bool {
+	for {
+		pos := atomic.LoadUint64(&s.tail)
+		sl := &s.slots[pos&shardMask]
+		seq := atomic.LoadUint64(&sl.seq)
+		switch {
+		case seq == pos:
+			if atomic.CompareAndSwapUint64(&s.tail, pos, pos+1) {
+				sl.kind, sl.key, sl.value = k, key, value
+				atomic.StoreUint64(&sl.seq, pos+1)
+				return true
+			}
+		case seq < pos:
+			// full
+			return false
+		}
+	}
+}
+
+// called only by the consumer, holding the lock of the recorder
+func (s *shard) drain(f func(measurementKind, string, int64)) {
+	for {
+		sl := &s.slots[s.head&shardMask]
+		if atomic.LoadUint64(&sl.seq) != s.head+1 {
+			return
+		}
+
+		k, key, value := sl.kind, sl.key, sl.value
+		sl.key = ""
+		atomic.StoreUint64(&sl.seq, s.head+shardSize)
+		s.head++
+		f(k, key, value)
+	}
+}
+
+func shardCount() int {
+	n := 1
+	for n < runtime.GOMAXPROCS(0) && n < maxShards {
+		n <<= 1
+	}
+
+	return n
+}
+
+func newRecorder(timer func(string, time.Duration), counter func(string, int64)) *recorder {
+	n := shardCount()
+	r := &recorder{
+		shards:   make([]*shard, n),
+		mask:     uint64(n - 1),
+		idle:     1,
+		wake:     make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+		counters: make(map[string]int64),
+		timer:    timer,
+		counter:  counter,
+	}
+
+	for i := range r.shards {
+		r.shards[i] = newShard()
+	}
+
+	go r.run()
+	return r
+}
+
+// wakes up the worker, when it is waiting for measurements
+func (r *recorder) notify() {
+	if atomic.LoadUint32(&r.idle) == 1 && atomic.CompareAndSwapUint32(&r.idle, 1, 0) {
+		select {
+		case r.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (r *recorder) recordTimer(key string, d time.Duration) {
+	// the low bits of the durations are spread well enough to choose
+	// the shard, without sharing a counter between the goroutines
+	h := uint64(d)
+	h ^= h >> 7
+	h ^= h >> 13
+	if !r.shards[h&r.mask].push(timerMeasurement, key, int64(d)) {
+		// when the buffer is full, the measurement is applied directly
+		r.timer(key, d)
+		return
+	}
+
+	r.notify()
+}
+
+// FNV-1a, without allocating
+func keyHash(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+
+	return h
+}
+
+func (r *recorder) recordCounter(key string, n int64) {
+	// the counters are spread by their keys, without sharing a counter
+	// between the goroutines
+	if !r.shards[keyHash(key)&r.mask].push(counterMeasurement, key, n) {
+		r.counter(key, n)
+		return
+	}
+
+	r.notify()
+}
+
+func (r *recorder) apply(k measurementKind, key string, value int64) {
+	switch k {
+	case timerMeasurement:
+		r.timer(key, time.Duration(value))
+	case counterMeasurement:
+		r.counters[key] += value
+	}
+}
+
+// applies the buffered measurements to the registry, summing up the
+// counters in the batch
+func (r *recorder) flush() {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	for _, s := range r.shards {
+		s.drain(r.apply)
+	}
+
+	for key, n := range r.counters {
+		r.counter(key, n)
+		delete(r.counters, key)
+	}
+}
+
+func (r *recorder) run() {
+	defer close(r.done)
+	for {
+		select {
+		case <-r.wake:
+		case <-r.quit:
+			r.flush()
+			return
+		}
+
+		// collecting a batch
+		select {
+		case <-time.After(flushInterval):
+		case <-r.quit:
+			r.flush()
+			return
+		}
+
+		r.flush()
+		atomic.StoreUint32(&r.idle, 1)
+
+		// the measurements recorded before the worker became idle
+		r.flush()
+	}
+}
+
+func (r *recorder) close() {
+	close(r.quit)
+	<-r.done
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import "github.com/rcrowley/go-metrics"
+
+// fnv-1a, inlined to avoid allocating a hash for every lookup
+const (
+	offset32 = 2166136261
+	prime32  = 16777619
+)
+
+// The sharded registry splits the metrics by the hash of their keys
+// between independent registries, so that looking up and registering
+// different keys rarely contend for the same lock, no matter how many
+// keys there are. The shards are merged when the metrics are listed.
+type shardedRegistry struct {
+	shards []metrics.Registry
+	mask   uint32
+}
+
+// the keys typically differ in their end, e.g. in the route id or the
+// status code, so only the end of the long keys is hashed
+const maxHashedKeyLength = 32
+
+// NewShardedRegistry creates a registry split into n shards. The number
+// of the shards is rounded up to a power of two. When n is less than 2,
+// it returns a standard registry.
+func NewShardedRegistry(n int) metrics.Registry {
+	if n < 2 {
+		return metrics.NewRegistry()
+	}
+
+	size := 1
+	for size < n {
+		size <<= 1
+	}
+
+	r := &shardedRegistry{
+		shards: make([]metrics.Registry, size),
+		mask:   uint32(size - 1),
+	}
+
+	for i := range r.shards {
+		r.shards[i] = metrics.NewRegistry()
+	}
+
+	return r
+}
+
+func (r *shardedRegistry) shard(name string) metrics.Registry {
+	h := uint32(offset32)
+	start := 0
+	if len(name) > maxHashedKeyLength {
+		start = len(name) - maxHashedKeyLength
+	}
+
+	for i := start; i < len(name); i++ {
+		h ^= uint32(name[i])
+		h *= prime32
+	}
+
+	return r.shards[h&r.mask]
+}
+
+func (r *shardedRegistry) Each(f func(string, interface{})) {
+	for _, s := range r.shards {
+		s.Each(f)
+	}
+}
+
+func (r *shardedRegistry) Get(name string) interface{} {
+	return r.shard(name).Get(name)
+}
+
+func (r *shardedRegistry) GetAll() map[string]map[string]interface{} {
+	all := make(map[string]map[string]interface{})
+	for _, s := range r.shards {
+		for name, values := range s.GetAll() {
+			all[name] = values
+		}
+	}
+
+	return all
+}
+
+func (r *shardedRegistry) GetOrRegister(name string, i interface{}) interface{} {
+	return r.shard(name).GetOrRegister(name, i)
+}
+
+func (r *shardedRegistry) Register(name string, i interface{}) error {
+	return r.shard(name).Register(name, i)
+}
+
+func (r *shardedRegistry) RunHealthchecks() {
+	for _, s := range r.shards {
+		s.RunHealthchecks()
+	}
+}
+
+func (r *shardedRegistry) Unregister(name string) {
+	r.shard(name).Unregister(name)
+}
+
+func (r *shardedRegistry) UnregisterAll() {
+	for _, s := range r.shards {
+		s.UnregisterAll()
+	}
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestShardedRegistry(t *testing.T) {
+	r := NewShardedRegistry(5)
+	if len(r.(*shardedRegistry).shards) != 8 {
+		t.Error("invalid number of shards", len(r.(*shardedRegistry).shards))
+	}
+
+	for i := 0; i < 100; i++ {
+		r.GetOrRegister(fmt.Sprintf("counter%d", i), metrics.NewCounter).(metrics.Counter).Inc(int64(i))
+	}
+
+	c := r.GetOrRegister("counter42", metrics.NewCounter).(metrics.Counter)
+	if c.Count() != 42 || r.Get("counter42") != c {
+		t.Error("failed to get the registered counter")
+	}
+
+	if err := r.Register("counter42", metrics.NewCounter()); err == nil {
+		t.Error("failed to fail on a duplicate metric")
+	}
+
+	var count int
+	r.Each(func(string, interface{}) { count++ })
+	if count != 100 || len(r.GetAll()) != 100 {
+		t.Error("failed to merge the shards", count, len(r.GetAll()))
+	}
+
+	r.Unregister("counter42")
+	if r.Get("counter42") != nil {
+		t.Error("failed to unregister")
+	}
+
+	r.UnregisterAll()
+	if len(r.GetAll()) != 0 {
+		t.Error("failed to unregister all")
+	}
+}
+
+func TestSingleShardRegistry(t *testing.T) {
+	if _, ok := NewShardedRegistry(1).(*metrics.StandardRegistry); !ok {
+		t.Error("failed to create a standard registry")
+	}
+}
+
+func TestDefaultShardedRegistry(t *testing.T) {
+	m := New(Options{RegistryShards: 3})
+	defer m.Close()
+
+	if r, ok := m.Registry().(*shardedRegistry); !ok || len(r.shards) != 4 {
+		t.Error("failed to create the sharded registry")
+	}
+}
+
+func benchmarkRegistry(b *testing.B, r metrics.Registry) {
+	const keyCount = 1 << 14
+	keys := make([]string, keyCount)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("backend.route%d", i)
+	}
+
+	var next uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := atomic.AddUint64(&next, 1)
+			r.GetOrRegister(keys[i%keyCount], metrics.NewCounter).(metrics.Counter).Inc(1)
+		}
+	})
+}
+
+func BenchmarkStandardRegistry(b *testing.B) {
+	benchmarkRegistry(b, metrics.NewRegistry())
+}
+
+func BenchmarkShardedRegistry(b *testing.B) {
+	benchmarkRegistry(b, NewShardedRegistry(shardCount()))
+}
@@ -0,0 +1,377 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultReportInterval is the default interval of pushing the metrics to
+// Graphite and StatsD.
+const DefaultReportInterval = 10 * time.Second
+
+const (
+	reporterDialTimeout  = 3 * time.Second
+	reporterWriteTimeout = 3 * time.Second
+
+	// the maximum size of the UDP datagrams, fitting in the MTU of
+	// the common networks
+	maxDatagram = 1432
+)
+
+// the percentiles of the timers and the histograms, and the suffixes of
+// their keys, when pushed
+var (
+	reportedPercentiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+	percentileSuffixes  = []string{"p50", "p75", "p95", "p99", "p999"}
+)
+
+// formats the metrics of the registry in the protocol of a reporter. The
+// counters map holds the counts of the counters at the previous flush,
+// for the protocols that expect the changes.
+type reportFormat func(b *bytes.Buffer, prefix string, key string, m interface{}, now time.Time, counters map[string]int64)
+
+// The reporter periodically pushes the metrics of the registry to a
+// Graphite or a StatsD server. When the connection fails, it is
+// established again on the next flush.
+type reporter struct {
+	name     string
+	network  string
+	address  string
+	prefix   string
+	format   reportFormat
+	maxWrite int
+
+	conn     net.Conn
+	failing  bool
+	counters map[string]int64
+}
+
+// the reporters running in the background, stopped on Close
+type reporters struct {
+	quit chan struct{}
+	done sync.WaitGroup
+}
+
+// parses a reporter address, optionally prefixed with the network, e.g.
+// tcp://graphite.example.org:2003
+func reporterAddress(address, defaultNetwork string) (network, hostPort string, err error) {
+	network, hostPort = defaultNetwork, address
+	if i := strings.Index(address, "://"); i >= 0 {
+		network, hostPort = address[:i], address[i+3:]
+	}
+
+	if network != "tcp" && network != "udp" {
+		return "", "", fmt.Errorf("unsupported network of the metrics reporter: %s", network)
+	}
+
+	if _, _, err := net.SplitHostPort(hostPort); err != nil {
+		return "", "", err
+	}
+
+	return network, hostPort, nil
+}
+
+func reporterPrefix(prefix, defaultPrefix string) string {
+	if prefix == "" {
+		prefix = defaultPrefix
+	}
+
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+
+	return prefix
+}
+
+func newReporter(name, address, defaultNetwork, prefix string, format reportFormat) (*reporter, error) {
+	network, hostPort, err := reporterAddress(address, defaultNetwork)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reporter{
+		name:     name,
+		network:  network,
+		address:  hostPort,
+		prefix:   prefix,
+		format:   format,
+		counters: make(map[string]int64),
+	}
+
+	if network == "udp" {
+		r.maxWrite = maxDatagram
+	}
+
+	return r, nil
+}
+
+func formatValue(v float64) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.3f", v), ".000")
+}
+
+// the durations are pushed in milliseconds
+func durationValue(ns float64) string {
+	return formatValue(ns / float64(time.Millisecond))
+}
+
+func counterDelta(counters map[string]int64, key string, count int64) int64 {
+	d := count - counters[key]
+	counters[key] = count
+	if d < 0 {
+		// the counter was reset
+		return count
+	}
+
+	return d
+}
+
+// writes the metrics in the Graphite plaintext protocol:
+//
+//	<prefix><key>.<field> <value> <unix timestamp>
+func graphiteFormat(b *bytes.Buffer, prefix string, key string, i interface{}, now time.Time, _ map[string]int64) {
+	ts := now.Unix()
+	line := func(field, value string) {
+		fmt.Fprintf(b, "%s%s.%s %s %d\n", prefix, key, field, value, ts)
+	}
+
+	switch m := i.(type) {
+	case metrics.Timer:
+		t := m.Snapshot()
+		line("count", fmt.Sprint(t.Count()))
+		line("min", durationValue(float64(t.Min())))
+		line("max", durationValue(float64(t.Max())))
+		line("mean", durationValue(t.Mean()))
+		for i, p := range t.Percentiles(reportedPercentiles) {
+			line(percentileSuffixes[i], durationValue(p))
+		}
+
+		line("m1_rate", formatValue(t.Rate1()))
+	case metrics.Histogram:
+		h := m.Snapshot()
+		line("count", fmt.Sprint(h.Count()))
+		line("min", fmt.Sprint(h.Min()))
+		line("max", fmt.Sprint(h.Max()))
+		line("mean", formatValue(h.Mean()))
+		for i, p := range h.Percentiles(reportedPercentiles) {
+			line(percentileSuffixes[i], formatValue(p))
+		}
+	case metrics.Counter:
+		line("count", fmt.Sprint(m.Count()))
+	case metrics.Meter:
+		s := m.Snapshot()
+		line("count", fmt.Sprint(s.Count()))
+		line("m1_rate", formatValue(s.Rate1()))
+	case metrics.Gauge:
+		line("value", fmt.Sprint(m.Value()))
+	case metrics.GaugeFloat64:
+		line("value", formatValue(m.Value()))
+	}
+}
+
+// writes the metrics in the StatsD protocol. The counters are pushed as
+// the change since the previous flush, and the aggregated timers and
+// histograms as gauges, with the count as a counter.
+func statsdFormat(b *bytes.Buffer, prefix string, key string, i interface{}, _ time.Time, counters map[string]int64) {
+	line := func(field, value, typ string) {
+		if field != "" {
+			field = "." + field
+		}
+
+		fmt.Fprintf(b, "%s%s%s:%s|%s\n", prefix, key, field, value, typ)
+	}
+
+	switch m := i.(type) {
+	case metrics.Timer:
+		t := m.Snapshot()
+		line("count", fmt.Sprint(counterDelta(counters, key, t.Count())), "c")
+		line("mean", durationValue(t.Mean()), "g")
+		for i, p := range t.Percentiles(reportedPercentiles) {
+			line(percentileSuffixes[i], durationValue(p), "g")
+		}
+	case metrics.Histogram:
+		h := m.Snapshot()
+		line("count", fmt.Sprint(counterDelta(counters, key, h.Count())), "c")
+		line("mean", formatValue(h.Mean()), "g")
+		for i, p := range h.Percentiles(reportedPercentiles) {
+			line(percentileSuffixes[i], formatValue(p), "g")
+		}
+	case metrics.Counter:
+		line("", fmt.Sprint(counterDelta(counters, key, m.Count())), "c")
+	case metrics.Meter:
+		line("", fmt.Sprint(counterDelta(counters, key, m.Count())), "c")
+	case metrics.Gauge:
+		line("", fmt.Sprint(m.Value()), "g")
+	case metrics.GaugeFloat64:
+		line("", formatValue(m.Value()), "g")
+	}
+}
+
+func (r *reporter) closeConn() {
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+}
+
+func (r *reporter) write(p []byte) error {
+	if r.conn == nil {
+		conn, err := net.DialTimeout(r.network, r.address, reporterDialTimeout)
+		if err != nil {
+			return err
+		}
+
+		r.conn = conn
+	}
+
+	r.conn.SetWriteDeadline(time.Now().Add(reporterWriteTimeout))
+	if _, err := r.conn.Write(p); err != nil {
+		r.closeConn()
+		return err
+	}
+
+	return nil
+}
+
+// writes the buffered lines, split to datagrams of the maximum size for
+// UDP
+func (r *reporter) send(b []byte) error {
+	if r.maxWrite <= 0 {
+		return r.write(b)
+	}
+
+	for len(b) > 0 {
+		n := len(b)
+		if n > r.maxWrite {
+			n = bytes.LastIndexByte(b[:r.maxWrite], '\n') + 1
+			if n <= 0 {
+				// a single line longer than the datagram
+				n = bytes.IndexByte(b, '\n') + 1
+			}
+		}
+
+		if err := r.write(b[:n]); err != nil {
+			return err
+		}
+
+		b = b[n:]
+	}
+
+	return nil
+}
+
+func (r *reporter) report(reg metrics.Registry, now time.Time) {
+	byKey := make(map[string]interface{})
+	var keys []string
+	reg.Each(func(key string, m interface{}) {
+		byKey[key] = m
+		keys = append(keys, key)
+	})
+
+	sort.Strings(keys)
+	var b bytes.Buffer
+	for _, key := range keys {
+		r.format(&b, r.prefix, key, byKey[key], now, r.counters)
+	}
+
+	if b.Len() == 0 {
+		return
+	}
+
+	err := r.send(b.Bytes())
+	switch {
+	case err != nil && !r.failing:
+		log.Errorf("failed to push the metrics to %s at %s: %v", r.name, r.address, err)
+		r.failing = true
+	case err == nil && r.failing:
+		log.Infof("pushing the metrics to %s at %s again", r.name, r.address)
+		r.failing = false
+	}
+}
+
+// creates the reporters configured in the options
+func newReporters(o Options) ([]*reporter, error) {
+	var rs []*reporter
+	if o.GraphiteAddr != "" {
+		r, err := newReporter("Graphite", o.GraphiteAddr, "tcp", reporterPrefix(o.GraphitePrefix, o.Prefix), graphiteFormat)
+		if err != nil {
+			return nil, err
+		}
+
+		rs = append(rs, r)
+	}
+
+	if o.StatsdAddr != "" {
+		r, err := newReporter("StatsD", o.StatsdAddr, "udp", reporterPrefix(o.StatsdPrefix, o.Prefix), statsdFormat)
+		if err != nil {
+			return nil, err
+		}
+
+		rs = append(rs, r)
+	}
+
+	return rs, nil
+}
+
+// starts pushing the metrics with the reporters configured in the
+// options, until the metrics are closed
+func (m *CodaHale) startReporters(o Options) {
+	rs, err := newReporters(o)
+	if err != nil {
+		log.Errorf("failed to create the metrics reporters: %v", err)
+		return
+	}
+
+	if len(rs) == 0 {
+		return
+	}
+
+	interval := o.ReportInterval
+	if interval <= 0 {
+		interval = DefaultReportInterval
+	}
+
+	m.reporters = &reporters{quit: make(chan struct{})}
+	for _, r := range rs {
+		m.reporters.done.Add(1)
+		go func(r *reporter) {
+			defer m.reporters.done.Done()
+			defer r.closeConn()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case now := <-ticker.C:
+					m.Flush()
+					r.report(m.reg, now)
+				case <-m.reporters.quit:
+					m.Flush()
+					r.report(m.reg, time.Now())
+					return
+				}
+			}
+		}(r)
+
+		log.Infof("pushing the metrics to %s at %s every %v", r.name, r.address, interval)
+	}
+}
+
+// stops the reporters, after pushing the metrics for the last time
+func (m *CodaHale) stopReporters() {
+	if m.reporters != nil {
+		m.reporters.close()
+		m.reporters = nil
+	}
+}
+
+func (rs *reporters) close() {
+	close(rs.quit)
+	rs.done.Wait()
+}
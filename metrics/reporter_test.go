@@ -0,0 +1,213 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestReporterAddress(t *testing.T) {
+	for _, test := range []struct {
+		address, network, hostPort string
+		fail                       bool
+	}{
+		{"graphite.example.org:2003", "tcp", "graphite.example.org:2003", false},
+		{"udp://graphite.example.org:2003", "udp", "graphite.example.org:2003", false},
+		{"http://graphite.example.org:2003", "", "", true},
+		{"graphite.example.org", "", "", true},
+	} {
+		network, hostPort, err := reporterAddress(test.address, "tcp")
+		if (err != nil) != test.fail || network != test.network || hostPort != test.hostPort {
+			t.Error("invalid address", test.address, network, hostPort, err)
+		}
+	}
+}
+
+func testRegistry() metrics.Registry {
+	reg := metrics.NewRegistry()
+	timer := createTimer()
+	timer.Update(20 * time.Millisecond)
+	reg.Register("backend.api", timer)
+	c := metrics.NewCounter()
+	c.Inc(3)
+	reg.Register("errors.backend.api", c)
+	return reg
+}
+
+func TestGraphiteReporter(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := make(chan string, 64)
+	accept := func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+		s := bufio.NewScanner(conn)
+		for s.Scan() {
+			lines <- s.Text()
+		}
+	}
+
+	defer l.Close()
+	go accept()
+	r, err := newReporter("Graphite", l.Addr().String(), "tcp", reporterPrefix("", "skipper"), graphiteFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.closeConn()
+	reg := testRegistry()
+	r.report(reg, time.Unix(1600000000, 0))
+
+	expect := func(expected ...string) {
+		got := make(map[string]bool)
+		for _, e := range expected {
+			for !got[e] {
+				select {
+				case line := <-lines:
+					got[line] = true
+				case <-time.After(time.Second):
+					t.Fatal("missing line", e, got)
+				}
+			}
+		}
+	}
+
+	expect(
+		"skipper.backend.api.count 1 1600000000",
+		"skipper.backend.api.p99 20 1600000000",
+		"skipper.errors.backend.api.count 3 1600000000",
+	)
+
+	// the connection is established again, after it failed
+	go accept()
+	r.conn.Close()
+	r.report(reg, time.Unix(1600000010, 0))
+	if !r.failing || r.conn != nil {
+		t.Fatal("failed to detect the failed connection")
+	}
+
+	r.report(reg, time.Unix(1600000020, 0))
+	if r.failing {
+		t.Error("failed to reconnect")
+	}
+
+	expect("skipper.errors.backend.api.count 3 1600000020")
+}
+
+func TestStatsdReporter(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+	r, err := newReporter("StatsD", conn.LocalAddr().String(), "udp", reporterPrefix("custom.", "skipper."), statsdFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.closeConn()
+	reg := testRegistry()
+	receive := func() string {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		p := make([]byte, maxDatagram)
+		n, _, err := conn.ReadFrom(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(p[:n])
+	}
+
+	r.report(reg, time.Now())
+	packet := receive()
+	for _, expected := range []string{
+		"custom.backend.api.count:1|c\n",
+		"custom.backend.api.p50:20|g\n",
+		"custom.errors.backend.api:3|c\n",
+	} {
+		if !strings.Contains(packet, expected) {
+			t.Error("missing line", expected, packet)
+		}
+	}
+
+	// only the changes of the counters are pushed
+	reg.Get("errors.backend.api").(metrics.Counter).Inc(2)
+	r.report(reg, time.Now())
+	if packet := receive(); !strings.Contains(packet, "custom.errors.backend.api:2|c\n") || !strings.Contains(packet, "custom.backend.api.count:0|c\n") {
+		t.Error("invalid changes", packet)
+	}
+}
+
+func TestSplitDatagrams(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+	r, err := newReporter("StatsD", conn.LocalAddr().String(), "udp", "", statsdFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.closeConn()
+	line := []byte(strings.Repeat("x", 99) + "\n")
+	if err := r.send(bytes.Repeat(line, 30)); err != nil {
+		t.Fatal(err)
+	}
+
+	var total int
+	for total < 3000 {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		p := make([]byte, 2*maxDatagram)
+		n, _, err := conn.ReadFrom(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n > maxDatagram || n%100 != 0 {
+			t.Fatal("invalid datagram size", n)
+		}
+
+		total += n
+	}
+}
+
+func TestReportOnClose(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+	m := New(Options{StatsdAddr: conn.LocalAddr().String(), ReportInterval: time.Hour})
+	InitWith(m, Options{StatsdAddr: conn.LocalAddr().String(), ReportInterval: time.Hour})
+	defer func() { Default = Void }()
+
+	m.IncErrorsBackend("api")
+	m.Close()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	p := make([]byte, maxDatagram)
+	n, _, err := conn.ReadFrom(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if packet := string(p[:n]); packet != "errors.backend.api:1|c\n" {
+		t.Error("invalid packet", packet)
+	}
+}
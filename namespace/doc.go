@@ -0,0 +1,50 @@
+/*
+Package namespace implements the namespaces of the routes, so that a
+shared fleet of proxies can serve the routes of multiple tenants, e.g.
+teams or organizations.
+
+A route belongs to a namespace, when its id starts with the name of the
+namespace, followed by a double underscore, e.g. team_a__api belongs to
+the namespace team_a. The names of the namespaces can contain letters,
+digits and single underscores.
+
+The namespaces scope:
+
+    - the admin API: the tokens of a namespace grant access only to the
+      routes of the namespace, and only on the /routes and the
+      /overrides paths,
+    - the routes set with the tokens of a namespace: they must match the
+      policy of the namespace, which lists the allowed hosts, custom
+      predicates, filters and backends. Every route needs at least one
+      Host predicate matching exactly one allowed host, so that a tenant
+      cannot take over the traffic of another tenant. A namespace
+      without a policy cannot set routes,
+    - the metrics: the keys of the route metrics are prefixed with
+      namespace.<name>., so that the metrics of a namespace can be
+      queried on the /metrics/namespace.<name>. path,
+    - the rate limits: the requests of the routes in a namespace are
+      limited to a budget per second, and the further requests are
+      responded with 429 and a Retry-After header. The limit is applied
+      by the proxy, on the route that it matched, including the
+      priority routes and the routes matched after a loopback.
+
+Example:
+
+    skipper -namespaces team_a,team_b \
+        -namespace-tokens team_a=file:/etc/skipper/team-a-tokens,team_b=env:TEAM_B_TOKENS \
+        -namespace-rate-limits team_a=1000,team_b=200 \
+        -namespace-policies /etc/skipper/namespace-policies.yaml \
+        -admin-address localhost:9922 -admin-tokens file:/etc/skipper/admin-tokens
+
+The policies file maps the namespace names to their policies:
+
+    team_a:
+      hosts: ["*.team-a.example.org", "team-a.example.org"]
+      predicates: [Traffic]
+      filters: [setRequestHeader, setPath]
+      backends: ["*.team-a.svc.cluster.local", "<loopback>"]
+
+The routes without a namespace, and the routes of the other data clients,
+are not affected.
+*/
+package namespace
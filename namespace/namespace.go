@@ -0,0 +1,196 @@
+package namespace
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/secrets"
+)
+
+// Separator separates the namespace from the rest of the route id, e.g.
+// team_a__api is the route api in the namespace team_a.
+const Separator = "__"
+
+var namePattern = regexp.MustCompile("^[A-Za-z0-9]+(_[A-Za-z0-9]+)*$")
+
+// Options for the namespaces.
+type Options struct {
+
+	// The names of the namespaces. They can contain letters, digits and
+	// single underscores.
+	Names []string
+
+	// The sources of the admin API tokens of the namespaces, mapped by
+	// the namespace names. The requests with these tokens can access
+	// only the routes of their namespace.
+	Tokens map[string]secrets.Source
+
+	// The maximum number of requests per second served by the routes
+	// of a namespace, mapped by the namespace names. The further
+	// requests are responded with 429 by the proxy. When not set for
+	// a namespace, its requests are not limited. See Allow.
+	RateLimits map[string]int
+
+	// The policies of the routes set with the tokens of the
+	// namespaces, mapped by the namespace names. A namespace without a
+	// policy cannot set routes on the admin API.
+	Policies map[string]Policy
+}
+
+// a token bucket, filled with the rate limit per second, holding at
+// most one second worth of requests
+type limiter struct {
+	mx     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+
+	// the key of the counter of the rejected requests
+	metricKey string
+}
+
+// Namespaces scopes the routes, the route metrics, the rate limits and
+// the admin API access by tenants.
+type Namespaces struct {
+	names    []string
+	known    map[string]bool
+	tokens   map[string]secrets.Source
+	limiters map[string]*limiter
+	policies map[string]Policy
+}
+
+func newLimiter(name string, rate int, now time.Time) *limiter {
+	return &limiter{
+		rate:      float64(rate),
+		tokens:    float64(rate),
+		last:      now,
+		metricKey: fmt.Sprintf(metrics.KeyNamespaceRateLimited, name),
+	}
+}
+
+func (l *limiter) allow(now time.Time) bool {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+
+	l.tokens--
+	return true
+}
+
+// New creates the namespaces. It fails when a name is invalid, or when
+// the tokens, the rate limits or the policies reference an unknown namespace.
+func New(o Options) (*Namespaces, error) {
+	n := &Namespaces{
+		known:    make(map[string]bool),
+		tokens:   make(map[string]secrets.Source),
+		limiters: make(map[string]*limiter),
+		policies: make(map[string]Policy),
+	}
+
+	for _, name := range o.Names {
+		if !namePattern.MatchString(name) {
+			return nil, fmt.Errorf("namespace: invalid name: '%s'", name)
+		}
+
+		if n.known[name] {
+			return nil, fmt.Errorf("namespace: duplicate name: '%s'", name)
+		}
+
+		n.known[name] = true
+		n.names = append(n.names, name)
+	}
+
+	sort.Strings(n.names)
+
+	for name, s := range o.Tokens {
+		if !n.known[name] {
+			return nil, fmt.Errorf("namespace: tokens of unknown namespace: '%s'", name)
+		}
+
+		n.tokens[name] = s
+	}
+
+	now := time.Now()
+	for name, rate := range o.RateLimits {
+		if !n.known[name] {
+			return nil, fmt.Errorf("namespace: rate limit of unknown namespace: '%s'", name)
+		}
+
+		if rate <= 0 {
+			return nil, fmt.Errorf("namespace: invalid rate limit of '%s': %d", name, rate)
+		}
+
+		n.limiters[name] = newLimiter(name, rate, now)
+	}
+
+	for name, p := range o.Policies {
+		if !n.known[name] {
+			return nil, fmt.Errorf("namespace: policy of unknown namespace: '%s'", name)
+		}
+
+		n.policies[name] = p
+	}
+
+	return n, nil
+}
+
+// Names returns the names of the namespaces, sorted.
+func (n *Namespaces) Names() []string {
+	return n.names
+}
+
+// Of returns the namespace of a route id, or an empty string, when the
+// route doesn't belong to any of the namespaces. It can be called on a
+// nil *Namespaces.
+func (n *Namespaces) Of(routeID string) string {
+	if n == nil {
+		return ""
+	}
+
+	i := strings.Index(routeID, Separator)
+	if i <= 0 || !n.known[routeID[:i]] {
+		return ""
+	}
+
+	return routeID[:i]
+}
+
+// Tokens returns the source of the admin API tokens of the namespaces,
+// mapped by the namespace names.
+func (n *Namespaces) Tokens() map[string]secrets.Source {
+	return n.tokens
+}
+
+// Allow applies the rate limit of the namespace of a route matched by
+// the proxy, and returns false, when the request exceeds it. It is set
+// as the RateLimit of the proxy parameters, so that the limit is applied
+// on the same route that serves the request.
+func (n *Namespaces) Allow(r *routing.Route) bool {
+	l, ok := n.limiters[n.Of(r.Id)]
+	if !ok || l.allow(time.Now()) {
+		return true
+	}
+
+	metrics.Default.IncCounter(l.metricKey)
+	return false
+}
+
+// HasRateLimits tells whether any of the namespaces has a rate limit.
+func (n *Namespaces) HasRateLimits() bool {
+	return len(n.limiters) > 0
+}
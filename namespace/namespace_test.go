@@ -0,0 +1,152 @@
+package namespace
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/secrets"
+)
+
+type tokenSource []string
+
+func (s tokenSource) Keys() ([][]byte, error) {
+	var k [][]byte
+	for _, t := range s {
+		k = append(k, []byte(t))
+	}
+
+	return k, nil
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for _, test := range []struct {
+		title   string
+		options Options
+	}{{
+		title:   "invalid name",
+		options: Options{Names: []string{"team-a"}},
+	}, {
+		title:   "double underscore",
+		options: Options{Names: []string{"team__a"}},
+	}, {
+		title:   "duplicate name",
+		options: Options{Names: []string{"team_a", "team_a"}},
+	}, {
+		title: "tokens of unknown namespace",
+		options: Options{
+			Names:  []string{"team_a"},
+			Tokens: map[string]secrets.Source{"team_b": tokenSource{"foo"}},
+		},
+	}, {
+		title: "rate limit of unknown namespace",
+		options: Options{
+			Names:      []string{"team_a"},
+			RateLimits: map[string]int{"team_b": 10},
+		},
+	}, {
+		title: "invalid rate limit",
+		options: Options{
+			Names:      []string{"team_a"},
+			RateLimits: map[string]int{"team_a": 0},
+		},
+	}, {
+		title: "policy of unknown namespace",
+		options: Options{
+			Names:    []string{"team_a"},
+			Policies: map[string]Policy{"team_b": {}},
+		},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			if _, err := New(test.options); err == nil {
+				t.Error("failed to fail")
+			}
+		})
+	}
+}
+
+func TestOf(t *testing.T) {
+	n, err := New(Options{Names: []string{"team_b", "team_a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := strings.Join(n.Names(), ","); names != "team_a,team_b" {
+		t.Error("invalid names", names)
+	}
+
+	for id, expected := range map[string]string{
+		"team_a__api":  "team_a",
+		"team_b__api":  "team_b",
+		"team_a___api": "team_a",
+		"team_c__api":  "",
+		"team_a_api":   "",
+		"__api":        "",
+		"api":          "",
+	} {
+		if ns := n.Of(id); ns != expected {
+			t.Errorf("%s: expected namespace: %q, got: %q", id, expected, ns)
+		}
+	}
+
+	var none *Namespaces
+	if ns := none.Of("team_a__api"); ns != "" {
+		t.Error("unexpected namespace", ns)
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	n, err := New(Options{
+		Names:      []string{"team_a", "team_b"},
+		RateLimits: map[string]int{"team_a": 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !n.HasRateLimits() {
+		t.Fatal("failed to detect the rate limits")
+	}
+
+	allow := func(id string) bool {
+		r := &routing.Route{}
+		r.Id = id
+		return n.Allow(r)
+	}
+
+	for i := 0; i < 3; i++ {
+		if !allow("team_a__api") {
+			t.Fatal("failed to allow a request within the budget")
+		}
+	}
+
+	if allow("team_a__api") {
+		t.Error("failed to limit the requests")
+	}
+
+	for _, id := range []string{"team_b__api", "api"} {
+		if !allow(id) {
+			t.Error("unexpected limit", id)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Now()
+	l := newLimiter("team_a", 2, now)
+	if !l.allow(now) || !l.allow(now) || l.allow(now) {
+		t.Fatal("invalid initial budget")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if !l.allow(now) || l.allow(now) {
+		t.Error("invalid refill")
+	}
+
+	// the budget doesn't grow beyond one second of requests
+	now = now.Add(time.Minute)
+	if !l.allow(now) || !l.allow(now) || l.allow(now) {
+		t.Error("invalid maximum budget")
+	}
+}
@@ -0,0 +1,197 @@
+package namespace
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp/syntax"
+	"strings"
+
+	"github.com/zalando/skipper/eskip"
+	"gopkg.in/yaml.v3"
+)
+
+// LoopbackBackend allows the <loopback> backend in the policies. The
+// <shunt> backend is always allowed.
+const LoopbackBackend = "<loopback>"
+
+// Policy defines what the routes of a namespace can contain, when they
+// are set with the token of the namespace on the admin API.
+type Policy struct {
+
+	// The hosts of the routes. The routes must have at least one Host
+	// predicate, and every Host predicate must be an anchored, exact
+	// host, e.g. ^api[.]team-a[.]example[.]org$, matching one of these
+	// hosts. A host starting with *. matches the subdomains, e.g.
+	// *.team-a.example.org.
+	Hosts []string `yaml:"hosts"`
+
+	// The names of the custom predicates, e.g. Traffic. The built-in
+	// Path, PathRegexp, Method, Header and HeaderRegexp predicates are
+	// always allowed.
+	Predicates []string `yaml:"predicates"`
+
+	// The names of the filters.
+	Filters []string `yaml:"filters"`
+
+	// The hosts of the network backends, in the same format as Hosts,
+	// and optionally <loopback>.
+	Backends []string `yaml:"backends"`
+}
+
+// LoadPolicies loads the policies of the namespaces from a YAML file,
+// mapped by the namespace names, e.g.:
+//
+//	team_a:
+//	  hosts: ["*.team-a.example.org"]
+//	  filters: [setRequestHeader, setPath]
+//	  backends: ["*.team-a.svc.cluster.local"]
+func LoadPolicies(file string) (map[string]Policy, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var p map[string]Policy
+	if err := yaml.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("namespace: invalid policies in %s: %v", file, err)
+	}
+
+	return p, nil
+}
+
+// matches a host against a pattern of the policies
+func matchHost(pattern, host string) bool {
+	pattern, host = strings.ToLower(pattern), strings.ToLower(host)
+	if strings.HasPrefix(pattern, "*.") {
+		return len(host) > len(pattern)-1 && strings.HasSuffix(host, pattern[1:])
+	}
+
+	return host == pattern
+}
+
+func matchAny(patterns []string, host string) bool {
+	for _, p := range patterns {
+		if matchHost(p, host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// returns the host matched by a host regexp, when it matches exactly one
+// host, e.g. ^www[.]example[.]org$
+func exactHost(expr string) (string, bool) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+
+	re = re.Simplify()
+	if re.Op != syntax.OpConcat || len(re.Sub) != 3 {
+		return "", false
+	}
+
+	if re.Sub[0].Op != syntax.OpBeginText ||
+		re.Sub[1].Op != syntax.OpLiteral ||
+		re.Sub[2].Op != syntax.OpEndText {
+		return "", false
+	}
+
+	return string(re.Sub[1].Rune), true
+}
+
+func (p *Policy) validateHosts(r *eskip.Route) error {
+	if len(r.HostRegexps) == 0 {
+		return fmt.Errorf("missing Host predicate")
+	}
+
+	for _, expr := range r.HostRegexps {
+		host, ok := exactHost(expr)
+		if !ok {
+			return fmt.Errorf("Host predicate must match a single host: %s", expr)
+		}
+
+		if !matchAny(p.Hosts, host) {
+			return fmt.Errorf("host not allowed: %s", host)
+		}
+	}
+
+	return nil
+}
+
+func (p *Policy) validateBackend(r *eskip.Route) error {
+	switch {
+	case r.Shunt || r.BackendType == eskip.ShuntBackend:
+		return nil
+	case r.BackendType == eskip.LoopBackend:
+		if !contains(p.Backends, LoopbackBackend) {
+			return fmt.Errorf("backend not allowed: %s", LoopbackBackend)
+		}
+
+		return nil
+	}
+
+	u, err := url.Parse(r.Backend)
+	if err != nil || u.Hostname() == "" {
+		return fmt.Errorf("invalid backend: %s", r.Backend)
+	}
+
+	if !matchAny(p.Backends, u.Hostname()) {
+		return fmt.Errorf("backend not allowed: %s", r.Backend)
+	}
+
+	return nil
+}
+
+func (p *Policy) validate(r *eskip.Route) error {
+	if err := p.validateHosts(r); err != nil {
+		return err
+	}
+
+	for _, pi := range r.Predicates {
+		if !contains(p.Predicates, pi.Name) {
+			return fmt.Errorf("predicate not allowed: %s", pi.Name)
+		}
+	}
+
+	for _, f := range r.Filters {
+		if !contains(p.Filters, f.Name) {
+			return fmt.Errorf("filter not allowed: %s", f.Name)
+		}
+	}
+
+	return p.validateBackend(r)
+}
+
+// Validate checks whether a route can be set with the token of a
+// namespace. The route must belong to the namespace, and it must match
+// the policy of the namespace. Without a policy, the namespace cannot
+// set routes.
+func (n *Namespaces) Validate(ns string, r *eskip.Route) error {
+	if n.Of(r.Id) != ns {
+		return fmt.Errorf("namespace: route %s not in namespace %s", r.Id, ns)
+	}
+
+	p, ok := n.policies[ns]
+	if !ok {
+		return fmt.Errorf("namespace: no policy for namespace %s", ns)
+	}
+
+	if err := p.validate(r); err != nil {
+		return fmt.Errorf("namespace: invalid route %s: %v", r.Id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package namespace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+func TestValidate(t *testing.T) {
+	n, err := New(Options{
+		Names: []string{"team_a", "team_b", "team_c"},
+		Policies: map[string]Policy{
+			"team_a": {
+				Hosts:      []string{"*.team-a.example.org", "team-a.example.org"},
+				Predicates: []string{"Traffic"},
+				Filters:    []string{"setPath"},
+				Backends:   []string{"*.team-a.svc", LoopbackBackend},
+			},
+			"team_b": {
+				Hosts:    []string{"team-b.example.org"},
+				Backends: []string{"team-b.svc"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		title, ns, route string
+		valid            bool
+	}{{
+		title: "allowed",
+		ns:    "team_a",
+		route: `team_a__api: Host("^api[.]team-a[.]example[.]org$") && Traffic(.3) -> setPath("/") -> "https://api.team-a.svc"`,
+		valid: true,
+	}, {
+		title: "escaped dots",
+		ns:    "team_a",
+		route: `team_a__api: Host("^team-a\\.example\\.org$") -> "https://api.team-a.svc:8080"`,
+		valid: true,
+	}, {
+		title: "shunt and loopback",
+		ns:    "team_a",
+		route: `team_a__api: Host("^team-a[.]example[.]org$") && Path("/a") -> <shunt>;
+			team_a__loop: Host("^team-a[.]example[.]org$") -> <loopback>`,
+		valid: true,
+	}, {
+		title: "route of another namespace",
+		ns:    "team_a",
+		route: `team_b__api: Host("^team-a[.]example[.]org$") -> <shunt>`,
+	}, {
+		title: "missing host",
+		ns:    "team_a",
+		route: `team_a__api: Path("/") -> <shunt>`,
+	}, {
+		title: "host of another tenant",
+		ns:    "team_a",
+		route: `team_a__x: Host("^team-b[.]example[.]org$") -> "https://api.team-a.svc"`,
+	}, {
+		title: "unanchored host",
+		ns:    "team_a",
+		route: `team_a__x: Host("team-a[.]example[.]org") -> <shunt>`,
+	}, {
+		title: "host pattern",
+		ns:    "team_a",
+		route: `team_a__x: Host("^.*[.]example[.]org$") -> <shunt>`,
+	}, {
+		title: "one of the hosts not allowed",
+		ns:    "team_a",
+		route: `team_a__x: Host("^team-a[.]example[.]org$") && Host("^team-b[.]example[.]org$") -> <shunt>`,
+	}, {
+		title: "subdomain of an exact host",
+		ns:    "team_b",
+		route: `team_b__x: Host("^api[.]team-b[.]example[.]org$") -> <shunt>`,
+	}, {
+		title: "predicate not allowed",
+		ns:    "team_b",
+		route: `team_b__x: Host("^team-b[.]example[.]org$") && Traffic(.3) -> <shunt>`,
+	}, {
+		title: "filter not allowed",
+		ns:    "team_b",
+		route: `team_b__x: Host("^team-b[.]example[.]org$") -> setPath("/") -> <shunt>`,
+	}, {
+		title: "backend not allowed",
+		ns:    "team_a",
+		route: `team_a__x: Host("^team-a[.]example[.]org$") -> "https://api.team-b.svc"`,
+	}, {
+		title: "loopback not allowed",
+		ns:    "team_b",
+		route: `team_b__x: Host("^team-b[.]example[.]org$") -> <loopback>`,
+	}, {
+		title: "no policy",
+		ns:    "team_c",
+		route: `team_c__x: Host("^team-c[.]example[.]org$") -> <shunt>`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			routes, err := eskip.Parse(test.route)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, r := range routes {
+				err := n.Validate(test.ns, r)
+				if test.valid && err != nil {
+					t.Error(err)
+				}
+
+				if !test.valid && err == nil {
+					t.Error("failed to fail")
+				}
+			}
+		})
+	}
+}
+
+func TestLoadPolicies(t *testing.T) {
+	d, err := ioutil.TempDir("", "namespace-policies")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(d)
+
+	file := filepath.Join(d, "policies.yaml")
+	if err := ioutil.WriteFile(file, []byte(`
+team_a:
+  hosts: ["*.team-a.example.org"]
+  filters: [setPath]
+  backends: ["*.team-a.svc"]
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadPolicies(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := p["team_a"]
+	if len(p) != 1 || len(a.Hosts) != 1 || a.Hosts[0] != "*.team-a.example.org" ||
+		len(a.Filters) != 1 || a.Filters[0] != "setPath" || len(a.Backends) != 1 {
+		t.Error("failed to load the policies", p)
+	}
+}
@@ -0,0 +1,38 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+var errReusePortNotSupported = errors.New("SO_REUSEPORT is not supported on this platform")
+
+// ListenReusePort opens n TCP listeners on the same address, with the
+// SO_REUSEPORT socket option, so that the kernel distributes the
+// incoming connections between them, and they can be served by
+// independent accept loops. When the port of the address is 0, the
+// additional listeners use the port chosen for the first one. It is
+// supported only on Linux.
+func ListenReusePort(address string, n int) ([]net.Listener, error) {
+	lc := net.ListenConfig{Control: reusePortControl}
+	var listeners []net.Listener
+	for i := 0; i < n; i++ {
+		l, err := lc.Listen(context.Background(), "tcp", address)
+		if err != nil {
+			for _, li := range listeners {
+				li.Close()
+			}
+
+			return nil, err
+		}
+
+		if i == 0 {
+			address = l.Addr().String()
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package net
+
+import "syscall"
+
+func reusePortControl(string, string, syscall.RawConn) error {
+	return errReusePortNotSupported
+}
@@ -0,0 +1,66 @@
+package net
+
+import (
+	"net"
+	"runtime"
+	"testing"
+)
+
+func TestListenReusePort(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip()
+	}
+
+	listeners, err := ListenReusePort("127.0.0.1:0", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+
+	if len(listeners) != 3 {
+		t.Fatal("invalid number of listeners", len(listeners))
+	}
+
+	address := listeners[0].Addr().String()
+	for _, l := range listeners[1:] {
+		if l.Addr().String() != address {
+			t.Error("listening on a different address", l.Addr())
+		}
+	}
+
+	// without the option, the address cannot be reused
+	if l, err := net.Listen("tcp", address); err == nil {
+		l.Close()
+		t.Error("failed to fail")
+	}
+
+	accepted := make(chan struct{}, 9)
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			for {
+				c, err := l.Accept()
+				if err != nil {
+					return
+				}
+
+				c.Close()
+				accepted <- struct{}{}
+			}
+		}(l)
+	}
+
+	for i := 0; i < 9; i++ {
+		c, err := net.Dial("tcp", address)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.Close()
+		<-accepted
+	}
+}
@@ -0,0 +1,7 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+// +build linux,!mips,!mipsle,!mips64,!mips64le
+
+package net
+
+// the syscall package doesn't define it on every architecture
+const soReusePort = 0xf
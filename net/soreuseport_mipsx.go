@@ -0,0 +1,8 @@
+//go:build linux && (mips || mipsle || mips64 || mips64le)
+// +build linux
+// +build mips mipsle mips64 mips64le
+
+package net
+
+// the syscall package doesn't define it on every architecture
+const soReusePort = 0x200
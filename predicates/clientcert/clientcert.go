@@ -0,0 +1,93 @@
+/*
+Package clientcert implements a predicate to match routes based on the
+verified TLS certificate of the client.
+
+The client certificates are verified by the listener, when the client
+authentication is enabled with the -tls-client-auth and the
+-tls-client-ca flags. The predicate matches only the requests whose
+client certificate was verified.
+
+Without arguments, the predicate matches any verified client
+certificate. With arguments, it matches when any of the regular
+expressions matches any of the names that the certificate identifies:
+the common name of the subject, or a DNS, email or URI subject
+alternative name.
+
+Examples:
+
+    // only match requests with a verified client certificate
+    example1: ClientCert() -> "http://example.org";
+
+    // only match requests from the client named orders.example.org
+    example2: ClientCert(/^orders[.]example[.]org$/) -> "http://example.org";
+
+    // only match requests from the clients of a SPIFFE trust domain
+    example3: ClientCert(/^spiffe:[/][/]example[.]org[/]/) -> "http://example.org";
+*/
+package clientcert
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/tlsconfig"
+)
+
+// The predicate can be referenced in eskip by the name "ClientCert".
+const Name = "ClientCert"
+
+type (
+	spec struct{}
+
+	predicate struct {
+		names []*regexp.Regexp
+	}
+)
+
+// New creates a predicate specification, whose instances can be used
+// to match the verified client certificates.
+func New() routing.PredicateSpec { return &spec{} }
+
+func (s *spec) Name() string { return Name }
+
+func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
+	p := &predicate{}
+	for _, a := range args {
+		s, ok := a.(string)
+		if !ok {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		rx, err := regexp.Compile(s)
+		if err != nil {
+			return nil, err
+		}
+
+		p.names = append(p.names, rx)
+	}
+
+	return p, nil
+}
+
+func (p *predicate) Match(r *http.Request) bool {
+	c := tlsconfig.ClientCertificate(r)
+	if c == nil {
+		return false
+	}
+
+	if len(p.names) == 0 {
+		return true
+	}
+
+	for _, n := range tlsconfig.ClientCertificateNames(c) {
+		for _, rx := range p.names {
+			if rx.MatchString(n) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
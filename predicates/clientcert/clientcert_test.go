@@ -0,0 +1,79 @@
+package clientcert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"testing"
+)
+
+func TestClientCert(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "orders.example.org"},
+		EmailAddresses: []string{"orders@example.org"},
+	}
+
+	verified, _ := http.NewRequest("GET", "https://www.example.org/", nil)
+	verified.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+
+	// sent by the client, but not verified
+	unverified, _ := http.NewRequest("GET", "https://www.example.org/", nil)
+	unverified.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+
+	plain, _ := http.NewRequest("GET", "http://www.example.org/", nil)
+
+	for _, test := range []struct {
+		title   string
+		args    []interface{}
+		request *http.Request
+		fail    bool
+		match   bool
+	}{{
+		title: "invalid argument",
+		args:  []interface{}{42.0},
+		fail:  true,
+	}, {
+		title: "invalid expression",
+		args:  []interface{}{"("},
+		fail:  true,
+	}, {
+		title:   "no TLS",
+		request: plain,
+	}, {
+		title:   "not verified",
+		request: unverified,
+	}, {
+		title:   "any verified",
+		request: verified,
+		match:   true,
+	}, {
+		title:   "matching email",
+		args:    []interface{}{"^billing[.]example[.]org$", "^orders@example[.]org$"},
+		request: verified,
+		match:   true,
+	}, {
+		title:   "not matching",
+		args:    []interface{}{"^billing[.]example[.]org$"},
+		request: verified,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			p, err := New().Create(test.args)
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if p.Match(test.request) != test.match {
+				t.Error("invalid match result, expected:", test.match)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Package geoip implements predicates to match routes based on the country
+and the autonomous system of the client, looked up in the GeoIP
+databases configured with the -geoip-db flag. See the skipper/geoip
+package.
+
+The Country predicate expects one or more ISO 3166-1 alpha-2 country
+codes, and matches when the country of the client is one of them. The
+ASN predicate expects one or more autonomous system numbers.
+
+The clients whose country or ASN is not found in the databases don't
+match any of the predicates.
+
+Examples:
+
+    // only match requests from Germany and Austria
+    example1: Country("DE", "AT") -> "http://example.org";
+
+    // only match requests from the autonomous system 64496
+    example2: ASN(64496) -> "http://example.org";
+*/
+package geoip
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/zalando/skipper/geoip"
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	// The country predicate can be referenced in eskip by the name
+	// "Country".
+	CountryName = "Country"
+
+	// The ASN predicate can be referenced in eskip by the name "ASN".
+	ASNName = "ASN"
+)
+
+type (
+	countrySpec struct {
+		db *geoip.DB
+	}
+
+	asnSpec struct {
+		db *geoip.DB
+	}
+
+	countryPredicate struct {
+		db        *geoip.DB
+		countries map[string]bool
+	}
+
+	asnPredicate struct {
+		db   *geoip.DB
+		asns map[uint]bool
+	}
+)
+
+// NewCountry creates a predicate specification, whose instances match
+// the country of the client.
+func NewCountry(db *geoip.DB) routing.PredicateSpec { return &countrySpec{db: db} }
+
+// NewASN creates a predicate specification, whose instances match the
+// autonomous system of the client.
+func NewASN(db *geoip.DB) routing.PredicateSpec { return &asnSpec{db: db} }
+
+func (s *countrySpec) Name() string { return CountryName }
+
+func (s *countrySpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) == 0 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	p := &countryPredicate{db: s.db, countries: make(map[string]bool)}
+	for _, a := range args {
+		c, ok := a.(string)
+		if !ok || len(c) != 2 {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		p.countries[strings.ToUpper(c)] = true
+	}
+
+	return p, nil
+}
+
+func (p *countryPredicate) Match(r *http.Request) bool {
+	c := p.db.LookupRequest(r).Country
+	return c != "" && p.countries[c]
+}
+
+func (s *asnSpec) Name() string { return ASNName }
+
+func (s *asnSpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) == 0 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	p := &asnPredicate{db: s.db, asns: make(map[uint]bool)}
+	for _, a := range args {
+		n, ok := a.(float64)
+		if !ok || n <= 0 || n != float64(uint(n)) {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		p.asns[uint(n)] = true
+	}
+
+	return p, nil
+}
+
+func (p *asnPredicate) Match(r *http.Request) bool {
+	asn := p.db.LookupRequest(r).ASN
+	return asn != 0 && p.asns[asn]
+}
@@ -0,0 +1,123 @@
+package geoip
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zalando/skipper/geoip"
+	"github.com/zalando/skipper/geoip/geoiptest"
+)
+
+func testDB(t *testing.T) (*geoip.DB, func()) {
+	dir, err := ioutil.TempDir("", "geoip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := filepath.Join(dir, "test.mmdb")
+	if err := geoiptest.WriteFile(f, "GeoLite2-Test", []geoiptest.Network{
+		{CIDR: "192.0.2.0/24", Country: "DE", ASN: 64496},
+		{CIDR: "198.51.100.0/24", Country: "AT"},
+	}); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	db, err := geoip.New(geoip.Options{Databases: []string{f}, ReloadInterval: -1})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func request(remoteAddr string) *http.Request {
+	r, _ := http.NewRequest("GET", "https://www.example.org", nil)
+	r.RemoteAddr = remoteAddr
+	return r
+}
+
+func TestCreate(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		asn  bool
+	}{{
+		msg: "no country",
+	}, {
+		msg:  "invalid country",
+		args: []interface{}{"DEU"},
+	}, {
+		msg:  "invalid country type",
+		args: []interface{}{42.0},
+	}, {
+		msg: "no asn",
+		asn: true,
+	}, {
+		msg:  "invalid asn",
+		args: []interface{}{1.5},
+		asn:  true,
+	}, {
+		msg:  "invalid asn type",
+		args: []interface{}{"64496"},
+		asn:  true,
+	}} {
+		spec := NewCountry(db)
+		if ti.asn {
+			spec = NewASN(db)
+		}
+
+		if _, err := spec.Create(ti.args); err == nil {
+			t.Error(ti.msg, "failed to fail")
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	country, err := NewCountry(db).Create([]interface{}{"de", "AT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asn, err := NewASN(db).Create([]interface{}{64496.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ti := range []struct {
+		remoteAddr string
+		country    bool
+		asn        bool
+	}{{
+		remoteAddr: "192.0.2.1:1234",
+		country:    true,
+		asn:        true,
+	}, {
+		remoteAddr: "198.51.100.1:1234",
+		country:    true,
+	}, {
+		remoteAddr: "203.0.113.1:1234",
+	}} {
+		r := request(ti.remoteAddr)
+		if country.Match(r) != ti.country {
+			t.Errorf("%s: expected country match: %t", ti.remoteAddr, ti.country)
+		}
+
+		if asn.Match(r) != ti.asn {
+			t.Errorf("%s: expected asn match: %t", ti.remoteAddr, ti.asn)
+		}
+	}
+}
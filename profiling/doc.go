@@ -0,0 +1,22 @@
+/*
+Package profiling implements the continuous profiling of the proxy, so
+that the regressions observed in production can be diagnosed after the
+fact, without having to reproduce them while the on-demand profiling
+endpoints are watched.
+
+The profiler captures a CPU profile and a heap profile periodically, and
+pushes them in the pprof format to the ingest endpoint of a Pyroscope
+compatible profiling service, e.g:
+
+    skipper -profiling-endpoint http://pyroscope:4040/ingest -profiling-interval 5m -profiling-labels env=prod,zone=eu-1
+
+The profiles are pushed with the name <app>.<type>{<labels>}, e.g:
+
+    skipper.cpu{env=prod,zone=eu-1}
+
+Only one CPU profile can be taken by the process at a time. When a CPU
+profile is being taken from the /debug/pprof/profile endpoint of the
+metrics listener, the periodic CPU profile is skipped, and the error is
+logged.
+*/
+package profiling
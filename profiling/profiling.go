@@ -0,0 +1,267 @@
+package profiling
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Types of the captured profiles.
+const (
+	CPU  = "cpu"
+	Heap = "heap"
+)
+
+const (
+	// DefaultAppName is the default application name that the
+	// profiles are pushed with.
+	DefaultAppName = "skipper"
+
+	// DefaultInterval is the default interval of capturing the
+	// profiles.
+	DefaultInterval = 5 * time.Minute
+
+	// DefaultCPUDuration is the default duration of a CPU profile.
+	DefaultCPUDuration = 10 * time.Second
+
+	pushTimeout = 30 * time.Second
+)
+
+var (
+	errMissingEndpoint = errors.New("profiling: endpoint required")
+	errCPUDuration     = errors.New("profiling: CPU duration must be shorter than the interval")
+)
+
+// Options for creating a Profiler.
+type Options struct {
+
+	// The URL of the ingest endpoint of a Pyroscope compatible
+	// profiling service, e.g. http://pyroscope:4040/ingest. Required.
+	Endpoint string
+
+	// The application name that the profiles are pushed with.
+	// Default: skipper.
+	AppName string
+
+	// Labels pushed with the profiles, e.g. the environment or the
+	// instance.
+	Labels map[string]string
+
+	// The interval of capturing the profiles. Default: 5m.
+	Interval time.Duration
+
+	// The duration of a CPU profile. Default: 10s.
+	CPUDuration time.Duration
+
+	// The types of the captured profiles: cpu and heap. Default: all.
+	Types []string
+}
+
+// Profiler captures the CPU and heap profiles of the process
+// periodically, and pushes them to a profiling service.
+type Profiler struct {
+	endpoint    string
+	appName     string
+	labels      string
+	interval    time.Duration
+	cpuDuration time.Duration
+	types       []string
+	client      *http.Client
+	quit        chan struct{}
+	done        chan struct{}
+}
+
+// New creates a Profiler, and starts capturing the profiles in the
+// background.
+func New(o Options) (*Profiler, error) {
+	if o.Endpoint == "" {
+		return nil, errMissingEndpoint
+	}
+
+	if _, err := url.Parse(o.Endpoint); err != nil {
+		return nil, err
+	}
+
+	if o.AppName == "" {
+		o.AppName = DefaultAppName
+	}
+
+	if o.Interval <= 0 {
+		o.Interval = DefaultInterval
+	}
+
+	if o.CPUDuration <= 0 {
+		o.CPUDuration = DefaultCPUDuration
+	}
+
+	if o.CPUDuration >= o.Interval {
+		return nil, errCPUDuration
+	}
+
+	if len(o.Types) == 0 {
+		o.Types = []string{CPU, Heap}
+	}
+
+	for _, t := range o.Types {
+		if t != CPU && t != Heap {
+			return nil, fmt.Errorf("profiling: invalid profile type: %s", t)
+		}
+	}
+
+	p := &Profiler{
+		endpoint:    o.Endpoint,
+		appName:     o.AppName,
+		labels:      formatLabels(o.Labels),
+		interval:    o.Interval,
+		cpuDuration: o.CPUDuration,
+		types:       o.Types,
+		client:      &http.Client{Timeout: pushTimeout},
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	go p.run()
+	return p, nil
+}
+
+// formats the labels in the format of {key1=value1,key2=value2}, in a
+// stable order
+func formatLabels(l map[string]string) string {
+	if len(l) == 0 {
+		return ""
+	}
+
+	var kv []string
+	for k, v := range l {
+		kv = append(kv, k+"="+v)
+	}
+
+	sort.Strings(kv)
+	return "{" + strings.Join(kv, ",") + "}"
+}
+
+// captures a CPU profile. It fails when a CPU profile is already being
+// taken, e.g. from the /debug/pprof/profile endpoint.
+func (p *Profiler) captureCPU() ([]byte, bool, error) {
+	var b bytes.Buffer
+	if err := pprof.StartCPUProfile(&b); err != nil {
+		return nil, false, err
+	}
+
+	t := time.NewTimer(p.cpuDuration)
+	defer t.Stop()
+
+	stopped := false
+	select {
+	case <-t.C:
+	case <-p.quit:
+		stopped = true
+	}
+
+	pprof.StopCPUProfile()
+	return b.Bytes(), stopped, nil
+}
+
+func captureHeap() ([]byte, error) {
+	var b bytes.Buffer
+	if err := pprof.Lookup("heap").WriteTo(&b, 0); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func (p *Profiler) push(typ string, from, until time.Time, profile []byte) error {
+	q := make(url.Values)
+	q.Set("name", p.appName+"."+typ+p.labels)
+	q.Set("from", strconv.FormatInt(from.Unix(), 10))
+	q.Set("until", strconv.FormatInt(until.Unix(), 10))
+	q.Set("format", "pprof")
+	q.Set("spyName", "gospy")
+
+	u := p.endpoint
+	if strings.Contains(u, "?") {
+		u += "&" + q.Encode()
+	} else {
+		u += "?" + q.Encode()
+	}
+
+	rsp, err := p.client.Post(u, "application/octet-stream", bytes.NewReader(profile))
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status from the profiling endpoint: %d", rsp.StatusCode)
+	}
+
+	return nil
+}
+
+// captures and pushes a profile of every configured type. It returns
+// false when the profiler was closed during the capturing.
+func (p *Profiler) capture() bool {
+	for _, t := range p.types {
+		var (
+			profile []byte
+			stopped bool
+			err     error
+		)
+
+		from := time.Now()
+		switch t {
+		case CPU:
+			profile, stopped, err = p.captureCPU()
+		case Heap:
+			profile, err = captureHeap()
+		}
+
+		if err != nil {
+			log.Errorf("error while capturing %s profile: %v", t, err)
+			continue
+		}
+
+		if stopped {
+			return false
+		}
+
+		if err := p.push(t, from, time.Now(), profile); err != nil {
+			log.Errorf("error while pushing %s profile: %v", t, err)
+		}
+	}
+
+	return true
+}
+
+func (p *Profiler) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !p.capture() {
+				return
+			}
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// Close stops capturing the profiles. It interrupts the CPU profile in
+// progress, without pushing it.
+func (p *Profiler) Close() {
+	close(p.quit)
+	<-p.done
+}
@@ -0,0 +1,85 @@
+package profiling
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type pushed struct {
+	name, format string
+	size         int
+}
+
+func TestFormatLabels(t *testing.T) {
+	for _, test := range []struct {
+		labels map[string]string
+		expect string
+	}{
+		{expect: ""},
+		{labels: map[string]string{"env": "prod"}, expect: "{env=prod}"},
+		{labels: map[string]string{"zone": "eu-1", "env": "prod"}, expect: "{env=prod,zone=eu-1}"},
+	} {
+		if l := formatLabels(test.labels); l != test.expect {
+			t.Errorf("invalid labels, got: %s, expected: %s", l, test.expect)
+		}
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for _, o := range []Options{
+		{},
+		{Endpoint: "http://localhost/ingest", Interval: time.Second, CPUDuration: time.Second},
+		{Endpoint: "http://localhost/ingest", Types: []string{"goroutine"}},
+	} {
+		if _, err := New(o); err == nil {
+			t.Error("failed to fail", o)
+		}
+	}
+}
+
+func TestPushesProfiles(t *testing.T) {
+	received := make(chan pushed, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		received <- pushed{
+			name:   r.URL.Query().Get("name"),
+			format: r.URL.Query().Get("format"),
+			size:   len(b),
+		}
+	}))
+	defer server.Close()
+
+	p, err := New(Options{
+		Endpoint:    server.URL + "/ingest",
+		Labels:      map[string]string{"env": "test"},
+		Interval:    60 * time.Millisecond,
+		CPUDuration: 30 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer p.Close()
+
+	expect := map[string]bool{"skipper.cpu{env=test}": true, "skipper.heap{env=test}": true}
+	timeout := time.After(3 * time.Second)
+	for len(expect) > 0 {
+		select {
+		case r := <-received:
+			if r.format != "pprof" || r.size == 0 {
+				t.Error("invalid profile", r)
+			}
+
+			delete(expect, r.name)
+		case <-timeout:
+			t.Fatal("timeout, missing profiles:", expect)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/flowid"
+	"github.com/zalando/skipper/logging"
+)
+
+func TestSetsProxyDetailsForAccessLog(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer backend.Close()
+
+	u, _ := url.Parse(backend.URL)
+
+	doc := `
+		testRoute: * -> "` + backend.URL + `";
+		loop1: Path("/loop1") -> setPath("/loop2") -> <loopback>;
+		loop2: Path("/loop2") -> setPath("/foo") -> <loopback>;
+	`
+	tp, err := newTestProxy(doc, FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	for _, test := range []struct {
+		path    string
+		retries int
+	}{
+		{"/foo", 0},
+		{"/loop2", 1},
+		{"/loop1", 2},
+	} {
+		d := &logging.ProxyDetails{}
+		r, _ := http.NewRequest("GET", "http://www.example.org"+test.path, nil)
+		r = r.WithContext(logging.ContextWithProxyDetails(r.Context(), d))
+		tp.proxy.ServeHTTP(httptest.NewRecorder(), r)
+
+		if d.RouteID != "testRoute" || d.BackendHost != u.Host || d.Retries != test.retries {
+			t.Error("failed to set the proxy details", test.path, d.RouteID, d.BackendHost, d.Retries)
+		}
+	}
+}
+
+func TestFlowIDAsRequestID(t *testing.T) {
+	received := make(chan string, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(flowid.HeaderName)
+	}))
+	defer backend.Close()
+
+	g, err := flowid.NewStandardGenerator(flowid.DefaultLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := `
+		generated: Path("/generated") -> "` + backend.URL + `";
+		filtered: Path("/filtered") -> flowId() -> "` + backend.URL + `"`
+	tp, err := newTestProxyWithFiltersAndParams(nil, doc, Params{FlowIDGenerator: g, CloseIdleConnsPeriod: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	for _, test := range []struct {
+		path     string
+		incoming string
+		reused   bool
+	}{
+		{"/generated", "", false},
+		{"/generated", "validflowid", true},
+		{"/generated", "[invalid]", false},
+		{"/filtered", "validflowid", false},
+	} {
+		d := &logging.ProxyDetails{}
+		r, _ := http.NewRequest("GET", "http://www.example.org"+test.path, nil)
+		r = r.WithContext(logging.ContextWithProxyDetails(r.Context(), d))
+		if test.incoming != "" {
+			r.Header.Set(flowid.HeaderName, test.incoming)
+		}
+
+		tp.proxy.ServeHTTP(httptest.NewRecorder(), r)
+
+		var id string
+		select {
+		case id = <-received:
+		case <-time.After(time.Second):
+			t.Fatal("backend not called")
+		}
+
+		if id == "" || d.RequestID != id || (id == test.incoming) != test.reused {
+			t.Error("invalid flow id", test.path, test.incoming, id, d.RequestID)
+		}
+	}
+}
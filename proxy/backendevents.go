@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/events"
+)
+
+// the minimum interval of publishing the backend_unhealthy events of
+// the same backend, to avoid flooding the event bus during an outage
+const backendUnhealthyInterval = 10 * time.Second
+
+type unhealthyBackends struct {
+	mx   sync.Mutex
+	last map[string]time.Time
+}
+
+func (u *unhealthyBackends) shouldPublish(host string, now time.Time) bool {
+	u.mx.Lock()
+	defer u.mx.Unlock()
+	if now.Sub(u.last[host]) < backendUnhealthyInterval {
+		return false
+	}
+
+	if u.last == nil {
+		u.last = make(map[string]time.Time)
+	}
+
+	u.last[host] = now
+	return true
+}
+
+// publishes a backend_unhealthy event, when the connection to a backend
+// failed
+func (p *Proxy) publishBackendUnhealthy(ctx *context, err error) {
+	if p.eventBus == nil || !p.unhealthyBackends.shouldPublish(ctx.route.Host, time.Now()) {
+		return
+	}
+
+	p.eventBus.Publish(&events.Event{
+		Type: events.TypeBackendUnhealthy,
+		Data: map[string]interface{}{
+			"backend": ctx.route.Host,
+			"route":   ctx.route.Id,
+			"error":   err.Error(),
+		},
+	})
+}
@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/events"
+	"github.com/zalando/skipper/filters/builtin"
+)
+
+func TestPublishesBackendUnhealthy(t *testing.T) {
+	bus := events.NewBus()
+	s := bus.Subscribe(0, events.TypeBackendUnhealthy)
+	defer s.Close()
+
+	doc := `failing: * -> "http://127.0.0.1:1"`
+	tp, err := newTestProxyWithFiltersAndParams(builtin.MakeRegistry(), doc, Params{EventBus: bus, CloseIdleConnsPeriod: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	// the second failure is within the throttling interval
+	for i := 0; i < 2; i++ {
+		r, _ := http.NewRequest("GET", "http://www.example.org/foo", nil)
+		tp.proxy.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	select {
+	case e := <-s.C:
+		if e.Data["backend"] != "127.0.0.1:1" || e.Data["route"] != "failing" || e.Data["error"] == "" {
+			t.Error("invalid event", e.Data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event not received")
+	}
+
+	select {
+	case e := <-s.C:
+		t.Error("unexpected event", e.Data)
+	default:
+	}
+}
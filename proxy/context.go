@@ -2,17 +2,33 @@ package proxy
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/logging"
 	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/tracing"
 )
 
 const unknownHost = "_unknownhost_"
 
+// durations of the processing phases, accumulated over the loopbacks,
+// reported in the Server-Timing header
+type phaseTiming struct {
+	lookup          time.Duration
+	requestFilters  time.Duration
+	backend         time.Duration
+	responseFilters time.Duration
+}
+
 type context struct {
 	responseWriter        http.ResponseWriter
 	request               *http.Request
@@ -30,6 +46,19 @@ type context struct {
 	incomingDebugResponse *http.Response
 	loopCounter           int
 	startServe            time.Time
+	span                  tracing.Span
+	proxyDetails          *logging.ProxyDetails
+	flowID                string
+	timing                *phaseTiming
+	metrics               filters.Metrics
+
+	// the header of the outgoing request, returned to the pool when
+	// the request was served
+	outgoingHeader http.Header
+
+	// holds the timing of the context, when it is not a loopback
+	// clone
+	ownTiming phaseTiming
 }
 
 func defaultBody() io.ReadCloser {
@@ -45,6 +74,21 @@ func defaultResponse(r *http.Request) *http.Response {
 	}
 }
 
+func rateLimitedResponse(r *http.Request) *http.Response {
+	text := http.StatusText(http.StatusTooManyRequests) + "\n"
+	h := make(http.Header)
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	h.Set("Content-Length", strconv.Itoa(len(text)))
+	h.Set("Retry-After", "1")
+	return &http.Response{
+		StatusCode:    http.StatusTooManyRequests,
+		Header:        h,
+		ContentLength: int64(len(text)),
+		Body:          ioutil.NopCloser(bytes.NewBufferString(text)),
+		Request:       r,
+	}
+}
+
 func cloneURL(u *url.URL) *url.URL {
 	uc := *u
 	return &uc
@@ -102,12 +146,17 @@ func appendParams(to, from map[string]string) map[string]string {
 	return to
 }
 
+// the returned context is taken from the pool, and it needs to be
+// returned with putContext
 func newContext(w http.ResponseWriter, r *http.Request, preserveOriginal bool) *context {
-	c := &context{
-		responseWriter: w,
-		request:        r,
-		stateBag:       make(map[string]interface{}),
-		outgoingHost:   r.Host,
+	c := getContext()
+	c.responseWriter = w
+	c.request = r
+	c.outgoingHost = r.Host
+	c.proxyDetails = logging.ProxyDetailsFromContext(r.Context())
+	c.timing = &c.ownTiming
+	if c.stateBag == nil {
+		c.stateBag = make(map[string]interface{})
 	}
 
 	if preserveOriginal {
@@ -126,6 +175,58 @@ func (c *context) applyRoute(route *routing.Route, params map[string]string, pre
 	}
 
 	c.pathParams = appendParams(c.pathParams, params)
+
+	// the access log records the last matched route, in case of
+	// loopbacks, and the number of loopbacks as the retries
+	if c.proxyDetails != nil {
+		c.proxyDetails.RouteID = route.Id
+		c.proxyDetails.BackendHost = route.Host
+		c.proxyDetails.Retries = c.loopCounter - 1
+	}
+}
+
+func formatTiming(name, description string, d time.Duration) string {
+	return fmt.Sprintf("%s;desc=\"%s\";dur=%.3f", name, description, float64(d)/float64(time.Millisecond))
+}
+
+// the value of the Server-Timing header, the durations in milliseconds
+func (t *phaseTiming) header(total time.Duration) string {
+	return strings.Join([]string{
+		formatTiming("route", "route lookup", t.lookup),
+		formatTiming("request-filters", "request filters", t.requestFilters),
+		formatTiming("backend", "backend", t.backend),
+		formatTiming("response-filters", "response filters", t.responseFilters),
+		formatTiming("total", "total", total),
+	}, ", ")
+}
+
+// sets the correlation ID of the request, shared with the access log
+func (c *context) setFlowID(id string) {
+	c.flowID = id
+	if c.proxyDetails != nil {
+		c.proxyDetails.RequestID = id
+	}
+}
+
+// the trace ID of the request, when the trace is recorded, to be used
+// as an exemplar of the latency metrics
+func (c *context) sampledTraceID() string {
+	sc := c.span.Context()
+	if !sc.IsValid() || !sc.Sampled {
+		return ""
+	}
+
+	return sc.TraceID()
+}
+
+// returns a logger that tags the entries with the flow id of the
+// request, when known
+func (c *context) logger() *log.Entry {
+	if c.flowID == "" {
+		return log.NewEntry(log.StandardLogger())
+	}
+
+	return log.WithField("flow-id", c.flowID)
 }
 
 func (c *context) ensureDefaultResponse() {
@@ -143,6 +244,16 @@ func (c *context) ensureDefaultResponse() {
 	}
 }
 
+// stores the header of the outgoing request, to return it to the pool,
+// when the request was served
+func (c *context) setOutgoingHeader(h http.Header) {
+	if c.outgoingHeader != nil {
+		putHeader(c.outgoingHeader)
+	}
+
+	c.outgoingHeader = h
+}
+
 func (c *context) deprecatedShunted() bool {
 	return c.deprecatedServed
 }
@@ -170,6 +281,7 @@ func (c *context) OriginalRequest() *http.Request      { return c.originalReques
 func (c *context) OriginalResponse() *http.Response    { return c.originalResponse }
 func (c *context) OutgoingHost() string                { return c.outgoingHost }
 func (c *context) SetOutgoingHost(h string)            { c.outgoingHost = h }
+func (c *context) Metrics() filters.Metrics            { return c.metrics }
 
 func (c *context) Serve(r *http.Response) {
 	r.Request = c.Request()
@@ -8,6 +8,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 )
 
 type (
@@ -28,6 +29,7 @@ type (
 	debugDocument struct {
 		RouteId         string             `json:"route_id,omitempty"`
 		Route           string             `json:"route,omitempty"`
+		Backend         string             `json:"backend,omitempty"`
 		Incoming        *debugRequest      `json:"incoming,omitempty"`
 		Outgoing        *debugRequest      `json:"outgoing,omitempty"`
 		ResponseMod     *debugResponseMod  `json:"response_mod,omitempty"`
@@ -52,9 +54,15 @@ type debugInfo struct {
 }
 
 func convertRequest(r *http.Request) *debugRequest {
+	// the outgoing requests don't have the RequestURI set
+	uri := r.RequestURI
+	if uri == "" && r.URL != nil {
+		uri = r.URL.RequestURI()
+	}
+
 	return &debugRequest{
 		Method:        r.Method,
-		Uri:           r.RequestURI,
+		Uri:           uri,
 		Proto:         r.Proto,
 		Header:        r.Header,
 		Host:          r.Host,
@@ -82,6 +90,9 @@ func convertDebugInfo(d *debugInfo) debugDocument {
 		doc.Route = d.route.String()
 		doc.Filters = d.route.Filters
 		doc.Predicates = d.route.Predicates
+		if d.route.BackendType != eskip.NetworkBackend {
+			doc.Backend = "<" + d.route.BackendType.String() + ">"
+		}
 	}
 
 	var requestBody io.Reader
@@ -94,6 +105,9 @@ func convertDebugInfo(d *debugInfo) debugDocument {
 
 	if d.outgoing != nil {
 		doc.Outgoing = convertRequest(d.outgoing)
+		if d.outgoing.URL != nil {
+			doc.Backend = (&url.URL{Scheme: d.outgoing.URL.Scheme, Host: d.outgoing.URL.Host}).String()
+		}
 
 		// if there is an outgoing request, use the body from there
 		requestBody = d.outgoing.Body
@@ -123,6 +123,18 @@ and `SetOutgoingHost()` methods of the `FilterContext` need to be used
 instead of the `Request.Header` map.
 
 
+Backend Connections
+
+Every backend, identified by the scheme and the host of the route
+backend, has its own connection pool, created on the first request. The
+connections of a backend can be tuned separately, e.g. with timeouts,
+client certificates or HTTP/2, with the BackendTransports params. The
+pools not used since the last closing of the idle connections are
+dropped. The state of the pools, e.g. the number of the open
+connections and of the pending requests, is returned by TransportStats.
+
+
+
 Proxy Example
 
 The below example demonstrates creating a routing proxy as a standard
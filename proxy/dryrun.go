@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// DryRunPath is the path where the handler created by NewDryRunHandler
+// accepts the synthetic requests.
+const DryRunPath = "/__skipper/dryrun"
+
+// synthetic request accepted by the dry-run handler
+type dryRunRequest struct {
+	Method        string      `json:"method"`
+	URL           string      `json:"url"`
+	Header        http.Header `json:"header,omitempty"`
+	Body          string      `json:"body,omitempty"`
+	RemoteAddress string      `json:"remote_address,omitempty"`
+}
+
+type dryRunHandler struct {
+	debugProxy *Proxy
+}
+
+// NewDryRunHandler creates an http.Handler that accepts a synthetic
+// request, as a JSON document in the body of a POST request, runs it
+// through the route matching and the filters of a proxy created with
+// the Debug flag, and responds with the resulting debug document,
+// containing the modified request and the would-be backend. The
+// backend is not contacted. Example:
+//
+//	POST /__skipper/dryrun
+//
+//	{
+//	    "method": "GET",
+//	    "url": "https://www.example.org/foo?bar=baz",
+//	    "header": {"X-Foo": ["qux"]},
+//	    "body": "",
+//	    "remote_address": "10.0.0.1:43210"
+//	}
+//
+// The requests to other paths are passed to the debug proxy itself,
+// the same way as without the dry-run handler.
+func NewDryRunHandler(debugProxy *Proxy) http.Handler {
+	return &dryRunHandler{debugProxy: debugProxy}
+}
+
+func (h *dryRunHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != DryRunPath {
+		h.debugProxy.ServeHTTP(w, r)
+		return
+	}
+
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var dr dryRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&dr); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sr, err := dr.toRequest()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.debugProxy.ServeHTTP(w, sr)
+}
+
+// creates an incoming request, in the form as the http server would
+// pass it to the proxy
+func (dr *dryRunRequest) toRequest() (*http.Request, error) {
+	if dr.Method == "" {
+		dr.Method = "GET"
+	}
+
+	u, err := url.Parse(dr.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := http.NewRequest(dr.Method, dr.URL, ioutil.NopCloser(bytes.NewBufferString(dr.Body)))
+	if err != nil {
+		return nil, err
+	}
+
+	r.ContentLength = int64(len(dr.Body))
+	r.RequestURI = u.RequestURI()
+	r.RemoteAddr = dr.RemoteAddress
+	if dr.Header != nil {
+		r.Header = dr.Header
+	}
+
+	if host := r.Header.Get("Host"); host != "" {
+		r.Host = host
+		r.Header.Del("Host")
+	}
+
+	// the server side requests contain only the path and the query
+	r.URL = &url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery}
+	return r, nil
+}
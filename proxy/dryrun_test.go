@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	doc := `
+		api: Host("api.example.org") && Path("/foo")
+			-> setPath("/bar")
+			-> setRequestHeader("X-Foo", "baz")
+			-> "https://backend.example.org";
+		shunted: Path("/shunt") -> <shunt>`
+
+	tp, err := newTestProxy(doc, Debug)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	h := NewDryRunHandler(tp.proxy)
+
+	for _, test := range []struct {
+		title           string
+		method          string
+		path            string
+		body            string
+		expectedStatus  int
+		expectedRoute   string
+		expectedBackend string
+		expectedURI     string
+	}{{
+		title:          "wrong method",
+		method:         "GET",
+		path:           DryRunPath,
+		expectedStatus: http.StatusMethodNotAllowed,
+	}, {
+		title:          "invalid document",
+		method:         "POST",
+		path:           DryRunPath,
+		body:           "{",
+		expectedStatus: http.StatusBadRequest,
+	}, {
+		title:           "synthetic request",
+		method:          "POST",
+		path:            DryRunPath,
+		body:            `{"method": "GET", "url": "https://api.example.org/foo?q=1", "header": {"X-Qux": ["quux"]}}`,
+		expectedStatus:  http.StatusOK,
+		expectedRoute:   "api",
+		expectedBackend: "https://backend.example.org",
+		expectedURI:     "/bar?q=1",
+	}, {
+		title:           "synthetic request to shunt",
+		method:          "POST",
+		path:            DryRunPath,
+		body:            `{"url": "http://www.example.org/shunt"}`,
+		expectedStatus:  http.StatusOK,
+		expectedRoute:   "shunted",
+		expectedBackend: "<shunt>",
+	}, {
+		title:          "plain debug request",
+		method:         "GET",
+		path:           "/shunt",
+		expectedStatus: http.StatusOK,
+		expectedRoute:  "shunted",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, "http://debug.example.org"+test.path, bytes.NewBufferString(test.body))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != test.expectedStatus {
+				t.Fatal("invalid status", w.Code, test.expectedStatus)
+			}
+
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var d debugDocument
+			if err := json.Unmarshal(w.Body.Bytes(), &d); err != nil {
+				t.Fatal(err)
+			}
+
+			if d.RouteId != test.expectedRoute {
+				t.Error("invalid route", d.RouteId, test.expectedRoute)
+			}
+
+			if test.expectedBackend != "" && d.Backend != test.expectedBackend {
+				t.Error("invalid backend", d.Backend, test.expectedBackend)
+			}
+
+			if test.expectedURI == "" {
+				return
+			}
+
+			if d.Outgoing == nil {
+				t.Fatal("missing outgoing request")
+			}
+
+			if d.Outgoing.Uri != test.expectedURI {
+				t.Error("invalid outgoing uri", d.Outgoing.Uri, test.expectedURI)
+			}
+
+			if d.Outgoing.Header.Get("X-Foo") != "baz" || d.Outgoing.Header.Get("X-Qux") != "quux" {
+				t.Error("invalid outgoing headers", d.Outgoing.Header)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/zalando/skipper/errorreport"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/builtin"
+)
+
+type (
+	panicFilter struct{}
+
+	testReporter struct {
+		mx        sync.Mutex
+		events    []*errorreport.Event
+		responses map[string][]int
+	}
+)
+
+func (f *panicFilter) Name() string                                       { return "panicFilter" }
+func (f *panicFilter) CreateFilter([]interface{}) (filters.Filter, error) { return f, nil }
+func (f *panicFilter) Request(filters.FilterContext)                      { panic("boom") }
+func (f *panicFilter) Response(filters.FilterContext)                     {}
+
+func (r *testReporter) Report(e *errorreport.Event) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *testReporter) ObserveResponse(routeID string, statusCode int) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.responses[routeID] = append(r.responses[routeID], statusCode)
+}
+
+func (r *testReporter) Close() {}
+
+func TestReportsFilterPanicsAndResponses(t *testing.T) {
+	fr := builtin.MakeRegistry()
+	fr.Register(&panicFilter{})
+
+	rep := &testReporter{responses: make(map[string][]int)}
+	doc := `
+		panicking: Path("/panic") -> panicFilter() -> status(418) -> <shunt>;
+		failing: Path("/fail") -> "http://127.0.0.1:1"`
+	tp, err := newTestProxyWithFiltersAndParams(fr, doc, Params{ErrorReporter: rep, CloseIdleConnsPeriod: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	for _, p := range []string{"/panic", "/fail"} {
+		r, _ := http.NewRequest("GET", "http://www.example.org"+p, nil)
+		tp.proxy.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	if len(rep.events) != 1 {
+		t.Fatal("invalid number of events", len(rep.events))
+	}
+
+	e := rep.events[0]
+	if e.Kind != errorreport.KindFilterPanic || e.Message != "boom" || e.RouteID != "panicking" ||
+		e.Filter != "panicFilter" || e.Stack == "" {
+		t.Error("invalid event", e)
+	}
+
+	if s := rep.responses["panicking"]; len(s) != 1 || s[0] != http.StatusTeapot {
+		t.Error("invalid observed response", s)
+	}
+
+	if s := rep.responses["failing"]; len(s) != 1 || s[0] < http.StatusInternalServerError {
+		t.Error("invalid observed response", s)
+	}
+}
@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/zalando/skipper/metrics"
+)
+
+// the prefix of the keys of the metrics emitted by the filters, so that
+// they don't collide with the metrics of the proxy
+const filterMetricsPrefix = "custom."
+
+// provides the metrics of the proxy to the filters, with the keys
+// prefixed
+type filterMetrics struct {
+	impl metrics.Metrics
+}
+
+func (m *filterMetrics) MeasureSince(key string, start time.Time) {
+	m.impl.MeasureSince(filterMetricsPrefix+key, start)
+}
+
+func (m *filterMetrics) IncCounter(key string) {
+	m.impl.IncCounter(filterMetricsPrefix + key)
+}
+
+func (m *filterMetrics) IncCounterBy(key string, n int64) {
+	m.impl.IncCounterBy(filterMetricsPrefix+key, n)
+}
+
+func (m *filterMetrics) UpdateGauge(key string, value float64) {
+	m.impl.UpdateGauge(filterMetricsPrefix+key, value)
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/metrics"
+)
+
+// records the keys of the custom metrics, and discards the rest
+type recordingMetrics struct {
+	metrics.Metrics
+	mx       sync.Mutex
+	counters map[string]int64
+	gauges   map[string]float64
+	timers   map[string]int
+}
+
+func (m *recordingMetrics) MeasureSince(key string, _ time.Time) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.timers[key]++
+}
+
+func (m *recordingMetrics) IncCounter(key string) {
+	m.IncCounterBy(key, 1)
+}
+
+func (m *recordingMetrics) IncCounterBy(key string, n int64) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.counters[key] += n
+}
+
+func (m *recordingMetrics) UpdateGauge(key string, value float64) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.gauges[key] = value
+}
+
+type metricsFilter struct{}
+
+func (f *metricsFilter) Name() string { return "emitMetrics" }
+
+func (f *metricsFilter) CreateFilter([]interface{}) (filters.Filter, error) { return f, nil }
+
+func (f *metricsFilter) Request(ctx filters.FilterContext) {
+	start := time.Now()
+	ctx.Metrics().IncCounter("emit.requests")
+	ctx.Metrics().IncCounterBy("emit.bytes", 42)
+	ctx.Metrics().UpdateGauge("emit.ratio", 0.5)
+	ctx.Metrics().MeasureSince("emit.request", start)
+}
+
+func (f *metricsFilter) Response(filters.FilterContext) {}
+
+func TestFilterMetrics(t *testing.T) {
+	m := &recordingMetrics{
+		Metrics:  metrics.Void,
+		counters: make(map[string]int64),
+		gauges:   make(map[string]float64),
+		timers:   make(map[string]int),
+	}
+
+	fr := make(filters.Registry)
+	fr.Register(&metricsFilter{})
+	tp, err := newTestProxyWithFiltersAndParams(fr, `* -> emitMetrics() -> <shunt>`, Params{
+		CloseIdleConnsPeriod: -1,
+		Metrics:              m,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	for i := 0; i < 2; i++ {
+		tp.proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "https://www.example.org", nil))
+	}
+
+	if m.counters["custom.emit.requests"] != 2 || m.counters["custom.emit.bytes"] != 84 {
+		t.Error("invalid counters", m.counters)
+	}
+
+	if m.gauges["custom.emit.ratio"] != 0.5 {
+		t.Error("invalid gauges", m.gauges)
+	}
+
+	if m.timers["custom.emit.request"] != 2 {
+		t.Error("invalid timers", m.timers)
+	}
+}
+
+func TestDefaultMetrics(t *testing.T) {
+	tp, err := newTestProxyWithFiltersAndParams(nil, `* -> <shunt>`, Params{CloseIdleConnsPeriod: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+	if tp.proxy.metrics != metrics.Default {
+		t.Error("failed to use the default metrics")
+	}
+
+	r, _ := http.NewRequest("GET", "https://www.example.org", nil)
+	ctx := newContext(httptest.NewRecorder(), r, false)
+	defer putContext(ctx)
+	ctx.metrics = tp.proxy.filterMetrics
+	if ctx.Metrics().(*filterMetrics).impl != metrics.Default {
+		t.Error("failed to provide the default metrics to the filters")
+	}
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"net/http"
+	"sync"
+)
+
+// the header maps with more keys than this are not reused, so that a few
+// large requests don't keep large maps in the pool
+const maxPooledHeaderSize = 64
+
+// pools of the objects allocated for every request, reused across the
+// requests to reduce the pressure on the garbage collector
+var (
+	contextPool = sync.Pool{New: func() interface{} { return &context{} }}
+	headerPool  = sync.Pool{New: func() interface{} { return make(http.Header) }}
+	bufferPool  = sync.Pool{New: func() interface{} { return new([proxyBufferSize]byte) }}
+)
+
+func getHeader() http.Header {
+	return headerPool.Get().(http.Header)
+}
+
+func putHeader(h http.Header) {
+	if len(h) > maxPooledHeaderSize {
+		return
+	}
+
+	for k := range h {
+		delete(h, k)
+	}
+
+	headerPool.Put(h)
+}
+
+func getBuffer() *[proxyBufferSize]byte {
+	return bufferPool.Get().(*[proxyBufferSize]byte)
+}
+
+func putBuffer(b *[proxyBufferSize]byte) {
+	bufferPool.Put(b)
+}
+
+func getContext() *context {
+	return contextPool.Get().(*context)
+}
+
+// returns the context to the pool, after the request was served. The
+// state bag is kept, and emptied.
+func putContext(c *context) {
+	if c.outgoingHeader != nil {
+		putHeader(c.outgoingHeader)
+	}
+
+	stateBag := c.stateBag
+	for k := range stateBag {
+		delete(stateBag, k)
+	}
+
+	*c = context{stateBag: stateBag}
+	contextPool.Put(c)
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContextReset(t *testing.T) {
+	r, _ := http.NewRequest("GET", "http://www.example.org/foo", nil)
+	c := newContext(httptest.NewRecorder(), r, true)
+	c.stateBag["foo"] = "bar"
+	c.timing.lookup = 42
+	c.setOutgoingHeader(getHeader())
+	c.outgoingHeader.Set("X-Foo", "bar")
+	stateBag := c.stateBag
+
+	putContext(c)
+
+	if c.request != nil || c.originalRequest != nil || c.outgoingHeader != nil || c.ownTiming.lookup != 0 {
+		t.Error("context not reset")
+	}
+
+	if len(stateBag) != 0 {
+		t.Error("state bag not emptied")
+	}
+
+	c = newContext(httptest.NewRecorder(), r, false)
+	defer putContext(c)
+	if len(c.stateBag) != 0 || c.timing != &c.ownTiming || c.timing.lookup != 0 {
+		t.Error("invalid context")
+	}
+}
+
+func TestHeaderPool(t *testing.T) {
+	h := getHeader()
+	h.Set("X-Foo", "bar")
+	putHeader(h)
+	if len(h) != 0 {
+		t.Error("header not emptied")
+	}
+}
+
+func benchmarkServe(b *testing.B, doc string) {
+	tp, err := newTestProxyWithFiltersAndParams(nil, doc, Params{CloseIdleConnsPeriod: -1})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer tp.close()
+
+	r, _ := http.NewRequest("GET", "http://www.example.org/foo", nil)
+	r.Header.Set("X-Test", "foo")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		tp.proxy.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			b.Fatal("unexpected status", w.Code)
+		}
+	}
+}
+
+func BenchmarkServeShunt(b *testing.B) {
+	benchmarkServe(b, `Path("/foo") -> setRequestHeader("X-Foo", "bar") -> status(200) -> <shunt>`)
+}
+
+func BenchmarkServeBackend(b *testing.B) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("Hello, world!"))
+	}))
+	defer backend.Close()
+
+	benchmarkServe(b, `Path("/foo") -> setRequestHeader("X-Foo", "bar") -> "`+backend.URL+`"`)
+}
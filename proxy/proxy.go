@@ -10,12 +10,18 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"runtime/debug"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/errorreport"
 	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/events"
+	"github.com/zalando/skipper/filters/flowid"
+	"github.com/zalando/skipper/filters/servertiming"
 	"github.com/zalando/skipper/metrics"
 	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/tracing"
 )
 
 const (
@@ -110,6 +116,58 @@ type Params struct {
 	// -1. Note, that disabling looping by this option, may result
 	// wrong routing depending on the current configuration.
 	MaxLoopbacks int
+
+	// Tracer used to create the spans of the proxied requests. When
+	// not set, tracing.Noop is used.
+	Tracer tracing.Tracer
+
+	// The metrics collected by the proxy, and provided to the filters.
+	// When not set, metrics.Default is used.
+	Metrics metrics.Metrics
+
+	// When set, the proxy generates a flow id for the requests that
+	// don't have a valid X-Flow-Id header. The flow id is used as
+	// the correlation ID of the request in the access log, the error
+	// logs and the trace spans, and it is forwarded to the backends.
+	FlowIDGenerator flowid.Generator
+
+	// When set, the proxy sets the Server-Timing header of every
+	// response, with the durations of the route lookup, the filters
+	// and the backend request. Otherwise, it is set only for the
+	// routes with the serverTiming filter.
+	ServerTiming bool
+
+	// Reporter used to send the panics, the filter panics and the
+	// bursts of 5xx responses to an error reporting service. When
+	// not set, errorreport.Noop is used.
+	ErrorReporter errorreport.Reporter
+
+	// When set, a backend_unhealthy event is published on the bus
+	// when connecting to a backend fails, at most once in every 10
+	// seconds per backend.
+	EventBus *events.Bus
+
+	// The TLS configuration of the connections to the backends, e.g.
+	// restricting the TLS versions and the cipher suites. When not
+	// set, the defaults are used.
+	TLSClientConfig *tls.Config
+
+	// When set, the security header policy is applied to all the
+	// responses, except for the routes with the
+	// disableSecurityHeaders filter.
+	SecurityHeaders *SecurityHeaders
+
+	// The settings of the connections to specific backends, mapped by
+	// the host of the backend, as in the route, e.g. api.example.org
+	// or 10.0.0.1:8080. Each backend gets its own connection pool,
+	// and the backends not listed here use the default settings.
+	BackendTransports map[string]BackendTransport
+
+	// When set, it is called with every route matched by the proxy,
+	// including the priority routes and the routes matched after a
+	// loopback, before the filters are applied. When it returns false,
+	// the request is responded with 429 and a Retry-After header.
+	RateLimit func(*routing.Route) bool
 }
 
 var errMaxLoopbacksReached = errors.New("max loopbacks reached")
@@ -147,14 +205,23 @@ type flusherWriter interface {
 // initializing, see the WithParams the constructor and Params.
 type Proxy struct {
 	routing             *routing.Routing
-	roundTripper        *http.Transport
+	transports          *transports
 	priorityRoutes      []PriorityRoute
 	flags               Flags
-	metrics             *metrics.Metrics
+	metrics             metrics.Metrics
+	filterMetrics       *filterMetrics
 	quit                chan struct{}
 	flushInterval       time.Duration
 	experimentalUpgrade bool
 	maxLoops            int
+	tracer              tracing.Tracer
+	flowIDGenerator     flowid.Generator
+	serverTiming        bool
+	errorReporter       errorreport.Reporter
+	eventBus            *events.Bus
+	unhealthyBackends   unhealthyBackends
+	securityHeaders     *SecurityHeaders
+	rateLimit           func(*routing.Route) bool
 }
 
 // proxyError is used to wrap errors during proxying and to indicate
@@ -192,7 +259,7 @@ func copyHeader(to, from http.Header) {
 }
 
 func cloneHeader(h http.Header) http.Header {
-	hh := make(http.Header)
+	hh := make(http.Header, len(h))
 	copyHeader(hh, h)
 	return hh
 }
@@ -200,7 +267,9 @@ func cloneHeader(h http.Header) http.Header {
 // copies a stream with flushing on every successful read operation
 // (similar to io.Copy but with flushing)
 func copyStream(to flusherWriter, from io.Reader) error {
-	b := make([]byte, proxyBufferSize)
+	pb := getBuffer()
+	defer putBuffer(pb)
+	b := pb[:]
 
 	for {
 		l, rerr := from.Read(b)
@@ -224,7 +293,8 @@ func copyStream(to flusherWriter, from io.Reader) error {
 }
 
 // creates an outgoing http request to be forwarded to the route endpoint
-// based on the augmented incoming request
+// based on the augmented incoming request. The header of the request is
+// taken from the pool.
 func mapRequest(r *http.Request, rt *routing.Route, host string) (*http.Request, error) {
 	u := r.URL
 	u.Scheme = rt.Scheme
@@ -240,7 +310,8 @@ func mapRequest(r *http.Request, rt *routing.Route, host string) (*http.Request,
 		return nil, err
 	}
 
-	rr.Header = cloneHeader(r.Header)
+	rr.Header = getHeader()
+	copyHeader(rr.Header, r.Header)
 	rr.Host = host
 
 	// If there is basic auth configured int the URL we add them as headers
@@ -273,14 +344,14 @@ func WithParams(p Params) *Proxy {
 		p.CloseIdleConnsPeriod = DefaultCloseIdleConnsPeriod
 	}
 
-	tr := &http.Transport{MaxIdleConnsPerHost: p.IdleConnectionsPerHost}
+	tr := newTransports(p)
 	quit := make(chan struct{})
 	if p.CloseIdleConnsPeriod > 0 {
 		go func() {
 			for {
 				select {
 				case <-time.After(p.CloseIdleConnsPeriod):
-					tr.CloseIdleConnections()
+					tr.closeIdle()
 				case <-quit:
 					return
 				}
@@ -288,15 +359,23 @@ func WithParams(p Params) *Proxy {
 		}()
 	}
 
-	if p.Flags.Insecure() {
-		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	m := p.Metrics
+	if m == nil {
+		m = metrics.Default
 	}
 
-	m := metrics.Default
 	if p.Flags.Debug() {
 		m = metrics.Void
 	}
 
+	if p.Tracer == nil || p.Flags.Debug() {
+		p.Tracer = tracing.Noop
+	}
+
+	if p.ErrorReporter == nil || p.Flags.Debug() {
+		p.ErrorReporter = errorreport.Noop
+	}
+
 	if p.MaxLoopbacks == 0 {
 		p.MaxLoopbacks = DefaultMaxLoopbacks
 	} else if p.MaxLoopbacks < 0 {
@@ -305,14 +384,22 @@ func WithParams(p Params) *Proxy {
 
 	return &Proxy{
 		routing:             p.Routing,
-		roundTripper:        tr,
+		transports:          tr,
 		priorityRoutes:      p.PriorityRoutes,
 		flags:               p.Flags,
 		metrics:             m,
+		filterMetrics:       &filterMetrics{impl: m},
 		quit:                quit,
 		flushInterval:       p.FlushInterval,
 		experimentalUpgrade: p.ExperimentalUpgrade,
 		maxLoops:            p.MaxLoopbacks,
+		tracer:              p.Tracer,
+		flowIDGenerator:     p.FlowIDGenerator,
+		serverTiming:        p.ServerTiming,
+		errorReporter:       p.ErrorReporter,
+		eventBus:            p.EventBus,
+		securityHeaders:     p.SecurityHeaders,
+		rateLimit:           p.RateLimit,
 	}
 }
 
@@ -326,17 +413,20 @@ func tryCatch(p func(), onErr func(err interface{})) {
 	p()
 }
 
-// applies filters to a request
+// applies filters to a request, and returns the filters that were
+// applied, to be applied to the response
 func (p *Proxy) applyFiltersToRequest(f []*routing.RouteFilter, ctx *context) []*routing.RouteFilter {
 	filtersStart := time.Now()
 
-	var filters = make([]*routing.RouteFilter, 0, len(f))
+	var applied int
 	for _, fi := range f {
 		start := time.Now()
+		span := p.startSpan(ctx, fi.Name).SetTag(tracing.TagPhase, "request")
 		tryCatch(func() {
 			fi.Request(ctx)
 			p.metrics.MeasureFilterRequest(fi.Name, start)
 		}, func(err interface{}) {
+			span.SetTag(tracing.TagError, true)
 			if p.flags.Debug() {
 				// these errors are collected for the debug mode to be able
 				// to report in the response which filters failed.
@@ -344,17 +434,19 @@ func (p *Proxy) applyFiltersToRequest(f []*routing.RouteFilter, ctx *context) []
 				return
 			}
 
-			log.Errorf("error while processing filter during request: %s: %v", fi.Name, err)
+			ctx.logger().Errorf("error while processing filter during request: %s: %v", fi.Name, err)
+			p.reportFilterPanic(ctx, fi.Name, err)
 		})
 
-		filters = append(filters, fi)
+		span.Finish()
+		applied++
 		if ctx.deprecatedShunted() || ctx.shunted() {
 			break
 		}
 	}
 
 	p.metrics.MeasureAllFiltersRequest(ctx.route.Id, filtersStart)
-	return filters
+	return f[:applied]
 }
 
 // applies filters to a response in reverse order
@@ -365,10 +457,12 @@ func (p *Proxy) applyFiltersToResponse(filters []*routing.RouteFilter, ctx *cont
 	for i := range filters {
 		fi := filters[count-1-i]
 		start := time.Now()
+		span := p.startSpan(ctx, fi.Name).SetTag(tracing.TagPhase, "response")
 		tryCatch(func() {
 			fi.Response(ctx)
 			p.metrics.MeasureFilterResponse(fi.Name, start)
 		}, func(err interface{}) {
+			span.SetTag(tracing.TagError, true)
 			if p.flags.Debug() {
 				// these errors are collected for the debug mode to be able
 				// to report in the response which filters failed.
@@ -376,8 +470,11 @@ func (p *Proxy) applyFiltersToResponse(filters []*routing.RouteFilter, ctx *cont
 				return
 			}
 
-			log.Errorf("error while processing filters during response: %s: %v", fi.Name, err)
+			ctx.logger().Errorf("error while processing filters during response: %s: %v", fi.Name, err)
+			p.reportFilterPanic(ctx, fi.Name, err)
 		})
+
+		span.Finish()
 	}
 
 	p.metrics.MeasureAllFiltersResponse(ctx.route.Id, filtersStart)
@@ -389,6 +486,68 @@ func addBranding(headerMap http.Header) {
 	headerMap.Set("Server", "Skipper")
 }
 
+// called from the recovering function, to get the stack of the panic
+func (p *Proxy) reportFilterPanic(ctx *context, filter string, err interface{}) {
+	p.errorReporter.Report(&errorreport.Event{
+		Kind:    errorreport.KindFilterPanic,
+		Message: fmt.Sprint(err),
+		RouteID: ctx.route.Id,
+		Filter:  filter,
+		FlowID:  ctx.flowID,
+		Stack:   string(debug.Stack()),
+	})
+}
+
+// reports the panics of the proxy, and lets them propagate to the
+// http server
+func (p *Proxy) reportPanic(ctx *context) {
+	err := recover()
+	if err == nil {
+		return
+	}
+
+	if err != http.ErrAbortHandler {
+		e := &errorreport.Event{
+			Kind:    errorreport.KindPanic,
+			Message: fmt.Sprint(err),
+			FlowID:  ctx.flowID,
+			Stack:   string(debug.Stack()),
+		}
+
+		if ctx.route != nil {
+			e.RouteID = ctx.route.Id
+		}
+
+		p.errorReporter.Report(e)
+	}
+
+	panic(err)
+}
+
+// takes the flow id of the incoming request, or generates one, when
+// the proxy is configured with a generator and the incoming one is not
+// valid
+func (p *Proxy) initFlowID(ctx *context) {
+	id := ctx.request.Header.Get(flowid.HeaderName)
+	if p.flowIDGenerator != nil && !p.flowIDGenerator.IsValid(id) {
+		var err error
+		id, err = p.flowIDGenerator.Generate()
+		if err != nil {
+			log.Errorf("error while generating flow id: %v", err)
+			return
+		}
+
+		ctx.request.Header.Set(flowid.HeaderName, id)
+	}
+
+	ctx.setFlowID(id)
+}
+
+// starts a child span of the ingress span of the request
+func (p *Proxy) startSpan(ctx *context, operation string) tracing.Span {
+	return p.tracer.StartSpan(operation, ctx.span.Context())
+}
+
 func (p *Proxy) lookupRoute(r *http.Request) (rt *routing.Route, params map[string]string) {
 	for _, prt := range p.priorityRoutes {
 		rt, params = prt.Match(r)
@@ -403,6 +562,7 @@ func (p *Proxy) lookupRoute(r *http.Request) (rt *routing.Route, params map[stri
 // send a premature error response
 func (p *Proxy) sendError(c *context, id string, code int) {
 	addBranding(c.responseWriter.Header())
+	p.applySecurityHeaders(c, c.responseWriter.Header())
 	http.Error(c.responseWriter, http.StatusText(code), code)
 	p.metrics.MeasureServe(
 		id,
@@ -411,13 +571,14 @@ func (p *Proxy) sendError(c *context, id string, code int) {
 		code,
 		c.startServe,
 	)
+	p.metrics.MeasureServeLatency(id, c.request.Method, code, c.startServe, c.sampledTraceID())
 }
 
 func (p *Proxy) makeUpgradeRequest(ctx *context, route *routing.Route, req *http.Request) error {
 	// have to parse url again, because path is not copied by mapRequest
 	backendURL, err := url.Parse(route.Backend)
 	if err != nil {
-		log.Errorf("can not parse backend %s, caused by: %s", route.Backend, err)
+		ctx.logger().Errorf("can not parse backend %s, caused by: %s", route.Backend, err)
 		return &proxyError{
 			err:  err,
 			code: http.StatusBadGateway,
@@ -430,7 +591,7 @@ func (p *Proxy) makeUpgradeRequest(ctx *context, route *routing.Route, req *http
 		backendAddr:     backendURL,
 		reverseProxy:    reverseProxy,
 		insecure:        p.flags.Insecure(),
-		tlsClientConfig: p.roundTripper.TLSClientConfig,
+		tlsClientConfig: p.transports.get(route.Scheme, route.Host).transport.TLSClientConfig,
 	}
 
 	upgradeProxy.serveHTTP(ctx.responseWriter, req)
@@ -441,10 +602,12 @@ func (p *Proxy) makeUpgradeRequest(ctx *context, route *routing.Route, req *http
 func (p *Proxy) makeBackendRequest(ctx *context) (*http.Response, error) {
 	req, err := mapRequest(ctx.request, ctx.route, ctx.outgoingHost)
 	if err != nil {
-		log.Errorf("could not map backend request, caused by: %v", err)
+		ctx.logger().Errorf("could not map backend request, caused by: %v", err)
 		return nil, err
 	}
 
+	ctx.setOutgoingHeader(req.Header)
+
 	if p.experimentalUpgrade && isUpgradeRequest(req) {
 		if err := p.makeUpgradeRequest(ctx, ctx.route, req); err != nil {
 			return nil, err
@@ -454,10 +617,24 @@ func (p *Proxy) makeBackendRequest(ctx *context) (*http.Response, error) {
 		return nil, &proxyError{handled: true}
 	}
 
-	response, err := p.roundTripper.RoundTrip(req)
+	span := p.startSpan(ctx, tracing.OperationBackend).
+		SetTag(tracing.TagSpanKind, "client").
+		SetTag(tracing.TagRouteID, ctx.route.Id).
+		SetTag(tracing.TagBackendHost, ctx.route.Host).
+		SetTag(tracing.TagHTTPURL, req.URL.String())
+	if ctx.flowID != "" {
+		span.SetTag(tracing.TagFlowID, ctx.flowID)
+	}
+
+	defer span.Finish()
+	p.tracer.Inject(span.Context(), req.Header)
+
+	response, err := p.transports.acquire(ctx.route.Scheme, ctx.route.Host).roundTrip(req)
 	if err != nil {
-		log.Errorf("error during backend roundtrip: %s: %v", ctx.route.Id, err)
+		span.SetTag(tracing.TagError, true)
+		ctx.logger().Errorf("error during backend roundtrip: %s: %v", ctx.route.Id, err)
 		if _, ok := err.(net.Error); ok {
+			p.publishBackendUnhealthy(ctx, err)
 			err = &proxyError{
 				err:  err,
 				code: http.StatusServiceUnavailable,
@@ -467,6 +644,7 @@ func (p *Proxy) makeBackendRequest(ctx *context) (*http.Response, error) {
 		return nil, err
 	}
 
+	span.SetTag(tracing.TagHTTPStatusCode, response.StatusCode)
 	return response, nil
 }
 
@@ -478,8 +656,11 @@ func (p *Proxy) do(ctx *context) error {
 	ctx.loopCounter++
 
 	lookupStart := time.Now()
+	lookupSpan := p.startSpan(ctx, tracing.OperationRouteLookup)
 	route, params := p.lookupRoute(ctx.request)
+	lookupSpan.Finish()
 	p.metrics.MeasureRouteLookup(lookupStart)
+	ctx.timing.lookup += time.Since(lookupStart)
 
 	if route == nil {
 		if !p.flags.Debug() {
@@ -492,7 +673,19 @@ func (p *Proxy) do(ctx *context) error {
 
 	ctx.applyRoute(route, params, p.flags.PreserveHost())
 
+	if p.rateLimit != nil && !p.rateLimit(route) {
+		ctx.response = rateLimitedResponse(ctx.request)
+		return nil
+	}
+
+	requestFiltersStart := time.Now()
 	processedFilters := p.applyFiltersToRequest(ctx.route.Filters, ctx)
+	ctx.timing.requestFilters += time.Since(requestFiltersStart)
+
+	// the flowId filter may have set or replaced the flow id
+	if id := ctx.request.Header.Get(flowid.HeaderName); id != "" {
+		ctx.setFlowID(id)
+	}
 
 	if ctx.deprecatedShunted() {
 		log.Debug("deprecated shunting detected in route: %s", ctx.route.Id)
@@ -505,6 +698,9 @@ func (p *Proxy) do(ctx *context) error {
 			return err
 		}
 
+		// the loopback contexts are not pooled, the header of their
+		// outgoing request is returned by the original context
+		ctx.setOutgoingHeader(loopCTX.outgoingHeader)
 		ctx.setResponse(loopCTX.response, p.flags.PreserveOriginal())
 	} else if p.flags.Debug() {
 		debugReq, err := mapRequest(ctx.request, ctx.route, ctx.outgoingHost)
@@ -512,6 +708,8 @@ func (p *Proxy) do(ctx *context) error {
 			return &proxyError{err: err}
 		}
 
+		ctx.setOutgoingHeader(debugReq.Header)
+
 		ctx.outgoingDebugRequest = debugReq
 		ctx.setResponse(&http.Response{Header: make(http.Header)}, p.flags.PreserveOriginal())
 	} else {
@@ -523,11 +721,14 @@ func (p *Proxy) do(ctx *context) error {
 		}
 
 		ctx.setResponse(rsp, p.flags.PreserveOriginal())
+		ctx.timing.backend += time.Since(backendStart)
 		p.metrics.MeasureBackend(ctx.route.Id, backendStart)
 		p.metrics.MeasureBackendHost(ctx.route.Host, backendStart)
 	}
 
+	responseFiltersStart := time.Now()
 	p.applyFiltersToResponse(processedFilters, ctx)
+	ctx.timing.responseFilters += time.Since(responseFiltersStart)
 	return nil
 }
 
@@ -546,12 +747,17 @@ func (p *Proxy) serveResponse(ctx *context) {
 
 	start := time.Now()
 	addBranding(ctx.response.Header)
+	p.applySecurityHeaders(ctx, ctx.response.Header)
+	if p.serverTiming || ctx.stateBag[servertiming.StateBagKey] == true {
+		ctx.response.Header.Add("Server-Timing", ctx.timing.header(start.Sub(ctx.startServe)))
+	}
+
 	copyHeader(ctx.responseWriter.Header(), ctx.response.Header)
 	ctx.responseWriter.WriteHeader(ctx.response.StatusCode)
 	err := copyStream(ctx.responseWriter.(flusherWriter), ctx.response.Body)
 	if err != nil {
 		p.metrics.IncErrorsStreaming(ctx.route.Id)
-		log.Error("error while copying the response stream", err)
+		ctx.logger().Error("error while copying the response stream", err)
 	} else {
 		p.metrics.MeasureResponse(ctx.response.StatusCode, ctx.request.Method, ctx.route.Id, start)
 	}
@@ -560,13 +766,32 @@ func (p *Proxy) serveResponse(ctx *context) {
 // http.Handler implementation
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := newContext(w, r, p.flags.PreserveOriginal())
+	defer putContext(ctx)
 	ctx.startServe = time.Now()
+	ctx.metrics = p.filterMetrics
+
+	parentSpan, _ := p.tracer.Extract(r.Header)
+	ctx.span = p.tracer.StartSpan(tracing.OperationIngress, parentSpan).
+		SetTag(tracing.TagComponent, "skipper").
+		SetTag(tracing.TagSpanKind, "server").
+		SetTag(tracing.TagHTTPMethod, r.Method).
+		SetTag(tracing.TagHTTPURL, r.URL.String()).
+		SetTag(tracing.TagHTTPHost, r.Host)
+	defer ctx.span.Finish()
+
+	defer p.reportPanic(ctx)
+	p.initFlowID(ctx)
+	defer func() {
+		if ctx.flowID != "" {
+			ctx.span.SetTag(tracing.TagFlowID, ctx.flowID)
+		}
+	}()
 
 	defer func() {
 		if ctx.response != nil && ctx.response.Body != nil {
 			err := ctx.response.Body.Close()
 			if err != nil {
-				log.Error("error during closing the response body", err)
+				ctx.logger().Error("error during closing the response body", err)
 			}
 		}
 	}()
@@ -601,14 +826,21 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			ctx.span.SetTag(tracing.TagRouteID, id).
+				SetTag(tracing.TagHTTPStatusCode, code).
+				SetTag(tracing.TagError, true)
 			p.sendError(ctx, id, code)
-			log.Errorf("error while proxying, route %s, status code %d: %v", id, code, err)
+			p.errorReporter.ObserveResponse(id, code)
+			ctx.logger().Errorf("error while proxying, route %s, status code %d: %v", id, code, err)
 		}
 
 		return
 	}
 
+	ctx.span.SetTag(tracing.TagRouteID, ctx.route.Id).
+		SetTag(tracing.TagHTTPStatusCode, ctx.response.StatusCode)
 	p.serveResponse(ctx)
+	p.errorReporter.ObserveResponse(ctx.route.Id, ctx.response.StatusCode)
 	p.metrics.MeasureServe(
 		ctx.route.Id,
 		ctx.metricsHost(),
@@ -616,6 +848,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		ctx.response.StatusCode,
 		ctx.startServe,
 	)
+	p.metrics.MeasureServeLatency(ctx.route.Id, r.Method, ctx.response.StatusCode, ctx.startServe, ctx.sampledTraceID())
 }
 
 // Close causes the proxy to stop closing idle
@@ -1024,3 +1024,55 @@ func TestBranding(t *testing.T) {
 		})
 	}
 }
+
+func TestRateLimitMatchedRoute(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	doc := `
+		open: Path("/open") -> "` + backend.URL + `";
+		limited: Path("/limited") -> "` + backend.URL + `";
+		loop: Path("/loop") -> setPath("/limited") -> <loopback>;
+	`
+
+	var matched []string
+	tp, err := newTestProxyWithFiltersAndParams(nil, doc, Params{
+		CloseIdleConnsPeriod: -1,
+		RateLimit: func(r *routing.Route) bool {
+			matched = append(matched, r.Id)
+			return r.Id != "limited"
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	for _, test := range []struct {
+		path     string
+		expected int
+		matched  []string
+	}{
+		{"/open", http.StatusOK, []string{"open"}},
+		{"/limited", http.StatusTooManyRequests, []string{"limited"}},
+		{"/loop", http.StatusTooManyRequests, []string{"loop", "limited"}},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			matched = nil
+			w := httptest.NewRecorder()
+			tp.proxy.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+			if w.Code != test.expected {
+				t.Error("invalid status", w.Code)
+			}
+
+			if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "1" {
+				t.Error("missing Retry-After")
+			}
+
+			if fmt.Sprint(matched) != fmt.Sprint(test.matched) {
+				t.Error("invalid matched routes", matched)
+			}
+		})
+	}
+}
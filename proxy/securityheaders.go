@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zalando/skipper/filters/securityheaders"
+)
+
+// SecurityHeaders is a proxy level policy of the response headers. It
+// is applied to all the responses, including the error responses of
+// the proxy, except for the routes with the disableSecurityHeaders
+// filter.
+type SecurityHeaders struct {
+
+	// When greater than 0, the Strict-Transport-Security header is
+	// set with this max-age in the responses of the HTTPS requests,
+	// received over TLS, or with X-Forwarded-Proto: https.
+	HSTSMaxAge time.Duration
+
+	// Adds the includeSubDomains directive to the
+	// Strict-Transport-Security header.
+	HSTSIncludeSubdomains bool
+
+	// Adds the preload directive to the Strict-Transport-Security
+	// header.
+	HSTSPreload bool
+
+	// When set, the Server, Via and X-Powered-By headers, revealing
+	// the software of the proxy and the backends, are removed.
+	RemoveFingerprint bool
+
+	// Response headers removed from all the responses, e.g.
+	// X-AspNet-Version.
+	StripHeaders []string
+}
+
+const hstsHeader = "Strict-Transport-Security"
+
+var fingerprintHeaders = []string{"Server", "Via", "X-Powered-By"}
+
+func (s *SecurityHeaders) hstsValue() string {
+	v := fmt.Sprintf("max-age=%d", int64(s.HSTSMaxAge/time.Second))
+	if s.HSTSIncludeSubdomains {
+		v += "; includeSubDomains"
+	}
+
+	if s.HSTSPreload {
+		v += "; preload"
+	}
+
+	return v
+}
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+func (p *Proxy) applySecurityHeaders(ctx *context, h http.Header) {
+	s := p.securityHeaders
+	if s == nil || ctx.stateBag[securityheaders.StateBagKey] == true {
+		return
+	}
+
+	if s.RemoveFingerprint {
+		for _, n := range fingerprintHeaders {
+			h.Del(n)
+		}
+	}
+
+	for _, n := range s.StripHeaders {
+		h.Del(n)
+	}
+
+	if s.HSTSMaxAge > 0 && isHTTPS(ctx.request) {
+		h.Set(hstsHeader, s.hstsValue())
+	}
+}
@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/builtin"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "Apache")
+		w.Header().Set("Via", "1.1 cache")
+		w.Header().Set("X-Aspnet-Version", "4.0")
+		w.Header().Set("X-Custom", "foo")
+	}))
+	defer backend.Close()
+
+	doc := `
+		default: Path("/") -> "` + backend.URL + `";
+		optOut: Path("/opt-out") -> disableSecurityHeaders() -> "` + backend.URL + `";
+		failing: Path("/failing") -> "http://127.0.0.1:1";
+	`
+
+	tp, err := newTestProxyWithFiltersAndParams(builtin.MakeRegistry(), doc, Params{
+		CloseIdleConnsPeriod: -1,
+		SecurityHeaders: &SecurityHeaders{
+			HSTSMaxAge:            365 * 24 * time.Hour,
+			HSTSIncludeSubdomains: true,
+			RemoveFingerprint:     true,
+			StripHeaders:          []string{"X-AspNet-Version"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	const hsts = "max-age=31536000; includeSubDomains"
+	for _, test := range []struct {
+		title    string
+		path     string
+		https    bool
+		expected map[string]string
+	}{{
+		title: "applied",
+		path:  "/",
+		https: true,
+		expected: map[string]string{
+			"Strict-Transport-Security": hsts,
+			"Server":                    "",
+			"Via":                       "",
+			"X-Powered-By":              "",
+			"X-Aspnet-Version":          "",
+			"X-Custom":                  "foo",
+		},
+	}, {
+		title: "no HSTS over plain HTTP",
+		path:  "/",
+		expected: map[string]string{
+			"Strict-Transport-Security": "",
+			"Server":                    "",
+		},
+	}, {
+		title: "opt-out",
+		path:  "/opt-out",
+		https: true,
+		expected: map[string]string{
+			"Strict-Transport-Security": "",
+			"Server":                    "Skipper",
+			"Via":                       "1.1 cache",
+			"X-Aspnet-Version":          "4.0",
+		},
+	}, {
+		title: "error response",
+		path:  "/failing",
+		https: true,
+		expected: map[string]string{
+			"Strict-Transport-Security": hsts,
+			"Server":                    "",
+		},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "http://www.example.org"+test.path, nil)
+			if test.https {
+				r.Header.Set("X-Forwarded-Proto", "https")
+			}
+
+			w := httptest.NewRecorder()
+			tp.proxy.ServeHTTP(w, r)
+			for k, v := range test.expected {
+				if got := w.Header().Get(k); got != v {
+					t.Errorf("invalid header %s, got: %q, expected: %q", k, got, v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+var serverTimingFormat = regexp.MustCompile(
+	`^route;desc="route lookup";dur=\d+\.\d{3}, ` +
+		`request-filters;desc="request filters";dur=\d+\.\d{3}, ` +
+		`backend;desc="backend";dur=\d+\.\d{3}, ` +
+		`response-filters;desc="response filters";dur=\d+\.\d{3}, ` +
+		`total;desc="total";dur=\d+\.\d{3}$`)
+
+func TestServerTiming(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Server-Timing", "db;dur=42")
+	}))
+	defer backend.Close()
+
+	doc := `
+		timed: Path("/timed") -> serverTiming() -> "` + backend.URL + `";
+		untimed: Path("/untimed") -> "` + backend.URL + `"`
+
+	for _, test := range []struct {
+		title    string
+		global   bool
+		path     string
+		expected bool
+	}{
+		{"filter", false, "/timed", true},
+		{"no filter", false, "/untimed", false},
+		{"global", true, "/untimed", true},
+	} {
+		t.Run(test.title, func(t *testing.T) {
+			tp, err := newTestProxyWithFiltersAndParams(nil, doc, Params{ServerTiming: test.global, CloseIdleConnsPeriod: -1})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer tp.close()
+
+			r, _ := http.NewRequest("GET", "http://www.example.org"+test.path, nil)
+			w := httptest.NewRecorder()
+			tp.proxy.ServeHTTP(w, r)
+
+			h := w.Header()["Server-Timing"]
+			if h[0] != "db;dur=42" {
+				t.Error("backend header not preserved", h)
+			}
+
+			if !test.expected {
+				if len(h) != 1 {
+					t.Error("unexpected header", h)
+				}
+
+				return
+			}
+
+			if len(h) != 2 || !serverTimingFormat.MatchString(h[1]) {
+				t.Error("invalid header", h)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zalando/skipper/filters/builtin"
+)
+
+func TestUpstreamTLSConfig(t *testing.T) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	backend.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	backend.StartTLS()
+	defer backend.Close()
+
+	for _, test := range []struct {
+		title      string
+		minVersion uint16
+		expected   int
+	}{{
+		title:    "default",
+		expected: http.StatusOK,
+	}, {
+		title:      "version accepted",
+		minVersion: tls.VersionTLS12,
+		expected:   http.StatusOK,
+	}, {
+		title:      "version rejected",
+		minVersion: tls.VersionTLS13,
+		expected:   http.StatusServiceUnavailable,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			params := Params{
+				Flags:                Insecure,
+				CloseIdleConnsPeriod: -1,
+			}
+
+			if test.minVersion != 0 {
+				params.TLSClientConfig = &tls.Config{MinVersion: test.minVersion}
+			}
+
+			tp, err := newTestProxyWithFiltersAndParams(builtin.MakeRegistry(), `* -> "`+backend.URL+`"`, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer tp.close()
+
+			r, _ := http.NewRequest("GET", "https://www.example.org/", nil)
+			w := httptest.NewRecorder()
+			tp.proxy.ServeHTTP(w, r)
+			if w.Code != test.expected {
+				t.Errorf("invalid status code, got: %d, expected: %d", w.Code, test.expected)
+			}
+
+			if params.TLSClientConfig != nil && params.TLSClientConfig.InsecureSkipVerify {
+				t.Error("the TLS configuration of the params was modified")
+			}
+		})
+	}
+}
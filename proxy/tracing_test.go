@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/tracing"
+)
+
+func TestTracingPropagatesContext(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer collector.Close()
+
+	traceHeader := make(chan string, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceHeader <- r.Header.Get(tracing.JaegerHeader)
+	}))
+	defer backend.Close()
+
+	tr, err := tracing.New(tracing.Options{Tracer: tracing.JaegerName, Endpoint: collector.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tr.Close()
+
+	doc := `* -> setRequestHeader("X-Foo", "bar") -> "` + backend.URL + `"`
+	tp, err := newTestProxyWithFiltersAndParams(nil, doc, Params{Tracer: tr, CloseIdleConnsPeriod: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	r, _ := http.NewRequest("GET", "http://www.example.org/foo", nil)
+	r.Header.Set(tracing.JaegerHeader, "abc:1:0:1")
+	tp.proxy.ServeHTTP(httptest.NewRecorder(), r)
+
+	select {
+	case h := <-traceHeader:
+		parts := strings.Split(h, ":")
+		if len(parts) != 4 || parts[0] != "0000000000000abc" || parts[1] == "1" || parts[3] != "1" {
+			t.Error("failed to propagate the span context", h)
+		}
+	case <-time.After(time.Second):
+		t.Error("backend not called")
+	}
+}
@@ -0,0 +1,313 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BackendTransport contains the settings of the connections to a
+// backend host. The zero values mean the defaults of the proxy.
+type BackendTransport struct {
+
+	// Same as net/http.Transport.MaxIdleConnsPerHost. Defaults to
+	// Params.IdleConnectionsPerHost.
+	MaxIdleConnsPerHost int
+
+	// The maximum number of the connections to the backend, including
+	// the ones in use. When 0, the connections are not limited.
+	MaxConnsPerHost int
+
+	// The timeout of establishing a connection.
+	DialTimeout time.Duration
+
+	// The timeout of the TLS handshake.
+	TLSHandshakeTimeout time.Duration
+
+	// The timeout of waiting for the response header, after the
+	// request was sent.
+	ResponseHeaderTimeout time.Duration
+
+	// How long the idle connections are kept open.
+	IdleConnTimeout time.Duration
+
+	// The TLS configuration of the connections, e.g. the client
+	// certificates for mTLS. Defaults to Params.TLSClientConfig.
+	TLSClientConfig *tls.Config
+
+	// When set, only HTTP/1.1 is used with the TLS backends. By
+	// default, HTTP/2 is attempted, the same way as by the default
+	// transport of net/http.
+	DisableHTTP2 bool
+}
+
+// TransportStats contains the state of the connection pool of a
+// backend.
+type TransportStats struct {
+	Scheme string `json:"scheme"`
+	Host   string `json:"host"`
+
+	// The number of the currently open connections.
+	OpenConnections int64 `json:"open_connections"`
+
+	// The number of the connections established, and the number of
+	// the failed attempts.
+	Dials      int64 `json:"dials"`
+	DialErrors int64 `json:"dial_errors"`
+
+	// The number of the requests sent, and the number of the requests
+	// currently waiting for the response header.
+	Requests       int64 `json:"requests"`
+	ActiveRequests int64 `json:"active_requests"`
+}
+
+type transportKey struct {
+	scheme, host string
+}
+
+// a transport dedicated to a single backend, counting its connections
+// and requests
+type backendTransport struct {
+	// accessed atomically, first for the alignment on 32-bit platforms
+	open, dials, dialErrors, requests, active int64
+
+	// accessed only when closing the idle connections
+	lastRequests int64
+
+	key       transportKey
+	transport *http.Transport
+}
+
+// a connection decrementing the open connections of the transport, when
+// closed
+type countedConn struct {
+	net.Conn
+	closed    int32
+	transport *backendTransport
+}
+
+// The transports are created for each backend, on the first request,
+// and cached, so that the backends don't share the connection pool, and
+// they can be tuned separately.
+type transports struct {
+	mx       sync.RWMutex
+	defaults BackendTransport
+	tuning   map[string]BackendTransport
+	insecure bool
+	cache    map[transportKey]*backendTransport
+}
+
+func (c *countedConn) Close() error {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		atomic.AddInt64(&c.transport.open, -1)
+	}
+
+	return c.Conn.Close()
+}
+
+func newTransports(p Params) *transports {
+	tuning := make(map[string]BackendTransport)
+	for host, bt := range p.BackendTransports {
+		tuning[host] = bt
+	}
+
+	return &transports{
+		defaults: BackendTransport{
+			MaxIdleConnsPerHost: p.IdleConnectionsPerHost,
+			TLSClientConfig:     p.TLSClientConfig,
+		},
+		tuning:   tuning,
+		insecure: p.Flags.Insecure(),
+		cache:    make(map[transportKey]*backendTransport),
+	}
+}
+
+func (t *transports) settings(host string) BackendTransport {
+	s, ok := t.tuning[host]
+	if !ok {
+		return t.defaults
+	}
+
+	if s.MaxIdleConnsPerHost <= 0 {
+		s.MaxIdleConnsPerHost = t.defaults.MaxIdleConnsPerHost
+	}
+
+	if s.TLSClientConfig == nil {
+		s.TLSClientConfig = t.defaults.TLSClientConfig
+	}
+
+	return s
+}
+
+func (t *transports) newTransport(key transportKey) *backendTransport {
+	s := t.settings(key.host)
+	bt := &backendTransport{key: key}
+	dialer := &net.Dialer{Timeout: s.DialTimeout}
+	bt.transport = &http.Transport{
+		DialContext:           bt.dialer(dialer),
+		MaxIdleConnsPerHost:   s.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       s.MaxConnsPerHost,
+		TLSHandshakeTimeout:   s.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: s.ResponseHeaderTimeout,
+		IdleConnTimeout:       s.IdleConnTimeout,
+		ForceAttemptHTTP2:     !s.DisableHTTP2,
+	}
+
+	if s.TLSClientConfig != nil {
+		bt.transport.TLSClientConfig = s.TLSClientConfig.Clone()
+	}
+
+	if t.insecure {
+		if bt.transport.TLSClientConfig == nil {
+			bt.transport.TLSClientConfig = &tls.Config{}
+		}
+
+		bt.transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+
+	return bt
+}
+
+// returns the transport of a backend, creating it when necessary. When
+// acquire is set, the transport is marked as in use, under the same lock
+// as the lookup, so that closeIdle doesn't drop it before the request
+// is sent. It needs to be released by roundTrip.
+func (t *transports) lookup(scheme, host string, acquire bool) *backendTransport {
+	key := transportKey{scheme: scheme, host: host}
+
+	t.mx.RLock()
+	bt, ok := t.cache[key]
+	if ok && acquire {
+		atomic.AddInt64(&bt.active, 1)
+	}
+
+	t.mx.RUnlock()
+	if ok {
+		return bt
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	bt, ok = t.cache[key]
+	if !ok {
+		bt = t.newTransport(key)
+		t.cache[key] = bt
+	}
+
+	if acquire {
+		atomic.AddInt64(&bt.active, 1)
+	}
+
+	return bt
+}
+
+// returns the transport of a backend, e.g. to read its settings
+func (t *transports) get(scheme, host string) *backendTransport {
+	return t.lookup(scheme, host, false)
+}
+
+// returns the transport of a backend, marked as in use
+func (t *transports) acquire(scheme, host string) *backendTransport {
+	return t.lookup(scheme, host, true)
+}
+
+// closes the idle connections, and drops the transports that were not
+// used since the last call, and are not in use
+func (t *transports) closeIdle() {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	for key, bt := range t.cache {
+		bt.transport.CloseIdleConnections()
+		requests := atomic.LoadInt64(&bt.requests)
+		if requests == bt.lastRequests && atomic.LoadInt64(&bt.open) == 0 && atomic.LoadInt64(&bt.active) == 0 {
+			delete(t.cache, key)
+			continue
+		}
+
+		bt.lastRequests = requests
+	}
+}
+
+func (t *transports) stats() []TransportStats {
+	t.mx.RLock()
+	s := make([]TransportStats, 0, len(t.cache))
+	for _, bt := range t.cache {
+		s = append(s, bt.stats())
+	}
+
+	t.mx.RUnlock()
+
+	sort.Slice(s, func(i, j int) bool {
+		if s[i].Host == s[j].Host {
+			return s[i].Scheme < s[j].Scheme
+		}
+
+		return s[i].Host < s[j].Host
+	})
+
+	return s
+}
+
+func (bt *backendTransport) dialer(d *net.Dialer) func(stdlibcontext.Context, string, string) (net.Conn, error) {
+	return func(ctx stdlibcontext.Context, network, address string) (net.Conn, error) {
+		conn, err := d.DialContext(ctx, network, address)
+		if err != nil {
+			atomic.AddInt64(&bt.dialErrors, 1)
+			return nil, err
+		}
+
+		atomic.AddInt64(&bt.dials, 1)
+		atomic.AddInt64(&bt.open, 1)
+		return &countedConn{Conn: conn, transport: bt}, nil
+	}
+}
+
+// sends a request on a transport returned by acquire, and releases it
+func (bt *backendTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&bt.requests, 1)
+	defer atomic.AddInt64(&bt.active, -1)
+	return bt.transport.RoundTrip(req)
+}
+
+func (bt *backendTransport) stats() TransportStats {
+	return TransportStats{
+		Scheme:          bt.key.scheme,
+		Host:            bt.key.host,
+		OpenConnections: atomic.LoadInt64(&bt.open),
+		Dials:           atomic.LoadInt64(&bt.dials),
+		DialErrors:      atomic.LoadInt64(&bt.dialErrors),
+		Requests:        atomic.LoadInt64(&bt.requests),
+		ActiveRequests:  atomic.LoadInt64(&bt.active),
+	}
+}
+
+// TransportStats returns the state of the connection pools of the
+// backends, ordered by the host.
+func (p *Proxy) TransportStats() []TransportStats {
+	return p.transports.stats()
+}
+
+// TransportStatsHandler returns a handler serving the state of the
+// connection pools of the backends as JSON.
+func (p *Proxy) TransportStatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(p.TransportStats()); err != nil {
+			log.Error("error while sending the transport stats", err)
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackendTransports(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(60 * time.Millisecond)
+		}
+
+		w.Write([]byte("Hello, world!"))
+	})
+
+	fast := httptest.NewServer(handler)
+	defer fast.Close()
+	slow := httptest.NewServer(handler)
+	defer slow.Close()
+
+	slowURL, _ := url.Parse(slow.URL)
+	tp, err := newTestProxyWithFiltersAndParams(nil, `
+		fast: Host("^fast$") -> "`+fast.URL+`";
+		slow: Host("^slow$") -> "`+slow.URL+`";
+	`, Params{
+		CloseIdleConnsPeriod: -1,
+		BackendTransports: map[string]BackendTransport{
+			slowURL.Host: {ResponseHeaderTimeout: 15 * time.Millisecond},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	request := func(host, path string) int {
+		r, _ := http.NewRequest("GET", "http://"+host+path, nil)
+		w := httptest.NewRecorder()
+		tp.proxy.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := request("fast", "/slow"); code != http.StatusOK {
+			t.Error("unexpected status of the default backend", code)
+		}
+	}
+
+	if code := request("slow", "/fast"); code != http.StatusOK {
+		t.Error("unexpected status of the tuned backend", code)
+	}
+
+	if code := request("slow", "/slow"); code != http.StatusServiceUnavailable {
+		t.Error("failed to time out", code)
+	}
+
+	stats := tp.proxy.TransportStats()
+	if len(stats) != 2 {
+		t.Fatal("invalid number of transports", len(stats))
+	}
+
+	fastURL, _ := url.Parse(fast.URL)
+	for _, s := range stats {
+		switch s.Host {
+		case fastURL.Host:
+			if s.Requests != 3 || s.Dials != 1 || s.OpenConnections != 1 || s.ActiveRequests != 0 {
+				t.Error("invalid stats of the default backend", s)
+			}
+		case slowURL.Host:
+			if s.Requests != 2 || s.Dials != 1 {
+				t.Error("invalid stats of the tuned backend", s)
+			}
+		default:
+			t.Error("unexpected transport", s.Host)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	tp.proxy.TransportStatsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/transports", nil))
+	var served []TransportStats
+	if err := json.NewDecoder(w.Body).Decode(&served); err != nil || len(served) != 2 || served[0] != stats[0] {
+		t.Error("failed to serve the stats", err, served)
+	}
+}
+
+func TestDropUnusedTransports(t *testing.T) {
+	tr := newTransports(Params{IdleConnectionsPerHost: 1})
+	bt := tr.get("https", "api.example.org")
+	if tr.get("https", "api.example.org") != bt {
+		t.Fatal("failed to cache the transport")
+	}
+
+	if tr.get("http", "api.example.org") == bt {
+		t.Fatal("failed to separate the transports by scheme")
+	}
+
+	bt.requests++
+	tr.closeIdle()
+	if s := tr.stats(); len(s) != 1 || s[0].Scheme != "https" {
+		t.Error("failed to keep only the used transport")
+	}
+
+	tr.closeIdle()
+	if len(tr.stats()) != 0 {
+		t.Error("failed to drop the unused transports")
+	}
+}
+
+func TestKeepAcquiredTransports(t *testing.T) {
+	tr := newTransports(Params{IdleConnectionsPerHost: 1})
+	bt := tr.acquire("https", "api.example.org")
+	tr.closeIdle()
+	tr.closeIdle()
+	if tr.get("https", "api.example.org") != bt {
+		t.Fatal("dropped the transport in use")
+	}
+
+	atomic.AddInt64(&bt.active, -1)
+	tr.closeIdle()
+	if len(tr.stats()) != 0 {
+		t.Error("failed to drop the released transport")
+	}
+}
+
+func TestTransportHTTP2(t *testing.T) {
+	tr := newTransports(Params{
+		BackendTransports: map[string]BackendTransport{
+			"h1.example.org": {DisableHTTP2: true},
+		},
+	})
+
+	if !tr.get("https", "api.example.org").transport.ForceAttemptHTTP2 {
+		t.Error("failed to attempt HTTP/2 by default")
+	}
+
+	if tr.get("https", "h1.example.org").transport.ForceAttemptHTTP2 {
+		t.Error("failed to disable HTTP/2")
+	}
+}
+
+func TestInsecureTransports(t *testing.T) {
+	tr := newTransports(Params{
+		Flags: Insecure,
+		BackendTransports: map[string]BackendTransport{
+			"api.example.org": {MaxConnsPerHost: 3},
+		},
+	})
+
+	bt := tr.get("https", "api.example.org")
+	if !bt.transport.TLSClientConfig.InsecureSkipVerify || bt.transport.MaxConnsPerHost != 3 {
+		t.Error("failed to apply the settings")
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Package routesig verifies the signatures of the route definitions, so
+that a compromised configuration store can't inject routes without
+having access to the signing keys.
+
+The trusted public keys are set with the -route-signing-keys flag, as
+PEM files containing PKIX public keys or certificates. The Ed25519,
+ECDSA and RSA keys are supported:
+
+    skipper -routes-file routes.eskip -route-signing-keys /etc/skipper/release.pem
+
+When the keys are set, the routes file needs to be signed, either with a
+detached signature in a file with the same path and the .sig suffix, or
+the routes file itself needs to be a JWS in compact serialization,
+containing the route definitions as its payload. The detached signature
+can be a detached JWS, with an empty payload, or the plain signature of
+the file, e.g. as created with openssl:
+
+    openssl dgst -sha256 -sign release-key.pem routes.eskip | base64 > routes.eskip.sig
+
+The routes stored in etcd need to be signed individually, and each value
+needs to be a JWS, containing the id, the version and the expression of
+the route as its payload. The SignRoute function can be used to create
+the signed values. The version needs to increase with every change, so
+that an older value of the route can't be replayed, and a value signed
+for a route can't be stored under a different id. The routes are
+deleted by storing a signed tombstone, with the Deleted field set,
+instead of removing their key. When the JWS header contains a key id,
+the public key with the same file name, without the extension, is used
+to verify it, e.g. release for release.pem.
+
+The routes file with a missing or invalid signature is rejected, and
+skipper fails to start. The routes in etcd with a missing or invalid
+signature, the removed keys, and the values with an older version are
+rejected, and their current version is kept in the routing table. The
+versions are tracked in memory, and after a restart, the current values
+in etcd are accepted. Every rejection is logged as an error, and a
+route_signature_invalid event is published, that can be forwarded to an
+alerting system, e.g. with the -event-webhook flag.
+
+The other data sources, Innkeeper and Kubernetes, don't support the
+signatures, and they can't be used together with the -route-signing-keys
+flag. Neither can the routing snapshot and the route history directory,
+because the routes stored in them are not signed.
+*/
+package routesig
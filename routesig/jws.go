@@ -0,0 +1,204 @@
+package routesig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var errInvalidJWS = errors.New("routesig: invalid JWS")
+
+type jwsHeader struct {
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid,omitempty"`
+	Crit []string `json:"crit,omitempty"`
+}
+
+func splitJWS(s string) (header, payload, signature string, ok bool) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" || strings.ContainsAny(s, " \t\r\n") {
+		return "", "", "", false
+	}
+
+	return parts[0], parts[1], parts[2], true
+}
+
+// checks whether a key can verify a JWS algorithm, and returns the hash
+// of the algorithm
+func algorithmKey(alg string, k crypto.PublicKey) (crypto.Hash, bool) {
+	switch kt := k.(type) {
+	case ed25519.PublicKey:
+		return 0, alg == "EdDSA"
+	case *ecdsa.PublicKey:
+		switch alg {
+		case "ES256":
+			return crypto.SHA256, kt.Curve.Params().BitSize == 256
+		case "ES384":
+			return crypto.SHA384, kt.Curve.Params().BitSize == 384
+		case "ES512":
+			return crypto.SHA512, kt.Curve.Params().BitSize == 521
+		}
+	case *rsa.PublicKey:
+		switch alg {
+		case "RS256", "PS256":
+			return crypto.SHA256, true
+		case "RS384", "PS384":
+			return crypto.SHA384, true
+		case "RS512", "PS512":
+			return crypto.SHA512, true
+		}
+	}
+
+	return 0, false
+}
+
+func verifyJWSSignature(alg string, k crypto.PublicKey, h crypto.Hash, input, sig []byte) bool {
+	switch kt := k.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(kt, input, sig)
+	case *ecdsa.PublicKey:
+		// the signature is the concatenation of r and s
+		size := (kt.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false
+		}
+
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(kt, digest(h, input), r, s)
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(kt, h, digest(h, input), sig, nil) == nil
+		}
+
+		return rsa.VerifyPKCS1v15(kt, h, digest(h, input), sig) == nil
+	default:
+		return false
+	}
+}
+
+func (v *Verifier) verifyJWS(header, payload, signature string) ([]byte, error) {
+	hb, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return nil, errInvalidJWS
+	}
+
+	var h jwsHeader
+	if err := json.Unmarshal(hb, &h); err != nil {
+		return nil, errInvalidJWS
+	}
+
+	// no extensions are supported, and the ones marked as critical
+	// must be understood
+	if len(h.Crit) > 0 {
+		return nil, fmt.Errorf("routesig: unsupported critical JWS header: %v", h.Crit)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, errInvalidJWS
+	}
+
+	input := []byte(header + "." + payload)
+	for _, k := range v.keys {
+		if h.Kid != "" && h.Kid != k.id {
+			continue
+		}
+
+		hash, ok := algorithmKey(h.Alg, k.key)
+		if !ok {
+			continue
+		}
+
+		if verifyJWSSignature(h.Alg, k.key, hash, input, sig) {
+			p, err := base64.RawURLEncoding.DecodeString(payload)
+			if err != nil {
+				return nil, errInvalidJWS
+			}
+
+			return p, nil
+		}
+	}
+
+	return nil, errInvalidSignature
+}
+
+// VerifyJWS checks a JWS in compact serialization, and returns its
+// payload. The supported algorithms are EdDSA with Ed25519, ES256,
+// ES384, ES512, RS256, RS384, RS512, PS256, PS384 and PS512. When the
+// header contains a key id, only the key with the same id is tried.
+func (v *Verifier) VerifyJWS(jws string) ([]byte, error) {
+	h, p, s, ok := splitJWS(strings.TrimSpace(jws))
+	if !ok {
+		return nil, errMissingSignature
+	}
+
+	return v.verifyJWS(h, p, s)
+}
+
+func signingAlgorithm(k crypto.Signer) (string, crypto.Hash, error) {
+	switch kt := k.Public().(type) {
+	case ed25519.PublicKey:
+		return "EdDSA", 0, nil
+	case *ecdsa.PublicKey:
+		switch kt.Curve.Params().BitSize {
+		case 256:
+			return "ES256", crypto.SHA256, nil
+		case 384:
+			return "ES384", crypto.SHA384, nil
+		case 521:
+			return "ES512", crypto.SHA512, nil
+		}
+	case *rsa.PublicKey:
+		return "RS256", crypto.SHA256, nil
+	}
+
+	return "", 0, errUnsupportedKey
+}
+
+// SignJWS signs a document with a private key, and returns it as a JWS
+// in compact serialization, e.g. to create a detached signature of a
+// routes file. The key id is optional.
+func SignJWS(doc []byte, k crypto.Signer, keyID string) (string, error) {
+	alg, hash, err := signingAlgorithm(k)
+	if err != nil {
+		return "", err
+	}
+
+	hb, err := json.Marshal(jwsHeader{Alg: alg, Kid: keyID})
+	if err != nil {
+		return "", err
+	}
+
+	input := base64.RawURLEncoding.EncodeToString(hb) + "." + base64.RawURLEncoding.EncodeToString(doc)
+
+	var sig []byte
+	switch kt := k.(type) {
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(kt, []byte(input))
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, kt, digest(hash, []byte(input)))
+		if err != nil {
+			return "", err
+		}
+
+		size := (kt.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+	default:
+		if sig, err = k.Sign(rand.Reader, digest(hash, []byte(input)), hash); err != nil {
+			return "", err
+		}
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
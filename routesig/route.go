@@ -0,0 +1,100 @@
+package routesig
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var errMissingVersion = errors.New("routesig: missing route version")
+
+// Route is the signed payload of a single route, e.g. stored as a value
+// in etcd. The signature covers the id and the version of the route, so
+// that a signed value can't be replayed under a different id, or to roll
+// back the route to an older version.
+type Route struct {
+
+	// The id of the route, e.g. the etcd key without the prefix.
+	Id string `json:"id"`
+
+	// The version of the route. It needs to increase with every
+	// change of the route, including the deletion.
+	Version uint64 `json:"version"`
+
+	// The route expression. Empty when the route is deleted.
+	Expression string `json:"route,omitempty"`
+
+	// Marks the value as a tombstone, that deletes the route.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// SignRoute signs a route with a private key, and returns it as a JWS in
+// compact serialization. The key id is optional.
+func SignRoute(r Route, k crypto.Signer, keyID string) (string, error) {
+	if r.Version == 0 {
+		return "", errMissingVersion
+	}
+
+	doc, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+
+	return SignJWS(doc, k, keyID)
+}
+
+func (v *Verifier) checkRoute(source, id string, r *Route) error {
+	if r.Id != id {
+		return fmt.Errorf("routesig: route signed for a different id: %s", r.Id)
+	}
+
+	if r.Version == 0 {
+		return errMissingVersion
+	}
+
+	if !r.Deleted && r.Expression == "" {
+		return errors.New("routesig: missing route expression")
+	}
+
+	v.mx.Lock()
+	defer v.mx.Unlock()
+	if r.Version < v.versions[source] {
+		return fmt.Errorf("routesig: route version %d older than the accepted %d", r.Version, v.versions[source])
+	}
+
+	if v.versions == nil {
+		v.versions = make(map[string]uint64)
+	}
+
+	v.versions[source] = r.Version
+	return nil
+}
+
+// VerifyRoute checks a signed route, created with SignRoute, stored under
+// an id. The route is rejected when it was signed for a different id, or
+// when its version is older than the last version accepted from the same
+// source. A deleted route is returned as a tombstone, and an unsigned
+// deletion, i.e. an empty value, is rejected. The versions are tracked in
+// memory, and they are not preserved across restarts. When the
+// verification fails, the rejection is logged and published as an event.
+func (v *Verifier) VerifyRoute(source, id string, value []byte) (*Route, error) {
+	p, err := v.Verify(source, value, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var r Route
+	if err := json.Unmarshal(p, &r); err != nil {
+		err = fmt.Errorf("routesig: invalid signed route: %v", err)
+		v.reject(source, err)
+		return nil, err
+	}
+
+	if err := v.checkRoute(source, id, &r); err != nil {
+		v.reject(source, err)
+		return nil, err
+	}
+
+	return &r, nil
+}
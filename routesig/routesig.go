@@ -0,0 +1,255 @@
+package routesig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/events"
+)
+
+var (
+	errNoKeys           = errors.New("routesig: no public keys")
+	errMissingSignature = errors.New("routesig: missing signature")
+	errInvalidSignature = errors.New("routesig: invalid signature")
+	errUnsupportedKey   = errors.New("routesig: unsupported key type")
+)
+
+// Options for creating a verifier.
+type Options struct {
+
+	// The public keys trusted to sign the route documents, in PEM
+	// format, either as PKIX public keys or as certificates. The
+	// keys are identified in the JWS headers by the name of their
+	// file without the extension. Required.
+	KeyFiles []string
+
+	// When set, a route_signature_invalid event is published on the
+	// bus, when a document is rejected.
+	EventBus *events.Bus
+}
+
+type key struct {
+	id  string
+	key crypto.PublicKey
+}
+
+// Verifier checks the signatures of the route documents, so that the
+// routes can only be changed by the holders of the trusted keys, and not
+// by anyone having write access to the configuration store.
+type Verifier struct {
+	keys []key
+	bus  *events.Bus
+
+	// the last accepted versions of the signed routes, by source
+	mx       sync.Mutex
+	versions map[string]uint64
+}
+
+func parsePublicKey(b []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("routesig: no PEM data found")
+	}
+
+	var (
+		k   crypto.PublicKey
+		err error
+	)
+
+	switch block.Type {
+	case "CERTIFICATE":
+		var c *x509.Certificate
+		if c, err = x509.ParseCertificate(block.Bytes); err == nil {
+			k = c.PublicKey
+		}
+	default:
+		k, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch k.(type) {
+	case ed25519.PublicKey, *ecdsa.PublicKey, *rsa.PublicKey:
+		return k, nil
+	default:
+		return nil, errUnsupportedKey
+	}
+}
+
+// New creates a verifier, loading the trusted public keys.
+func New(o Options) (*Verifier, error) {
+	if len(o.KeyFiles) == 0 {
+		return nil, errNoKeys
+	}
+
+	v := &Verifier{bus: o.EventBus}
+	for _, f := range o.KeyFiles {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := parsePublicKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("error while loading route signing key %s: %v", f, err)
+		}
+
+		id := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+		v.keys = append(v.keys, key{id: id, key: k})
+	}
+
+	return v, nil
+}
+
+// NewWithKeys creates a verifier with the trusted public keys, where the
+// keys of the map are the key ids. The supported key types are
+// ed25519.PublicKey, *ecdsa.PublicKey and *rsa.PublicKey.
+func NewWithKeys(keys map[string]crypto.PublicKey, bus *events.Bus) (*Verifier, error) {
+	if len(keys) == 0 {
+		return nil, errNoKeys
+	}
+
+	v := &Verifier{bus: bus}
+	for id, k := range keys {
+		switch k.(type) {
+		case ed25519.PublicKey, *ecdsa.PublicKey, *rsa.PublicKey:
+		default:
+			return nil, errUnsupportedKey
+		}
+
+		v.keys = append(v.keys, key{id: id, key: k})
+	}
+
+	return v, nil
+}
+
+// the signatures are accepted in binary and in base64 encoded form,
+// e.g. as created by openssl dgst -sign
+func decodeSignature(sig []byte) []byte {
+	s := bytes.TrimSpace(sig)
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if d, err := enc.DecodeString(string(s)); err == nil {
+			return d
+		}
+	}
+
+	return sig
+}
+
+func hashFor(k *ecdsa.PublicKey) crypto.Hash {
+	switch k.Curve.Params().BitSize {
+	case 384:
+		return crypto.SHA384
+	case 521:
+		return crypto.SHA512
+	default:
+		return crypto.SHA256
+	}
+}
+
+func digest(h crypto.Hash, doc []byte) []byte {
+	hh := h.New()
+	hh.Write(doc)
+	return hh.Sum(nil)
+}
+
+// verifies a signature of the whole document, made with the hash
+// corresponding to the type of the key
+func verifyDetached(k crypto.PublicKey, doc, sig []byte) bool {
+	switch kt := k.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(kt, doc, sig)
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(kt, digest(hashFor(kt), doc), sig)
+	case *rsa.PublicKey:
+		d := digest(crypto.SHA256, doc)
+		return rsa.VerifyPKCS1v15(kt, crypto.SHA256, d, sig) == nil ||
+			rsa.VerifyPSS(kt, crypto.SHA256, d, sig, nil) == nil
+	default:
+		return false
+	}
+}
+
+// VerifyDetached checks a detached signature of a document. The signature
+// can be a detached JWS, with an empty payload, or the plain signature of
+// the document, binary or base64 encoded. The plain signatures are
+// verified with SHA-256, or with SHA-384 for the P-384 keys, and with
+// PKCS #1 v1.5 or PSS for the RSA keys, e.g. as created by:
+//
+//	openssl dgst -sha256 -sign key.pem routes.eskip | base64 > routes.eskip.sig
+func (v *Verifier) VerifyDetached(doc, sig []byte) error {
+	if len(bytes.TrimSpace(sig)) == 0 {
+		return errMissingSignature
+	}
+
+	if h, p, s, ok := splitJWS(string(bytes.TrimSpace(sig))); ok {
+		if p != "" {
+			return errors.New("routesig: the detached JWS has a payload")
+		}
+
+		_, err := v.verifyJWS(h, base64.RawURLEncoding.EncodeToString(doc), s)
+		return err
+	}
+
+	d := decodeSignature(sig)
+	for _, k := range v.keys {
+		if verifyDetached(k.key, doc, d) {
+			return nil
+		}
+	}
+
+	return errInvalidSignature
+}
+
+// Verify checks a document, and returns the signed content. When the
+// signature is empty, the document needs to be a JWS in compact
+// serialization, and its payload is returned. Otherwise, the signature
+// is checked as a detached one, see VerifyDetached, and the document is
+// returned. When the verification fails, the rejection is logged and
+// published as an event. The source identifies the document in the logs
+// and the events, e.g. the path of a file.
+func (v *Verifier) Verify(source string, doc, sig []byte) ([]byte, error) {
+	var (
+		content []byte
+		err     error
+	)
+
+	if len(sig) == 0 {
+		content, err = v.VerifyJWS(string(doc))
+	} else {
+		content, err = doc, v.VerifyDetached(doc, sig)
+	}
+
+	if err != nil {
+		v.reject(source, err)
+		return nil, err
+	}
+
+	return content, nil
+}
+
+func (v *Verifier) reject(source string, err error) {
+	log.Errorf("route document rejected, %s: %v", source, err)
+	v.bus.Publish(&events.Event{
+		Type: events.TypeRouteSignatureInvalid,
+		Data: map[string]interface{}{
+			"source": source,
+			"error":  err.Error(),
+		},
+	})
+}
@@ -0,0 +1,338 @@
+package routesig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/events"
+)
+
+const testRoutes = `r: Path("/foo") -> "https://www.example.org";`
+
+func generateKeys(t *testing.T) map[string]crypto.Signer {
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]crypto.Signer{"ed25519": ed, "p256": p256, "p384": p384, "rsa": r}
+}
+
+func newVerifier(t *testing.T, bus *events.Bus, keys ...crypto.Signer) *Verifier {
+	public := make(map[string]crypto.PublicKey)
+	for i, k := range keys {
+		public[string(rune('a'+i))] = k.Public()
+	}
+
+	v, err := NewWithKeys(public, bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
+
+func TestJWS(t *testing.T) {
+	keys := generateKeys(t)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, k := range keys {
+		t.Run(name, func(t *testing.T) {
+			jws, err := SignJWS([]byte(testRoutes), k, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			v := newVerifier(t, nil, other, k)
+			p, err := v.VerifyJWS(jws)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(p) != testRoutes {
+				t.Error("invalid payload", string(p))
+			}
+
+			parts := strings.Split(jws, ".")
+			parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`r: * -> "https://evil.example.org";`))
+			if _, err := v.VerifyJWS(strings.Join(parts, ".")); err == nil {
+				t.Error("failed to fail with modified payload")
+			}
+
+			if _, err := newVerifier(t, nil, other).VerifyJWS(jws); err == nil {
+				t.Error("failed to fail with untrusted key")
+			}
+		})
+	}
+}
+
+func TestKeyID(t *testing.T) {
+	keys := generateKeys(t)
+	v, err := NewWithKeys(map[string]crypto.PublicKey{
+		"first":  keys["p256"].Public(),
+		"second": keys["ed25519"].Public(),
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jws, err := SignJWS([]byte(testRoutes), keys["p256"], "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.VerifyJWS(jws); err != nil {
+		t.Error(err)
+	}
+
+	jws, err = SignJWS([]byte(testRoutes), keys["p256"], "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.VerifyJWS(jws); err == nil {
+		t.Error("failed to fail with the key id of a different key")
+	}
+}
+
+func TestDetached(t *testing.T) {
+	keys := generateKeys(t)
+	doc := []byte(testRoutes)
+	digest := sha256.Sum256(doc)
+
+	ecSig, err := ecdsa.SignASN1(rand.Reader, keys["p256"].(*ecdsa.PrivateKey), digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaSig, err := rsa.SignPKCS1v15(rand.Reader, keys["rsa"].(*rsa.PrivateKey), crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jws, err := SignJWS(doc, keys["ed25519"], "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(jws, ".")
+	detachedJWS := parts[0] + ".." + parts[2]
+
+	v := newVerifier(t, nil, keys["p256"], keys["rsa"], keys["ed25519"])
+	for _, test := range []struct {
+		title string
+		doc   []byte
+		sig   []byte
+		fail  bool
+	}{{
+		title: "ECDSA, binary",
+		doc:   doc,
+		sig:   ecSig,
+	}, {
+		title: "ECDSA, base64",
+		doc:   doc,
+		sig:   []byte(base64.StdEncoding.EncodeToString(ecSig) + "\n"),
+	}, {
+		title: "RSA",
+		doc:   doc,
+		sig:   []byte(base64.StdEncoding.EncodeToString(rsaSig)),
+	}, {
+		title: "Ed25519",
+		doc:   doc,
+		sig:   ed25519.Sign(keys["ed25519"].(ed25519.PrivateKey), doc),
+	}, {
+		title: "detached JWS",
+		doc:   doc,
+		sig:   []byte(detachedJWS),
+	}, {
+		title: "modified document",
+		doc:   []byte(testRoutes + "\n"),
+		sig:   ecSig,
+		fail:  true,
+	}, {
+		title: "modified document, detached JWS",
+		doc:   []byte(testRoutes + "\n"),
+		sig:   []byte(detachedJWS),
+		fail:  true,
+	}, {
+		title: "JWS with payload",
+		doc:   doc,
+		sig:   []byte(jws),
+		fail:  true,
+	}, {
+		title: "missing signature",
+		doc:   doc,
+		fail:  true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			err := v.VerifyDetached(test.doc, test.sig)
+			if test.fail && err == nil {
+				t.Error("failed to fail")
+			} else if !test.fail && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRejectionPublished(t *testing.T) {
+	keys := generateKeys(t)
+	bus := events.NewBus()
+	s := bus.Subscribe(1, events.TypeRouteSignatureInvalid)
+	defer s.Close()
+
+	v := newVerifier(t, bus, keys["p256"])
+	if _, err := v.Verify("routes.eskip", []byte(testRoutes), nil); err == nil {
+		t.Fatal("failed to fail")
+	}
+
+	select {
+	case e := <-s.C:
+		if e.Data["source"] != "routes.eskip" {
+			t.Error("invalid event data", e.Data)
+		}
+	case <-time.After(time.Second):
+		t.Error("event not published")
+	}
+
+	jws, err := SignJWS([]byte(testRoutes), keys["p256"], "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := v.Verify("routes.eskip", []byte(jws), nil)
+	if err != nil || string(p) != testRoutes {
+		t.Error("failed to verify", string(p), err)
+	}
+}
+
+func TestSignedRoute(t *testing.T) {
+	keys := generateKeys(t)
+	v := newVerifier(t, nil, keys["ed25519"])
+	sign := func(r Route) []byte {
+		s, err := SignRoute(r, keys["ed25519"], "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return []byte(s)
+	}
+
+	const source = "/skipper/routes/r"
+	v1 := sign(Route{Id: "r", Version: 1, Expression: testRoutes})
+	v2 := sign(Route{Id: "r", Version: 2, Expression: testRoutes})
+
+	if _, err := SignRoute(Route{Id: "r", Expression: testRoutes}, keys["ed25519"], ""); err == nil {
+		t.Error("failed to fail without a version")
+	}
+
+	if _, err := v.VerifyRoute(source, "other", v1); err == nil {
+		t.Error("failed to fail with a different id")
+	}
+
+	r, err := v.VerifyRoute(source, "r", v2)
+	if err != nil || r.Version != 2 || r.Expression != testRoutes {
+		t.Fatal("failed to verify", r, err)
+	}
+
+	if _, err := v.VerifyRoute(source, "r", v2); err != nil {
+		t.Error("failed to verify the same version again", err)
+	}
+
+	if _, err := v.VerifyRoute(source, "r", v1); err == nil {
+		t.Error("failed to fail with an older version")
+	}
+
+	if _, err := v.VerifyRoute(source, "r", nil); err == nil {
+		t.Error("failed to fail with an unsigned delete")
+	}
+
+	r, err = v.VerifyRoute(source, "r", sign(Route{Id: "r", Version: 3, Deleted: true}))
+	if err != nil || !r.Deleted {
+		t.Fatal("failed to verify the tombstone", r, err)
+	}
+
+	if _, err := v.VerifyRoute(source, "r", v2); err == nil {
+		t.Error("failed to fail with a version older than the tombstone")
+	}
+}
+
+func TestLoadKeyFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skipper-routesig")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	keys := generateKeys(t)
+	var files []string
+	for name, k := range keys {
+		der, err := x509.MarshalPKIXPublicKey(k.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f := filepath.Join(dir, name+".pem")
+		if err := ioutil.WriteFile(f, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		files = append(files, f)
+	}
+
+	v, err := New(Options{KeyFiles: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jws, err := SignJWS([]byte(testRoutes), keys["p384"], "p384")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.VerifyJWS(jws); err != nil {
+		t.Error(err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.pem")
+	if err := ioutil.WriteFile(invalid, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(Options{KeyFiles: []string{invalid}}); err == nil {
+		t.Error("failed to fail")
+	}
+}
@@ -3,9 +3,11 @@ package routing
 import (
 	"fmt"
 	"net/url"
+	"sort"
 	"time"
 
 	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/events"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/logging"
 	"github.com/zalando/skipper/predicates"
@@ -38,6 +40,44 @@ type incomingData struct {
 	deletedIds     []string
 }
 
+// the merged route definitions, and the incoming data that triggered
+// the merge. Complete is set, when all the data clients have already
+// sent their routes.
+type mergedDefs struct {
+	defs     []*eskip.Route
+	incoming *incomingData
+	complete bool
+}
+
+// the next version of the routing table, with the details of the
+// change. When the matcher is nil, the current routing table is kept,
+// because it was built from the same route definitions. Complete is
+// set, when all the data clients have already sent their routes.
+type routingUpdate struct {
+	complete bool
+	matcher  *matcher
+	defs     []*eskip.Route
+	incoming *incomingData
+	diff     *routeDiff
+	hosts    []string
+	input    *routingInput
+}
+
+// the merged route definitions received from the data clients, that a
+// routing table was built from
+type routingInput struct {
+	id   string
+	defs []*eskip.Route
+}
+
+// the IDs of the routes changed compared to the previous version of the
+// routing table
+type routeDiff struct {
+	added   []string
+	changed []string
+	removed []string
+}
+
 func (d *incomingData) log(l logging.Logger) {
 	for _, r := range d.upsertedRoutes {
 		l.Infof("route settings, %v, route: %v: %v", d.typ, r.Id, r)
@@ -52,9 +92,14 @@ func (d *incomingData) log(l logging.Logger) {
 // The function does not return unless quit is closed. When started, it request for the
 // whole current set of routes, and continues polling for the subsequent updates. When a
 // communication error occurs, it re-requests the whole valid set, and continues polling.
-// Currently, the routes with the same id coming from different sources are merged in an
-// undeterministic way, but this may change in the future.
-func receiveFromClient(c DataClient, o Options, out chan<- *incomingData, quit <-chan struct{}) {
+// The routes with the same id coming from different sources are merged in the order of the
+// data clients, see mergeDefs.
+func receiveFromClient(c DataClient, o Options, st *statusTracker, out chan<- *incomingData, quit <-chan struct{}) {
+	var notify <-chan struct{}
+	if n, ok := c.(UpdateNotifier); ok {
+		notify = n.Updates()
+	}
+
 	initial := true
 	for {
 		var (
@@ -71,6 +116,8 @@ func receiveFromClient(c DataClient, o Options, out chan<- *incomingData, quit <
 			routes, deletedIDs, err = c.LoadUpdate()
 		}
 
+		st.clientResult(c, err)
+
 		switch {
 		case err != nil && initial:
 			o.Log.Error("error while receiveing initial data;", err)
@@ -79,8 +126,6 @@ func receiveFromClient(c DataClient, o Options, out chan<- *incomingData, quit <
 			initial = true
 			to = 0
 		case initial || len(routes) > 0 || len(deletedIDs) > 0:
-			initial = false
-
 			var incoming *incomingData
 			if initial {
 				incoming = &incomingData{incomingReset, c, routes, nil}
@@ -88,6 +133,8 @@ func receiveFromClient(c DataClient, o Options, out chan<- *incomingData, quit <
 				incoming = &incomingData{incomingUpdate, c, routes, deletedIDs}
 			}
 
+			initial = false
+
 			select {
 			case out <- incoming:
 			case <-quit:
@@ -97,6 +144,7 @@ func receiveFromClient(c DataClient, o Options, out chan<- *incomingData, quit <
 
 		select {
 		case <-time.After(to):
+		case <-notify:
 		case <-quit:
 			return
 		}
@@ -125,11 +173,19 @@ func applyIncoming(defs routeDefs, d *incomingData) routeDefs {
 	return defs
 }
 
-// merges the route definitions from multiple data clients by route id
-func mergeDefs(defsByClient map[DataClient]routeDefs) []*eskip.Route {
+// merges the route definitions from multiple data clients by route id,
+// in the order of the data clients, so that the later ones override the
+// routes of the earlier ones
+func mergeDefs(clients []DataClient, defsByClient map[DataClient]routeDefs) []*eskip.Route {
 	mergeById := make(routeDefs)
-	for _, defs := range defsByClient {
-		for id, def := range defs {
+	for _, c := range clients {
+		if d, ok := c.(RouteDeleter); ok {
+			for _, id := range d.DeletedRoutes() {
+				delete(mergeById, id)
+			}
+		}
+
+		for id, def := range defsByClient[c] {
 			mergeById[id] = def
 		}
 	}
@@ -148,13 +204,13 @@ func mergeDefs(defsByClient map[DataClient]routeDefs) []*eskip.Route {
 //
 // The active set of routes from last successful update are used until the
 // next successful update.
-func receiveRouteDefs(o Options, quit <-chan struct{}) <-chan []*eskip.Route {
+func receiveRouteDefs(o Options, st *statusTracker, quit <-chan struct{}) <-chan *mergedDefs {
 	in := make(chan *incomingData)
-	out := make(chan []*eskip.Route)
+	out := make(chan *mergedDefs)
 	defsByClient := make(map[DataClient]routeDefs)
 
 	for _, c := range o.DataClients {
-		go receiveFromClient(c, o, in, quit)
+		go receiveFromClient(c, o, st, in, quit)
 	}
 
 	go func() {
@@ -171,7 +227,11 @@ func receiveRouteDefs(o Options, quit <-chan struct{}) <-chan []*eskip.Route {
 			defsByClient[c] = applyIncoming(defsByClient[c], incoming)
 
 			select {
-			case out <- mergeDefs(defsByClient):
+			case out <- &mergedDefs{
+				defs:     mergeDefs(o.DataClients, defsByClient),
+				incoming: incoming,
+				complete: len(defsByClient) == len(o.DataClients),
+			}:
 			case <-quit:
 				return
 			}
@@ -223,6 +283,23 @@ func createFilters(fr filters.Registry, defs []*eskip.Filter) ([]*RouteFilter, e
 	return fs, nil
 }
 
+// lets the filters prepare the work that depends only on the route, so
+// that the errors are found when the route is loaded
+func prepareFilters(fs []*RouteFilter, ri filters.RouteInfo) error {
+	for _, f := range fs {
+		if p, ok := f.Filter.(filters.RoutePreparer); ok {
+			pf, err := p.PrepareRoute(ri)
+			if err != nil {
+				return fmt.Errorf("filter %s: %v", f.Name, err)
+			}
+
+			f.Filter = pf
+		}
+	}
+
+	return nil
+}
+
 // check if a predicate is a distinguished, path tree predicate
 func isTreePredicate(name string) bool {
 	switch name {
@@ -329,6 +406,15 @@ func processRouteDef(cpm map[string]PredicateSpec, fr filters.Registry, def *esk
 		return nil, err
 	}
 
+	if err := prepareFilters(fs, filters.RouteInfo{
+		Id:      def.Id,
+		Backend: def.Backend,
+		Scheme:  scheme,
+		Host:    host,
+	}); err != nil {
+		return nil, err
+	}
+
 	cps, err := processPredicates(cpm, def.Predicates)
 	if err != nil {
 		return nil, err
@@ -355,10 +441,21 @@ func mapPredicates(cps []PredicateSpec) map[string]PredicateSpec {
 // processes a set of route definitions for the routing table
 func processRouteDefs(o Options, fr filters.Registry, defs []*eskip.Route) []*Route {
 	cpm := mapPredicates(o.Predicates)
+	rs := newRestrictions(o)
+	gr := newGuardrails(o)
 
 	var routes []*Route
 	for _, def := range defs {
-		route, err := processRouteDef(cpm, fr, def)
+		var route *Route
+		err := rs.check(def)
+		if err == nil {
+			err = gr.check(def)
+		}
+
+		if err == nil {
+			route, err = processRouteDef(cpm, fr, def)
+		}
+
 		if err == nil {
 			routes = append(routes, route)
 		} else {
@@ -369,28 +466,121 @@ func processRouteDefs(o Options, fr filters.Registry, defs []*eskip.Route) []*Ro
 	return routes
 }
 
+// the definitions of the processed routes, sorted by their id
+func routeDefinitions(routes []*Route) []*eskip.Route {
+	defs := make([]*eskip.Route, len(routes))
+	for i, r := range routes {
+		defs[i] = &r.Route
+	}
+
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Id < defs[j].Id })
+	return defs
+}
+
+// compares the route definitions to the previous version, keyed by
+// their ID
+func diffRouteDefs(previous map[string]string, defs []*eskip.Route) (*routeDiff, map[string]string) {
+	d := &routeDiff{}
+	current := make(map[string]string)
+	for _, r := range defs {
+		rs := r.String()
+		current[r.Id] = rs
+		if prs, ok := previous[r.Id]; !ok {
+			d.added = append(d.added, r.Id)
+		} else if prs != rs {
+			d.changed = append(d.changed, r.Id)
+		}
+	}
+
+	for id := range previous {
+		if _, ok := current[id]; !ok {
+			d.removed = append(d.removed, id)
+		}
+	}
+
+	sort.Strings(d.added)
+	sort.Strings(d.changed)
+	sort.Strings(d.removed)
+	return d, current
+}
+
 // receives the next version of the routing table on the output channel,
 // when an update is received on one of the data clients.
-func receiveRouteMatcher(o Options, out chan<- *matcher, quit <-chan struct{}) {
-	updates := receiveRouteDefs(o, quit)
+//
+// When the current routing table was loaded from a snapshot, it is kept
+// until all the data clients have sent their routes, and then it is
+// rebuilt only when the route definitions are different from the ones
+// in the snapshot.
+func receiveRouteMatcher(o Options, st *statusTracker, snap *snapshot, out chan<- *routingUpdate, quit <-chan struct{}) {
+	updates := receiveRouteDefs(o, st, quit)
 	var (
-		mout         *matcher
-		outRelay     chan<- *matcher
-		updatesRelay <-chan []*eskip.Route
+		mout         *routingUpdate
+		outRelay     chan<- *routingUpdate
+		updatesRelay <-chan *mergedDefs
+		previous     map[string]string
+		diff         *routeDiff
 	)
 
+	if snap != nil {
+		previous = routeStrings(snap.Defs)
+	}
+
 	updatesRelay = updates
 	for {
 		select {
-		case defs := <-updatesRelay:
+		case merged := <-updatesRelay:
 			o.Log.Info("route settings received")
-			routes := processRouteDefs(o, o.FilterRegistry, defs)
+
+			// checked before processing the routes, to protect the
+			// proxy from a runaway control plane
+			if o.MaxRoutes > 0 && len(merged.defs) > o.MaxRoutes {
+				err := fmt.Errorf("routing table rejected: %d routes, maximum: %d", len(merged.defs), o.MaxRoutes)
+				o.Log.Error(err)
+				st.rejected(err)
+				o.EventBus.Publish(&events.Event{
+					Type: events.TypeRouteTableRejected,
+					Data: map[string]interface{}{
+						"source":     fmt.Sprintf("%T", merged.incoming.client),
+						"routes":     len(merged.defs),
+						"max_routes": o.MaxRoutes,
+					},
+				})
+
+				continue
+			}
+
+			if snap != nil && !merged.complete {
+				o.Log.Info("keeping the routes of the snapshot until all the data clients are loaded")
+				continue
+			}
+
+			diff, previous = diffRouteDefs(previous, merged.defs)
+			input := &routingInput{id: inputID(previous), defs: merged.defs}
+			if snap != nil && snap.InputID == input.id {
+				o.Log.Info("the routes of the snapshot are up to date")
+				snap = nil
+				mout = &routingUpdate{complete: true, incoming: merged.incoming, diff: diff, input: input}
+				updatesRelay = nil
+				outRelay = out
+				continue
+			}
+
+			snap = nil
+			routes := processRouteDefs(o, o.FilterRegistry, merged.defs)
 			m, errs := newMatcher(routes, o.MatchingOptions)
 			for _, err := range errs {
 				o.Log.Error(err)
 			}
 
-			mout = m
+			mout = &routingUpdate{
+				complete: merged.complete,
+				matcher:  m,
+				incoming: merged.incoming,
+				diff:     diff,
+				hosts:    routeHosts(routes),
+				defs:     routeDefinitions(routes),
+				input:    input,
+			}
 			updatesRelay = nil
 			outRelay = out
 		case outRelay <- mout:
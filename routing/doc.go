@@ -77,6 +77,15 @@ request object, and it returns true or false meaning that the request is
 a match or not.
 
 
+Allowed and Disabled Filters and Predicates
+
+The filters and the predicates, including the built-in ones, that can be
+used in the routes, can be restricted per deployment with allow and deny
+lists, e.g. to forbid filters that change the backend on a public tier.
+The routes referencing a filter or a predicate that is not allowed are
+rejected, both when loaded from the data clients and when validated.
+
+
 Data Clients
 
 Routing definitions are not directly passed to the routing instance, but
@@ -98,6 +107,49 @@ Currently, the routes with the same id coming from different sources are
 merged in an nondeterministic way, but this behavior may change in the
 future.
 
+Resource Guardrails
+
+The routing can limit the resources used by the routes. The routes with
+too many filters, with too complex regular expressions, or with a too
+large estimated memory footprint are rejected the same way as the other
+invalid routes. When an update exceeds the maximum number of the routes,
+the whole update is rejected, the previous routing table is kept, and
+the rejection is reported in the status and with a route_table_rejected
+event.
+
+Versions of the Routing Table
+
+The router keeps the last applied versions of the routing table, by
+default 10, and optionally stores them in a directory, too. The versions
+are identified by their content, so applying the same set of routes again
+results in the same version id. Only the tables containing the routes of
+all the data clients are kept as versions, and the partial ones, applied
+while the data clients are loading, are not. The versions are available
+through the Versions and Version methods, e.g. to roll back to a
+previous version via the admin API.
+
+Snapshots
+
+When a snapshot file is set, the route definitions of the routing table
+are saved in it after every update containing the routes of all the data
+clients, on Close, or by calling SaveSnapshot. On startup, the routing
+table is built from the saved definitions, so that the routes are served
+without waiting for the data clients. Once all the data clients have
+sent their routes, the routing table is rebuilt only when they differ
+from the saved ones, so a restart with unchanged routes builds the table
+only once, instead of once from the partial and once from the complete
+set of routes. While the table from the snapshot is used, it is shown in
+the status.
+
+The snapshot contains the route definitions, and not the built routing
+table, because the filter and predicate instances can hold live state,
+e.g. connections or caches, that cannot be serialized. The filters, the
+predicates and the matcher are created again from the saved definitions,
+with the same registry, so building the table from the snapshot takes as
+long as building it from the data clients. The snapshot and the history
+directory are not signed, and they can't be used together with the
+route signatures.
+
 For a full description of the route definitions, see the documentation
 of the skipper/eskip package.
 */
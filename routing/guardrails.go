@@ -0,0 +1,97 @@
+package routing
+
+import (
+	"fmt"
+	"regexp/syntax"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+const (
+	// the estimated fixed memory overhead of a route, with its
+	// filters, predicates and its node in the lookup tree
+	routeBaseSize = 1 << 10
+
+	// the estimated memory used by an instruction of a compiled
+	// regular expression, including the matching state
+	regexpInstSize = 64
+)
+
+// the limits of the resources used by a single route
+type guardrails struct {
+	maxFilters          int
+	maxRegexpComplexity int
+	maxRouteSize        int
+}
+
+func newGuardrails(o Options) *guardrails {
+	return &guardrails{
+		maxFilters:          o.MaxFiltersPerRoute,
+		maxRegexpComplexity: o.MaxRegexpComplexity,
+		maxRouteSize:        o.MaxRouteSize,
+	}
+}
+
+// the complexity of a regular expression is the number of the
+// instructions of its compiled program
+func regexpComplexity(expr string) (int, error) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return 0, err
+	}
+
+	p, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p.Inst), nil
+}
+
+func routeRegexps(def *eskip.Route) []string {
+	exprs := append(append([]string(nil), def.PathRegexps...), def.HostRegexps...)
+	for _, h := range def.HeaderRegexps {
+		exprs = append(exprs, h...)
+	}
+
+	return exprs
+}
+
+// estimates the memory used by a route, based on the size of its
+// definition and the complexity of its regular expressions
+func estimateRouteSize(def *eskip.Route, regexpInsts int) int {
+	return routeBaseSize + 2*len(def.String()) + regexpInsts*regexpInstSize
+}
+
+// checks whether a route stays within the configured limits
+func (g *guardrails) check(def *eskip.Route) error {
+	if g.maxFilters > 0 && len(def.Filters) > g.maxFilters {
+		return fmt.Errorf("too many filters: %d, maximum: %d", len(def.Filters), g.maxFilters)
+	}
+
+	if g.maxRegexpComplexity <= 0 && g.maxRouteSize <= 0 {
+		return nil
+	}
+
+	var insts int
+	for _, expr := range routeRegexps(def) {
+		c, err := regexpComplexity(expr)
+		if err != nil {
+			return err
+		}
+
+		if g.maxRegexpComplexity > 0 && c > g.maxRegexpComplexity {
+			return fmt.Errorf("regular expression too complex: '%s', complexity: %d, maximum: %d", expr, c, g.maxRegexpComplexity)
+		}
+
+		insts += c
+	}
+
+	if g.maxRouteSize > 0 {
+		if size := estimateRouteSize(def, insts); size > g.maxRouteSize {
+			return fmt.Errorf("route too large, estimated size: %d bytes, maximum: %d", size, g.maxRouteSize)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package routing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/logging"
+)
+
+// DefaultHistorySize is the default number of the applied versions of
+// the routing table kept in memory.
+const DefaultHistorySize = 10
+
+const versionFileExt = ".eskip"
+
+// Version is an applied version of the routing table.
+type Version struct {
+
+	// Identifies the content of the routing table. Applying the same
+	// set of routes again results in the same ID.
+	ID string `json:"id"`
+
+	// The time when the version was applied.
+	Applied time.Time `json:"applied"`
+
+	// The number of the routes in the table.
+	Routes int `json:"routes"`
+
+	defs []*eskip.Route
+}
+
+// keeps the last applied versions of the routing table, and, when a
+// directory is set, stores them as files, too
+type history struct {
+	mx       sync.Mutex
+	size     int
+	dir      string
+	log      logging.Logger
+	versions []*Version
+}
+
+// Definitions returns the route definitions of the version, sorted by
+// their id.
+func (v *Version) Definitions() []*eskip.Route {
+	return v.defs
+}
+
+func versionID(defs []*eskip.Route) string {
+	h := sha256.Sum256([]byte(eskip.Print(false, defs...)))
+	return hex.EncodeToString(h[:6])
+}
+
+func newHistory(o Options) *history {
+	if o.HistorySize < 0 {
+		return nil
+	}
+
+	size := o.HistorySize
+	if size == 0 {
+		size = DefaultHistorySize
+	}
+
+	h := &history{size: size, dir: o.HistoryDir, log: o.Log}
+	if h.dir != "" {
+		if err := h.load(); err != nil {
+			o.Log.Errorf("error while loading the routing table history: %v", err)
+		}
+	}
+
+	return h
+}
+
+// the file names contain the time of applying the version, in unix
+// nanoseconds, and the version id: <time>-<id>.eskip
+func versionFileName(v *Version) string {
+	return fmt.Sprintf("%d-%s%s", v.Applied.UnixNano(), v.ID, versionFileExt)
+}
+
+func parseVersionFileName(name string) (time.Time, string, bool) {
+	if !strings.HasSuffix(name, versionFileExt) {
+		return time.Time{}, "", false
+	}
+
+	parts := strings.SplitN(strings.TrimSuffix(name, versionFileExt), "-", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", false
+	}
+
+	ns, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+
+	return time.Unix(0, ns), parts[1], true
+}
+
+// loads the stored versions, e.g. after a restart
+func (h *history) load() error {
+	files, err := ioutil.ReadDir(h.dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var versions []*Version
+	for _, f := range files {
+		applied, id, ok := parseVersionFileName(f.Name())
+		if !ok {
+			continue
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(h.dir, f.Name()))
+		if err != nil {
+			return err
+		}
+
+		defs, err := eskip.Parse(string(b))
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.Name(), err)
+		}
+
+		versions = append(versions, &Version{ID: id, Applied: applied, Routes: len(defs), defs: defs})
+	}
+
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Applied.Before(versions[j].Applied) })
+	if len(versions) > h.size {
+		versions = versions[len(versions)-h.size:]
+	}
+
+	h.versions = versions
+	return nil
+}
+
+func (h *history) store(v *Version, dropped []*Version) {
+	if err := os.MkdirAll(h.dir, 0755); err != nil {
+		h.log.Errorf("error while storing the routing table version: %v", err)
+		return
+	}
+
+	// written to a temporary file first, so that a partially written
+	// version is never loaded
+	name := filepath.Join(h.dir, versionFileName(v))
+	if err := ioutil.WriteFile(name+".tmp", []byte(eskip.Print(true, v.defs...)), 0644); err != nil {
+		h.log.Errorf("error while storing the routing table version: %v", err)
+		return
+	}
+
+	if err := os.Rename(name+".tmp", name); err != nil {
+		h.log.Errorf("error while storing the routing table version: %v", err)
+		return
+	}
+
+	for _, d := range dropped {
+		if err := os.Remove(filepath.Join(h.dir, versionFileName(d))); err != nil && !os.IsNotExist(err) {
+			h.log.Errorf("error while removing the routing table version: %v", err)
+		}
+	}
+}
+
+// records an applied version, unless it has the same content as the
+// latest one
+func (h *history) add(defs []*eskip.Route, applied time.Time) {
+	v := &Version{ID: versionID(defs), Applied: applied, Routes: len(defs), defs: defs}
+
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if len(h.versions) > 0 && h.versions[len(h.versions)-1].ID == v.ID {
+		return
+	}
+
+	h.versions = append(h.versions, v)
+	var dropped []*Version
+	if len(h.versions) > h.size {
+		dropped = h.versions[:len(h.versions)-h.size]
+		h.versions = append([]*Version(nil), h.versions[len(h.versions)-h.size:]...)
+	}
+
+	if h.dir != "" {
+		h.store(v, dropped)
+	}
+}
+
+// the versions, the latest first
+func (h *history) list() []*Version {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	l := make([]*Version, len(h.versions))
+	for i, v := range h.versions {
+		l[len(l)-i-1] = v
+	}
+
+	return l
+}
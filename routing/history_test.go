@@ -0,0 +1,199 @@
+package routing_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+func versionRoutes(v *routing.Version) string {
+	var s string
+	for _, r := range v.Definitions() {
+		s += r.Id + ";"
+	}
+
+	return s
+}
+
+func newHistoryRouting(t *testing.T, dc *testdataclient.Client, dir string) (*routing.Routing, *loggingtest.Logger) {
+	tl := loggingtest.New()
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PollTimeout:    pollTimeout,
+		Log:            tl,
+		HistorySize:    3,
+		HistoryDir:     dir,
+	})
+
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	return rt, tl
+}
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "routing-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	dc, err := testdataclient.NewDoc(`a: Path("/a") -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt, tl := newHistoryRouting(t, dc, dir)
+	defer tl.Close()
+
+	for _, doc := range []string{
+		`b: Path("/b") -> <shunt>`,
+		`c: Path("/c") -> <shunt>`,
+		`d: Path("/d") -> <shunt>`,
+	} {
+		tl.Reset()
+		if err := dc.UpdateDoc(doc, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// no change, no new version
+	tl.Reset()
+	dc.UpdateDoc(`d: Path("/d") -> <shunt>`, nil)
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(rt *routing.Routing) {
+		versions := rt.Versions()
+		if len(versions) != 3 {
+			t.Fatal("invalid number of versions", len(versions))
+		}
+
+		for i, expected := range []string{"a;b;c;d;", "a;b;c;", "a;b;"} {
+			if routes := versionRoutes(versions[i]); routes != expected {
+				t.Error("invalid version", i, routes)
+			}
+
+			if versions[i].Routes != len(versions[i].Definitions()) {
+				t.Error("invalid route count", versions[i].Routes)
+			}
+		}
+
+		if v, ok := rt.Version(versions[1].ID); !ok || v != versions[1] {
+			t.Error("failed to find the version")
+		}
+
+		if _, ok := rt.Version("no-such-version"); ok {
+			t.Error("unexpected version found")
+		}
+	}
+
+	check(rt)
+	rt.Close()
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 3 {
+		t.Error("invalid number of stored versions", len(files))
+	}
+
+	// the versions are loaded after a restart, and the current one is
+	// not recorded again
+	dc2, err := testdataclient.NewDoc(`a: Path("/a") -> <shunt>; b: Path("/b") -> <shunt>; c: Path("/c") -> <shunt>; d: Path("/d") -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt2, tl2 := newHistoryRouting(t, dc2, dir)
+	defer tl2.Close()
+	defer rt2.Close()
+	check(rt2)
+}
+
+func TestHistoryDisabled(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`a: Path("/a") -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tl := loggingtest.New()
+	defer tl.Close()
+	rt := routing.New(routing.Options{
+		DataClients: []routing.DataClient{dc},
+		PollTimeout: pollTimeout,
+		Log:         tl,
+		HistorySize: -1,
+	})
+	defer rt.Close()
+
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rt.Versions()) != 0 {
+		t.Error("unexpected versions")
+	}
+}
+
+func TestHistoryPartialTables(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`a: Path("/a") -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delayed := newDelayedClient(t, `b: Path("/b") -> <shunt>`)
+	tl := loggingtest.New()
+	defer tl.Close()
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc, delayed},
+		PollTimeout:    pollTimeout,
+		Log:            tl,
+		HistorySize:    3,
+	})
+	defer rt.Close()
+
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := rt.Status(); !s.Updated || s.Complete {
+		t.Error("invalid status", s.Updated, s.Complete)
+	}
+
+	if len(rt.Versions()) != 0 {
+		t.Fatal("the partial table was kept as a version")
+	}
+
+	tl.Reset()
+	close(delayed.release)
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if !rt.Status().Complete {
+		t.Error("failed to report the complete table")
+	}
+
+	if v := rt.Versions(); len(v) != 1 || versionRoutes(v[0]) != "a;b;" {
+		t.Error("invalid versions", v)
+	}
+}
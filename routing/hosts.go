@@ -0,0 +1,64 @@
+package routing
+
+import (
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// returns the string matched by a regular expression, when it matches
+// only a single, anchored, case sensitive literal. When lineAnchors is
+// true, the expressions anchored to the beginning and the end of a line
+// are accepted, too.
+func anchoredLiteral(expr string, lineAnchors bool) (string, bool) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+
+	re = re.Simplify()
+	if re.Op != syntax.OpConcat || len(re.Sub) != 3 {
+		return "", false
+	}
+
+	begin, literal, end := re.Sub[0], re.Sub[1], re.Sub[2]
+	if begin.Op != syntax.OpBeginText && (!lineAnchors || begin.Op != syntax.OpBeginLine) ||
+		end.Op != syntax.OpEndText && (!lineAnchors || end.Op != syntax.OpEndLine) ||
+		literal.Op != syntax.OpLiteral || literal.Flags&syntax.FoldCase != 0 {
+		return "", false
+	}
+
+	return string(literal.Rune), true
+}
+
+// returns the host name matched by a Host predicate expression, when
+// the expression matches only a single, anchored literal host name,
+// e.g. ^www[.]example[.]org$
+func literalHost(expr string) (string, bool) {
+	host, ok := anchoredLiteral(expr, true)
+	if !ok || strings.ContainsAny(host, ":/ ") {
+		return "", false
+	}
+
+	return strings.ToLower(host), true
+}
+
+// the literal host names of the routes, sorted
+func routeHosts(routes []*Route) []string {
+	set := make(map[string]bool)
+	for _, r := range routes {
+		for _, h := range r.HostRegexps {
+			if lh, ok := literalHost(h); ok {
+				set[lh] = true
+			}
+		}
+	}
+
+	var hosts []string
+	for h := range set {
+		hosts = append(hosts, h)
+	}
+
+	sort.Strings(hosts)
+	return hosts
+}
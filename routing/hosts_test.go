@@ -0,0 +1,25 @@
+package routing
+
+import "testing"
+
+func TestLiteralHost(t *testing.T) {
+	for _, test := range []struct {
+		expr, host string
+		ok         bool
+	}{
+		{expr: "^www[.]example[.]org$", host: "www.example.org", ok: true},
+		{expr: `^api\.example\.org$`, host: "api.example.org", ok: true},
+		{expr: "^WWW[.]Example[.]org$", host: "www.example.org", ok: true},
+		{expr: "www[.]example[.]org"},
+		{expr: "^www[.]example[.]org(:[0-9]+)?$"},
+		{expr: "^.*[.]example[.]org$"},
+		{expr: "^(www|api)[.]example[.]org$"},
+		{expr: "(?i)^www[.]example[.]org$"},
+		{expr: "["},
+	} {
+		host, ok := literalHost(test.expr)
+		if ok != test.ok || host != test.host {
+			t.Errorf("%s: got %s, %v, expected %s, %v", test.expr, host, ok, test.host, test.ok)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"regexp"
 	"sort"
+	"sync"
 
 	"github.com/dimfeld/httppath"
 	"github.com/zalando/pathmux"
@@ -15,6 +16,10 @@ type leafRequestMatcher struct {
 	path string
 }
 
+// the request matchers are reused, because passing them to the path tree
+// as an interface would allocate them for every request
+var leafRequestMatcherPool = sync.Pool{New: func() interface{} { return &leafRequestMatcher{} }}
+
 func (m *leafRequestMatcher) Match(value interface{}) (bool, interface{}) {
 	v, ok := value.(*pathMatcher)
 	if !ok {
@@ -27,7 +32,12 @@ func (m *leafRequestMatcher) Match(value interface{}) (bool, interface{}) {
 }
 
 type leafMatcher struct {
-	method        string
+	method string
+
+	// the Host conditions that are anchored literals, e.g.
+	// ^www[.]example[.]org$, are matched by comparing the strings
+	hosts []string
+
 	hostRxs       []*regexp.Regexp
 	pathRxs       []*regexp.Regexp
 	headersExact  map[string]string
@@ -45,6 +55,7 @@ func leafWeight(l *leafMatcher) int {
 		w++
 	}
 
+	w += len(l.hosts)
 	w += len(l.hostRxs)
 	w += len(l.pathRxs)
 	w += len(l.headersExact)
@@ -124,11 +135,26 @@ func canonicalizeHeaderRegexps(hrx map[string][]*regexp.Regexp) map[string][]*re
 	return chrx
 }
 
+// separates the Host conditions that can be matched by comparing the
+// strings
+func literalHosts(exps []string) (hosts []string, rest []string) {
+	for _, exp := range exps {
+		if h, ok := anchoredLiteral(exp, false); ok {
+			hosts = append(hosts, h)
+		} else {
+			rest = append(rest, exp)
+		}
+	}
+
+	return
+}
+
 // creates a new leaf matcher. preprocesses the
 // Host, PathRegexp, Header and HeaderRegexp
 // conditions.
 func newLeaf(r *Route) (*leafMatcher, error) {
-	hostRxs, err := compileRxs(r.HostRegexps)
+	hosts, hostExps := literalHosts(r.HostRegexps)
+	hostRxs, err := compileRxs(hostExps)
 	if err != nil {
 		return nil, err
 	}
@@ -151,6 +177,7 @@ func newLeaf(r *Route) (*leafMatcher, error) {
 
 	return &leafMatcher{
 		method:        r.Method,
+		hosts:         hosts,
 		hostRxs:       hostRxs,
 		pathRxs:       pathRxs,
 		headersExact:  canonicalizeHeaders(r.Headers),
@@ -339,6 +366,17 @@ func matchRegexps(rxs []*regexp.Regexp, s string) bool {
 	return true
 }
 
+// matches the literal Host conditions in a leaf matcher.
+func matchHosts(hosts []string, host string) bool {
+	for _, h := range hosts {
+		if h != host {
+			return false
+		}
+	}
+
+	return true
+}
+
 // matches a set of request headers to a fix and regexp header condition
 func matchHeader(h http.Header, key string, check func(string) bool) bool {
 	vals, has := h[key]
@@ -393,6 +431,10 @@ func matchLeaf(l *leafMatcher, req *http.Request, path string) bool {
 		return false
 	}
 
+	if !matchHosts(l.hosts, req.Host) {
+		return false
+	}
+
 	if !matchRegexps(l.hostRxs, req.Host) {
 		return false
 	}
@@ -430,10 +472,13 @@ func (m *matcher) match(r *http.Request) (*Route, map[string]string) {
 	// normalize path before matching
 	// in case ignoring trailing slashes, match without the trailing slash
 	path := cleanPath(r.URL.Path, m.matchingOptions)
-	lrm := &leafRequestMatcher{r, path}
+	lrm := leafRequestMatcherPool.Get().(*leafRequestMatcher)
+	lrm.r, lrm.path = r, path
 
 	// first match fixed and wildcard paths
 	params, l := matchPathTree(m.paths, path, lrm)
+	lrm.r, lrm.path = nil, ""
+	leafRequestMatcherPool.Put(lrm)
 
 	if l != nil {
 		return l.route, params
@@ -1,6 +1,7 @@
 package routing
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/zalando/pathmux"
@@ -841,6 +842,80 @@ func TestMakeLeaf(t *testing.T) {
 	}
 }
 
+func TestLiteralHosts(t *testing.T) {
+	for _, test := range []struct {
+		expr    string
+		literal bool
+		host    string
+		match   bool
+	}{
+		{`^www[.]example[.]org$`, true, "www.example.org", true},
+		{`^www[.]example[.]org$`, true, "xwww.example.org", false},
+		{`^www\.example\.org:9090$`, true, "www.example.org:9090", true},
+		{`^www[.]example[.]org$`, true, "WWW.example.org", false},
+		{`www[.]example[.]org`, false, "xwww.example.org", true},
+		{`(?i)^www[.]example[.]org$`, false, "WWW.example.org", true},
+		{`(?m)^www[.]example[.]org$`, false, "www.example.org", true},
+		{`^www[.]example[.](org|com)$`, false, "www.example.com", true},
+	} {
+		r, err := docToRoute(fmt.Sprintf("Host(%q) -> <shunt>", test.expr))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		l, err := newLeaf(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if test.literal && (len(l.hosts) != 1 || len(l.hostRxs) != 0) ||
+			!test.literal && (len(l.hosts) != 0 || len(l.hostRxs) != 1) {
+			t.Error("invalid preprocessing of the host condition", test.expr)
+		}
+
+		if leafWeight(l) != 1 {
+			t.Error("invalid leaf weight", test.expr)
+		}
+
+		req := &http.Request{Host: test.host, URL: &url.URL{Path: "/"}}
+		if matchLeaf(l, req, "/") != test.match {
+			t.Error("invalid host match", test.expr, test.host)
+		}
+	}
+}
+
+func TestMatchAllocations(t *testing.T) {
+	routes, err := docToRoutes(`
+		api: Host(/^api[.]example[.]org$/) && Method("GET") && Header("X-Version", "2") -> "https://api.example.org";
+		www: Host(/^www[.]example[.]org$/) && HeaderRegexp("Accept", "html") -> "https://www.example.org";
+		catchAll: * -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, errs := newMatcher(routes, MatchingOptionsNone)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	req := &http.Request{
+		Method: "GET",
+		Host:   "www.example.org",
+		URL:    &url.URL{Path: "/foo/bar"},
+		Header: http.Header{"Accept": []string{"text/html"}},
+	}
+
+	if r, _ := m.match(req); r == nil || r.Id != "www" {
+		t.Fatal("failed to match the route", r)
+	}
+
+	// the path tree allocates the wildcard parameters only, but the
+	// rest of the lookup should not allocate
+	if a := testing.AllocsPerRun(100, func() { matchLeaves(m.rootLeaves, req, req.URL.Path) }); a != 0 {
+		t.Error("unexpected allocations", a)
+	}
+}
+
 func TestMakeMatcherEmpty(t *testing.T) {
 	m, errs := newMatcher(nil, MatchingOptionsNone)
 	if len(errs) != 0 || m == nil {
@@ -1125,3 +1200,35 @@ func BenchmarkConstructionMass(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkMatchLeaves(b *testing.B) {
+	var doc bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&doc, "r%d: Host(/^www%d[.]example[.]org$/) && Method(\"GET\") && Header(\"X-Version\", \"2\") -> <shunt>;\n", i, i)
+	}
+
+	routes, err := docToRoutes(doc.String())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	m, errs := newMatcher(routes, MatchingOptionsNone)
+	if len(errs) != 0 {
+		b.Fatal(errs)
+	}
+
+	req := &http.Request{
+		Method: "GET",
+		Host:   "www999.example.org",
+		URL:    &url.URL{Path: "/"},
+		Header: http.Header{"X-Version": []string{"2"}},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if r, _ := m.match(req); r == nil {
+			b.Fatal("failed to match")
+		}
+	}
+}
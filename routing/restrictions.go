@@ -0,0 +1,93 @@
+package routing
+
+import (
+	"fmt"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+// the filters and predicates allowed or disabled in a deployment
+type restrictions struct {
+	allowedFilters     map[string]bool
+	disabledFilters    map[string]bool
+	allowedPredicates  map[string]bool
+	disabledPredicates map[string]bool
+}
+
+func nameSet(names []string) map[string]bool {
+	if len(names) == 0 {
+		return nil
+	}
+
+	s := make(map[string]bool)
+	for _, n := range names {
+		s[n] = true
+	}
+
+	return s
+}
+
+func newRestrictions(o Options) *restrictions {
+	return &restrictions{
+		allowedFilters:     nameSet(o.AllowedFilters),
+		disabledFilters:    nameSet(o.DisabledFilters),
+		allowedPredicates:  nameSet(o.AllowedPredicates),
+		disabledPredicates: nameSet(o.DisabledPredicates),
+	}
+}
+
+func allowed(allow, disable map[string]bool, name string) bool {
+	return !disable[name] && (allow == nil || allow[name])
+}
+
+// the names of the predicates used by a route, including the built-in
+// ones that the parser stores in dedicated fields
+func predicateNames(def *eskip.Route) []string {
+	var names []string
+	if def.Path != "" {
+		names = append(names, PathName)
+	}
+
+	if len(def.HostRegexps) > 0 {
+		names = append(names, "Host")
+	}
+
+	if len(def.PathRegexps) > 0 {
+		names = append(names, "PathRegexp")
+	}
+
+	if def.Method != "" {
+		names = append(names, "Method")
+	}
+
+	if len(def.Headers) > 0 {
+		names = append(names, "Header")
+	}
+
+	if len(def.HeaderRegexps) > 0 {
+		names = append(names, "HeaderRegexp")
+	}
+
+	for _, p := range def.Predicates {
+		names = append(names, p.Name)
+	}
+
+	return names
+}
+
+// checks whether a route uses only the allowed filters and predicates
+func (r *restrictions) check(def *eskip.Route) error {
+	for _, f := range def.Filters {
+		if !allowed(r.allowedFilters, r.disabledFilters, f.Name) {
+			return fmt.Errorf("filter disabled: '%s'", f.Name)
+		}
+	}
+
+	for _, name := range predicateNames(def) {
+		if !allowed(r.allowedPredicates, r.disabledPredicates, name) {
+			return fmt.Errorf("predicate disabled: '%s'", name)
+		}
+	}
+
+	return nil
+}
@@ -1,11 +1,13 @@
 package routing
 
 import (
+	"fmt"
 	"net/http"
 	"sync/atomic"
 	"time"
 
 	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/events"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/logging"
 )
@@ -44,6 +46,22 @@ type DataClient interface {
 	LoadUpdate() ([]*eskip.Route, []string, error)
 }
 
+// UpdateNotifier can be implemented by the data clients that know when
+// they have updates, e.g. when the routes are changed with an API. The
+// updates are requested when the channel receives a value, without
+// waiting for the poll timeout.
+type UpdateNotifier interface {
+	Updates() <-chan struct{}
+}
+
+// RouteDeleter can be implemented by the data clients that can delete
+// the routes provided by the other data clients. The routes with the
+// returned IDs are removed from the routes of the data clients
+// preceding it in the list of the data clients.
+type RouteDeleter interface {
+	DeletedRoutes() []string
+}
+
 // Predicate instances are used as custom user defined route
 // matching predicates.
 type Predicate interface {
@@ -81,12 +99,51 @@ type Options struct {
 	PollTimeout time.Duration
 
 	// The set of different data clients where the
-	// route definitions are read from.
+	// route definitions are read from. When multiple
+	// data clients provide a route with the same id,
+	// the one from the data client later in the list
+	// is used.
 	DataClients []DataClient
 
 	// Specifications of custom, user defined predicates.
 	Predicates []PredicateSpec
 
+	// When not empty, only the filters in the list can be used in
+	// the routes.
+	AllowedFilters []string
+
+	// Filters that cannot be used in the routes, even when they are
+	// registered. The routes referencing them are rejected.
+	DisabledFilters []string
+
+	// When not empty, only the predicates in the list can be used
+	// in the routes. It applies to the built-in predicates, too, e.g.
+	// Path, Host, Method or Header.
+	AllowedPredicates []string
+
+	// Predicates that cannot be used in the routes, including the
+	// built-in ones. The routes referencing them are rejected.
+	DisabledPredicates []string
+
+	// The maximum number of the route definitions in the routing
+	// table. When an update exceeds it, it is rejected, and the
+	// previous routing table is kept. Zero means no limit.
+	MaxRoutes int
+
+	// The maximum number of filters in a route. The routes exceeding
+	// it are rejected. Zero means no limit.
+	MaxFiltersPerRoute int
+
+	// The maximum complexity of the regular expressions in the
+	// routes, measured in the number of the instructions of the
+	// compiled expression. The routes exceeding it are rejected.
+	// Zero means no limit.
+	MaxRegexpComplexity int
+
+	// The maximum estimated memory used by a route, in bytes. The
+	// routes exceeding it are rejected. Zero means no limit.
+	MaxRouteSize int
+
 	// Performance tuning option.
 	//
 	// When zero, the newly constructed routing
@@ -102,8 +159,31 @@ type Options struct {
 	// by benchmarks.)
 	UpdateBuffer int
 
+	// The number of the last applied versions of the routing table
+	// kept in memory, e.g. to roll back a bad change. Default: 10.
+	// When negative, no history is kept.
+	HistorySize int
+
+	// When set, the versions of the routing table are stored in
+	// this directory, too, and they are loaded from there on
+	// startup. The stored versions are not signed.
+	HistoryDir string
+
+	// When set, the route definitions of the routing table are saved
+	// in this file after every complete update, on Close, or by
+	// calling SaveSnapshot, and the routing table is built from them
+	// on startup, so that the routes can be served before the data
+	// clients are loaded. Once all the data clients have sent their
+	// routes, the routing table is rebuilt only when they changed.
+	// The snapshot is not signed.
+	SnapshotFile string
+
 	// Set a custom logger if necessary.
 	Log logging.Logger
+
+	// When set, a route_table_updated event is published on the bus
+	// every time a new version of the routing table is applied.
+	EventBus *events.Bus
 }
 
 // Filter contains extensions to generic filter
@@ -141,7 +221,13 @@ type Route struct {
 // updatable request matching.
 type Routing struct {
 	matcher atomic.Value
+	hosts   atomic.Value
+	defs    atomic.Value
+	input   atomic.Value
+	history *history
+	options Options
 	log     logging.Logger
+	status  *statusTracker
 	quit    chan struct{}
 }
 
@@ -152,22 +238,71 @@ func New(o Options) *Routing {
 		o.Log = &logging.DefaultLog{}
 	}
 
-	r := &Routing{log: o.Log, quit: make(chan struct{})}
+	r := &Routing{
+		options: o,
+		log:     o.Log,
+		status:  newStatusTracker(o.DataClients),
+		history: newHistory(o),
+		quit:    make(chan struct{}),
+	}
+
 	initialMatcher, _ := newMatcher(nil, MatchingOptionsNone)
 	r.matcher.Store(initialMatcher)
-	r.startReceivingUpdates(o)
+
+	var snap *snapshot
+	if o.SnapshotFile != "" {
+		snap = r.applySnapshot(o)
+	}
+
+	r.startReceivingUpdates(o, snap)
 	return r
 }
 
-func (r *Routing) startReceivingUpdates(o Options) {
-	c := make(chan *matcher)
-	go receiveRouteMatcher(o, c, r.quit)
+func (r *Routing) startReceivingUpdates(o Options, snap *snapshot) {
+	c := make(chan *routingUpdate)
+	go receiveRouteMatcher(o, r.status, snap, c, r.quit)
 	go func() {
 		for {
 			select {
-			case m := <-c:
-				r.matcher.Store(m)
+			case u := <-c:
+				defs := u.defs
+				if u.matcher == nil {
+					defs = r.Routes()
+				} else {
+					r.matcher.Store(u.matcher)
+					r.hosts.Store(u.hosts)
+				}
+
+				// the partial tables, applied while the data
+				// clients are loading, are not kept as versions
+				if r.history != nil && u.complete {
+					r.history.add(defs, time.Now())
+				}
+
+				r.defs.Store(defs)
+				r.input.Store(u.input)
+				r.status.applied(len(defs), u.complete)
+				if u.matcher != nil && u.complete && o.SnapshotFile != "" {
+					if err := r.SaveSnapshot(); err != nil {
+						r.log.Errorf("error while saving the routing snapshot: %v", err)
+					}
+				}
+
 				r.log.Info("route settings applied")
+				o.EventBus.Publish(&events.Event{
+					Type: events.TypeRouteTableUpdated,
+					Data: map[string]interface{}{
+						"source":   fmt.Sprintf("%T", u.incoming.client),
+						"update":   u.incoming.typ.String(),
+						"routes":   len(defs),
+						"invalid":  len(u.input.defs) - len(defs),
+						"upserted": len(u.incoming.upsertedRoutes),
+						"deleted":  len(u.incoming.deletedIds),
+						"added":    u.diff.added,
+						"changed":  u.diff.changed,
+						"removed":  u.diff.removed,
+					},
+				})
 			case <-r.quit:
 				return
 			}
@@ -175,6 +310,75 @@ func (r *Routing) startReceivingUpdates(o Options) {
 	}()
 }
 
+// Status returns the state of the routing table, and the connectivity
+// of the data clients.
+func (r *Routing) Status() *Status {
+	return r.status.status()
+}
+
+// Hosts returns the host names of the current routes, that are matched
+// by a Host predicate with a literal host name, e.g.
+// Host("^www[.]example[.]org$").
+func (r *Routing) Hosts() []string {
+	h, _ := r.hosts.Load().([]string)
+	return h
+}
+
+// Routes returns the definitions of the valid routes of the current
+// routing table, sorted by their id.
+func (r *Routing) Routes() []*eskip.Route {
+	d, _ := r.defs.Load().([]*eskip.Route)
+	return d
+}
+
+// Versions returns the last applied versions of the routing table, the
+// latest first.
+func (r *Routing) Versions() []*Version {
+	if r.history == nil {
+		return nil
+	}
+
+	return r.history.list()
+}
+
+// Version returns an applied version of the routing table by its id. When
+// the same version was applied multiple times, the latest is returned.
+func (r *Routing) Version(id string) (*Version, bool) {
+	for _, v := range r.Versions() {
+		if v.ID == id {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+// Validate checks whether the route definitions can be applied, e.g.
+// whether their filters and predicates exist and are allowed, and
+// whether they stay within the configured limits, and returns the first
+// error found.
+func (r *Routing) Validate(defs []*eskip.Route) error {
+	cpm := mapPredicates(r.options.Predicates)
+	rs := newRestrictions(r.options)
+	gr := newGuardrails(r.options)
+	for _, def := range defs {
+		err := rs.check(def)
+		if err == nil {
+			err = gr.check(def)
+		}
+
+		if err == nil {
+			_, err = processRouteDef(cpm, r.options.FilterRegistry, def)
+		}
+
+		if err != nil {
+			return fmt.Errorf("invalid route %s: %v", def.Id, err)
+		}
+	}
+
+	return nil
+}
+
 // Matches a request in the current routing tree.
 //
 // If the request matches a route, returns the route and a map of
@@ -185,7 +389,15 @@ func (r *Routing) Route(req *http.Request) (*Route, map[string]string) {
 	return m.match(req)
 }
 
-// Closes routing, stops receiving routes.
+// Closes routing, stops receiving routes. When the snapshot file is set,
+// the current routing table is saved.
 func (r *Routing) Close() {
 	close(r.quit)
+	if r.options.SnapshotFile == "" {
+		return
+	}
+
+	if err := r.SaveSnapshot(); err != nil {
+		r.log.Errorf("error while saving the routing snapshot: %v", err)
+	}
 }
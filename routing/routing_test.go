@@ -3,10 +3,12 @@ package routing_test
 import (
 	"errors"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/events"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/filters/builtin"
 	"github.com/zalando/skipper/filters/filtertest"
@@ -356,6 +358,78 @@ func TestProcessesFilterDefinitions(t *testing.T) {
 	}
 }
 
+type preparingSpec struct{}
+
+type preparingFilter struct{ fail bool }
+
+type preparedFilter struct{ host string }
+
+func (preparingSpec) Name() string { return "preparing" }
+
+func (preparingSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	return &preparingFilter{fail: len(args) == 1 && args[0] == "fail"}, nil
+}
+
+func (f *preparingFilter) Request(filters.FilterContext)  {}
+func (f *preparingFilter) Response(filters.FilterContext) {}
+
+func (f *preparingFilter) PrepareRoute(ri filters.RouteInfo) (filters.Filter, error) {
+	if f.fail {
+		return nil, errors.New("failed to prepare")
+	}
+
+	return &preparedFilter{host: ri.Host}, nil
+}
+
+func (f *preparedFilter) Request(filters.FilterContext)  {}
+func (f *preparedFilter) Response(filters.FilterContext) {}
+
+func TestPreparesFilters(t *testing.T) {
+	fr := make(filters.Registry)
+	fr.Register(preparingSpec{})
+
+	dc, err := testdataclient.NewDoc(`
+		prepared: Path("/prepared") -> preparing() -> "https://www.example.org";
+		failing: Path("/failing") -> preparing("fail") -> "https://www.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tl := loggingtest.New()
+	defer tl.Close()
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: fr,
+		DataClients:    []routing.DataClient{dc},
+		PollTimeout:    pollTimeout,
+		Log:            tl,
+	})
+	defer rt.Close()
+
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tl.WaitFor("filter preparing: failed to prepare", time.Second); err != nil {
+		t.Error("the failure was not reported when loading the route")
+	}
+
+	r, _ := http.NewRequest("GET", "https://www.example.com/prepared", nil)
+	route, _ := rt.Route(r)
+	if route == nil || len(route.Filters) != 1 {
+		t.Fatal("failed to load the route")
+	}
+
+	if f, ok := route.Filters[0].Filter.(*preparedFilter); !ok || f.host != "www.example.org" {
+		t.Error("the filter was not prepared", route.Filters[0].Filter)
+	}
+
+	r, _ = http.NewRequest("GET", "https://www.example.com/failing", nil)
+	if route, _ := rt.Route(r); route != nil {
+		t.Error("the route with the failing filter was loaded")
+	}
+}
+
 func TestProcessesPredicates(t *testing.T) {
 	dc, err := testdataclient.NewDoc(`
         route1: CustomPredicate("custom1") -> "https://route1.example.org";
@@ -438,3 +512,277 @@ func TestNonMatchedStaticRoute(t *testing.T) {
 		}
 	}
 }
+
+func TestPublishesRouteTableUpdates(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`
+		foo: Path("/foo") -> "https://foo.example.org";
+		bar: Path("/bar") -> "https://bar.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus := events.NewBus()
+	s := bus.Subscribe(0, events.TypeRouteTableUpdated)
+	defer s.Close()
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PollTimeout:    pollTimeout,
+		EventBus:       bus,
+	})
+	defer rt.Close()
+
+	receive := func() *events.Event {
+		select {
+		case e := <-s.C:
+			return e
+		case <-time.After(time.Second):
+			t.Fatal("event not received")
+			return nil
+		}
+	}
+
+	e := receive()
+	if e.Data["update"] != "reset" || e.Data["routes"] != 2 || e.Data["upserted"] != 2 ||
+		e.Data["source"] != "*testdataclient.Client" {
+		t.Error("invalid initial event", e.Data)
+	}
+
+	if added, ok := e.Data["added"].([]string); !ok || len(added) != 2 || added[0] != "bar" || added[1] != "foo" {
+		t.Error("invalid added routes", e.Data["added"])
+	}
+
+	dc.Update(nil, []string{"bar"})
+	e = receive()
+	if e.Data["update"] != "update" || e.Data["routes"] != 1 || e.Data["deleted"] != 1 {
+		t.Error("invalid update event", e.Data)
+	}
+
+	if removed, ok := e.Data["removed"].([]string); !ok || len(removed) != 1 || removed[0] != "bar" {
+		t.Error("invalid removed routes", e.Data["removed"])
+	}
+}
+
+func TestStatus(t *testing.T) {
+	dc := testdataclient.New([]*eskip.Route{{Id: "route1", Path: "/some-path", Backend: "https://www.example.org"}})
+	dc.FailNext()
+
+	tr, err := newTestRouting(dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tr.close()
+
+	s := tr.routing.Status()
+	if !s.Updated || s.LastUpdate.IsZero() || s.Routes != 1 {
+		t.Error("invalid routing status", s)
+	}
+
+	if len(s.DataClients) != 1 {
+		t.Fatal("invalid data client status", s.DataClients)
+	}
+
+	if c := s.DataClients[0]; c.Type != "*testdataclient.Client" || !c.Connected || c.LastSuccess.IsZero() {
+		t.Error("invalid data client status", c)
+	}
+}
+
+type deletingClient struct {
+	*testdataclient.Client
+	deleted []string
+}
+
+func (c *deletingClient) DeletedRoutes() []string { return c.deleted }
+
+func TestMergesInClientOrder(t *testing.T) {
+	dc1 := testdataclient.New([]*eskip.Route{
+		{Id: "route1", Path: "/some-path", Backend: "https://www.example.org"},
+		{Id: "route2", Path: "/some-other", Backend: "https://www.example.org"},
+	})
+
+	dc2 := &deletingClient{
+		Client:  testdataclient.New([]*eskip.Route{{Id: "route1", Path: "/some-path", Backend: "https://override.example.org"}}),
+		deleted: []string{"route2"},
+	}
+
+	tr, err := newTestRouting(dc1, dc2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tr.close()
+
+	r, err := tr.checkGetRequest("https://www.example.com/some-path")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Backend != "https://override.example.org" {
+		t.Error("failed to override route", r.Backend)
+	}
+
+	if _, err := tr.checkGetRequest("https://www.example.com/some-other"); err == nil {
+		t.Error("failed to delete route")
+	}
+
+	routes := tr.routing.Routes()
+	if len(routes) != 1 || routes[0].Id != "route1" {
+		t.Error("invalid routes", routes)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tr, err := newTestRouting()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tr.close()
+
+	valid, err := eskip.Parse(`r: Path("/foo") -> setPath("/bar") -> "https://www.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tr.routing.Validate(valid); err != nil {
+		t.Error(err)
+	}
+
+	invalid, err := eskip.Parse(`r: Path("/foo") -> noSuchFilter() -> "https://www.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tr.routing.Validate(invalid); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestRestrictions(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`
+		allowed: Path("/foo") -> setPath("/bar") -> "https://www.example.org";
+		disabledFilter: Path("/baz") -> setRequestHeader("Host", "evil.example.org") -> "https://www.example.org";
+		disabledPredicate: Method("POST") -> "https://www.example.org";
+		notAllowedPredicate: CustomPredicate("custom1") -> "https://www.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tl := loggingtest.New()
+	defer tl.Close()
+
+	rt := routing.New(routing.Options{
+		FilterRegistry:     builtin.MakeRegistry(),
+		Predicates:         []routing.PredicateSpec{&predicate{}},
+		DataClients:        []routing.DataClient{dc},
+		PollTimeout:        pollTimeout,
+		Log:                tl,
+		DisabledFilters:    []string{"setRequestHeader"},
+		AllowedPredicates:  []string{"Path", "Method"},
+		DisabledPredicates: []string{"Method"},
+	})
+	defer rt.Close()
+
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := rt.Routes()
+	if len(routes) != 1 || routes[0].Id != "allowed" {
+		t.Error("invalid routes", routes)
+	}
+
+	for _, test := range []struct {
+		route string
+		fail  bool
+	}{
+		{route: `r: Path("/foo") -> setPath("/bar") -> <shunt>`},
+		{route: `r: Path("/foo") -> setRequestHeader("Host", "evil.example.org") -> <shunt>`, fail: true},
+		{route: `r: Method("GET") -> <shunt>`, fail: true},
+		{route: `r: Host("www.example.org") -> <shunt>`, fail: true},
+		{route: `r: CustomPredicate("custom1") -> <shunt>`, fail: true},
+	} {
+		r, err := eskip.Parse(test.route)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := rt.Validate(r); (err != nil) != test.fail {
+			t.Error("unexpected validation result", test.route, err)
+		}
+	}
+}
+
+func TestGuardrails(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`
+		small: Path("/foo") -> setPath("/bar") -> "https://www.example.org";
+		manyFilters: Path("/baz") -> setPath("/a") -> setPath("/b") -> setPath("/c") -> "https://www.example.org";
+		complexRegexp: PathRegexp("^/(a|b|c|d|e|f|g|h)+[0-9]{10,20}$") -> "https://www.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tl := loggingtest.New()
+	defer tl.Close()
+
+	rt := routing.New(routing.Options{
+		FilterRegistry:      builtin.MakeRegistry(),
+		DataClients:         []routing.DataClient{dc},
+		PollTimeout:         pollTimeout,
+		Log:                 tl,
+		MaxRoutes:           3,
+		MaxFiltersPerRoute:  2,
+		MaxRegexpComplexity: 30,
+		MaxRouteSize:        4096,
+	})
+	defer rt.Close()
+
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := rt.Routes()
+	if len(routes) != 1 || routes[0].Id != "small" {
+		t.Error("invalid routes", routes)
+	}
+
+	for _, test := range []struct {
+		route string
+		fail  bool
+	}{
+		{route: `r: Path("/foo") -> setPath("/bar") -> <shunt>`},
+		{route: `r: Path("/foo") -> setPath("/a") -> setPath("/b") -> setPath("/c") -> <shunt>`, fail: true},
+		{route: `r: PathRegexp("^/[a-z]+$") -> <shunt>`},
+		{route: `r: PathRegexp("[0-9]{100}") -> <shunt>`, fail: true},
+		{route: `r: Header("X-Foo", "` + strings.Repeat("x", 4096) + `") -> <shunt>`, fail: true},
+	} {
+		r, err := eskip.Parse(test.route)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := rt.Validate(r); (err != nil) != test.fail {
+			t.Error("unexpected validation result", test.route, err)
+		}
+	}
+
+	tl.Reset()
+	dc.Update([]*eskip.Route{
+		{Id: "r1", Path: "/r1", Backend: "https://www.example.org"},
+		{Id: "r2", Path: "/r2", Backend: "https://www.example.org"},
+	}, nil)
+
+	if err := tl.WaitFor("routing table rejected: 5 routes, maximum: 3", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := rt.Status(); s.RejectedUpdates != 1 || s.LastRejection == "" {
+		t.Error("rejection not reported", s.RejectedUpdates, s.LastRejection)
+	}
+
+	if routes := rt.Routes(); len(routes) != 1 || routes[0].Id != "small" {
+		t.Error("the previous routing table was not kept", routes)
+	}
+}
@@ -0,0 +1,165 @@
+package routing
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+// incremented when the content of the snapshot files changes
+const snapshotFormat = 1
+
+var (
+	// ErrNoSnapshotFile is returned when saving a snapshot, while the
+	// snapshot file is not set.
+	ErrNoSnapshotFile = errors.New("routing: snapshot file not set")
+
+	// ErrNoRoutingTable is returned when saving a snapshot, before the
+	// first routing table was applied.
+	ErrNoRoutingTable = errors.New("routing: no routing table to save")
+
+	// ErrIncompleteRoutingTable is returned when saving a snapshot,
+	// while the routing table doesn't contain the routes of all the
+	// data clients yet.
+	ErrIncompleteRoutingTable = errors.New("routing: the routing table is not complete")
+)
+
+// The snapshot contains the merged route definitions received from the
+// data clients, that the applied routing table was built from, and
+// their id, so that, after a restart, the table can be built before the
+// data clients are loaded, and the rebuild can be skipped, when they
+// return the same definitions. It doesn't contain the built routing
+// table: the filters, the predicates and the matcher are created again
+// from the stored definitions, once, on startup.
+type snapshot struct {
+	Format  int
+	Created time.Time
+	InputID string
+	Defs    []*eskip.Route
+}
+
+// identifies the merged route definitions, from their string
+// representation, mapped by the route id
+func inputID(defs map[string]string) string {
+	ids := make([]string, 0, len(defs))
+	for id := range defs {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	h := sha256.New()
+	for _, id := range ids {
+		fmt.Fprintf(h, "%s\n%s\n", id, defs[id])
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:12])
+}
+
+func routeStrings(defs []*eskip.Route) map[string]string {
+	m := make(map[string]string)
+	for _, d := range defs {
+		m[d.Id] = d.String()
+	}
+
+	return m
+}
+
+func loadSnapshot(name string) (*snapshot, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	var s snapshot
+	if err := gob.NewDecoder(f).Decode(&s); err != nil {
+		return nil, err
+	}
+
+	if s.Format != snapshotFormat {
+		return nil, fmt.Errorf("unsupported snapshot format: %d", s.Format)
+	}
+
+	return &s, nil
+}
+
+// written to a temporary file first, so that a partially written
+// snapshot is never loaded
+func saveSnapshot(name string, s *snapshot) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(f).Encode(s); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+// SaveSnapshot stores the current routing table in the snapshot file
+// set in the options. Only the tables containing the routes of all the
+// data clients are saved.
+func (r *Routing) SaveSnapshot() error {
+	if r.options.SnapshotFile == "" {
+		return ErrNoSnapshotFile
+	}
+
+	input, _ := r.input.Load().(*routingInput)
+	if input == nil {
+		return ErrNoRoutingTable
+	}
+
+	if !r.status.status().Ready() {
+		return ErrIncompleteRoutingTable
+	}
+
+	return saveSnapshot(r.options.SnapshotFile, &snapshot{
+		Format:  snapshotFormat,
+		Created: time.Now(),
+		InputID: input.id,
+		Defs:    input.defs,
+	})
+}
+
+// applies the routing table from the snapshot file, when it exists
+func (r *Routing) applySnapshot(o Options) *snapshot {
+	s, err := loadSnapshot(o.SnapshotFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		r.log.Errorf("error while loading the routing snapshot: %v", err)
+		return nil
+	}
+
+	routes := processRouteDefs(o, o.FilterRegistry, s.Defs)
+	m, errs := newMatcher(routes, o.MatchingOptions)
+	for _, err := range errs {
+		r.log.Error(err)
+	}
+
+	r.matcher.Store(m)
+	r.hosts.Store(routeHosts(routes))
+	r.defs.Store(routeDefinitions(routes))
+	r.input.Store(&routingInput{id: s.InputID, defs: s.Defs})
+	r.status.appliedSnapshot(len(routes))
+	r.log.Infof("routing table loaded from the snapshot of %v", s.Created)
+	return s
+}
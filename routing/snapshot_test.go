@@ -0,0 +1,251 @@
+package routing_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+// a data client that returns its routes only after it was released
+type delayedClient struct {
+	release chan struct{}
+	routes  []*eskip.Route
+}
+
+func newDelayedClient(t *testing.T, doc string) *delayedClient {
+	routes, err := eskip.Parse(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &delayedClient{release: make(chan struct{}), routes: routes}
+}
+
+func (dc *delayedClient) LoadAll() ([]*eskip.Route, error) {
+	<-dc.release
+	return dc.routes, nil
+}
+
+func (dc *delayedClient) LoadUpdate() ([]*eskip.Route, []string, error) {
+	return nil, nil, nil
+}
+
+func newSnapshotRouting(file string, dc ...routing.DataClient) (*routing.Routing, *loggingtest.Logger) {
+	tl := loggingtest.New()
+	return routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    dc,
+		PollTimeout:    pollTimeout,
+		Log:            tl,
+		SnapshotFile:   file,
+	}), tl
+}
+
+func routesTo(rt *routing.Routing, path string) bool {
+	r, _ := http.NewRequest("GET", "https://www.example.org"+path, nil)
+	route, _ := rt.Route(r)
+	return route != nil
+}
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "routing-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "routes.snapshot")
+
+	const doc = `a: Path("/a") -> setPath("/b") -> <shunt>; b: Path("/b") -> <shunt>`
+	dc, err := testdataclient.NewDoc(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt, tl := newSnapshotRouting(file, dc)
+	defer tl.Close()
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(file); err != nil {
+		t.Fatal("failed to save the snapshot after the update", err)
+	}
+
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+
+	rt.Close()
+	if _, err := os.Stat(file); err != nil {
+		t.Fatal("failed to save the snapshot on close", err)
+	}
+
+	t.Run("served before the data clients are loaded, and kept", func(t *testing.T) {
+		delayed := newDelayedClient(t, doc)
+		rt, tl := newSnapshotRouting(file, delayed)
+		defer tl.Close()
+		defer rt.Close()
+
+		if !routesTo(rt, "/a") || !routesTo(rt, "/b") {
+			t.Fatal("failed to serve the routes of the snapshot")
+		}
+
+		if s := rt.Status(); !s.Updated || !s.Snapshot || s.Routes != 2 {
+			t.Error("invalid status", s)
+		}
+
+		close(delayed.release)
+		if err := tl.WaitFor("the routes of the snapshot are up to date", time.Second); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := rt.Status(); s.Snapshot || s.Routes != 2 {
+			t.Error("invalid status after the data clients were loaded", s)
+		}
+
+		if !routesTo(rt, "/a") || !routesTo(rt, "/b") {
+			t.Error("failed to keep the routes")
+		}
+	})
+
+	t.Run("waits for all the data clients", func(t *testing.T) {
+		first, err := testdataclient.NewDoc(`a: Path("/a") -> setPath("/b") -> <shunt>`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		second := newDelayedClient(t, `b: Path("/b") -> <shunt>`)
+		rt, tl := newSnapshotRouting(file, first, second)
+		defer tl.Close()
+		defer rt.Close()
+
+		if err := tl.WaitFor("keeping the routes of the snapshot", time.Second); err != nil {
+			t.Fatal(err)
+		}
+
+		if !routesTo(rt, "/b") {
+			t.Error("failed to keep the routes of the snapshot")
+		}
+
+		close(second.release)
+		if err := tl.WaitFor("the routes of the snapshot are up to date", time.Second); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("replaced, when the routes changed", func(t *testing.T) {
+		delayed := newDelayedClient(t, `c: Path("/c") -> <shunt>`)
+		rt, tl := newSnapshotRouting(file, delayed)
+		defer tl.Close()
+		defer rt.Close()
+
+		if !routesTo(rt, "/a") || routesTo(rt, "/c") {
+			t.Fatal("failed to serve the routes of the snapshot")
+		}
+
+		close(delayed.release)
+		if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+			t.Fatal(err)
+		}
+
+		if routesTo(rt, "/a") || !routesTo(rt, "/c") {
+			t.Error("failed to replace the routes of the snapshot")
+		}
+
+		if s := rt.Status(); s.Snapshot || s.Routes != 1 {
+			t.Error("invalid status", s)
+		}
+	})
+}
+
+func TestSnapshotPartialTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "routing-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "routes.snapshot")
+
+	first, err := testdataclient.NewDoc(`a: Path("/a") -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second := newDelayedClient(t, `b: Path("/b") -> <shunt>`)
+	rt, tl := newSnapshotRouting(file, first, second)
+	defer tl.Close()
+	defer rt.Close()
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rt.SaveSnapshot(); err != routing.ErrIncompleteRoutingTable {
+		t.Error("failed to fail with the partial table", err)
+	}
+
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("the partial table was saved", err)
+	}
+
+	tl.Reset()
+	close(second.release)
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(file); err != nil {
+		t.Error("failed to save the complete table", err)
+	}
+}
+
+func TestSnapshotBeforeRoutingTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "routing-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "routes.snapshot")
+
+	delayed := newDelayedClient(t, `a: Path("/a") -> <shunt>`)
+	defer close(delayed.release)
+	rt, tl := newSnapshotRouting(file, delayed)
+	defer tl.Close()
+	defer rt.Close()
+	if err := rt.SaveSnapshot(); err != routing.ErrNoRoutingTable {
+		t.Error("failed to fail before the first routing table", err)
+	}
+
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("unexpected snapshot file", err)
+	}
+}
+
+func TestSnapshotFileNotSet(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`a: Path("/a") -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt, tl := newSnapshotRouting("", dc)
+	defer tl.Close()
+	defer rt.Close()
+	if err := rt.SaveSnapshot(); err != routing.ErrNoSnapshotFile {
+		t.Error("failed to fail without a snapshot file", err)
+	}
+}
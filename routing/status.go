@@ -0,0 +1,147 @@
+package routing
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DataClientStatus describes the connectivity of a data client.
+type DataClientStatus struct {
+
+	// The type of the data client, e.g. *etcd.Client.
+	Type string `json:"type"`
+
+	// True when the last request of the data client succeeded.
+	Connected bool `json:"connected"`
+
+	// The time of the last successful request of the data client.
+	LastSuccess time.Time `json:"last_success"`
+
+	// The error of the last failed request of the data client.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Status describes the state of the routing table and its sources.
+type Status struct {
+
+	// True when a routing table was applied at least once.
+	Updated bool `json:"updated"`
+
+	// True when all the data clients have sent their routes, and a
+	// routing table containing the routes of all of them was applied.
+	Complete bool `json:"complete"`
+
+	// The time when the last version of the routing table was
+	// applied.
+	LastUpdate time.Time `json:"last_update"`
+
+	// The number of the routes in the routing table.
+	Routes int `json:"routes"`
+
+	// True while the routing table loaded from the snapshot file is
+	// used, and it was not confirmed yet by the data clients.
+	Snapshot bool `json:"snapshot,omitempty"`
+
+	// The number of the updates rejected, because they exceeded the
+	// maximum number of the routes.
+	RejectedUpdates int `json:"rejected_updates"`
+
+	// The reason of the last rejected update.
+	LastRejection string `json:"last_rejection,omitempty"`
+
+	// The status of the data clients, in the order of the
+	// configuration.
+	DataClients []DataClientStatus `json:"data_clients"`
+}
+
+// Ready tells whether the routing table contains the routes of all the
+// data clients, or it was loaded from the snapshot file, while the data
+// clients are loading.
+func (s *Status) Ready() bool {
+	return s.Complete || s.Snapshot
+}
+
+type statusTracker struct {
+	mx         sync.Mutex
+	clients    []DataClient
+	byClient   map[DataClient]*DataClientStatus
+	lastUpdate time.Time
+	routes     int
+	complete   bool
+	snapshot   bool
+	rejections int
+	lastReject string
+}
+
+func newStatusTracker(clients []DataClient) *statusTracker {
+	st := &statusTracker{
+		clients:  clients,
+		byClient: make(map[DataClient]*DataClientStatus),
+	}
+
+	for _, c := range clients {
+		st.byClient[c] = &DataClientStatus{Type: fmt.Sprintf("%T", c)}
+	}
+
+	return st
+}
+
+func (st *statusTracker) clientResult(c DataClient, err error) {
+	st.mx.Lock()
+	defer st.mx.Unlock()
+	cs := st.byClient[c]
+	if err != nil {
+		cs.Connected = false
+		cs.LastError = err.Error()
+		return
+	}
+
+	cs.Connected = true
+	cs.LastSuccess = time.Now()
+	cs.LastError = ""
+}
+
+func (st *statusTracker) applied(routes int, complete bool) {
+	st.mx.Lock()
+	defer st.mx.Unlock()
+	st.lastUpdate = time.Now()
+	st.routes = routes
+	st.complete = st.complete || complete
+	st.snapshot = false
+}
+
+func (st *statusTracker) appliedSnapshot(routes int) {
+	st.mx.Lock()
+	defer st.mx.Unlock()
+	st.lastUpdate = time.Now()
+	st.routes = routes
+	st.snapshot = true
+}
+
+func (st *statusTracker) rejected(err error) {
+	st.mx.Lock()
+	defer st.mx.Unlock()
+	st.rejections++
+	st.lastReject = err.Error()
+}
+
+func (st *statusTracker) status() *Status {
+	st.mx.Lock()
+	defer st.mx.Unlock()
+	s := &Status{
+		Updated:         !st.lastUpdate.IsZero(),
+		Complete:        st.complete,
+		LastUpdate:      st.lastUpdate,
+		Routes:          st.routes,
+		Snapshot:        st.snapshot,
+		RejectedUpdates: st.rejections,
+		LastRejection:   st.lastReject,
+	}
+
+	for _, c := range st.clients {
+		s.DataClients = append(s.DataClients, *st.byClient[c])
+	}
+
+	return s
+}
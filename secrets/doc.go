@@ -0,0 +1,27 @@
+/*
+Package secrets implements keyrings of secret keys, that the filters can
+use to encrypt, decrypt, sign and verify data, e.g. the encryptCookie
+filter.
+
+The keys are loaded from a source, that can be a file, containing one key
+per line, an environment variable, containing the keys separated by
+commas, or a secret in the KV secrets engine of Vault:
+
+    skipper -secrets cookies=file:/etc/skipper/cookie-keys,tokens=env:TOKEN_KEYS
+
+The first key of a source is the active one, used to encrypt and sign the
+data, while all the keys are accepted to decrypt and verify it. The keys
+need to be at least 16 bytes long. The encryption and the signing keys
+are derived from the configured secrets, and the encrypted messages
+contain the id of the key, so that the keys can be rotated without
+downtime:
+
+    1. prepend the new key to the source,
+    2. wait until the old key is not used anymore, e.g. the cookies
+       encrypted with it expired or were set again,
+    3. remove the old key from the source.
+
+The sources are reloaded periodically, see the -secrets-refresh-interval
+flag. When reloading fails, the current keys are kept.
+*/
+package secrets
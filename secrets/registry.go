@@ -0,0 +1,45 @@
+package secrets
+
+import "sync"
+
+// Registry contains the named keyrings available to the filters. The
+// filters reference the keyrings by their name in their arguments.
+type Registry struct {
+	mx       sync.RWMutex
+	keyrings map[string]*Keyring
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{keyrings: make(map[string]*Keyring)}
+}
+
+// Add adds a keyring with a name. When a keyring was already registered
+// with the same name, it is closed and replaced.
+func (r *Registry) Add(name string, k *Keyring) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if previous, ok := r.keyrings[name]; ok {
+		previous.Close()
+	}
+
+	r.keyrings[name] = k
+}
+
+// Get returns a keyring by its name.
+func (r *Registry) Get(name string) (*Keyring, bool) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	k, ok := r.keyrings[name]
+	return k, ok
+}
+
+// Close closes all the keyrings.
+func (r *Registry) Close() {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	for name, k := range r.keyrings {
+		k.Close()
+		delete(r.keyrings, name)
+	}
+}
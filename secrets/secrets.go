@@ -0,0 +1,350 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// DefaultRefreshInterval is the default interval of reloading the
+	// keys from their source.
+	DefaultRefreshInterval = time.Minute
+
+	// MinKeySize is the minimum size of the secret keys.
+	MinKeySize = 16
+
+	formatVersion = 1
+	keyIDSize     = 4
+	headerSize    = 1 + keyIDSize
+)
+
+var (
+	errNoKeys          = errors.New("secrets: no keys")
+	errInvalidMessage  = errors.New("secrets: invalid message")
+	errUnknownKey      = errors.New("secrets: unknown key")
+	errDecryptFailed   = errors.New("secrets: decryption failed")
+	errUnsupportedType = errors.New("secrets: unsupported source type")
+)
+
+// Source loads the secret keys, e.g. from a file. The first key is the
+// active one, used for the encryption and the signing, while all the
+// keys are accepted for the decryption and the verification, so that
+// the keys can be rotated by prepending a new key, and removing the
+// oldest one later.
+type Source interface {
+	Keys() ([][]byte, error)
+}
+
+type fileSource struct {
+	path string
+}
+
+type envSource struct {
+	name string
+}
+
+type key struct {
+	id      []byte
+	aead    cipher.AEAD
+	signing []byte
+}
+
+type keySet struct {
+	keys        []*key
+	fingerprint [sha256.Size]byte
+}
+
+// Keyring encrypts, decrypts, signs and verifies messages with the keys
+// loaded from a source, and reloads the keys periodically.
+type Keyring struct {
+	source  Source
+	current atomic.Value
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+// NewFileSource creates a source loading the keys from a file, one key
+// per line. The empty lines and the lines starting with # are ignored.
+func NewFileSource(path string) Source {
+	return &fileSource{path: path}
+}
+
+// NewEnvSource creates a source loading the keys from an environment
+// variable, separated by commas.
+func NewEnvSource(name string) Source {
+	return &envSource{name: name}
+}
+
+// ParseSource parses a source in the format of file:<path> or
+// env:<variable name>.
+func ParseSource(s string) (Source, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("secrets: invalid source: %s", s)
+	}
+
+	switch parts[0] {
+	case "file":
+		return NewFileSource(parts[1]), nil
+	case "env":
+		return NewEnvSource(parts[1]), nil
+	default:
+		return nil, errUnsupportedType
+	}
+}
+
+func parseKeys(s string) [][]byte {
+	var keys [][]byte
+	for _, l := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == ',' }) {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		keys = append(keys, []byte(l))
+	}
+
+	return keys
+}
+
+func (s *fileSource) Keys() ([][]byte, error) {
+	b, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseKeys(string(b)), nil
+}
+
+func (s *envSource) Keys() ([][]byte, error) {
+	return parseKeys(os.Getenv(s.name)), nil
+}
+
+func derive(secret []byte, info string, size int) []byte {
+	b := make([]byte, size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte(info)), b); err != nil {
+		// the output of HKDF is limited only for the sizes way above
+		// these ones
+		panic(err)
+	}
+
+	return b
+}
+
+// the encryption and the signing keys, and the id of the key are derived
+// from the secret with HKDF, so that the secret is never used directly
+func newKey(secret []byte) (*key, error) {
+	if len(secret) < MinKeySize {
+		return nil, fmt.Errorf("secrets: key too short, minimum size: %d", MinKeySize)
+	}
+
+	block, err := aes.NewCipher(derive(secret, "skipper encryption", 32))
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &key{
+		id:      derive(secret, "skipper key id", keyIDSize),
+		aead:    aead,
+		signing: derive(secret, "skipper signing", sha256.Size),
+	}, nil
+}
+
+func loadKeySet(s Source) (*keySet, error) {
+	secrets, err := s.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(secrets) == 0 {
+		return nil, errNoKeys
+	}
+
+	ks := &keySet{fingerprint: sha256.Sum256(bytes.Join(secrets, []byte{0}))}
+	for _, si := range secrets {
+		k, err := newKey(si)
+		if err != nil {
+			return nil, err
+		}
+
+		ks.keys = append(ks.keys, k)
+	}
+
+	return ks, nil
+}
+
+// NewKeyring creates a keyring, loading the keys from the source. The
+// keys are reloaded in the refresh interval. 0 means the default
+// interval, 1m, while a negative value disables the reloading.
+func NewKeyring(s Source, refreshInterval time.Duration) (*Keyring, error) {
+	ks, err := loadKeySet(s)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Keyring{
+		source: s,
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	k.current.Store(ks)
+
+	if refreshInterval == 0 {
+		refreshInterval = DefaultRefreshInterval
+	}
+
+	if refreshInterval < 0 {
+		close(k.done)
+		return k, nil
+	}
+
+	go k.run(refreshInterval)
+	return k, nil
+}
+
+func (k *Keyring) keys() *keySet {
+	return k.current.Load().(*keySet)
+}
+
+// reloads the keys, and keeps the current ones on failure
+func (k *Keyring) refresh() {
+	ks, err := loadKeySet(k.source)
+	if err != nil {
+		log.Errorf("error while reloading secret keys: %v", err)
+		return
+	}
+
+	if ks.fingerprint != k.keys().fingerprint {
+		log.Infof("secret keys reloaded, number of keys: %d", len(ks.keys))
+		k.current.Store(ks)
+	}
+}
+
+func (k *Keyring) run(interval time.Duration) {
+	defer close(k.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			k.refresh()
+		case <-k.quit:
+			return
+		}
+	}
+}
+
+func (ks *keySet) find(id []byte) (*key, bool) {
+	for i, k := range ks.keys {
+		if hmac.Equal(k.id, id) {
+			return k, i == 0
+		}
+	}
+
+	return nil, false
+}
+
+// Encrypt encrypts and authenticates a message with the active key,
+// using AES-256-GCM.
+func (k *Keyring) Encrypt(plaintext []byte) ([]byte, error) {
+	key := k.keys().keys[0]
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	// the header is authenticated as additional data, that must not
+	// overlap the output
+	header := append([]byte{formatVersion}, key.id...)
+	c := make([]byte, 0, headerSize+len(nonce)+len(plaintext)+key.aead.Overhead())
+	c = append(append(c, header...), nonce...)
+	return key.aead.Seal(c, nonce, plaintext, header), nil
+}
+
+// Decrypt decrypts a message encrypted by the keyring. The rotated
+// return value is true, when the message was encrypted with a key that is
+// not the active one anymore, signaling that the message needs to be
+// encrypted again, e.g. in case of a cookie, it needs to be set again.
+func (k *Keyring) Decrypt(ciphertext []byte) (plaintext []byte, rotated bool, err error) {
+	if len(ciphertext) < headerSize || ciphertext[0] != formatVersion {
+		return nil, false, errInvalidMessage
+	}
+
+	key, active := k.keys().find(ciphertext[1:headerSize])
+	if key == nil {
+		return nil, false, errUnknownKey
+	}
+
+	ns := key.aead.NonceSize()
+	if len(ciphertext) < headerSize+ns {
+		return nil, false, errInvalidMessage
+	}
+
+	nonce := ciphertext[headerSize : headerSize+ns]
+	plaintext, err = key.aead.Open(nil, nonce, ciphertext[headerSize+ns:], ciphertext[:headerSize])
+	if err != nil {
+		return nil, false, errDecryptFailed
+	}
+
+	return plaintext, !active, nil
+}
+
+func (k *key) sign(data []byte) []byte {
+	mac := hmac.New(sha256.New, k.signing)
+	mac.Write(data)
+	return append(append([]byte{formatVersion}, k.id...), mac.Sum(nil)...)
+}
+
+// Sign returns the HMAC-SHA256 signature of the data with the active
+// key, prefixed with the id of the key.
+func (k *Keyring) Sign(data []byte) []byte {
+	return k.keys().keys[0].sign(data)
+}
+
+// Verify verifies a signature created by the keyring. The rotated
+// return value is true, when the signature is valid, but it was created
+// with a key that is not the active one anymore.
+func (k *Keyring) Verify(data, signature []byte) (valid, rotated bool) {
+	if len(signature) < headerSize || signature[0] != formatVersion {
+		return false, false
+	}
+
+	key, active := k.keys().find(signature[1:headerSize])
+	if key == nil {
+		return false, false
+	}
+
+	return hmac.Equal(key.sign(data), signature), !active
+}
+
+// Close stops reloading the keys.
+func (k *Keyring) Close() {
+	select {
+	case <-k.quit:
+	default:
+		close(k.quit)
+	}
+
+	<-k.done
+}
@@ -0,0 +1,220 @@
+package secrets
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	rotatedKey = "old-secret-key-0123456789"
+	activeKey  = "new-secret-key-0123456789"
+)
+
+type staticSource [][]byte
+
+func (s staticSource) Keys() ([][]byte, error) { return s, nil }
+
+func keyring(t *testing.T, keys ...string) *Keyring {
+	var s staticSource
+	for _, k := range keys {
+		s = append(s, []byte(k))
+	}
+
+	k, err := NewKeyring(s, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k := keyring(t, activeKey)
+	defer k.Close()
+
+	c, err := k.Encrypt([]byte("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(c, []byte("foo")) {
+		t.Error("failed to encrypt")
+	}
+
+	p, rotated, err := k.Decrypt(c)
+	if err != nil || string(p) != "foo" || rotated {
+		t.Errorf("failed to decrypt: %q, %v, %v", p, rotated, err)
+	}
+
+	c2, err := k.Encrypt([]byte("foo"))
+	if err != nil || bytes.Equal(c, c2) {
+		t.Error("failed to use a unique nonce")
+	}
+
+	c[len(c)-1] ^= 1
+	if _, _, err := k.Decrypt(c); err == nil {
+		t.Error("failed to detect tampering")
+	}
+
+	if _, _, err := k.Decrypt([]byte{1, 2}); err == nil {
+		t.Error("failed to fail on short message")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old := keyring(t, rotatedKey)
+	defer old.Close()
+
+	c, err := old.Encrypt([]byte("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := old.Sign([]byte("bar"))
+
+	rotated := keyring(t, activeKey, rotatedKey)
+	defer rotated.Close()
+
+	p, isRotated, err := rotated.Decrypt(c)
+	if err != nil || string(p) != "foo" || !isRotated {
+		t.Errorf("failed to decrypt with rotated key: %q, %v, %v", p, isRotated, err)
+	}
+
+	if valid, isRotated := rotated.Verify([]byte("bar"), signature); !valid || !isRotated {
+		t.Errorf("failed to verify with rotated key: %v, %v", valid, isRotated)
+	}
+
+	removed := keyring(t, activeKey)
+	defer removed.Close()
+
+	if _, _, err := removed.Decrypt(c); err != errUnknownKey {
+		t.Errorf("failed to reject removed key: %v", err)
+	}
+
+	if valid, _ := removed.Verify([]byte("bar"), signature); valid {
+		t.Error("failed to reject the signature of removed key")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	k := keyring(t, activeKey)
+	defer k.Close()
+
+	s := k.Sign([]byte("foo"))
+	if valid, rotated := k.Verify([]byte("foo"), s); !valid || rotated {
+		t.Error("failed to verify")
+	}
+
+	if valid, _ := k.Verify([]byte("bar"), s); valid {
+		t.Error("failed to reject invalid signature")
+	}
+}
+
+func TestInvalidKeys(t *testing.T) {
+	if _, err := NewKeyring(staticSource{}, -1); err == nil {
+		t.Error("failed to fail without keys")
+	}
+
+	if _, err := NewKeyring(staticSource{[]byte("short")}, -1); err == nil {
+		t.Error("failed to fail with short key")
+	}
+}
+
+func TestSources(t *testing.T) {
+	d, err := ioutil.TempDir("", "skipper-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(d)
+	fn := filepath.Join(d, "keys")
+	if err := ioutil.WriteFile(fn, []byte("# active key\n"+activeKey+"\n\n"+rotatedKey+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("SKIPPER_TEST_SECRETS", activeKey+","+rotatedKey)
+	defer os.Unsetenv("SKIPPER_TEST_SECRETS")
+
+	for _, s := range []string{"file:" + fn, "env:SKIPPER_TEST_SECRETS"} {
+		src, err := ParseSource(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		keys, err := src.Keys()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(keys) != 2 || string(keys[0]) != activeKey || string(keys[1]) != rotatedKey {
+			t.Errorf("failed to load keys from %s: %q", s, keys)
+		}
+	}
+
+	for _, s := range []string{"foo", "file:", "kms:foo"} {
+		if _, err := ParseSource(s); err == nil {
+			t.Errorf("failed to fail: %s", s)
+		}
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	d, err := ioutil.TempDir("", "skipper-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(d)
+	fn := filepath.Join(d, "keys")
+	if err := ioutil.WriteFile(fn, []byte(rotatedKey), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := NewKeyring(NewFileSource(fn), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer k.Close()
+	c, err := k.Encrypt([]byte("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(fn, []byte(activeKey+"\n"+rotatedKey), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	k.refresh()
+	if _, rotated, err := k.Decrypt(c); err != nil || !rotated {
+		t.Errorf("failed to refresh the keys: %v, %v", rotated, err)
+	}
+
+	// the current keys are kept on failure
+	os.Remove(fn)
+	k.refresh()
+	if _, _, err := k.Decrypt(c); err != nil {
+		t.Errorf("failed to keep the keys: %v", err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	k := keyring(t, activeKey)
+	r.Add("cookies", k)
+	if got, ok := r.Get("cookies"); !ok || got != k {
+		t.Error("failed to get keyring")
+	}
+
+	if _, ok := r.Get("foo"); ok {
+		t.Error("unexpected keyring")
+	}
+
+	r.Close()
+	if _, ok := r.Get("cookies"); ok {
+		t.Error("failed to close the registry")
+	}
+}
@@ -1,33 +1,82 @@
 package skipper
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/acme"
+	"github.com/zalando/skipper/admin"
+	"github.com/zalando/skipper/audit"
+	"github.com/zalando/skipper/banlist"
+	"github.com/zalando/skipper/capture"
+	"github.com/zalando/skipper/config"
 	"github.com/zalando/skipper/dataclients/kubernetes"
+	"github.com/zalando/skipper/errorreport"
 	"github.com/zalando/skipper/eskipfile"
 	"github.com/zalando/skipper/etcd"
+	"github.com/zalando/skipper/events"
 	"github.com/zalando/skipper/filters"
+	authfilter "github.com/zalando/skipper/filters/auth"
 	"github.com/zalando/skipper/filters/builtin"
+	cookiefilter "github.com/zalando/skipper/filters/cookie"
+	"github.com/zalando/skipper/filters/flowid"
+	geoipfilter "github.com/zalando/skipper/filters/geoip"
+	"github.com/zalando/skipper/filters/waf"
+	"github.com/zalando/skipper/geoip"
+	"github.com/zalando/skipper/health"
 	"github.com/zalando/skipper/innkeeper"
 	"github.com/zalando/skipper/logging"
+	"github.com/zalando/skipper/maintenance"
+	"github.com/zalando/skipper/maxprocs"
 	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/namespace"
+	snet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/predicates/clientcert"
 	"github.com/zalando/skipper/predicates/cookie"
+	geoippredicate "github.com/zalando/skipper/predicates/geoip"
 	"github.com/zalando/skipper/predicates/interval"
 	"github.com/zalando/skipper/predicates/query"
 	"github.com/zalando/skipper/predicates/source"
 	"github.com/zalando/skipper/predicates/traffic"
+	"github.com/zalando/skipper/profiling"
 	"github.com/zalando/skipper/proxy"
+	"github.com/zalando/skipper/routesig"
 	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/secrets"
+	"github.com/zalando/skipper/slowclient"
+	"github.com/zalando/skipper/spiffe"
+	"github.com/zalando/skipper/strictparsing"
+	"github.com/zalando/skipper/systemd"
+	"github.com/zalando/skipper/tap"
+	"github.com/zalando/skipper/tlsconfig"
+	"github.com/zalando/skipper/tracing"
+	"github.com/zalando/skipper/vault"
 )
 
 const (
 	defaultSourcePollTimeout   = 30 * time.Millisecond
 	defaultRoutingUpdateBuffer = 1 << 5
+	defaultACMECache           = "/var/cache/skipper/acme"
+	defaultLivenessPath        = "/alive"
+	defaultReadinessPath       = "/ready"
+	readyCheckInterval         = 100 * time.Millisecond
+	listenerCheckTimeout       = 5 * time.Second
 )
 
 // Options to start skipper.
@@ -116,6 +165,83 @@ type Options struct {
 	// File containing static route definitions.
 	RoutesFile string
 
+	// The public keys trusted to sign the route definitions, in PEM
+	// format. When set, the routes file and the routes in etcd are
+	// accepted only with a valid signature, and the other data
+	// sources can't be used.
+	RouteSigningKeys []string
+
+	// When set, the admin API is served on this address, to list,
+	// add, update and delete routes at runtime, and to change the
+	// log level, the access log debug fields and the trace sampling.
+	// See the admin package. Without AdminCertPathTLS and
+	// AdminKeyPathTLS, it needs to be a loopback address, and when
+	// the host is not set, e.g. :9922, it is bound to localhost.
+	AdminAddress string
+
+	// The certificate and the key file of the admin listener. When
+	// set, the admin API is served with TLS, on any address, with
+	// the same TLS version and cipher suite restrictions as the proxy
+	// listener.
+	AdminCertPathTLS string
+	AdminKeyPathTLS  string
+
+	// The source of the bearer tokens accepted by the admin API, in
+	// the format of file:<path> or env:<variable>. Required when
+	// AdminAddress is set.
+	AdminTokens string
+
+	// The number of the last applied versions of the routing table
+	// kept in memory, that the admin API can roll back to. Default:
+	// 10. When negative, no history is kept.
+	RouteHistorySize int
+
+	// When set, the versions of the routing table are stored in this
+	// directory, too, and they are kept across restarts. It can't be
+	// used together with RouteSigningKeys.
+	RouteHistoryDir string
+
+	// When set, the route definitions of the routing table are saved
+	// in this file after every complete update and on shutdown, and
+	// on startup, the routing table is built from them, and served
+	// until the data clients are loaded. It is rebuilt only when the
+	// routes from the data clients differ from the saved ones. It
+	// can't be used together with RouteSigningKeys.
+	RoutingSnapshotFile string
+
+	// The IDs of the routes that respond with 503 in maintenance
+	// mode, switched with the admin API. When empty, all the
+	// requests are responded with 503.
+	MaintenanceRoutes []string
+
+	// The value of the Retry-After header of the maintenance
+	// responses. Default: 5m.
+	MaintenanceRetryAfter time.Duration
+
+	// The period of closing the existing connections after
+	// entering maintenance mode. Default: 30s. When negative, the
+	// connections are not drained.
+	MaintenanceDrainPeriod time.Duration
+
+	// The namespaces of the routes. A route belongs to a namespace,
+	// when its id starts with the name of the namespace and a double
+	// underscore, e.g. team_a__api. See the namespace package.
+	Namespaces []string
+
+	// The sources of the admin API tokens of the namespaces, mapped by
+	// the namespace names, in the same format as AdminTokens. These
+	// tokens grant access only to the routes of their namespace.
+	NamespaceTokens map[string]string
+
+	// The maximum number of requests per second served by the routes
+	// of the namespaces, mapped by the namespace names.
+	NamespaceRateLimits map[string]int
+
+	// A YAML file with the policies of the routes set with the tokens
+	// of the namespaces, mapped by the namespace names. A namespace
+	// without a policy cannot set routes. See namespace.Policy.
+	NamespacePolicyFile string
+
 	// Polling timeout of the routing data sources.
 	SourcePollTimeout time.Duration
 
@@ -134,6 +260,13 @@ type Options struct {
 	// by the proxy are closed.
 	CloseIdleConnsPeriod time.Duration
 
+	// The settings of the connections to specific backends, e.g.
+	// timeouts, client certificates or HTTP/2, mapped by the host of
+	// the backend. Every backend has its own connection pool, and the
+	// state of the pools is served on /transports of the support
+	// listener.
+	BackendTransports map[string]proxy.BackendTransport
+
 	// Flag indicating to ignore trailing slashes in paths during route
 	// lookup.
 	IgnoreTrailingSlash bool
@@ -145,6 +278,40 @@ type Options struct {
 	// Specifications of custom, user defined predicates.
 	CustomPredicates []routing.PredicateSpec
 
+	// When not empty, only the listed filters can be used in the
+	// routes.
+	AllowedFilters []string
+
+	// Filters that cannot be used in the routes, e.g. to forbid
+	// changing the backend on a public tier. The routes referencing
+	// them are rejected.
+	DisabledFilters []string
+
+	// When not empty, only the listed predicates, including the
+	// built-in ones like Path or Host, can be used in the routes.
+	AllowedPredicates []string
+
+	// Predicates that cannot be used in the routes, including the
+	// built-in ones. The routes referencing them are rejected.
+	DisabledPredicates []string
+
+	// The maximum number of the routes in the routing table. The
+	// updates exceeding it are rejected, and the previous routing
+	// table is kept. Zero means no limit.
+	MaxRoutes int
+
+	// The maximum number of filters in a route. Zero means no limit.
+	MaxFiltersPerRoute int
+
+	// The maximum complexity of the regular expressions in the
+	// routes, in the number of the instructions of the compiled
+	// expression. Zero means no limit.
+	MaxRegexpComplexity int
+
+	// The maximum estimated memory used by a route, in bytes. Zero
+	// means no limit.
+	MaxRouteSize int
+
 	// Custom data clients to be used together with the default etcd and Innkeeper.
 	CustomDataClients []routing.DataClient
 
@@ -156,6 +323,13 @@ type Options struct {
 	// Network address for the /metrics endpoint
 	MetricsListener string
 
+	// When set, the metrics are collected by this instance, instead of
+	// the one created from the metrics options, e.g. to export them
+	// from a program embedding skipper, or to plug in a custom metrics
+	// backend. When it is created with metrics.New, it is served on
+	// the MetricsListener, too, when set.
+	Metrics metrics.Metrics
+
 	// Skipper provides a set of metrics with different keys which are exposed via HTTP in JSON
 	// You can customize those key names with your own prefix
 	MetricsPrefix string
@@ -164,6 +338,68 @@ type Options struct {
 	// metrics listener.
 	EnableProfile bool
 
+	// When set, CPU and heap profiles are captured periodically, and
+	// pushed to this Pyroscope compatible ingest endpoint. See the
+	// profiling package.
+	ProfilingEndpoint string
+
+	// The interval of capturing the profiles. Default: 5m.
+	ProfilingInterval time.Duration
+
+	// Labels pushed with the profiles.
+	ProfilingLabels map[string]string
+
+	// If set, the serve latencies are recorded in Prometheus
+	// histograms, served on /metrics/prometheus, together with the
+	// other metrics, translated to Prometheus metrics with labels
+	// for the route, host, method and status code. When tracing is
+	// enabled, the trace IDs of the sampled requests are attached
+	// to the histogram buckets as exemplars, in the OpenMetrics
+	// format.
+	EnablePrometheusMetrics bool
+
+	// When set, the metrics are pushed to this Graphite server. The
+	// address can be prefixed with the network, e.g.
+	// udp://graphite.example.org:2003. Default network: tcp.
+	MetricsGraphiteAddr string
+
+	// The prefix of the keys pushed to Graphite. Defaults to
+	// MetricsPrefix.
+	MetricsGraphitePrefix string
+
+	// When set, the metrics are pushed to this StatsD server. The
+	// address can be prefixed with the network, e.g.
+	// tcp://statsd.example.org:8125. Default network: udp.
+	MetricsStatsdAddr string
+
+	// The prefix of the keys pushed to StatsD. Defaults to
+	// MetricsPrefix.
+	MetricsStatsdPrefix string
+
+	// The interval of pushing the metrics to Graphite and StatsD.
+	// Default: 10s.
+	MetricsReportInterval time.Duration
+
+	// The sampling of the durations measured by the timers: uniform,
+	// expdecay or hdr. Default: uniform.
+	MetricsTimerSample string
+
+	// The number of the durations kept by the uniform and the
+	// exponentially decaying samples. Default: 1024.
+	MetricsTimerReservoirSize int
+
+	// The number of the significant decimal digits of the durations
+	// counted by the HDR histograms, from 1 to 5. Default: 2.
+	MetricsHistogramPrecision int
+
+	// The highest duration tracked by the HDR histograms. Default: 1m.
+	MetricsHistogramMaxValue time.Duration
+
+	// When set, the counters, meters, timers and histograms can be
+	// reset on the metrics listener, with a DELETE request to
+	// /metrics, authorized with this token as a bearer token.
+	MetricsResetToken string
+
 	// Flag that enables reporting of the Go garbage collector statistics exported in debug.GCStats
 	EnableDebugGcMetrics bool
 
@@ -187,6 +423,11 @@ type Options struct {
 	// When /dev/stderr or /dev/stdout is passed in, it will be resolved
 	// to os.Stderr or os.Stdout.
 	//
+	// When a syslog URL (syslog+udp://, syslog+tcp:// or
+	// syslog+tls://) or an HTTP URL is passed in, the log entries
+	// are sent to the remote server, buffered and with retries. See
+	// logging.NewRemoteWriter.
+	//
 	// Warning: passing an arbitrary file will try to open it append
 	// on start and use it, or fail on start, but the current
 	// implementation doesn't support any more proper handling
@@ -201,6 +442,9 @@ type Options struct {
 	// When /dev/stderr or /dev/stdout is passed in, it will be resolved
 	// to os.Stderr or os.Stdout.
 	//
+	// Remote outputs are supported the same way as for the
+	// application log.
+	//
 	// Warning: passing an arbitrary file will try to open for append
 	// it on start and use it, or fail on start, but the current
 	// implementation doesn't support any more proper handling
@@ -210,13 +454,229 @@ type Options struct {
 	// Disables the access log.
 	AccessLogDisabled bool
 
+	// Format of the access log entries, possible values: default,
+	// combined, common and json. The default format is the Apache
+	// combined log format, extended with the request duration and
+	// the requested host.
+	AccessLogFormat string
+
+	// Fields written by the JSON access log. When empty, the fields
+	// in logging.DefaultJSONAccessLogFields are written. The country
+	// and the asn fields are written only when listed explicitly.
+	AccessLogJSONFields []string
+
+	// Request headers whose values are written by the JSON access
+	// log.
+	AccessLogRequestHeaders []string
+
+	// Response headers whose values are written by the JSON access
+	// log.
+	AccessLogResponseHeaders []string
+
+	// Sample rates of the access log entries by response status
+	// class, e.g. {2: 0.01, 3: 0.1}. The classes without a rate are
+	// always logged.
+	AccessLogSampleRates map[int]float64
+
+	// Routes excluded from the access log, e.g. health checks.
+	AccessLogExcludeRoutes []string
+
+	// Path prefixes excluded from the access log.
+	AccessLogExcludePaths []string
+
+	// Enables the capturing of the full requests and responses,
+	// triggered by the X-Skipper-Capture header from the trusted
+	// networks, or by the capture() filter. The captured requests
+	// are served on the /capture path of the metrics listener. See
+	// the capture package.
+	EnableCapture bool
+
+	// The number of the captured requests kept in memory.
+	// Default: 100.
+	CaptureBufferSize int
+
+	// The maximum number of bytes recorded from the request and
+	// response bodies. Default: 64k.
+	CaptureMaxBodySize int64
+
+	// Networks, in CIDR notation, whose clients can trigger the
+	// capturing with the X-Skipper-Capture header.
+	CaptureTrustedNetworks []string
+
+	// The bearer token required by the /capture path. Required, when
+	// the capturing is enabled.
+	CaptureToken string
+
+	// When set, the credential and cookie headers are captured in
+	// clear text, instead of being redacted.
+	CaptureKeepCredentials bool
+
+	// Enables streaming the summary of the live requests on the /tap
+	// path of the metrics listener, as server-sent events. See the
+	// tap package.
+	EnableTap bool
+
+	// The bearer token required by the tap endpoint.
+	TapToken string
+
+	// The fraction of the requests streamed by the tap endpoint.
+	// Default: 1.
+	TapSampleRate float64
+
+	// The request headers included in the streamed events.
+	TapHeaders []string
+
+	// When set, skipper starts an additional listener on this
+	// address, that doesn't forward the requests to the backends,
+	// but responds with the details of the route matching and the
+	// filter processing. Besides the plain requests, it accepts
+	// synthetic requests described in JSON on the
+	// /__skipper/dryrun path. See proxy.NewDryRunHandler.
 	DebugListener string
 
-	//Path of certificate when using TLS
+	//Path of certificate when using TLS. It can be a comma separated
+	//list, to serve multiple certificates, selected by the server name
+	//sent by the clients (SNI). The first one is the default.
 	CertPathTLS string
-	//Path of key when using TLS
+	//Path of key when using TLS. When multiple certificates are used,
+	//a comma separated list, in the same order as the certificates.
 	KeyPathTLS string
 
+	// Directory containing certificate and key file pairs, named as
+	// <name>.crt and <name>.key, served in addition to the ones set
+	// in CertPathTLS and KeyPathTLS.
+	CertDirTLS string
+
+	// Kubernetes secrets of the type kubernetes.io/tls, referenced as
+	// <namespace>/<name>, whose certificates are served in addition
+	// to the ones loaded from the files. The secrets are accessed
+	// with the KubernetesInCluster and KubernetesURL settings.
+	TLSKubernetesSecrets []string
+
+	// The path of the issue endpoint of a Vault PKI role, e.g.
+	// pki/issue/skipper. When set, a certificate issued by Vault is
+	// served in addition to the other certificates, and renewed before
+	// it expires. The Vault token is taken from the VAULT_TOKEN
+	// environment variable.
+	VaultPKIPath string
+
+	// The address of the Vault server. Default: the value of the
+	// VAULT_ADDR environment variable.
+	VaultAddress string
+
+	// The common name and the subject alternative names of the
+	// certificate issued by Vault.
+	VaultPKICommonName string
+	VaultPKIAltNames   []string
+
+	// The requested lifetime of the certificate issued by Vault.
+	// Default: the TTL of the role.
+	VaultPKITTL time.Duration
+
+	// The interval of checking the certificate files for changes,
+	// and reloading them without restarting. Default: 1m. When
+	// negative, the certificates are not reloaded.
+	TLSReloadInterval time.Duration
+
+	// When set, and TLS is enabled, only HTTP/1.1 is offered to the
+	// clients. Otherwise, HTTP/2 is negotiated with ALPN.
+	DisableHTTP2 bool
+
+	// When set, and TLS is enabled, skipper accepts the plaintext
+	// requests on this address, and redirects them to the TLS
+	// listener.
+	TLSRedirectAddress string
+
+	// The minimum and the maximum TLS version accepted by the
+	// listener, one of 1.0, 1.1, 1.2 and 1.3. Default: the Go
+	// defaults.
+	TLSMinVersion string
+	TLSMaxVersion string
+
+	// The cipher suites accepted by the listener, with the names
+	// defined in the crypto/tls package. The cipher suites of TLS 1.3
+	// are not configurable.
+	TLSCipherSuites []string
+
+	// The elliptic curves accepted by the listener, in the order of
+	// preference: X25519, P256, P384 or P521.
+	TLSCurves []string
+
+	// Whether the listener verifies the client certificates: none,
+	// optional or required. In optional mode, the clients without a
+	// certificate are accepted, too. See the ClientCert predicate,
+	// and the clientCertAuth and clientCertHeaders filters.
+	TLSClientAuth string
+
+	// The PEM files of the CA certificates that the client
+	// certificates are verified with.
+	TLSClientCAFiles []string
+
+	// When set, the OCSP responses of the TLS certificates are
+	// stapled to the handshakes, and refreshed in the background.
+	EnableOCSPStapling bool
+
+	// The interval of checking whether the stapled OCSP responses
+	// need to be refreshed. Default: 10m.
+	OCSPCheckInterval time.Duration
+
+	// The minimum and the maximum TLS version of the connections to
+	// the backends.
+	UpstreamTLSMinVersion string
+	UpstreamTLSMaxVersion string
+
+	// The cipher suites of the connections to the backends.
+	UpstreamTLSCipherSuites []string
+
+	// The elliptic curves of the connections to the backends.
+	UpstreamTLSCurves []string
+
+	// When set, the TLS connections of the proxy listener and of the
+	// backends are restricted to the algorithms approved by FIPS
+	// 140-2, and the configuration that is not compliant, e.g.
+	// skipping the TLS verification, is rejected during the startup.
+	FIPS bool
+
+	// When set, the X.509 SVIDs received from the SPIFFE workload API
+	// are used as the client certificates of the connections to the
+	// backends, and the backends are verified by their SPIFFE ID.
+	EnableSPIFFE bool
+
+	// The address of the SPIFFE workload API, e.g.
+	// unix:///run/spire/sockets/agent.sock. When empty, it is taken
+	// from the SPIFFE_ENDPOINT_SOCKET environment variable.
+	SPIFFESocket string
+
+	// The SPIFFE IDs, or trust domains, of the accepted backends. When
+	// empty, the backends of the trust domain of the proxy are
+	// accepted.
+	SPIFFEAllowedIDs []string
+
+	// When set, the TLS certificates are obtained and renewed with
+	// the ACME protocol, e.g. from Let's Encrypt, for the server names
+	// that the configured certificates don't match. See the acme
+	// package.
+	EnableACME bool
+
+	// The domains that the certificates are obtained for with ACME.
+	ACMEDomains []string
+
+	// When set, the certificates are obtained with ACME for the hosts
+	// of the routes, too, that are matched by a Host predicate with a
+	// literal host name.
+	ACMEHostsFromRoutes bool
+
+	// The contact email address of the ACME account.
+	ACMEEmail string
+
+	// Where the ACME account key and the certificates are stored: a
+	// directory, s3://bucket/prefix, or etcd:///prefix, using the
+	// etcd URLs. Default: /var/cache/skipper/acme.
+	ACMECache string
+
+	// The directory URL of the ACME server. Default: Let's Encrypt.
+	ACMEDirectoryURL string
+
 	// Flush interval for upgraded Proxy connections
 	BackendFlushInterval time.Duration
 
@@ -224,13 +684,303 @@ type Options struct {
 	ExperimentalUpgrade bool
 
 	MaxLoopbacks int
+
+	// When set, the proxy generates a flow id for the incoming
+	// requests without a valid X-Flow-Id header. The flow id is used
+	// as the correlation ID of the request in the access log, the
+	// error logs and the trace spans.
+	GenerateFlowID bool
+
+	// When set, the proxy sets the Server-Timing header of every
+	// response, with the durations of its processing phases.
+	// Otherwise, it is set only for the routes with the serverTiming
+	// filter.
+	ServerTiming bool
+
+	// When greater than 0, the Strict-Transport-Security header is set
+	// in the responses of the HTTPS requests, with this max-age. It is
+	// part of the proxy level security header policy, that can be
+	// turned off for individual routes with the disableSecurityHeaders
+	// filter.
+	HSTSMaxAge time.Duration
+
+	// Adds the includeSubDomains and the preload directives to the
+	// Strict-Transport-Security header.
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+
+	// When set, the Server, Via and X-Powered-By headers are removed
+	// from the responses.
+	RemoveFingerprintHeaders bool
+
+	// Response headers removed from all the responses.
+	StripResponseHeaders []string
+
+	// When set, the clients that repeatedly receive 401, 403 or 429
+	// responses are temporarily banned. The bans are listed on the
+	// support listener at /bans. See the banlist package.
+	EnableBanList bool
+
+	// The number of violations within the window, that gets a
+	// client banned. Default: 10.
+	BanListThreshold int
+
+	// The time window of counting the violations. Default: 1m.
+	BanListWindow time.Duration
+
+	// The duration of the bans. Default: 10m.
+	BanListDuration time.Duration
+
+	// The status codes counted as violations. Default: 401, 403 and
+	// 429.
+	BanListStatuses []int
+
+	// When set, the clients are identified by their Authorization
+	// header, too.
+	BanListByToken bool
+
+	// When set, the IP of the clients is taken from the
+	// X-Forwarded-For header.
+	BanListTrustForwarded bool
+
+	// When set, the bans are shared through Redis, set as
+	// redis://[:password@]host[:port][/db].
+	BanListRedisURL string
+
+	// Web application firewall rule files, in the format of
+	// ModSecurity. When set, the waf() filter is available for the
+	// routes. See the filters/waf package.
+	WAFRules []string
+
+	// The default mode of the waf() filter: block or detect. Default:
+	// block.
+	WAFMode string
+
+	// When set, the waf rules of the response phases are evaluated
+	// against the responses.
+	WAFInspectResponse bool
+
+	// The maximum size of the request and response bodies inspected by
+	// the waf rules. Default: 128k.
+	WAFMaxBodySize int64
+
+	// When set, the request bodies longer than WAFMaxBodySize, or
+	// encoded, e.g. with gzip, are inspected only partially, or not at
+	// all, instead of being rejected in block mode.
+	WAFPartialBodyInspection bool
+
+	// When set, the requests on the proxy listener are rejected, when
+	// they have ambiguous framing, e.g. both Content-Length and
+	// Transfer-Encoding, invalid characters, or oversized headers. On
+	// the TLS listener, HTTP/2 is disabled by it. See the
+	// strictparsing package.
+	StrictParsing bool
+
+	// The maximum size of the request line and the header fields of
+	// the incoming requests. Default: 1M.
+	MaxHeaderBytes int
+
+	// When greater than 1, the proxy listens with this many sockets on
+	// the same address, using SO_REUSEPORT, each with its own accept
+	// loop, so that the kernel balances the incoming connections
+	// between them. When negative, the number of the listeners is set
+	// to GOMAXPROCS. Supported only on Linux.
+	ReusePortListeners int
+
+	// When set, GOMAXPROCS is lowered to the CPU quota of the cgroup of
+	// the container, unless the GOMAXPROCS environment variable is set.
+	// See the maxprocs package.
+	AdjustMaxProcs bool
+
+	// Named keyrings available to the filters that need secret keys,
+	// e.g. encryptCookie. The values are the sources of the keys:
+	// file:<path>, env:<variable name> or vault:<path>[#<field>]. The
+	// first key of a source is the active one, and the rest of the
+	// keys are accepted for decryption during rotation. See the
+	// secrets package.
+	Secrets map[string]string
+
+	// The interval of reloading the secret keys from their sources.
+	// Default: 1m.
+	SecretsRefreshInterval time.Duration
+
+	// GeoIP databases in the MaxMind DB format, e.g. the country and
+	// the ASN databases. When set, the Country and the ASN predicates
+	// and the geoip filter are available, and the country and the
+	// ASN of the clients can be written to the JSON access log.
+	GeoIPDatabases []string
+
+	// The interval of checking the GeoIP database files for changes.
+	// Default: 1m.
+	GeoIPReloadInterval time.Duration
+
+	// When set, the GeoIP lookups and the IP reputation checks use
+	// the address from the X-Forwarded-For header. Only use it, when
+	// skipper runs behind a load balancer that sets the header.
+	GeoIPTrustForwarded bool
+
+	// Feeds of the known bad IP addresses and networks, as file paths
+	// or http(s) URLs. When set, the requests from these sources are
+	// rejected with 403.
+	IPReputationFeeds []string
+
+	// The interval of reloading the IP reputation feeds. Default:
+	// 10m.
+	IPReputationRefresh time.Duration
+
+	// The maximum time of receiving the header of a request, counted
+	// from accepting the connection, or from the first byte of the
+	// subsequent requests on the keep-alive connections. 0 means no
+	// limit.
+	SlowClientHeaderTimeout time.Duration
+
+	// The minimum transfer rate of the request bodies, in bytes per
+	// second. 0 means no limit.
+	SlowClientMinBodyRate int
+
+	// The initial time allowed for receiving a request body, when the
+	// minimum transfer rate is enforced. Default: 10s.
+	SlowClientBodyTimeout time.Duration
+
+	// The maximum number of the concurrent connections from the same
+	// IP address. 0 means no limit.
+	MaxConnsPerIP int
+
+	// The time of caching the accepted credentials by the auth
+	// filters, e.g. basicAuth. 0 disables the caching.
+	AuthCacheTTL time.Duration
+
+	// The time of caching the rejected credentials by the auth
+	// filters. Default: 10s.
+	AuthCacheNegativeTTL time.Duration
+
+	// The maximum number of the cached auth decisions. Default:
+	// 10000.
+	AuthCacheMaxEntries int
+
+	// The Sentry DSN of the project that the panics, the filter panics
+	// and the bursts of 5xx responses are reported to. When empty,
+	// the errors are not reported. See the errorreport package.
+	ErrorReportingDSN string
+
+	// The environment reported with the error events.
+	ErrorReportingEnvironment string
+
+	// The maximum number of error events sent per minute.
+	// Default: 60.
+	ErrorReportingRateLimit int
+
+	// The number of 5xx responses of a route per minute that is
+	// reported as an error event. Default: 10.
+	ErrorBurstThreshold int
+
+	// Event bus that the changes of the proxy state are published
+	// on, e.g. the updates of the routing table. Programs embedding
+	// skipper can subscribe to it. When not set, a new bus is created.
+	EventBus *events.Bus
+
+	// When set, the events are posted to this URL, as JSON objects.
+	EventWebhookURL string
+
+	// When set, the liveness and the readiness of the proxy are
+	// served on the support paths of the metrics listener, including
+	// the connectivity of the data clients. See the health package.
+	EnableHealthEndpoints bool
+
+	// The path of the liveness endpoint. Default: /alive.
+	LivenessPath string
+
+	// The path of the readiness endpoint. Posting to the drain
+	// subpath, e.g. /ready/drain, forces the proxy to be not ready.
+	// Default: /ready.
+	ReadinessPath string
+
+	// When set, on SIGTERM, the proxy is reported as not ready, and
+	// it keeps serving the requests for this duration, before the
+	// listener is shut down gracefully.
+	DrainDelay time.Duration
+
+	// The effective configuration of the instance, e.g. the resolved
+	// command line flags, with the secrets redacted. When set, it is
+	// served as JSON on the /config path of the metrics listener. See
+	// config.Effective.
+	EffectiveConfig config.Values
+
+	// When set, the applied changes of the routing table are recorded,
+	// and served on the /audit path of the metrics listener. See the
+	// audit package.
+	EnableRouteAudit bool
+
+	// The number of the route audit entries kept in memory.
+	// Default: 1000.
+	RouteAuditMaxEntries int
+
+	// When set, the route audit entries are appended to this file.
+	RouteAuditFile string
+
+	// Name of the tracer used to trace the proxied requests. Possible
+	// values: noop, jaeger, otel, zipkin. Default: noop.
+	Tracer string
+
+	// Service name that the recorded spans are reported with.
+	// Default: skipper.
+	TracerServiceName string
+
+	// Address where the recorded spans are sent to. See
+	// tracing.Options.Endpoint.
+	TracerEndpoint string
+
+	// Fraction of the traces sampled, when the incoming request
+	// doesn't carry a sampling decision. When 0, every trace is
+	// sampled.
+	TracerSampleRate float64
+
+	// Sampling strategy of the tracer, possible values: parentbased,
+	// ratio. Default: parentbased.
+	TracerSampler string
+
+	// Format of the span context in the HTTP headers, possible values:
+	// jaeger, w3c, b3, b3single. Default: the format of the tracer.
+	TracerPropagation string
 }
 
 func createDataClients(o Options, auth innkeeper.Authentication) ([]routing.DataClient, error) {
-	var clients []routing.DataClient
+	var (
+		clients  []routing.DataClient
+		verifier *routesig.Verifier
+	)
+
+	if len(o.RouteSigningKeys) > 0 {
+		if o.InnkeeperUrl != "" || o.Kubernetes {
+			return nil, errors.New("route signatures are supported only by the routes file and etcd")
+		}
+
+		// the routes stored on the disk are not signed
+		if o.RouteHistoryDir != "" || o.RoutingSnapshotFile != "" {
+			return nil, errors.New("the route history directory and the routing snapshot can't be used together with the route signatures")
+		}
+
+		var err error
+		if verifier, err = routesig.New(routesig.Options{
+			KeyFiles: o.RouteSigningKeys,
+			EventBus: o.EventBus,
+		}); err != nil {
+			return nil, err
+		}
+	}
 
 	if o.RoutesFile != "" {
-		f, err := eskipfile.Open(o.RoutesFile)
+		var (
+			f   *eskipfile.Client
+			err error
+		)
+
+		if verifier != nil {
+			f, err = eskipfile.OpenVerified(o.RoutesFile, verifier)
+		} else {
+			f, err = eskipfile.Open(o.RoutesFile)
+		}
+
 		if err != nil {
 			log.Error("error while opening eskip file", err)
 			return nil, err
@@ -262,6 +1012,7 @@ func createDataClients(o Options, auth innkeeper.Authentication) ([]routing.Data
 			Prefix:    o.EtcdPrefix,
 			Timeout:   o.EtcdWaitTimeout,
 			Insecure:  o.EtcdInsecure,
+			Verifier:  verifier,
 		})
 
 		if err != nil {
@@ -288,7 +1039,11 @@ func createDataClients(o Options, auth innkeeper.Authentication) ([]routing.Data
 	return clients, nil
 }
 
-func getLogOutput(name string) (io.Writer, error) {
+func getLogOutput(name, kind string) (io.Writer, error) {
+	if logging.IsRemoteOutput(name) {
+		return logging.NewRemoteWriter(name, kind)
+	}
+
 	name = path.Clean(name)
 
 	if name == "/dev/stdout" {
@@ -310,151 +1065,1505 @@ func initLog(o Options) error {
 	)
 
 	if o.ApplicationLogOutput != "" {
-		logOutput, err = getLogOutput(o.ApplicationLogOutput)
+		logOutput, err = getLogOutput(o.ApplicationLogOutput, "application")
 		if err != nil {
 			return err
 		}
 	}
 
 	if !o.AccessLogDisabled && o.AccessLogOutput != "" {
-		accessLogOutput, err = getLogOutput(o.AccessLogOutput)
+		accessLogOutput, err = getLogOutput(o.AccessLogOutput, "access")
 		if err != nil {
 			return err
 		}
 	}
 
-	logging.Init(logging.Options{
-		ApplicationLogPrefix: o.ApplicationLogPrefix,
-		ApplicationLogOutput: logOutput,
-		AccessLogOutput:      accessLogOutput,
-		AccessLogDisabled:    o.AccessLogDisabled})
-
-	return nil
+	return logging.Init(logging.Options{
+		ApplicationLogPrefix:     o.ApplicationLogPrefix,
+		ApplicationLogOutput:     logOutput,
+		AccessLogOutput:          accessLogOutput,
+		AccessLogDisabled:        o.AccessLogDisabled,
+		AccessLogFormat:          o.AccessLogFormat,
+		AccessLogJSONFields:      o.AccessLogJSONFields,
+		AccessLogRequestHeaders:  o.AccessLogRequestHeaders,
+		AccessLogResponseHeaders: o.AccessLogResponseHeaders,
+		AccessLogSampleRates:     o.AccessLogSampleRates,
+		AccessLogExcludeRoutes:   o.AccessLogExcludeRoutes,
+		AccessLogExcludePaths:    o.AccessLogExcludePaths})
 }
 
 func (o *Options) isHTTPS() bool {
-	return o.CertPathTLS != "" && o.KeyPathTLS != ""
+	return o.CertPathTLS != "" && o.KeyPathTLS != "" ||
+		o.CertDirTLS != "" ||
+		len(o.TLSKubernetesSecrets) > 0 ||
+		o.VaultPKIPath != "" ||
+		o.EnableACME
 }
 
-func listenAndServe(proxy http.Handler, o *Options) error {
-	// create the access log handler
-	loggingHandler := logging.NewHandler(proxy)
-	log.Infof("proxy listener on %v", o.Address)
-	if o.isHTTPS() {
-		return http.ListenAndServeTLS(o.Address, o.CertPathTLS, o.KeyPathTLS, loggingHandler)
+// the providers of the certificates from the sources other than files
+func (o *Options) certProviders() ([]tlsconfig.Provider, error) {
+	var providers []tlsconfig.Provider
+	if len(o.TLSKubernetesSecrets) > 0 {
+		p, err := kubernetes.NewSecretProvider(kubernetes.Options{
+			KubernetesInCluster: o.KubernetesInCluster,
+			KubernetesURL:       o.KubernetesURL,
+		}, o.TLSKubernetesSecrets)
+		if err != nil {
+			return nil, err
+		}
+
+		providers = append(providers, p)
+	}
+
+	if o.VaultPKIPath != "" {
+		p, err := vault.NewPKIProvider(vault.Options{
+			Address:    o.VaultAddress,
+			Path:       o.VaultPKIPath,
+			CommonName: o.VaultPKICommonName,
+			AltNames:   o.VaultPKIAltNames,
+			TTL:        o.VaultPKITTL,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		providers = append(providers, p)
 	}
-	log.Infof("certPathTLS or keyPathTLS not found, defaulting to HTTP")
-	return http.ListenAndServe(o.Address, loggingHandler)
+
+	return providers, nil
 }
 
-// Run skipper.
-func Run(o Options) error {
-	// init log
-	err := initLog(o)
+// the keyrings available to the filters, loaded from the configured
+// sources
+func (o *Options) secretsRegistry() (*secrets.Registry, error) {
+	r := secrets.NewRegistry()
+	for name, spec := range o.Secrets {
+		var (
+			source secrets.Source
+			err    error
+		)
+
+		if strings.HasPrefix(spec, "vault:") {
+			path, field := strings.TrimPrefix(spec, "vault:"), ""
+			if i := strings.LastIndex(path, "#"); i >= 0 {
+				path, field = path[:i], path[i+1:]
+			}
+
+			source, err = vault.NewKVSource(vault.KVOptions{
+				Address: o.VaultAddress,
+				Path:    path,
+				Field:   field,
+			})
+		} else {
+			source, err = secrets.ParseSource(spec)
+		}
+
+		var k *secrets.Keyring
+		if err == nil {
+			k, err = secrets.NewKeyring(source, o.SecretsRefreshInterval)
+		}
+
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("error while loading the secret keys of %s: %v", name, err)
+		}
+
+		r.Add(name, k)
+	}
+
+	return r, nil
+}
+
+// the namespaces of the routes, or nil, when not set
+func (o *Options) namespaces() (*namespace.Namespaces, error) {
+	if len(o.Namespaces) == 0 {
+		if len(o.NamespaceTokens) > 0 || len(o.NamespaceRateLimits) > 0 || o.NamespacePolicyFile != "" {
+			return nil, errors.New("the namespace tokens, rate limits and policies require namespaces")
+		}
+
+		return nil, nil
+	}
+
+	tokens := make(map[string]secrets.Source)
+	for name, spec := range o.NamespaceTokens {
+		s, err := secrets.ParseSource(spec)
+		if err != nil {
+			return nil, fmt.Errorf("error while parsing the tokens of namespace %s: %v", name, err)
+		}
+
+		tokens[name] = s
+	}
+
+	var policies map[string]namespace.Policy
+	if o.NamespacePolicyFile != "" {
+		var err error
+		if policies, err = namespace.LoadPolicies(o.NamespacePolicyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return namespace.New(namespace.Options{
+		Names:      o.Namespaces,
+		Tokens:     tokens,
+		RateLimits: o.NamespaceRateLimits,
+		Policies:   policies,
+	})
+}
+
+// the proxy level security header policy, or nil, when not set
+func (o *Options) securityHeaders() *proxy.SecurityHeaders {
+	if o.HSTSMaxAge <= 0 && !o.RemoveFingerprintHeaders && len(o.StripResponseHeaders) == 0 {
+		return nil
+	}
+
+	return &proxy.SecurityHeaders{
+		HSTSMaxAge:            o.HSTSMaxAge,
+		HSTSIncludeSubdomains: o.HSTSIncludeSubdomains,
+		HSTSPreload:           o.HSTSPreload,
+		RemoveFingerprint:     o.RemoveFingerprintHeaders,
+		StripHeaders:          o.StripResponseHeaders,
+	}
+}
+
+// pairs the comma separated certificate and key files
+func (o *Options) keyPairs() ([]tlsconfig.KeyPair, error) {
+	if o.CertPathTLS == "" && o.KeyPathTLS == "" {
+		return nil, nil
+	}
+
+	certs := strings.Split(o.CertPathTLS, ",")
+	keys := strings.Split(o.KeyPathTLS, ",")
+	if len(certs) != len(keys) {
+		return nil, errors.New("the number of the TLS certificate and key files must be the same")
+	}
+
+	var pairs []tlsconfig.KeyPair
+	for i := range certs {
+		pairs = append(pairs, tlsconfig.KeyPair{CertFile: certs[i], KeyFile: keys[i]})
+	}
+
+	return pairs, nil
+}
+
+// the options of the TLS listener, loading the certificate sources and
+// the client CAs
+func (o *Options) tlsOptions() (tlsconfig.Options, error) {
+	keyPairs, err := o.keyPairs()
 	if err != nil {
-		return err
+		return tlsconfig.Options{}, err
 	}
 
-	// init metrics
-	metrics.Init(metrics.Options{
-		Listener:                 o.MetricsListener,
-		Prefix:                   o.MetricsPrefix,
-		EnableDebugGcMetrics:     o.EnableDebugGcMetrics,
-		EnableRuntimeMetrics:     o.EnableRuntimeMetrics,
-		EnableServeRouteMetrics:  o.EnableServeRouteMetrics,
-		EnableServeHostMetrics:   o.EnableServeHostMetrics,
-		EnableBackendHostMetrics: o.EnableBackendHostMetrics,
-		EnableProfile:            o.EnableProfile,
+	policy, err := o.fipsPolicy(o.TLSMinVersion, o.TLSMaxVersion, o.TLSCipherSuites, o.TLSCurves)
+	if err != nil {
+		return tlsconfig.Options{}, err
+	}
+
+	clientAuth, err := tlsconfig.ParseClientAuth(o.TLSClientAuth)
+	if err != nil {
+		return tlsconfig.Options{}, err
+	}
+
+	var clientCAs *x509.CertPool
+	if len(o.TLSClientCAFiles) > 0 {
+		if clientCAs, err = tlsconfig.LoadCertPool(o.TLSClientCAFiles); err != nil {
+			return tlsconfig.Options{}, err
+		}
+	}
+
+	providers, err := o.certProviders()
+	if err != nil {
+		return tlsconfig.Options{}, err
+	}
+
+	return tlsconfig.Options{
+		KeyPairs:          keyPairs,
+		CertDir:           o.CertDirTLS,
+		Providers:         providers,
+		ReloadInterval:    o.TLSReloadInterval,
+		EventBus:          o.EventBus,
+		DisableHTTP2:      o.DisableHTTP2,
+		Policy:            policy,
+		ClientAuth:        clientAuth,
+		ClientCAs:         clientCAs,
+		OCSPStapling:      o.EnableOCSPStapling,
+		OCSPCheckInterval: o.OCSPCheckInterval,
+		FIPS:              o.FIPS,
+	}, nil
+}
+
+// redirects the plaintext requests to the TLS listener, keeping the
+// method and the body with 308
+// serves a handler that is set after the listener was started, and
+// responds with 503 until then
+type lateHandler struct {
+	mx      sync.RWMutex
+	handler http.Handler
+}
+
+func (h *lateHandler) set(handler http.Handler) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	h.handler = handler
+}
+
+func (h *lateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mx.RLock()
+	handler := h.handler
+	h.mx.RUnlock()
+	if handler == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+func httpsRedirect(tlsAddress string) http.Handler {
+	_, port, _ := net.SplitHostPort(tlsAddress)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		u := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, u.String(), http.StatusPermanentRedirect)
 	})
+}
 
-	// create authentication for Innkeeper
-	auth := innkeeper.CreateInnkeeperAuthentication(innkeeper.AuthOptions{
-		InnkeeperAuthToken:  o.InnkeeperAuthToken,
-		OAuthCredentialsDir: o.OAuthCredentialsDir,
-		OAuthUrl:            o.OAuthUrl,
-		OAuthScope:          o.OAuthScope})
+func createACMEManager(o *Options, hosts acme.HostProvider) (*acme.Manager, error) {
+	if o.ACMECache == "" {
+		o.ACMECache = defaultACMECache
+	}
 
-	// create data clients
-	dataClients, err := createDataClients(o, auth)
+	cache, err := acme.ParseCache(o.ACMECache, o.EtcdUrls)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// append custom data clients
-	dataClients = append(dataClients, o.CustomDataClients...)
+	ao := acme.Options{
+		Email:        o.ACMEEmail,
+		Domains:      o.ACMEDomains,
+		Cache:        cache,
+		DirectoryURL: o.ACMEDirectoryURL,
+	}
 
-	if len(dataClients) == 0 {
-		log.Warning("no route source specified")
+	if o.ACMEHostsFromRoutes {
+		ao.Hosts = hosts
+	}
+
+	return acme.New(ao)
+}
+
+// the GeoIP databases, or nil, when not configured
+func (o *Options) geoIPDatabase() (*geoip.DB, error) {
+	if len(o.GeoIPDatabases) == 0 {
+		return nil, nil
+	}
+
+	return geoip.New(geoip.Options{
+		Databases:         o.GeoIPDatabases,
+		ReloadInterval:    o.GeoIPReloadInterval,
+		TrustForwardedFor: o.GeoIPTrustForwarded,
+	})
+}
+
+// creates the filter registry with the builtin filters, the custom
+// filters, and the optional filters enabled by the options. The returned
+// function releases the resources of the optional filters.
+func (o *Options) filterRegistry(geoDB *geoip.DB) (filters.Registry, func(), error) {
+	var closers []func()
+	closeAll := func() {
+		for _, c := range closers {
+			c()
+		}
 	}
 
-	// create a filter registry with the available filter specs registered,
-	// and register the custom filters
 	registry := builtin.MakeRegistry()
 	for _, f := range o.CustomFilters {
 		registry.Register(f)
 	}
 
-	// create routing
-	// create the proxy instance
-	var mo routing.MatchingOptions
-	if o.IgnoreTrailingSlash {
-		mo = routing.IgnoreTrailingSlash
+	if o.AuthCacheTTL > 0 {
+		authCache := authfilter.NewCache(authfilter.CacheOptions{
+			TTL:         o.AuthCacheTTL,
+			NegativeTTL: o.AuthCacheNegativeTTL,
+			MaxEntries:  o.AuthCacheMaxEntries,
+		})
+
+		registry.Register(authfilter.NewBasicAuthWithCache(authCache))
 	}
 
-	// ensure a non-zero poll timeout
-	if o.SourcePollTimeout <= 0 {
-		o.SourcePollTimeout = defaultSourcePollTimeout
+	if len(o.Secrets) > 0 {
+		sr, err := o.secretsRegistry()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		closers = append(closers, sr.Close)
+		registry.Register(cookiefilter.NewEncryptCookie(sr))
 	}
 
-	// check for dev mode, and set update buffer of the routes
-	updateBuffer := defaultRoutingUpdateBuffer
-	if o.DevMode {
-		updateBuffer = 0
+	if len(o.WAFRules) > 0 {
+		rules, err := waf.LoadRules(o.WAFRules)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+
+		spec, err := waf.New(waf.Options{
+			Rules:                 rules,
+			Mode:                  o.WAFMode,
+			InspectResponse:       o.WAFInspectResponse,
+			MaxBodySize:           o.WAFMaxBodySize,
+			PartialBodyInspection: o.WAFPartialBodyInspection,
+		})
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+
+		registry.Register(spec)
 	}
 
-	// include bundeled custom predicates
-	o.CustomPredicates = append(o.CustomPredicates,
+	if geoDB != nil {
+		registry.Register(geoipfilter.New(geoDB))
+	}
+
+	return registry, closeAll, nil
+}
+
+// the custom predicates, and the predicates bundled with skipper
+func (o *Options) predicates(geoDB *geoip.DB) []routing.PredicateSpec {
+	p := append([]routing.PredicateSpec(nil), o.CustomPredicates...)
+	if geoDB != nil {
+		p = append(p, geoippredicate.NewCountry(geoDB), geoippredicate.NewASN(geoDB))
+	}
+
+	return append(p,
 		source.New(),
 		interval.NewBetween(),
 		interval.NewBefore(),
 		interval.NewAfter(),
 		cookie.New(),
 		query.New(),
-		traffic.New())
+		traffic.New(),
+		clientcert.New())
+}
 
-	// create a routing engine
-	routing := routing.New(routing.Options{
-		FilterRegistry:  registry,
-		MatchingOptions: mo,
-		PollTimeout:     o.SourcePollTimeout,
-		DataClients:     dataClients,
-		Predicates:      o.CustomPredicates,
-		UpdateBuffer:    updateBuffer})
-	defer routing.Close()
+// the slow client protection, or nil, when not enabled
+func (o *Options) slowClientGuard() *slowclient.Guard {
+	if o.SlowClientHeaderTimeout <= 0 && o.SlowClientMinBodyRate <= 0 && o.MaxConnsPerIP <= 0 {
+		return nil
+	}
 
-	proxyFlags := proxy.Flags(o.ProxyOptions) | o.ProxyFlags
-	proxyParams := proxy.Params{
-		Routing:                routing,
-		Flags:                  proxyFlags,
-		PriorityRoutes:         o.PriorityRoutes,
-		IdleConnectionsPerHost: o.IdleConnectionsPerHost,
-		CloseIdleConnsPeriod:   o.CloseIdleConnsPeriod,
-		FlushInterval:          o.BackendFlushInterval,
-		ExperimentalUpgrade:    o.ExperimentalUpgrade,
-		MaxLoopbacks:           o.MaxLoopbacks,
+	return slowclient.NewGuard(slowclient.Options{
+		HeaderTimeout: o.SlowClientHeaderTimeout,
+		MinBodyRate:   o.SlowClientMinBodyRate,
+		BodyTimeout:   o.SlowClientBodyTimeout,
+		MaxConnsPerIP: o.MaxConnsPerIP,
+	})
+}
+
+// checks that the TLS configuration is compliant with the FIPS mode
+func (o *Options) checkFIPS() error {
+	if (proxy.Flags(o.ProxyOptions) | o.ProxyFlags).Insecure() || o.EtcdInsecure || o.InnkeeperInsecure {
+		return errors.New("skipping the TLS verification is not allowed in FIPS mode")
 	}
 
-	if o.DebugListener != "" {
-		do := proxyParams
-		do.Flags |= proxy.Debug
-		dbg := proxy.WithParams(do)
-		log.Infof("debug listener on %v", o.DebugListener)
-		go func() { http.ListenAndServe(o.DebugListener, dbg) }()
+	if _, err := o.fipsPolicy(o.TLSMinVersion, o.TLSMaxVersion, o.TLSCipherSuites, o.TLSCurves); err != nil {
+		return err
 	}
 
-	// create the proxy
-	proxy := proxy.WithParams(proxyParams)
-	defer proxy.Close()
+	if _, err := o.fipsPolicy(
+		o.UpstreamTLSMinVersion,
+		o.UpstreamTLSMaxVersion,
+		o.UpstreamTLSCipherSuites,
+		o.UpstreamTLSCurves,
+	); err != nil {
+		return err
+	}
+
+	if tlsconfig.BoringCrypto {
+		log.Info("FIPS mode enabled, using the BoringCrypto module")
+	} else {
+		log.Warning("FIPS mode enabled, but the binary was not built with the BoringCrypto module, the TLS settings are restricted, but the crypto implementation is not FIPS validated")
+	}
+
+	return nil
+}
+
+// parses a TLS policy, and restricts it, when the FIPS mode is enabled
+func (o *Options) fipsPolicy(minVersion, maxVersion string, cipherSuites, curves []string) (tlsconfig.Policy, error) {
+	p, err := tlsconfig.ParsePolicy(minVersion, maxVersion, cipherSuites, curves)
+	if err != nil || !o.FIPS {
+		return p, err
+	}
+
+	return tlsconfig.RestrictFIPS(p)
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// returns the address and the TLS configuration of the admin listener.
+// Without TLS, the tokens are sent in the clear, so the listener can only
+// be bound to a loopback address.
+func (o *Options) adminListener() (string, *tls.Config, error) {
+	if o.AdminCertPathTLS != "" || o.AdminKeyPathTLS != "" {
+		cert, err := tls.LoadX509KeyPair(o.AdminCertPathTLS, o.AdminKeyPathTLS)
+		if err != nil {
+			return "", nil, fmt.Errorf("error while loading the admin TLS certificate: %v", err)
+		}
+
+		policy, err := o.fipsPolicy(o.TLSMinVersion, o.TLSMaxVersion, o.TLSCipherSuites, o.TLSCurves)
+		if err != nil {
+			return "", nil, err
+		}
+
+		c := &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+		policy.Apply(c)
+		return o.AdminAddress, c, nil
+	}
+
+	host, port, err := net.SplitHostPort(o.AdminAddress)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if host == "" {
+		return net.JoinHostPort("localhost", port), nil, nil
+	}
+
+	if !isLoopback(host) {
+		return "", nil, fmt.Errorf("the admin API without TLS can only listen on a loopback address: %s", o.AdminAddress)
+	}
+
+	return o.AdminAddress, nil, nil
+}
+
+// creates the handler of the admin API, served on a separate listener.
+// The changes are persisted, when requested, in etcd, when it is one of
+// the data clients. The routing, the client, the tracer and the
+// maintenance mode are set by the caller.
+func adminHandler(o Options, ao admin.Options, dataClients []routing.DataClient) (http.Handler, error) {
+	if o.AdminTokens == "" {
+		return nil, errors.New("the admin API requires tokens")
+	}
+
+	if len(o.RouteSigningKeys) > 0 {
+		return nil, errors.New("the admin API can't be used together with the route signatures")
+	}
+
+	tokens, err := secrets.ParseSource(o.AdminTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	ao.Tokens = tokens
+	for _, dc := range dataClients {
+		if ec, ok := dc.(*etcd.Client); ok {
+			ao.Store = ec
+		}
+	}
+
+	return admin.New(ao)
+}
+
+// serves the proxy on the bound listeners, and, when the drain channel
+// is closed, shuts down gracefully. When serving on one of the listeners
+// fails, the others are closed, too.
+func serve(srv *http.Server, ls []net.Listener, useTLS bool, h *health.Health, drain <-chan struct{}) error {
+	if h != nil {
+		h.SetListening()
+	}
+
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		if drain == nil {
+			return
+		}
+
+		<-drain
+		log.Info("shutting down the proxy listener")
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Errorf("error while shutting down the proxy listener: %v", err)
+		}
+	}()
+
+	errs := make(chan error, len(ls))
+	for _, l := range ls {
+		go func(l net.Listener) {
+			var err error
+			if useTLS {
+				err = srv.ServeTLS(l, "", "")
+			} else {
+				err = srv.Serve(l)
+			}
+
+			if err != http.ErrServerClosed {
+				srv.Close()
+			}
+
+			errs <- err
+		}(l)
+	}
+
+	var err error
+	for range ls {
+		if lerr := <-errs; lerr != http.ErrServerClosed && err == nil {
+			err = lerr
+		}
+	}
+
+	if err == nil {
+		<-shutdown
+	}
+
+	return err
+}
+
+// listens on the address, or on the default port of the protocol. With
+// the reuse port option, it returns multiple listeners on the same
+// address.
+func (o *Options) listen(defaultAddress string) ([]net.Listener, error) {
+	address := o.Address
+	if address == "" {
+		address = defaultAddress
+	}
+
+	n := o.ReusePortListeners
+	if n < 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+
+	if n > 1 {
+		log.Infof("listening with %d sockets on %v", n, address)
+		return snet.ListenReusePort(address, n)
+	}
+
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	return []net.Listener{l}, nil
+}
+
+// serves the proxy, and registers the created server, and the TLS
+// configuration, when used, with addServer, when set
+func listenAndServe(proxy http.Handler, o *Options, certManager *acme.Manager, h *health.Health, addServer func(*http.Server, *tlsconfig.Server), drain <-chan struct{}) error {
+	// create the access log handler
+	var handler http.Handler = logging.NewHandler(proxy)
+	guard := o.slowClientGuard()
+	if guard != nil {
+		handler = guard.Wrap(handler)
+	}
+
+	log.Infof("proxy listener on %v", o.Address)
+	if !o.isHTTPS() {
+		log.Infof("certPathTLS or keyPathTLS not found, defaulting to HTTP")
+		srv := &http.Server{
+			Addr:           o.Address,
+			Handler:        handler,
+			MaxHeaderBytes: o.MaxHeaderBytes,
+		}
+
+		ls, err := o.listen(":http")
+		if err != nil {
+			return err
+		}
+
+		for i := range ls {
+			if guard != nil {
+				ls[i] = guard.Listener(ls[i])
+			}
+
+			if o.StrictParsing {
+				ls[i] = strictparsing.NewListener(ls[i], strictparsing.Options{MaxHeaderBytes: o.MaxHeaderBytes})
+			}
+		}
+
+		if addServer != nil {
+			addServer(srv, nil)
+		}
+
+		return serve(srv, ls, false, h, drain)
+	}
+
+	tlsOptions, err := o.tlsOptions()
+	if err != nil {
+		return err
+	}
+
+	// only the HTTP/1.x requests can be validated
+	if o.StrictParsing && !tlsOptions.DisableHTTP2 {
+		log.Info("HTTP/2 is disabled by the strict parsing")
+		tlsOptions.DisableHTTP2 = true
+	}
+
+	redirect := httpsRedirect(o.Address)
+	if certManager != nil {
+		tlsOptions.Fallback = certManager.GetCertificate
+		tlsOptions.AdditionalProtos = []string{acme.ALPNProto}
+
+		// the HTTP-01 challenges are answered on the redirect listener
+		redirect = certManager.HTTPHandler(redirect)
+	}
+
+	tlsServer, err := tlsconfig.New(tlsOptions)
+	if err != nil {
+		return err
+	}
+
+	defer tlsServer.Close()
+	srv := &http.Server{
+		Addr:           o.Address,
+		Handler:        handler,
+		TLSConfig:      tlsServer.Config,
+		MaxHeaderBytes: o.MaxHeaderBytes,
+	}
+
+	if o.DisableHTTP2 {
+		// a non-nil, empty map prevents the automatic HTTP/2 setup
+		srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+
+	if o.TLSRedirectAddress != "" {
+		log.Infof("TLS redirect listener on %v", o.TLSRedirectAddress)
+		redirectServer := serveSupport("TLS redirect", o.TLSRedirectAddress, redirect)
+		defer redirectServer.Close()
+	}
+
+	ls, err := o.listen(":https")
+	if err != nil {
+		return err
+	}
+
+	// the guard needs the connections below the TLS layer, while the
+	// strict parsing needs them decrypted, so in this case, the TLS
+	// layer is applied by the listener, instead of the server
+	useTLS := !o.StrictParsing
+	for i := range ls {
+		if guard != nil {
+			ls[i] = guard.Listener(ls[i])
+		}
+
+		if o.StrictParsing {
+			ls[i] = tls.NewListener(ls[i], srv.TLSConfig)
+			ls[i] = strictparsing.NewListener(ls[i], strictparsing.Options{MaxHeaderBytes: o.MaxHeaderBytes})
+		}
+	}
+
+	if o.StrictParsing {
+		srv.ConnContext = strictparsing.ConnContext
+		srv.Handler = strictparsing.TLSState(srv.Handler)
+	}
+
+	if addServer != nil {
+		addServer(srv, tlsServer)
+	}
+
+	return serve(srv, ls, useTLS, h, drain)
+}
+
+// Server is an instance of skipper, that can be embedded in other Go
+// programs. It is created with New, started with Serve and stopped with
+// Shutdown.
+type Server struct {
+	options     Options
+	handler     http.Handler
+	certManager *acme.Manager
+	health      *health.Health
+	maintenance *maintenance.Mode
+	routing     *routing.Routing
+	notifier    *systemd.Notifier
+
+	mx        sync.Mutex
+	servers   []*http.Server
+	tlsServer *tlsconfig.Server
+	closers   []func()
+	quit      chan struct{}
+	stopOnce  sync.Once
+	closeOnce sync.Once
+}
+
+// New creates an instance of skipper, with the custom filters,
+// predicates, data clients and metrics backend set in the options. It
+// initializes the routing and the proxy, and starts the support
+// listeners, e.g. the metrics and the admin API, but the proxy listener
+// is started only by Serve.
+func New(o Options) (*Server, error) {
+	s := &Server{quit: make(chan struct{})}
+	if err := s.setup(o); err != nil {
+		s.close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// registers a function releasing a resource, called in the reverse
+// order of the registration
+func (s *Server) onClose(f func()) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.closers = append(s.closers, f)
+}
+
+func (s *Server) close() {
+	s.closeOnce.Do(func() {
+		s.mx.Lock()
+		closers := s.closers
+		s.closers = nil
+		s.mx.Unlock()
+
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	})
+}
+
+// starts serving a support listener, e.g. the admin API, in the
+// background. It is stopped by closing the returned server.
+func serveSupport(name, address string, h http.Handler) *http.Server {
+	return serveSupportTLS(name, address, nil, h)
+}
+
+// starts serving a support listener, with TLS, when the configuration
+// is set
+func serveSupportTLS(name, address string, c *tls.Config, h http.Handler) *http.Server {
+	srv := &http.Server{Addr: address, Handler: h, TLSConfig: c}
+	go func() {
+		var err error
+		if c != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+
+		if err != nil && err != http.ErrServerClosed {
+			log.Errorf("%s listener failed: %v", name, err)
+		}
+	}()
+
+	return srv
+}
+
+// starts a support listener, closed together with the instance
+func (s *Server) listenSupport(name, address string, h http.Handler) {
+	s.listenSupportTLS(name, address, nil, h)
+}
+
+func (s *Server) listenSupportTLS(name, address string, c *tls.Config, h http.Handler) {
+	srv := serveSupportTLS(name, address, c, h)
+	s.onClose(func() { srv.Close() })
+}
+
+// called when the proxy listener is bound
+func (s *Server) addServer(srv *http.Server, tlsServer *tlsconfig.Server) {
+	s.mx.Lock()
+	s.servers = append(s.servers, srv)
+	s.tlsServer = tlsServer
+	s.mx.Unlock()
+
+	if s.maintenance != nil {
+		s.maintenance.AddServer(srv)
+	}
+
+	if s.notifier != nil {
+		go s.notifyReady()
+	}
+}
+
+// notifies systemd, when the routing table is ready
+func (s *Server) notifyReady() {
+	for !s.routing.Status().Ready() {
+		select {
+		case <-time.After(readyCheckInterval):
+		case <-s.quit:
+			return
+		}
+	}
+
+	s.notifier.Ready()
+}
+
+// on SIGHUP, reloads the TLS certificates immediately
+func (s *Server) reloadOnSignal() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	for {
+		select {
+		case <-sigs:
+			log.Info("received SIGHUP, reloading")
+			s.notifier.Reloading()
+
+			s.mx.Lock()
+			tlsServer := s.tlsServer
+			s.mx.Unlock()
+
+			if tlsServer != nil {
+				tlsServer.Reload()
+			}
+
+			s.notifier.Ready()
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// checks that the proxy listener accepts the connections, and responds
+// to the requests. The OPTIONS * requests are answered by the HTTP
+// server without calling the proxy handler. When the client
+// certificates are required, only the connection is checked.
+func (s *Server) checkListener() error {
+	s.mx.Lock()
+	serving := len(s.servers) > 0
+	s.mx.Unlock()
+
+	// still starting
+	if !serving {
+		return nil
+	}
+
+	scheme, address := "http", s.options.Address
+	if s.options.isHTTPS() {
+		scheme = "https"
+		if address == "" {
+			address = ":https"
+		}
+	} else if address == "" {
+		address = ":http"
+	}
+
+	if clientAuth, _ := tlsconfig.ParseClientAuth(s.options.TLSClientAuth); scheme == "https" &&
+		(clientAuth == tls.RequireAnyClientCert || clientAuth == tls.RequireAndVerifyClientCert) {
+		conn, err := net.DialTimeout("tcp", address, listenerCheckTimeout)
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	}
+
+	client := &http.Client{
+		Timeout: listenerCheckTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, address)
+			},
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+	}
+
+	req, err := http.NewRequest("OPTIONS", scheme+"://localhost", nil)
+	if err != nil {
+		return err
+	}
+
+	req.URL.Opaque = "*"
+	rsp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	return rsp.Body.Close()
+}
+
+func (s *Server) stop() {
+	s.stopOnce.Do(func() {
+		s.notifier.Stopping()
+		close(s.quit)
+	})
+}
+
+// Serve starts the proxy listener, and blocks until the proxy is shut
+// down, either by Shutdown, or, when the drain delay is set, by
+// SIGTERM. It returns nil after a graceful shutdown. It can be called
+// only once. While serving, SIGHUP reloads the TLS certificates.
+func (s *Server) Serve() error {
+	go s.reloadOnSignal()
+	if sigterm := drainOnSignal(s.options.DrainDelay, s.health, s.notifier); sigterm != nil {
+		go func() {
+			select {
+			case <-sigterm:
+				s.stop()
+			case <-s.quit:
+			}
+		}()
+	}
+
+	return listenAndServe(s.handler, &s.options, s.certManager, s.health, s.addServer, s.quit)
+}
+
+// Shutdown stops the proxy listener gracefully, waiting for the active
+// requests until the context is done, closes the support listeners, and
+// releases the resources of the instance.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stop()
+
+	s.mx.Lock()
+	servers := s.servers
+	s.mx.Unlock()
+
+	var err error
+	for _, srv := range servers {
+		if serr := srv.Shutdown(ctx); serr != nil && err == nil {
+			err = serr
+		}
+	}
+
+	s.close()
+	return err
+}
+
+// Run starts skipper, and blocks until it fails, or until the context is
+// done. When the context is done, the proxy is shut down gracefully.
+func Run(ctx context.Context, o Options) error {
+	s, err := New(o)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Shutdown(context.Background())
+		case <-done:
+		}
+	}()
+
+	err = s.Serve()
+	s.Shutdown(context.Background())
+	return err
+}
+
+// initializes the components of the proxy, and starts the support
+// listeners. The resources are released on close.
+func (s *Server) setup(o Options) error {
+	// init log
+	err := initLog(o)
+	if err != nil {
+		return err
+	}
+
+	if o.AdjustMaxProcs {
+		if _, err := maxprocs.Adjust(maxprocs.DefaultCgroupRoot); err != nil {
+			log.Errorf("failed to adjust GOMAXPROCS: %v", err)
+		}
+	}
+
+	if o.FIPS {
+		if err := o.checkFIPS(); err != nil {
+			return err
+		}
+	}
+
+	if o.EventBus == nil {
+		o.EventBus = events.NewBus()
+	}
+
+	// when started by systemd, the state changes are notified, and the
+	// watchdog is pinged while the proxy listener responds
+	s.notifier = systemd.New(systemd.Options{Check: s.checkListener})
+	s.onClose(func() { s.notifier.Close() })
+
+	if o.EventWebhookURL != "" {
+		w := events.NewWebhook(o.EventBus, o.EventWebhookURL)
+		s.onClose(func() { w.Close() })
+	}
+
+	supportHandlers := make(map[string]http.Handler)
+	if o.EnableRouteAudit {
+		trail, err := audit.New(o.EventBus, audit.Options{
+			MaxEntries: o.RouteAuditMaxEntries,
+			File:       o.RouteAuditFile,
+		})
+		if err != nil {
+			return err
+		}
+
+		s.onClose(func() { trail.Close() })
+		supportHandlers["/audit"] = trail
+	}
+
+	var capt *capture.Capture
+	if o.EnableCapture {
+		capt, err = capture.New(capture.Options{
+			BufferSize:      o.CaptureBufferSize,
+			MaxBodySize:     o.CaptureMaxBodySize,
+			TrustedNetworks: o.CaptureTrustedNetworks,
+			Token:           o.CaptureToken,
+			KeepCredentials: o.CaptureKeepCredentials,
+		})
+		if err != nil {
+			return err
+		}
+
+		supportHandlers["/capture"] = capt
+	}
+
+	var tp *tap.Tap
+	if o.EnableTap {
+		tp, err = tap.New(tap.Options{
+			Token:      o.TapToken,
+			SampleRate: o.TapSampleRate,
+			Headers:    o.TapHeaders,
+		})
+		if err != nil {
+			return err
+		}
+
+		supportHandlers["/tap"] = tp
+	}
+
+	if o.ProfilingEndpoint != "" {
+		p, err := profiling.New(profiling.Options{
+			Endpoint: o.ProfilingEndpoint,
+			Interval: o.ProfilingInterval,
+			Labels:   o.ProfilingLabels,
+		})
+		if err != nil {
+			return err
+		}
+
+		s.onClose(func() { p.Close() })
+	}
+
+	// init tracing
+	tracer, err := tracing.New(tracing.Options{
+		Tracer:      o.Tracer,
+		ServiceName: o.TracerServiceName,
+		Endpoint:    o.TracerEndpoint,
+		SampleRate:  o.TracerSampleRate,
+		Sampler:     o.TracerSampler,
+		Propagation: o.TracerPropagation,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.onClose(func() { tracer.Close() })
+
+	// create authentication for Innkeeper
+	auth := innkeeper.CreateInnkeeperAuthentication(innkeeper.AuthOptions{
+		InnkeeperAuthToken:  o.InnkeeperAuthToken,
+		OAuthCredentialsDir: o.OAuthCredentialsDir,
+		OAuthUrl:            o.OAuthUrl,
+		OAuthScope:          o.OAuthScope})
+
+	// create data clients
+	dataClients, err := createDataClients(o, auth)
+	if err != nil {
+		return err
+	}
+
+	// append custom data clients
+	dataClients = append(dataClients, o.CustomDataClients...)
+
+	// the admin client needs to be the last one, to override the routes
+	// of the other data clients
+	var adminClient *admin.Client
+	if o.AdminAddress != "" {
+		adminClient = admin.NewClient()
+		dataClients = append(dataClients, adminClient)
+	}
+
+	if len(dataClients) == 0 {
+		log.Warning("no route source specified")
+	}
+
+	geoDB, err := o.geoIPDatabase()
+	if err != nil {
+		return err
+	}
+
+	if geoDB != nil {
+		s.onClose(func() { geoDB.Close() })
+	}
+
+	// create a filter registry with the available filter specs registered,
+	// and register the custom filters
+	registry, closeFilters, err := o.filterRegistry(geoDB)
+	if err != nil {
+		return err
+	}
+
+	s.onClose(func() { closeFilters() })
+
+	var reputation *geoip.Reputation
+	if len(o.IPReputationFeeds) > 0 {
+		reputation, err = geoip.NewReputation(geoip.ReputationOptions{
+			Feeds:             o.IPReputationFeeds,
+			RefreshInterval:   o.IPReputationRefresh,
+			TrustForwardedFor: o.GeoIPTrustForwarded,
+		})
+		if err != nil {
+			return err
+		}
+
+		s.onClose(func() { reputation.Close() })
+	}
+
+	// create routing
+	// create the proxy instance
+	var mo routing.MatchingOptions
+	if o.IgnoreTrailingSlash {
+		mo = routing.IgnoreTrailingSlash
+	}
+
+	// ensure a non-zero poll timeout
+	if o.SourcePollTimeout <= 0 {
+		o.SourcePollTimeout = defaultSourcePollTimeout
+	}
+
+	// check for dev mode, and set update buffer of the routes
+	updateBuffer := defaultRoutingUpdateBuffer
+	if o.DevMode {
+		updateBuffer = 0
+	}
+
+	// include bundeled custom predicates
+	o.CustomPredicates = o.predicates(geoDB)
+
+	// create a routing engine
+	routing := routing.New(routing.Options{
+		FilterRegistry:      registry,
+		MatchingOptions:     mo,
+		PollTimeout:         o.SourcePollTimeout,
+		DataClients:         dataClients,
+		Predicates:          o.CustomPredicates,
+		UpdateBuffer:        updateBuffer,
+		EventBus:            o.EventBus,
+		AllowedFilters:      o.AllowedFilters,
+		DisabledFilters:     o.DisabledFilters,
+		AllowedPredicates:   o.AllowedPredicates,
+		DisabledPredicates:  o.DisabledPredicates,
+		MaxRoutes:           o.MaxRoutes,
+		MaxFiltersPerRoute:  o.MaxFiltersPerRoute,
+		MaxRegexpComplexity: o.MaxRegexpComplexity,
+		MaxRouteSize:        o.MaxRouteSize,
+		HistorySize:         o.RouteHistorySize,
+		HistoryDir:          o.RouteHistoryDir,
+		SnapshotFile:        o.RoutingSnapshotFile})
+	s.onClose(func() { routing.Close() })
+
+	var banList *banlist.BanList
+	if o.EnableBanList {
+		bo := banlist.Options{
+			Threshold:         o.BanListThreshold,
+			Window:            o.BanListWindow,
+			Duration:          o.BanListDuration,
+			Statuses:          o.BanListStatuses,
+			IdentifyByToken:   o.BanListByToken,
+			TrustForwardedFor: o.BanListTrustForwarded,
+			EventBus:          o.EventBus,
+		}
+
+		if o.BanListRedisURL != "" {
+			ro, err := banlist.ParseRedisURL(o.BanListRedisURL)
+			if err != nil {
+				return err
+			}
+
+			store, err := banlist.NewRedisStore(ro)
+			if err != nil {
+				return err
+			}
+
+			s.onClose(func() { store.Close() })
+			bo.Store = store
+		}
+
+		banList = banlist.New(bo)
+		s.onClose(func() { banList.Close() })
+		supportHandlers["/bans"] = banList
+	}
+
+	var healthEndpoints *health.Health
+	if o.EnableHealthEndpoints {
+		healthEndpoints = health.New(health.Options{
+			Routing:         routing,
+			EventBus:        o.EventBus,
+			WaitForListener: true,
+		})
+		s.onClose(func() { healthEndpoints.Close() })
+
+		livenessPath, readinessPath := o.LivenessPath, o.ReadinessPath
+		if livenessPath == "" {
+			livenessPath = defaultLivenessPath
+		}
+
+		if readinessPath == "" {
+			readinessPath = defaultReadinessPath
+		}
+
+		supportHandlers[livenessPath] = healthEndpoints.LivenessHandler()
+		supportHandlers[readinessPath] = healthEndpoints.ReadinessHandler()
+		supportHandlers[readinessPath+"/drain"] = healthEndpoints.DrainHandler()
+
+		// the original paths are kept for backwards compatibility
+		supportHandlers["/healthz"] = healthEndpoints.LivenessHandler()
+		supportHandlers["/readyz"] = healthEndpoints.ReadinessHandler()
+	}
+
+	if o.EffectiveConfig != nil {
+		supportHandlers["/config"] = config.Handler(o.EffectiveConfig)
+	}
+
+	// the proxy is created after the support listener was started
+	transportStats := &lateHandler{}
+	supportHandlers["/transports"] = transportStats
+
+	namespaces, err := o.namespaces()
+	if err != nil {
+		return err
+	}
+
+	var routeNamespace func(string) string
+	if namespaces != nil {
+		routeNamespace = namespaces.Of
+	}
+
+	var maintenanceMode *maintenance.Mode
+	if adminClient != nil {
+		mo := maintenance.Options{
+			Routing:     routing,
+			Routes:      o.MaintenanceRoutes,
+			RetryAfter:  o.MaintenanceRetryAfter,
+			DrainPeriod: o.MaintenanceDrainPeriod,
+		}
+
+		if healthEndpoints != nil {
+			mo.Readiness = healthEndpoints
+		}
+
+		adminAddress, adminTLS, err := o.adminListener()
+		if err != nil {
+			return err
+		}
+
+		maintenanceMode = maintenance.New(mo)
+		s.onClose(func() { maintenanceMode.Close() })
+
+		h, err := adminHandler(o, admin.Options{
+			Routing:     routing,
+			Client:      adminClient,
+			Tracer:      tracer,
+			Maintenance: maintenanceMode,
+			Namespaces:  namespaces,
+		}, dataClients)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("admin listener on %v", adminAddress)
+		s.listenSupportTLS("admin", adminAddress, adminTLS, h)
+	}
+
+	// init metrics
+	metricsOptions := metrics.Options{
+		Listener:                 o.MetricsListener,
+		Prefix:                   o.MetricsPrefix,
+		EnableDebugGcMetrics:     o.EnableDebugGcMetrics,
+		EnableRuntimeMetrics:     o.EnableRuntimeMetrics,
+		EnableServeRouteMetrics:  o.EnableServeRouteMetrics,
+		EnableServeHostMetrics:   o.EnableServeHostMetrics,
+		EnableBackendHostMetrics: o.EnableBackendHostMetrics,
+		EnableProfile:            o.EnableProfile,
+		EnablePrometheus:         o.EnablePrometheusMetrics,
+		GraphiteAddr:             o.MetricsGraphiteAddr,
+		GraphitePrefix:           o.MetricsGraphitePrefix,
+		StatsdAddr:               o.MetricsStatsdAddr,
+		StatsdPrefix:             o.MetricsStatsdPrefix,
+		ReportInterval:           o.MetricsReportInterval,
+		TimerSample:              metrics.TimerSample(o.MetricsTimerSample),
+		TimerReservoirSize:       o.MetricsTimerReservoirSize,
+		HistogramPrecision:       o.MetricsHistogramPrecision,
+		HistogramMaxValue:        o.MetricsHistogramMaxValue,
+		ResetToken:               o.MetricsResetToken,
+		SupportHandlers:          supportHandlers,
+		RouteNamespace:           routeNamespace,
+	}
+
+	var stopMetrics func()
+	if o.Metrics != nil {
+		stopMetrics = metrics.InitWith(o.Metrics, metricsOptions)
+	} else {
+		stopMetrics = metrics.Init(metricsOptions)
+	}
+
+	s.onClose(stopMetrics)
+
+	proxyFlags := proxy.Flags(o.ProxyOptions) | o.ProxyFlags
+	proxyParams := proxy.Params{
+		Routing:                routing,
+		Flags:                  proxyFlags,
+		PriorityRoutes:         o.PriorityRoutes,
+		IdleConnectionsPerHost: o.IdleConnectionsPerHost,
+		CloseIdleConnsPeriod:   o.CloseIdleConnsPeriod,
+		FlushInterval:          o.BackendFlushInterval,
+		ExperimentalUpgrade:    o.ExperimentalUpgrade,
+		MaxLoopbacks:           o.MaxLoopbacks,
+		ServerTiming:           o.ServerTiming,
+		SecurityHeaders:        o.securityHeaders(),
+		EventBus:               o.EventBus,
+		BackendTransports:      o.BackendTransports,
+		Metrics:                o.Metrics,
+	}
+
+	if namespaces != nil && namespaces.HasRateLimits() {
+		proxyParams.RateLimit = namespaces.Allow
+	}
+
+	upstreamPolicy, err := o.fipsPolicy(
+		o.UpstreamTLSMinVersion,
+		o.UpstreamTLSMaxVersion,
+		o.UpstreamTLSCipherSuites,
+		o.UpstreamTLSCurves,
+	)
+	if err != nil {
+		return err
+	}
+
+	proxyParams.TLSClientConfig = upstreamPolicy.ClientConfig()
+
+	if o.EnableSPIFFE {
+		svidSource, err := spiffe.New(spiffe.Options{
+			SocketAddress: o.SPIFFESocket,
+			AllowedIDs:    o.SPIFFEAllowedIDs,
+		})
+		if err != nil {
+			return err
+		}
+
+		s.onClose(func() { svidSource.Close() })
+		proxyParams.TLSClientConfig = svidSource.ClientConfig(proxyParams.TLSClientConfig)
+	}
+
+	errorReporter, err := errorreport.New(errorreport.Options{
+		DSN:                o.ErrorReportingDSN,
+		Environment:        o.ErrorReportingEnvironment,
+		MaxEventsPerMinute: o.ErrorReportingRateLimit,
+		BurstThreshold:     o.ErrorBurstThreshold,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.onClose(func() { errorReporter.Close() })
+	proxyParams.ErrorReporter = errorReporter
+
+	if o.GenerateFlowID {
+		g, err := flowid.NewStandardGenerator(flowid.DefaultLength)
+		if err != nil {
+			return err
+		}
+
+		proxyParams.FlowIDGenerator = g
+	}
+
+	if o.DebugListener != "" {
+		do := proxyParams
+		do.Flags |= proxy.Debug
+		dbg := proxy.NewDryRunHandler(proxy.WithParams(do))
+		log.Infof("debug listener on %v", o.DebugListener)
+		s.listenSupport("debug", o.DebugListener, dbg)
+	}
+
+	// create the proxy
+	proxyParams.Tracer = tracer
+	proxy := proxy.WithParams(proxyParams)
+	s.onClose(func() { proxy.Close() })
+	transportStats.set(proxy.TransportStatsHandler())
+
+	var handler http.Handler = proxy
+	if capt != nil {
+		handler = capt.Wrap(handler)
+	}
+
+	if tp != nil {
+		handler = tp.Wrap(handler)
+	}
+
+	// the banned clients are rejected before capturing and tapping
+	if banList != nil {
+		handler = banList.Wrap(handler)
+	}
+
+	if reputation != nil {
+		handler = reputation.Wrap(handler)
+	}
+
+	if maintenanceMode != nil {
+		handler = maintenanceMode.Wrap(handler)
+	}
+
+	// the GeoIP lookup happens first, so that the rejected requests are
+	// logged with the country, too
+	if geoDB != nil {
+		handler = geoDB.Wrap(handler)
+	}
+
+	var certManager *acme.Manager
+	if o.EnableACME {
+		certManager, err = createACMEManager(&o, routing)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.options = o
+	s.routing = routing
+	s.handler = handler
+	s.certManager = certManager
+	s.health = healthEndpoints
+	s.maintenance = maintenanceMode
+	return nil
+}
+
+// when the delay is set, on SIGTERM, the proxy is marked as draining,
+// and the returned channel is closed after the delay, to shut down the
+// proxy listener
+func drainOnSignal(delay time.Duration, h *health.Health, n *systemd.Notifier) <-chan struct{} {
+	if delay <= 0 {
+		return nil
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM)
+
+	drain := make(chan struct{})
+	go func() {
+		<-sigs
+		log.Infof("received SIGTERM, draining for %v", delay)
+		n.Stopping()
+		if h != nil {
+			h.SetDraining(true)
+		}
+
+		time.Sleep(delay)
+		close(drain)
+	}()
 
-	return listenAndServe(proxy, &o)
+	return drain
 }
@@ -1,17 +1,27 @@
 package skipper
 
 import (
+	"bufio"
+	"context"
 	"crypto/tls"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/health"
+	"github.com/zalando/skipper/metrics"
 	"github.com/zalando/skipper/proxy"
 	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
 )
 
 const (
@@ -98,7 +108,7 @@ func TestWithWrongCertPathFails(t *testing.T) {
 	proxy := proxy.New(rt, proxy.OptionsNone)
 	defer proxy.Close()
 
-	err = listenAndServe(proxy, &o)
+	err = listenAndServe(proxy, &o, nil, nil, nil, nil)
 	if err == nil {
 		t.Fatal(err)
 	}
@@ -122,7 +132,7 @@ func TestWithWrongKeyPathFails(t *testing.T) {
 
 	proxy := proxy.New(rt, proxy.OptionsNone)
 	defer proxy.Close()
-	err = listenAndServe(proxy, &o)
+	err = listenAndServe(proxy, &o, nil, nil, nil, nil)
 	if err == nil {
 		t.Fatal(err)
 	}
@@ -153,7 +163,7 @@ func TestHTTPSServer(t *testing.T) {
 
 	proxy := proxy.New(rt, proxy.OptionsNone)
 	defer proxy.Close()
-	go listenAndServe(proxy, &o)
+	go listenAndServe(proxy, &o, nil, nil, nil, nil)
 
 	r, err := waitConnGet("https://" + o.Address)
 	if r != nil {
@@ -192,7 +202,7 @@ func TestHTTPServer(t *testing.T) {
 
 	proxy := proxy.New(rt, proxy.OptionsNone)
 	defer proxy.Close()
-	go listenAndServe(proxy, &o)
+	go listenAndServe(proxy, &o, nil, nil, nil, nil)
 	r, err := waitConnGet("http://" + o.Address)
 	if r != nil {
 		defer r.Body.Close()
@@ -208,3 +218,572 @@ func TestHTTPServer(t *testing.T) {
 		t.Fatalf("Failed to stream response body: %v", err)
 	}
 }
+
+func TestDrainShutdown(t *testing.T) {
+	a, err := findAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := Options{Address: a}
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{}})
+	defer rt.Close()
+
+	h := health.New(health.Options{Routing: rt, WaitForListener: true})
+	defer h.Close()
+
+	if r := h.Readiness(); r.Listening == nil || *r.Listening {
+		t.Error("listener reported before bound")
+	}
+
+	proxy := proxy.New(rt, proxy.OptionsNone)
+	defer proxy.Close()
+
+	drain := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- listenAndServe(proxy, &o, nil, h, nil, drain) }()
+
+	r, err := waitConnGet("http://" + o.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Body.Close()
+	if r := h.Readiness(); r.Listening == nil || !*r.Listening {
+		t.Error("listener not reported")
+	}
+
+	close(drain)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout while shutting down")
+	}
+}
+
+func TestReusePortListeners(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip()
+	}
+
+	a, err := findAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := Options{Address: a, ReusePortListeners: 3}
+	ls, err := o.listen(":http")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ls) != 3 {
+		t.Error("invalid number of listeners", len(ls))
+	}
+
+	for _, l := range ls {
+		l.Close()
+	}
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{}})
+	defer rt.Close()
+
+	proxy := proxy.New(rt, proxy.OptionsNone)
+	defer proxy.Close()
+
+	drain := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- listenAndServe(proxy, &o, nil, nil, nil, drain) }()
+
+	for i := 0; i < 6; i++ {
+		r, err := waitConnGet("http://" + o.Address)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r.Body.Close()
+	}
+
+	close(drain)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout while shutting down")
+	}
+}
+
+func TestStrictParsingTLS(t *testing.T) {
+	a, err := findAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := Options{
+		Address:       a,
+		CertPathTLS:   "fixtures/test.crt",
+		KeyPathTLS:    "fixtures/test.key",
+		StrictParsing: true,
+	}
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	drain := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- listenAndServe(h, &o, nil, nil, nil, drain) }()
+
+	r, err := waitConnGet("https://" + a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Body.Close()
+	if r.StatusCode != http.StatusOK || r.ProtoMajor != 1 {
+		t.Error("invalid response", r.StatusCode, r.Proto)
+	}
+
+	c, err := tls.Dial("tcp", a, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(3 * time.Second))
+	if _, err := c.Write([]byte("POST / HTTP/1.1\r\nHost: www.example.org\r\n" +
+		"Content-Length: 3\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	rsp, err := http.ReadResponse(bufio.NewReader(c), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusBadRequest {
+		t.Error("failed to reject the ambiguous request", rsp.StatusCode)
+	}
+
+	close(drain)
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	a, err := findAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := eskip.Parse(`hello: Path("/hello") -> status(418) -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := metrics.New(metrics.Options{})
+	defer func() { metrics.Default = metrics.Void }()
+
+	s, err := New(Options{
+		Address:           a,
+		AccessLogDisabled: true,
+		CustomDataClients: []routing.DataClient{testdataclient.New(routes)},
+		Metrics:           m,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if metrics.Default != m {
+		t.Error("failed to use the custom metrics")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.Serve() }()
+
+	r, err := waitConnGet("http://" + a + "/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Body.Close()
+	if r.StatusCode != http.StatusTeapot {
+		t.Error("invalid status", r.StatusCode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Error(err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout while shutting down")
+	}
+}
+
+func TestShutdownSupportListeners(t *testing.T) {
+	var addresses []string
+	for i := 0; i < 4; i++ {
+		a, err := findAddress()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addresses = append(addresses, a)
+	}
+
+	// without TLS, the admin API listens only on loopback addresses
+	_, port, err := net.SplitHostPort(addresses[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addresses[3] = net.JoinHostPort("127.0.0.1", port)
+
+	os.Setenv("TEST_SHUTDOWN_ADMIN_TOKENS", "foo")
+	defer os.Unsetenv("TEST_SHUTDOWN_ADMIN_TOKENS")
+
+	o := Options{
+		Address:           addresses[0],
+		MetricsListener:   addresses[1],
+		DebugListener:     addresses[2],
+		AdminAddress:      addresses[3],
+		AdminTokens:       "env:TEST_SHUTDOWN_ADMIN_TOKENS",
+		AccessLogDisabled: true,
+	}
+
+	for i := 0; i < 2; i++ {
+		s, err := New(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		done := make(chan error, 1)
+		go func() { done <- s.Serve() }()
+
+		for _, a := range addresses {
+			r, err := waitConnGet("http://" + a + "/")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r.Body.Close()
+		}
+
+		if err := s.Shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+
+		for _, a := range addresses[1:] {
+			if _, err := http.Get("http://" + a + "/"); err == nil {
+				t.Error("failed to close the listener", a)
+			}
+		}
+
+		if metrics.Default != metrics.Void {
+			t.Error("failed to reset the metrics")
+		}
+	}
+}
+
+func TestAdminListener(t *testing.T) {
+	for _, test := range []struct {
+		title   string
+		options Options
+		address string
+		tls     bool
+		fail    bool
+	}{{
+		title:   "loopback",
+		options: Options{AdminAddress: "127.0.0.1:9922"},
+		address: "127.0.0.1:9922",
+	}, {
+		title:   "IPv6 loopback",
+		options: Options{AdminAddress: "[::1]:9922"},
+		address: "[::1]:9922",
+	}, {
+		title:   "no host",
+		options: Options{AdminAddress: ":9922"},
+		address: "localhost:9922",
+	}, {
+		title:   "public address without TLS",
+		options: Options{AdminAddress: "10.0.0.1:9922"},
+		fail:    true,
+	}, {
+		title:   "unspecified address without TLS",
+		options: Options{AdminAddress: "0.0.0.0:9922"},
+		fail:    true,
+	}, {
+		title: "TLS",
+		options: Options{
+			AdminAddress:     ":9922",
+			AdminCertPathTLS: "fixtures/test.crt",
+			AdminKeyPathTLS:  "fixtures/test.key",
+		},
+		address: ":9922",
+		tls:     true,
+	}, {
+		title:   "missing TLS key",
+		options: Options{AdminAddress: ":9922", AdminCertPathTLS: "fixtures/test.crt"},
+		fail:    true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			a, c, err := test.options.adminListener()
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if a != test.address || (c != nil) != test.tls {
+				t.Error("invalid listener", a, c != nil)
+			}
+		})
+	}
+}
+
+func TestAdminTLS(t *testing.T) {
+	a, err := findAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("TEST_ADMIN_TLS_TOKENS", "foo")
+	defer os.Unsetenv("TEST_ADMIN_TLS_TOKENS")
+
+	s, err := New(Options{
+		Address:           ":0",
+		AdminAddress:      a,
+		AdminCertPathTLS:  "fixtures/test.crt",
+		AdminKeyPathTLS:   "fixtures/test.key",
+		AdminTokens:       "env:TEST_ADMIN_TLS_TOKENS",
+		AccessLogDisabled: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.close()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+
+	var rsp *http.Response
+	for i := 0; i < 30; i++ {
+		if rsp, err = client.Get("https://" + a + "/routes"); err == nil {
+			break
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusUnauthorized {
+		t.Error("invalid status", rsp.StatusCode)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	a, err := findAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, Options{Address: a, AccessLogDisabled: true}) }()
+
+	r, err := waitConnGet("http://" + a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Body.Close()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout while shutting down")
+	}
+}
+
+func TestSystemdNotify(t *testing.T) {
+	a, err := findAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "skipper-systemd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", socket)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	receive := func() string {
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		b := make([]byte, 1024)
+		n, err := conn.Read(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(b[:n])
+	}
+
+	s, err := New(Options{
+		Address:           a,
+		AccessLogDisabled: true,
+		CustomDataClients: []routing.DataClient{testdataclient.New(nil)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.Serve() }()
+
+	if n := receive(); !strings.HasPrefix(n, "READY=1") {
+		t.Error("invalid notification", n)
+	}
+
+	if err := s.checkListener(); err != nil {
+		t.Error(err)
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+
+	if n := receive(); !strings.HasPrefix(n, "STOPPING=1") {
+		t.Error("invalid notification", n)
+	}
+
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+
+	if err := s.checkListener(); err == nil {
+		t.Error("failed to fail after shutdown")
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	for _, test := range []struct {
+		tlsAddress, url, expect string
+	}{
+		{":443", "http://www.example.org/foo?bar=baz", "https://www.example.org/foo?bar=baz"},
+		{":9443", "http://www.example.org:9080/foo", "https://www.example.org:9443/foo"},
+		{"", "http://www.example.org/", "https://www.example.org/"},
+	} {
+		req, err := http.NewRequest("POST", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp := httptest.NewRecorder()
+		httpsRedirect(test.tlsAddress).ServeHTTP(rsp, req)
+		if rsp.Code != http.StatusPermanentRedirect || rsp.Header().Get("Location") != test.expect {
+			t.Error("invalid redirect", test.url, rsp.Code, rsp.Header().Get("Location"))
+		}
+	}
+}
+
+func TestKeyPairs(t *testing.T) {
+	o := Options{CertPathTLS: "a.crt,b.crt", KeyPathTLS: "a.key,b.key"}
+	p, err := o.keyPairs()
+	if err != nil || len(p) != 2 || p[1].CertFile != "b.crt" || p[1].KeyFile != "b.key" {
+		t.Error("invalid key pairs", p, err)
+	}
+
+	o = Options{CertPathTLS: "a.crt,b.crt", KeyPathTLS: "a.key"}
+	if _, err := o.keyPairs(); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestCheckFIPS(t *testing.T) {
+	for _, o := range []Options{
+		{FIPS: true, ProxyFlags: proxy.Insecure},
+		{FIPS: true, EtcdInsecure: true},
+		{FIPS: true, TLSMinVersion: "1.0"},
+		{FIPS: true, UpstreamTLSCurves: []string{"X25519"}},
+	} {
+		if err := o.checkFIPS(); err == nil {
+			t.Error("failed to fail", o)
+		}
+	}
+
+	o := Options{FIPS: true, TLSMinVersion: "1.3", UpstreamTLSCurves: []string{"P384"}}
+	if err := o.checkFIPS(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLateHandler(t *testing.T) {
+	h := &lateHandler{}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/transports", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Error("unexpected status before the handler was set", w.Code)
+	}
+
+	h.set(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusTeapot) }))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/transports", nil))
+	if w.Code != http.StatusTeapot {
+		t.Error("failed to serve the handler", w.Code)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
@@ -51,7 +52,7 @@ func main() {
 	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
-		err = skipper.Run(skipper.Options{
+		err = skipper.Run(context.Background(), skipper.Options{
 			AccessLogDisabled: true,
 			Address:           address,
 			RoutesFile:        routesFile})
@@ -0,0 +1,37 @@
+/*
+Package slowclient implements protections against the clients that keep
+the connections of the proxy busy by sending their requests slowly, e.g.
+the slowloris attack.
+
+The protections are applied on the connections of the proxy listener,
+below the TLS layer:
+
+    - the header timeout limits the time of receiving the header of a
+      request, including the TLS handshake of the new connections,
+    - the minimum body rate closes the connections whose request body is
+      received slower than the configured rate, after an initial timeout,
+    - the connection limit caps the number of the concurrent connections
+      from the same IP address.
+
+Example:
+
+    skipper -slow-client-header-timeout 10s -slow-client-min-body-rate 500 -max-conns-per-ip 64
+
+The header timeout is counted from accepting the connection, and, on the
+keep-alive connections, from the first byte of the subsequent requests,
+so that the idle connections are not affected. The time allowed for
+receiving a request body is the initial body timeout, extended by one
+second for every received -slow-client-min-body-rate bytes, and it is
+counted only while the body is read.
+
+On the HTTP/2 connections, the protections apply only to the connection
+preface and the first request, because the requests are multiplexed.
+
+When skipper runs behind a load balancer, the connection limit applies to
+the connections of the load balancer, too.
+
+The enforcement actions are counted in the metrics, with the following
+keys: slowclient.headertimeout, slowclient.bodyrate and
+slowclient.connlimit.
+*/
+package slowclient
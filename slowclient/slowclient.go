@@ -0,0 +1,366 @@
+package slowclient
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/metrics"
+)
+
+// DefaultBodyTimeout is the default time allowed for receiving the
+// request bodies, before the minimum transfer rate is applied.
+const DefaultBodyTimeout = 10 * time.Second
+
+// The enforcement actions, as counted in the metrics with the
+// slowclient.<action> keys.
+const (
+	ActionHeaderTimeout = "headertimeout"
+	ActionBodyRate      = "bodyrate"
+	ActionConnLimit     = "connlimit"
+)
+
+type phase int
+
+const (
+	// receiving the header of a request
+	phaseHeader phase = iota
+
+	// waiting for the next request on a keep-alive connection
+	phaseIdle
+
+	// the request is handled
+	phaseActive
+
+	// the handler reads the request body
+	phaseBody
+
+	// the connection is not controlled anymore, e.g. HTTP/2
+	phaseExempt
+)
+
+// Options for the slow client protection. The zero values disable the
+// individual protections.
+type Options struct {
+
+	// The maximum time of receiving the header of a request, counted
+	// from accepting the connection, or from receiving the first byte
+	// of the subsequent requests on the keep-alive connections. It
+	// includes the TLS handshake of the new connections.
+	HeaderTimeout time.Duration
+
+	// The minimum transfer rate of the request bodies, in bytes per
+	// second. The time allowed for receiving a body is the
+	// BodyTimeout, extended by one second for every MinBodyRate bytes
+	// received.
+	MinBodyRate int
+
+	// The initial time allowed for receiving a request body, when the
+	// minimum transfer rate is enforced. Default: 10s.
+	BodyTimeout time.Duration
+
+	// The maximum number of the concurrent connections from the same
+	// IP address. When skipper runs behind a load balancer, it needs
+	// to be high enough for the connections of the load balancer.
+	MaxConnsPerIP int
+}
+
+// Guard enforces the time limits on the connections of a listener,
+// and limits the number of concurrent connections per client IP.
+type Guard struct {
+	options Options
+	metrics metrics.Metrics
+	mx      sync.Mutex
+	conns   map[string]*conn
+	perIP   map[string]int
+}
+
+type listener struct {
+	net.Listener
+	guard *Guard
+}
+
+type conn struct {
+	net.Conn
+	guard          *Guard
+	ip             string
+	mx             sync.Mutex
+	phase          phase
+	start          time.Time
+	bodyBytes      int64
+	serverDeadline time.Time
+	applied        time.Time
+	closeOnce      sync.Once
+}
+
+type handler struct {
+	guard *Guard
+	next  http.Handler
+}
+
+type body struct {
+	io.ReadCloser
+	conn    *conn
+	started bool
+	done    bool
+}
+
+// NewGuard creates the slow client protection.
+func NewGuard(o Options) *Guard {
+	if o.BodyTimeout <= 0 {
+		o.BodyTimeout = DefaultBodyTimeout
+	}
+
+	return &Guard{
+		options: o,
+		metrics: metrics.Default,
+		conns:   make(map[string]*conn),
+		perIP:   make(map[string]int),
+	}
+}
+
+func (g *Guard) enforced(action string, c *conn) {
+	log.Debugf("slow client protection: %s, client: %s", action, c.RemoteAddr())
+	g.metrics.IncCounter(fmt.Sprintf(metrics.KeySlowClient, action))
+}
+
+func hostIP(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+
+	return addr
+}
+
+// Listener wraps a listener, so that its connections are controlled by
+// the guard. It needs to receive the raw connections, e.g. below the TLS
+// layer.
+func (g *Guard) Listener(l net.Listener) net.Listener {
+	return &listener{Listener: l, guard: g}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if gc, ok := l.guard.register(c); ok {
+			return gc, nil
+		}
+	}
+}
+
+func (g *Guard) register(c net.Conn) (*conn, bool) {
+	gc := &conn{
+		Conn:  c,
+		guard: g,
+		ip:    hostIP(c.RemoteAddr().String()),
+		phase: phaseHeader,
+		start: time.Now(),
+	}
+
+	g.mx.Lock()
+	if g.options.MaxConnsPerIP > 0 && g.perIP[gc.ip] >= g.options.MaxConnsPerIP {
+		g.mx.Unlock()
+		g.enforced(ActionConnLimit, gc)
+		c.Close()
+		return nil, false
+	}
+
+	g.perIP[gc.ip]++
+	g.conns[c.RemoteAddr().String()] = gc
+	g.mx.Unlock()
+	return gc, true
+}
+
+func (g *Guard) unregister(c *conn) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+	if g.perIP[c.ip]--; g.perIP[c.ip] <= 0 {
+		delete(g.perIP, c.ip)
+	}
+
+	delete(g.conns, c.RemoteAddr().String())
+}
+
+// the connection of a request, when it was accepted by the guard
+func (g *Guard) conn(r *http.Request) *conn {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+	return g.conns[r.RemoteAddr]
+}
+
+func earlier(a, b time.Time) time.Time {
+	if a.IsZero() || !b.IsZero() && b.Before(a) {
+		return b
+	}
+
+	return a
+}
+
+// the deadline enforced by the guard in the current phase, or zero
+func (c *conn) ownDeadline() time.Time {
+	o := c.guard.options
+	switch c.phase {
+	case phaseHeader:
+		if o.HeaderTimeout > 0 {
+			return c.start.Add(o.HeaderTimeout)
+		}
+	case phaseBody:
+		if o.MinBodyRate > 0 {
+			rate := time.Duration(c.bodyBytes) * time.Second / time.Duration(o.MinBodyRate)
+			return c.start.Add(o.BodyTimeout + rate)
+		}
+	}
+
+	return time.Time{}
+}
+
+// applies the earlier of the deadline set by the server and the one of
+// the guard. Expects the lock to be held.
+func (c *conn) applyDeadline() error {
+	d := earlier(c.serverDeadline, c.ownDeadline())
+	if d.Equal(c.applied) {
+		return nil
+	}
+
+	c.applied = d
+	return c.Conn.SetReadDeadline(d)
+}
+
+// changes the phase, unless the connection is exempt. When from is not
+// -1, the phase is changed only from the specified one, because the
+// body of a request may be read after the handler returned, e.g. by
+// the transport of the proxy.
+func (c *conn) setPhase(from, to phase) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.phase == phaseExempt || from >= 0 && c.phase != from {
+		return
+	}
+
+	c.phase = to
+	c.start = time.Now()
+	c.bodyBytes = 0
+	c.applyDeadline()
+}
+
+func (c *conn) Read(p []byte) (int, error) {
+	c.mx.Lock()
+	c.applyDeadline()
+	own := c.ownDeadline()
+	server := c.serverDeadline
+	ph := c.phase
+	c.mx.Unlock()
+
+	n, err := c.Conn.Read(p)
+
+	c.mx.Lock()
+	switch {
+	case n > 0 && c.phase == phaseIdle:
+		c.phase = phaseHeader
+		c.start = time.Now()
+	case c.phase == phaseBody:
+		c.bodyBytes += int64(n)
+	}
+	c.mx.Unlock()
+
+	if ne, ok := err.(net.Error); ok && ne.Timeout() && !own.IsZero() && !time.Now().Before(own) &&
+		(server.IsZero() || own.Before(server)) {
+		if ph == phaseBody {
+			c.guard.enforced(ActionBodyRate, c)
+		} else {
+			c.guard.enforced(ActionHeaderTimeout, c)
+		}
+	}
+
+	return n, err
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.serverDeadline = t
+	return c.applyDeadline()
+}
+
+func (c *conn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+
+	return c.Conn.SetWriteDeadline(t)
+}
+
+func (c *conn) Close() error {
+	c.closeOnce.Do(func() { c.guard.unregister(c) })
+	return c.Conn.Close()
+}
+
+// Wrap returns a handler that tracks the requests of the connections
+// accepted by the guard, to apply the header timeout only while the
+// request header is received, and the minimum transfer rate only while
+// the request body is read.
+func (g *Guard) Wrap(next http.Handler) http.Handler {
+	return &handler{guard: g, next: next}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c := h.guard.conn(r)
+	if c == nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	// the requests of an HTTP/2 connection are multiplexed, the guard
+	// controls only the connection preface and the first request
+	if r.ProtoMajor >= 2 {
+		c.setPhase(-1, phaseExempt)
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	c.setPhase(-1, phaseActive)
+	defer c.setPhase(-1, phaseIdle)
+	if r.Body != nil && h.guard.options.MinBodyRate > 0 {
+		r.Body = &body{ReadCloser: r.Body, conn: c}
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	if b.done {
+		return b.ReadCloser.Read(p)
+	}
+
+	if !b.started {
+		b.started = true
+		b.conn.setPhase(phaseActive, phaseBody)
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.finish()
+	}
+
+	return n, err
+}
+
+func (b *body) finish() {
+	if b.started && !b.done {
+		b.done = true
+		b.conn.setPhase(phaseBody, phaseActive)
+	}
+}
+
+func (b *body) Close() error {
+	b.finish()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,199 @@
+package slowclient
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startServer(o Options, h http.Handler) (*httptest.Server, *Guard) {
+	g := NewGuard(o)
+	s := httptest.NewUnstartedServer(g.Wrap(h))
+	s.Listener = g.Listener(s.Listener)
+	s.Start()
+	return s, g
+}
+
+func dial(t *testing.T, s *httptest.Server) net.Conn {
+	c, err := net.Dial("tcp", s.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+// waits until the server closes the connection, or the timeout
+func closedWithin(c net.Conn, timeout time.Duration) bool {
+	c.SetReadDeadline(time.Now().Add(timeout))
+	_, err := ioutil.ReadAll(c)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return false
+	}
+
+	return true
+}
+
+func TestHeaderTimeout(t *testing.T) {
+	s, _ := startServer(Options{HeaderTimeout: 100 * time.Millisecond}, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer s.Close()
+
+	c := dial(t, s)
+	defer c.Close()
+
+	if _, err := c.Write([]byte("GET / HTTP/1.1\r\nHost: www.example.org\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !closedWithin(c, time.Second) {
+		t.Error("failed to close the connection")
+	}
+}
+
+func TestHeaderTimeoutNotAppliedOnIdle(t *testing.T) {
+	s, _ := startServer(Options{HeaderTimeout: 100 * time.Millisecond}, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer s.Close()
+
+	c := dial(t, s)
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	for i := 0; i < 2; i++ {
+		if _, err := c.Write([]byte("GET / HTTP/1.1\r\nHost: www.example.org\r\n\r\n")); err != nil {
+			t.Fatal(err)
+		}
+
+		rsp, err := http.ReadResponse(r, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Body.Close()
+		if rsp.StatusCode != http.StatusOK {
+			t.Fatal("unexpected status", rsp.StatusCode)
+		}
+
+		// idle longer than the header timeout
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func TestMinBodyRate(t *testing.T) {
+	readErr := make(chan error, 1)
+	s, _ := startServer(Options{
+		MinBodyRate: 100,
+		BodyTimeout: 100 * time.Millisecond,
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := ioutil.ReadAll(r.Body)
+		readErr <- err
+	}))
+	defer s.Close()
+
+	c := dial(t, s)
+	defer c.Close()
+
+	if _, err := c.Write([]byte("POST / HTTP/1.1\r\nHost: www.example.org\r\nContent-Length: 1000\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 20 bytes per second, while the minimum is 100
+	go func() {
+		for i := 0; i < 40; i++ {
+			if _, err := c.Write([]byte("x")); err != nil {
+				return
+			}
+
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+
+	select {
+	case err := <-readErr:
+		if err == nil {
+			t.Error("failed to fail reading the body")
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("failed to enforce the minimum body rate")
+	}
+}
+
+func TestFastBody(t *testing.T) {
+	s, _ := startServer(Options{
+		MinBodyRate: 100,
+		BodyTimeout: 100 * time.Millisecond,
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Write(b)
+	}))
+	defer s.Close()
+
+	body := strings.Repeat("x", 1<<16)
+	rsp, err := http.Post(s.URL, "text/plain", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rsp.Body.Close()
+	b, err := ioutil.ReadAll(rsp.Body)
+	if err != nil || string(b) != body {
+		t.Error("failed to echo the body", err, len(b))
+	}
+}
+
+func TestMaxConnsPerIP(t *testing.T) {
+	s, g := startServer(Options{MaxConnsPerIP: 1}, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer s.Close()
+
+	c1 := dial(t, s)
+	if _, err := c1.Write([]byte("GET / HTTP/1.1\r\nHost: www.example.org\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	rsp, err := http.ReadResponse(bufio.NewReader(c1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+
+	c2 := dial(t, s)
+	defer c2.Close()
+	if !closedWithin(c2, time.Second) {
+		t.Error("failed to reject the connection")
+	}
+
+	c1.Close()
+
+	// the closed connection is released
+	timeout := time.After(time.Second)
+	for {
+		g.mx.Lock()
+		n := g.perIP["127.0.0.1"]
+		g.mx.Unlock()
+		if n == 0 {
+			break
+		}
+
+		select {
+		case <-timeout:
+			t.Fatal("failed to release the connection")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	c3 := dial(t, s)
+	defer c3.Close()
+	if closedWithin(c3, 100*time.Millisecond) {
+		t.Error("failed to accept the connection")
+	}
+}
@@ -0,0 +1,34 @@
+/*
+Package spiffe implements the SPIFFE workload identity of the proxy
+towards the backends.
+
+The X.509 SVIDs of the proxy are received from the SPIFFE workload API,
+e.g. from the SPIRE agent running on the same node, and are used as the
+client certificates of the TLS connections to the backends. The workload
+API streams the rotated SVIDs before the current ones expire, and the new
+connections use them without restarting the proxy. The certificates of
+the backends are verified with the trust bundles received together with
+the SVIDs, including the bundles of the federated trust domains, and by
+their SPIFFE ID, instead of the host name:
+
+    skipper -spiffe -spiffe-socket unix:///run/spire/sockets/agent.sock
+
+When the -spiffe-socket flag is not set, the address of the workload API
+is taken from the SPIFFE_ENDPOINT_SOCKET environment variable. By
+default, the backends of the same trust domain as the proxy are
+accepted. The accepted backends can be restricted to a list of SPIFFE
+IDs, where an entry containing only a trust domain accepts any workload
+of that trust domain:
+
+    skipper -spiffe -spiffe-allowed-ids spiffe://example.org/backend,spiffe://partner.example.com
+
+When the identity is enabled, all the TLS connections to the backends are
+verified as SPIFFE workloads, and the backends not presenting an SVID
+are rejected. The proxy waits for the first SVID during the startup, and
+fails to start, when it is not received within 30 seconds. When the
+connection to the workload API breaks, the current SVID is used until
+the connection is restored.
+
+Only the X.509 SVIDs are supported, the JWT SVIDs are not.
+*/
+package spiffe
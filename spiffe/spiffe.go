@@ -0,0 +1,405 @@
+package spiffe
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// EndpointSocketEnv is the environment variable that the address of
+	// the workload API is taken from, when not set in the options.
+	EndpointSocketEnv = "SPIFFE_ENDPOINT_SOCKET"
+
+	// DefaultStartTimeout is the default time of waiting for the first
+	// SVID from the workload API.
+	DefaultStartTimeout = 30 * time.Second
+
+	minRetryDelay = time.Second
+	maxRetryDelay = 30 * time.Second
+)
+
+var (
+	errNoSVID     = errors.New("spiffe: no SVID received")
+	errNoSPIFFEID = errors.New("spiffe: the certificate has no SPIFFE ID")
+)
+
+// Options for creating an SVID source.
+type Options struct {
+
+	// The address of the workload API, e.g.
+	// unix:///run/spire/sockets/agent.sock. When empty, it is taken
+	// from the SPIFFE_ENDPOINT_SOCKET environment variable.
+	SocketAddress string
+
+	// The SPIFFE IDs of the backends that the proxy accepts, e.g.
+	// spiffe://example.org/backend. An entry containing only a trust
+	// domain, e.g. spiffe://example.org, accepts any workload of the
+	// trust domain. When empty, the workloads of the trust domain of
+	// the proxy are accepted.
+	AllowedIDs []string
+
+	// The maximum time of waiting for the first SVID. Default: 30s.
+	StartTimeout time.Duration
+}
+
+// the SVID and the trust bundles received in an update
+type state struct {
+	id          string
+	trustDomain string
+	cert        *tls.Certificate
+	bundles     map[string]*x509.CertPool
+	expires     time.Time
+}
+
+// Source receives the X.509 SVIDs of the proxy from the SPIFFE workload
+// API, e.g. from a SPIRE agent, and keeps them up to date as they are
+// rotated. The SVIDs are used as the client certificates of the
+// connections to the backends, and the certificates of the backends are
+// verified with the trust bundles received together with the SVIDs.
+type Source struct {
+	options Options
+	client  *workloadClient
+	current atomic.Value
+	cancel  context.CancelFunc
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+// ParseID validates a SPIFFE ID, and returns its trust domain.
+func ParseID(id string) (string, error) {
+	u, err := url.Parse(id)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Scheme != "spiffe" || u.Host == "" || u.User != nil || u.Port() != "" ||
+		u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("spiffe: invalid SPIFFE ID: %s", id)
+	}
+
+	return strings.ToLower(u.Host), nil
+}
+
+// the SPIFFE ID of a certificate is its only URI SAN
+func certificateID(c *x509.Certificate) (string, string, error) {
+	if len(c.URIs) != 1 {
+		return "", "", errNoSPIFFEID
+	}
+
+	id := c.URIs[0].String()
+	td, err := ParseID(id)
+	return id, td, err
+}
+
+func parseBundle(der []byte) (*x509.CertPool, error) {
+	certs, err := x509.ParseCertificates(der)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	for _, c := range certs {
+		pool.AddCert(c)
+	}
+
+	return pool, nil
+}
+
+func newState(r *x509SVIDResponse) (*state, error) {
+	// the first SVID is the default one, according to the workload API
+	// specification
+	if len(r.svids) == 0 {
+		return nil, errNoSVID
+	}
+
+	svid := r.svids[0]
+	td, err := ParseID(svid.spiffeID)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := x509.ParseCertificates(svid.certs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(certs) == 0 {
+		return nil, errNoSVID
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(svid.key)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := key.(crypto.Signer); !ok {
+		return nil, errors.New("spiffe: unsupported SVID key")
+	}
+
+	cert := &tls.Certificate{PrivateKey: key, Leaf: certs[0]}
+	for _, c := range certs {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+
+	bundles := make(map[string]*x509.CertPool)
+	for ftd, b := range r.federatedBundles {
+		pool, err := parseBundle(b)
+		if err != nil {
+			return nil, err
+		}
+
+		bundles[strings.ToLower(strings.TrimPrefix(ftd, "spiffe://"))] = pool
+	}
+
+	// the own bundle takes precedence over a federated one of the same
+	// trust domain
+	if bundles[td], err = parseBundle(svid.bundle); err != nil {
+		return nil, err
+	}
+
+	return &state{
+		id:          svid.spiffeID,
+		trustDomain: td,
+		cert:        cert,
+		bundles:     bundles,
+		expires:     certs[0].NotAfter,
+	}, nil
+}
+
+// New creates an SVID source, and waits for the first SVID from the
+// workload API.
+func New(o Options) (*Source, error) {
+	if o.SocketAddress == "" {
+		o.SocketAddress = os.Getenv(EndpointSocketEnv)
+	}
+
+	if o.StartTimeout <= 0 {
+		o.StartTimeout = DefaultStartTimeout
+	}
+
+	for _, id := range o.AllowedIDs {
+		if _, err := ParseID(id); err != nil {
+			return nil, err
+		}
+	}
+
+	client, err := newWorkloadClient(o.SocketAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Source{
+		options: o,
+		client:  client,
+		cancel:  cancel,
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	started := make(chan struct{})
+	go s.run(ctx, started)
+
+	select {
+	case <-started:
+		return s, nil
+	case <-time.After(o.StartTimeout):
+		s.Close()
+		return nil, errors.New("spiffe: timeout while waiting for the first SVID")
+	}
+}
+
+// receives the updates until the stream fails
+func (s *Source) receive(ctx context.Context, started chan<- struct{}) error {
+	stream, err := s.client.fetchX509SVID(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer stream.close()
+	for {
+		r, err := stream.next()
+		if err != nil {
+			return err
+		}
+
+		st, err := newState(r)
+		if err != nil {
+			// keeping the current SVID, it may be still valid
+			log.Errorf("error while processing SVID update: %v", err)
+			continue
+		}
+
+		first := s.current.Load() == nil
+		s.current.Store(st)
+		log.Infof("SVID received: %s, expires: %v", st.id, st.expires)
+		if first {
+			close(started)
+		}
+	}
+}
+
+func (s *Source) run(ctx context.Context, started chan<- struct{}) {
+	defer close(s.done)
+	delay := minRetryDelay
+	for {
+		err := s.receive(ctx, started)
+		select {
+		case <-s.quit:
+			return
+		default:
+		}
+
+		log.Errorf("error while receiving SVIDs from the workload API, retrying in %v: %v", delay, err)
+		select {
+		case <-time.After(delay):
+		case <-s.quit:
+			return
+		}
+
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+func (s *Source) state() *state {
+	st, _ := s.current.Load().(*state)
+	return st
+}
+
+// ID returns the SPIFFE ID of the proxy.
+func (s *Source) ID() string {
+	if st := s.state(); st != nil {
+		return st.id
+	}
+
+	return ""
+}
+
+// Certificate returns the current SVID.
+func (s *Source) Certificate() (*tls.Certificate, error) {
+	st := s.state()
+	if st == nil {
+		return nil, errNoSVID
+	}
+
+	return st.cert, nil
+}
+
+func (s *Source) allowed(id, td string, st *state) bool {
+	if len(s.options.AllowedIDs) == 0 {
+		return td == st.trustDomain
+	}
+
+	for _, a := range s.options.AllowedIDs {
+		if a == id || a == "spiffe://"+td {
+			return true
+		}
+	}
+
+	return false
+}
+
+// VerifyPeer verifies the certificate chain of a backend with the trust
+// bundle of its trust domain, and checks whether its SPIFFE ID is
+// allowed. The host name of the backend is not verified.
+func (s *Source) VerifyPeer(rawCerts [][]byte) error {
+	st := s.state()
+	if st == nil {
+		return errNoSVID
+	}
+
+	if len(rawCerts) == 0 {
+		return errors.New("spiffe: no backend certificate")
+	}
+
+	var certs []*x509.Certificate
+	for _, raw := range rawCerts {
+		c, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+
+		certs = append(certs, c)
+	}
+
+	id, td, err := certificateID(certs[0])
+	if err != nil {
+		return err
+	}
+
+	roots, ok := st.bundles[td]
+	if !ok {
+		return fmt.Errorf("spiffe: no trust bundle for the trust domain: %s", td)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return err
+	}
+
+	if !s.allowed(id, td, st) {
+		return fmt.Errorf("spiffe: backend not allowed: %s", id)
+	}
+
+	return nil
+}
+
+// ClientConfig returns a copy of the TLS configuration of the
+// connections to the backends, with the current SVID set as the client
+// certificate, and verifying the backends by their SPIFFE ID instead of
+// the host name. The base configuration can be nil.
+func (s *Source) ClientConfig(base *tls.Config) *tls.Config {
+	var c *tls.Config
+	if base == nil {
+		c = &tls.Config{}
+	} else {
+		c = base.Clone()
+	}
+
+	c.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return s.Certificate()
+	}
+
+	// the standard verification is replaced, because it would check
+	// the host name against the DNS names of the certificate
+	c.InsecureSkipVerify = true
+	c.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		return s.VerifyPeer(rawCerts)
+	}
+
+	return c
+}
+
+// Close stops receiving the SVID updates.
+func (s *Source) Close() {
+	select {
+	case <-s.quit:
+	default:
+		close(s.quit)
+		s.cancel()
+	}
+
+	<-s.done
+	s.client.close()
+}
@@ -0,0 +1,411 @@
+package spiffe
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+type testSVID struct {
+	id    string
+	certs []byte
+	key   []byte
+	tls   tls.Certificate
+}
+
+type fakeAgent struct {
+	listener net.Listener
+	updates  chan []byte
+	dir      string
+}
+
+var serial int64
+
+func nextSerial() *big.Int {
+	serial++
+	return big.NewInt(serial)
+}
+
+func newCA(t *testing.T, trustDomain string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          nextSerial(),
+		Subject:               pkix.Name{CommonName: trustDomain},
+		URIs:                  []*url.URL{{Scheme: "spiffe", Host: trustDomain}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) issue(t *testing.T, id string) *testSVID {
+	u, err := url.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: nextSerial(),
+		URIs:         []*url.URL{u},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testSVID{
+		id:    id,
+		certs: der,
+		key:   pkcs8,
+		tls:   tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+	}
+}
+
+func appendField(b []byte, field int, value []byte) []byte {
+	var n [binary.MaxVarintLen64]byte
+	b = append(b, n[:binary.PutUvarint(n[:], uint64(field<<3|2))]...)
+	b = append(b, n[:binary.PutUvarint(n[:], uint64(len(value)))]...)
+	return append(b, value...)
+}
+
+func encodeResponse(svid *testSVID, bundle *testCA, federated map[string]*testCA) []byte {
+	var s []byte
+	s = appendField(s, 1, []byte(svid.id))
+	s = appendField(s, 2, svid.certs)
+	s = appendField(s, 3, svid.key)
+	s = appendField(s, 4, bundle.cert.Raw)
+
+	var m []byte
+	m = appendField(m, 1, s)
+	for td, ca := range federated {
+		var e []byte
+		e = appendField(e, 1, []byte(td))
+		e = appendField(e, 2, ca.cert.Raw)
+		m = appendField(m, 3, e)
+	}
+
+	return m
+}
+
+func startAgent(t *testing.T) *fakeAgent {
+	dir, err := ioutil.TempDir("", "spiffe-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("unix", filepath.Join(dir, "agent.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &fakeAgent{listener: l, updates: make(chan []byte, 4), dir: dir}
+	srv := &http2.Server{}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go srv.ServeConn(c, &http2.ServeConnOpts{Handler: http.HandlerFunc(a.serveHTTP)})
+		}
+	}()
+
+	return a
+}
+
+func (a *fakeAgent) address() string {
+	return "unix://" + a.listener.Addr().String()
+}
+
+func (a *fakeAgent) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/SpiffeWorkloadAPI/FetchX509SVID" || r.Header.Get("Workload.spiffe.io") != "true" {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Grpc-Status", "3")
+		w.Header().Set("Grpc-Message", "missing security header")
+		return
+	}
+
+	ioutil.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+	for {
+		select {
+		case m := <-a.updates:
+			var h [grpcHeaderSize]byte
+			binary.BigEndian.PutUint32(h[1:], uint32(len(m)))
+			w.Write(h[:])
+			w.Write(m)
+			w.(http.Flusher).Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (a *fakeAgent) close() {
+	a.listener.Close()
+	os.RemoveAll(a.dir)
+}
+
+func TestParseID(t *testing.T) {
+	for _, ti := range []struct {
+		id          string
+		trustDomain string
+		fail        bool
+	}{{
+		id:          "spiffe://example.org/backend",
+		trustDomain: "example.org",
+	}, {
+		id:          "spiffe://Example.org",
+		trustDomain: "example.org",
+	}, {
+		id:   "https://example.org/backend",
+		fail: true,
+	}, {
+		id:   "spiffe:///backend",
+		fail: true,
+	}, {
+		id:   "spiffe://example.org:443/backend",
+		fail: true,
+	}, {
+		id:   "spiffe://example.org/backend?foo=bar",
+		fail: true,
+	}} {
+		t.Run(ti.id, func(t *testing.T) {
+			td, err := ParseID(ti.id)
+			if ti.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if td != ti.trustDomain {
+				t.Errorf("invalid trust domain, got: %s, expected: %s", td, ti.trustDomain)
+			}
+		})
+	}
+}
+
+func TestReceivesRotatedSVIDs(t *testing.T) {
+	agent := startAgent(t)
+	defer agent.close()
+
+	ca := newCA(t, "example.org")
+	first := ca.issue(t, "spiffe://example.org/skipper")
+	agent.updates <- encodeResponse(first, ca, nil)
+
+	s, err := New(Options{SocketAddress: agent.address()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	if s.ID() != "spiffe://example.org/skipper" {
+		t.Errorf("invalid SPIFFE ID: %s", s.ID())
+	}
+
+	c, err := s.Certificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(c.Certificate[0]) != string(first.certs) {
+		t.Fatal("invalid SVID")
+	}
+
+	second := ca.issue(t, "spiffe://example.org/skipper")
+	agent.updates <- encodeResponse(second, ca, nil)
+
+	timeout := time.After(3 * time.Second)
+	for {
+		c, err := s.Certificate()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(c.Certificate[0]) == string(second.certs) {
+			return
+		}
+
+		select {
+		case <-timeout:
+			t.Fatal("rotated SVID not received")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestStartTimeout(t *testing.T) {
+	agent := startAgent(t)
+	defer agent.close()
+
+	if _, err := New(Options{
+		SocketAddress: agent.address(),
+		StartTimeout:  30 * time.Millisecond,
+	}); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestInvalidAddress(t *testing.T) {
+	if _, err := New(Options{SocketAddress: "tcp://127.0.0.1:8081"}); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newCA(t, "example.org")
+	partnerCA := newCA(t, "partner.example.com")
+	proxySVID := ca.issue(t, "spiffe://example.org/skipper")
+
+	for _, ti := range []struct {
+		msg        string
+		backend    *testSVID
+		allowedIDs []string
+		federated  map[string]*testCA
+		fail       bool
+	}{{
+		msg:     "same trust domain",
+		backend: ca.issue(t, "spiffe://example.org/backend"),
+	}, {
+		msg:        "allowed ID",
+		backend:    ca.issue(t, "spiffe://example.org/backend"),
+		allowedIDs: []string{"spiffe://example.org/backend"},
+	}, {
+		msg:        "not allowed ID",
+		backend:    ca.issue(t, "spiffe://example.org/backend"),
+		allowedIDs: []string{"spiffe://example.org/other"},
+		fail:       true,
+	}, {
+		msg:     "no bundle for the trust domain",
+		backend: partnerCA.issue(t, "spiffe://partner.example.com/backend"),
+		fail:    true,
+	}, {
+		msg:       "federated trust domain, not allowed by default",
+		backend:   partnerCA.issue(t, "spiffe://partner.example.com/backend"),
+		federated: map[string]*testCA{"spiffe://partner.example.com": partnerCA},
+		fail:      true,
+	}, {
+		msg:        "federated trust domain, allowed",
+		backend:    partnerCA.issue(t, "spiffe://partner.example.com/backend"),
+		federated:  map[string]*testCA{"spiffe://partner.example.com": partnerCA},
+		allowedIDs: []string{"spiffe://partner.example.com"},
+	}, {
+		msg:     "forged trust domain",
+		backend: newCA(t, "example.org").issue(t, "spiffe://example.org/backend"),
+		fail:    true,
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			agent := startAgent(t)
+			defer agent.close()
+			agent.updates <- encodeResponse(proxySVID, ca, ti.federated)
+
+			s, err := New(Options{SocketAddress: agent.address(), AllowedIDs: ti.allowedIDs})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer s.Close()
+
+			clientCAs := x509.NewCertPool()
+			clientCAs.AddCert(ca.cert)
+
+			var clientID string
+			backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				clientID = r.TLS.PeerCertificates[0].URIs[0].String()
+			}))
+
+			backend.TLS = &tls.Config{
+				Certificates: []tls.Certificate{ti.backend.tls},
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				ClientCAs:    clientCAs,
+			}
+
+			backend.StartTLS()
+			defer backend.Close()
+
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: s.ClientConfig(nil)}}
+			rsp, err := client.Get(backend.URL)
+			if ti.fail {
+				if err == nil {
+					rsp.Body.Close()
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rsp.Body.Close()
+			if clientID != "spiffe://example.org/skipper" {
+				t.Errorf("invalid client ID: %s", clientID)
+			}
+		})
+	}
+}
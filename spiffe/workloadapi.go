@@ -0,0 +1,278 @@
+package spiffe
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http2"
+)
+
+const (
+	fetchX509SVIDURL = "http://localhost/SpiffeWorkloadAPI/FetchX509SVID"
+
+	// the size of the length-prefix of the gRPC messages
+	grpcHeaderSize = 5
+
+	// the SVID responses are small, the limit protects only against
+	// a misbehaving agent
+	maxMessageSize = 4 << 20
+)
+
+var (
+	errInvalidMessage = errors.New("spiffe: invalid workload API message")
+	errMessageTooLong = errors.New("spiffe: workload API message too long")
+)
+
+// x509SVID as in the X509SVID message of the workload API
+type x509SVID struct {
+	spiffeID string
+	certs    []byte
+	key      []byte
+	bundle   []byte
+}
+
+// x509SVIDResponse as in the X509SVIDResponse message of the workload
+// API
+type x509SVIDResponse struct {
+	svids            []x509SVID
+	federatedBundles map[string][]byte
+}
+
+// the client of the workload API, implementing only the streaming
+// FetchX509SVID call, over gRPC, using HTTP/2 without TLS on a unix
+// socket
+type workloadClient struct {
+	transport *http2.Transport
+}
+
+type svidStream struct {
+	body io.ReadCloser
+}
+
+// socketPath accepts the socket address in the format of the
+// SPIFFE_ENDPOINT_SOCKET environment variable, unix:///path, or as a
+// plain path.
+func socketPath(address string) (string, error) {
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		address = strings.TrimPrefix(address, "unix://")
+	case strings.Contains(address, "://"):
+		return "", fmt.Errorf("spiffe: unsupported workload API address: %s", address)
+	}
+
+	if address == "" {
+		return "", errors.New("spiffe: missing workload API address")
+	}
+
+	return address, nil
+}
+
+func newWorkloadClient(address string) (*workloadClient, error) {
+	path, err := socketPath(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &workloadClient{transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(string, string, *tls.Config) (net.Conn, error) {
+			return net.Dial("unix", path)
+		},
+	}}, nil
+}
+
+func grpcError(h http.Header) error {
+	status := h.Get("Grpc-Status")
+	if status == "" || status == "0" {
+		return nil
+	}
+
+	return fmt.Errorf("spiffe: workload API error: %s %s", status, h.Get("Grpc-Message"))
+}
+
+// fetchX509SVID starts the stream of the SVID updates. The stream is
+// closed when the context is canceled.
+func (c *workloadClient) fetchX509SVID(ctx context.Context) (*svidStream, error) {
+	// an empty X509SVIDRequest message
+	req, err := http.NewRequest("POST", fetchX509SVIDURL, bytes.NewReader(make([]byte, grpcHeaderSize)))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+
+	// required by the workload API, to protect against the server
+	// side request forgery
+	req.Header.Set("Workload.spiffe.io", "true")
+
+	rsp, err := c.transport.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		rsp.Body.Close()
+		return nil, fmt.Errorf("spiffe: workload API responded with: %d", rsp.StatusCode)
+	}
+
+	if err := grpcError(rsp.Header); err != nil {
+		rsp.Body.Close()
+		return nil, err
+	}
+
+	return &svidStream{body: rsp.Body}, nil
+}
+
+func (c *workloadClient) close() {
+	c.transport.CloseIdleConnections()
+}
+
+// next blocks until the next update is received.
+func (s *svidStream) next() (*x509SVIDResponse, error) {
+	var h [grpcHeaderSize]byte
+	if _, err := io.ReadFull(s.body, h[:]); err != nil {
+		return nil, err
+	}
+
+	if h[0] != 0 {
+		return nil, errors.New("spiffe: compressed workload API messages are not supported")
+	}
+
+	size := binary.BigEndian.Uint32(h[1:])
+	if size > maxMessageSize {
+		return nil, errMessageTooLong
+	}
+
+	m := make([]byte, size)
+	if _, err := io.ReadFull(s.body, m); err != nil {
+		return nil, err
+	}
+
+	return decodeX509SVIDResponse(m)
+}
+
+func (s *svidStream) close() {
+	s.body.Close()
+}
+
+// decodes a single field of a protobuf message, supporting only the wire
+// types used by the workload API messages
+func nextField(m []byte) (field int, value []byte, rest []byte, err error) {
+	tag, n := binary.Uvarint(m)
+	if n <= 0 {
+		return 0, nil, nil, errInvalidMessage
+	}
+
+	m = m[n:]
+	field = int(tag >> 3)
+	switch tag & 7 {
+	case 0:
+		// varint, e.g. an enum, not used by the SVID messages
+		_, n = binary.Uvarint(m)
+		if n <= 0 {
+			return 0, nil, nil, errInvalidMessage
+		}
+
+		return field, nil, m[n:], nil
+	case 2:
+		l, n := binary.Uvarint(m)
+		if n <= 0 || l > uint64(len(m)-n) {
+			return 0, nil, nil, errInvalidMessage
+		}
+
+		m = m[n:]
+		return field, m[:l], m[l:], nil
+	default:
+		return 0, nil, nil, errInvalidMessage
+	}
+}
+
+func decodeX509SVID(m []byte) (x509SVID, error) {
+	var s x509SVID
+	for len(m) > 0 {
+		field, value, rest, err := nextField(m)
+		if err != nil {
+			return s, err
+		}
+
+		switch field {
+		case 1:
+			s.spiffeID = string(value)
+		case 2:
+			s.certs = value
+		case 3:
+			s.key = value
+		case 4:
+			s.bundle = value
+		}
+
+		m = rest
+	}
+
+	return s, nil
+}
+
+func decodeBundleEntry(m []byte) (string, []byte, error) {
+	var (
+		key   string
+		value []byte
+	)
+
+	for len(m) > 0 {
+		field, v, rest, err := nextField(m)
+		if err != nil {
+			return "", nil, err
+		}
+
+		switch field {
+		case 1:
+			key = string(v)
+		case 2:
+			value = v
+		}
+
+		m = rest
+	}
+
+	return key, value, nil
+}
+
+func decodeX509SVIDResponse(m []byte) (*x509SVIDResponse, error) {
+	r := &x509SVIDResponse{federatedBundles: make(map[string][]byte)}
+	for len(m) > 0 {
+		field, value, rest, err := nextField(m)
+		if err != nil {
+			return nil, err
+		}
+
+		switch field {
+		case 1:
+			s, err := decodeX509SVID(value)
+			if err != nil {
+				return nil, err
+			}
+
+			r.svids = append(r.svids, s)
+		case 3:
+			td, bundle, err := decodeBundleEntry(value)
+			if err != nil {
+				return nil, err
+			}
+
+			r.federatedBundles[td] = bundle
+		}
+
+		m = rest
+	}
+
+	return r, nil
+}
@@ -0,0 +1,40 @@
+/*
+Package strictparsing provides a hardened parsing mode for the incoming
+HTTP/1.x requests, that closes the request smuggling vectors, when
+skipper runs in front of backends, or behind load balancers, with a
+lenient HTTP parser.
+
+The mode is enabled with the -strict-parsing flag. The connections of
+the proxy listener are validated before the requests reach the Go
+HTTP server, and the requests are rejected with 400 Bad Request, and the
+connection is closed, when:
+
+    - both Content-Length and Transfer-Encoding are set
+    - Content-Length or Transfer-Encoding is set multiple times
+    - Content-Length is not a plain decimal number
+    - Transfer-Encoding is not chunked, or it is used with HTTP/1.0
+    - the chunk sizes or the chunk terminators are invalid
+    - the request line or a header name is invalid, e.g. whitespace
+      before the colon
+    - a header value contains control characters
+    - the lines are terminated with bare LF, or folded
+    - the Host header is set multiple times
+
+When the invalid chunk is found while the body of a request is already
+being proxied, reading the body fails, and the connection is closed
+after the response of the proxy.
+
+The requests whose request line and header fields exceed the size set
+with the -max-header-bytes flag, 1M by default, are rejected with 431
+Request Header Fields Too Large.
+
+The framing of the messages is followed, so that the pipelined requests
+are validated, too. After a protocol upgrade, e.g. WebSocket, or an
+HTTP/2 connection preface, the connection is not validated anymore.
+
+On the TLS listener, the connections are decrypted by the listener,
+before the validation, instead of the Go HTTP server. In this case,
+HTTP/2 is not offered to the clients, because only the HTTP/1.x
+requests can be validated.
+*/
+package strictparsing
@@ -0,0 +1,199 @@
+package strictparsing
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// the framing and the connection handling of a request, as defined by
+// its header
+type message struct {
+	chunked       bool
+	contentLength int64
+	upgrade       bool
+}
+
+// token characters, as defined by RFC 7230
+func isTokenChar(b byte) bool {
+	switch {
+	case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9':
+		return true
+	default:
+		return strings.IndexByte("!#$%&'*+-.^_`|~", b) >= 0
+	}
+}
+
+func validToken(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+
+	for _, bi := range b {
+		if !isTokenChar(bi) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// field values can contain the visible characters, spaces, tabs and
+// obs-text, but no other control characters
+func validValue(b []byte) bool {
+	for _, bi := range b {
+		if bi < ' ' && bi != '\t' || bi == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
+
+// the sign and the whitespace accepted by strconv are not allowed in
+// Content-Length
+func digits(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+
+	for _, bi := range b {
+		if bi < '0' || bi > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func validTarget(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+
+	for _, bi := range b {
+		if bi <= ' ' || bi == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseRequestLine(line []byte) (method, version string, err error) {
+	parts := bytes.Split(line, []byte(" "))
+	if len(parts) != 3 || !validToken(parts[0]) || !validTarget(parts[1]) {
+		return "", "", errors.New("invalid request line")
+	}
+
+	version = string(parts[2])
+	if version != "HTTP/1.1" && version != "HTTP/1.0" {
+		return "", "", errors.New("unsupported HTTP version")
+	}
+
+	return string(parts[0]), version, nil
+}
+
+// parses a header or trailer field line, and returns the lower case name
+// and the value without the surrounding whitespace
+func parseField(line []byte) (string, []byte, error) {
+	if line[0] == ' ' || line[0] == '\t' {
+		return "", nil, errors.New("obsolete line folding")
+	}
+
+	i := bytes.IndexByte(line, ':')
+	if i <= 0 || !validToken(line[:i]) {
+		return "", nil, errors.New("invalid header name")
+	}
+
+	value := bytes.Trim(line[i+1:], " \t")
+	if !validValue(value) {
+		return "", nil, errors.New("invalid header value")
+	}
+
+	return strings.ToLower(string(line[:i])), value, nil
+}
+
+func hasToken(list []byte, token string) bool {
+	for _, t := range bytes.Split(list, []byte(",")) {
+		if strings.EqualFold(string(bytes.TrimSpace(t)), token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validates the request line and the header fields, without the closing
+// empty line, and returns the framing of the request
+func parseHeader(b []byte) (message, error) {
+	lines := bytes.Split(b, crlf)
+	for _, l := range lines {
+		if bytes.IndexByte(l, '\r') >= 0 || bytes.IndexByte(l, '\n') >= 0 {
+			return message{}, errors.New("bare CR or LF")
+		}
+	}
+
+	method, version, err := parseRequestLine(lines[0])
+	if err != nil {
+		return message{}, err
+	}
+
+	var (
+		m                                 message
+		contentLengths, transferEncodings int
+		hosts                             int
+		connectionUpgrade, upgrade        bool
+	)
+
+	for _, l := range lines[1:] {
+		name, value, err := parseField(l)
+		if err != nil {
+			return message{}, err
+		}
+
+		switch name {
+		case "content-length":
+			contentLengths++
+			if !digits(value) {
+				return message{}, errors.New("invalid Content-Length")
+			}
+
+			if m.contentLength, err = strconv.ParseInt(string(value), 10, 64); err != nil {
+				return message{}, errors.New("invalid Content-Length")
+			}
+		case "transfer-encoding":
+			transferEncodings++
+			if !strings.EqualFold(string(value), "chunked") {
+				return message{}, errors.New("unsupported Transfer-Encoding")
+			}
+
+			m.chunked = true
+		case "host":
+			hosts++
+		case "connection":
+			connectionUpgrade = connectionUpgrade || hasToken(value, "upgrade")
+		case "upgrade":
+			upgrade = true
+		}
+	}
+
+	switch {
+	case contentLengths > 1:
+		return message{}, errors.New("multiple Content-Length headers")
+	case transferEncodings > 1:
+		return message{}, errors.New("multiple Transfer-Encoding headers")
+	case contentLengths > 0 && transferEncodings > 0:
+		return message{}, errors.New("both Content-Length and Transfer-Encoding")
+	case transferEncodings > 0 && version == "HTTP/1.0":
+		return message{}, errors.New("Transfer-Encoding in HTTP/1.0")
+	case hosts > 1:
+		return message{}, errors.New("multiple Host headers")
+	}
+
+	// after a protocol upgrade or a tunnel, the connection doesn't
+	// carry HTTP/1.x anymore
+	m.upgrade = connectionUpgrade && upgrade || method == "CONNECT"
+	return m, nil
+}
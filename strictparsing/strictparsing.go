@@ -0,0 +1,397 @@
+package strictparsing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultMaxHeaderBytes is the default maximum size of the request line
+// and the header fields of a request.
+const DefaultMaxHeaderBytes = http.DefaultMaxHeaderBytes
+
+const (
+	readSize          = 4096
+	maxChunkSizeBytes = 1024
+	rejectTimeout     = time.Second
+)
+
+type state int
+
+const (
+	stateHeader state = iota
+	stateBody
+	stateChunkSize
+	stateChunkData
+	stateChunkDataEnd
+	stateTrailer
+	statePassthrough
+)
+
+var (
+	crlf         = []byte("\r\n")
+	headerEnd    = []byte("\r\n\r\n")
+	http2Preface = []byte("PRI * HTTP/2.0\r\n")
+)
+
+// Options for the strict parsing listener.
+type Options struct {
+
+	// The maximum size of the request line and the header fields of a
+	// request, and of the trailer fields of the chunked requests.
+	// Default: 1M.
+	MaxHeaderBytes int
+}
+
+// Error describes the violation of the strict parsing rules. The
+// connections return it wrapped in a *net.OpError, so that the HTTP
+// server closes the connection. When the violation is found in the
+// header of a request, the rejection is sent, when the connection is
+// closed, after the response of the preceding request.
+type Error struct {
+	Reason string
+}
+
+type listener struct {
+	net.Listener
+	options Options
+}
+
+// conn validates the HTTP/1.x requests read from the underlying
+// connection, following the framing of the messages, so that the
+// pipelined requests and the bodies are handled, too.
+type conn struct {
+	net.Conn
+	options   Options
+	state     state
+	remaining int64
+	trailer   int
+	upgrade   bool
+	in        []byte
+	out       []byte
+	err       error
+	readErr   error
+	mx        sync.Mutex
+	reject    int
+}
+
+func (e *Error) Error() string { return "strict parsing: " + e.Reason }
+
+// NewListener wraps a listener, whose connections reject the requests
+// with ambiguous framing, invalid characters, or oversized headers,
+// before the HTTP server parses them. It needs to receive the plain
+// text HTTP/1.x connections, e.g. it cannot be applied below the TLS
+// layer, but it can wrap a listener created with tls.NewListener. In
+// that case, the HTTP server needs to be set up with ConnContext and
+// TLSState.
+func NewListener(l net.Listener, o Options) net.Listener {
+	if o.MaxHeaderBytes <= 0 {
+		o.MaxHeaderBytes = DefaultMaxHeaderBytes
+	}
+
+	return &listener{Listener: l, options: o}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{Conn: c, options: l.options}, nil
+}
+
+func (c *conn) fail(status int, reason string) {
+	log.Infof("strict parsing: rejected request from %s: %s", c.RemoteAddr(), reason)
+	c.err = &net.OpError{
+		Op:     "read",
+		Net:    c.LocalAddr().Network(),
+		Source: c.LocalAddr(),
+		Addr:   c.RemoteAddr(),
+		Err:    &Error{Reason: reason},
+	}
+
+	// the violations in the body or the trailer of a request are
+	// answered by the server as part of the response to the request
+	if c.state == stateHeader {
+		c.mx.Lock()
+		c.reject = status
+		c.mx.Unlock()
+	}
+}
+
+func (c *conn) Close() error {
+	c.mx.Lock()
+	status := c.reject
+	c.reject = 0
+	c.mx.Unlock()
+
+	if status != 0 {
+		text := http.StatusText(status)
+		c.SetWriteDeadline(time.Now().Add(rejectTimeout))
+		fmt.Fprintf(
+			c.Conn,
+			"HTTP/1.1 %d %s\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s",
+			status,
+			text,
+			len(text),
+			text,
+		)
+	}
+
+	return c.Conn.Close()
+}
+
+// the body data and the passthrough connections can be read directly
+// into the buffer of the caller
+func (c *conn) direct() bool {
+	return c.state == stateBody || c.state == stateChunkData || c.state == statePassthrough
+}
+
+func (c *conn) Read(p []byte) (int, error) {
+	for len(c.out) == 0 {
+		switch {
+		case c.err != nil:
+			return 0, c.err
+		case len(c.in) == 0 && c.readErr != nil:
+			return 0, c.readErr
+		case len(c.in) == 0 && c.direct():
+			return c.readDirect(p)
+		case c.process():
+		case c.readErr != nil:
+			return 0, c.readErr
+		default:
+			c.fill()
+		}
+	}
+
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+func (c *conn) readDirect(p []byte) (int, error) {
+	if c.state == statePassthrough {
+		return c.Conn.Read(p)
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+
+	n, err := c.Conn.Read(p)
+	c.consumed(n)
+	return n, err
+}
+
+func (c *conn) fill() {
+	b := make([]byte, readSize)
+	n, err := c.Conn.Read(b)
+	c.in = append(c.in, b[:n]...)
+	if err != nil {
+		c.readErr = err
+	}
+}
+
+// moves validated bytes from the input to the output
+func (c *conn) pass(n int) {
+	c.out = append(c.out, c.in[:n]...)
+	c.in = c.in[n:]
+}
+
+// counts the body or chunk bytes passed
+func (c *conn) consumed(n int) {
+	c.remaining -= int64(n)
+	if c.remaining > 0 {
+		return
+	}
+
+	if c.state == stateChunkData {
+		c.state = stateChunkDataEnd
+	} else {
+		c.endMessage()
+	}
+}
+
+func (c *conn) endMessage() {
+	if c.upgrade {
+		c.state = statePassthrough
+	} else {
+		c.state = stateHeader
+	}
+}
+
+// processes the input in the current state, and tells whether any
+// progress was made
+func (c *conn) process() bool {
+	switch c.state {
+	case stateHeader:
+		return c.processHeader()
+	case stateBody, stateChunkData:
+		n := len(c.in)
+		if int64(n) > c.remaining {
+			n = int(c.remaining)
+		}
+
+		c.pass(n)
+		c.consumed(n)
+		return true
+	case stateChunkSize:
+		return c.processChunkSize()
+	case stateChunkDataEnd:
+		if len(c.in) < len(crlf) {
+			return false
+		}
+
+		if !bytes.HasPrefix(c.in, crlf) {
+			c.fail(http.StatusBadRequest, "invalid chunk data")
+			return true
+		}
+
+		c.pass(len(crlf))
+		c.state = stateChunkSize
+		return true
+	case stateTrailer:
+		return c.processTrailer()
+	default:
+		c.pass(len(c.in))
+		return true
+	}
+}
+
+func (c *conn) processHeader() bool {
+	// empty lines before the request line are dropped, as recommended
+	// by RFC 7230
+	for bytes.HasPrefix(c.in, crlf) {
+		c.in = c.in[len(crlf):]
+	}
+
+	if bytes.HasPrefix(c.in, http2Preface) {
+		c.state = statePassthrough
+		return true
+	}
+
+	i := bytes.Index(c.in, headerEnd)
+	if i < 0 && len(c.in) <= c.options.MaxHeaderBytes {
+		return false
+	}
+
+	if i < 0 || i+len(headerEnd) > c.options.MaxHeaderBytes {
+		c.fail(http.StatusRequestHeaderFieldsTooLarge, "request header too large")
+		return true
+	}
+
+	m, err := parseHeader(c.in[:i])
+	if err != nil {
+		c.fail(http.StatusBadRequest, err.Error())
+		return true
+	}
+
+	c.pass(i + len(headerEnd))
+	c.upgrade = m.upgrade
+	switch {
+	case m.chunked:
+		c.state = stateChunkSize
+		c.trailer = 0
+	case m.contentLength > 0:
+		c.state = stateBody
+		c.remaining = m.contentLength
+	default:
+		c.endMessage()
+	}
+
+	return true
+}
+
+func (c *conn) processChunkSize() bool {
+	i := bytes.Index(c.in, crlf)
+	if i < 0 {
+		switch {
+		case bytes.IndexByte(c.in, '\n') >= 0:
+			c.fail(http.StatusBadRequest, "invalid chunk size line")
+			return true
+		case len(c.in) > maxChunkSizeBytes:
+			c.fail(http.StatusBadRequest, "chunk size line too long")
+			return true
+		default:
+			return false
+		}
+	}
+
+	size, err := parseChunkSize(c.in[:i])
+	if err != nil {
+		c.fail(http.StatusBadRequest, err.Error())
+		return true
+	}
+
+	c.pass(i + len(crlf))
+	if size == 0 {
+		c.state = stateTrailer
+	} else {
+		c.state = stateChunkData
+		c.remaining = size
+	}
+
+	return true
+}
+
+func (c *conn) processTrailer() bool {
+	i := bytes.Index(c.in, crlf)
+	if i < 0 {
+		if c.trailer+len(c.in) > c.options.MaxHeaderBytes {
+			c.fail(http.StatusBadRequest, "request trailer too large")
+			return true
+		}
+
+		return false
+	}
+
+	c.trailer += i + len(crlf)
+	if c.trailer > c.options.MaxHeaderBytes {
+		c.fail(http.StatusBadRequest, "request trailer too large")
+		return true
+	}
+
+	if i > 0 {
+		if _, _, err := parseField(c.in[:i]); err != nil {
+			c.fail(http.StatusBadRequest, err.Error())
+			return true
+		}
+	}
+
+	c.pass(i + len(crlf))
+	if i == 0 {
+		c.endMessage()
+	}
+
+	return true
+}
+
+func parseChunkSize(line []byte) (int64, error) {
+	// the chunk extensions are ignored, but validated
+	if i := bytes.IndexByte(line, ';'); i >= 0 {
+		if !validValue(line[i+1:]) {
+			return 0, errors.New("invalid chunk extension")
+		}
+
+		line = line[:i]
+	}
+
+	if len(line) == 0 || len(line) > 16 {
+		return 0, errors.New("invalid chunk size")
+	}
+
+	size, err := strconv.ParseInt(string(line), 16, 64)
+	if err != nil || size < 0 {
+		return 0, errors.New("invalid chunk size")
+	}
+
+	return size, nil
+}
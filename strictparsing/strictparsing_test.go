@@ -0,0 +1,196 @@
+package strictparsing
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T, o Options) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Write([]byte(r.URL.Path + ":" + string(b)))
+	})}
+
+	go s.Serve(NewListener(l, o))
+	return l.Addr().String(), func() { s.Close() }
+}
+
+// sends raw requests on a single connection, and returns the statuses
+// and the bodies of the responses
+func roundtrip(t *testing.T, address, requests string, count int) ([]int, []string) {
+	c, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(3 * time.Second))
+	if _, err := c.Write([]byte(requests)); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		statuses []int
+		bodies   []string
+	)
+
+	r := bufio.NewReader(c)
+	for i := 0; i < count; i++ {
+		rsp, err := http.ReadResponse(r, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		statuses = append(statuses, rsp.StatusCode)
+		bodies = append(bodies, string(b))
+	}
+
+	return statuses, bodies
+}
+
+func TestValidRequests(t *testing.T) {
+	address, close := startServer(t, Options{})
+	defer close()
+
+	statuses, bodies := roundtrip(t, address, ""+
+		"GET /get HTTP/1.1\r\nHost: www.example.org\r\n\r\n"+
+		"POST /length HTTP/1.1\r\nHost: www.example.org\r\nContent-Length: 3\r\n\r\nfoo"+
+		"\r\n"+
+		"POST /chunked HTTP/1.1\r\nHost: www.example.org\r\nTransfer-Encoding: chunked\r\n\r\n"+
+		"3;ext=1\r\nfoo\r\n3\r\nbar\r\n0\r\nX-Trailer: baz\r\n\r\n"+
+		"GET /last HTTP/1.1\r\nHost: www.example.org\r\nConnection: close\r\n\r\n", 4)
+
+	expected := []string{"/get:", "/length:foo", "/chunked:foobar", "/last:"}
+	for i := range expected {
+		if statuses[i] != http.StatusOK || bodies[i] != expected[i] {
+			t.Errorf("invalid response %d: %d, %s", i, statuses[i], bodies[i])
+		}
+	}
+}
+
+func TestInvalidRequests(t *testing.T) {
+	for _, test := range []struct {
+		title   string
+		request string
+	}{{
+		title: "Content-Length and Transfer-Encoding",
+		request: "POST / HTTP/1.1\r\nHost: www.example.org\r\nContent-Length: 6\r\nTransfer-Encoding: chunked\r\n\r\n" +
+			"0\r\n\r\nG",
+	}, {
+		title:   "multiple Content-Length",
+		request: "POST / HTTP/1.1\r\nHost: www.example.org\r\nContent-Length: 3\r\nContent-Length: 3\r\n\r\nfoo",
+	}, {
+		title:   "signed Content-Length",
+		request: "POST / HTTP/1.1\r\nHost: www.example.org\r\nContent-Length: +3\r\n\r\nfoo",
+	}, {
+		title:   "multiple Transfer-Encoding",
+		request: "POST / HTTP/1.1\r\nHost: www.example.org\r\nTransfer-Encoding: chunked\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n",
+	}, {
+		title:   "unsupported Transfer-Encoding",
+		request: "POST / HTTP/1.1\r\nHost: www.example.org\r\nTransfer-Encoding: gzip, chunked\r\n\r\n0\r\n\r\n",
+	}, {
+		title:   "Transfer-Encoding in HTTP/1.0",
+		request: "POST / HTTP/1.0\r\nHost: www.example.org\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n",
+	}, {
+		title:   "whitespace before colon",
+		request: "POST / HTTP/1.1\r\nHost: www.example.org\r\nTransfer-Encoding : chunked\r\n\r\n0\r\n\r\n",
+	}, {
+		title:   "obsolete line folding",
+		request: "GET / HTTP/1.1\r\nHost: www.example.org\r\nX-Foo: bar\r\n baz\r\n\r\n",
+	}, {
+		title:   "bare LF",
+		request: "GET / HTTP/1.1\r\nHost: www.example.org\nX-Foo: bar\r\n\r\n",
+	}, {
+		title:   "control character in value",
+		request: "GET / HTTP/1.1\r\nHost: www.example.org\r\nX-Foo: bar\x00baz\r\n\r\n",
+	}, {
+		title:   "multiple Host",
+		request: "GET / HTTP/1.1\r\nHost: www.example.org\r\nHost: evil.example.org\r\n\r\n",
+	}, {
+		title:   "invalid request line",
+		request: "GET  / HTTP/1.1\r\nHost: www.example.org\r\n\r\n",
+	}, {
+		title:   "header too large",
+		request: "GET / HTTP/1.1\r\nHost: www.example.org\r\nX-Foo: " + strings.Repeat("x", 256) + "\r\n\r\n",
+	}, {
+		title:   "invalid chunk size",
+		request: "POST / HTTP/1.1\r\nHost: www.example.org\r\nTransfer-Encoding: chunked\r\n\r\n0x3\r\nfoo\r\n0\r\n\r\n",
+	}, {
+		title:   "missing chunk terminator",
+		request: "POST / HTTP/1.1\r\nHost: www.example.org\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nfoobar\r\n0\r\n\r\n",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			address, close := startServer(t, Options{MaxHeaderBytes: 128})
+			defer close()
+
+			expected := http.StatusBadRequest
+			if test.title == "header too large" {
+				expected = http.StatusRequestHeaderFieldsTooLarge
+			}
+
+			statuses, _ := roundtrip(t, address, test.request, 1)
+			if statuses[0] != expected {
+				t.Errorf("failed to reject the request, got: %d", statuses[0])
+			}
+		})
+	}
+}
+
+func TestSmuggledRequestRejected(t *testing.T) {
+	address, close := startServer(t, Options{})
+	defer close()
+
+	statuses, bodies := roundtrip(t, address, ""+
+		"GET /first HTTP/1.1\r\nHost: www.example.org\r\n\r\n"+
+		"POST /second HTTP/1.1\r\nHost: www.example.org\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n"+
+		"0\r\n\r\n", 2)
+
+	if statuses[0] != http.StatusOK || bodies[0] != "/first:" {
+		t.Errorf("failed to serve the valid request: %d, %s", statuses[0], bodies[0])
+	}
+
+	if statuses[1] != http.StatusBadRequest {
+		t.Errorf("failed to reject the pipelined request: %d", statuses[1])
+	}
+}
+
+func TestUpgradePassthrough(t *testing.T) {
+	m, err := parseHeader([]byte("GET /ws HTTP/1.1\r\nHost: www.example.org\r\nConnection: keep-alive, Upgrade\r\nUpgrade: websocket"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.upgrade {
+		t.Error("failed to detect the upgrade")
+	}
+
+	c := &conn{state: stateHeader, options: Options{MaxHeaderBytes: DefaultMaxHeaderBytes}}
+	c.in = []byte("GET /ws HTTP/1.1\r\nHost: www.example.org\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n\x81\x05hello")
+	for c.process() && len(c.in) > 0 {
+	}
+
+	if c.err != nil || c.state != statePassthrough || len(c.out) != 91 {
+		t.Errorf("failed to pass through the upgraded connection: %v, %d, %d", c.err, c.state, len(c.out))
+	}
+}
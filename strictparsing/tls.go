@@ -0,0 +1,36 @@
+package strictparsing
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+)
+
+type connKey struct{}
+
+// ConnContext stores the connection of the requests in their context.
+// It is set as the ConnContext of the HTTP server, when the listener
+// wraps a TLS listener, so that TLSState can find the TLS connection.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, c)
+}
+
+// TLSState wraps the handler of an HTTP server, whose listener wraps a
+// TLS listener, and sets the TLS connection state of the requests. The
+// HTTP server sets it only when it accepts the TLS connections
+// directly.
+func TLSState(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil {
+			if c, ok := r.Context().Value(connKey{}).(*conn); ok {
+				if tc, ok := c.Conn.(*tls.Conn); ok {
+					s := tc.ConnectionState()
+					r.TLS = &s
+				}
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,31 @@
+/*
+Package systemd implements the notifications of the systemd service
+manager, so that the deployments managed by systemd get accurate
+readiness signaling, and automatic restarts, when the proxy stops
+serving the requests.
+
+When skipper is started by systemd with Type=notify, it sends:
+
+    - READY=1, when the proxy listener is bound and the first routing
+      table was applied,
+    - RELOADING=1, followed by READY=1, when it receives SIGHUP, and
+      reloads the TLS certificates,
+    - STOPPING=1, when it starts shutting down.
+
+When the watchdog is enabled with WatchdogSec, skipper pings it in half
+of the timeout, but only when the proxy listener responds to a request,
+so that systemd restarts the proxy, when it is wedged.
+
+Example unit:
+
+    [Service]
+    Type=notify
+    ExecStart=/usr/bin/skipper -routes-file /etc/skipper/routes.eskip
+    ExecReload=/bin/kill -HUP $MAINPID
+    WatchdogSec=30s
+    Restart=on-failure
+
+When the NOTIFY_SOCKET environment variable is not set, no notifications
+are sent.
+*/
+package systemd
@@ -0,0 +1,202 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The states sent to the service manager.
+const (
+	Ready     = "READY=1"
+	Reloading = "RELOADING=1"
+	Stopping  = "STOPPING=1"
+	Watchdog  = "WATCHDOG=1"
+)
+
+// Options for the notifications of the service manager.
+type Options struct {
+
+	// The socket of the service manager. Default: the value of the
+	// NOTIFY_SOCKET environment variable.
+	Socket string
+
+	// The watchdog timeout of the service. The watchdog is pinged in
+	// half of the timeout. Default: the value of the WATCHDOG_USEC
+	// environment variable, when WATCHDOG_PID is not set, or it is
+	// the pid of the process. When negative, the watchdog is not
+	// pinged.
+	WatchdogTimeout time.Duration
+
+	// When set, the watchdog is pinged only when the check succeeds,
+	// so that the service manager restarts the proxy, when it stops
+	// serving the requests.
+	Check func() error
+}
+
+// Notifier sends the state changes of the proxy to the service
+// manager, and pings the watchdog. The methods of a nil *Notifier do
+// nothing, so that the callers don't need to check whether the process
+// was started by systemd.
+type Notifier struct {
+	socket string
+	check  func() error
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+// WatchdogTimeout returns the watchdog timeout set by the service
+// manager, or 0, when the watchdog is not enabled for the process.
+func WatchdogTimeout() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Notify sends the states to the service manager, on the socket set in
+// the NOTIFY_SOCKET environment variable. It returns false, when the
+// variable is not set, e.g. when the process was not started by
+// systemd.
+func Notify(states ...string) (bool, error) {
+	return notify(os.Getenv("NOTIFY_SOCKET"), states...)
+}
+
+func notify(socket string, states ...string) (bool, error) {
+	if socket == "" {
+		return false, nil
+	}
+
+	// abstract namespace socket
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+
+	defer conn.Close()
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// New creates a notifier, and starts pinging the watchdog, when it is
+// enabled. It returns nil, when no socket was set, e.g. when the process
+// was not started by systemd.
+func New(o Options) *Notifier {
+	if o.Socket == "" {
+		o.Socket = os.Getenv("NOTIFY_SOCKET")
+	}
+
+	if o.Socket == "" {
+		return nil
+	}
+
+	if o.WatchdogTimeout == 0 {
+		o.WatchdogTimeout = WatchdogTimeout()
+	}
+
+	n := &Notifier{
+		socket: o.Socket,
+		check:  o.Check,
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if o.WatchdogTimeout > 0 {
+		log.Infof("systemd watchdog enabled, timeout: %v", o.WatchdogTimeout)
+		go n.watchdog(o.WatchdogTimeout / 2)
+	} else {
+		close(n.done)
+	}
+
+	return n
+}
+
+func (n *Notifier) notify(states ...string) {
+	if _, err := notify(n.socket, states...); err != nil {
+		log.Errorf("error while notifying systemd: %v", err)
+	}
+}
+
+func (n *Notifier) watchdog(interval time.Duration) {
+	defer close(n.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if n.check != nil {
+				if err := n.check(); err != nil {
+					log.Errorf("systemd watchdog check failed: %v", err)
+					continue
+				}
+			}
+
+			n.notify(Watchdog)
+		case <-n.quit:
+			return
+		}
+	}
+}
+
+// Ready notifies the service manager that the proxy started, or that it
+// finished reloading.
+func (n *Notifier) Ready() {
+	if n == nil {
+		return
+	}
+
+	n.notify(Ready, "STATUS=serving")
+}
+
+// Reloading notifies the service manager that the proxy started
+// reloading. It needs to be followed by Ready.
+func (n *Notifier) Reloading() {
+	if n == nil {
+		return
+	}
+
+	n.notify(Reloading, "STATUS=reloading")
+}
+
+// Stopping notifies the service manager that the proxy started shutting
+// down.
+func (n *Notifier) Stopping() {
+	if n == nil {
+		return
+	}
+
+	n.notify(Stopping, "STATUS=stopping")
+}
+
+// Close stops pinging the watchdog.
+func (n *Notifier) Close() {
+	if n == nil {
+		return
+	}
+
+	select {
+	case <-n.quit:
+	default:
+		close(n.quit)
+	}
+
+	<-n.done
+}
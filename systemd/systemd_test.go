@@ -0,0 +1,142 @@
+package systemd
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func listen(t *testing.T) (*net.UnixConn, string, func()) {
+	dir, err := ioutil.TempDir("", "skipper-systemd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return conn, socket, func() {
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func receive(t *testing.T, conn *net.UnixConn) string {
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	b := make([]byte, 1024)
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b[:n])
+}
+
+func TestNotSystemd(t *testing.T) {
+	os.Unsetenv("NOTIFY_SOCKET")
+	if sent, err := Notify(Ready); sent || err != nil {
+		t.Error("unexpected notification", sent, err)
+	}
+
+	n := New(Options{})
+	if n != nil {
+		t.Error("unexpected notifier")
+	}
+
+	// no-op
+	n.Ready()
+	n.Stopping()
+	n.Close()
+}
+
+func TestNotify(t *testing.T) {
+	conn, socket, cleanup := listen(t)
+	defer cleanup()
+
+	n := New(Options{Socket: socket, WatchdogTimeout: -1})
+	defer n.Close()
+
+	for _, test := range []struct {
+		notify   func()
+		expected string
+	}{
+		{n.Ready, "READY=1\nSTATUS=serving"},
+		{n.Reloading, "RELOADING=1\nSTATUS=reloading"},
+		{n.Stopping, "STOPPING=1\nSTATUS=stopping"},
+	} {
+		test.notify()
+		if s := receive(t, conn); s != test.expected {
+			t.Errorf("expected: %q, got: %q", test.expected, s)
+		}
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	conn, socket, cleanup := listen(t)
+	defer cleanup()
+
+	var failing int32
+	n := New(Options{
+		Socket:          socket,
+		WatchdogTimeout: 30 * time.Millisecond,
+		Check: func() error {
+			if atomic.LoadInt32(&failing) == 1 {
+				return errors.New("wedged")
+			}
+
+			return nil
+		},
+	})
+	defer n.Close()
+
+	if s := receive(t, conn); s != Watchdog {
+		t.Fatal("invalid ping", s)
+	}
+
+	atomic.StoreInt32(&failing, 1)
+
+	// a ping may have been sent before the check started failing
+	time.Sleep(30 * time.Millisecond)
+	for {
+		conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+		if _, err := conn.Read(make([]byte, 1024)); err != nil {
+			break
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(90 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 1024)); err == nil {
+		t.Error("unexpected ping while the check is failing")
+	}
+}
+
+func TestWatchdogTimeout(t *testing.T) {
+	defer os.Unsetenv("WATCHDOG_USEC")
+	defer os.Unsetenv("WATCHDOG_PID")
+
+	os.Setenv("WATCHDOG_USEC", "2000000")
+	os.Unsetenv("WATCHDOG_PID")
+	if d := WatchdogTimeout(); d != 2*time.Second {
+		t.Error("invalid timeout", d)
+	}
+
+	os.Setenv("WATCHDOG_PID", "1")
+	if d := WatchdogTimeout(); d != 0 {
+		t.Error("unexpected timeout for another process", d)
+	}
+
+	os.Unsetenv("WATCHDOG_PID")
+	os.Setenv("WATCHDOG_USEC", "foo")
+	if d := WatchdogTimeout(); d != 0 {
+		t.Error("unexpected timeout", d)
+	}
+}
@@ -0,0 +1,25 @@
+/*
+Package tap implements streaming the summary of the live requests to
+operators, giving a tcpdump-like view of the traffic without packet
+capture.
+
+The stream is served on the /tap path of the support (metrics)
+listener, as server-sent events, one JSON object per request,
+containing the method, the host, the path, the matched route, the
+response status, the duration and the selected request headers. The
+query of the requests is never streamed, and the values of the
+credential and cookie headers are redacted, even when they are
+selected.
+
+The endpoint requires the configured token, sent as a bearer token.
+The requests are sampled with the configured rate, and when a
+subscriber cannot keep up with the stream, the further events are
+dropped for it. Example:
+
+    skipper -enable-tap -tap-token secret -tap-sample-rate 0.1 -tap-headers User-Agent
+    curl -N -H 'Authorization: Bearer secret' localhost:9911/tap
+
+When there are no subscribers, the tap has no effect on the proxied
+requests.
+*/
+package tap
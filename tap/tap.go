@@ -0,0 +1,319 @@
+package tap
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/logging"
+)
+
+const (
+	// DefaultSampleRate is the default fraction of the requests
+	// streamed to the subscribers.
+	DefaultSampleRate = 1.0
+
+	// DefaultBufferSize is the default number of events buffered for
+	// a subscriber. When the subscriber cannot keep up, the further
+	// events are dropped.
+	DefaultBufferSize = 256
+
+	// DefaultHeartbeatInterval is the default interval of the SSE
+	// comments sent to keep the idle streams open.
+	DefaultHeartbeatInterval = 15 * time.Second
+
+	redacted = "[redacted]"
+)
+
+var errMissingToken = errors.New("tap: token required")
+
+// the values of these headers are never streamed
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// Options for creating a Tap instance.
+type Options struct {
+
+	// The bearer token that the subscribers need to send in the
+	// Authorization header. Required.
+	Token string
+
+	// The fraction of the requests streamed to the subscribers,
+	// between 0 and 1. Default: 1.
+	SampleRate float64
+
+	// The request headers included in the events. The values of
+	// the credential and cookie headers are redacted.
+	Headers []string
+
+	// The number of events buffered for a subscriber. Default: 256.
+	BufferSize int
+
+	// The interval of the heartbeat comments sent on the idle
+	// streams. Default: 15s.
+	HeartbeatInterval time.Duration
+}
+
+// Event is the redacted summary of a proxied request.
+type Event struct {
+	Time       time.Time         `json:"time"`
+	Method     string            `json:"method"`
+	Host       string            `json:"host"`
+	Path       string            `json:"path"`
+	RouteID    string            `json:"route_id,omitempty"`
+	FlowID     string            `json:"flow_id,omitempty"`
+	StatusCode int               `json:"status_code"`
+	DurationMs float64           `json:"duration_ms"`
+	Headers    map[string]string `json:"headers,omitempty"`
+}
+
+// Tap streams the summary of the live requests to the subscribers. It
+// implements http.Handler, serving the stream as server-sent events.
+type Tap struct {
+	token       []byte
+	sampleRate  float64
+	headers     []string
+	bufferSize  int
+	heartbeat   time.Duration
+	mx          sync.Mutex
+	subscribers map[chan *Event]struct{}
+	count       int32
+}
+
+type statusWriter struct {
+	writer http.ResponseWriter
+	code   int
+}
+
+type handler struct {
+	tap  *Tap
+	next http.Handler
+}
+
+// New creates a Tap instance.
+func New(o Options) (*Tap, error) {
+	if o.Token == "" {
+		return nil, errMissingToken
+	}
+
+	if o.SampleRate <= 0 || o.SampleRate > 1 {
+		o.SampleRate = DefaultSampleRate
+	}
+
+	if o.BufferSize <= 0 {
+		o.BufferSize = DefaultBufferSize
+	}
+
+	if o.HeartbeatInterval <= 0 {
+		o.HeartbeatInterval = DefaultHeartbeatInterval
+	}
+
+	return &Tap{
+		token:       []byte(o.Token),
+		sampleRate:  o.SampleRate,
+		headers:     o.Headers,
+		bufferSize:  o.BufferSize,
+		heartbeat:   o.HeartbeatInterval,
+		subscribers: make(map[chan *Event]struct{}),
+	}, nil
+}
+
+func (w *statusWriter) Header() http.Header { return w.writer.Header() }
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.code = code
+	w.writer.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	return w.writer.Write(p)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.writer.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+
+	return nil, nil, errors.New("could not hijack connection")
+}
+
+// Subscribers returns the number of the connected subscribers.
+func (t *Tap) Subscribers() int {
+	return int(atomic.LoadInt32(&t.count))
+}
+
+func (t *Tap) subscribe() chan *Event {
+	c := make(chan *Event, t.bufferSize)
+	t.mx.Lock()
+	t.subscribers[c] = struct{}{}
+	t.mx.Unlock()
+	atomic.AddInt32(&t.count, 1)
+	return c
+}
+
+func (t *Tap) unsubscribe(c chan *Event) {
+	t.mx.Lock()
+	delete(t.subscribers, c)
+	t.mx.Unlock()
+	atomic.AddInt32(&t.count, -1)
+}
+
+// never blocks the proxied request, the slow subscribers miss events
+func (t *Tap) publish(e *Event) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for c := range t.subscribers {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
+
+func (t *Tap) authorized(r *http.Request) bool {
+	const prefix = "Bearer "
+	a := r.Header.Get("Authorization")
+	if !strings.HasPrefix(a, prefix) {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(a, prefix)), t.token) == 1
+}
+
+func (t *Tap) selectHeaders(h http.Header) map[string]string {
+	if len(t.headers) == 0 {
+		return nil
+	}
+
+	selected := make(map[string]string)
+	for _, n := range t.headers {
+		v := h.Get(n)
+		if v == "" {
+			continue
+		}
+
+		if sensitiveHeaders[http.CanonicalHeaderKey(n)] {
+			v = redacted
+		}
+
+		selected[n] = v
+	}
+
+	return selected
+}
+
+// ServeHTTP streams the events as server-sent events, one JSON object
+// per event, until the client disconnects. The clients need to send
+// the configured token as a bearer token.
+func (t *Tap) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !t.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	c := t.subscribe()
+	defer t.unsubscribe(c)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+
+	heartbeat := time.NewTicker(t.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e := <-c:
+			b, err := json.Marshal(e)
+			if err != nil {
+				log.Error("error while encoding tap event", err)
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+
+		f.Flush()
+	}
+}
+
+// Wrap creates an http.Handler that publishes the summary of the
+// sampled requests of the wrapped handler to the subscribers. When
+// there are no subscribers, it only forwards the requests.
+func (t *Tap) Wrap(next http.Handler) http.Handler {
+	return &handler{tap: t, next: next}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t := h.tap
+	if t.Subscribers() == 0 || (t.sampleRate < 1 && rand.Float64() >= t.sampleRate) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	// the query is not streamed, because it may contain credentials
+	e := &Event{
+		Time:    time.Now(),
+		Method:  r.Method,
+		Host:    r.Host,
+		Path:    r.URL.Path,
+		Headers: t.selectHeaders(r.Header),
+	}
+
+	sw := &statusWriter{writer: w}
+	h.next.ServeHTTP(sw, r)
+
+	e.StatusCode = sw.code
+	e.DurationMs = float64(time.Since(e.Time)) / float64(time.Millisecond)
+	if d := logging.ProxyDetailsFromContext(r.Context()); d != nil {
+		e.RouteID = d.RouteID
+		e.FlowID = d.RequestID
+	}
+
+	t.publish(e)
+}
@@ -0,0 +1,128 @@
+package tap
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/logging"
+)
+
+func TestRequiresToken(t *testing.T) {
+	if _, err := New(Options{}); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	tp, err := New(Options{Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, auth := range []string{"", "secret", "Bearer wrong"} {
+		r := httptest.NewRequest("GET", "/tap", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+
+		w := httptest.NewRecorder()
+		tp.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Error("failed to reject", auth, w.Code)
+		}
+	}
+}
+
+func TestNoSubscribers(t *testing.T) {
+	tp, err := New(Options{Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var called bool
+	h := tp.Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if _, ok := w.(*statusWriter); ok {
+			t.Error("unexpected wrapping without subscribers")
+		}
+
+		called = true
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !called {
+		t.Error("request not forwarded")
+	}
+}
+
+func TestStream(t *testing.T) {
+	tp, err := New(Options{Token: "secret", Headers: []string{"User-Agent", "Authorization", "X-Missing"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	support := httptest.NewServer(tp)
+	defer support.Close()
+
+	proxy := tp.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.ProxyDetailsFromContext(r.Context()).RouteID = "testRoute"
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	req, _ := http.NewRequest("GET", support.URL, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK || rsp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatal("invalid response", rsp.StatusCode, rsp.Header.Get("Content-Type"))
+	}
+
+	for tp.Subscribers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	r := httptest.NewRequest("GET", "/foo?token=bar", nil)
+	r.Header.Set("User-Agent", "test-agent")
+	r.Header.Set("Authorization", "Basic foo")
+	r = r.WithContext(logging.ContextWithProxyDetails(r.Context(), &logging.ProxyDetails{}))
+	proxy.ServeHTTP(httptest.NewRecorder(), r)
+
+	events := make(chan *Event, 1)
+	go func() {
+		s := bufio.NewScanner(rsp.Body)
+		for s.Scan() {
+			if !strings.HasPrefix(s.Text(), "data: ") {
+				continue
+			}
+
+			var e Event
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(s.Text(), "data: ")), &e); err != nil {
+				t.Error(err)
+			}
+
+			events <- &e
+			return
+		}
+	}()
+
+	select {
+	case e := <-events:
+		if e.Path != "/foo" || e.RouteID != "testRoute" || e.StatusCode != http.StatusTeapot {
+			t.Error("invalid event", e.Path, e.RouteID, e.StatusCode)
+		}
+
+		if len(e.Headers) != 2 || e.Headers["User-Agent"] != "test-agent" || e.Headers["Authorization"] != redacted {
+			t.Error("invalid headers", e.Headers)
+		}
+	case <-time.After(time.Second):
+		t.Error("event not received")
+	}
+}
@@ -0,0 +1,14 @@
+//go:build boringcrypto
+// +build boringcrypto
+
+package tlsconfig
+
+import (
+	// restricts the TLS connections of the process to the FIPS
+	// approved settings, regardless of the configuration
+	_ "crypto/tls/fipsonly"
+)
+
+// BoringCrypto tells whether the binary was built with the BoringCrypto
+// module, e.g. with GOEXPERIMENT=boringcrypto.
+const BoringCrypto = true
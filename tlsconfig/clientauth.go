@@ -0,0 +1,84 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Client authentication modes.
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequired = "required"
+)
+
+var errMissingClientCA = errors.New("tlsconfig: client CA required for client certificate authentication")
+
+// ParseClientAuth parses a client authentication mode: none, optional
+// or required. In optional mode, the certificates sent by the clients
+// are verified, but the connections without a certificate are
+// accepted, too. An empty string means none.
+func ParseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(mode) {
+	case "", ClientAuthNone:
+		return tls.NoClientCert, nil
+	case ClientAuthOptional:
+		return tls.VerifyClientCertIfGiven, nil
+	case ClientAuthRequired:
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("invalid client authentication mode: %s", mode)
+	}
+}
+
+// LoadCertPool loads a certificate pool from PEM files, e.g. the CA
+// certificates that the client certificates are verified with.
+func LoadCertPool(files []string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, f := range files {
+		pem, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", f)
+		}
+	}
+
+	return pool, nil
+}
+
+// ClientCertificate returns the verified certificate of the client that
+// sent the request, or nil, when the client didn't send a certificate,
+// or it was not verified.
+func ClientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// ClientCertificateNames returns the names that a client certificate
+// identifies: the common name of the subject, and the DNS, email and
+// URI subject alternative names, e.g. a SPIFFE ID.
+func ClientCertificateNames(c *x509.Certificate) []string {
+	var names []string
+	if c.Subject.CommonName != "" {
+		names = append(names, c.Subject.CommonName)
+	}
+
+	names = append(names, c.DNSNames...)
+	names = append(names, c.EmailAddresses...)
+	for _, u := range c.URIs {
+		names = append(names, u.String())
+	}
+
+	return names
+}
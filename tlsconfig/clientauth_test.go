@@ -0,0 +1,147 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseClientAuth(t *testing.T) {
+	for mode, expected := range map[string]tls.ClientAuthType{
+		"":         tls.NoClientCert,
+		"none":     tls.NoClientCert,
+		"optional": tls.VerifyClientCertIfGiven,
+		"Required": tls.RequireAndVerifyClientCert,
+	} {
+		if a, err := ParseClientAuth(mode); err != nil || a != expected {
+			t.Error("failed to parse client auth mode", mode, a, err)
+		}
+	}
+
+	if _, err := ParseClientAuth("always"); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skipper-tlsconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	server := createKeyPair(t, dir, "www.example.org")
+	client := createKeyPair(t, dir, "orders.example.org", "orders.internal")
+	unknown := createKeyPair(t, dir, "unknown.example.org")
+
+	if _, err := New(Options{
+		KeyPairs:       []KeyPair{server},
+		ReloadInterval: -1,
+		ClientAuth:     tls.RequireAndVerifyClientCert,
+	}); err != errMissingClientCA {
+		t.Error("failed to fail without client CA", err)
+	}
+
+	if _, err := LoadCertPool([]string{server.KeyFile}); err == nil {
+		t.Error("failed to fail with an invalid CA file")
+	}
+
+	clientCAs, err := LoadCertPool([]string{client.CertFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientCert, err := tls.LoadX509KeyPair(client.CertFile, client.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unknownCert, err := tls.LoadX509KeyPair(unknown.CertFile, unknown.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		title      string
+		clientAuth tls.ClientAuthType
+		clientCert *tls.Certificate
+		fail       bool
+		names      []string
+	}{{
+		title:      "required, without certificate",
+		clientAuth: tls.RequireAndVerifyClientCert,
+		fail:       true,
+	}, {
+		title:      "required, unknown certificate",
+		clientAuth: tls.RequireAndVerifyClientCert,
+		clientCert: &unknownCert,
+		fail:       true,
+	}, {
+		title:      "required, verified certificate",
+		clientAuth: tls.RequireAndVerifyClientCert,
+		clientCert: &clientCert,
+		names:      []string{"orders.example.org", "orders.internal"},
+	}, {
+		title:      "optional, without certificate",
+		clientAuth: tls.VerifyClientCertIfGiven,
+	}, {
+		title:      "optional, verified certificate",
+		clientAuth: tls.VerifyClientCertIfGiven,
+		clientCert: &clientCert,
+		names:      []string{"orders.example.org", "orders.internal"},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			s, err := New(Options{
+				KeyPairs:       []KeyPair{server},
+				ReloadInterval: -1,
+				ClientAuth:     test.clientAuth,
+				ClientCAs:      clientCAs,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer s.Close()
+
+			var names []string
+			ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if c := ClientCertificate(r); c != nil {
+					names = ClientCertificateNames(c)
+				}
+			}))
+			ts.TLS = s.Config
+			ts.StartTLS()
+			defer ts.Close()
+
+			clientConfig := &tls.Config{InsecureSkipVerify: true}
+			if test.clientCert != nil {
+				clientConfig.Certificates = []tls.Certificate{*test.clientCert}
+			}
+
+			c := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+			rsp, err := c.Get(ts.URL)
+			if test.fail {
+				if err == nil {
+					rsp.Body.Close()
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rsp.Body.Close()
+			if !reflect.DeepEqual(names, test.names) {
+				t.Errorf("invalid client certificate names, got: %v, expected: %v", names, test.names)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Package tlsconfig creates the TLS configuration of the proxy listener.
+
+When a certificate and a key file are configured, the proxy listener
+terminates TLS, and offers HTTP/2 to the clients with ALPN, besides
+HTTP/1.1:
+
+    skipper -tls-cert /etc/skipper/tls.crt -tls-key /etc/skipper/tls.key
+
+The certificate file needs to contain the intermediate certificates,
+too. HTTP/2 can be disabled with the -disable-http2 flag.
+
+Multiple certificates can be served by the same listener, selected by
+the server name that the clients send with SNI. The certificate and key
+files are passed as comma separated lists, in the same order:
+
+    skipper -tls-cert example.org.crt,wildcard.example.com.crt -tls-key example.org.key,wildcard.example.com.key
+
+The certificates are selected by their subject alternative names, or by
+their common name, when they don't have any. The exact names take
+precedence over the wildcard names, and a wildcard name, e.g.
+*.example.com, matches a single label. When no certificate matches the
+server name, or the client doesn't send one, the first certificate is
+served.
+
+The certificates can be loaded from a directory, too, where the
+certificate and the key files are named as <name>.crt and <name>.key,
+e.g. a mounted Kubernetes secret of the type kubernetes.io/tls:
+
+    skipper -tls-cert-dir /etc/skipper/certs
+
+The certificates can be loaded from Kubernetes secrets of the type
+kubernetes.io/tls, too, accessed with the same settings as the
+Kubernetes ingress data client. The service account of skipper needs to
+be allowed to get the secrets:
+
+    skipper -kubernetes-in-cluster -tls-kubernetes-secrets default/example-org,default/example-com
+
+Or a certificate can be issued by the PKI secrets engine of Vault, and
+renewed before it expires, see the vault package. The certificates from
+Kubernetes and Vault are served after the ones loaded from the files.
+
+The certificate files, the directory, the Kubernetes secrets and the
+Vault certificate are checked for changes every minute, and the
+certificates are reloaded, without restarting the proxy or interrupting
+the open connections. When the reload fails, e.g. because the
+certificate was already replaced but the key not yet, the previous
+certificates are served, and the reload is retried in the next round. The interval can be set with the -tls-reload-interval flag. After
+a successful reload, a certificate_reloaded event is published.
+
+The TLS versions, the cipher suites and the elliptic curves accepted by
+the listener can be restricted, e.g. to satisfy a compliance policy:
+
+    skipper -tls-min-version 1.2 -tls-cipher-suites TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384 -tls-curves X25519,P256
+
+The cipher suites are named as in the crypto/tls package. The cipher
+suites of TLS 1.3 are not configurable. The same restrictions can be
+applied to the connections to the backends, with the -upstream-tls-*
+flags, e.g. -upstream-tls-min-version 1.2.
+
+With the -enable-ocsp-stapling flag, the proxy fetches the OCSP
+responses of the certificates from the responders of their issuers, and
+staples them to the handshakes, so that the clients don't need to
+check the revocation status themselves. The certificate files need to
+contain the issuer certificates. The responses are refreshed in the
+background, half-way through their validity period, checked every 10
+minutes by default, which can be changed with the -ocsp-check-interval
+flag. When refreshing a response fails, the previous one is stapled
+until it expires.
+
+For the regulated deployments, the -fips flag restricts the TLS
+connections of the listener and of the backends to the algorithms
+approved by FIPS 140-2: TLS 1.2 and 1.3, the ECDHE key exchange with the
+P-256 and P-384 curves, and the AES-GCM cipher suites. The -tls-* and the
+-upstream-tls-* flags can restrict these settings further, but the proxy
+fails to start, when they allow an algorithm that is not approved, or
+when the TLS verification of the backends, etcd or Innkeeper is
+disabled. The served certificates need to have an RSA key of at least
+2048 bits, or an ECDSA key with the P-256 or P-384 curve, and signatures
+using SHA-2, otherwise loading them fails:
+
+    skipper -fips -tls-cert tls.crt -tls-key tls.key
+
+The flag restricts only the configuration, while the cryptographic
+module of the standard Go build is not FIPS validated. For a validated
+module, skipper needs to be built with BoringCrypto, e.g. with make
+skipper-fips, which sets GOEXPERIMENT=boringcrypto. These builds enforce
+the approved TLS settings for all the connections of the process,
+regardless of the -fips flag, and the flag logs whether the BoringCrypto
+module is used.
+
+The listener can verify the certificates of the clients, with the CA
+certificates set with the -tls-client-ca flag. In required mode, the
+connections without a valid client certificate are rejected during the
+handshake. In optional mode, the connections without a certificate are
+accepted, too, but the certificates sent by the clients are verified:
+
+    skipper -tls-cert tls.crt -tls-key tls.key -tls-client-auth required -tls-client-ca clients-ca.crt
+
+The verified client certificate can be used in the routes, with the
+ClientCert predicate, and the clientCertAuth and clientCertHeaders
+filters. In required mode, the ACME TLS-ALPN-01 challenges can't be
+answered, and the HTTP-01 challenges need to be used instead.
+
+Optionally, skipper can accept the plaintext requests on an additional
+listener, and redirect them to the TLS listener, with a 308 Permanent
+Redirect:
+
+    skipper -address :443 -tls-cert tls.crt -tls-key tls.key -tls-redirect-address :80
+*/
+package tlsconfig
@@ -0,0 +1,174 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+)
+
+const minFIPSRSABits = 2048
+
+// the cipher suites approved by FIPS 140-2, in the order of preference
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// the curves approved by FIPS 140-2, in the order of preference
+var fipsCurves = []tls.CurveID{
+	tls.CurveP256,
+	tls.CurveP384,
+}
+
+var fipsSignatureAlgorithms = map[x509.SignatureAlgorithm]bool{
+	x509.SHA256WithRSA:    true,
+	x509.SHA384WithRSA:    true,
+	x509.SHA512WithRSA:    true,
+	x509.SHA256WithRSAPSS: true,
+	x509.SHA384WithRSAPSS: true,
+	x509.SHA512WithRSAPSS: true,
+	x509.ECDSAWithSHA256:  true,
+	x509.ECDSAWithSHA384:  true,
+	x509.ECDSAWithSHA512:  true,
+}
+
+// FIPSPolicy returns the policy allowing only the algorithms approved by
+// FIPS 140-2: TLS 1.2 and 1.3, ECDHE key exchange with the P-256 and
+// P-384 curves, and AES-GCM.
+func FIPSPolicy() Policy {
+	return Policy{
+		MinVersion:       tls.VersionTLS12,
+		MaxVersion:       tls.VersionTLS13,
+		CipherSuites:     append([]uint16(nil), fipsCipherSuites...),
+		CurvePreferences: append([]tls.CurveID(nil), fipsCurves...),
+	}
+}
+
+func containsSuite(s []uint16, id uint16) bool {
+	for _, si := range s {
+		if si == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsCurve(c []tls.CurveID, id tls.CurveID) bool {
+	for _, ci := range c {
+		if ci == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func cipherSuiteName(id uint16) string {
+	for _, cs := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if cs.ID == id {
+			return cs.Name
+		}
+	}
+
+	return fmt.Sprintf("0x%04x", id)
+}
+
+// RestrictFIPS checks that a policy allows only the algorithms approved
+// by FIPS 140-2, and returns it, with the settings left empty taken from
+// FIPSPolicy. It fails, when the policy allows an algorithm or a TLS
+// version that is not approved.
+func RestrictFIPS(p Policy) (Policy, error) {
+	f := FIPSPolicy()
+	if p.MinVersion != 0 && p.MinVersion < f.MinVersion {
+		return Policy{}, fmt.Errorf("TLS version not allowed in FIPS mode: %#x", p.MinVersion)
+	}
+
+	if p.MaxVersion != 0 && p.MaxVersion < f.MinVersion {
+		return Policy{}, fmt.Errorf("TLS version not allowed in FIPS mode: %#x", p.MaxVersion)
+	}
+
+	for _, cs := range p.CipherSuites {
+		if !containsSuite(fipsCipherSuites, cs) {
+			return Policy{}, fmt.Errorf("TLS cipher suite not allowed in FIPS mode: %s", cipherSuiteName(cs))
+		}
+	}
+
+	for _, c := range p.CurvePreferences {
+		if !containsCurve(fipsCurves, c) {
+			return Policy{}, fmt.Errorf("TLS curve not allowed in FIPS mode: %v", c)
+		}
+	}
+
+	if p.MinVersion == 0 {
+		p.MinVersion = f.MinVersion
+	}
+
+	if p.MaxVersion == 0 {
+		p.MaxVersion = f.MaxVersion
+	}
+
+	if len(p.CipherSuites) == 0 {
+		p.CipherSuites = f.CipherSuites
+	}
+
+	if len(p.CurvePreferences) == 0 {
+		p.CurvePreferences = f.CurvePreferences
+	}
+
+	return p, nil
+}
+
+// CheckFIPSCertificate checks that the key of a certificate is approved
+// by FIPS 140-2, RSA of at least 2048 bits, or ECDSA with the P-256 or
+// P-384 curve, and that the certificates of the chain are signed with an
+// approved algorithm.
+func CheckFIPSCertificate(c *tls.Certificate) error {
+	if len(c.Certificate) == 0 {
+		return errNoCertificate
+	}
+
+	leaf := c.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(c.Certificate[0]); err != nil {
+			return err
+		}
+	}
+
+	switch k := leaf.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minFIPSRSABits {
+			return fmt.Errorf("RSA key size not allowed in FIPS mode: %d, %s", k.N.BitLen(), leaf.Subject)
+		}
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() && k.Curve != elliptic.P384() {
+			return fmt.Errorf("ECDSA curve not allowed in FIPS mode: %s, %s", k.Curve.Params().Name, leaf.Subject)
+		}
+	default:
+		return fmt.Errorf("key type not allowed in FIPS mode: %T, %s", leaf.PublicKey, leaf.Subject)
+	}
+
+	for _, raw := range c.Certificate {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+
+		// the signature of the self-signed roots is not verified
+		if cert.CheckSignatureFrom(cert) == nil {
+			continue
+		}
+
+		if !fipsSignatureAlgorithms[cert.SignatureAlgorithm] {
+			return fmt.Errorf("signature algorithm not allowed in FIPS mode: %v, %s", cert.SignatureAlgorithm, cert.Subject)
+		}
+	}
+
+	return nil
+}
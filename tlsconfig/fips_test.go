@@ -0,0 +1,193 @@
+package tlsconfig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestRestrictFIPS(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		policy   Policy
+		expected Policy
+		fail     bool
+	}{{
+		title:    "defaults",
+		expected: FIPSPolicy(),
+	}, {
+		title: "restricted further",
+		policy: Policy{
+			MinVersion:       tls.VersionTLS13,
+			CurvePreferences: []tls.CurveID{tls.CurveP384},
+		},
+		expected: Policy{
+			MinVersion:       tls.VersionTLS13,
+			MaxVersion:       tls.VersionTLS13,
+			CipherSuites:     FIPSPolicy().CipherSuites,
+			CurvePreferences: []tls.CurveID{tls.CurveP384},
+		},
+	}, {
+		title:  "old version",
+		policy: Policy{MinVersion: tls.VersionTLS10},
+		fail:   true,
+	}, {
+		title:  "old max version",
+		policy: Policy{MaxVersion: tls.VersionTLS11},
+		fail:   true,
+	}, {
+		title:  "cipher suite not approved",
+		policy: Policy{CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305}},
+		fail:   true,
+	}, {
+		title:  "curve not approved",
+		policy: Policy{CurvePreferences: []tls.CurveID{tls.X25519}},
+		fail:   true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			p, err := RestrictFIPS(test.policy)
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if p.MinVersion != test.expected.MinVersion || p.MaxVersion != test.expected.MaxVersion {
+				t.Error("invalid versions", p.MinVersion, p.MaxVersion)
+			}
+
+			if len(p.CipherSuites) != len(test.expected.CipherSuites) {
+				t.Fatal("invalid cipher suites", p.CipherSuites)
+			}
+
+			for i, cs := range test.expected.CipherSuites {
+				if p.CipherSuites[i] != cs {
+					t.Error("invalid cipher suites", p.CipherSuites)
+				}
+			}
+
+			if len(p.CurvePreferences) != len(test.expected.CurvePreferences) {
+				t.Fatal("invalid curves", p.CurvePreferences)
+			}
+
+			for i, c := range test.expected.CurvePreferences {
+				if p.CurvePreferences[i] != c {
+					t.Error("invalid curves", p.CurvePreferences)
+				}
+			}
+		})
+	}
+}
+
+func createCertificate(t *testing.T, key crypto.Signer, alg x509.SignatureAlgorithm) *tls.Certificate {
+	template := &x509.Certificate{
+		SerialNumber:       big.NewInt(time.Now().UnixNano()),
+		Subject:            pkix.Name{CommonName: "www.example.org"},
+		NotBefore:          time.Now().Add(-time.Hour),
+		NotAfter:           time.Now().Add(time.Hour),
+		SignatureAlgorithm: alg,
+	}
+
+	// signed by a separate issuer, to check the signature algorithm
+	issuerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "issuer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestCheckFIPSCertificate(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsa2048, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsa1024, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		title string
+		cert  *tls.Certificate
+		fail  bool
+	}{{
+		title: "ECDSA P-256",
+		cert:  createCertificate(t, p256, x509.ECDSAWithSHA256),
+	}, {
+		title: "RSA 2048",
+		cert:  createCertificate(t, rsa2048, x509.ECDSAWithSHA384),
+	}, {
+		title: "ECDSA P-521",
+		cert:  createCertificate(t, p521, x509.ECDSAWithSHA256),
+		fail:  true,
+	}, {
+		title: "RSA 1024",
+		cert:  createCertificate(t, rsa1024, x509.ECDSAWithSHA256),
+		fail:  true,
+	}, {
+		title: "SHA-1 signature",
+		cert:  createCertificate(t, p256, x509.ECDSAWithSHA1),
+		fail:  true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			err := CheckFIPSCertificate(test.cert)
+			if test.fail && err == nil {
+				t.Error("failed to fail")
+			} else if !test.fail && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestNewFIPS(t *testing.T) {
+	s, err := New(Options{
+		KeyPairs: []KeyPair{{CertFile: "../fixtures/test.crt", KeyFile: "../fixtures/test.key"}},
+		FIPS:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Close()
+}
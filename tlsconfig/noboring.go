@@ -0,0 +1,8 @@
+//go:build !boringcrypto
+// +build !boringcrypto
+
+package tlsconfig
+
+// BoringCrypto tells whether the binary was built with the BoringCrypto
+// module, e.g. with GOEXPERIMENT=boringcrypto.
+const BoringCrypto = false
@@ -0,0 +1,167 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// DefaultOCSPCheckInterval is the default interval of checking
+	// whether the stapled OCSP responses need to be refreshed.
+	DefaultOCSPCheckInterval = 10 * time.Minute
+
+	ocspTimeout         = 10 * time.Second
+	maxOCSPResponseSize = 1 << 20
+)
+
+var errNoIssuer = errors.New("tlsconfig: issuer certificate missing from the chain")
+
+type staple struct {
+	response   []byte
+	refreshAt  time.Time
+	nextUpdate time.Time
+}
+
+// stapler keeps the OCSP responses of the served certificates, and
+// refreshes them half-way through their validity period. It is used
+// only from the background goroutine of the Server, and during the
+// initialization.
+type stapler struct {
+	client  *http.Client
+	staples map[[sha256.Size]byte]*staple
+}
+
+func newStapler() *stapler {
+	return &stapler{
+		client:  &http.Client{Timeout: ocspTimeout},
+		staples: make(map[[sha256.Size]byte]*staple),
+	}
+}
+
+func fetchOCSP(client *http.Client, c *tls.Certificate) ([]byte, *ocsp.Response, error) {
+	if len(c.Certificate) < 2 {
+		return nil, nil, errNoIssuer
+	}
+
+	issuer, err := x509.ParseCertificate(c.Certificate[1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := ocsp.CreateRequest(c.Leaf, issuer, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rsp, err := client.Post(c.Leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("OCSP responder returned: %d", rsp.StatusCode)
+	}
+
+	der, err := ioutil.ReadAll(io.LimitReader(rsp.Body, maxOCSPResponseSize))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := ocsp.ParseResponseForCert(der, c.Leaf, issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return der, r, nil
+}
+
+// the responses are refreshed half-way between their this and next
+// update times, or in every round, when they don't have a next update
+func refreshTime(r *ocsp.Response) time.Time {
+	if r.NextUpdate.IsZero() {
+		return r.ThisUpdate
+	}
+
+	return r.ThisUpdate.Add(r.NextUpdate.Sub(r.ThisUpdate) / 2)
+}
+
+func (st *stapler) refresh(c *tls.Certificate, now time.Time) {
+	if len(c.Leaf.OCSPServer) == 0 {
+		return
+	}
+
+	key := sha256.Sum256(c.Leaf.Raw)
+	if s, ok := st.staples[key]; ok && now.Before(s.refreshAt) {
+		return
+	}
+
+	der, r, err := fetchOCSP(st.client, c)
+	if err != nil {
+		log.Errorf("error while fetching the OCSP response of %s: %v", c.Leaf.Subject.CommonName, err)
+
+		// the previous response is served until it expires
+		if s, ok := st.staples[key]; ok && !s.nextUpdate.IsZero() && now.After(s.nextUpdate) {
+			delete(st.staples, key)
+		}
+
+		return
+	}
+
+	if r.Status == ocsp.Revoked {
+		log.Errorf("certificate revoked: %s, at: %v", c.Leaf.Subject.CommonName, r.RevokedAt)
+	}
+
+	st.staples[key] = &staple{
+		response:   der,
+		refreshAt:  refreshTime(r),
+		nextUpdate: r.NextUpdate,
+	}
+}
+
+// refreshes the expiring responses, and returns a store serving the
+// certificates with the stapled responses
+func (st *stapler) staple(store *Store, now time.Time) *Store {
+	current := make(map[[sha256.Size]byte]bool)
+	for _, c := range store.certs {
+		current[sha256.Sum256(c.Leaf.Raw)] = true
+		st.refresh(c, now)
+	}
+
+	// drop the responses of the certificates not served anymore
+	for key := range st.staples {
+		if !current[key] {
+			delete(st.staples, key)
+		}
+	}
+
+	return st.apply(store)
+}
+
+// returns a store with copies of the certificates, that have the
+// current responses stapled
+func (st *stapler) apply(store *Store) *Store {
+	var certs []*tls.Certificate
+	for _, c := range store.certs {
+		cc := *c
+		cc.OCSPStaple = nil
+		if s, ok := st.staples[sha256.Sum256(c.Leaf.Raw)]; ok {
+			cc.OCSPStaple = s.response
+		}
+
+		certs = append(certs, &cc)
+	}
+
+	return newStore(certs)
+}
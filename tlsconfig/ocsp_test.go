@@ -0,0 +1,240 @@
+package tlsconfig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+type testResponder struct {
+	t          *testing.T
+	issuer     *x509.Certificate
+	key        crypto.Signer
+	mx         sync.Mutex
+	validFor   time.Duration
+	requests   int
+	statusCode int
+}
+
+func (r *testResponder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.requests++
+	if r.statusCode != 0 {
+		w.WriteHeader(r.statusCode)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	ocspReq, err := ocsp.ParseRequest(body)
+	if err != nil {
+		r.t.Error(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	rsp, err := ocsp.CreateResponse(r.issuer, r.issuer, ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: ocspReq.SerialNumber,
+		ThisUpdate:   now.Add(-time.Hour),
+		NextUpdate:   now.Add(-time.Hour).Add(r.validFor),
+	}, r.key)
+	if err != nil {
+		r.t.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(rsp)
+}
+
+func (r *testResponder) requestCount() int {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.requests
+}
+
+// creates a certificate signed by a CA, pointing to an OCSP responder,
+// with the chain in the certificate file
+func createSignedKeyPair(t *testing.T, dir, ocspURL string) (KeyPair, *testResponder) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "www.example.org"},
+		DNSNames:     []string{"www.example.org"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		OCSPServer:   []string{ocspURL},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := KeyPair{
+		CertFile: filepath.Join(dir, "signed.crt"),
+		KeyFile:  filepath.Join(dir, "signed.key"),
+	}
+
+	chain := append(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})...,
+	)
+
+	if err := ioutil.WriteFile(p.CertFile, chain, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(p.KeyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return p, &testResponder{t: t, issuer: ca, key: caKey}
+}
+
+func TestOCSPStapling(t *testing.T) {
+	for _, test := range []struct {
+		title      string
+		validFor   time.Duration
+		statusCode int
+		stapled    bool
+		refreshed  bool
+	}{{
+		title:    "valid response",
+		validFor: 24 * time.Hour,
+		stapled:  true,
+	}, {
+		title:     "refreshed half-way through the validity",
+		validFor:  90 * time.Minute,
+		stapled:   true,
+		refreshed: true,
+	}, {
+		title:      "responder failure",
+		statusCode: http.StatusInternalServerError,
+		refreshed:  true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "skipper-tlsconfig")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer os.RemoveAll(dir)
+
+			var responder *testResponder
+			rs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				responder.ServeHTTP(w, r)
+			}))
+			defer rs.Close()
+
+			var pair KeyPair
+			pair, responder = createSignedKeyPair(t, dir, rs.URL)
+			responder.validFor = test.validFor
+			responder.statusCode = test.statusCode
+
+			s, err := New(Options{
+				KeyPairs:          []KeyPair{pair},
+				ReloadInterval:    -1,
+				OCSPStapling:      true,
+				OCSPCheckInterval: 10 * time.Millisecond,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer s.Close()
+
+			// wait for a few rounds
+			time.Sleep(80 * time.Millisecond)
+
+			c, err := s.GetCertificate(&tls.ClientHelloInfo{ServerName: "www.example.org"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if stapled := len(c.OCSPStaple) > 0; stapled != test.stapled {
+				t.Errorf("invalid stapling, got: %v, expected: %v", stapled, test.stapled)
+			}
+
+			if c.OCSPStaple != nil {
+				if _, err := ocsp.ParseResponse(c.OCSPStaple, responder.issuer); err != nil {
+					t.Error(err)
+				}
+			}
+
+			if refreshed := responder.requestCount() > 1; refreshed != test.refreshed {
+				t.Errorf("invalid refresh, got: %v, expected: %v", refreshed, test.refreshed)
+			}
+		})
+	}
+}
+
+func TestOCSPStaplingWithoutResponder(t *testing.T) {
+	s, err := New(Options{
+		KeyPairs:       []KeyPair{{CertFile: "../fixtures/test.crt", KeyFile: "../fixtures/test.key"}},
+		ReloadInterval: -1,
+		OCSPStapling:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+	c, err := s.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil || c.OCSPStaple != nil {
+		t.Error("unexpected OCSP staple", err)
+	}
+}
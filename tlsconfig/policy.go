@@ -0,0 +1,161 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// Policy restricts the protocol versions, the cipher suites and the
+// elliptic curves of the TLS connections. The zero value keeps the
+// defaults of the Go TLS implementation.
+type Policy struct {
+
+	// The minimum accepted TLS version, e.g. tls.VersionTLS12.
+	MinVersion uint16
+
+	// The maximum accepted TLS version.
+	MaxVersion uint16
+
+	// The enabled cipher suites, in the order of preference. The
+	// cipher suites of TLS 1.3 are not configurable.
+	CipherSuites []uint16
+
+	// The elliptic curves used in the ECDHE key exchange, in the order
+	// of preference.
+	CurvePreferences []tls.CurveID
+}
+
+var versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var curves = map[string]tls.CurveID{
+	"X25519": tls.X25519,
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+}
+
+// ParseVersion parses a TLS version, one of 1.0, 1.1, 1.2 or 1.3. The
+// TLS prefix is optional, e.g. TLS1.2. An empty string returns 0,
+// meaning the default.
+func ParseVersion(s string) (uint16, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	v, ok := versions[strings.TrimPrefix(strings.ToUpper(s), "TLS")]
+	if !ok {
+		return 0, fmt.Errorf("invalid TLS version: %s", s)
+	}
+
+	return v, nil
+}
+
+// ParseCipherSuites parses cipher suite names, as defined in the Go
+// crypto/tls package, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	byName := make(map[string]uint16)
+	for _, cs := range tls.CipherSuites() {
+		byName[cs.Name] = cs.ID
+	}
+
+	for _, cs := range tls.InsecureCipherSuites() {
+		byName[cs.Name] = cs.ID
+	}
+
+	var ids []uint16
+	for _, n := range names {
+		id, ok := byName[strings.ToUpper(n)]
+		if !ok {
+			return nil, fmt.Errorf("invalid TLS cipher suite: %s", n)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// ParseCurves parses elliptic curve names, one of X25519, P256, P384
+// and P521.
+func ParseCurves(names []string) ([]tls.CurveID, error) {
+	var ids []tls.CurveID
+	for _, n := range names {
+		id, ok := curves[strings.TrimPrefix(strings.ToUpper(n), "CURVE")]
+		if !ok {
+			return nil, fmt.Errorf("invalid TLS curve: %s", n)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// ParsePolicy parses a policy from the version, cipher suite and curve
+// names.
+func ParsePolicy(minVersion, maxVersion string, cipherSuites, curves []string) (Policy, error) {
+	var (
+		p   Policy
+		err error
+	)
+
+	if p.MinVersion, err = ParseVersion(minVersion); err != nil {
+		return Policy{}, err
+	}
+
+	if p.MaxVersion, err = ParseVersion(maxVersion); err != nil {
+		return Policy{}, err
+	}
+
+	if p.MinVersion != 0 && p.MaxVersion != 0 && p.MinVersion > p.MaxVersion {
+		return Policy{}, fmt.Errorf("the minimum TLS version is greater than the maximum: %s, %s", minVersion, maxVersion)
+	}
+
+	if p.CipherSuites, err = ParseCipherSuites(cipherSuites); err != nil {
+		return Policy{}, err
+	}
+
+	if p.CurvePreferences, err = ParseCurves(curves); err != nil {
+		return Policy{}, err
+	}
+
+	return p, nil
+}
+
+// Apply sets the restrictions of the policy in a TLS configuration.
+func (p Policy) Apply(c *tls.Config) {
+	if p.MinVersion != 0 {
+		c.MinVersion = p.MinVersion
+	}
+
+	if p.MaxVersion != 0 {
+		c.MaxVersion = p.MaxVersion
+	}
+
+	if len(p.CipherSuites) > 0 {
+		c.CipherSuites = p.CipherSuites
+	}
+
+	if len(p.CurvePreferences) > 0 {
+		c.CurvePreferences = p.CurvePreferences
+	}
+}
+
+// ClientConfig returns the TLS configuration of the connections to the
+// backends restricted by the policy, or nil, when the policy is the
+// zero value.
+func (p Policy) ClientConfig() *tls.Config {
+	if p.MinVersion == 0 && p.MaxVersion == 0 && len(p.CipherSuites) == 0 && len(p.CurvePreferences) == 0 {
+		return nil
+	}
+
+	c := &tls.Config{}
+	p.Apply(c)
+	return c
+}
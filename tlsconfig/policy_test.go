@@ -0,0 +1,106 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	for _, test := range []struct {
+		title        string
+		minVersion   string
+		maxVersion   string
+		cipherSuites []string
+		curves       []string
+		expected     Policy
+		fail         bool
+	}{{
+		title: "defaults",
+	}, {
+		title:      "versions",
+		minVersion: "1.2",
+		maxVersion: "TLS1.3",
+		expected:   Policy{MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS13},
+	}, {
+		title:      "invalid version",
+		minVersion: "1.4",
+		fail:       true,
+	}, {
+		title:      "min greater than max",
+		minVersion: "1.3",
+		maxVersion: "1.2",
+		fail:       true,
+	}, {
+		title: "cipher suites",
+		cipherSuites: []string{
+			"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+			"tls_ecdhe_ecdsa_with_aes_128_gcm_sha256",
+		},
+		expected: Policy{CipherSuites: []uint16{
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		}},
+	}, {
+		title:        "invalid cipher suite",
+		cipherSuites: []string{"TLS_FOO"},
+		fail:         true,
+	}, {
+		title:    "curves",
+		curves:   []string{"X25519", "CurveP256"},
+		expected: Policy{CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256}},
+	}, {
+		title:  "invalid curve",
+		curves: []string{"P128"},
+		fail:   true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			p, err := ParsePolicy(test.minVersion, test.maxVersion, test.cipherSuites, test.curves)
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(p, test.expected) {
+				t.Errorf("invalid policy, got: %v, expected: %v", p, test.expected)
+			}
+		})
+	}
+}
+
+func TestApplyPolicy(t *testing.T) {
+	if (Policy{}).ClientConfig() != nil {
+		t.Error("unexpected client config for the default policy")
+	}
+
+	p := Policy{
+		MinVersion:       tls.VersionTLS12,
+		CipherSuites:     []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		CurvePreferences: []tls.CurveID{tls.CurveP384},
+	}
+
+	s, err := New(Options{
+		KeyPairs:       []KeyPair{{CertFile: "../fixtures/test.crt", KeyFile: "../fixtures/test.key"}},
+		ReloadInterval: -1,
+		Policy:         p,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+	for _, c := range []*tls.Config{s.Config, p.ClientConfig()} {
+		if c.MinVersion != tls.VersionTLS12 || c.MaxVersion != 0 ||
+			!reflect.DeepEqual(c.CipherSuites, p.CipherSuites) ||
+			!reflect.DeepEqual(c.CurvePreferences, p.CurvePreferences) {
+			t.Error("policy not applied", c.MinVersion, c.MaxVersion, c.CipherSuites, c.CurvePreferences)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package tlsconfig
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// PEMKeyPair is a certificate and its private key in PEM format. The
+// certificate needs to contain the intermediate certificates, too.
+type PEMKeyPair struct {
+
+	// Identifies the key pair in the logs and in the events, e.g.
+	// the name of a Kubernetes secret.
+	Name string
+
+	Cert []byte
+	Key  []byte
+}
+
+// Provider provides certificates from a source other than the local
+// files, e.g. Kubernetes secrets or Vault. The providers are called
+// initially, and then in every reload round, and when the returned
+// key pairs change, the served certificates are reloaded.
+// Implementations should cache the key pairs, when obtaining them is
+// expensive.
+type Provider interface {
+	KeyPairs() ([]PEMKeyPair, error)
+}
+
+// the current version of the certificates and the keys, from the files
+// and the providers
+type material struct {
+	pairs []KeyPair
+	pems  []PEMKeyPair
+}
+
+func (s *Server) material() (*material, error) {
+	pairs, err := s.keyPairs()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &material{pairs: pairs}
+	for _, p := range s.providers {
+		pems, err := p.KeyPairs()
+		if err != nil {
+			return nil, err
+		}
+
+		m.pems = append(m.pems, pems...)
+	}
+
+	return m, nil
+}
+
+func (m *material) empty() bool {
+	return len(m.pairs) == 0 && len(m.pems) == 0
+}
+
+// identifies the files by their paths, sizes and modification times,
+// and the key pairs of the providers by their content
+func (m *material) fingerprint() string {
+	f := []string{fingerprint(m.pairs)}
+	for _, p := range m.pems {
+		h := sha256.New()
+		h.Write(p.Cert)
+		h.Write(p.Key)
+		f = append(f, fmt.Sprintf("%s:%x", p.Name, h.Sum(nil)))
+	}
+
+	return strings.Join(f, ";")
+}
+
+func (m *material) names() []string {
+	var n []string
+	for _, p := range m.pairs {
+		n = append(n, p.CertFile)
+	}
+
+	for _, p := range m.pems {
+		n = append(n, p.Name)
+	}
+
+	return n
+}
+
+func (m *material) load() (*Store, error) {
+	var certs []*tls.Certificate
+	for _, p := range m.pairs {
+		c, err := loadKeyPair(p)
+		if err != nil {
+			return nil, err
+		}
+
+		certs = append(certs, c)
+	}
+
+	for _, p := range m.pems {
+		c, err := parseKeyPair(p.Cert, p.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", p.Name, err)
+		}
+
+		certs = append(certs, c)
+	}
+
+	return newStore(certs), nil
+}
@@ -0,0 +1,115 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/events"
+)
+
+type testProvider struct {
+	mx    sync.Mutex
+	pairs []PEMKeyPair
+	err   error
+}
+
+func (p *testProvider) KeyPairs() ([]PEMKeyPair, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return p.pairs, p.err
+}
+
+func (p *testProvider) set(pairs []PEMKeyPair, err error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.pairs, p.err = pairs, err
+}
+
+func readPEMKeyPair(t *testing.T, name string, p KeyPair) PEMKeyPair {
+	cert, err := ioutil.ReadFile(p.CertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ioutil.ReadFile(p.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return PEMKeyPair{Name: name, Cert: cert, Key: key}
+}
+
+func TestProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skipper-tlsconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	foo := readPEMKeyPair(t, "foo", createKeyPair(t, dir, "foo", "foo.example.org"))
+	bar := readPEMKeyPair(t, "bar", createKeyPair(t, dir, "bar", "bar.example.org"))
+
+	p := &testProvider{err: errors.New("unavailable")}
+	if _, err := New(Options{Providers: []Provider{p}}); err == nil {
+		t.Error("failed to fail when the provider fails")
+	}
+
+	p.set([]PEMKeyPair{foo}, nil)
+
+	bus := events.NewBus()
+	sub := bus.Subscribe(0, events.TypeCertificateReloaded)
+	defer sub.Close()
+
+	s, err := New(Options{
+		KeyPairs:       []KeyPair{{CertFile: "../fixtures/test.crt", KeyFile: "../fixtures/test.key"}},
+		Providers:      []Provider{p},
+		ReloadInterval: 10 * time.Millisecond,
+		EventBus:       bus,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	lookup := func(name string) string {
+		c, err := s.GetCertificate(&tls.ClientHelloInfo{ServerName: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return c.Leaf.Subject.CommonName
+	}
+
+	if cn := lookup("foo.example.org"); cn != "foo" {
+		t.Fatal("invalid initial certificate", cn)
+	}
+
+	p.set([]PEMKeyPair{foo, bar}, nil)
+	select {
+	case e := <-sub.C:
+		names, _ := e.Data["certificates"].([]string)
+		if len(names) != 3 || names[1] != "foo" || names[2] != "bar" {
+			t.Error("invalid event", e.Data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("certificates not reloaded")
+	}
+
+	if cn := lookup("bar.example.org"); cn != "bar" {
+		t.Error("invalid reloaded certificate", cn)
+	}
+
+	// the previous certificates are kept, when the provider fails
+	p.set(nil, errors.New("unavailable"))
+	time.Sleep(30 * time.Millisecond)
+	if cn := lookup("bar.example.org"); cn != "bar" {
+		t.Error("failed to keep the certificates", cn)
+	}
+}
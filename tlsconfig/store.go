@@ -0,0 +1,145 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"strings"
+)
+
+var errNoCertificate = errors.New("tlsconfig: no certificate")
+
+// KeyPair is the path of a certificate file and its private key file,
+// in PEM format. The certificate file needs to contain the
+// intermediate certificates, too.
+type KeyPair struct {
+	CertFile string
+	KeyFile  string
+}
+
+// Store selects the served certificate based on the server name sent
+// by the client with SNI. Exact names take precedence over wildcard
+// names. When no certificate matches, or the client doesn't send a
+// server name, the first certificate is served.
+type Store struct {
+	certs  []*tls.Certificate
+	byName map[string]*tls.Certificate
+}
+
+func parseKeyPair(certPEM, keyPEM []byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &cert, nil
+}
+
+func loadKeyPair(p KeyPair) (*tls.Certificate, error) {
+	certPEM, err := ioutil.ReadFile(p.CertFile)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := ioutil.ReadFile(p.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseKeyPair(certPEM, keyPEM)
+}
+
+// the names that a certificate is served for, the subject alternative
+// names, or the common name, when there are none
+func certificateNames(c *tls.Certificate) []string {
+	if len(c.Leaf.DNSNames) > 0 {
+		return c.Leaf.DNSNames
+	}
+
+	if c.Leaf.Subject.CommonName != "" {
+		return []string{c.Leaf.Subject.CommonName}
+	}
+
+	return nil
+}
+
+func newStore(certs []*tls.Certificate) *Store {
+	s := &Store{certs: certs, byName: make(map[string]*tls.Certificate)}
+	for _, c := range certs {
+		for _, n := range certificateNames(c) {
+			n = strings.ToLower(n)
+
+			// when multiple certificates have the same name, the
+			// first one is served
+			if _, ok := s.byName[n]; !ok {
+				s.byName[n] = c
+			}
+		}
+	}
+
+	return s
+}
+
+// LoadStore loads the key pairs.
+func LoadStore(pairs []KeyPair) (*Store, error) {
+	var certs []*tls.Certificate
+	for _, p := range pairs {
+		c, err := loadKeyPair(p)
+		if err != nil {
+			return nil, err
+		}
+
+		certs = append(certs, c)
+	}
+
+	return newStore(certs), nil
+}
+
+// Match returns the certificate whose names match the server name, or
+// nil.
+func (s *Store) Match(serverName string) *tls.Certificate {
+	name := strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if c, ok := s.byName[name]; ok {
+		return c
+	}
+
+	// a wildcard matches a single label
+	if i := strings.Index(name, "."); i > 0 {
+		if c, ok := s.byName["*"+name[i:]]; ok {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// Lookup returns the certificate served for a server name. When no
+// certificate matches, it returns the default, or nil, when the store
+// is empty.
+func (s *Store) Lookup(serverName string) *tls.Certificate {
+	if c := s.Match(serverName); c != nil {
+		return c
+	}
+
+	if len(s.certs) == 0 {
+		return nil
+	}
+
+	return s.certs[0]
+}
+
+// GetCertificate can be used as the GetCertificate function of a
+// tls.Config.
+func (s *Store) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if c := s.Lookup(hello.ServerName); c != nil {
+		return c, nil
+	}
+
+	return nil, errNoCertificate
+}
@@ -0,0 +1,95 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// creates a self-signed certificate for the names, and returns the
+// paths of the certificate and the key files
+func createKeyPair(t *testing.T, dir, commonName string, names ...string) KeyPair {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := KeyPair{
+		CertFile: filepath.Join(dir, commonName+".crt"),
+		KeyFile:  filepath.Join(dir, commonName+".key"),
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := ioutil.WriteFile(p.CertFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(p.KeyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestLookup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skipper-tlsconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	s, err := LoadStore([]KeyPair{
+		createKeyPair(t, dir, "default", "default.example.org"),
+		createKeyPair(t, dir, "wildcard", "*.example.org"),
+		createKeyPair(t, dir, "exact", "www.example.org", "api.example.org"),
+		createKeyPair(t, dir, "cn.example.com"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		serverName, expect string
+	}{
+		{"", "default"},
+		{"www.example.org", "exact"},
+		{"API.example.org.", "exact"},
+		{"foo.example.org", "wildcard"},
+		{"foo.bar.example.org", "default"},
+		{"cn.example.com", "cn.example.com"},
+		{"www.example.net", "default"},
+	} {
+		if c := s.Lookup(test.serverName); c.Leaf.Subject.CommonName != test.expect {
+			t.Errorf("invalid certificate for %s, got: %s, expected: %s", test.serverName, c.Leaf.Subject.CommonName, test.expect)
+		}
+	}
+}
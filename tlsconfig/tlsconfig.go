@@ -0,0 +1,383 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/events"
+)
+
+// DefaultReloadInterval is the default interval of checking the
+// certificate files for changes.
+const DefaultReloadInterval = time.Minute
+
+// Options for creating the TLS configuration of the proxy listener.
+type Options struct {
+
+	// The certificates served by the listener, selected by the
+	// server name sent by the clients. Either these, a certificate
+	// directory or a provider is required.
+	KeyPairs []KeyPair
+
+	// A directory containing certificate and key file pairs, named as
+	// <name>.crt and <name>.key. The pairs in the directory are served
+	// after the ones set in KeyPairs.
+	CertDir string
+
+	// Providers of certificates from sources other than the local
+	// files, e.g. Kubernetes secrets or Vault. Their certificates are
+	// served after the ones loaded from the files.
+	Providers []Provider
+
+	// The interval of checking the certificate files for changes.
+	// When the files change, the certificates are reloaded, without
+	// interrupting the connections. Default: 1m. When negative, the
+	// certificates are reloaded only by Reload.
+	ReloadInterval time.Duration
+
+	// When set, a certificate_reloaded event is published on the bus
+	// when the certificates were reloaded.
+	EventBus *events.Bus
+
+	// When set, only HTTP/1.1 is offered to the clients during the
+	// protocol negotiation.
+	DisableHTTP2 bool
+
+	// When set, the certificates for the server names that none of
+	// the configured certificates matches are requested from this
+	// function, e.g. from an ACME client. In this case, configuring
+	// certificate files is optional.
+	Fallback func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+	// Application protocols offered to the clients in addition to
+	// HTTP, e.g. for the ACME TLS-ALPN-01 challenges.
+	AdditionalProtos []string
+
+	// Restricts the TLS versions, the cipher suites and the curves
+	// accepted by the listener.
+	Policy Policy
+
+	// Whether the listener requests, verifies or requires the client
+	// certificates. See ParseClientAuth.
+	ClientAuth tls.ClientAuthType
+
+	// The CA certificates that the client certificates are verified
+	// with. Required when ClientAuth is set.
+	ClientCAs *x509.CertPool
+
+	// When set, the OCSP responses of the certificates are fetched
+	// from the OCSP responders of their issuers, and stapled to the
+	// handshakes. The certificate files need to contain the issuer
+	// certificates.
+	OCSPStapling bool
+
+	// The interval of checking whether the stapled OCSP responses need
+	// to be refreshed. They are refreshed half-way through their
+	// validity period. Default: 10m.
+	OCSPCheckInterval time.Duration
+
+	// When set, only the certificates with the keys and the signature
+	// algorithms approved by FIPS 140-2 are accepted, see
+	// CheckFIPSCertificate. It doesn't change the policy, that can be
+	// restricted with RestrictFIPS.
+	FIPS bool
+}
+
+// Server holds the TLS configuration of the proxy listener, and keeps
+// the served certificates up to date.
+type Server struct {
+
+	// The TLS configuration used by the listener.
+	Config *tls.Config
+
+	pairs       []KeyPair
+	dir         string
+	providers   []Provider
+	fallback    func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	store       atomic.Value
+	fingerprint string
+	bus         *events.Bus
+	stapler     *stapler
+	fips        bool
+	reloads     chan chan struct{}
+	quit        chan struct{}
+	done        chan struct{}
+}
+
+// NextProtos returns the application protocols offered to the clients,
+// in the order of preference.
+func NextProtos(disableHTTP2 bool) []string {
+	if disableHTTP2 {
+		return []string{"http/1.1"}
+	}
+
+	return []string{"h2", "http/1.1"}
+}
+
+// New creates the TLS configuration of the proxy listener, and starts
+// watching the certificate files for changes, and refreshing the OCSP
+// responses, when enabled.
+func New(o Options) (*Server, error) {
+	if o.ClientAuth != tls.NoClientCert && o.ClientCAs == nil {
+		return nil, errMissingClientCA
+	}
+
+	if o.ReloadInterval == 0 {
+		o.ReloadInterval = DefaultReloadInterval
+	}
+
+	if o.OCSPCheckInterval <= 0 {
+		o.OCSPCheckInterval = DefaultOCSPCheckInterval
+	}
+
+	s := &Server{
+		pairs:     o.KeyPairs,
+		dir:       o.CertDir,
+		providers: o.Providers,
+		fallback:  o.Fallback,
+		bus:       o.EventBus,
+		fips:      o.FIPS,
+		reloads:   make(chan chan struct{}),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	if o.OCSPStapling {
+		s.stapler = newStapler()
+	}
+
+	m, err := s.material()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.load(m); err != nil {
+		return nil, err
+	}
+
+	s.Config = &tls.Config{
+		GetCertificate:           s.GetCertificate,
+		NextProtos:               append(NextProtos(o.DisableHTTP2), o.AdditionalProtos...),
+		PreferServerCipherSuites: true,
+		ClientAuth:               o.ClientAuth,
+		ClientCAs:                o.ClientCAs,
+	}
+
+	o.Policy.Apply(s.Config)
+
+	go s.run(o.ReloadInterval, o.OCSPCheckInterval)
+	return s, nil
+}
+
+// the pairs of <name>.crt and <name>.key files in a directory, sorted
+// by name
+func dirKeyPairs(dir string) ([]KeyPair, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".crt" {
+			continue
+		}
+
+		names = append(names, strings.TrimSuffix(f.Name(), ".crt"))
+	}
+
+	sort.Strings(names)
+
+	var pairs []KeyPair
+	for _, n := range names {
+		pairs = append(pairs, KeyPair{
+			CertFile: filepath.Join(dir, n+".crt"),
+			KeyFile:  filepath.Join(dir, n+".key"),
+		})
+	}
+
+	return pairs, nil
+}
+
+func (s *Server) keyPairs() ([]KeyPair, error) {
+	if s.dir == "" {
+		return s.pairs, nil
+	}
+
+	dp, err := dirKeyPairs(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append([]KeyPair(nil), s.pairs...), dp...), nil
+}
+
+// identifies the current version of the certificate files, by their
+// paths, sizes and modification times
+func fingerprint(pairs []KeyPair) string {
+	var f []string
+	for _, p := range pairs {
+		for _, n := range []string{p.CertFile, p.KeyFile} {
+			fi, err := os.Stat(n)
+			if err != nil {
+				f = append(f, n+":"+err.Error())
+				continue
+			}
+
+			f = append(f, fmt.Sprintf("%s:%d:%d", n, fi.Size(), fi.ModTime().UnixNano()))
+		}
+	}
+
+	return strings.Join(f, ";")
+}
+
+func (s *Server) load(m *material) error {
+	if m.empty() && s.fallback == nil {
+		return errNoCertificate
+	}
+
+	store, err := m.load()
+	if err != nil {
+		return err
+	}
+
+	if s.fips {
+		for _, c := range store.certs {
+			if err := CheckFIPSCertificate(c); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.stapler != nil {
+		store = s.stapler.apply(store)
+	}
+
+	s.store.Store(store)
+	s.fingerprint = m.fingerprint()
+	return nil
+}
+
+// reloads the certificates, when the files or the key pairs of the
+// providers changed. When the reload fails, e.g. because only the
+// certificate was updated yet, but not the key, the previous
+// certificates are kept, and the reload is retried in the next round.
+func (s *Server) reload() {
+	m, err := s.material()
+	if err != nil {
+		log.Errorf("error while listing the certificates: %v", err)
+		return
+	}
+
+	if m.fingerprint() == s.fingerprint {
+		return
+	}
+
+	if err := s.load(m); err != nil {
+		log.Errorf("error while reloading the certificates: %v", err)
+		return
+	}
+
+	names := m.names()
+	log.Infof("TLS certificates reloaded: %d", len(names))
+	if s.stapler != nil {
+		s.staple()
+	}
+
+	s.bus.Publish(&events.Event{
+		Type: events.TypeCertificateReloaded,
+		Data: map[string]interface{}{"certificates": names},
+	})
+}
+
+// refreshes the stapled OCSP responses, when necessary
+func (s *Server) staple() {
+	store := s.store.Load().(*Store)
+	s.store.Store(s.stapler.staple(store, time.Now()))
+}
+
+// reloads the certificates, and refreshes the stapled OCSP responses,
+// in the configured intervals
+func (s *Server) run(reloadInterval, ocspInterval time.Duration) {
+	defer close(s.done)
+
+	var reload, staple <-chan time.Time
+	if reloadInterval > 0 {
+		ticker := time.NewTicker(reloadInterval)
+		defer ticker.Stop()
+		reload = ticker.C
+	}
+
+	if s.stapler != nil {
+		s.staple()
+		ticker := time.NewTicker(ocspInterval)
+		defer ticker.Stop()
+		staple = ticker.C
+	}
+
+	for {
+		select {
+		case <-reload:
+			s.reload()
+		case <-staple:
+			s.staple()
+		case done := <-s.reloads:
+			s.reload()
+			close(done)
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// GetCertificate returns the certificate for the server name sent by
+// the client. It is used as the GetCertificate function of the TLS
+// configuration.
+func (s *Server) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	store := s.store.Load().(*Store)
+	if s.fallback == nil {
+		return store.GetCertificate(hello)
+	}
+
+	if c := store.Match(hello.ServerName); c != nil {
+		return c, nil
+	}
+
+	if hello.ServerName != "" {
+		return s.fallback(hello)
+	}
+
+	return store.GetCertificate(hello)
+}
+
+// Reload checks the certificates immediately, and reloads them, when
+// they changed, e.g. on SIGHUP. It returns when the reload finished.
+func (s *Server) Reload() {
+	done := make(chan struct{})
+	select {
+	case s.reloads <- done:
+		<-done
+	case <-s.done:
+	}
+}
+
+// Close stops watching the certificate files, and refreshing the
+// OCSP responses.
+func (s *Server) Close() {
+	select {
+	case <-s.quit:
+	default:
+		close(s.quit)
+	}
+
+	<-s.done
+}
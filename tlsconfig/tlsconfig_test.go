@@ -0,0 +1,201 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/events"
+)
+
+func TestNew(t *testing.T) {
+	for _, test := range []struct {
+		title      string
+		options    Options
+		nextProtos []string
+		fail       bool
+	}{{
+		title: "missing certificate",
+		fail:  true,
+	}, {
+		title: "key not found",
+		options: Options{
+			KeyPairs: []KeyPair{{CertFile: "../fixtures/test.crt", KeyFile: "../fixtures/notFound.key"}},
+		},
+		fail: true,
+	}, {
+		title: "http2",
+		options: Options{
+			KeyPairs: []KeyPair{{CertFile: "../fixtures/test.crt", KeyFile: "../fixtures/test.key"}},
+		},
+		nextProtos: []string{"h2", "http/1.1"},
+	}, {
+		title: "http2 disabled",
+		options: Options{
+			KeyPairs:     []KeyPair{{CertFile: "../fixtures/test.crt", KeyFile: "../fixtures/test.key"}},
+			DisableHTTP2: true,
+		},
+		nextProtos: []string{"http/1.1"},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			s, err := New(test.options)
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer s.Close()
+
+			c := s.Config
+			if cert, err := c.GetCertificate(&tls.ClientHelloInfo{}); err != nil || cert == nil {
+				t.Error("certificate not loaded", err)
+			}
+
+			if len(c.NextProtos) != len(test.nextProtos) {
+				t.Fatal("invalid protocols", c.NextProtos)
+			}
+
+			for i, p := range test.nextProtos {
+				if c.NextProtos[i] != p {
+					t.Error("invalid protocols", c.NextProtos)
+				}
+			}
+		})
+	}
+}
+
+func TestReloadsCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skipper-tlsconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	createKeyPair(t, dir, "foo", "foo.example.org")
+
+	bus := events.NewBus()
+	sub := bus.Subscribe(0, events.TypeCertificateReloaded)
+	defer sub.Close()
+
+	s, err := New(Options{CertDir: dir, ReloadInterval: 10 * time.Millisecond, EventBus: bus})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	lookup := func(name string) string {
+		c, err := s.GetCertificate(&tls.ClientHelloInfo{ServerName: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return c.Leaf.Subject.CommonName
+	}
+
+	if cn := lookup("bar.example.org"); cn != "foo" {
+		t.Fatal("invalid initial certificate", cn)
+	}
+
+	createKeyPair(t, dir, "bar", "bar.example.org")
+	select {
+	case <-sub.C:
+	case <-time.After(time.Second):
+		t.Fatal("certificates not reloaded")
+	}
+
+	if cn := lookup("bar.example.org"); cn != "bar" {
+		t.Error("invalid reloaded certificate", cn)
+	}
+
+	if cn := lookup("foo.example.org"); cn != "foo" {
+		t.Error("invalid reloaded certificate", cn)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skipper-tlsconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	createKeyPair(t, dir, "foo", "foo.example.org")
+	s, err := New(Options{CertDir: dir, ReloadInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	createKeyPair(t, dir, "bar", "bar.example.org")
+	s.Reload()
+
+	c, err := s.GetCertificate(&tls.ClientHelloInfo{ServerName: "bar.example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cn := c.Leaf.Subject.CommonName; cn != "bar" {
+		t.Error("certificates not reloaded", cn)
+	}
+
+	// no-op after close
+	s.Close()
+	s.Reload()
+}
+
+func TestFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skipper-tlsconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fallback := &tls.Certificate{}
+	s, err := New(Options{
+		KeyPairs:         []KeyPair{createKeyPair(t, dir, "foo", "foo.example.org")},
+		ReloadInterval:   -1,
+		Fallback:         func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return fallback, nil },
+		AdditionalProtos: []string{"acme-tls/1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	if p := s.Config.NextProtos; len(p) != 3 || p[2] != "acme-tls/1" {
+		t.Error("invalid protocols", p)
+	}
+
+	for _, test := range []struct {
+		serverName   string
+		expectConfig bool
+	}{
+		{"foo.example.org", true},
+		{"", true},
+		{"bar.example.org", false},
+	} {
+		c, err := s.GetCertificate(&tls.ClientHelloInfo{ServerName: test.serverName})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if (c != fallback) != test.expectConfig {
+			t.Error("invalid certificate for", test.serverName)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Package tracing implements distributed tracing of the requests
+proxied by skipper.
+
+The proxy creates a span for each incoming request, with child spans
+for the route lookup, for each filter and for the backend call. The
+span context of the incoming request is continued when it carries
+one, and the context of the backend span is propagated to the backend
+in the HTTP headers, so that the request flow across the services can
+be followed.
+
+Tracers
+
+The tracer implementation is selected by name in the Options:
+
+    noop:   the default, it doesn't record or propagate anything
+    jaeger: propagates the span context in the Uber-Trace-Id header,
+            and sends the sampled spans to a Jaeger collector
+    otel:   propagates the span context in the W3C Traceparent header,
+            and exports the sampled spans to an OpenTelemetry collector,
+            using OTLP/HTTP with JSON encoding
+    zipkin: propagates the span context in the B3 headers, and sends the
+            sampled spans to the Zipkin v2 HTTP API, in JSON
+
+The propagation format of the recording tracers can be overridden with
+the Propagation option, e.g. to use the B3 headers with the
+OpenTelemetry tracer, when the backend services only understand B3.
+For B3, both the multiple X-B3-* headers and the single B3 header are
+accepted in the incoming requests, while the outgoing requests get the
+multiple headers with the b3 option, or the single one with b3single.
+
+The recording tracers sample the traces started by skipper based on the
+SampleRate option, while, with the default parentbased sampler, the
+traces started by the clients inherit the sampling decision of the
+incoming request. With the ratio sampler, the sample rate is applied to
+every trace. The sampling decision is derived from the trace ID, so
+every service applying the same rate makes the same decision.
+
+The finished spans are reported in batches, periodically, from a
+bounded queue. When the queue is full, the spans are dropped.
+
+Command line example:
+
+    skipper -tracer jaeger -tracer-endpoint http://jaeger:14268/api/traces -tracer-sample-rate 0.01
+*/
+package tracing
@@ -0,0 +1,278 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// JaegerHeader is the name of the HTTP header used by Jaeger to
+	// propagate the span context.
+	JaegerHeader = "Uber-Trace-Id"
+
+	// DefaultJaegerEndpoint is the default address of the Jaeger
+	// collector's HTTP endpoint.
+	DefaultJaegerEndpoint = "http://localhost:14268/api/traces"
+
+	jaegerFlagSampled = 1
+)
+
+// thrift binary protocol type IDs
+const (
+	thriftBool   = 2
+	thriftDouble = 4
+	thriftI32    = 8
+	thriftI64    = 10
+	thriftString = 11
+	thriftStruct = 12
+	thriftList   = 15
+)
+
+// jaeger.thrift TagType values
+const (
+	jaegerTagString = 0
+	jaegerTagDouble = 1
+	jaegerTagBool   = 2
+	jaegerTagLong   = 3
+)
+
+type jaegerPropagator struct{}
+
+type jaegerSender struct {
+	endpoint    string
+	serviceName string
+	tags        map[string]string
+	client      *http.Client
+}
+
+// thriftWriter implements the subset of the thrift binary protocol
+// required to encode a jaeger.thrift Batch.
+type thriftWriter struct {
+	buf bytes.Buffer
+}
+
+func newJaeger(o Options) Tracer {
+	if o.Endpoint == "" {
+		o.Endpoint = DefaultJaegerEndpoint
+	}
+
+	if o.ServiceName == "" {
+		o.ServiceName = DefaultServiceName
+	}
+
+	s := &jaegerSender{
+		endpoint:    o.Endpoint,
+		serviceName: o.ServiceName,
+		tags:        o.Tags,
+		client:      &http.Client{Timeout: 5 * time.Second},
+	}
+
+	return newRecordingTracer(o, jaegerPropagator{}, newBatchReporter(o, s.send))
+}
+
+// format: {trace-id}:{span-id}:{parent-span-id}:{flags}
+func (jaegerPropagator) inject(c SpanContext, h http.Header) {
+	var flags int
+	if c.Sampled {
+		flags = jaegerFlagSampled
+	}
+
+	h.Set(JaegerHeader, fmt.Sprintf("%s:%x:%x:%x", c.TraceID(), c.SpanID, c.ParentID, flags))
+}
+
+func (jaegerPropagator) extract(h http.Header) (SpanContext, bool) {
+	v := h.Get(JaegerHeader)
+	if v == "" {
+		return SpanContext{}, false
+	}
+
+	// the value may be URL encoded
+	v = strings.Replace(v, "%3A", ":", -1)
+	parts := strings.Split(v, ":")
+	if len(parts) != 4 {
+		return SpanContext{}, false
+	}
+
+	var (
+		c   SpanContext
+		err error
+	)
+
+	if c.TraceIDHigh, c.TraceIDLow, err = parseTraceID(parts[0]); err != nil {
+		return SpanContext{}, false
+	}
+
+	if c.SpanID, err = strconv.ParseUint(parts[1], 16, 64); err != nil {
+		return SpanContext{}, false
+	}
+
+	if c.ParentID, err = strconv.ParseUint(parts[2], 16, 64); err != nil {
+		return SpanContext{}, false
+	}
+
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return SpanContext{}, false
+	}
+
+	c.Sampled = flags&jaegerFlagSampled != 0
+	if !c.IsValid() {
+		return SpanContext{}, false
+	}
+
+	return c, true
+}
+
+// parses a 64 or 128 bit hexadecimal trace ID
+func parseTraceID(s string) (high, low uint64, err error) {
+	if len(s) > 32 || len(s) == 0 {
+		return 0, 0, fmt.Errorf("invalid trace id: %s", s)
+	}
+
+	if len(s) > 16 {
+		if high, err = strconv.ParseUint(s[:len(s)-16], 16, 64); err != nil {
+			return
+		}
+
+		s = s[len(s)-16:]
+	}
+
+	low, err = strconv.ParseUint(s, 16, 64)
+	return
+}
+
+func (w *thriftWriter) fieldHeader(typ byte, id int16) {
+	w.buf.WriteByte(typ)
+	binary.Write(&w.buf, binary.BigEndian, id)
+}
+
+func (w *thriftWriter) fieldStop() {
+	w.buf.WriteByte(0)
+}
+
+func (w *thriftWriter) i32(v int32) {
+	binary.Write(&w.buf, binary.BigEndian, v)
+}
+
+func (w *thriftWriter) i64(v int64) {
+	binary.Write(&w.buf, binary.BigEndian, v)
+}
+
+func (w *thriftWriter) str(s string) {
+	w.i32(int32(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *thriftWriter) listHeader(elemType byte, size int) {
+	w.buf.WriteByte(elemType)
+	w.i32(int32(size))
+}
+
+func (w *thriftWriter) tag(key string, value interface{}) {
+	w.fieldHeader(thriftString, 1)
+	w.str(key)
+
+	if b, ok := value.(bool); ok {
+		w.fieldHeader(thriftI32, 2)
+		w.i32(jaegerTagBool)
+		w.fieldHeader(thriftBool, 5)
+		if b {
+			w.buf.WriteByte(1)
+		} else {
+			w.buf.WriteByte(0)
+		}
+	} else if n, ok := tagInt(value); ok {
+		w.fieldHeader(thriftI32, 2)
+		w.i32(jaegerTagLong)
+		w.fieldHeader(thriftI64, 6)
+		w.i64(n)
+	} else if f, ok := tagFloat(value); ok {
+		w.fieldHeader(thriftI32, 2)
+		w.i32(jaegerTagDouble)
+		w.fieldHeader(thriftDouble, 4)
+		binary.Write(&w.buf, binary.BigEndian, math.Float64bits(f))
+	} else {
+		w.fieldHeader(thriftI32, 2)
+		w.i32(jaegerTagString)
+		w.fieldHeader(thriftString, 3)
+		w.str(tagString(value))
+	}
+
+	w.fieldStop()
+}
+
+func (w *thriftWriter) span(s *span) {
+	w.fieldHeader(thriftI64, 1)
+	w.i64(int64(s.context.TraceIDLow))
+	w.fieldHeader(thriftI64, 2)
+	w.i64(int64(s.context.TraceIDHigh))
+	w.fieldHeader(thriftI64, 3)
+	w.i64(int64(s.context.SpanID))
+	w.fieldHeader(thriftI64, 4)
+	w.i64(int64(s.context.ParentID))
+	w.fieldHeader(thriftString, 5)
+	w.str(s.operation)
+	w.fieldHeader(thriftI32, 7)
+	w.i32(jaegerFlagSampled)
+	w.fieldHeader(thriftI64, 8)
+	w.i64(s.start.UnixNano() / int64(time.Microsecond))
+	w.fieldHeader(thriftI64, 9)
+	w.i64(int64(s.duration / time.Microsecond))
+
+	if len(s.tags) > 0 {
+		w.fieldHeader(thriftList, 10)
+		w.listHeader(thriftStruct, len(s.tags))
+		for k, v := range s.tags {
+			w.tag(k, v)
+		}
+	}
+
+	w.fieldStop()
+}
+
+// encodes the spans as a jaeger.thrift Batch
+func (js *jaegerSender) encode(spans []*span) []byte {
+	var w thriftWriter
+
+	w.fieldHeader(thriftStruct, 1)
+	w.fieldHeader(thriftString, 1)
+	w.str(js.serviceName)
+	if len(js.tags) > 0 {
+		w.fieldHeader(thriftList, 2)
+		w.listHeader(thriftStruct, len(js.tags))
+		for k, v := range js.tags {
+			w.tag(k, v)
+		}
+	}
+
+	w.fieldStop()
+
+	w.fieldHeader(thriftList, 2)
+	w.listHeader(thriftStruct, len(spans))
+	for _, s := range spans {
+		w.span(s)
+	}
+
+	w.fieldStop()
+	return w.buf.Bytes()
+}
+
+func (js *jaegerSender) send(spans []*span) error {
+	rsp, err := js.client.Post(js.endpoint, "application/x-thrift", bytes.NewReader(js.encode(spans)))
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status from the jaeger collector: %d", rsp.StatusCode)
+	}
+
+	return nil
+}
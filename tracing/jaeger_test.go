@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJaegerPropagation(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		header   string
+		valid    bool
+		expected SpanContext
+	}{{
+		title: "no header",
+	}, {
+		title:  "invalid format",
+		header: "foo:bar",
+	}, {
+		title:  "invalid hex",
+		header: "foo:1:0:1",
+	}, {
+		title:    "64 bit trace id",
+		header:   "a:b:c:1",
+		valid:    true,
+		expected: SpanContext{TraceIDLow: 10, SpanID: 11, ParentID: 12, Sampled: true},
+	}, {
+		title:    "128 bit trace id, not sampled",
+		header:   "1000000000000000a:b:0:0",
+		valid:    true,
+		expected: SpanContext{TraceIDHigh: 1, TraceIDLow: 10, SpanID: 11},
+	}, {
+		title:    "url encoded",
+		header:   "a%3Ab%3A0%3A1",
+		valid:    true,
+		expected: SpanContext{TraceIDLow: 10, SpanID: 11, Sampled: true},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			h := make(http.Header)
+			if test.header != "" {
+				h.Set(JaegerHeader, test.header)
+			}
+
+			c, ok := jaegerPropagator{}.extract(h)
+			if ok != test.valid {
+				t.Fatal("unexpected validity", ok)
+			}
+
+			if c != test.expected {
+				t.Error("invalid span context", c, test.expected)
+			}
+
+			if !ok {
+				return
+			}
+
+			hh := make(http.Header)
+			jaegerPropagator{}.inject(c, hh)
+			cc, _ := jaegerPropagator{}.extract(hh)
+			if cc != c {
+				t.Error("failed to roundtrip", cc, c)
+			}
+		})
+	}
+}
+
+func TestJaegerReport(t *testing.T) {
+	received := make(chan []byte, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-thrift" {
+			t.Error("invalid content type")
+		}
+
+		b, _ := ioutil.ReadAll(r.Body)
+		received <- b
+	}))
+	defer collector.Close()
+
+	tr := newJaeger(Options{
+		Endpoint:      collector.URL,
+		ServiceName:   "test-service",
+		FlushInterval: time.Hour,
+	})
+
+	s := tr.StartSpan("test-operation", SpanContext{})
+	s.SetTag("http.status_code", 200)
+	s.SetTag("error", false)
+	s.SetTag("route", "foo")
+	s.Finish()
+	tr.Close()
+
+	select {
+	case b := <-received:
+		for _, expected := range []string{"test-service", "test-operation", "http.status_code", "route", "foo"} {
+			if !bytes.Contains(b, []byte(expected)) {
+				t.Error("missing from the batch:", expected)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Error("spans not received")
+	}
+}
@@ -0,0 +1,274 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// TraceparentHeader is the name of the HTTP header defined by the
+	// W3C Trace Context specification to propagate the span context.
+	TraceparentHeader = "Traceparent"
+
+	// DefaultOpenTelemetryEndpoint is the default address of the
+	// OTLP/HTTP traces endpoint.
+	DefaultOpenTelemetryEndpoint = "http://localhost:4318/v1/traces"
+
+	traceparentVersion = "00"
+	traceFlagSampled   = 1
+)
+
+// OTLP span kinds and status codes
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindServer   = 2
+	otlpSpanKindClient   = 3
+	otlpStatusError      = 2
+)
+
+type w3cPropagator struct{}
+
+type otlpSender struct {
+	endpoint string
+	resource otlpResource
+	client   *http.Client
+}
+
+type (
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+
+	otlpStatus struct {
+		Code int `json:"code,omitempty"`
+	}
+
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+
+	otlpTraces struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+)
+
+// the tags set by the proxy are exported with the names defined by the
+// OpenTelemetry semantic conventions
+var otlpAttributeNames = map[string]string{
+	TagHTTPMethod:     "http.request.method",
+	TagHTTPURL:        "url.full",
+	TagHTTPHost:       "server.address",
+	TagHTTPStatusCode: "http.response.status_code",
+	TagBackendHost:    "skipper.backend_host",
+}
+
+func newOpenTelemetry(o Options) Tracer {
+	if o.Endpoint == "" {
+		o.Endpoint = DefaultOpenTelemetryEndpoint
+	}
+
+	if o.ServiceName == "" {
+		o.ServiceName = DefaultServiceName
+	}
+
+	resource := otlpResource{Attributes: []otlpAttribute{otlpAttr("service.name", o.ServiceName)}}
+	for k, v := range o.Tags {
+		resource.Attributes = append(resource.Attributes, otlpAttr(k, v))
+	}
+
+	s := &otlpSender{
+		endpoint: o.Endpoint,
+		resource: resource,
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+
+	t := newRecordingTracer(o, w3cPropagator{}, newBatchReporter(o, s.send))
+	t.traceID128 = true
+	return t
+}
+
+// format: {version}-{trace-id}-{parent-id}-{trace-flags}
+func (w3cPropagator) inject(c SpanContext, h http.Header) {
+	var flags int
+	if c.Sampled {
+		flags = traceFlagSampled
+	}
+
+	h.Set(TraceparentHeader, fmt.Sprintf(
+		"%s-%016x%016x-%016x-%02x",
+		traceparentVersion,
+		c.TraceIDHigh,
+		c.TraceIDLow,
+		c.SpanID,
+		flags,
+	))
+}
+
+func (w3cPropagator) extract(h http.Header) (SpanContext, bool) {
+	v := h.Get(TraceparentHeader)
+	parts := strings.Split(strings.TrimSpace(v), "-")
+
+	// future versions may append further fields
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		parts[0] == traceparentVersion && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+
+	var (
+		c   SpanContext
+		err error
+	)
+
+	if c.TraceIDHigh, c.TraceIDLow, err = parseTraceID(parts[1]); err != nil {
+		return SpanContext{}, false
+	}
+
+	if c.SpanID, err = strconv.ParseUint(parts[2], 16, 64); err != nil {
+		return SpanContext{}, false
+	}
+
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return SpanContext{}, false
+	}
+
+	c.Sampled = flags&traceFlagSampled != 0
+	if !c.IsValid() {
+		return SpanContext{}, false
+	}
+
+	return c, true
+}
+
+func otlpAttr(key string, value interface{}) otlpAttribute {
+	var v otlpValue
+	if b, ok := value.(bool); ok {
+		v.BoolValue = &b
+	} else if n, ok := tagInt(value); ok {
+		s := strconv.FormatInt(n, 10)
+		v.IntValue = &s
+	} else if f, ok := tagFloat(value); ok {
+		v.DoubleValue = &f
+	} else {
+		s := tagString(value)
+		v.StringValue = &s
+	}
+
+	return otlpAttribute{Key: key, Value: v}
+}
+
+func otlpConvert(s *span) otlpSpan {
+	ts := otlpSpan{
+		TraceID:           fmt.Sprintf("%016x%016x", s.context.TraceIDHigh, s.context.TraceIDLow),
+		SpanID:            fmt.Sprintf("%016x", s.context.SpanID),
+		Name:              s.operation,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.start.Add(s.duration).UnixNano(), 10),
+	}
+
+	if s.context.ParentID != 0 {
+		ts.ParentSpanID = fmt.Sprintf("%016x", s.context.ParentID)
+	}
+
+	for k, v := range s.tags {
+		switch k {
+		case TagSpanKind:
+			switch v {
+			case "server":
+				ts.Kind = otlpSpanKindServer
+			case "client":
+				ts.Kind = otlpSpanKindClient
+			}
+		case TagError:
+			if v == true {
+				ts.Status.Code = otlpStatusError
+			}
+		default:
+			if name, ok := otlpAttributeNames[k]; ok {
+				k = name
+			}
+
+			ts.Attributes = append(ts.Attributes, otlpAttr(k, v))
+		}
+	}
+
+	return ts
+}
+
+func (s *otlpSender) encode(spans []*span) ([]byte, error) {
+	converted := make([]otlpSpan, len(spans))
+	for i, sp := range spans {
+		converted[i] = otlpConvert(sp)
+	}
+
+	return json.Marshal(otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource: s.resource,
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: DefaultServiceName},
+			Spans: converted,
+		}},
+	}}})
+}
+
+func (s *otlpSender) send(spans []*span) error {
+	b, err := s.encode(spans)
+	if err != nil {
+		return err
+	}
+
+	rsp, err := s.client.Post(s.endpoint, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status from the OTLP endpoint: %d", rsp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestW3CPropagation(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		header   string
+		valid    bool
+		expected SpanContext
+	}{{
+		title: "no header",
+	}, {
+		title:  "invalid version",
+		header: "ff-0000000000000001000000000000000a-000000000000000b-01",
+	}, {
+		title:  "short trace id",
+		header: "00-000000000000000a-000000000000000b-01",
+	}, {
+		title:  "zero trace id",
+		header: "00-00000000000000000000000000000000-000000000000000b-01",
+	}, {
+		title:  "extra fields with the current version",
+		header: "00-0000000000000001000000000000000a-000000000000000b-01-foo",
+	}, {
+		title:    "sampled",
+		header:   "00-0000000000000001000000000000000a-000000000000000b-01",
+		valid:    true,
+		expected: SpanContext{TraceIDHigh: 1, TraceIDLow: 10, SpanID: 11, Sampled: true},
+	}, {
+		title:    "not sampled",
+		header:   "00-0000000000000001000000000000000a-000000000000000b-00",
+		valid:    true,
+		expected: SpanContext{TraceIDHigh: 1, TraceIDLow: 10, SpanID: 11},
+	}, {
+		title:    "future version with extra fields",
+		header:   "01-0000000000000001000000000000000a-000000000000000b-01-foo",
+		valid:    true,
+		expected: SpanContext{TraceIDHigh: 1, TraceIDLow: 10, SpanID: 11, Sampled: true},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			h := make(http.Header)
+			if test.header != "" {
+				h.Set(TraceparentHeader, test.header)
+			}
+
+			c, ok := w3cPropagator{}.extract(h)
+			if ok != test.valid {
+				t.Fatal("unexpected validity", ok)
+			}
+
+			if c != test.expected {
+				t.Error("invalid span context", c, test.expected)
+			}
+
+			if !ok {
+				return
+			}
+
+			hh := make(http.Header)
+			w3cPropagator{}.inject(c, hh)
+			if test.header[:2] == traceparentVersion && hh.Get(TraceparentHeader) != test.header {
+				t.Error("failed to roundtrip", hh.Get(TraceparentHeader), test.header)
+			}
+		})
+	}
+}
+
+func TestRatioSampler(t *testing.T) {
+	r := &testReporter{}
+	tr := newRecordingTracer(Options{SampleRate: 0.5, Sampler: SamplerRatio}, testPropagator{}, r)
+
+	low := tr.StartSpan("foo", SpanContext{TraceIDLow: 1 << 62, SpanID: 1, Sampled: false})
+	if !low.Context().Sampled {
+		t.Error("failed to override the parent decision")
+	}
+
+	high := tr.StartSpan("foo", SpanContext{TraceIDLow: 1 << 63, SpanID: 1, Sampled: true})
+	if high.Context().Sampled {
+		t.Error("failed to override the parent decision")
+	}
+}
+
+func TestOpenTelemetryExport(t *testing.T) {
+	received := make(chan otlpTraces, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var traces otlpTraces
+		if err := json.NewDecoder(r.Body).Decode(&traces); err != nil {
+			t.Error(err)
+		}
+
+		received <- traces
+	}))
+	defer collector.Close()
+
+	tr := newOpenTelemetry(Options{
+		Endpoint:      collector.URL,
+		ServiceName:   "test-service",
+		FlushInterval: time.Hour,
+	})
+
+	s := tr.StartSpan("ingress", SpanContext{})
+	s.SetTag(TagSpanKind, "server")
+	s.SetTag(TagRouteID, "foo")
+	s.SetTag(TagHTTPStatusCode, 502)
+	s.SetTag(TagError, true)
+	s.Finish()
+	tr.Close()
+
+	var traces otlpTraces
+	select {
+	case traces = <-received:
+	case <-time.After(time.Second):
+		t.Fatal("spans not received")
+	}
+
+	if len(traces.ResourceSpans) != 1 || len(traces.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatal("invalid export")
+	}
+
+	rs := traces.ResourceSpans[0]
+	if *rs.Resource.Attributes[0].Value.StringValue != "test-service" {
+		t.Error("invalid service name")
+	}
+
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatal("invalid number of spans", len(spans))
+	}
+
+	sp := spans[0]
+	if len(sp.TraceID) != 32 || len(sp.SpanID) != 16 || sp.ParentSpanID != "" {
+		t.Error("invalid ids", sp.TraceID, sp.SpanID, sp.ParentSpanID)
+	}
+
+	if sp.Kind != otlpSpanKindServer || sp.Status.Code != otlpStatusError {
+		t.Error("invalid kind or status", sp.Kind, sp.Status.Code)
+	}
+
+	attrs := make(map[string]otlpValue)
+	for _, a := range sp.Attributes {
+		attrs[a.Key] = a.Value
+	}
+
+	if v := attrs[TagRouteID].StringValue; v == nil || *v != "foo" {
+		t.Error("invalid route id")
+	}
+
+	if v := attrs["http.response.status_code"].IntValue; v == nil || *v != "502" {
+		t.Error("invalid status code")
+	}
+}
@@ -0,0 +1,341 @@
+package tracing
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const maxBatchSize = 1 << 8
+
+// propagator implementations write and read the span context in a
+// tracer specific header format.
+type propagator interface {
+	inject(SpanContext, http.Header)
+	extract(http.Header) (SpanContext, bool)
+}
+
+// reporter implementations receive the finished, sampled spans.
+type reporter interface {
+	report(*span)
+	close() error
+}
+
+type idGenerator struct {
+	mx  sync.Mutex
+	rnd *rand.Rand
+}
+
+// recordingTracer is the common implementation of the tracers that
+// record spans, differing only in the propagation format and the
+// way they report the finished spans.
+type recordingTracer struct {
+	// the bits of the float64 sample rate, accessed atomically, and
+	// kept first for the alignment on 32 bit platforms
+	sampleRate uint64
+
+	propagator  propagator
+	reporter    reporter
+	parentBased bool
+	traceID128  bool
+	ids         *idGenerator
+}
+
+type span struct {
+	mx        sync.Mutex
+	tracer    *recordingTracer
+	context   SpanContext
+	operation string
+	start     time.Time
+	duration  time.Duration
+	tags      map[string]interface{}
+	finished  bool
+}
+
+// batchReporter collects the finished spans and sends them in batches,
+// either periodically or when enough of them were collected.
+type batchReporter struct {
+	queue         chan *span
+	send          func([]*span) error
+	flushInterval time.Duration
+	quit          chan struct{}
+	done          chan struct{}
+}
+
+func newIDGenerator() *idGenerator {
+	return &idGenerator{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (g *idGenerator) next() uint64 {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+	for {
+		if id := g.rnd.Uint64(); id != 0 {
+			return id
+		}
+	}
+}
+
+func normalizeSampleRate(rate float64) float64 {
+	if rate == 0 {
+		return 1
+	}
+
+	return rate
+}
+
+func newRecordingTracer(o Options, p propagator, r reporter) *recordingTracer {
+	// the propagation format can be overridden, e.g. to talk B3 to
+	// legacy services while reporting to a different backend
+	if pp, ok := propagators[o.Propagation]; ok {
+		p = pp
+	}
+
+	return &recordingTracer{
+		sampleRate:  math.Float64bits(normalizeSampleRate(o.SampleRate)),
+		propagator:  p,
+		reporter:    r,
+		parentBased: o.Sampler != SamplerRatio,
+		ids:         newIDGenerator(),
+	}
+}
+
+// the sampling decision is derived from the trace ID, so that it is
+// the same for all the spans of a trace, and all the services using
+// the same rate
+func (t *recordingTracer) sample(traceIDLow uint64) bool {
+	switch rate := t.SampleRate(); {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	default:
+		return float64(traceIDLow>>11) < rate*(1<<53)
+	}
+}
+
+func (t *recordingTracer) SampleRate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&t.sampleRate))
+}
+
+func (t *recordingTracer) SetSampleRate(rate float64) {
+	atomic.StoreUint64(&t.sampleRate, math.Float64bits(normalizeSampleRate(rate)))
+}
+
+func (t *recordingTracer) StartSpan(operation string, parent SpanContext) Span {
+	var c SpanContext
+	if parent.IsValid() {
+		c = parent
+		c.ParentID = parent.SpanID
+		if !t.parentBased {
+			c.Sampled = t.sample(c.TraceIDLow)
+		}
+	} else {
+		c.TraceIDHigh = t.traceIDHigh()
+		c.TraceIDLow = t.ids.next()
+		c.Sampled = t.sample(c.TraceIDLow)
+	}
+
+	c.SpanID = t.ids.next()
+	s := &span{
+		tracer:    t,
+		context:   c,
+		operation: operation,
+		start:     time.Now(),
+	}
+
+	if c.Sampled {
+		s.tags = make(map[string]interface{})
+	}
+
+	return s
+}
+
+// only the W3C format requires 128 bit trace IDs, but it is safe to
+// use them with the others, too
+func (t *recordingTracer) traceIDHigh() uint64 {
+	if t.traceID128 {
+		return t.ids.next()
+	}
+
+	return 0
+}
+
+func (t *recordingTracer) Inject(c SpanContext, h http.Header) {
+	if c.IsValid() {
+		t.propagator.inject(c, h)
+	}
+}
+
+func (t *recordingTracer) Extract(h http.Header) (SpanContext, bool) {
+	return t.propagator.extract(h)
+}
+
+func (t *recordingTracer) Close() error {
+	return t.reporter.close()
+}
+
+func (s *span) Context() SpanContext { return s.context }
+
+func (s *span) SetTag(key string, value interface{}) Span {
+	if !s.context.Sampled {
+		return s
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if !s.finished {
+		s.tags[key] = value
+	}
+
+	return s
+}
+
+func (s *span) Finish() {
+	s.mx.Lock()
+	if s.finished {
+		s.mx.Unlock()
+		return
+	}
+
+	s.finished = true
+	s.duration = time.Since(s.start)
+	s.mx.Unlock()
+
+	if s.context.Sampled {
+		s.tracer.reporter.report(s)
+	}
+}
+
+// returns the value of integer tags
+func tagInt(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// returns the value of floating point tags
+func tagFloat(v interface{}) (float64, bool) {
+	switch f := v.(type) {
+	case float32:
+		return float64(f), true
+	case float64:
+		return f, true
+	default:
+		return 0, false
+	}
+}
+
+// returns the string representation of a tag value
+func tagString(v interface{}) string {
+	switch vv := v.(type) {
+	case string:
+		return vv
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func newBatchReporter(o Options, send func([]*span) error) *batchReporter {
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = DefaultFlushInterval
+	}
+
+	if o.QueueSize <= 0 {
+		o.QueueSize = DefaultQueueSize
+	}
+
+	r := &batchReporter{
+		queue:         make(chan *span, o.QueueSize),
+		send:          send,
+		flushInterval: o.FlushInterval,
+		quit:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	go r.receive()
+	return r
+}
+
+func (r *batchReporter) report(s *span) {
+	select {
+	case r.queue <- s:
+	default:
+		log.Debug("tracing: span queue full, dropping span")
+	}
+}
+
+func (r *batchReporter) flush(batch []*span) []*span {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := r.send(batch); err != nil {
+		log.Errorf("tracing: failed to send %d spans: %v", len(batch), err)
+	}
+
+	return batch[:0]
+}
+
+func (r *batchReporter) receive() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	var batch []*span
+	for {
+		select {
+		case s := <-r.queue:
+			batch = append(batch, s)
+			if len(batch) >= maxBatchSize {
+				batch = r.flush(batch)
+			}
+		case <-ticker.C:
+			batch = r.flush(batch)
+		case <-r.quit:
+			for {
+				select {
+				case s := <-r.queue:
+					batch = append(batch, s)
+				default:
+					r.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *batchReporter) close() error {
+	close(r.quit)
+	<-r.done
+	return nil
+}
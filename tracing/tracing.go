@@ -0,0 +1,258 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// NoopName is the name of the default tracer, which doesn't
+	// propagate or record anything.
+	NoopName = "noop"
+
+	// JaegerName is the name of the tracer reporting to a Jaeger
+	// collector.
+	JaegerName = "jaeger"
+
+	// OpenTelemetryName is the name of the tracer using the W3C
+	// trace context propagation and exporting the spans with the
+	// OTLP protocol.
+	OpenTelemetryName = "otel"
+
+	// ZipkinName is the name of the tracer using the B3 propagation
+	// and reporting to the Zipkin v2 HTTP API.
+	ZipkinName = "zipkin"
+
+	// Propagation formats that can be used to override the default
+	// format of the selected tracer.
+	PropagationJaeger   = "jaeger"
+	PropagationW3C      = "w3c"
+	PropagationB3       = "b3"
+	PropagationB3Single = "b3single"
+
+	// SamplerParentBased is the default sampler. It respects the
+	// sampling decision of the incoming requests, and applies the
+	// sample rate only to the new traces.
+	SamplerParentBased = "parentbased"
+
+	// SamplerRatio applies the sample rate to every trace, ignoring
+	// the sampling decision of the incoming requests.
+	SamplerRatio = "ratio"
+
+	// The default service name used by the recording tracers.
+	DefaultServiceName = "skipper"
+
+	// The default interval of sending the recorded spans.
+	DefaultFlushInterval = time.Second
+
+	// The default maximum number of finished spans kept in memory
+	// while waiting for the next flush. When the queue is full, the
+	// further spans are dropped.
+	DefaultQueueSize = 1 << 12
+)
+
+// Operation names of the spans created by the proxy.
+const (
+	OperationIngress     = "ingress"
+	OperationRouteLookup = "route_lookup"
+	OperationBackend     = "backend"
+)
+
+// Tag keys set by the proxy on the spans. The generic ones follow the
+// OpenTracing semantic conventions.
+const (
+	TagComponent      = "component"
+	TagSpanKind       = "span.kind"
+	TagHTTPMethod     = "http.method"
+	TagHTTPURL        = "http.url"
+	TagHTTPHost       = "http.host"
+	TagHTTPStatusCode = "http.status_code"
+	TagError          = "error"
+	TagRouteID        = "skipper.route_id"
+	TagBackendHost    = "skipper.backend_host"
+	TagPhase          = "skipper.filter_phase"
+	TagFlowID         = "skipper.flow_id"
+)
+
+// Options for initializing a tracer.
+type Options struct {
+
+	// Name of the tracer implementation. Possible values: noop,
+	// jaeger, otel and zipkin. When empty, the noop tracer is used.
+	Tracer string
+
+	// The service name that the recorded spans are reported with.
+	// Default: skipper.
+	ServiceName string
+
+	// The address where the finished spans are sent to. Its
+	// format depends on the tracer implementation. For Jaeger,
+	// it is the URL of the collector's HTTP endpoint, default:
+	// http://localhost:14268/api/traces. For OpenTelemetry, it is
+	// the URL of the OTLP/HTTP traces endpoint, default:
+	// http://localhost:4318/v1/traces. For Zipkin, it is the URL
+	// of the v2 spans endpoint, default:
+	// http://localhost:9411/api/v2/spans.
+	Endpoint string
+
+	// The format of the span context in the HTTP headers. Possible
+	// values: jaeger (Uber-Trace-Id), w3c (Traceparent), b3 (the
+	// multiple X-B3-* headers) and b3single (the single B3 header).
+	// When empty, the format of the tracer is used: jaeger for
+	// Jaeger, w3c for OpenTelemetry and b3 for Zipkin. The b3
+	// formats accept both the single and the multiple headers
+	// from the incoming requests.
+	Propagation string
+
+	// The fraction of the traces that are sampled, when the
+	// incoming request doesn't carry a sampling decision already.
+	// When 0, every trace is sampled. When negative, no trace is
+	// sampled, unless the incoming request says otherwise.
+	SampleRate float64
+
+	// The sampling strategy, possible values: parentbased and ratio.
+	// Default: parentbased.
+	Sampler string
+
+	// The interval of sending the recorded spans to the endpoint.
+	// Default: 1s.
+	FlushInterval time.Duration
+
+	// The maximum number of finished spans waiting for the next
+	// flush. Default: 4096.
+	QueueSize int
+
+	// Static tags attached to every reported span, e.g. the
+	// name of the cluster.
+	Tags map[string]string
+}
+
+// SpanContext identifies a span across process boundaries. The IDs are
+// shared by all the supported propagation formats: 128 bit trace IDs,
+// of which only the lower half may be used, and 64 bit span IDs.
+type SpanContext struct {
+	TraceIDHigh uint64
+	TraceIDLow  uint64
+	SpanID      uint64
+	ParentID    uint64
+	Sampled     bool
+}
+
+// Span instances represent a single operation within a trace.
+// Implementations need to be safe for setting tags from multiple
+// goroutines.
+type Span interface {
+
+	// Returns the identifiers of the span, to be used as the
+	// parent of its child spans, or for propagation.
+	Context() SpanContext
+
+	// Sets a tag on the span. The supported value types are
+	// strings, booleans, integers and floats; other values are
+	// recorded in their fmt.Sprint format.
+	SetTag(key string, value interface{}) Span
+
+	// Marks the end of the operation. Calling SetTag or Finish
+	// after Finish has no effect.
+	Finish()
+}
+
+// Tracer instances create spans and propagate their context over
+// the HTTP headers.
+type Tracer interface {
+
+	// Starts a new span. When the parent is valid, the span is
+	// created as its child, otherwise it starts a new trace.
+	StartSpan(operation string, parent SpanContext) Span
+
+	// Writes the span context into the HTTP headers of an
+	// outgoing request.
+	Inject(SpanContext, http.Header)
+
+	// Reads the span context from the HTTP headers of an incoming
+	// request. Returns false when the headers don't contain a
+	// valid span context.
+	Extract(http.Header) (SpanContext, bool)
+
+	// Flushes the pending spans and stops the tracer.
+	Close() error
+}
+
+// SampleRateController is implemented by the tracers whose sample rate
+// can be changed at runtime, e.g. to sample every trace while debugging
+// an incident. The rate has the same meaning as the SampleRate option.
+type SampleRateController interface {
+	SampleRate() float64
+	SetSampleRate(float64)
+}
+
+type noopSpan struct{}
+
+type noopTracer struct{}
+
+var noopSpanInstance = &noopSpan{}
+
+var propagators = map[string]propagator{
+	PropagationJaeger:   jaegerPropagator{},
+	PropagationW3C:      w3cPropagator{},
+	PropagationB3:       b3Propagator{},
+	PropagationB3Single: b3Propagator{single: true},
+}
+
+// Noop is a tracer that neither records nor propagates anything. It
+// is the default tracer of the proxy.
+var Noop Tracer = &noopTracer{}
+
+// IsValid returns true when the span context contains a trace and a
+// span ID.
+func (sc SpanContext) IsValid() bool {
+	return (sc.TraceIDHigh != 0 || sc.TraceIDLow != 0) && sc.SpanID != 0
+}
+
+// TraceID returns the hexadecimal representation of the trace ID. When
+// the higher 64 bits are not used, it returns only the lower 64 bits.
+func (sc SpanContext) TraceID() string {
+	if sc.TraceIDHigh == 0 {
+		return fmt.Sprintf("%016x", sc.TraceIDLow)
+	}
+
+	return fmt.Sprintf("%016x%016x", sc.TraceIDHigh, sc.TraceIDLow)
+}
+
+func (s *noopSpan) Context() SpanContext                 { return SpanContext{} }
+func (s *noopSpan) SetTag(string, interface{}) Span      { return s }
+func (s *noopSpan) Finish()                              {}
+func (t *noopTracer) StartSpan(string, SpanContext) Span { return noopSpanInstance }
+func (t *noopTracer) Inject(SpanContext, http.Header)    {}
+func (t *noopTracer) Close() error                       { return nil }
+
+func (t *noopTracer) Extract(http.Header) (SpanContext, bool) {
+	return SpanContext{}, false
+}
+
+// New creates a tracer based on the Tracer field of the options.
+func New(o Options) (Tracer, error) {
+	switch o.Sampler {
+	case "", SamplerParentBased, SamplerRatio:
+	default:
+		return nil, fmt.Errorf("tracing: unsupported sampler: %s", o.Sampler)
+	}
+
+	if _, ok := propagators[o.Propagation]; o.Propagation != "" && !ok {
+		return nil, fmt.Errorf("tracing: unsupported propagation: %s", o.Propagation)
+	}
+
+	switch o.Tracer {
+	case "", NoopName:
+		return Noop, nil
+	case JaegerName:
+		return newJaeger(o), nil
+	case OpenTelemetryName:
+		return newOpenTelemetry(o), nil
+	case ZipkinName:
+		return newZipkin(o), nil
+	default:
+		return nil, fmt.Errorf("tracing: unsupported tracer: %s", o.Tracer)
+	}
+}
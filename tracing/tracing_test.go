@@ -0,0 +1,188 @@
+package tracing
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testReporter struct {
+	mx    sync.Mutex
+	spans []*span
+}
+
+type testPropagator struct{}
+
+func (r *testReporter) report(s *span) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func (r *testReporter) close() error { return nil }
+
+func (testPropagator) inject(c SpanContext, h http.Header) {
+	jaegerPropagator{}.inject(c, h)
+}
+
+func (testPropagator) extract(h http.Header) (SpanContext, bool) {
+	return jaegerPropagator{}.extract(h)
+}
+
+func TestNewTracer(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		fail bool
+	}{
+		{"", false},
+		{NoopName, false},
+		{JaegerName, false},
+		{"foo", true},
+	} {
+		tr, err := New(Options{Tracer: test.name})
+		if test.fail {
+			if err == nil {
+				t.Error("failed to fail", test.name)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Error(test.name, err)
+			continue
+		}
+
+		tr.Close()
+	}
+}
+
+func TestNoop(t *testing.T) {
+	s := Noop.StartSpan("foo", SpanContext{})
+	s.SetTag("bar", "baz")
+	s.Finish()
+
+	if s.Context().IsValid() {
+		t.Error("unexpected valid span context")
+	}
+
+	h := make(http.Header)
+	Noop.Inject(SpanContext{TraceIDLow: 1, SpanID: 2}, h)
+	if len(h) != 0 {
+		t.Error("unexpected headers")
+	}
+}
+
+func TestChildSpan(t *testing.T) {
+	r := &testReporter{}
+	tr := newRecordingTracer(Options{}, testPropagator{}, r)
+
+	root := tr.StartSpan("root", SpanContext{})
+	child := tr.StartSpan("child", root.Context())
+	child.SetTag("foo", "bar")
+	child.Finish()
+	root.Finish()
+
+	if len(r.spans) != 2 {
+		t.Fatal("failed to report spans", len(r.spans))
+	}
+
+	c := r.spans[0]
+	if c.context.TraceIDLow != root.Context().TraceIDLow ||
+		c.context.ParentID != root.Context().SpanID ||
+		c.context.SpanID == root.Context().SpanID {
+		t.Error("invalid child context", c.context, root.Context())
+	}
+
+	if c.tags["foo"] != "bar" {
+		t.Error("failed to set tag")
+	}
+}
+
+func TestSampling(t *testing.T) {
+	r := &testReporter{}
+	tr := newRecordingTracer(Options{SampleRate: -1}, testPropagator{}, r)
+
+	s := tr.StartSpan("root", SpanContext{})
+	s.SetTag("foo", "bar")
+	s.Finish()
+	if len(r.spans) != 0 {
+		t.Error("unexpected span reported")
+	}
+
+	if !s.Context().IsValid() {
+		t.Error("unsampled span should be propagated")
+	}
+
+	s = tr.StartSpan("child", SpanContext{TraceIDLow: 1, SpanID: 2, Sampled: true})
+	s.Finish()
+	if len(r.spans) != 1 {
+		t.Error("failed to respect the parent sampling decision")
+	}
+}
+
+func TestSetSampleRate(t *testing.T) {
+	r := &testReporter{}
+	tr := newRecordingTracer(Options{SampleRate: -1}, testPropagator{}, r)
+
+	var c SampleRateController = tr
+	if c.SampleRate() != -1 {
+		t.Error("invalid sample rate", c.SampleRate())
+	}
+
+	c.SetSampleRate(0)
+	if c.SampleRate() != 1 {
+		t.Error("invalid sample rate", c.SampleRate())
+	}
+
+	tr.StartSpan("root", SpanContext{}).Finish()
+	if len(r.spans) != 1 {
+		t.Error("failed to sample after changing the rate")
+	}
+
+	c.SetSampleRate(-1)
+	tr.StartSpan("root", SpanContext{}).Finish()
+	if len(r.spans) != 1 {
+		t.Error("unexpected span reported")
+	}
+}
+
+func TestFinishOnce(t *testing.T) {
+	r := &testReporter{}
+	tr := newRecordingTracer(Options{}, testPropagator{}, r)
+	s := tr.StartSpan("root", SpanContext{})
+	s.Finish()
+	s.Finish()
+	s.SetTag("foo", "bar")
+	if len(r.spans) != 1 {
+		t.Error("span reported multiple times")
+	}
+
+	if _, ok := r.spans[0].tags["foo"]; ok {
+		t.Error("tag set after finish")
+	}
+}
+
+func TestBatchReporter(t *testing.T) {
+	var (
+		mx   sync.Mutex
+		sent int
+	)
+
+	br := newBatchReporter(Options{FlushInterval: time.Hour}, func(spans []*span) error {
+		mx.Lock()
+		defer mx.Unlock()
+		sent += len(spans)
+		return nil
+	})
+
+	for i := 0; i < maxBatchSize+3; i++ {
+		br.report(&span{})
+	}
+
+	br.close()
+	if sent != maxBatchSize+3 {
+		t.Error("failed to send all spans", sent)
+	}
+}
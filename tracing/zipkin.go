@@ -0,0 +1,256 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// B3Header is the name of the single HTTP header used by the B3
+	// propagation format.
+	B3Header = "B3"
+
+	// The names of the multiple HTTP headers used by the B3
+	// propagation format.
+	B3TraceIDHeader      = "X-B3-Traceid"
+	B3SpanIDHeader       = "X-B3-Spanid"
+	B3ParentSpanIDHeader = "X-B3-Parentspanid"
+	B3SampledHeader      = "X-B3-Sampled"
+	B3FlagsHeader        = "X-B3-Flags"
+
+	// DefaultZipkinEndpoint is the default address of the Zipkin
+	// v2 HTTP API.
+	DefaultZipkinEndpoint = "http://localhost:9411/api/v2/spans"
+)
+
+// b3Propagator reads both the single and the multiple header format,
+// preferring the single one, and writes the configured one.
+type b3Propagator struct {
+	single bool
+}
+
+type zipkinSender struct {
+	endpoint      string
+	localEndpoint zipkinEndpoint
+	tags          map[string]string
+	client        *http.Client
+}
+
+type (
+	zipkinEndpoint struct {
+		ServiceName string `json:"serviceName"`
+	}
+
+	zipkinSpan struct {
+		TraceID       string            `json:"traceId"`
+		ID            string            `json:"id"`
+		ParentID      string            `json:"parentId,omitempty"`
+		Name          string            `json:"name"`
+		Kind          string            `json:"kind,omitempty"`
+		Timestamp     int64             `json:"timestamp"`
+		Duration      int64             `json:"duration"`
+		LocalEndpoint zipkinEndpoint    `json:"localEndpoint"`
+		Tags          map[string]string `json:"tags,omitempty"`
+	}
+)
+
+func newZipkin(o Options) Tracer {
+	if o.Endpoint == "" {
+		o.Endpoint = DefaultZipkinEndpoint
+	}
+
+	if o.ServiceName == "" {
+		o.ServiceName = DefaultServiceName
+	}
+
+	s := &zipkinSender{
+		endpoint:      o.Endpoint,
+		localEndpoint: zipkinEndpoint{ServiceName: o.ServiceName},
+		tags:          o.Tags,
+		client:        &http.Client{Timeout: 5 * time.Second},
+	}
+
+	return newRecordingTracer(o, b3Propagator{}, newBatchReporter(o, s.send))
+}
+
+func (p b3Propagator) inject(c SpanContext, h http.Header) {
+	sampled := "0"
+	if c.Sampled {
+		sampled = "1"
+	}
+
+	if p.single {
+		v := fmt.Sprintf("%s-%016x-%s", c.TraceID(), c.SpanID, sampled)
+		if c.ParentID != 0 {
+			v = fmt.Sprintf("%s-%016x", v, c.ParentID)
+		}
+
+		h.Set(B3Header, v)
+		return
+	}
+
+	h.Set(B3TraceIDHeader, c.TraceID())
+	h.Set(B3SpanIDHeader, fmt.Sprintf("%016x", c.SpanID))
+	if c.ParentID != 0 {
+		h.Set(B3ParentSpanIDHeader, fmt.Sprintf("%016x", c.ParentID))
+	} else {
+		h.Del(B3ParentSpanIDHeader)
+	}
+
+	h.Set(B3SampledHeader, sampled)
+	h.Del(B3FlagsHeader)
+}
+
+// the sampling state is either "1", "0", "d" (debug), or, in case of
+// the multiple headers, "true" or "false" by older implementations
+func b3Sampled(state string) bool {
+	switch state {
+	case "1", "d", "true":
+		return true
+	default:
+		return false
+	}
+}
+
+// format: {trace-id}-{span-id}-{sampling-state}-{parent-span-id}, where
+// the last two are optional
+func extractB3Single(v string) (SpanContext, bool) {
+	parts := strings.Split(v, "-")
+	if len(parts) < 2 || len(parts) > 4 {
+		return SpanContext{}, false
+	}
+
+	var (
+		c   SpanContext
+		err error
+	)
+
+	if c.TraceIDHigh, c.TraceIDLow, err = parseTraceID(parts[0]); err != nil {
+		return SpanContext{}, false
+	}
+
+	if c.SpanID, err = strconv.ParseUint(parts[1], 16, 64); err != nil {
+		return SpanContext{}, false
+	}
+
+	if len(parts) > 2 {
+		c.Sampled = b3Sampled(parts[2])
+	}
+
+	if len(parts) > 3 {
+		if c.ParentID, err = strconv.ParseUint(parts[3], 16, 64); err != nil {
+			return SpanContext{}, false
+		}
+	}
+
+	return c, c.IsValid()
+}
+
+func extractB3Multi(h http.Header) (SpanContext, bool) {
+	var (
+		c   SpanContext
+		err error
+	)
+
+	if c.TraceIDHigh, c.TraceIDLow, err = parseTraceID(h.Get(B3TraceIDHeader)); err != nil {
+		return SpanContext{}, false
+	}
+
+	if c.SpanID, err = strconv.ParseUint(h.Get(B3SpanIDHeader), 16, 64); err != nil {
+		return SpanContext{}, false
+	}
+
+	if p := h.Get(B3ParentSpanIDHeader); p != "" {
+		if c.ParentID, err = strconv.ParseUint(p, 16, 64); err != nil {
+			return SpanContext{}, false
+		}
+	}
+
+	c.Sampled = b3Sampled(strings.ToLower(h.Get(B3SampledHeader))) || h.Get(B3FlagsHeader) == "1"
+	return c, c.IsValid()
+}
+
+func (b3Propagator) extract(h http.Header) (SpanContext, bool) {
+	var (
+		c  SpanContext
+		ok bool
+	)
+
+	if v := h.Get(B3Header); v != "" {
+		c, ok = extractB3Single(v)
+	} else {
+		c, ok = extractB3Multi(h)
+	}
+
+	if !ok {
+		return SpanContext{}, false
+	}
+
+	return c, true
+}
+
+func (zs *zipkinSender) convert(s *span) zipkinSpan {
+	zsp := zipkinSpan{
+		TraceID:       s.context.TraceID(),
+		ID:            fmt.Sprintf("%016x", s.context.SpanID),
+		Name:          s.operation,
+		Timestamp:     s.start.UnixNano() / int64(time.Microsecond),
+		Duration:      int64(s.duration / time.Microsecond),
+		LocalEndpoint: zs.localEndpoint,
+		Tags:          make(map[string]string),
+	}
+
+	if s.context.ParentID != 0 {
+		zsp.ParentID = fmt.Sprintf("%016x", s.context.ParentID)
+	}
+
+	for k, v := range zs.tags {
+		zsp.Tags[k] = v
+	}
+
+	for k, v := range s.tags {
+		switch k {
+		case TagSpanKind:
+			zsp.Kind = strings.ToUpper(tagString(v))
+		case TagError:
+			// zipkin marks the span failed by the presence of the
+			// error tag, regardless of its value
+			if v == true {
+				zsp.Tags[k] = "true"
+			}
+		default:
+			zsp.Tags[k] = tagString(v)
+		}
+	}
+
+	return zsp
+}
+
+func (zs *zipkinSender) send(spans []*span) error {
+	converted := make([]zipkinSpan, len(spans))
+	for i, s := range spans {
+		converted[i] = zs.convert(s)
+	}
+
+	b, err := json.Marshal(converted)
+	if err != nil {
+		return err
+	}
+
+	rsp, err := zs.client.Post(zs.endpoint, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status from the zipkin endpoint: %d", rsp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestB3Propagation(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		headers  map[string]string
+		valid    bool
+		expected SpanContext
+	}{{
+		title: "no header",
+	}, {
+		title:   "single, deny only",
+		headers: map[string]string{B3Header: "0"},
+	}, {
+		title:   "single, invalid span id",
+		headers: map[string]string{B3Header: "000000000000000a-foo-1"},
+	}, {
+		title:    "single, ids only",
+		headers:  map[string]string{B3Header: "000000000000000a-000000000000000b"},
+		valid:    true,
+		expected: SpanContext{TraceIDLow: 10, SpanID: 11},
+	}, {
+		title:    "single, sampled with parent",
+		headers:  map[string]string{B3Header: "0000000000000001000000000000000a-000000000000000b-1-000000000000000c"},
+		valid:    true,
+		expected: SpanContext{TraceIDHigh: 1, TraceIDLow: 10, SpanID: 11, ParentID: 12, Sampled: true},
+	}, {
+		title:    "single, debug",
+		headers:  map[string]string{B3Header: "000000000000000a-000000000000000b-d"},
+		valid:    true,
+		expected: SpanContext{TraceIDLow: 10, SpanID: 11, Sampled: true},
+	}, {
+		title: "single preferred over multi",
+		headers: map[string]string{
+			B3Header:        "000000000000000a-000000000000000b-0",
+			B3TraceIDHeader: "000000000000000c",
+			B3SpanIDHeader:  "000000000000000d",
+			B3SampledHeader: "1",
+		},
+		valid:    true,
+		expected: SpanContext{TraceIDLow: 10, SpanID: 11},
+	}, {
+		title:   "multi, missing span id",
+		headers: map[string]string{B3TraceIDHeader: "000000000000000a"},
+	}, {
+		title: "multi, sampled with parent",
+		headers: map[string]string{
+			B3TraceIDHeader:      "0000000000000001000000000000000a",
+			B3SpanIDHeader:       "000000000000000b",
+			B3ParentSpanIDHeader: "000000000000000c",
+			B3SampledHeader:      "1",
+		},
+		valid:    true,
+		expected: SpanContext{TraceIDHigh: 1, TraceIDLow: 10, SpanID: 11, ParentID: 12, Sampled: true},
+	}, {
+		title: "multi, legacy sampled value",
+		headers: map[string]string{
+			B3TraceIDHeader: "000000000000000a",
+			B3SpanIDHeader:  "000000000000000b",
+			B3SampledHeader: "True",
+		},
+		valid:    true,
+		expected: SpanContext{TraceIDLow: 10, SpanID: 11, Sampled: true},
+	}, {
+		title: "multi, debug flag",
+		headers: map[string]string{
+			B3TraceIDHeader: "000000000000000a",
+			B3SpanIDHeader:  "000000000000000b",
+			B3FlagsHeader:   "1",
+		},
+		valid:    true,
+		expected: SpanContext{TraceIDLow: 10, SpanID: 11, Sampled: true},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			h := make(http.Header)
+			for k, v := range test.headers {
+				h.Set(k, v)
+			}
+
+			c, ok := b3Propagator{}.extract(h)
+			if ok != test.valid {
+				t.Fatal("unexpected validity", ok)
+			}
+
+			if c != test.expected {
+				t.Error("invalid span context", c, test.expected)
+			}
+
+			if !ok {
+				return
+			}
+
+			for _, p := range []b3Propagator{{}, {single: true}} {
+				hh := make(http.Header)
+				p.inject(c, hh)
+				if p.single != (hh.Get(B3Header) != "") || p.single == (hh.Get(B3TraceIDHeader) != "") {
+					t.Error("invalid headers injected", p.single, hh)
+				}
+
+				if cc, _ := p.extract(hh); cc != c {
+					t.Error("failed to roundtrip", p.single, cc, c)
+				}
+			}
+		})
+	}
+}
+
+func TestPropagationOverride(t *testing.T) {
+	if _, err := New(Options{Tracer: JaegerName, Propagation: "foo"}); err == nil {
+		t.Error("failed to fail")
+	}
+
+	tr, err := New(Options{Tracer: OpenTelemetryName, Propagation: PropagationB3Single})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tr.Close()
+
+	h := make(http.Header)
+	tr.Inject(tr.StartSpan("test", SpanContext{}).Context(), h)
+	if h.Get(B3Header) == "" || h.Get(TraceparentHeader) != "" {
+		t.Error("propagation format not overridden", h)
+	}
+}
+
+func TestZipkinReport(t *testing.T) {
+	received := make(chan []zipkinSpan, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Error("invalid content type")
+		}
+
+		var spans []zipkinSpan
+		if err := json.NewDecoder(r.Body).Decode(&spans); err != nil {
+			t.Error(err)
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		received <- spans
+	}))
+	defer collector.Close()
+
+	tr := newZipkin(Options{
+		Endpoint:      collector.URL,
+		ServiceName:   "test-service",
+		FlushInterval: time.Hour,
+		Tags:          map[string]string{"cluster": "test"},
+	})
+
+	parent := tr.StartSpan("ingress", SpanContext{})
+	s := tr.StartSpan("backend", parent.Context())
+	s.SetTag(TagSpanKind, "client")
+	s.SetTag(TagHTTPStatusCode, 200)
+	s.SetTag(TagError, false)
+	s.Finish()
+	tr.Close()
+
+	var spans []zipkinSpan
+	select {
+	case spans = <-received:
+	case <-time.After(time.Second):
+		t.Fatal("spans not received")
+	}
+
+	if len(spans) != 1 {
+		t.Fatal("invalid number of spans", len(spans))
+	}
+
+	sp := spans[0]
+	if len(sp.TraceID) != 16 || len(sp.ID) != 16 || len(sp.ParentID) != 16 {
+		t.Error("invalid ids", sp.TraceID, sp.ID, sp.ParentID)
+	}
+
+	if sp.Name != "backend" || sp.Kind != "CLIENT" || sp.LocalEndpoint.ServiceName != "test-service" {
+		t.Error("invalid span", sp.Name, sp.Kind, sp.LocalEndpoint.ServiceName)
+	}
+
+	if sp.Tags[TagHTTPStatusCode] != "200" || sp.Tags[TagError] != "" || sp.Tags["cluster"] != "test" {
+		t.Error("invalid tags", sp.Tags)
+	}
+}
@@ -0,0 +1,202 @@
+package skipper
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/innkeeper"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/secrets"
+	"github.com/zalando/skipper/tlsconfig"
+)
+
+// ErrValidationFailed is returned by Validate, when the configuration
+// contains errors.
+var ErrValidationFailed = errors.New("validation failed")
+
+type validationReport struct {
+	w      io.Writer
+	errors int
+}
+
+func (r *validationReport) ok(format string, args ...interface{}) {
+	fmt.Fprintf(r.w, "ok     "+format+"\n", args...)
+}
+
+func (r *validationReport) warn(format string, args ...interface{}) {
+	fmt.Fprintf(r.w, "warn   "+format+"\n", args...)
+}
+
+func (r *validationReport) fail(format string, args ...interface{}) {
+	r.errors++
+	fmt.Fprintf(r.w, "error  "+format+"\n", args...)
+}
+
+func (r *validationReport) check(title string, err error) bool {
+	if err != nil {
+		r.fail("%s: %v", title, err)
+		return false
+	}
+
+	r.ok("%s", title)
+	return true
+}
+
+// validates the routes of the data clients, and checks whether the same
+// route ids are used by multiple sources
+func validateRoutes(r *validationReport, rt *routing.Routing, clients []routing.DataClient) {
+	sources := make(map[string]string)
+	for _, c := range clients {
+		source := fmt.Sprintf("%T", c)
+		routes, err := c.LoadAll()
+		if err != nil {
+			r.fail("data client %s: %v", source, err)
+			continue
+		}
+
+		var invalid int
+		ids := make(map[string]bool)
+		for _, ri := range routes {
+			if ids[ri.Id] {
+				r.warn("data client %s: duplicate route id %s", source, ri.Id)
+			}
+
+			ids[ri.Id] = true
+			if previous, ok := sources[ri.Id]; ok && previous != source {
+				r.warn("data client %s: route %s overrides the route from %s", source, ri.Id, previous)
+			}
+
+			sources[ri.Id] = source
+			if err := rt.Validate([]*eskip.Route{ri}); err != nil {
+				invalid++
+				r.fail("data client %s: %v", source, err)
+			}
+		}
+
+		if invalid == 0 {
+			r.ok("data client %s: %d routes", source, len(routes))
+		}
+	}
+
+	if len(clients) == 0 {
+		r.warn("no route source specified")
+	}
+}
+
+// validates the TLS material of the listener, without starting the
+// background reloading
+func validateTLS(r *validationReport, o *Options) {
+	to, err := o.tlsOptions()
+	if !r.check("TLS options", err) {
+		return
+	}
+
+	to.ReloadInterval = -1
+	to.OCSPStapling = false
+	if o.EnableACME {
+		// the certificates of the ACME hosts are obtained at runtime
+		to.Fallback = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return nil, errors.New("not available during validation")
+		}
+	}
+
+	s, err := tlsconfig.New(to)
+	if r.check("TLS certificates", err) {
+		s.Close()
+	}
+}
+
+// Validate checks the configuration without starting the proxy: it loads
+// the routes from all the configured data clients, and validates them
+// against the registered filters and predicates, loads the TLS
+// certificates, and checks the TLS policies and the other configured
+// resources. The report is written to w, and, when any error was found,
+// ErrValidationFailed is returned.
+func Validate(o Options, w io.Writer) error {
+	r := &validationReport{w: w}
+
+	if o.FIPS {
+		r.check("FIPS mode", o.checkFIPS())
+	}
+
+	_, err := o.fipsPolicy(o.UpstreamTLSMinVersion, o.UpstreamTLSMaxVersion, o.UpstreamTLSCipherSuites, o.UpstreamTLSCurves)
+	r.check("upstream TLS policy", err)
+
+	if o.isHTTPS() {
+		validateTLS(r, &o)
+	}
+
+	if o.AdminAddress != "" {
+		if o.AdminTokens == "" {
+			r.fail("admin API: missing tokens")
+		} else {
+			_, err := secrets.ParseSource(o.AdminTokens)
+			r.check("admin API tokens", err)
+		}
+
+		_, _, err := o.adminListener()
+		r.check("admin API listener", err)
+	}
+
+	if len(o.MaintenanceRoutes) > 0 && o.AdminAddress == "" {
+		r.warn("maintenance routes: the maintenance mode requires the admin API")
+	}
+
+	geoDB, err := o.geoIPDatabase()
+	if geoDB != nil {
+		defer geoDB.Close()
+	}
+
+	if len(o.GeoIPDatabases) > 0 {
+		r.check("GeoIP databases", err)
+	}
+
+	registry, closeFilters, err := o.filterRegistry(geoDB)
+	if r.check("filters", err) {
+		defer closeFilters()
+
+		auth := innkeeper.CreateInnkeeperAuthentication(innkeeper.AuthOptions{
+			InnkeeperAuthToken:  o.InnkeeperAuthToken,
+			OAuthCredentialsDir: o.OAuthCredentialsDir,
+			OAuthUrl:            o.OAuthUrl,
+			OAuthScope:          o.OAuthScope})
+
+		dataClients, err := createDataClients(o, auth)
+		if r.check("data clients", err) {
+			dataClients = append(dataClients, o.CustomDataClients...)
+
+			// no data clients, the routes are validated one by one
+			rt := routing.New(routing.Options{
+				FilterRegistry:      registry,
+				Predicates:          o.predicates(geoDB),
+				AllowedFilters:      o.AllowedFilters,
+				DisabledFilters:     o.DisabledFilters,
+				AllowedPredicates:   o.AllowedPredicates,
+				DisabledPredicates:  o.DisabledPredicates,
+				MaxFiltersPerRoute:  o.MaxFiltersPerRoute,
+				MaxRegexpComplexity: o.MaxRegexpComplexity,
+				MaxRouteSize:        o.MaxRouteSize,
+			})
+
+			validateRoutes(r, rt, dataClients)
+
+			if len(o.Namespaces) > 0 || len(o.NamespaceTokens) > 0 || len(o.NamespaceRateLimits) > 0 {
+				_, err := o.namespaces()
+				r.check("namespaces", err)
+			}
+
+			rt.Close()
+		}
+	}
+
+	if r.errors > 0 {
+		fmt.Fprintf(w, "%d error(s) found\n", r.errors)
+		return ErrValidationFailed
+	}
+
+	fmt.Fprintln(w, "configuration valid")
+	return nil
+}
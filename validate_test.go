@@ -0,0 +1,146 @@
+package skipper
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func writeRoutes(t *testing.T, routes string) string {
+	f, err := ioutil.TempFile("", "skipper-validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+	if _, err := f.WriteString(routes); err != nil {
+		t.Fatal(err)
+	}
+
+	return f.Name()
+}
+
+func TestValidate(t *testing.T) {
+	valid := writeRoutes(t, `
+		foo: Path("/foo") -> setPath("/") -> "https://foo.example.org";
+		bar: Path("/bar") && Cookie("alpha", "on") -> <shunt>;
+	`)
+	defer os.Remove(valid)
+
+	invalid := writeRoutes(t, `
+		foo: Path("/foo") -> noSuchFilter() -> "https://foo.example.org";
+		bar: Path("/bar") && NoSuchPredicate() -> <shunt>;
+		baz: Path("/baz") -> <shunt>;
+	`)
+	defer os.Remove(invalid)
+
+	for _, test := range []struct {
+		title    string
+		options  Options
+		fail     bool
+		contains []string
+	}{{
+		title:    "valid routes",
+		options:  Options{RoutesFile: valid},
+		contains: []string{"2 routes", "configuration valid"},
+	}, {
+		title:    "invalid routes",
+		options:  Options{RoutesFile: invalid},
+		fail:     true,
+		contains: []string{"noSuchFilter", "NoSuchPredicate", "2 error(s) found"},
+	}, {
+		title:   "missing routes file",
+		options: Options{RoutesFile: valid + "-missing"},
+		fail:    true,
+	}, {
+		title: "valid TLS",
+		options: Options{
+			RoutesFile:  valid,
+			CertPathTLS: "fixtures/test.crt",
+			KeyPathTLS:  "fixtures/test.key",
+		},
+		contains: []string{"ok     TLS certificates"},
+	}, {
+		title: "missing certificate",
+		options: Options{
+			RoutesFile:  valid,
+			CertPathTLS: "fixtures/missing.crt",
+			KeyPathTLS:  "fixtures/test.key",
+		},
+		fail:     true,
+		contains: []string{"error  TLS certificates"},
+	}, {
+		title: "invalid TLS policy",
+		options: Options{
+			RoutesFile:    valid,
+			CertPathTLS:   "fixtures/test.crt",
+			KeyPathTLS:    "fixtures/test.key",
+			TLSMinVersion: "1.7",
+		},
+		fail: true,
+	}, {
+		title:    "admin API without tokens",
+		options:  Options{RoutesFile: valid, AdminAddress: ":9922"},
+		fail:     true,
+		contains: []string{"admin API: missing tokens"},
+	}, {
+		title:    "admin API on a public address without TLS",
+		options:  Options{RoutesFile: valid, AdminAddress: "10.0.0.1:9922", AdminTokens: "env:PATH"},
+		fail:     true,
+		contains: []string{"error  admin API listener"},
+	}, {
+		title: "routing snapshot with route signatures",
+		options: Options{
+			RoutesFile:          valid,
+			RouteSigningKeys:    []string{"release.pem"},
+			RoutingSnapshotFile: "routes.snapshot",
+		},
+		fail:     true,
+		contains: []string{"can't be used together with the route signatures"},
+	}, {
+		title:    "disabled filter",
+		options:  Options{RoutesFile: valid, DisabledFilters: []string{"setPath"}},
+		fail:     true,
+		contains: []string{"filter disabled: 'setPath'"},
+	}, {
+		title:    "predicate not allowed",
+		options:  Options{RoutesFile: valid, AllowedPredicates: []string{"Path"}},
+		fail:     true,
+		contains: []string{"predicate disabled: 'Cookie'"},
+	}, {
+		title: "valid namespaces",
+		options: Options{
+			RoutesFile:          valid,
+			Namespaces:          []string{"team_a"},
+			NamespaceRateLimits: map[string]int{"team_a": 100},
+		},
+		contains: []string{"ok     namespaces"},
+	}, {
+		title: "rate limit of unknown namespace",
+		options: Options{
+			RoutesFile:          valid,
+			Namespaces:          []string{"team_a"},
+			NamespaceRateLimits: map[string]int{"team_b": 100},
+		},
+		fail:     true,
+		contains: []string{"rate limit of unknown namespace"},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			var out bytes.Buffer
+			err := Validate(test.options, &out)
+			if test.fail && err != ErrValidationFailed {
+				t.Error("failed to fail", out.String())
+			} else if !test.fail && err != nil {
+				t.Error(err, out.String())
+			}
+
+			for _, c := range test.contains {
+				if !strings.Contains(out.String(), c) {
+					t.Errorf("missing from the report: %s\n%s", c, out.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+/*
+Package vault implements a provider of the TLS certificates of the proxy
+listener, that are issued by the PKI secrets engine of Vault.
+
+The certificate is requested from the issue endpoint of a PKI role, and
+renewed when one third of its lifetime is left, or at the configured
+time before its expiry:
+
+    skipper -vault-pki-path pki/issue/skipper -vault-pki-common-name www.example.org -vault-pki-alt-names example.org
+
+The address of the Vault server and the token are taken from the
+VAULT_ADDR and the VAULT_TOKEN environment variables, or the address
+can be set with the -vault-address flag. When renewing the certificate
+fails, the current one is served until it expires, and the renewal is
+retried in the next reload round of the listener, see the
+-tls-reload-interval flag. The certificates issued by Vault are served
+in addition to the ones loaded from the files or from Kubernetes
+secrets.
+
+The package implements also a source of secret keys, loading them from
+the KV secrets engine, version 1 or 2, that can be used as the source of
+the keyrings of the filters:
+
+    skipper -secrets cookies=vault:secret/data/skipper/cookies#keys
+*/
+package vault
@@ -0,0 +1,154 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultKVField = "keys"
+
+var errMissingKVPath = errors.New("vault: KV secret path required")
+
+// KVOptions for creating a KV source.
+type KVOptions struct {
+
+	// The address of the Vault server. Default: the value of the
+	// VAULT_ADDR environment variable.
+	Address string
+
+	// The Vault token. Default: the value of the VAULT_TOKEN
+	// environment variable.
+	Token string
+
+	// The path of the secret, e.g. secret/data/skipper/cookies for
+	// the version 2 of the KV secrets engine, or secret/skipper/cookies
+	// for the version 1. Required.
+	Path string
+
+	// The field of the secret containing the keys, separated by commas
+	// or new lines, or as a list. Default: keys.
+	Field string
+
+	// Timeout of the requests to Vault. Default: 30s.
+	Timeout time.Duration
+}
+
+// KVSource loads secret keys from the KV secrets engine of Vault. It
+// implements the secrets.Source interface.
+type KVSource struct {
+	options KVOptions
+	client  *http.Client
+}
+
+type kvResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []string               `json:"errors"`
+}
+
+// NewKVSource creates a KV source.
+func NewKVSource(o KVOptions) (*KVSource, error) {
+	if o.Address == "" {
+		o.Address = os.Getenv(addressEnvVar)
+	}
+
+	if o.Token == "" {
+		o.Token = os.Getenv(tokenEnvVar)
+	}
+
+	if o.Field == "" {
+		o.Field = defaultKVField
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+
+	switch {
+	case o.Address == "":
+		return nil, errMissingAddress
+	case o.Token == "":
+		return nil, errMissingToken
+	case o.Path == "":
+		return nil, errMissingKVPath
+	}
+
+	o.Address = strings.TrimSuffix(o.Address, "/")
+	o.Path = strings.Trim(o.Path, "/")
+	return &KVSource{
+		options: o,
+		client:  &http.Client{Timeout: o.Timeout},
+	}, nil
+}
+
+func splitKeys(s string) [][]byte {
+	var keys [][]byte
+	for _, k := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == ',' }) {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, []byte(k))
+		}
+	}
+
+	return keys
+}
+
+// Keys reads the secret, and returns the keys from its configured field.
+func (s *KVSource) Keys() ([][]byte, error) {
+	req, err := http.NewRequest("GET", s.options.Address+"/v1/"+s.options.Path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-Vault-Token", s.options.Token)
+	rsp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	b, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var r kvResponse
+	if err := json.Unmarshal(b, &r); err != nil && rsp.StatusCode == http.StatusOK {
+		return nil, err
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault: failed to read secret: %d, %s", rsp.StatusCode, strings.Join(r.Errors, "; "))
+	}
+
+	// the version 2 of the KV engine nests the data of the secret
+	data := r.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+
+	switch v := data[s.options.Field].(type) {
+	case string:
+		return splitKeys(v), nil
+	case []interface{}:
+		var keys [][]byte
+		for _, vi := range v {
+			k, ok := vi.(string)
+			if !ok {
+				return nil, fmt.Errorf("vault: invalid key in field: %s", s.options.Field)
+			}
+
+			keys = append(keys, []byte(k))
+		}
+
+		return keys, nil
+	default:
+		return nil, fmt.Errorf("vault: field not found in secret: %s", s.options.Field)
+	}
+}
@@ -0,0 +1,96 @@
+package vault
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKVSource(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors": ["permission denied"]}`))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/cookies":
+			w.Write([]byte(`{"data": {"data": {"keys": "new-key, old-key"}, "metadata": {"version": 2}}}`))
+		case "/v1/secret/cookies":
+			w.Write([]byte(`{"data": {"keys": ["new-key", "old-key"], "other": 42}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": []}`))
+		}
+	}))
+	defer s.Close()
+
+	for _, test := range []struct {
+		title string
+		path  string
+		field string
+		token string
+		fail  bool
+	}{{
+		title: "KV version 2",
+		path:  "secret/data/cookies",
+	}, {
+		title: "KV version 1",
+		path:  "/secret/cookies",
+	}, {
+		title: "missing field",
+		path:  "secret/cookies",
+		field: "foo",
+		fail:  true,
+	}, {
+		title: "invalid field",
+		path:  "secret/cookies",
+		field: "other",
+		fail:  true,
+	}, {
+		title: "not found",
+		path:  "secret/foo",
+		fail:  true,
+	}, {
+		title: "forbidden",
+		path:  "secret/cookies",
+		token: "foo",
+		fail:  true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			token := test.token
+			if token == "" {
+				token = "test-token"
+			}
+
+			src, err := NewKVSource(KVOptions{Address: s.URL, Token: token, Path: test.path, Field: test.field})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			keys, err := src.Keys()
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(keys) != 2 || string(keys[0]) != "new-key" || string(keys[1]) != "old-key" {
+				t.Errorf("invalid keys: %q", keys)
+			}
+		})
+	}
+}
+
+func TestKVSourceOptions(t *testing.T) {
+	if _, err := NewKVSource(KVOptions{Address: "https://vault", Token: "foo"}); err != errMissingKVPath {
+		t.Errorf("failed to fail without path: %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/tlsconfig"
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+	addressEnvVar  = "VAULT_ADDR"
+	tokenEnvVar    = "VAULT_TOKEN"
+)
+
+var (
+	errMissingAddress    = errors.New("vault: address required")
+	errMissingToken      = errors.New("vault: token required")
+	errMissingPath       = errors.New("vault: PKI issue path required")
+	errMissingCommonName = errors.New("vault: common name required")
+)
+
+// Options for creating a PKI provider.
+type Options struct {
+
+	// The address of the Vault server. Default: the value of the
+	// VAULT_ADDR environment variable.
+	Address string
+
+	// The Vault token. Default: the value of the VAULT_TOKEN
+	// environment variable.
+	Token string
+
+	// The path of the issue endpoint of the PKI secrets engine,
+	// including the role, e.g. pki/issue/skipper. Required.
+	Path string
+
+	// The common name of the requested certificate. Required.
+	CommonName string
+
+	// The subject alternative names of the requested certificate.
+	AltNames []string
+
+	// The requested lifetime of the certificate. Default: the TTL of
+	// the role.
+	TTL time.Duration
+
+	// The time before the expiry when the certificate is renewed.
+	// Default: one third of its lifetime.
+	RenewBefore time.Duration
+
+	// Timeout of the requests to Vault. Default: 30s.
+	Timeout time.Duration
+}
+
+type issueRequest struct {
+	CommonName string `json:"common_name"`
+	AltNames   string `json:"alt_names,omitempty"`
+	TTL        string `json:"ttl,omitempty"`
+}
+
+type issueResponse struct {
+	Data struct {
+		Certificate string   `json:"certificate"`
+		PrivateKey  string   `json:"private_key"`
+		IssuingCA   string   `json:"issuing_ca"`
+		CAChain     []string `json:"ca_chain"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// PKIProvider issues a TLS certificate with the PKI secrets engine of
+// Vault, and renews it before it expires. It implements the
+// tlsconfig.Provider interface.
+type PKIProvider struct {
+	options   Options
+	client    *http.Client
+	mx        sync.Mutex
+	current   *tlsconfig.PEMKeyPair
+	notBefore time.Time
+	notAfter  time.Time
+}
+
+// NewPKIProvider creates a PKI provider. The certificate is issued on
+// the first call to KeyPairs.
+func NewPKIProvider(o Options) (*PKIProvider, error) {
+	if o.Address == "" {
+		o.Address = os.Getenv(addressEnvVar)
+	}
+
+	if o.Token == "" {
+		o.Token = os.Getenv(tokenEnvVar)
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+
+	switch {
+	case o.Address == "":
+		return nil, errMissingAddress
+	case o.Token == "":
+		return nil, errMissingToken
+	case o.Path == "":
+		return nil, errMissingPath
+	case o.CommonName == "":
+		return nil, errMissingCommonName
+	}
+
+	o.Address = strings.TrimSuffix(o.Address, "/")
+	o.Path = strings.Trim(o.Path, "/")
+	return &PKIProvider{
+		options: o,
+		client:  &http.Client{Timeout: o.Timeout},
+	}, nil
+}
+
+func (p *PKIProvider) issue() (*issueResponse, error) {
+	ir := issueRequest{
+		CommonName: p.options.CommonName,
+		AltNames:   strings.Join(p.options.AltNames, ","),
+	}
+
+	if p.options.TTL > 0 {
+		ir.TTL = p.options.TTL.String()
+	}
+
+	b, err := json.Marshal(ir)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", p.options.Address+"/v1/"+p.options.Path, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-Vault-Token", p.options.Token)
+	req.Header.Set("Content-Type", "application/json")
+	rsp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	b, err = ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var r issueResponse
+	if err := json.Unmarshal(b, &r); err != nil && rsp.StatusCode == http.StatusOK {
+		return nil, err
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault: failed to issue certificate: %d, %s", rsp.StatusCode, strings.Join(r.Errors, "; "))
+	}
+
+	return &r, nil
+}
+
+// the certificate followed by the chain, without the root
+func certificateChain(r *issueResponse) []byte {
+	chain := []string{r.Data.Certificate}
+	if len(r.Data.CAChain) > 0 {
+		chain = append(chain, r.Data.CAChain...)
+	} else if r.Data.IssuingCA != "" {
+		chain = append(chain, r.Data.IssuingCA)
+	}
+
+	return []byte(strings.Join(chain, "\n") + "\n")
+}
+
+func (p *PKIProvider) renewAt() time.Time {
+	if p.options.RenewBefore > 0 {
+		return p.notAfter.Add(-p.options.RenewBefore)
+	}
+
+	return p.notAfter.Add(-p.notAfter.Sub(p.notBefore) / 3)
+}
+
+// KeyPairs returns the issued certificate, and renews it, when it is
+// about to expire. When the renewal fails, the current certificate is
+// returned until it expires, and the renewal is retried on the next
+// call.
+func (p *PKIProvider) KeyPairs() ([]tlsconfig.PEMKeyPair, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	now := time.Now()
+	if p.current != nil && now.Before(p.renewAt()) {
+		return []tlsconfig.PEMKeyPair{*p.current}, nil
+	}
+
+	r, err := p.issue()
+	if err == nil {
+		err = p.update(r)
+	}
+
+	if err != nil {
+		if p.current != nil && now.Before(p.notAfter) {
+			log.Errorf("error while renewing the certificate from vault: %v", err)
+			return []tlsconfig.PEMKeyPair{*p.current}, nil
+		}
+
+		return nil, err
+	}
+
+	log.Infof("certificate issued by vault for %s, expires: %v", p.options.CommonName, p.notAfter)
+	return []tlsconfig.PEMKeyPair{*p.current}, nil
+}
+
+func (p *PKIProvider) update(r *issueResponse) error {
+	block, _ := pem.Decode([]byte(r.Data.Certificate))
+	if block == nil {
+		return errors.New("vault: invalid certificate")
+	}
+
+	c, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return err
+	}
+
+	p.current = &tlsconfig.PEMKeyPair{
+		Name: "vault:" + p.options.Path + "/" + p.options.CommonName,
+		Cert: certificateChain(r),
+		Key:  []byte(r.Data.PrivateKey),
+	}
+
+	p.notBefore = c.NotBefore
+	p.notAfter = c.NotAfter
+	return nil
+}
@@ -0,0 +1,246 @@
+package vault
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testVault struct {
+	t        *testing.T
+	mx       sync.Mutex
+	requests int
+	fail     bool
+	ca       *x509.Certificate
+	caKey    *ecdsa.PrivateKey
+	caPEM    string
+}
+
+func newTestVault(t *testing.T) *testVault {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testVault{
+		t:     t,
+		ca:    ca,
+		caKey: caKey,
+		caPEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+	}
+}
+
+func (v *testVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mx.Lock()
+	defer v.mx.Unlock()
+	v.requests++
+
+	if r.Method != "POST" || r.URL.Path != "/v1/pki/issue/skipper" || r.Header.Get("X-Vault-Token") != "test-token" {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors": ["permission denied"]}`))
+		return
+	}
+
+	if v.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"errors": ["internal error"]}`))
+		return
+	}
+
+	var ir issueRequest
+	if err := json.NewDecoder(r.Body).Decode(&ir); err != nil {
+		v.t.Error(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		v.t.Error(err)
+		return
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: ir.CommonName},
+		DNSNames:     []string{ir.CommonName, ir.AltNames},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, v.ca, &key.PublicKey, v.caKey)
+	if err != nil {
+		v.t.Error(err)
+		return
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		v.t.Error(err)
+		return
+	}
+
+	var rsp issueResponse
+	rsp.Data.Certificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	rsp.Data.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	rsp.Data.IssuingCA = v.caPEM
+	json.NewEncoder(w).Encode(rsp)
+}
+
+func (v *testVault) setFail(fail bool) {
+	v.mx.Lock()
+	defer v.mx.Unlock()
+	v.fail = fail
+}
+
+func (v *testVault) requestCount() int {
+	v.mx.Lock()
+	defer v.mx.Unlock()
+	return v.requests
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for _, o := range []Options{
+		{Token: "t", Path: "pki/issue/skipper", CommonName: "www.example.org"},
+		{Address: "http://vault", Path: "pki/issue/skipper", CommonName: "www.example.org"},
+		{Address: "http://vault", Token: "t", CommonName: "www.example.org"},
+		{Address: "http://vault", Token: "t", Path: "pki/issue/skipper"},
+	} {
+		if _, err := NewPKIProvider(o); err == nil {
+			t.Error("failed to fail", o)
+		}
+	}
+}
+
+func TestIssuesCertificate(t *testing.T) {
+	v := newTestVault(t)
+	s := httptest.NewServer(v)
+	defer s.Close()
+
+	p, err := NewPKIProvider(Options{
+		Address:    s.URL,
+		Token:      "test-token",
+		Path:       "/pki/issue/skipper/",
+		CommonName: "www.example.org",
+		AltNames:   []string{"example.org"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pairs, err := p.KeyPairs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pairs) != 1 || pairs[0].Name != "vault:pki/issue/skipper/www.example.org" {
+		t.Fatal("invalid key pairs", pairs)
+	}
+
+	c, err := tls.X509KeyPair(pairs[0].Cert, pairs[0].Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.Certificate) != 2 {
+		t.Error("the issuing CA is missing from the chain")
+	}
+
+	// cached until renewal
+	if _, err := p.KeyPairs(); err != nil || v.requestCount() != 1 {
+		t.Error("failed to cache the certificate", err, v.requestCount())
+	}
+}
+
+func TestRenewsCertificate(t *testing.T) {
+	v := newTestVault(t)
+	s := httptest.NewServer(v)
+	defer s.Close()
+
+	p, err := NewPKIProvider(Options{
+		Address:     s.URL,
+		Token:       "test-token",
+		Path:        "pki/issue/skipper",
+		CommonName:  "www.example.org",
+		RenewBefore: 2 * time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := p.KeyPairs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := p.KeyPairs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v.requestCount() != 2 || string(first[0].Key) == string(second[0].Key) {
+		t.Error("failed to renew the certificate")
+	}
+
+	// the current certificate is served while it is valid
+	v.setFail(true)
+	third, err := p.KeyPairs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(third[0].Key) != string(second[0].Key) {
+		t.Error("failed to keep the current certificate")
+	}
+}
+
+func TestIssueFails(t *testing.T) {
+	v := newTestVault(t)
+	s := httptest.NewServer(v)
+	defer s.Close()
+
+	p, err := NewPKIProvider(Options{
+		Address:    s.URL,
+		Token:      "invalid-token",
+		Path:       "pki/issue/skipper",
+		CommonName: "www.example.org",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.KeyPairs(); err == nil {
+		t.Error("failed to fail")
+	}
+}